@@ -77,83 +77,81 @@ func (abi ABI) Pack(name string, args ...interface{}) ([]byte, error) {
 	return append(method.Id(), arguments...), nil
 }
 
-// toGoSliceType prses the input and casts it to the proper slice defined by the ABI
-// argument in T.
-func toGoSlice(i int, t Argument, output []byte) (interface{}, error) {
-	index := i * 32
-	// The slice must, at very least be large enough for the index+32 which is exactly the size required
-	// for the [offset in output, size of offset].
+// readLength reads the word at index of output as an offset or a length and
+// makes sure it doesn't point beyond the output.
+func readLength(index int, output []byte) (int, error) {
 	if index+32 > len(output) {
-		return nil, fmt.Errorf("abi: cannot marshal in to go slice: insufficient size output %d require %d", len(output), index+32)
+		return 0, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), index+32)
 	}
-	elem := t.Type.Elem
+	num := new(big.Int).SetBytes(output[index : index+32])
+	if num.BitLen() > 63 || num.Int64() > int64(len(output)) {
+		return 0, fmt.Errorf("abi: cannot marshal in to go type: offset %v would go over slice boundary (len=%d)", num, len(output))
+	}
+	return int(num.Int64()), nil
+}
 
-	// first we need to create a slice of the type
+// toGoSlice parses size consecutive elements of the array or slice type t from
+// output and casts them to a Go slice (or array) of the element type.
+func toGoSlice(t Type, output []byte, size int) (interface{}, error) {
+	elemSize := getTypeSize(*t.Elem)
+	// the output must at the very least hold the heads of all elements
+	if size*elemSize > len(output) {
+		return nil, fmt.Errorf("abi: cannot marshal in to go slice: insufficient size output %d require %d", len(output), size*elemSize)
+	}
 	var refSlice reflect.Value
-	switch elem.T {
-	case IntTy, UintTy, BoolTy: // int, uint, bool can all be of type big int.
-		refSlice = reflect.ValueOf([]*big.Int(nil))
-	case AddressTy: // address must be of slice Address
-		refSlice = reflect.ValueOf([]common.Address(nil))
-	case HashTy: // hash must be of slice hash
-		refSlice = reflect.ValueOf([]common.Hash(nil))
-	case FixedBytesTy:
-		refSlice = reflect.ValueOf([]byte(nil))
-	default: // no other types are supported
-		return nil, fmt.Errorf("abi: unsupported slice type %v", elem.T)
+	if t.T == SliceTy {
+		refSlice = reflect.MakeSlice(t.Type, size, size)
+	} else {
+		refSlice = reflect.New(t.Type).Elem()
 	}
-	// get the offset which determines the start of this array ...
-	offset := int(new(big.Int).SetBytes(output[index : index+32]).Uint64())
-	if offset+32 > len(output) {
-		return nil, fmt.Errorf("abi: cannot marshal in to go slice: offset %d would go over slice boundary (len=%d)", len(output), offset+32)
+	// offsets of dynamic elements are relative to the start of the elements
+	for i := 0; i < size; i++ {
+		elem, err := toGoElem(i*elemSize, *t.Elem, output)
+		if err != nil {
+			return nil, err
+		}
+		refSlice.Index(i).Set(elem)
 	}
+	return refSlice.Interface(), nil
+}
 
-	slice := output[offset:]
-	// ... starting with the size of the array in elements ...
-	size := int(new(big.Int).SetBytes(slice[:32]).Uint64())
-	slice = slice[32:]
-	// ... and make sure that we've at the very least the amount of bytes
-	// available in the buffer.
-	if size*32 > len(slice) {
-		return nil, fmt.Errorf("abi: cannot marshal in to go slice: insufficient size output %d require %d", len(output), offset+32+size*32)
-	}
+// toGoTuple parses the components of the tuple type t from output and casts
+// them to the fields of a Go struct.
+func toGoTuple(t Type, output []byte) (interface{}, error) {
+	refStruct := reflect.New(t.Type).Elem()
 
-	// reslice to match the required size
-	slice = slice[:(size * 32)]
-	for i := 0; i < size; i++ {
-		var (
-			inter        interface{}             // interface type
-			returnOutput = slice[i*32 : i*32+32] // the return output
-		)
-
-		// set inter to the correct type (cast)
-		switch elem.T {
-		case IntTy, UintTy:
-			inter = new(big.Int).SetBytes(returnOutput)
-		case BoolTy:
-			inter = new(big.Int).SetBytes(returnOutput).Uint64() > 0
-		case AddressTy:
-			inter = common.BytesToAddress(returnOutput)
-		case HashTy:
-			inter = common.BytesToHash(returnOutput)
+	// offsets of dynamic components are relative to the start of the tuple
+	index := 0
+	for i, elem := range t.TupleElems {
+		field, err := toGoElem(index, *elem, output)
+		if err != nil {
+			return nil, err
 		}
-		// append the item to our reflect slice
-		refSlice = reflect.Append(refSlice, reflect.ValueOf(inter))
+		refStruct.Field(i).Set(field)
+		index += getTypeSize(*elem)
 	}
-
-	// return the interface
-	return refSlice.Interface(), nil
+	return refStruct.Interface(), nil
 }
 
-// toGoType parses the input and casts it to the proper type defined by the ABI
-// argument in T.
-func toGoType(i int, t Argument, output []byte) (interface{}, error) {
-	// we need to treat slices differently
-	if (t.Type.IsSlice || t.Type.IsArray) && t.Type.T != BytesTy && t.Type.T != StringTy && t.Type.T != FixedBytesTy {
-		return toGoSlice(i, t, output)
+// toGoElem parses an element of an array or tuple, casting it to the Go type of
+// its abi type.
+func toGoElem(index int, t Type, output []byte) (reflect.Value, error) {
+	val, err := toGoType(index, t, output)
+	if err != nil {
+		return reflect.Value{}, err
 	}
+	// fixed bytes are returned as the full word, trim them to their array type
+	if t.T == FixedBytesTy {
+		array := reflect.New(t.Type).Elem()
+		reflect.Copy(array, reflect.ValueOf(val))
+		return array, nil
+	}
+	return reflect.ValueOf(val), nil
+}
 
-	index := i * 32
+// toGoType parses the input at the given byte index and casts it to the proper
+// type defined by the ABI type t.
+func toGoType(index int, t Type, output []byte) (interface{}, error) {
 	if index+32 > len(output) {
 		return nil, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), index+32)
 	}
@@ -161,16 +159,46 @@ func toGoType(i int, t Argument, output []byte) (interface{}, error) {
 	// Parse the given index output and check whether we need to read
 	// a different offset and length based on the type (i.e. string, bytes)
 	var returnOutput []byte
-	switch t.Type.T {
+	switch t.T {
+	case TupleTy, ArrayTy:
+		// static tuples and arrays are stored in place, dynamic ones at
+		// the end of the return bytes
+		data := output[index:]
+		if isDynamicType(t) {
+			offset, err := readLength(index, output)
+			if err != nil {
+				return nil, err
+			}
+			data = output[offset:]
+		}
+		if t.T == TupleTy {
+			return toGoTuple(t, data)
+		}
+		return toGoSlice(t, data, t.SliceSize)
+	case SliceTy:
+		// slices are written at the end of the return bytes, starting with
+		// their size in elements
+		offset, err := readLength(index, output)
+		if err != nil {
+			return nil, err
+		}
+		size, err := readLength(offset, output)
+		if err != nil {
+			return nil, err
+		}
+		return toGoSlice(t, output[offset+32:], size)
 	case StringTy, BytesTy: // variable arrays are written at the end of the return bytes
 		// parse offset from which we should start reading
-		offset := int(new(big.Int).SetBytes(output[index : index+32]).Uint64())
+		offset, err := readLength(index, output)
+		if err != nil {
+			return nil, err
+		}
 		if offset+32 > len(output) {
 			return nil, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), offset+32)
 		}
 		// parse the size up until we should be reading
 		size := int(new(big.Int).SetBytes(output[offset : offset+32]).Uint64())
-		if offset+32+size > len(output) {
+		if size < 0 || offset+32+size > len(output) {
 			return nil, fmt.Errorf("abi: cannot marshal in to go type: length insufficient %d require %d", len(output), offset+32+size)
 		}
 
@@ -181,13 +209,13 @@ func toGoType(i int, t Argument, output []byte) (interface{}, error) {
 	}
 
 	// convert the bytes to whatever is specified by the ABI.
-	switch t.Type.T {
+	switch t.T {
 	case IntTy, UintTy:
 		bigNum := new(big.Int).SetBytes(returnOutput)
 
 		// If the type is a integer convert to the integer type
 		// specified by the ABI.
-		switch t.Type.Kind {
+		switch t.Kind {
 		case reflect.Uint8:
 			return uint8(bigNum.Uint64()), nil
 		case reflect.Uint16:
@@ -218,7 +246,7 @@ func toGoType(i int, t Argument, output []byte) (interface{}, error) {
 	case StringTy:
 		return string(returnOutput), nil
 	}
-	return nil, fmt.Errorf("abi: unknown type %v", t.Type.T)
+	return nil, fmt.Errorf("abi: unknown type %v", t.T)
 }
 
 // these variable are used to determine certain types during type assertion for
@@ -228,9 +256,40 @@ var (
 	r_byte       = reflect.TypeOf(byte(0))
 )
 
-// Unpack output in v according to the abi specification
+// unpackValues parses all the output arguments from the return bytes. Static
+// arrays and tuples are stored in place, so an argument's head may span more
+// than a single word.
+func unpackValues(args []Argument, output []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(args))
+
+	index := 0
+	for i, arg := range args {
+		value, err := toGoType(index, arg.Type, output)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+		index += getTypeSize(arg.Type)
+	}
+	return values, nil
+}
+
+// Unpack output in v according to the abi specification. The name may either
+// be a method, in which case its return values are unpacked, or an event, in
+// which case its non-indexed inputs are unpacked from the log data.
 func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
-	var method = abi.Methods[name]
+	var outputs []Argument
+	if method, ok := abi.Methods[name]; ok {
+		outputs = method.Outputs
+	} else if event, ok := abi.Events[name]; ok {
+		for _, input := range event.Inputs {
+			if !input.Indexed {
+				outputs = append(outputs, input)
+			}
+		}
+	} else {
+		return fmt.Errorf("abi: could not locate named method or event")
+	}
 
 	if len(output) == 0 {
 		return fmt.Errorf("abi: unmarshalling empty output")
@@ -247,88 +306,76 @@ func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
 		typ   = value.Type()
 	)
 
-	if len(method.Outputs) > 1 {
-		switch value.Kind() {
-		// struct will match named return values to the struct's field
-		// names
-		case reflect.Struct:
-			for i := 0; i < len(method.Outputs); i++ {
-				marshalledValue, err := toGoType(i, method.Outputs[i], output)
-				if err != nil {
-					return err
-				}
-				reflectValue := reflect.ValueOf(marshalledValue)
-
-				for j := 0; j < typ.NumField(); j++ {
-					field := typ.Field(j)
-					// TODO read tags: `abi:"fieldName"`
-					if field.Name == strings.ToUpper(method.Outputs[i].Name[:1])+method.Outputs[i].Name[1:] {
-						if err := set(value.Field(j), reflectValue, method.Outputs[i]); err != nil {
-							return err
-						}
-					}
-				}
-			}
-		case reflect.Slice:
-			if !value.Type().AssignableTo(r_interSlice) {
-				return fmt.Errorf("abi: cannot marshal tuple in to slice %T (only []interface{} is supported)", v)
+	marshalledValues, err := unpackValues(outputs, output)
+	if err != nil {
+		return err
+	}
+	// a single value is set directly, unless it's destined to a named field
+	// of a struct (e.g. the only non-indexed input of an event)
+	if len(outputs) == 1 && (value.Kind() != reflect.Struct || outputs[0].Type.T == TupleTy) {
+		return set(value, reflect.ValueOf(marshalledValues[0]), outputs[0].Type)
+	}
+	switch value.Kind() {
+	// struct will match named return values to the struct's field
+	// names
+	case reflect.Struct:
+		for i := 0; i < len(outputs); i++ {
+			if outputs[i].Name == "" {
+				continue
 			}
+			reflectValue := reflect.ValueOf(marshalledValues[i])
 
-			// if the slice already contains values, set those instead of the interface slice itself.
-			if value.Len() > 0 {
-				if len(method.Outputs) > value.Len() {
-					return fmt.Errorf("abi: cannot marshal in to slices of unequal size (require: %v, got: %v)", len(method.Outputs), value.Len())
-				}
-
-				for i := 0; i < len(method.Outputs); i++ {
-					marshalledValue, err := toGoType(i, method.Outputs[i], output)
-					if err != nil {
-						return err
-					}
-					reflectValue := reflect.ValueOf(marshalledValue)
-					if err := set(value.Index(i).Elem(), reflectValue, method.Outputs[i]); err != nil {
+			for j := 0; j < typ.NumField(); j++ {
+				field := typ.Field(j)
+				// TODO read tags: `abi:"fieldName"`
+				if field.Name == strings.ToUpper(outputs[i].Name[:1])+outputs[i].Name[1:] {
+					if err := set(value.Field(j), reflectValue, outputs[i].Type); err != nil {
 						return err
 					}
 				}
-				return nil
+			}
+		}
+	case reflect.Slice:
+		if !value.Type().AssignableTo(r_interSlice) {
+			return fmt.Errorf("abi: cannot marshal tuple in to slice %T (only []interface{} is supported)", v)
+		}
+
+		// if the slice already contains values, set those instead of the interface slice itself.
+		if value.Len() > 0 {
+			if len(outputs) > value.Len() {
+				return fmt.Errorf("abi: cannot marshal in to slices of unequal size (require: %v, got: %v)", len(outputs), value.Len())
 			}
 
-			// create a new slice and start appending the unmarshalled
-			// values to the new interface slice.
-			z := reflect.MakeSlice(typ, 0, len(method.Outputs))
-			for i := 0; i < len(method.Outputs); i++ {
-				marshalledValue, err := toGoType(i, method.Outputs[i], output)
-				if err != nil {
+			for i := 0; i < len(outputs); i++ {
+				reflectValue := reflect.ValueOf(marshalledValues[i])
+				if err := set(value.Index(i).Elem(), reflectValue, outputs[i].Type); err != nil {
 					return err
 				}
-				z = reflect.Append(z, reflect.ValueOf(marshalledValue))
 			}
-			value.Set(z)
-		default:
-			return fmt.Errorf("abi: cannot unmarshal tuple in to %v", typ)
+			return nil
 		}
 
-	} else {
-		marshalledValue, err := toGoType(0, method.Outputs[0], output)
-		if err != nil {
-			return err
-		}
-		if err := set(value, reflect.ValueOf(marshalledValue), method.Outputs[0]); err != nil {
-			return err
+		// create a new slice and start appending the unmarshalled
+		// values to the new interface slice.
+		z := reflect.MakeSlice(typ, 0, len(outputs))
+		for i := 0; i < len(outputs); i++ {
+			z = reflect.Append(z, reflect.ValueOf(marshalledValues[i]))
 		}
+		value.Set(z)
+	default:
+		return fmt.Errorf("abi: cannot unmarshal tuple in to %v", typ)
 	}
-
 	return nil
 }
 
 func (abi *ABI) UnmarshalJSON(data []byte) error {
 	var fields []struct {
-		Type     string
-		Name     string
-		Constant bool
-		Indexed  bool
-		Inputs   []Argument
-		Outputs  []Argument
+		Type      string
+		Name      string
+		Constant  bool
+		Anonymous bool
+		Inputs    []Argument
+		Outputs   []Argument
 	}
 
 	if err := json.Unmarshal(data, &fields); err != nil {
@@ -353,8 +400,9 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 			}
 		case "event":
 			abi.Events[field.Name] = Event{
-				Name:   field.Name,
-				Inputs: field.Inputs,
+				Name:      field.Name,
+				Anonymous: field.Anonymous,
+				Inputs:    field.Inputs,
 			}
 		}
 	}
@@ -357,8 +357,6 @@ func TestMethodPack(t *testing.T) {
 	}
 
 	sig := abi.Methods["slice"].Id()
-	sig = append(sig, common.LeftPadBytes([]byte{32}, 32)...)
-	sig = append(sig, common.LeftPadBytes([]byte{2}, 32)...)
 	sig = append(sig, common.LeftPadBytes([]byte{1}, 32)...)
 	sig = append(sig, common.LeftPadBytes([]byte{2}, 32)...)
 
@@ -406,8 +404,6 @@ func TestMethodPack(t *testing.T) {
 	}
 
 	sig = abi.Methods["slice256"].Id()
-	sig = append(sig, common.LeftPadBytes([]byte{32}, 32)...)
-	sig = append(sig, common.LeftPadBytes([]byte{2}, 32)...)
 	sig = append(sig, common.LeftPadBytes([]byte{1}, 32)...)
 	sig = append(sig, common.LeftPadBytes([]byte{2}, 32)...)
 
@@ -1150,3 +1146,245 @@ func TestUnmarshal(t *testing.T) {
 		t.Fatal("expected error:", err)
 	}
 }
+
+func TestNewTypeNested(t *testing.T) {
+	for i, test := range []struct {
+		def    string
+		kind   string
+		goType interface{}
+	}{
+		{`{"type": "uint8[2][]"}`, "uint8[2][]", [][2]uint8(nil)},
+		{`{"type": "int[][3]"}`, "int256[][3]", [3][]*big.Int{}},
+		{`{"type": "bytes32[2][2]"}`, "bytes32[2][2]", [2][2][32]byte{}},
+		{`{"type": "string[]"}`, "string[]", []string(nil)},
+		{
+			`{"type": "tuple", "components": [{"name": "a", "type": "uint256"}, {"name": "b_c", "type": "address[]"}]}`,
+			"(uint256,address[])",
+			struct {
+				A  *big.Int
+				BC []common.Address
+			}{},
+		},
+		{
+			`{"type": "tuple[2][]", "components": [{"name": "x", "type": "tuple", "components": [{"name": "y", "type": "bool"}]}]}`,
+			"((bool))[2][]",
+			[][2]struct{ X struct{ Y bool } }(nil),
+		},
+	} {
+		var arg Argument
+		if err := arg.UnmarshalJSON([]byte(test.def)); err != nil {
+			t.Errorf("%d: failed to parse type: %v", i, err)
+			continue
+		}
+		if arg.Type.String() != test.kind {
+			t.Errorf("%d: type string mismatch: have %s, want %s", i, arg.Type, test.kind)
+		}
+		if want := reflect.TypeOf(test.goType); arg.Type.Type != want {
+			t.Errorf("%d: go type mismatch: have %v, want %v", i, arg.Type.Type, want)
+		}
+	}
+	for i, def := range []string{"uint[", "uint]", "uint[a]", "tuple", "tuple[]", "bytes32[9000000000000000000]", "bool[65536][65536]"} {
+		if _, err := NewType(def); err == nil {
+			t.Errorf("%d: expected error for %q", i, def)
+		}
+	}
+}
+
+// Tests the encoding examples of the contract ABI specification.
+func TestPackSpecExamples(t *testing.T) {
+	const definition = `[
+	{ "name" : "f", "inputs": [ { "type": "uint" }, { "type": "uint32[]" }, { "type": "bytes10" }, { "type": "bytes" } ] },
+	{ "name" : "g", "inputs": [ { "type": "uint[][]" }, { "type": "string[]" } ] }]`
+
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bytes10 [10]byte
+	copy(bytes10[:], "1234567890")
+
+	packed, err := abi.Pack("f", big.NewInt(0x123), []uint32{0x456, 0x789}, bytes10, []byte("Hello, world!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := common.Hex2Bytes("8be65246" +
+		"0000000000000000000000000000000000000000000000000000000000000123" +
+		"0000000000000000000000000000000000000000000000000000000000000080" +
+		"3132333435363738393000000000000000000000000000000000000000000000" +
+		"00000000000000000000000000000000000000000000000000000000000000e0" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000456" +
+		"0000000000000000000000000000000000000000000000000000000000000789" +
+		"000000000000000000000000000000000000000000000000000000000000000d" +
+		"48656c6c6f2c20776f726c642100000000000000000000000000000000000000")
+	if !bytes.Equal(packed, exp) {
+		t.Errorf("f: expected %x, got %x", exp, packed)
+	}
+
+	ints := [][]*big.Int{{big.NewInt(1), big.NewInt(2)}, {big.NewInt(3)}}
+	strs := []string{"one", "two", "three"}
+
+	packed, err = abi.Pack("g", ints, strs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = common.Hex2Bytes("2289b18c" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000140" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"00000000000000000000000000000000000000000000000000000000000000a0" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000003" +
+		"0000000000000000000000000000000000000000000000000000000000000003" +
+		"0000000000000000000000000000000000000000000000000000000000000060" +
+		"00000000000000000000000000000000000000000000000000000000000000a0" +
+		"00000000000000000000000000000000000000000000000000000000000000e0" +
+		"0000000000000000000000000000000000000000000000000000000000000003" +
+		"6f6e650000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000003" +
+		"74776f0000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"7468726565000000000000000000000000000000000000000000000000000000")
+	if !bytes.Equal(packed, exp) {
+		t.Errorf("g: expected %x, got %x", exp, packed)
+	}
+
+	// The same encoding must unpack in to the original values
+	abi.Methods["g"] = Method{Name: "g", Outputs: abi.Methods["g"].Inputs}
+
+	var out struct {
+		Ints [][]*big.Int
+		Strs []string
+	}
+	out2 := []interface{}{&out.Ints, &out.Strs}
+	if err := abi.Unpack(&out2, "g", packed[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.Ints, ints) || !reflect.DeepEqual(out.Strs, strs) {
+		t.Errorf("g: unpack mismatch: have %v %v, want %v %v", out.Ints, out.Strs, ints, strs)
+	}
+}
+
+func TestTupleEncoding(t *testing.T) {
+	const definition = `[
+	{ "name" : "dynamic", "inputs": [ { "name": "s", "type": "tuple", "components": [ { "name": "a", "type": "uint256" }, { "name": "s", "type": "string" } ] } ],
+	  "outputs": [ { "name": "s", "type": "tuple", "components": [ { "name": "a", "type": "uint256" }, { "name": "s", "type": "string" } ] } ] },
+	{ "name" : "static", "inputs": [ { "name": "p", "type": "tuple", "components": [ { "name": "x", "type": "uint8" }, { "name": "y", "type": "bytes2[2]" } ] }, { "name": "b", "type": "bool" } ] }]`
+
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig := abi.Methods["dynamic"].Sig(); sig != "dynamic((uint256,string))" {
+		t.Errorf("signature mismatch: have %s", sig)
+	}
+	type dynamic struct {
+		A *big.Int
+		S string
+	}
+	packed, err := abi.Pack("dynamic", dynamic{big.NewInt(1), "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := common.Hex2Bytes(
+		"0000000000000000000000000000000000000000000000000000000000000020" + // offset of the tuple
+			"0000000000000000000000000000000000000000000000000000000000000001" + // a
+			"0000000000000000000000000000000000000000000000000000000000000040" + // offset of s in the tuple
+			"0000000000000000000000000000000000000000000000000000000000000002" +
+			"6869000000000000000000000000000000000000000000000000000000000000")
+	if !bytes.Equal(packed[4:], exp) {
+		t.Errorf("expected %x, got %x", exp, packed[4:])
+	}
+	// Unpack in to a struct of a different type with the same field names
+	var out struct {
+		S string
+		A *big.Int
+	}
+	if err := abi.Unpack(&out, "dynamic", exp); err != nil {
+		t.Fatal(err)
+	}
+	if out.A.Cmp(big.NewInt(1)) != 0 || out.S != "hi" {
+		t.Errorf("unpack mismatch: have %v/%v", out.A, out.S)
+	}
+	var missing struct{ A *big.Int }
+	if err := abi.Unpack(&missing, "dynamic", exp); err == nil {
+		t.Errorf("expected error for missing tuple field")
+	}
+
+	// Static tuples are packed in place
+	type static struct {
+		X uint8
+		Y [2][2]byte
+	}
+	packed, err = abi.Pack("static", &static{7, [2][2]byte{{1, 2}, {3, 4}}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = common.Hex2Bytes(
+		"0000000000000000000000000000000000000000000000000000000000000007" +
+			"0102000000000000000000000000000000000000000000000000000000000000" +
+			"0304000000000000000000000000000000000000000000000000000000000000" +
+			"0000000000000000000000000000000000000000000000000000000000000001")
+	if !bytes.Equal(packed[4:], exp) {
+		t.Errorf("expected %x, got %x", exp, packed[4:])
+	}
+	if _, err := abi.Pack("static", struct{ X uint8 }{7}, true); err == nil {
+		t.Errorf("expected error for missing tuple field")
+	}
+}
+
+func TestTupleSliceRoundTrip(t *testing.T) {
+	const definition = `[
+	{ "name" : "orders", "inputs": [ { "name": "orders", "type": "tuple[]", "components": [ { "name": "id", "type": "uint64" }, { "name": "owners", "type": "address[]" }, { "name": "tag", "type": "bytes" } ] }, { "name": "n", "type": "uint256" } ] }]`
+
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type order struct {
+		Id     uint64
+		Owners []common.Address
+		Tag    []byte
+	}
+	orders := []order{
+		{1, []common.Address{{1}, {2}}, []byte("first")},
+		{2, nil, []byte{}},
+		{3, []common.Address{{3}}, bytes.Repeat([]byte{0xff}, 40)},
+	}
+	packed, err := abi.Pack("orders", orders, big.NewInt(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abi.Methods["orders"] = Method{Name: "orders", Outputs: abi.Methods["orders"].Inputs}
+
+	var out struct {
+		Orders []order
+		N      *big.Int
+	}
+	if err := abi.Unpack(&out, "orders", packed[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if out.N.Cmp(big.NewInt(3)) != 0 || len(out.Orders) != len(orders) {
+		t.Fatalf("unpack mismatch: have %v", out)
+	}
+	for i, o := range out.Orders {
+		if o.Id != orders[i].Id || len(o.Owners) != len(orders[i].Owners) || !bytes.Equal(o.Tag, orders[i].Tag) {
+			t.Errorf("order %d mismatch: have %v, want %v", i, o, orders[i])
+		}
+		for j := range o.Owners {
+			if o.Owners[j] != orders[i].Owners[j] {
+				t.Errorf("order %d owner %d mismatch: have %x, want %x", i, j, o.Owners[j], orders[i].Owners[j])
+			}
+		}
+	}
+	// Truncated outputs must be rejected rather than panic
+	for i := 0; i < len(packed)-4; i += 32 {
+		if err := abi.Unpack(&out, "orders", packed[4:4+i]); err == nil && i > 0 {
+			t.Errorf("expected error for output truncated to %d bytes", i)
+		}
+	}
+}
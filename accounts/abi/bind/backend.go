@@ -22,6 +22,8 @@ import (
 
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/event"
 )
 
 // ErrNoCode is returned by call and transact operations for which the requested
@@ -72,14 +74,40 @@ type ContractTransactor interface {
 	SendTransaction(tx *types.Transaction) error
 }
 
+// FilterQuery contains options for contract log filtering.
+type FilterQuery struct {
+	FromBlock *big.Int         // beginning of the queried range, nil means genesis block
+	ToBlock   *big.Int         // end of the range, nil means latest block
+	Addresses []common.Address // restricts matches to events created by specific contracts
+
+	// The Topic list restricts matches to particular event topics. Each event has a list
+	// of topics. Topics matches a prefix of that list. An empty element slice matches any
+	// topic. Non-empty elements represent an alternative that matches any of the
+	// contained topics.
+	Topics [][]common.Hash
+}
+
+// ContractFilterer defines the methods needed to access log events using one-off
+// queries or continuous event subscriptions.
+type ContractFilterer interface {
+	// FilterLogs executes a log filter operation, blocking during execution and
+	// returning all the results in one batch.
+	FilterLogs(query FilterQuery) ([]vm.Log, error)
+
+	// SubscribeFilterLogs creates a background log filtering operation, returning
+	// a subscription immediately, which can be used to stream the found events.
+	SubscribeFilterLogs(query FilterQuery, ch chan<- vm.Log) (event.Subscription, error)
+}
+
 // ContractBackend defines the methods needed to allow operating with contract
 // on a read-write basis.
 //
-// This interface is essentially the union of ContractCaller and ContractTransactor
-// but due to a bug in the Go compiler (https://github.com/golang/go/issues/6977),
-// we cannot simply list it as the two interfaces. The other solution is to add a
-// third interface containing the common methods, but that convolutes the user API
-// as it introduces yet another parameter to require for initialization.
+// This interface is essentially the union of ContractCaller, ContractTransactor
+// and ContractFilterer but due to a bug in the Go compiler
+// (https://github.com/golang/go/issues/6977), we cannot simply list it as the
+// three interfaces. The other solution is to add a fourth interface containing
+// the common methods, but that convolutes the user API as it introduces yet
+// another parameter to require for initialization.
 type ContractBackend interface {
 	// HasCode checks if the contract at the given address has any code associated
 	// with it or not. This is needed to differentiate between contract internal
@@ -108,4 +136,12 @@ type ContractBackend interface {
 
 	// SendTransaction injects the transaction into the pending pool for execution.
 	SendTransaction(tx *types.Transaction) error
+
+	// FilterLogs executes a log filter operation, blocking during execution and
+	// returning all the results in one batch.
+	FilterLogs(query FilterQuery) ([]vm.Log, error)
+
+	// SubscribeFilterLogs creates a background log filtering operation, returning
+	// a subscription immediately, which can be used to stream the found events.
+	SubscribeFilterLogs(query FilterQuery, ch chan<- vm.Log) (event.Subscription, error)
 }
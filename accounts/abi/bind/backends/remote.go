@@ -22,14 +22,22 @@ import (
 	"math/big"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereumproject/go-ethereum/accounts/abi/bind"
 	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/common/hexutil"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/event"
 	"github.com/ethereumproject/go-ethereum/rlp"
 	"github.com/ethereumproject/go-ethereum/rpc"
 )
 
+// filterPollInterval is the time between two polls of a log filter installed
+// on the remote node for a subscription.
+var filterPollInterval = time.Second
+
 // This nil assignment ensures compile time that rpcBackend implements bind.ContractBackend.
 var _ bind.ContractBackend = (*rpcBackend)(nil)
 
@@ -243,3 +251,138 @@ func (b *rpcBackend) SendTransaction(tx *types.Transaction) error {
 	}
 	return nil
 }
+
+// filterArgs is the JSON RPC representation of a log filter query.
+type filterArgs struct {
+	FromBlock string           `json:"fromBlock,omitempty"`
+	ToBlock   string           `json:"toBlock,omitempty"`
+	Addresses []common.Address `json:"address,omitempty"`
+	Topics    [][]common.Hash  `json:"topics,omitempty"`
+}
+
+// toFilterArgs converts a filter query into its JSON RPC representation. The
+// missing range boundaries are left for the remote node to default.
+func toFilterArgs(query bind.FilterQuery) filterArgs {
+	args := filterArgs{
+		Addresses: query.Addresses,
+		Topics:    make([][]common.Hash, len(query.Topics)),
+	}
+	if query.FromBlock != nil {
+		args.FromBlock = hexutil.EncodeBig(query.FromBlock)
+	}
+	if query.ToBlock != nil {
+		args.ToBlock = hexutil.EncodeBig(query.ToBlock)
+	}
+	for i, topics := range query.Topics {
+		// An empty set of alternatives is a wildcard, sent as null
+		if len(topics) > 0 {
+			args.Topics[i] = topics
+		}
+	}
+	return args
+}
+
+// rpcLog is the JSON RPC representation of a contract log event.
+type rpcLog struct {
+	Address     common.Address `json:"address"`
+	Topics      []common.Hash  `json:"topics"`
+	Data        hexutil.Bytes  `json:"data"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	TxIndex     hexutil.Uint   `json:"transactionIndex"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Index       hexutil.Uint   `json:"logIndex"`
+}
+
+// decodeLogs parses a list of logs sent back by the remote node.
+func decodeLogs(res json.RawMessage) ([]vm.Log, error) {
+	var raw []rpcLog
+	if err := json.Unmarshal(res, &raw); err != nil {
+		return nil, err
+	}
+	logs := make([]vm.Log, len(raw))
+	for i, log := range raw {
+		logs[i] = vm.Log{
+			Address:     log.Address,
+			Topics:      log.Topics,
+			Data:        log.Data,
+			BlockNumber: uint64(log.BlockNumber),
+			TxHash:      log.TxHash,
+			TxIndex:     uint(log.TxIndex),
+			BlockHash:   log.BlockHash,
+			Index:       uint(log.Index),
+		}
+	}
+	return logs, nil
+}
+
+// FilterLogs implements ContractFilterer.FilterLogs, delegating the log search
+// to the remote node.
+func (b *rpcBackend) FilterLogs(query bind.FilterQuery) ([]vm.Log, error) {
+	res, err := b.request("eth_getLogs", []interface{}{toFilterArgs(query)})
+	if err != nil {
+		return nil, err
+	}
+	return decodeLogs(res)
+}
+
+// SubscribeFilterLogs implements ContractFilterer.SubscribeFilterLogs, installing
+// a log filter on the remote node and polling it for changes until the returned
+// subscription is torn down.
+func (b *rpcBackend) SubscribeFilterLogs(query bind.FilterQuery, ch chan<- vm.Log) (event.Subscription, error) {
+	res, err := b.request("eth_newFilter", []interface{}{toFilterArgs(query)})
+	if err != nil {
+		return nil, err
+	}
+	var id string
+	if err := json.Unmarshal(res, &id); err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer b.request("eth_uninstallFilter", []interface{}{id})
+
+		// Past logs requested by the query are delivered before the new ones
+		if query.FromBlock != nil {
+			res, err := b.request("eth_getFilterLogs", []interface{}{id})
+			if err != nil {
+				return err
+			}
+			logs, err := decodeLogs(res)
+			if err != nil {
+				return err
+			}
+			for _, log := range logs {
+				select {
+				case ch <- log:
+				case <-quit:
+					return nil
+				}
+			}
+		}
+		ticker := time.NewTicker(filterPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				res, err := b.request("eth_getFilterChanges", []interface{}{id})
+				if err != nil {
+					return err
+				}
+				logs, err := decodeLogs(res)
+				if err != nil {
+					return err
+				}
+				for _, log := range logs {
+					select {
+					case ch <- log:
+					case <-quit:
+						return nil
+					}
+				}
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
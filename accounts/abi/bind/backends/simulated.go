@@ -24,6 +24,8 @@ import (
 	"github.com/ethereumproject/go-ethereum/core"
 	"github.com/ethereumproject/go-ethereum/core/state"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/eth/filters"
 	"github.com/ethereumproject/go-ethereum/ethdb"
 	"github.com/ethereumproject/go-ethereum/event"
 )
@@ -36,6 +38,7 @@ var _ bind.ContractBackend = (*SimulatedBackend)(nil)
 type SimulatedBackend struct {
 	database   ethdb.Database   // In memory database to store our testing data
 	blockchain *core.BlockChain // Ethereum blockchain to handle the consensus
+	mux        *event.TypeMux   // Event mux the blockchain posts its chain events to
 
 	pendingBlock *types.Block   // Currently pending block that will be imported on request
 	pendingState *state.StateDB // Currently pending state that will be the active on on request
@@ -46,11 +49,13 @@ type SimulatedBackend struct {
 func NewSimulatedBackend(accounts ...core.GenesisAccount) *SimulatedBackend {
 	database, _ := ethdb.NewMemDatabase()
 	core.WriteGenesisBlockForTesting(database, accounts...)
-	blockchain, _ := core.NewBlockChain(database, core.DefaultConfigMorden.ChainConfig, new(core.FakePow), new(event.TypeMux))
+	mux := new(event.TypeMux)
+	blockchain, _ := core.NewBlockChain(database, core.DefaultConfigMorden.ChainConfig, new(core.FakePow), mux)
 
 	backend := &SimulatedBackend{
 		database:   database,
 		blockchain: blockchain,
+		mux:        mux,
 	}
 	backend.Rollback()
 
@@ -117,7 +122,7 @@ func (b *SimulatedBackend) ContractCall(contract common.Address, data []byte, pe
 		data:     data,
 	}
 	// Execute the call and return
-	vmenv := core.NewEnv(statedb, core.DefaultConfigMorden.ChainConfig, b.blockchain, msg, block.Header())
+	vmenv := core.NewEnv(statedb, core.DefaultConfigMorden.ChainConfig, b.blockchain, msg, block.Header(), vm.Config{})
 	gaspool := new(core.GasPool).AddGas(common.MaxBig)
 
 	out, _, _, err := core.ApplyMessage(vmenv, msg, gaspool)
@@ -167,7 +172,7 @@ func (b *SimulatedBackend) EstimateGasLimit(sender common.Address, contract *com
 		data:     data,
 	}
 	// Execute the call and return
-	vmenv := core.NewEnv(statedb, core.DefaultConfigMorden.ChainConfig, b.blockchain, msg, block.Header())
+	vmenv := core.NewEnv(statedb, core.DefaultConfigMorden.ChainConfig, b.blockchain, msg, block.Header(), vm.Config{})
 	gaspool := new(core.GasPool).AddGas(common.MaxBig)
 
 	_, gas, _, err := core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
@@ -189,6 +194,62 @@ func (b *SimulatedBackend) SendTransaction(tx *types.Transaction) error {
 	return nil
 }
 
+// FilterLogs implements ContractFilterer.FilterLogs, searching the committed
+// blocks for the logs matching the query.
+func (b *SimulatedBackend) FilterLogs(query bind.FilterQuery) ([]vm.Log, error) {
+	filter := filters.NewQueryFilter(b.database, query.FromBlock, query.ToBlock, query.Addresses, query.Topics)
+
+	var logs []vm.Log
+	for _, log := range filter.Find() {
+		logs = append(logs, *log)
+	}
+	return logs, nil
+}
+
+// SubscribeFilterLogs implements ContractFilterer.SubscribeFilterLogs, streaming
+// the logs matching the query from every block committed from now on. If the
+// query has a starting block, the matching logs already committed since are
+// delivered first.
+func (b *SimulatedBackend) SubscribeFilterLogs(query bind.FilterQuery, ch chan<- vm.Log) (event.Subscription, error) {
+	filter := filters.NewQueryFilter(b.database, query.FromBlock, query.ToBlock, query.Addresses, query.Topics)
+
+	// Subscribe before collecting the past logs, not to miss any block
+	events := b.mux.Subscribe(core.ChainEvent{})
+
+	var past vm.Logs
+	if query.FromBlock != nil {
+		past = filter.Find()
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer events.Unsubscribe()
+
+		for _, log := range past {
+			select {
+			case ch <- *log:
+			case <-quit:
+				return nil
+			}
+		}
+		for {
+			select {
+			case ev, ok := <-events.Chan():
+				if !ok {
+					return nil
+				}
+				for _, log := range filter.FilterLogs(ev.Data.(core.ChainEvent).Logs) {
+					select {
+					case ch <- *log:
+					case <-quit:
+						return nil
+					}
+				}
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
 // callmsg implements core.Message to allow passing it as a transaction simulator.
 type callmsg struct {
 	from     *state.StateObject
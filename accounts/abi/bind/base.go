@@ -25,7 +25,9 @@ import (
 	"github.com/ethereumproject/go-ethereum/accounts/abi"
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
 	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/event"
 )
 
 // SignerFn is a signer function callback when a contract requires a method to
@@ -49,6 +51,19 @@ type TransactOpts struct {
 	GasLimit *big.Int // Gas limit to set for the transaction execution (nil = estimate + 10%)
 }
 
+// FilterOpts is the collection of options to fine tune filtering for events
+// within a bound contract.
+type FilterOpts struct {
+	Start uint64  // Start of the queried range
+	End   *uint64 // End of the range (nil = latest)
+}
+
+// WatchOpts is the collection of options to fine tune subscribing for events
+// within a bound contract.
+type WatchOpts struct {
+	Start *uint64 // Start of the queried range (nil = latest)
+}
+
 // BoundContract is the base wrapper object that reflects a contract on the
 // Ethereum network. It contains a collection of methods that are used by the
 // higher level contract bindings to operate.
@@ -57,19 +72,21 @@ type BoundContract struct {
 	abi        abi.ABI            // Reflect based ABI to access the correct Ethereum methods
 	caller     ContractCaller     // Read interface to interact with the blockchain
 	transactor ContractTransactor // Write interface to interact with the blockchain
+	filterer   ContractFilterer   // Event filtering to interact with the blockchain
 
 	latestHasCode  uint32 // Cached verification that the latest state contains code for this contract
 	pendingHasCode uint32 // Cached verification that the pending state contains code for this contract
 }
 
-// NewBoundContract creates a low level contract interface through which calls
-// and transactions may be made through.
-func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller, transactor ContractTransactor) *BoundContract {
+// NewBoundContract creates a low level contract interface through which calls,
+// transactions and event filtering may be made through.
+func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller, transactor ContractTransactor, filterer ContractFilterer) *BoundContract {
 	return &BoundContract{
 		address:    address,
 		abi:        abi,
 		caller:     caller,
 		transactor: transactor,
+		filterer:   filterer,
 	}
 }
 
@@ -77,7 +94,7 @@ func NewBoundContract(address common.Address, abi abi.ABI, caller ContractCaller
 // deployment address with a Go wrapper.
 func DeployContract(opts *TransactOpts, abi abi.ABI, bytecode []byte, backend ContractBackend, params ...interface{}) (common.Address, *types.Transaction, *BoundContract, error) {
 	// Otherwise try to deploy the contract
-	c := NewBoundContract(common.Address{}, abi, backend, backend)
+	c := NewBoundContract(common.Address{}, abi, backend, backend, backend)
 
 	input, err := c.abi.Pack("", params...)
 	if err != nil {
@@ -204,3 +221,123 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 	}
 	return signedTx, nil
 }
+
+// FilterLogs filters contract logs for past blocks, returning the necessary
+// channels to construct a strongly typed bound iterator on top of them.
+func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]interface{}) (chan vm.Log, event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(FilterOpts)
+	}
+	topics, err := c.eventTopics(name, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Start the background filtering
+	logs := make(chan vm.Log, 128)
+
+	config := FilterQuery{
+		Addresses: []common.Address{c.address},
+		Topics:    topics,
+		FromBlock: new(big.Int).SetUint64(opts.Start),
+	}
+	if opts.End != nil {
+		config.ToBlock = new(big.Int).SetUint64(*opts.End)
+	}
+	buff, err := c.filterer.FilterLogs(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	sub := event.NewSubscription(func(quit <-chan struct{}) error {
+		for _, log := range buff {
+			select {
+			case logs <- log:
+			case <-quit:
+				return nil
+			}
+		}
+		return nil
+	})
+	return logs, sub, nil
+}
+
+// WatchLogs filters subscribes to contract logs for future blocks, returning a
+// subscription object that can be used to tear down the watcher.
+func (c *BoundContract) WatchLogs(opts *WatchOpts, name string, query ...[]interface{}) (chan vm.Log, event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(WatchOpts)
+	}
+	topics, err := c.eventTopics(name, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Start the background filtering
+	logs := make(chan vm.Log, 128)
+
+	config := FilterQuery{
+		Addresses: []common.Address{c.address},
+		Topics:    topics,
+	}
+	if opts.Start != nil {
+		config.FromBlock = new(big.Int).SetUint64(*opts.Start)
+	}
+	sub, err := c.filterer.SubscribeFilterLogs(config, logs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return logs, sub, nil
+}
+
+// UnpackLog unpacks a retrieved log into the provided output structure. Unnamed
+// event arguments are unpacked into fields named by their position (Arg0, ...),
+// the same way the generated bindings name them.
+func (c *BoundContract) UnpackLog(out interface{}, event string, log vm.Log) error {
+	ev, ok := c.abi.Events[event]
+	if !ok {
+		return fmt.Errorf("abi: could not locate named event %s", event)
+	}
+	inputs := make([]abi.Argument, len(ev.Inputs))
+	copy(inputs, ev.Inputs)
+	for i, input := range inputs {
+		if input.Name == "" {
+			inputs[i].Name = fmt.Sprintf("arg%d", i)
+		}
+	}
+	ev.Inputs = inputs
+
+	if len(log.Data) > 0 {
+		parsed := abi.ABI{Events: map[string]abi.Event{event: ev}}
+		if err := parsed.Unpack(out, event, log.Data); err != nil {
+			return err
+		}
+	}
+	var indexed []abi.Argument
+	for _, arg := range inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	topics := log.Topics
+	if !ev.Anonymous {
+		if len(topics) == 0 {
+			return errors.New("log has no event selector topic")
+		}
+		topics = topics[1:]
+	}
+	return parseTopics(out, indexed, topics)
+}
+
+// eventTopics constructs the topic set filtering for the named event, with the
+// query rules matching its indexed arguments in order. Unless the event is
+// anonymous, its selector is prepended as the first topic.
+func (c *BoundContract) eventTopics(name string, query [][]interface{}) ([][]common.Hash, error) {
+	event, ok := c.abi.Events[name]
+	if !ok {
+		return nil, fmt.Errorf("abi: could not locate named event %s", name)
+	}
+	if !event.Anonymous {
+		query = append([][]interface{}{{event.Id()}}, query...)
+	}
+	return makeTopics(query...)
+}
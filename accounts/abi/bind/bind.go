@@ -23,7 +23,7 @@ package bind
 import (
 	"bytes"
 	"fmt"
-	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"unicode"
@@ -85,6 +85,51 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string) (string
 				transacts[original.Name] = &tmplMethod{Original: original, Normalized: normalized, Structured: structured(original)}
 			}
 		}
+		// Extract the events, normalizing them the same way as the methods
+		events := make(map[string]*tmplEvent)
+		for _, original := range evmABI.Events {
+			normalized := original
+			normalized.Name = capitalise(original.Name)
+
+			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
+			copy(normalized.Inputs, original.Inputs)
+			for j, input := range normalized.Inputs {
+				if input.Name == "" {
+					normalized.Inputs[j].Name = fmt.Sprintf("arg%d", j)
+				}
+			}
+			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
+		}
+		// Generate the Go structs of all tuples used by the contract. The
+		// methods are visited in order to keep the struct names stable.
+		names := make([]string, 0, len(evmABI.Methods))
+		for name := range evmABI.Methods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		args := evmABI.Constructor.Inputs
+		for _, name := range names {
+			args = append(args, evmABI.Methods[name].Inputs...)
+			args = append(args, evmABI.Methods[name].Outputs...)
+		}
+		names = names[:0]
+		for name := range evmABI.Events {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, input := range evmABI.Events[name].Inputs {
+				if !input.Indexed {
+					args = append(args, input)
+				}
+			}
+		}
+		structs := make(map[string]*tmplStruct)
+		for _, arg := range args {
+			bindStruct(arg.Type, capitalise(types[i]), structs)
+		}
 		contracts[types[i]] = &tmplContract{
 			Type:        capitalise(types[i]),
 			InputABI:    strippedABI,
@@ -92,6 +137,8 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string) (string
 			Constructor: evmABI.Constructor,
 			Calls:       calls,
 			Transacts:   transacts,
+			Events:      events,
+			Structs:     structs,
 		}
 	}
 	// Generate the contract template data content and render it
@@ -102,7 +149,9 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string) (string
 	buffer := new(bytes.Buffer)
 
 	funcs := map[string]interface{}{
-		"bindtype": bindType,
+		"bindtype":      bindType,
+		"bindtopictype": bindTopicType,
+		"capitalise":    capitalise,
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -118,45 +167,88 @@ func Bind(types []string, abis []string, bytecodes []string, pkg string) (string
 
 // bindType converts a Solidity type to a Go one. Since there is no clear mapping
 // from all Solidity types to Go ones (e.g. uint17), those that cannot be exactly
-// mapped will use an upscaled type (e.g. *big.Int).
-func bindType(kind abi.Type) string {
-	stringKind := kind.String()
-
-	switch {
-	case strings.HasPrefix(stringKind, "address"):
-		parts := regexp.MustCompile("address(\\[[0-9]*\\])?").FindStringSubmatch(stringKind)
-		if len(parts) != 2 {
-			return stringKind
-		}
-		return fmt.Sprintf("%scommon.Address", parts[1])
+// mapped will use an upscaled type (e.g. *big.Int). Tuples are mapped to the
+// struct generated for their signature by bindStruct.
+func bindType(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.SliceTy:
+		return "[]" + bindType(*kind.Elem, structs)
 
-	case strings.HasPrefix(stringKind, "bytes"):
-		parts := regexp.MustCompile("bytes([0-9]*)(\\[[0-9]*\\])?").FindStringSubmatch(stringKind)
-		if len(parts) != 3 {
-			return stringKind
-		}
-		return fmt.Sprintf("%s[%s]byte", parts[2], parts[1])
+	case abi.ArrayTy:
+		return fmt.Sprintf("[%d]", kind.SliceSize) + bindType(*kind.Elem, structs)
 
-	case strings.HasPrefix(stringKind, "int") || strings.HasPrefix(stringKind, "uint"):
-		parts := regexp.MustCompile("(u)?int([0-9]*)(\\[[0-9]*\\])?").FindStringSubmatch(stringKind)
-		if len(parts) != 4 {
-			return stringKind
-		}
-		switch parts[2] {
-		case "8", "16", "32", "64":
-			return fmt.Sprintf("%s%sint%s", parts[3], parts[1], parts[2])
+	case abi.TupleTy:
+		if s, exist := structs[kind.String()]; exist {
+			return s.Name
 		}
-		return fmt.Sprintf("%s*big.Int", parts[3])
+		return kind.String()
+
+	case abi.AddressTy:
+		return "common.Address"
+
+	case abi.BytesTy:
+		return "[]byte"
 
-	case strings.HasPrefix(stringKind, "bool") || strings.HasPrefix(stringKind, "string"):
-		parts := regexp.MustCompile("([a-z]+)(\\[[0-9]*\\])?").FindStringSubmatch(stringKind)
-		if len(parts) != 3 {
-			return stringKind
+	case abi.FixedBytesTy:
+		return fmt.Sprintf("[%d]byte", kind.SliceSize)
+
+	case abi.IntTy, abi.UintTy:
+		switch kind.Size {
+		case 8, 16, 32, 64:
+			if kind.T == abi.UintTy {
+				return fmt.Sprintf("uint%d", kind.Size)
+			}
+			return fmt.Sprintf("int%d", kind.Size)
 		}
-		return fmt.Sprintf("%s%s", parts[2], parts[1])
+		return "*big.Int"
+
+	case abi.BoolTy:
+		return "bool"
+
+	case abi.StringTy:
+		return "string"
 
 	default:
-		return stringKind
+		return kind.String()
+	}
+}
+
+// bindTopicType converts a Solidity type to a Go one used for an indexed event
+// argument. Dynamic types are only stored as the Keccak256 hash of their value
+// in the log topics, so they can't be reconstructed and are mapped to a hash.
+func bindTopicType(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		return "common.Hash"
+	}
+	return bindType(kind, structs)
+}
+
+// bindStruct generates a Go struct for every tuple nested in the given type
+// which doesn't have one yet. Tuples of the same signature share a struct,
+// named after the contract and the order the tuples were found in.
+func bindStruct(kind abi.Type, prefix string, structs map[string]*tmplStruct) {
+	switch kind.T {
+	case abi.SliceTy, abi.ArrayTy:
+		bindStruct(*kind.Elem, prefix, structs)
+
+	case abi.TupleTy:
+		if _, exist := structs[kind.String()]; exist {
+			return
+		}
+		var fields []*tmplField
+		for i, elem := range kind.TupleElems {
+			bindStruct(*elem, prefix, structs)
+			fields = append(fields, &tmplField{
+				Name:    abi.ToCamelCase(kind.TupleRawNames[i]),
+				Type:    bindType(*elem, structs),
+				SolKind: *elem,
+			})
+		}
+		structs[kind.String()] = &tmplStruct{
+			Name:   fmt.Sprintf("%sStruct%d", prefix, len(structs)),
+			Fields: fields,
+		}
 	}
 }
 
@@ -288,6 +288,175 @@ var bindTests = []struct {
 			}
 		`,
 	},
+	// Tests that tuples and nested arrays are bound to Go structs and slices
+	{
+		`StructChecker`, ``, ``,
+		`
+			[
+				{"type":"function","name":"point","constant":true,"inputs":[],"outputs":[{"name":"p","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},
+				{"type":"function","name":"points","constant":true,"inputs":[{"name":"n","type":"uint8[2][]"}],"outputs":[{"name":"","type":"tuple[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},
+				{"type":"function","name":"shape","constant":true,"inputs":[],"outputs":[{"name":"id","type":"uint64"},{"name":"shape","type":"tuple","components":[{"name":"origin","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},{"name":"corner_points","type":"tuple[4]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},{"name":"label","type":"string"}]}]},
+				{"type":"function","name":"setShapes","constant":false,"inputs":[{"name":"shapes","type":"tuple[][]","components":[{"name":"origin","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},{"name":"corner_points","type":"tuple[4]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]},{"name":"label","type":"string"}]}],"outputs":[]}
+			]
+		`,
+		`if b, err := NewStructChecker(common.Address{}, backends.NewNilBackend()); b == nil || err != nil {
+			 t.Fatalf("binding (%v) nil or error (%v) not nil", b, nil)
+		 } else if false { // Don't run, just compile and test types
+			 var (
+				 point  StructCheckerStruct0
+				 points []StructCheckerStruct0
+				 shape  StructCheckerStruct1
+				 err    error
+			 )
+			 point, err = b.Point(nil)
+			 points, err = b.Points(nil, [][2]uint8{{1, 2}})
+			 res, _ := b.Shape(nil)
+			 shape = res.Shape
+			 _, err = b.SetShapes(nil, [][]StructCheckerStruct1{{shape}})
+
+			 var x, y *big.Int = point.X, shape.CornerPoints[3].Y
+			 var label string = shape.Label
+			 var origin StructCheckerStruct0 = shape.Origin
+			 var id uint64 = res.Id
+
+			 fmt.Println(points, x, y, label, origin, id, err)
+		 }`,
+	},
+	// Tests that tuple arrays can be packed and returned through the simulator.
+	// The contract is hand assembled and returns the encoding of [(1, 2), (3, 4)]
+	// for any call.
+	{
+		`Structer`,
+		`
+			contract Structer {
+				struct Point { uint x; uint y; }
+
+				function echoPoints(Point[] points) constant returns (Point[]) {
+					return [Point(1, 2), Point(3, 4)];
+				}
+			}
+		`,
+		`60cc600c60003960cc6000f360c0600c60003960c06000f3` +
+			`0000000000000000000000000000000000000000000000000000000000000020` +
+			`0000000000000000000000000000000000000000000000000000000000000002` +
+			`0000000000000000000000000000000000000000000000000000000000000001` +
+			`0000000000000000000000000000000000000000000000000000000000000002` +
+			`0000000000000000000000000000000000000000000000000000000000000003` +
+			`0000000000000000000000000000000000000000000000000000000000000004`,
+		`[{"constant":true,"inputs":[{"name":"points","type":"tuple[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}],"name":"echoPoints","outputs":[{"name":"","type":"tuple[]","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}],"type":"function"}]`,
+		`
+			// Generate a new random account and a funded simulator
+			key, _ := crypto.GenerateKey()
+			auth := bind.NewKeyedTransactor(key)
+			sim := backends.NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(10000000000)})
+
+			// Deploy a struct tester contract and execute a tuple array call on it
+			_, _, structer, err := DeployStructer(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy structer contract: %v", err)
+			}
+			sim.Commit()
+
+			points := []StructerStruct0{{big.NewInt(1), big.NewInt(2)}, {big.NewInt(3), big.NewInt(4)}}
+			if out, err := structer.EchoPoints(nil, points); err != nil {
+				t.Fatalf("Failed to call tuple echoer: %v", err)
+			} else if !reflect.DeepEqual(out, points) {
+				t.Fatalf("Tuple return mismatch: have %v, want %v", out, points)
+			}
+		`,
+	},
+	// Tests that events can be filtered and watched through the generated bindings.
+	// The contract is hand assembled and for any call with a uint64 id argument
+	// emits Raised(msg.sender, id, 2 * id).
+	{
+		`Eventer`,
+		`
+			contract Eventer {
+				event Raised(address indexed sender, uint64 indexed id, uint value);
+
+				function raise(uint64 id) {
+					Raised(msg.sender, id, 2 * id);
+				}
+			}
+		`,
+		`6032600c60003960326000f3` +
+			`60043580600202600052337f4c470dcd0a0f3ea3891b07c46df64c1ce32562763f9002d19ed8d0c837ce890b60206000a300`,
+		`[{"constant":false,"inputs":[{"name":"id","type":"uint64"}],"name":"raise","outputs":[],"type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"name":"sender","type":"address"},{"indexed":true,"name":"id","type":"uint64"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Raised","type":"event"}]`,
+		`
+			// Generate a new random account and a funded simulator
+			key, _ := crypto.GenerateKey()
+			auth := bind.NewKeyedTransactor(key)
+			sim := backends.NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(10000000000)})
+
+			// Deploy an event raiser contract and start watching for a specific id
+			_, _, eventer, err := DeployEventer(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy eventer contract: %v", err)
+			}
+			sim.Commit()
+
+			sink := make(chan *EventerRaised, 4)
+			sub, err := eventer.WatchRaised(nil, sink, nil, []uint64{2})
+			if err != nil {
+				t.Fatalf("Failed to watch events: %v", err)
+			}
+			defer sub.Unsubscribe()
+
+			// Raise a few events and check that they are all retrievable
+			for id := uint64(1); id <= 3; id++ {
+				if _, err := eventer.Raise(auth, id); err != nil {
+					t.Fatalf("Failed to raise event %d: %v", id, err)
+				}
+			}
+			sim.Commit()
+
+			it, err := eventer.FilterRaised(nil, []common.Address{auth.From}, nil)
+			if err != nil {
+				t.Fatalf("Failed to filter events: %v", err)
+			}
+			id := uint64(1)
+			for ; it.Next(); id++ {
+				if it.Event.Sender != auth.From || it.Event.Id != id || it.Event.Value.Cmp(new(big.Int).SetUint64(2*id)) != 0 {
+					t.Errorf("Event %d mismatch: have %+v", id, it.Event)
+				}
+				if it.Event.Raw.BlockNumber != 2 {
+					t.Errorf("Event %d block mismatch: have %d, want 2", id, it.Event.Raw.BlockNumber)
+				}
+			}
+			if err := it.Error(); err != nil {
+				t.Fatalf("Event iteration failed: %v", err)
+			}
+			it.Close()
+			if id != 4 {
+				t.Fatalf("Filtered event count mismatch: have %d, want 3", id-1)
+			}
+			// Filtering on an id nobody raised shouldn't find anything
+			if it, err = eventer.FilterRaised(&bind.FilterOpts{Start: 2}, nil, []uint64{4}); err != nil {
+				t.Fatalf("Failed to filter events: %v", err)
+			}
+			if it.Next() {
+				t.Fatalf("Unexpected event found: %+v", it.Event)
+			}
+			it.Close()
+
+			// Only the watched event should have been delivered
+			select {
+			case ev := <-sink:
+				if ev.Sender != auth.From || ev.Id != 2 || ev.Value.Cmp(big.NewInt(4)) != 0 {
+					t.Fatalf("Watched event mismatch: have %+v", ev)
+				}
+			case err := <-sub.Err():
+				t.Fatalf("Watch failed: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Watched event not delivered")
+			}
+			select {
+			case ev := <-sink:
+				t.Fatalf("Unexpected watched event: %+v", ev)
+			case <-time.After(100 * time.Millisecond):
+			}
+		`,
+	},
 	// Tests that anonymous default methods can be correctly invoked
 	{
 		`Defaulter`,
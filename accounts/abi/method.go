@@ -45,9 +45,17 @@ func (m Method) pack(method Method, args ...interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("argument count mismatch: %d for %d", len(args), len(method.Inputs))
 	}
 	// variable input is the output appended at the end of packed
-	// output. This is used for strings and bytes types input.
+	// output. This is used for dynamic types input (string, bytes,
+	// slices and the arrays and tuples containing them).
 	var variableInput []byte
 
+	// static arrays and tuples are packed in place, so the head may
+	// span more than a word per input.
+	var inputOffset int
+	for _, input := range method.Inputs {
+		inputOffset += getTypeSize(input.Type)
+	}
+
 	var ret []byte
 	for i, a := range args {
 		input := method.Inputs[i]
@@ -57,10 +65,10 @@ func (m Method) pack(method Method, args ...interface{}) ([]byte, error) {
 			return nil, fmt.Errorf("`%s` %v", method.Name, err)
 		}
 
-		// check for a slice type (string, bytes, slice)
-		if input.Type.requiresLengthPrefix() {
+		// check for a dynamic type
+		if isDynamicType(input.Type) {
 			// calculate the offset
-			offset := len(method.Inputs)*32 + len(variableInput)
+			offset := inputOffset + len(variableInput)
 			// set the offset
 			ret = append(ret, packNum(reflect.ValueOf(offset))...)
 			// Append the packed output to the variable input. The variable input
@@ -25,13 +25,14 @@ import (
 
 var (
 	big_t     = reflect.TypeOf(big.Int{})
-	ubig_t    = reflect.TypeOf(big.Int{})
 	int_t     = reflect.TypeOf(int(0))
 	int8_t    = reflect.TypeOf(int8(0))
 	int16_t   = reflect.TypeOf(int16(0))
 	int32_t   = reflect.TypeOf(int32(0))
 	int64_t   = reflect.TypeOf(int64(0))
 	address_t = reflect.TypeOf(common.Address{})
+	bool_t    = reflect.TypeOf(false)
+	string_t  = reflect.TypeOf("")
 
 	int_ts   = reflect.TypeOf([]int(nil))
 	int8_ts  = reflect.TypeOf([]int8(nil))
@@ -58,6 +58,30 @@ func reflectIntKind(unsigned bool, size int) reflect.Kind {
 	return reflect.Ptr
 }
 
+// reflectIntType returns the Go type integers of the given size and
+// unsignedness are decoded in to.
+func reflectIntType(unsigned bool, size int) reflect.Type {
+	switch reflectIntKind(unsigned, size) {
+	case reflect.Uint8:
+		return reflect.TypeOf(uint8(0))
+	case reflect.Uint16:
+		return reflect.TypeOf(uint16(0))
+	case reflect.Uint32:
+		return reflect.TypeOf(uint32(0))
+	case reflect.Uint64:
+		return reflect.TypeOf(uint64(0))
+	case reflect.Int8:
+		return int8_t
+	case reflect.Int16:
+		return int16_t
+	case reflect.Int32:
+		return int32_t
+	case reflect.Int64:
+		return int64_t
+	}
+	return reflect.PtrTo(big_t)
+}
+
 // mustArrayToBytesSlice creates a new byte slice with the exact same size as value
 // and copies the bytes in value to the new slice.
 func mustArrayToByteSlice(value reflect.Value) reflect.Value {
@@ -67,31 +91,76 @@ func mustArrayToByteSlice(value reflect.Value) reflect.Value {
 }
 
 // set attempts to assign src to dst by either setting, copying or otherwise.
+// The abi type t of src is used to descend in to arrays and tuples.
 //
 // set is a bit more lenient when it comes to assignment and doesn't force an as
 // strict ruleset as bare `reflect` does.
-func set(dst, src reflect.Value, output Argument) error {
+func set(dst, src reflect.Value, t Type) error {
 	dstType := dst.Type()
 	srcType := src.Type()
 
 	switch {
-	case dstType.AssignableTo(src.Type()):
+	case srcType.AssignableTo(dstType):
 		dst.Set(src)
-	case dstType.Kind() == reflect.Array && srcType.Kind() == reflect.Slice:
+	case dstType.Kind() == reflect.Array && srcType.Kind() == reflect.Slice && srcType.Elem() == r_byte:
 		if !dstType.Elem().AssignableTo(r_byte) {
 			return fmt.Errorf("abi: cannot unmarshal %v in to array of elem %v", src.Type(), dstType.Elem())
 		}
 
-		if dst.Len() < output.Type.SliceSize {
-			return fmt.Errorf("abi: cannot unmarshal src (len=%d) in to dst (len=%d)", output.Type.SliceSize, dst.Len())
+		if dst.Len() < t.SliceSize {
+			return fmt.Errorf("abi: cannot unmarshal src (len=%d) in to dst (len=%d)", t.SliceSize, dst.Len())
 		}
 		reflect.Copy(dst, src)
 	case dstType.Kind() == reflect.Interface:
 		dst.Set(src)
 	case dstType.Kind() == reflect.Ptr:
-		return set(dst.Elem(), src, output)
+		if dst.IsNil() {
+			if !dst.CanSet() {
+				return fmt.Errorf("abi: cannot unmarshal %v in to nil %v", src.Type(), dst.Type())
+			}
+			dst.Set(reflect.New(dstType.Elem()))
+		}
+		return set(dst.Elem(), src, t)
+	case (dstType.Kind() == reflect.Slice || dstType.Kind() == reflect.Array) && t.Elem != nil &&
+		(srcType.Kind() == reflect.Slice || srcType.Kind() == reflect.Array):
+		return setSlice(dst, src, t)
+	case dstType.Kind() == reflect.Struct && srcType.Kind() == reflect.Struct && t.T == TupleTy:
+		return setStruct(dst, src, t)
 	default:
 		return fmt.Errorf("abi: cannot unmarshal %v in to %v", src.Type(), dst.Type())
 	}
 	return nil
 }
+
+// setSlice assigns the elements of the decoded array or slice src to the
+// elements of dst one by one.
+func setSlice(dst, src reflect.Value, t Type) error {
+	if dst.Kind() == reflect.Slice {
+		dst.Set(reflect.MakeSlice(dst.Type(), src.Len(), src.Len()))
+	} else if dst.Len() != src.Len() {
+		return fmt.Errorf("abi: cannot unmarshal src (len=%d) in to dst (len=%d)", src.Len(), dst.Len())
+	}
+	for i := 0; i < src.Len(); i++ {
+		if err := set(dst.Index(i), src.Index(i), *t.Elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setStruct assigns the components of the decoded tuple src to the fields of
+// the same name in dst.
+func setStruct(dst, src reflect.Value, t Type) error {
+	for i, elem := range t.TupleElems {
+		name := src.Type().Field(i).Name
+
+		field := dst.FieldByName(name)
+		if !field.IsValid() || !field.CanSet() {
+			return fmt.Errorf("abi: cannot unmarshal tuple field %s in to %v", name, dst.Type())
+		}
+		if err := set(field, src.Field(i), *elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
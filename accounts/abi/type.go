@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -34,8 +35,14 @@ const (
 	BytesTy
 	HashTy
 	RealTy
+	ArrayTy
+	TupleTy
 )
 
+// maxArraySize is the maximum size in bytes of the Go value of a fixed size
+// array type, which is allocated when decoding an argument of the type.
+const maxArraySize = 1 << 24
+
 // Type is the reflection of the supported argument type
 type Type struct {
 	IsSlice, IsArray bool
@@ -44,57 +51,78 @@ type Type struct {
 	Elem *Type
 
 	Kind reflect.Kind
-	Type reflect.Type
+	Type reflect.Type // Go type values of this abi type are decoded in to
 	Size int
 	T    byte // Our own type checking
 
+	TupleElems    []*Type  // Types of the tuple components
+	TupleRawNames []string // Names of the tuple components as given in the abi
+
 	stringKind string // holds the unparsed string for deriving signatures
 }
 
 var (
-	// fullTypeRegex parses the abi types
+	// typeRegex parses the abi sub types
 	//
 	// Types can be in the format of:
 	//
-	// 	Input  = Type [ "[" [ Number ] "]" ] Name .
-	// 	Type   = [ "u" ] "int" [ Number ] .
+	// 	Input  = Type { "[" [ Number ] "]" } Name .
+	// 	Type   = [ "u" ] "int" [ Number ] | "tuple" .
 	//
 	// Examples:
 	//
-	//      string     int       uint       real
+	//      string     int       uint       tuple
 	//      string32   int8      uint8      uint[]
-	//      address    int256    uint256    real[2]
-	fullTypeRegex = regexp.MustCompile("([a-zA-Z0-9]+)(\\[([0-9]*)?\\])?")
-	// typeRegex parses the abi sub types
+	//      address    int256    uint256    uint[2][]
 	typeRegex = regexp.MustCompile("([a-zA-Z]+)([0-9]*)?")
 )
 
-// NewType creates a new reflection type of abi type given in t.
-func NewType(t string) (typ Type, err error) {
-	res := fullTypeRegex.FindAllStringSubmatch(t, -1)[0]
-	// check if type is slice and parse type.
-	switch {
-	case res[3] != "":
-		// err is ignored. Already checked for number through the regexp
-		typ.SliceSize, _ = strconv.Atoi(res[3])
-		typ.IsArray = true
-	case res[2] != "":
-		typ.IsSlice, typ.SliceSize = true, -1
-	case res[0] == "":
+// NewType creates a new reflection type of abi type given in t. Tuple types
+// require the components of the tuple, as found in the json abi definition.
+func NewType(t string, components ...Argument) (typ Type, err error) {
+	if strings.Count(t, "[") != strings.Count(t, "]") {
 		return Type{}, fmt.Errorf("abi: type parse error: %s", t)
 	}
-	if typ.IsArray || typ.IsSlice {
-		sliceType, err := NewType(res[1])
+	// check if type is an array or slice and parse its element type. Arrays
+	// may be nested, the outermost dimension is the last one.
+	if i := strings.LastIndex(t, "["); i >= 0 {
+		if !strings.HasSuffix(t, "]") {
+			return Type{}, fmt.Errorf("abi: type parse error: %s", t)
+		}
+		elem, err := NewType(t[:i], components...)
 		if err != nil {
 			return Type{}, err
 		}
-		typ.Elem = &sliceType
-		typ.stringKind = sliceType.stringKind + t[len(res[1]):]
+		typ.Elem = &elem
+		typ.stringKind = elem.stringKind + t[i:]
+
+		if size := t[i+1 : len(t)-1]; size == "" {
+			typ.IsSlice, typ.SliceSize = true, -1
+			typ.T = SliceTy
+			typ.Type = reflect.SliceOf(elem.Type)
+		} else {
+			if typ.SliceSize, err = strconv.Atoi(size); err != nil || typ.SliceSize < 0 {
+				return Type{}, fmt.Errorf("abi: error parsing array size: %s", t)
+			}
+			elemSize := int(elem.Type.Size())
+			if elemSize == 0 {
+				elemSize = 1
+			}
+			if typ.SliceSize > maxArraySize/elemSize {
+				return Type{}, fmt.Errorf("abi: array too large: %s", t)
+			}
+			typ.IsArray = true
+			typ.T = ArrayTy
+			typ.Type = reflect.ArrayOf(typ.SliceSize, elem.Type)
+		}
 		return typ, nil
 	}
 
 	// parse the type and size of the abi-type.
-	parsedType := typeRegex.FindAllStringSubmatch(res[1], -1)[0]
+	parsedType := typeRegex.FindStringSubmatch(t)
+	if parsedType == nil {
+		return Type{}, fmt.Errorf("abi: type parse error: %s", t)
+	}
 	// varSize is the size of the variable
 	var varSize int
 	if len(parsedType[2]) > 0 {
@@ -116,16 +144,17 @@ func NewType(t string) (typ Type, err error) {
 	switch varType {
 	case "int":
 		typ.Kind = reflectIntKind(false, varSize)
-		typ.Type = big_t
+		typ.Type = reflectIntType(false, varSize)
 		typ.Size = varSize
 		typ.T = IntTy
 	case "uint":
 		typ.Kind = reflectIntKind(true, varSize)
-		typ.Type = ubig_t
+		typ.Type = reflectIntType(true, varSize)
 		typ.Size = varSize
 		typ.T = UintTy
 	case "bool":
 		typ.Kind = reflect.Bool
+		typ.Type = bool_t
 		typ.T = BoolTy
 	case "address":
 		typ.Kind = reflect.Array
@@ -134,6 +163,7 @@ func NewType(t string) (typ Type, err error) {
 		typ.T = AddressTy
 	case "string":
 		typ.Kind = reflect.String
+		typ.Type = string_t
 		typ.Size = -1
 		typ.T = StringTy
 	case "bytes":
@@ -143,11 +173,39 @@ func NewType(t string) (typ Type, err error) {
 			typ.IsSlice = true
 			typ.T = BytesTy
 			typ.SliceSize = -1
+			typ.Type = reflect.SliceOf(r_byte)
 		} else {
 			typ.IsArray = true
 			typ.T = FixedBytesTy
 			typ.SliceSize = varSize
+			typ.Type = reflect.ArrayOf(varSize, r_byte)
+		}
+	case "tuple":
+		if len(components) == 0 {
+			return Type{}, fmt.Errorf("abi: tuple type without components")
 		}
+		var (
+			fields []reflect.StructField
+			kinds  []string
+			seen   = make(map[string]bool)
+		)
+		for _, c := range components {
+			name := ToCamelCase(c.Name)
+			if name == "" || seen[name] {
+				return Type{}, fmt.Errorf("abi: tuple component names must be unique and not empty: %q", c.Name)
+			}
+			seen[name] = true
+
+			elem := c.Type
+			fields = append(fields, reflect.StructField{Name: name, Type: elem.Type})
+			kinds = append(kinds, elem.stringKind)
+			typ.TupleElems = append(typ.TupleElems, &elem)
+			typ.TupleRawNames = append(typ.TupleRawNames, c.Name)
+		}
+		typ.Kind = reflect.Struct
+		typ.Type = reflect.StructOf(fields)
+		typ.T = TupleTy
+		typ.stringKind = "(" + strings.Join(kinds, ",") + ")"
 	default:
 		return Type{}, fmt.Errorf("unsupported arg type: %s", t)
 	}
@@ -168,16 +226,60 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 		return nil, err
 	}
 
-	if (t.IsSlice || t.IsArray) && t.T != BytesTy && t.T != FixedBytesTy {
-		var packed []byte
+	switch t.T {
+	case SliceTy, ArrayTy:
+		var ret []byte
+		if t.requiresLengthPrefix() {
+			ret = packNum(reflect.ValueOf(v.Len()))
+		}
+		// dynamic elements are referenced by their offset from the start of
+		// the array data and appended after the static part.
+		var (
+			dynamic = isDynamicType(*t.Elem)
+			offset  = v.Len() * getTypeSize(*t.Elem)
+			tail    []byte
+		)
 		for i := 0; i < v.Len(); i++ {
 			val, err := t.Elem.pack(v.Index(i))
 			if err != nil {
 				return nil, err
 			}
-			packed = append(packed, val...)
+			if !dynamic {
+				ret = append(ret, val...)
+				continue
+			}
+			ret = append(ret, packNum(reflect.ValueOf(offset))...)
+			offset += len(val)
+			tail = append(tail, val...)
 		}
-		return packBytesSlice(packed, v.Len()), nil
+		return append(ret, tail...), nil
+
+	case TupleTy:
+		var (
+			ret, tail []byte
+			offset    int
+		)
+		for _, elem := range t.TupleElems {
+			offset += getTypeSize(*elem)
+		}
+		for i, elem := range t.TupleElems {
+			field := v.FieldByName(ToCamelCase(t.TupleRawNames[i]))
+			if !field.IsValid() {
+				return nil, fmt.Errorf("abi: field %s can't be found in the given value", t.TupleRawNames[i])
+			}
+			val, err := elem.pack(field)
+			if err != nil {
+				return nil, err
+			}
+			if !isDynamicType(*elem) {
+				ret = append(ret, val...)
+				continue
+			}
+			ret = append(ret, packNum(reflect.ValueOf(offset))...)
+			offset += len(val)
+			tail = append(tail, val...)
+		}
+		return append(ret, tail...), nil
 	}
 
 	return packElement(t, v), nil
@@ -186,5 +288,55 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 // requireLengthPrefix returns whether the type requires any sort of length
 // prefixing.
 func (t Type) requiresLengthPrefix() bool {
-	return t.T != FixedBytesTy && (t.T == StringTy || t.T == BytesTy || t.IsSlice || t.IsArray)
+	return t.T == StringTy || t.T == BytesTy || t.T == SliceTy
+}
+
+// isDynamicType returns whether the encoding of the type has a variable size.
+// Dynamic values are stored in the tail of the encoding and referenced by an
+// offset in its head.
+func isDynamicType(t Type) bool {
+	switch t.T {
+	case TupleTy:
+		for _, elem := range t.TupleElems {
+			if isDynamicType(*elem) {
+				return true
+			}
+		}
+		return false
+	case ArrayTy:
+		return isDynamicType(*t.Elem)
+	}
+	return t.requiresLengthPrefix()
+}
+
+// getTypeSize returns the number of bytes a value of the type occupies in the
+// head of an encoding. Dynamic types only store a 32 byte offset there, static
+// arrays and tuples are stored in place.
+func getTypeSize(t Type) int {
+	if isDynamicType(t) {
+		return 32
+	}
+	switch t.T {
+	case ArrayTy:
+		return t.SliceSize * getTypeSize(*t.Elem)
+	case TupleTy:
+		size := 0
+		for _, elem := range t.TupleElems {
+			size += getTypeSize(*elem)
+		}
+		return size
+	}
+	return 32
+}
+
+// ToCamelCase converts an under-score separated abi name in to the exported Go
+// identifier used for it in tuple structs, e.g. "_from" becomes "From".
+func ToCamelCase(input string) string {
+	parts := strings.Split(input, "_")
+	for i, s := range parts {
+		if len(s) > 0 {
+			parts[i] = strings.ToUpper(s[:1]) + s[1:]
+		}
+	}
+	return strings.Join(parts, "")
 }
@@ -132,7 +132,12 @@ func run(ctx *cli.Context) error {
 	if valueFlag == nil {
 		log.Fatalf("malformed %s flag value %q", ValueFlag.Name, ctx.GlobalString(ValueFlag.Name))
 	}
-	vmenv := NewEnv(statedb, common.StringToAddress("evmuser"), valueFlag)
+	logger := vm.NewStructLogger(nil)
+	var cfg vm.Config
+	if ctx.GlobalBool(DebugFlag.Name) {
+		cfg.Tracer = logger
+	}
+	vmenv := NewEnv(statedb, common.StringToAddress("evmuser"), valueFlag, cfg)
 
 	tstart := time.Now()
 
@@ -162,6 +167,10 @@ func run(ctx *cli.Context) error {
 	}
 	vmdone := time.Since(tstart)
 
+	if ctx.GlobalBool(DebugFlag.Name) {
+		vm.WriteTrace(os.Stderr, logger.StructLogs())
+	}
+
 	if ctx.GlobalBool(DumpFlag.Name) {
 		statedb.CommitTo(db, false)
 		fmt.Println(string(statedb.Dump([]common.Address{})))
@@ -209,7 +218,7 @@ type VMEnv struct {
 	evm *vm.EVM
 }
 
-func NewEnv(state *state.StateDB, transactor common.Address, value *big.Int) *VMEnv {
+func NewEnv(state *state.StateDB, transactor common.Address, value *big.Int, cfg vm.Config) *VMEnv {
 	env := &VMEnv{
 		state:      state,
 		transactor: &transactor,
@@ -217,7 +226,7 @@ func NewEnv(state *state.StateDB, transactor common.Address, value *big.Int) *VM
 		time:       big.NewInt(time.Now().Unix()),
 	}
 
-	env.evm = vm.New(env)
+	env.evm = vm.New(env, cfg)
 	return env
 }
 
@@ -225,6 +234,10 @@ func NewEnv(state *state.StateDB, transactor common.Address, value *big.Int) *VM
 type ruleSet struct{}
 
 func (ruleSet) IsHomestead(*big.Int) bool { return true }
+func (ruleSet) IsAtlantis(*big.Int) bool  { return true }
+func (ruleSet) Precompiled(*big.Int) map[string]*vm.PrecompiledAccount {
+	return vm.AllPrecompiledContracts()
+}
 
 func (ruleSet) GasTable(*big.Int) *vm.GasTable {
 	return &vm.GasTable{
@@ -284,6 +297,10 @@ func (self *VMEnv) DelegateCall(caller vm.ContractRef, addr common.Address, data
 	return core.DelegateCall(self, caller, addr, data, gas, price)
 }
 
+func (self *VMEnv) StaticCall(caller vm.ContractRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error) {
+	return core.StaticCall(self, caller, addr, data, gas, price)
+}
+
 func (self *VMEnv) Create(caller vm.ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error) {
 	return core.Create(self, caller, data, gas, price, value)
 }
@@ -20,6 +20,9 @@ var buildAddrTxIndexCommand = cli.Command{
 	run the command on multiple occasions and pick up indexing progress where the last session
 	left off.
 	To enable address-transaction indexing during block sync and import, use the '--atxi' flag.
+	With '--atxi.tokens' the senders and recipients of ERC20 token transfers are indexed too.
+	With '--atxi.internal' so are the addresses reached by internal value transfers, but these
+	are only known for blocks imported with that flag.
 			`,
 	Flags: []cli.Flag{
 		cli.IntFlag{
@@ -58,6 +61,9 @@ func buildAddrTxIndexCmd(ctx *cli.Context) error {
 		glog.Fatalln("can't open index database")
 	}
 	defer indexDB.Close()
+	if err := core.MigrateAddrTxIndex(indexDB); err != nil {
+		glog.Fatalf("can't migrate index database: %v", err)
+	}
 
 	bc, chainDB := MakeChain(ctx)
 	if bc == nil || chainDB == nil {
@@ -65,6 +71,12 @@ func buildAddrTxIndexCmd(ctx *cli.Context) error {
 	}
 	defer chainDB.Close()
 
-	bc.SetAtxi(&core.AtxiT{Db: indexDB, AutoMode: false, Progress: &core.AtxiProgressT{}})
+	bc.SetAtxi(&core.AtxiT{
+		Db:       indexDB,
+		AutoMode: false,
+		Progress: &core.AtxiProgressT{},
+		Internal: ctx.GlobalBool(aliasableName(AddrTxIndexInternalFlag.Name, ctx)),
+		Tokens:   ctx.GlobalBool(aliasableName(AddrTxIndexTokensFlag.Name, ctx)),
+	})
 	return core.BuildAddrTxIndex(bc, chainDB, indexDB, startIndex, stopIndex, step)
 }
@@ -30,7 +30,10 @@ import (
 	"github.com/ethereumproject/go-ethereum/core"
 	"github.com/ethereumproject/go-ethereum/core/state"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/trie"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -116,6 +119,39 @@ var (
 		space limitations, or attack.
 		`,
 	}
+	pruneStateCommand = cli.Command{
+		Action:  pruneState,
+		Name:    "prune-state",
+		Aliases: []string{"prunestate"},
+		Usage:   "Delete stale state trie nodes from the chain database",
+		Description: `
+	Prune-state removes all state trie nodes which are not reachable from the state
+	roots of the most recent blocks, so that the states of older blocks will no
+	longer be available. The node must not be running.
+
+	Retained nodes are first marked in a bloom filter, which is saved next to the
+	chain database. If pruning is interrupted, running the command again resumes
+	deleting with the saved filter.
+
+	Use --dry-run to report the space which would be reclaimed without deleting anything.
+		`,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "blocks",
+				Usage: "Number of most recent block states to retain",
+				Value: 128,
+			},
+			cli.IntFlag{
+				Name:  "bloomsize",
+				Usage: "Megabytes of memory allocated to the bloom filter of retained nodes",
+				Value: 2048,
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Report the space which would be reclaimed without deleting anything",
+			},
+		},
+	}
 )
 
 func importChain(ctx *cli.Context) error {
@@ -125,6 +161,7 @@ func importChain(ctx *cli.Context) error {
 	chain, chainDb := MakeChain(ctx)
 	start := time.Now()
 	err := ImportChain(chain, ctx.Args().First())
+	chain.Stop()
 	chainDb.Close()
 	if err != nil {
 		log.Fatal("Import error: ", err)
@@ -291,6 +328,96 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+func pruneState(ctx *cli.Context) error {
+	blocks := ctx.Int("blocks")
+	if blocks < 1 {
+		log.Fatal("--blocks must be at least 1")
+	}
+	dryRun := ctx.Bool("dry-run")
+
+	chainDb := MakeChainDatabase(ctx)
+	defer chainDb.Close()
+	// The state lives in the key-value part of the database, skip the freezer
+	kvDb := chainDb
+	if fdb, ok := chainDb.(*ethdb.FreezerDatabase); ok {
+		kvDb = fdb.Database
+	}
+	bloomPath := filepath.Join(MustMakeChainDataDir(ctx), "prune-state.bloom")
+
+	// Resume an interrupted run if its bloom filter is still around
+	pruner, err := state.LoadPruner(kvDb, bloomPath)
+	if err == nil && !dryRun {
+		glog.D(logger.Warn).Infof("Resuming interrupted state pruning with %s", bloomPath)
+	} else {
+		head := core.GetBlock(chainDb, core.GetHeadBlockHash(chainDb))
+		if head == nil {
+			log.Fatal("head block not found")
+		}
+		if fast := core.GetBlock(chainDb, core.GetHeadFastBlockHash(chainDb)); fast != nil && fast.NumberU64() > head.NumberU64() {
+			log.Fatalf("fast sync in progress (fast block #%d, full block #%d), finish syncing before pruning", fast.NumberU64(), head.NumberU64())
+		}
+		// Collect the roots of the most recent states, oldest first
+		var roots []common.Hash
+		for block := head; block != nil && len(roots) < blocks; block = core.GetBlock(chainDb, block.ParentHash()) {
+			if _, err := trie.New(block.Root(), chainDb); err == nil {
+				roots = append([]common.Hash{block.Root()}, roots...)
+			} else if block == head {
+				log.Fatalf("state of head block #%d missing", head.NumberU64())
+			}
+			if block.NumberU64() == 0 {
+				break
+			}
+		}
+		glog.D(logger.Warn).Infof("Marking %d state roots of blocks up to #%d", len(roots), head.NumberU64())
+
+		pruner = state.NewPruner(kvDb, uint64(ctx.Int("bloomsize"))*1024*1024)
+		start, logged := time.Now(), time.Now()
+		err := pruner.Mark(roots, func(marked uint64) {
+			if time.Since(logged) > 8*time.Second {
+				glog.D(logger.Warn).Infof("Marking state: %d nodes, elapsed %v", marked, time.Since(start))
+				logged = time.Now()
+			}
+		})
+		if err != nil {
+			log.Fatalf("failed to mark state: %v", err)
+		}
+		glog.D(logger.Warn).Infof("Marking done in %v", time.Since(start))
+
+		if !dryRun {
+			if err := pruner.Save(bloomPath); err != nil {
+				log.Fatalf("failed to save state bloom: %v", err)
+			}
+		}
+	}
+	// Delete (or count) everything that wasn't marked
+	start, logged := time.Now(), time.Now()
+	stats, err := pruner.Sweep(dryRun, func(stats state.PruneStats) {
+		if time.Since(logged) > 8*time.Second {
+			glog.D(logger.Warn).Infof("Sweeping state: scanned %d entries, %d stale nodes (%v), elapsed %v", stats.Scanned, stats.Deleted, stats.Size, time.Since(start))
+			logged = time.Now()
+		}
+	})
+	if err != nil {
+		log.Fatalf("failed to sweep state: %v", err)
+	}
+	if dryRun {
+		glog.D(logger.Warn).Infof("Dry run: %d of %d entries are stale trie nodes, %v would be reclaimed", stats.Deleted, stats.Scanned, stats.Size)
+		return nil
+	}
+	glog.D(logger.Warn).Infof("Deleted %d stale trie nodes (%v) in %v", stats.Deleted, stats.Size, time.Since(start))
+	if err := os.Remove(bloomPath); err != nil {
+		glog.D(logger.Error).Errorf("failed to remove state bloom: %v", err)
+	}
+
+	glog.D(logger.Warn).Infoln("Compacting database to reclaim disk space...")
+	start = time.Now()
+	if err := kvDb.Compact(nil, nil); err != nil {
+		log.Fatalf("failed to compact database: %v", err)
+	}
+	glog.D(logger.Warn).Infof("Compaction done in %v", time.Since(start))
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
@@ -707,10 +707,13 @@ func dumpChainConfig(ctx *cli.Context) error {
 
 // startNode boots up the system node and all registered protocols, after which
 // it unlocks any requested accounts, and starts the RPC/IPC interfaces and the
-// miner.
+// miner. A light client node has no full Ethereum service, nil is returned.
 func startNode(ctx *cli.Context, stack *node.Node) *eth.Ethereum {
 	// Start up the node itself
 	StartNode(stack)
+	if ctx.GlobalBool(aliasableName(LightModeFlag.Name, ctx)) {
+		return nil
+	}
 
 	// Unlock any account specifically requested
 	var ethereum *eth.Ethereum
@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"math/big"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -40,6 +42,8 @@ import (
 	"github.com/ethereumproject/go-ethereum/eth"
 	"github.com/ethereumproject/go-ethereum/ethdb"
 	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/graphql"
+	"github.com/ethereumproject/go-ethereum/les"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/ethereumproject/go-ethereum/miner"
@@ -47,6 +51,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/p2p/discover"
 	"github.com/ethereumproject/go-ethereum/p2p/nat"
 	"github.com/ethereumproject/go-ethereum/pow"
+	"github.com/ethereumproject/go-ethereum/rpc"
 	"github.com/ethereumproject/go-ethereum/whisper"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -338,6 +343,84 @@ func MakeRPCModules(input string) []string {
 	return result
 }
 
+// MakeRPCPolicy creates the access policy of the HTTP and WS RPC interfaces and
+// of the GraphQL endpoint from the set command line flags.
+func MakeRPCPolicy(ctx *cli.Context) rpc.Policy {
+	policy := rpc.Policy{
+		MaxRequestSize:   int64(ctx.GlobalInt(RPCMaxRequestSizeFlag.Name)),
+		MethodRateLimits: make(map[string]rpc.RateLimit),
+	}
+	if allow := ctx.GlobalString(RPCAllowFlag.Name); allow != "" {
+		policy.Allow = MakeRPCModules(allow)
+	}
+	if deny := ctx.GlobalString(RPCDenyFlag.Name); deny != "" {
+		policy.Deny = MakeRPCModules(deny)
+	}
+	if limit := ctx.GlobalString(RPCRateLimitFlag.Name); limit != "" {
+		rate, err := parseRateLimit(limit)
+		if err != nil {
+			glog.Fatalf("invalid --%s: %v", RPCRateLimitFlag.Name, err)
+		}
+		policy.RateLimit = rate
+	}
+	if limits := ctx.GlobalString(RPCMethodRateLimitsFlag.Name); limits != "" {
+		for _, limit := range MakeRPCModules(limits) {
+			parts := strings.SplitN(limit, "=", 2)
+			if len(parts) != 2 {
+				glog.Fatalf("invalid --%s: %q is not method=rate[:burst]", RPCMethodRateLimitsFlag.Name, limit)
+			}
+			rate, err := parseRateLimit(parts[1])
+			if err != nil {
+				glog.Fatalf("invalid --%s: %v", RPCMethodRateLimitsFlag.Name, err)
+			}
+			policy.MethodRateLimits[strings.TrimSpace(parts[0])] = rate
+		}
+	}
+	if path := ctx.GlobalString(RPCAuthTokensFlag.Name); path != "" {
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			glog.Fatal("Failed to read RPC auth tokens file: ", err)
+		}
+		for _, line := range strings.Split(string(text), "\n") {
+			if token := strings.TrimSpace(line); token != "" {
+				policy.AuthTokens = append(policy.AuthTokens, token)
+			}
+		}
+		if len(policy.AuthTokens) == 0 {
+			glog.Fatalf("RPC auth tokens file %s holds no tokens", path)
+		}
+	}
+	if path := ctx.GlobalString(RPCJWTSecretFlag.Name); path != "" {
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			glog.Fatal("Failed to read RPC JWT secret file: ", err)
+		}
+		secret := common.FromHex(strings.TrimSpace(string(text)))
+		if len(secret) < 32 {
+			glog.Fatalf("RPC JWT secret in %s is shorter than 32 bytes", path)
+		}
+		policy.JWTSecret = secret
+	}
+	return policy
+}
+
+// parseRateLimit parses a rate limit given as rate[:burst] requests per second.
+// The burst defaults to the rate, rounded up.
+func parseRateLimit(s string) (rpc.RateLimit, error) {
+	parts := strings.SplitN(s, ":", 2)
+	rate, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || rate <= 0 {
+		return rpc.RateLimit{}, fmt.Errorf("invalid rate %q", parts[0])
+	}
+	limit := rpc.RateLimit{Rate: rate, Burst: int(math.Ceil(rate))}
+	if len(parts) == 2 {
+		if limit.Burst, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil || limit.Burst < 1 {
+			return rpc.RateLimit{}, fmt.Errorf("invalid burst %q", parts[1])
+		}
+	}
+	return limit, nil
+}
+
 // MakeHTTPRpcHost creates the HTTP RPC listener interface string from the set
 // command line flags, returning empty if the HTTP endpoint is disabled.
 func MakeHTTPRpcHost(ctx *cli.Context) string {
@@ -495,8 +578,23 @@ func MakeSystemNode(version string, ctx *cli.Context) *node.Node {
 	if err != nil {
 		glog.Fatalf("%v: failed to create the protocol stack: ", ErrStackFail, err)
 	}
-	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
-		return eth.New(ctx, ethConf)
+	if ctx.GlobalBool(aliasableName(LightModeFlag.Name, ctx)) {
+		if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+			return les.New(ctx, ethConf)
+		}); err != nil {
+			glog.Fatalf("%v: failed to register the light Ethereum service: %v", ErrStackFail, err)
+		}
+	} else if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		fullNode, err := eth.New(ctx, ethConf)
+		if err != nil || ethConf.LightServ == 0 {
+			return fullNode, err
+		}
+		ls, err := les.NewLesServer(fullNode, ethConf)
+		if err != nil {
+			return nil, err
+		}
+		fullNode.AddLesServer(ls)
+		return fullNode, nil
 	}); err != nil {
 		glog.Fatalf("%v: failed to register the Ethereum service: ", ErrStackFail, err)
 	}
@@ -505,6 +603,12 @@ func MakeSystemNode(version string, ctx *cli.Context) *node.Node {
 			glog.Fatalf("%v: failed to register the Whisper service: ", ErrStackFail, err)
 		}
 	}
+	if ctx.GlobalBool(GraphQLEnabledFlag.Name) {
+		if ctx.GlobalBool(aliasableName(LightModeFlag.Name, ctx)) {
+			glog.Fatalf("%v: the GraphQL service is not available in light mode", ErrStackFail)
+		}
+		mustRegisterGraphQL(ctx, stack)
+	}
 
 	// If --mlog enabled, configure and create mlog dir and file
 	if ctx.GlobalString(MLogFlag.Name) != "off" {
@@ -518,6 +622,31 @@ func MakeSystemNode(version string, ctx *cli.Context) *node.Node {
 	return stack
 }
 
+// mustRegisterGraphQL registers the GraphQL service, serving the chain of the
+// Ethereum service on its own HTTP endpoint. The endpoint shares the access
+// policy and the TLS certificate of the HTTP RPC interface.
+func mustRegisterGraphQL(ctx *cli.Context, stack *node.Node) {
+	config := graphql.Config{
+		Endpoint: net.JoinHostPort(ctx.GlobalString(GraphQLListenAddrFlag.Name), strconv.Itoa(ctx.GlobalInt(GraphQLPortFlag.Name))),
+		CORS:     ctx.GlobalString(GraphQLCORSDomainFlag.Name),
+		VHosts:   MakeRPCModules(ctx.GlobalString(GraphQLVirtualHostsFlag.Name)),
+		TLSCert:  ctx.GlobalString(RPCTLSCertFlag.Name),
+		TLSKey:   ctx.GlobalString(RPCTLSKeyFlag.Name),
+		Policy:   MakeRPCPolicy(ctx),
+		MaxCost:  ctx.GlobalInt(GraphQLMaxCostFlag.Name),
+		Timeout:  ctx.GlobalDuration(GraphQLTimeoutFlag.Name),
+	}
+	if err := stack.Register(func(sctx *node.ServiceContext) (node.Service, error) {
+		var ethereum *eth.Ethereum
+		if err := sctx.Service(&ethereum); err != nil {
+			return nil, err
+		}
+		return graphql.New(ethereum, config)
+	}); err != nil {
+		glog.Fatalf("%v: failed to register the GraphQL service: %v", ErrStackFail, err)
+	}
+}
+
 // shouldAttemptDirMigration decides based on flags if
 // should attempt to migration from old (<=3.3) directory schema to new.
 func shouldAttemptDirMigration(ctx *cli.Context) bool {
@@ -532,24 +661,32 @@ func shouldAttemptDirMigration(ctx *cli.Context) bool {
 func mustMakeStackConf(ctx *cli.Context, name string, config *core.SufficientChainConfig) (stackConf *node.Config, shhEnable bool) {
 	// Configure the node's service container
 	stackConf = &node.Config{
-		DataDir:         MustMakeChainDataDir(ctx),
-		PrivateKey:      MakeNodeKey(ctx),
-		Name:            name,
-		NoDiscovery:     ctx.GlobalBool(aliasableName(NoDiscoverFlag.Name, ctx)),
-		BootstrapNodes:  config.ParsedBootstrap,
-		ListenAddr:      MakeListenAddress(ctx),
-		NAT:             MakeNAT(ctx),
-		MaxPeers:        ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
-		MaxPendingPeers: ctx.GlobalInt(aliasableName(MaxPendingPeersFlag.Name, ctx)),
-		IPCPath:         MakeIPCPath(ctx),
-		HTTPHost:        MakeHTTPRpcHost(ctx),
-		HTTPPort:        ctx.GlobalInt(aliasableName(RPCPortFlag.Name, ctx)),
-		HTTPCors:        ctx.GlobalString(aliasableName(RPCCORSDomainFlag.Name, ctx)),
-		HTTPModules:     MakeRPCModules(ctx.GlobalString(aliasableName(RPCApiFlag.Name, ctx))),
-		WSHost:          MakeWSRpcHost(ctx),
-		WSPort:          ctx.GlobalInt(aliasableName(WSPortFlag.Name, ctx)),
-		WSOrigins:       ctx.GlobalString(aliasableName(WSAllowedOriginsFlag.Name, ctx)),
-		WSModules:       MakeRPCModules(ctx.GlobalString(aliasableName(WSApiFlag.Name, ctx))),
+		DataDir:          MustMakeChainDataDir(ctx),
+		DatabaseEngine:   ctx.GlobalString(aliasableName(DatabaseEngineFlag.Name, ctx)),
+		PrivateKey:       MakeNodeKey(ctx),
+		Name:             name,
+		NoDiscovery:      ctx.GlobalBool(aliasableName(NoDiscoverFlag.Name, ctx)),
+		BootstrapNodes:   config.ParsedBootstrap,
+		ListenAddr:       MakeListenAddress(ctx),
+		NAT:              MakeNAT(ctx),
+		MaxPeers:         ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
+		MaxPendingPeers:  ctx.GlobalInt(aliasableName(MaxPendingPeersFlag.Name, ctx)),
+		IPCPath:          MakeIPCPath(ctx),
+		HTTPHost:         MakeHTTPRpcHost(ctx),
+		HTTPPort:         ctx.GlobalInt(aliasableName(RPCPortFlag.Name, ctx)),
+		HTTPCors:         ctx.GlobalString(aliasableName(RPCCORSDomainFlag.Name, ctx)),
+		HTTPModules:      MakeRPCModules(ctx.GlobalString(aliasableName(RPCApiFlag.Name, ctx))),
+		HTTPVirtualHosts: MakeRPCModules(ctx.GlobalString(aliasableName(RPCVirtualHostsFlag.Name, ctx))),
+		HTTPTLSCert:      ctx.GlobalString(RPCTLSCertFlag.Name),
+		HTTPTLSKey:       ctx.GlobalString(RPCTLSKeyFlag.Name),
+		WSHost:           MakeWSRpcHost(ctx),
+		WSPort:           ctx.GlobalInt(aliasableName(WSPortFlag.Name, ctx)),
+		WSOrigins:        ctx.GlobalString(aliasableName(WSAllowedOriginsFlag.Name, ctx)),
+		WSModules:        MakeRPCModules(ctx.GlobalString(aliasableName(WSApiFlag.Name, ctx))),
+		WSVirtualHosts:   MakeRPCModules(ctx.GlobalString(aliasableName(WSVirtualHostsFlag.Name, ctx))),
+		WSTLSCert:        ctx.GlobalString(WSTLSCertFlag.Name),
+		WSTLSKey:         ctx.GlobalString(WSTLSKeyFlag.Name),
+		RPCPolicy:        MakeRPCPolicy(ctx),
 	}
 
 	// Configure the Whisper service
@@ -590,11 +727,17 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 	ethConf := &eth.Config{
 		ChainConfig:             sconf.ChainConfig,
 		Genesis:                 sconf.Genesis,
+		Checkpoint:              sconf.Checkpoint,
 		UseAddrTxIndex:          ctx.GlobalBool(aliasableName(AddrTxIndexFlag.Name, ctx)),
+		AddrTxIndexInternal:     ctx.GlobalBool(aliasableName(AddrTxIndexInternalFlag.Name, ctx)),
+		AddrTxIndexTokens:       ctx.GlobalBool(aliasableName(AddrTxIndexTokensFlag.Name, ctx)),
 		FastSync:                ctx.GlobalBool(aliasableName(FastSyncFlag.Name, ctx)),
+		LightServ:               ctx.GlobalInt(aliasableName(LightServFlag.Name, ctx)),
 		BlockChainVersion:       ctx.GlobalInt(aliasableName(BlockchainVersionFlag.Name, ctx)),
 		DatabaseCache:           ctx.GlobalInt(aliasableName(CacheFlag.Name, ctx)),
 		DatabaseHandles:         MakeDatabaseHandles(),
+		GCMode:                  ctx.GlobalString(aliasableName(GCModeFlag.Name, ctx)),
+		AncientThreshold:        uint64(ctx.GlobalInt(aliasableName(AncientThresholdFlag.Name, ctx))),
 		NetworkId:               sconf.Network,
 		MaxPeers:                ctx.GlobalInt(aliasableName(MaxPeersFlag.Name, ctx)),
 		AccountManager:          accman,
@@ -622,6 +765,35 @@ func mustMakeEthConf(ctx *cli.Context, sconf *core.SufficientChainConfig) *eth.C
 	if _, ok := ethConf.GpoMaxGasPrice.SetString(ctx.GlobalString(aliasableName(GpoMaxGasPriceFlag.Name, ctx)), 0); !ok {
 		log.Fatalf("malformed %s flag value %q", aliasableName(GpoMaxGasPriceFlag.Name, ctx), ctx.GlobalString(aliasableName(GpoMaxGasPriceFlag.Name, ctx)))
 	}
+	if ethConf.LightServ < 0 || ethConf.LightServ > 100 {
+		log.Fatalf("invalid %s flag value %d, want a percentage between 0 and 100", aliasableName(LightServFlag.Name, ctx), ethConf.LightServ)
+	}
+
+	// The transaction pool limits are unsigned, so negative values would wrap
+	// around past the sanity checks of the pool
+	txPoolLimit := func(flag cli.IntFlag) uint64 {
+		name := aliasableName(flag.Name, ctx)
+		limit := ctx.GlobalInt(name)
+		if limit < 0 {
+			log.Fatalf("invalid %s flag value %d, want a non-negative number", name, limit)
+		}
+		return uint64(limit)
+	}
+	ethConf.TxPool = core.TxPoolConfig{
+		Journal:      ctx.GlobalString(aliasableName(TxPoolJournalFlag.Name, ctx)),
+		Rejournal:    ctx.GlobalDuration(aliasableName(TxPoolRejournalFlag.Name, ctx)),
+		PriceBump:    txPoolLimit(TxPoolPriceBumpFlag),
+		AccountSlots: txPoolLimit(TxPoolAccountSlotsFlag),
+		GlobalSlots:  txPoolLimit(TxPoolGlobalSlotsFlag),
+		AccountQueue: txPoolLimit(TxPoolAccountQueueFlag),
+		GlobalQueue:  txPoolLimit(TxPoolGlobalQueueFlag),
+	}
+
+	switch ethConf.GCMode {
+	case core.GCModeFull, core.GCModeArchive:
+	default:
+		log.Fatalf("invalid %s flag value %q, want %q or %q", aliasableName(GCModeFlag.Name, ctx), ethConf.GCMode, core.GCModeFull, core.GCModeArchive)
+	}
 
 	switch sconf.Consensus {
 	case "ethash-test":
@@ -796,29 +968,35 @@ func MustMakeChainConfigFromDefaults(ctx *cli.Context) *core.ChainConfig {
 	return c
 }
 
-// MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
+// MakeChainDatabase open the chain database using the flags passed to the client and will hard crash if it fails.
 func MakeChainDatabase(ctx *cli.Context) ethdb.Database {
 	var (
 		chaindir = MustMakeChainDataDir(ctx)
+		engine   = ctx.GlobalString(aliasableName(DatabaseEngineFlag.Name, ctx))
 		cache    = ctx.GlobalInt(aliasableName(CacheFlag.Name, ctx))
 		handles  = MakeDatabaseHandles()
 	)
 
-	chainDb, err := ethdb.NewLDBDatabase(filepath.Join(chaindir, "chaindata"), cache, handles)
+	chainDb, err := ethdb.NewDatabase(engine, filepath.Join(chaindir, "chaindata"), cache, handles)
 	if err != nil {
 		glog.Fatal("Could not open database: ", err)
 	}
-	return chainDb
+	freezerDb, err := ethdb.NewDatabaseWithFreezer(chainDb, filepath.Join(chaindir, "chaindata", "ancient"), core.FreezerTables)
+	if err != nil {
+		glog.Fatal("Could not open ancient database: ", err)
+	}
+	return freezerDb
 }
 
 func MakeIndexDatabase(ctx *cli.Context) ethdb.Database {
 	var (
 		chaindir = MustMakeChainDataDir(ctx)
+		engine   = ctx.GlobalString(aliasableName(DatabaseEngineFlag.Name, ctx))
 		cache    = ctx.GlobalInt(aliasableName(CacheFlag.Name, ctx))
 		handles  = MakeDatabaseHandles()
 	)
 
-	indexesDb, err := ethdb.NewLDBDatabase(filepath.Join(chaindir, "indexes"), cache, handles)
+	indexesDb, err := ethdb.NewDatabase(engine, filepath.Join(chaindir, "indexes"), cache, handles)
 	if err != nil {
 		glog.Fatal("Could not open database: ", err)
 	}
@@ -843,6 +1021,10 @@ func MakeChain(ctx *cli.Context) (chain *core.BlockChain, chainDb ethdb.Database
 	if err != nil {
 		glog.Fatal("Could not start chainmanager: ", err)
 	}
+	if err := chain.SetGCMode(ctx.GlobalString(aliasableName(GCModeFlag.Name, ctx))); err != nil {
+		glog.Fatal(err)
+	}
+	chain.SetAncientThreshold(uint64(ctx.GlobalInt(aliasableName(AncientThresholdFlag.Name, ctx))))
 	return chain, chainDb
 }
 
@@ -11,6 +11,8 @@ import (
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/core"
 	"github.com/ethereumproject/go-ethereum/eth"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/graphql"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/ethereumproject/go-ethereum/rpc"
 	"gopkg.in/urfave/cli.v1"
@@ -85,6 +87,21 @@ var (
 		Usage: "Megabytes of memory allocated to internal caching (min 16MB / database forced)",
 		Value: 1024,
 	}
+	GCModeFlag = cli.StringFlag{
+		Name:  "gcmode",
+		Usage: `Blockchain garbage collection mode ("full", "archive")`,
+		Value: core.GCModeArchive,
+	}
+	DatabaseEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: `Storage engine of the chain and index databases ("leveldb", "boltdb")`,
+		Value: ethdb.EngineLevelDB,
+	}
+	AncientThresholdFlag = cli.IntFlag{
+		Name:  "ancient-threshold",
+		Usage: "Number of recent blocks kept in the chain database, older ones are moved to chaindata/ancient (0 = disabled)",
+		Value: core.DefaultAncientThreshold,
+	}
 	BlockchainVersionFlag = cli.IntFlag{
 		Name:  "blockchain-version,blockchainversion",
 		Usage: "Blockchain version (integer)",
@@ -94,6 +111,15 @@ var (
 		Name:  "fast",
 		Usage: "Enable fast syncing through state downloads",
 	}
+	LightModeFlag = cli.BoolFlag{
+		Name:  "light",
+		Usage: "Enable light client mode: sync only headers and retrieve the rest on demand from LES servers",
+	}
+	LightServFlag = cli.IntFlag{
+		Name:  "lightserv",
+		Usage: "Maximum percentage of time allowed for serving LES requests (0-100, 0 = disabled)",
+		Value: 0,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "light-kdf,lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -106,6 +132,14 @@ var (
 		Name:  "atxi.autobuild,atxi.auto-build",
 		Usage: "Begins automatic concurrent indexes building process that runs alongside a normally running geth.",
 	}
+	AddrTxIndexInternalFlag = cli.BoolFlag{
+		Name:  "atxi.internal",
+		Usage: "Also index the addresses reached by internal value transfers (CALLs with value). Only blocks imported with this flag are indexed for them",
+	}
+	AddrTxIndexTokensFlag = cli.BoolFlag{
+		Name:  "atxi.tokens",
+		Usage: "Also index the senders and recipients of ERC20 token Transfer events",
+	}
 	// Network Split settings
 	ETFChain = cli.BoolFlag{
 		Name:  "etf",
@@ -281,6 +315,56 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: rpc.DefaultHTTPApis,
 	}
+	RPCVirtualHostsFlag = cli.StringFlag{
+		Name:  "rpc-vhosts,rpcvhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept HTTP-RPC requests (server enforced, '*' = any)",
+		Value: "localhost",
+	}
+	RPCTLSCertFlag = cli.StringFlag{
+		Name:  "rpc.tls.cert",
+		Usage: "PEM encoded certificate file to serve HTTP-RPC and GraphQL over HTTPS with",
+		Value: "",
+	}
+	RPCTLSKeyFlag = cli.StringFlag{
+		Name:  "rpc.tls.key",
+		Usage: "PEM encoded private key file of the HTTP-RPC certificate",
+		Value: "",
+	}
+	RPCMaxRequestSizeFlag = cli.IntFlag{
+		Name:  "rpc-max-request-size",
+		Usage: "Maximum size in bytes of HTTP-RPC and GraphQL request bodies and WS-RPC messages (0 = 128 KiB over HTTP, unlimited over WS)",
+		Value: 0,
+	}
+	RPCRateLimitFlag = cli.StringFlag{
+		Name:  "rpc-rate-limit",
+		Usage: "Requests per second allowed per client IP over HTTP-RPC, WS-RPC and GraphQL, as rate[:burst] (empty = unlimited)",
+		Value: "",
+	}
+	RPCMethodRateLimitsFlag = cli.StringFlag{
+		Name:  "rpc-method-rate-limits",
+		Usage: "Comma separated requests per second allowed per client IP for single methods, as method=rate[:burst]",
+		Value: "",
+	}
+	RPCAllowFlag = cli.StringFlag{
+		Name:  "rpc-allow",
+		Usage: "Comma separated methods (e.g. eth_call) or modules (e.g. net) allowed over HTTP-RPC, WS-RPC and GraphQL, where queries are graphql_query calls (empty = all offered)",
+		Value: "",
+	}
+	RPCDenyFlag = cli.StringFlag{
+		Name:  "rpc-deny",
+		Usage: "Comma separated methods or modules denied over HTTP-RPC, WS-RPC and GraphQL, overriding --rpc-allow",
+		Value: "",
+	}
+	RPCAuthTokensFlag = cli.StringFlag{
+		Name:  "rpc-auth-tokens",
+		Usage: "File of bearer tokens, one per line, required by HTTP-RPC, WS-RPC and GraphQL",
+		Value: "",
+	}
+	RPCJWTSecretFlag = cli.StringFlag{
+		Name:  "rpc-jwt-secret",
+		Usage: "File holding the hex secret of HS256 JWT bearer tokens required by HTTP-RPC, WS-RPC and GraphQL",
+		Value: "",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipc-disable,ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -319,6 +403,55 @@ var (
 		Usage: "Origins from which to accept websockets requests",
 		Value: "",
 	}
+	WSVirtualHostsFlag = cli.StringFlag{
+		Name:  "ws-vhosts,wsvhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept WS-RPC requests (server enforced, '*' = any)",
+		Value: "localhost",
+	}
+	WSTLSCertFlag = cli.StringFlag{
+		Name:  "ws.tls.cert",
+		Usage: "PEM encoded certificate file to serve WS-RPC over secure websockets (wss) with",
+		Value: "",
+	}
+	WSTLSKeyFlag = cli.StringFlag{
+		Name:  "ws.tls.key",
+		Usage: "PEM encoded private key file of the WS-RPC certificate",
+		Value: "",
+	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable the GraphQL server (not available in light mode)",
+	}
+	GraphQLListenAddrFlag = cli.StringFlag{
+		Name:  "graphql.addr",
+		Usage: "GraphQL server listening interface",
+		Value: common.DefaultGraphQLHost,
+	}
+	GraphQLPortFlag = cli.IntFlag{
+		Name:  "graphql.port",
+		Usage: "GraphQL server listening port",
+		Value: common.DefaultGraphQLPort,
+	}
+	GraphQLCORSDomainFlag = cli.StringFlag{
+		Name:  "graphql.corsdomain",
+		Usage: "Comma separated list of domains from which to accept cross origin GraphQL requests (browser enforced)",
+		Value: "",
+	}
+	GraphQLVirtualHostsFlag = cli.StringFlag{
+		Name:  "graphql.vhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept GraphQL requests (server enforced, '*' = any)",
+		Value: "localhost",
+	}
+	GraphQLMaxCostFlag = cli.IntFlag{
+		Name:  "graphql.maxcost",
+		Usage: "Maximum estimated cost of a GraphQL query, in fields resolved (list fields multiply the cost of their selection)",
+		Value: graphql.DefaultMaxCost,
+	}
+	GraphQLTimeoutFlag = cli.DurationFlag{
+		Name:  "graphql.timeout",
+		Usage: "Time limit of the execution of a GraphQL query",
+		Value: graphql.DefaultTimeout,
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement (only in combination with console/attach)",
@@ -384,6 +517,43 @@ var (
 		Value: "solc",
 	}
 
+	// Transaction pool settings
+	TxPoolJournalFlag = cli.StringFlag{
+		Name:  "txpool.journal",
+		Usage: "Disk journal for local transactions to survive node restarts",
+		Value: core.DefaultTxPoolConfig.Journal,
+	}
+	TxPoolRejournalFlag = cli.DurationFlag{
+		Name:  "txpool.rejournal",
+		Usage: "Time interval to regenerate the local transaction journal",
+		Value: core.DefaultTxPoolConfig.Rejournal,
+	}
+	TxPoolPriceBumpFlag = cli.IntFlag{
+		Name:  "txpool.pricebump",
+		Usage: "Price bump percentage to replace an already existing transaction",
+		Value: int(core.DefaultTxPoolConfig.PriceBump),
+	}
+	TxPoolAccountSlotsFlag = cli.IntFlag{
+		Name:  "txpool.accountslots",
+		Usage: "Minimum number of executable transaction slots guaranteed per account",
+		Value: int(core.DefaultTxPoolConfig.AccountSlots),
+	}
+	TxPoolGlobalSlotsFlag = cli.IntFlag{
+		Name:  "txpool.globalslots",
+		Usage: "Maximum number of executable transaction slots for all accounts",
+		Value: int(core.DefaultTxPoolConfig.GlobalSlots),
+	}
+	TxPoolAccountQueueFlag = cli.IntFlag{
+		Name:  "txpool.accountqueue",
+		Usage: "Maximum number of non-executable transaction slots permitted per account",
+		Value: int(core.DefaultTxPoolConfig.AccountQueue),
+	}
+	TxPoolGlobalQueueFlag = cli.IntFlag{
+		Name:  "txpool.globalqueue",
+		Usage: "Maximum number of non-executable transaction slots for all accounts",
+		Value: int(core.DefaultTxPoolConfig.GlobalQueue),
+	}
+
 	// Gas price oracle settings
 	GpoMinGasPriceFlag = cli.StringFlag{
 		Name:  "gpo-min,gpomin",
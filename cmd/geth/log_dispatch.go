@@ -181,7 +181,7 @@ func runDisplayLogs(ctx *cli.Context, e *eth.Ethereum, tickerInterval time.Durat
 			handledEvents = append(handledEvents, h.ev)
 		}
 	}
-	var ethEvents event.Subscription
+	var ethEvents event.TypeMuxSubscription
 	if len(handledEvents) > 0 {
 		ethEvents = e.EventMux().Subscribe(handledEvents...)
 	}
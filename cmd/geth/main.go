@@ -121,6 +121,7 @@ func makeCLIApp() (app *cli.App) {
 		dumpCommand,
 		rollbackCommand,
 		recoverCommand,
+		pruneStateCommand,
 		resetCommand,
 		monitorCommand,
 		accountCommand,
@@ -153,9 +154,16 @@ func makeCLIApp() (app *cli.App) {
 		ChainIdentityFlag,
 		BlockchainVersionFlag,
 		FastSyncFlag,
+		LightModeFlag,
+		LightServFlag,
 		AddrTxIndexFlag,
 		AddrTxIndexAutoBuildFlag,
+		AddrTxIndexInternalFlag,
+		AddrTxIndexTokensFlag,
 		CacheFlag,
+		GCModeFlag,
+		DatabaseEngineFlag,
+		AncientThresholdFlag,
 		LightKDFFlag,
 		JSpathFlag,
 		ListenPortFlag,
@@ -163,6 +171,13 @@ func makeCLIApp() (app *cli.App) {
 		MaxPendingPeersFlag,
 		EtherbaseFlag,
 		GasPriceFlag,
+		TxPoolJournalFlag,
+		TxPoolRejournalFlag,
+		TxPoolPriceBumpFlag,
+		TxPoolAccountSlotsFlag,
+		TxPoolGlobalSlotsFlag,
+		TxPoolAccountQueueFlag,
+		TxPoolGlobalQueueFlag,
 		MinerThreadsFlag,
 		MiningEnabledFlag,
 		MiningGPUFlag,
@@ -177,11 +192,24 @@ func makeCLIApp() (app *cli.App) {
 		RPCListenAddrFlag,
 		RPCPortFlag,
 		RPCApiFlag,
+		RPCVirtualHostsFlag,
+		RPCTLSCertFlag,
+		RPCTLSKeyFlag,
 		WSEnabledFlag,
 		WSListenAddrFlag,
 		WSPortFlag,
 		WSApiFlag,
 		WSAllowedOriginsFlag,
+		WSVirtualHostsFlag,
+		WSTLSCertFlag,
+		WSTLSKeyFlag,
+		GraphQLEnabledFlag,
+		GraphQLListenAddrFlag,
+		GraphQLPortFlag,
+		GraphQLCORSDomainFlag,
+		GraphQLVirtualHostsFlag,
+		GraphQLMaxCostFlag,
+		GraphQLTimeoutFlag,
 		IPCDisabledFlag,
 		IPCApiFlag,
 		IPCPathFlag,
@@ -192,6 +220,13 @@ func makeCLIApp() (app *cli.App) {
 		TestNetFlag,
 		NetworkIdFlag,
 		RPCCORSDomainFlag,
+		RPCMaxRequestSizeFlag,
+		RPCRateLimitFlag,
+		RPCMethodRateLimitsFlag,
+		RPCAllowFlag,
+		RPCDenyFlag,
+		RPCAuthTokensFlag,
+		RPCJWTSecretFlag,
 		NeckbeardFlag,
 		VerbosityFlag,
 		DisplayFlag,
@@ -340,7 +375,7 @@ func geth(ctx *cli.Context) error {
 	n := MakeSystemNode(Version, ctx)
 	ethe := startNode(ctx, n)
 
-	if ctx.GlobalString(LogStatusFlag.Name) != "off" {
+	if ethe != nil && ctx.GlobalString(LogStatusFlag.Name) != "off" {
 		dispatchStatusLogs(ctx, ethe)
 	}
 	logLoggingConfiguration(ctx)
@@ -68,6 +68,7 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			dumpCommand,
 			rollbackCommand,
 			recoverCommand,
+			pruneStateCommand,
 			resetCommand,
 		},
 		Flags: []cli.Flag{
@@ -77,7 +78,12 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			DevModeFlag,
 			NodeNameFlag,
 			FastSyncFlag,
+			LightModeFlag,
+			LightServFlag,
 			CacheFlag,
+			GCModeFlag,
+			DatabaseEngineFlag,
+			AncientThresholdFlag,
 			LightKDFFlag,
 			SputnikVMFlag,
 			BlockchainVersionFlag,
@@ -97,6 +103,8 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			AccountsIndexFlag,
 			AddrTxIndexFlag,
 			AddrTxIndexAutoBuildFlag,
+			AddrTxIndexInternalFlag,
+			AddrTxIndexTokensFlag,
 		},
 	},
 	{
@@ -112,15 +120,35 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			RPCListenAddrFlag,
 			RPCPortFlag,
 			RPCApiFlag,
+			RPCVirtualHostsFlag,
+			RPCTLSCertFlag,
+			RPCTLSKeyFlag,
 			WSEnabledFlag,
 			WSListenAddrFlag,
 			WSPortFlag,
 			WSApiFlag,
 			WSAllowedOriginsFlag,
+			WSVirtualHostsFlag,
+			WSTLSCertFlag,
+			WSTLSKeyFlag,
+			GraphQLEnabledFlag,
+			GraphQLListenAddrFlag,
+			GraphQLPortFlag,
+			GraphQLCORSDomainFlag,
+			GraphQLVirtualHostsFlag,
+			GraphQLMaxCostFlag,
+			GraphQLTimeoutFlag,
 			IPCDisabledFlag,
 			IPCApiFlag,
 			IPCPathFlag,
 			RPCCORSDomainFlag,
+			RPCMaxRequestSizeFlag,
+			RPCRateLimitFlag,
+			RPCMethodRateLimitsFlag,
+			RPCAllowFlag,
+			RPCDenyFlag,
+			RPCAuthTokensFlag,
+			RPCJWTSecretFlag,
 			JSpathFlag,
 			ExecFlag,
 			PreloadJSFlag,
@@ -155,6 +183,18 @@ var AppHelpFlagAndCommandGroups = []flagGroup{
 			ExtraDataFlag,
 		},
 	},
+	{
+		Name: "TRANSACTION POOL",
+		Flags: []cli.Flag{
+			TxPoolJournalFlag,
+			TxPoolRejournalFlag,
+			TxPoolPriceBumpFlag,
+			TxPoolAccountSlotsFlag,
+			TxPoolGlobalSlotsFlag,
+			TxPoolAccountQueueFlag,
+			TxPoolGlobalQueueFlag,
+		},
+	},
 	{
 		Name: "GAS PRICE ORACLE",
 		Flags: []cli.Flag{
@@ -29,6 +29,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/core"
 	"github.com/ethereumproject/go-ethereum/core/state"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
 	"github.com/ethereumproject/go-ethereum/crypto"
 	"github.com/ethereumproject/go-ethereum/ethdb"
 	"github.com/ethereumproject/go-ethereum/logger"
@@ -93,7 +94,7 @@ func (api *PrivateRegistarAPI) SaveInfo(info *compiler.ContractInfo, filename st
 // Register registers a new content hash in the registry.
 func (api *PrivateRegistarAPI) Register(sender common.Address, addr common.Address, contentHashHex string) (bool, error) {
 	block := api.be.bc.CurrentBlock()
-	state, err := state.New(block.Root(), state.NewDatabase(api.be.chainDb))
+	state, err := api.be.bc.StateAt(block.Root())
 	if err != nil {
 		return false, err
 	}
@@ -173,7 +174,7 @@ func (be *registryAPIBackend) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr
 	}
 
 	block := be.bc.CurrentBlock()
-	statedb, err := state.New(block.Root(), state.NewDatabase(be.chainDb))
+	statedb, err := be.bc.StateAt(block.Root())
 	if err != nil {
 		return "", "", err
 	}
@@ -207,7 +208,7 @@ func (be *registryAPIBackend) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr
 	}
 
 	header := be.bc.CurrentBlock().Header()
-	vmenv := core.NewEnv(statedb, be.config, be.bc, msg, header)
+	vmenv := core.NewEnv(statedb, be.config, be.bc, msg, header, vm.Config{})
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 	res, gas, _, err := core.ApplyMessage(vmenv, msg, gp)
 
@@ -217,7 +218,7 @@ func (be *registryAPIBackend) Call(fromStr, toStr, valueStr, gasStr, gasPriceStr
 // StorageAt returns the data stores in the state for the given address and location.
 func (be *registryAPIBackend) StorageAt(addr string, storageAddr string) string {
 	block := be.bc.CurrentBlock()
-	state, err := state.New(block.Root(), state.NewDatabase(be.chainDb))
+	state, err := be.bc.StateAt(block.Root())
 	if err != nil {
 		return ""
 	}
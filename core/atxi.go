@@ -6,26 +6,49 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
 	"os/signal"
-	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/state"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/crypto"
 	"github.com/ethereumproject/go-ethereum/ethdb"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/rlp"
 )
 
 var (
 	errAtxiNotEnabled = errors.New("atxi not intialized")
 	errAtxiInvalidUse = errors.New("invalid parameters passed to ATXI")
 
-	txAddressIndexPrefix = []byte("atx-")
-	txAddressBookmarkKey = []byte("ATXIBookmark")
+	// txAddressIndexPrefix + address + block number (big endian) + direction + kind of + tx hash -> nil
+	// The block number is big endian so that the keys of an address are in block order.
+	txAddressIndexPrefix = []byte("atx2-")
+	// txAddressIndexLegacyPrefix keys the index with little endian block numbers,
+	// see MigrateAddrTxIndex.
+	txAddressIndexLegacyPrefix = []byte("atx-")
+	txAddressBookmarkKey       = []byte("ATXIBookmark")
+
+	// txInternalTransfersPrefix + block hash -> internal value transfers of the block
+	txInternalTransfersPrefix = []byte("atxi-transfers-")
+
+	// erc20TransferTopic is the topic of the ERC20 Transfer(address,address,uint256) event.
+	erc20TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+// Kinds of transactions an address can be indexed for, the 'kindof' byte of the index key.
+const (
+	atxiKindStandard = 's' // top-level transaction to an account
+	atxiKindContract = 'c' // top-level contract creation
+	atxiKindInternal = 'i' // value transfer by an internal CALL
+	atxiKindToken    = 't' // ERC20 token Transfer event
 )
 
 type AtxiT struct {
@@ -33,6 +56,21 @@ type AtxiT struct {
 	AutoMode bool
 	Progress *AtxiProgressT
 	Step     uint64
+
+	// Internal enables indexing the addresses reached by internal CALLs with
+	// value. These are collected by tracing the blocks on import, so blocks
+	// processed without it aren't indexed for them, not even by atxi-build.
+	Internal bool
+	// Tokens enables indexing the senders and recipients of ERC20 token
+	// Transfer events.
+	Tokens bool
+}
+
+// atxiTransfer is a value transfer made by a contract during the execution of
+// a transaction.
+type atxiTransfer struct {
+	TxHash   common.Hash
+	From, To common.Address
 }
 
 type AtxiProgressT struct {
@@ -70,11 +108,11 @@ func formatAddrTxIterator(address common.Address) (iteratorPrefix []byte) {
 	return
 }
 
-// formatAddrTxBytesIndex formats the index key, eg. atx-<addr><blockNumber><t|f><s|c|><txhash>
+// formatAddrTxBytesIndex formats the index key, eg. atx2-<addr><blockNumber><t|f><s|c|i|t><txhash>
 // The values for these arguments should be of determinate length and format, see test TestFormatAndResolveAddrTxBytesKey
 // for example.
 func formatAddrTxBytesIndex(address, blockNumber, direction, kindof, txhash []byte) (key []byte) {
-	key = make([]byte, 0, 66) // 66 is the total capacity of the key = prefix(4)+addr(20)+blockNumber(8)+dir(1)+kindof(1)+txhash(32)
+	key = make([]byte, 0, 67) // 67 is the total capacity of the key = prefix(5)+addr(20)+blockNumber(8)+dir(1)+kindof(1)+txhash(32)
 	key = append(key, txAddressIndexPrefix...)
 	key = append(key, address...)
 	key = append(key, blockNumber...)
@@ -86,19 +124,71 @@ func formatAddrTxBytesIndex(address, blockNumber, direction, kindof, txhash []by
 
 // resolveAddrTxBytes resolves the index key to individual []byte values
 func resolveAddrTxBytes(key []byte) (address, blockNumber, direction, kindof, txhash []byte) {
-	// prefix = key[:4]
-	address = key[4:24]      // common.AddressLength = 20
-	blockNumber = key[24:32] // uint64 via little endian
-	direction = key[32:33]   // == key[32] (1 byte)
-	kindof = key[33:34]
-	txhash = key[34:]
+	key = key[len(txAddressIndexPrefix):]
+	address = key[:20]       // common.AddressLength = 20
+	blockNumber = key[20:28] // uint64 via big endian
+	direction = key[28:29]   // == key[28] (1 byte)
+	kindof = key[29:30]
+	txhash = key[30:]
 	return
 }
 
-// WriteBlockAddTxIndexes writes atx-indexes for a given block.
+// encodeAddrTxBlockNumber encodes a block number for the index keys.
+func encodeAddrTxBlockNumber(number uint64) []byte {
+	bn := make([]byte, 8)
+	binary.BigEndian.PutUint64(bn, number)
+	return bn
+}
+
+// MigrateAddrTxIndex moves the entries of an index written with the legacy key
+// layout, which isn't in block order, to the current one. Every batch moves
+// its entries atomically, so an interrupted migration resumes where it left off.
+func MigrateAddrTxIndex(db ethdb.Database) error {
+	it := db.NewIterator(txAddressIndexLegacyPrefix, nil)
+	defer it.Release()
+
+	var (
+		batch = db.NewBatch()
+		moved int
+	)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(txAddressIndexLegacyPrefix)+62 {
+			continue
+		}
+		legacy := key[len(txAddressIndexLegacyPrefix):]
+		bn := encodeAddrTxBlockNumber(binary.LittleEndian.Uint64(legacy[20:28]))
+		if err := batch.Put(formatAddrTxBytesIndex(legacy[:20], bn, legacy[28:29], legacy[29:30], legacy[30:]), nil); err != nil {
+			return err
+		}
+		if err := batch.Delete(common.CopyBytes(key)); err != nil {
+			return err
+		}
+		moved++
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if moved > 0 {
+		glog.V(logger.Info).Infof("Migrated %d address-transaction index entries", moved)
+	}
+	return nil
+}
+
+// WriteBlockAddTxIndexes writes atx-indexes for the top-level transactions of
+// a given block.
 func WriteBlockAddTxIndexes(indexDb ethdb.Database, block *types.Block) error {
 	batch := indexDb.NewBatch()
-	if _, err := putBlockAddrTxsToBatch(batch, block); err != nil {
+	if _, err := putBlockAddrTxsToBatch(batch, block, nil, nil); err != nil {
 		return err
 	}
 	return batch.Write()
@@ -106,64 +196,186 @@ func WriteBlockAddTxIndexes(indexDb ethdb.Database, block *types.Block) error {
 
 // putBlockAddrTxsToBatch formats and puts keys for a given block to a db Batch.
 // Batch can be written afterward if no errors, ie. batch.Write()
-func putBlockAddrTxsToBatch(putBatch ethdb.Batch, block *types.Block) (txsCount int, err error) {
-	for _, tx := range block.Transactions() {
-		txsCount++
+// Token transfers are indexed from receipts and internal transfers from
+// transfers, either can be nil to skip them.
+func putBlockAddrTxsToBatch(putBatch ethdb.Putter, block *types.Block, receipts types.Receipts, transfers []atxiTransfer) (txsCount int, err error) {
+	keys, err := blockAddrTxKeys(block, receipts, transfers)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := putBatch.Put(key, nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(block.Transactions()), nil
+}
 
+// blockAddrTxKeys returns the atx-index keys of a given block.
+func blockAddrTxKeys(block *types.Block, receipts types.Receipts, transfers []atxiTransfer) (keys [][]byte, err error) {
+	bn := encodeAddrTxBlockNumber(block.NumberU64())
+
+	add := func(from, to common.Address, kindof byte, txHash common.Hash) {
+		keys = append(keys,
+			formatAddrTxBytesIndex(from.Bytes(), bn, []byte("f"), []byte{kindof}, txHash.Bytes()),
+			formatAddrTxBytesIndex(to.Bytes(), bn, []byte("t"), []byte{kindof}, txHash.Bytes()),
+		)
+	}
+	for _, tx := range block.Transactions() {
 		from, err := tx.From()
 		if err != nil {
-			return txsCount, err
+			return nil, err
 		}
 		to := tx.To()
-		// s: standard
-		// c: contract
-		txKindOf := []byte("s")
+		var kindof byte = atxiKindStandard
 		if to == nil || to.IsEmpty() {
 			to = &common.Address{}
-			txKindOf = []byte("c")
+			kindof = atxiKindContract
 		}
+		add(from, *to, kindof, tx.Hash())
+	}
+	for _, transfer := range transfers {
+		add(transfer.From, transfer.To, atxiKindInternal, transfer.TxHash)
+	}
+	txs := block.Transactions()
+	for i, receipt := range receipts {
+		if i >= len(txs) {
+			break
+		}
+		for _, log := range receipt.Logs {
+			// ERC721 uses the same event signature with an indexed token id,
+			// only the three topics of ERC20 transfers are taken.
+			if len(log.Topics) != 3 || log.Topics[0] != erc20TransferTopic {
+				continue
+			}
+			add(common.BytesToAddress(log.Topics[1][:]), common.BytesToAddress(log.Topics[2][:]), atxiKindToken, txs[i].Hash())
+		}
+	}
+	return keys, nil
+}
 
-		// Note that len 8 because uint64 guaranteed <= 8 bytes.
-		bn := make([]byte, 8)
-		binary.LittleEndian.PutUint64(bn, block.NumberU64())
+// blockKeys returns the atx-index keys of a given block, including the
+// optional kinds of transactions enabled.
+func (a *AtxiT) blockKeys(chainDb ethdb.Database, block *types.Block) ([][]byte, error) {
+	var (
+		receipts  types.Receipts
+		transfers []atxiTransfer
+	)
+	if a.Tokens {
+		receipts = GetBlockReceipts(chainDb, block.Hash())
+	}
+	if a.Internal {
+		transfers = getAtxiTransfers(a.Db, block.Hash())
+	}
+	return blockAddrTxKeys(block, receipts, transfers)
+}
 
-		if err := putBatch.Put(formatAddrTxBytesIndex(from.Bytes(), bn, []byte("f"), txKindOf, tx.Hash().Bytes()), nil); err != nil {
-			return txsCount, err
+// putBlock puts the atx-index keys of a given block to a db Batch.
+func (a *AtxiT) putBlock(putBatch ethdb.Putter, chainDb ethdb.Database, block *types.Block) error {
+	keys, err := a.blockKeys(chainDb, block)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := putBatch.Put(key, nil); err != nil {
+			return err
 		}
-		if err := putBatch.Put(formatAddrTxBytesIndex(to.Bytes(), bn, []byte("t"), txKindOf, tx.Hash().Bytes()), nil); err != nil {
-			return txsCount, err
+	}
+	return nil
+}
+
+// deleteBlock deletes the atx-index keys of a given block with a db Batch, eg.
+// in the case of chain reorg.
+func (a *AtxiT) deleteBlock(deleteBatch ethdb.Deleter, chainDb ethdb.Database, block *types.Block) error {
+	keys, err := a.blockKeys(chainDb, block)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := deleteBatch.Delete(key); err != nil {
+			return err
 		}
 	}
-	return txsCount, nil
+	return nil
 }
 
-type atxi struct {
-	blockN uint64
-	tx     string
+// writeBlock writes the atx-indexes for a given block.
+func (a *AtxiT) writeBlock(chainDb ethdb.Database, block *types.Block) error {
+	batch := a.Db.NewBatch()
+	if err := a.putBlock(batch, chainDb, block); err != nil {
+		return err
+	}
+	return batch.Write()
 }
-type sortableAtxis []atxi
 
-// Len implements sort.Sort interface.
-func (s sortableAtxis) Len() int {
-	return len(s)
+// getAtxiTransfers retrieves the internal value transfers of a block.
+func getAtxiTransfers(db ethdb.Database, hash common.Hash) []atxiTransfer {
+	data, _ := db.Get(append(append([]byte{}, txInternalTransfersPrefix...), hash.Bytes()...))
+	if len(data) == 0 {
+		return nil
+	}
+	var transfers []atxiTransfer
+	if err := rlp.DecodeBytes(data, &transfers); err != nil {
+		glog.V(logger.Error).Errorf("invalid internal transfers RLP for block %x: %v", hash, err)
+		return nil
+	}
+	return transfers
 }
 
-// Less implements sort.Sort interface.
-// By default newer transactions by blockNumber are first.
-func (s sortableAtxis) Less(i, j int) bool {
-	return s[i].blockN > s[j].blockN
+// writeAtxiTransfers stores the internal value transfers of a block, so that
+// they can be indexed once the block becomes canonical.
+func writeAtxiTransfers(db ethdb.Putter, hash common.Hash, transfers []atxiTransfer) error {
+	if len(transfers) == 0 {
+		return nil
+	}
+	data, err := rlp.EncodeToBytes(transfers)
+	if err != nil {
+		return err
+	}
+	return db.Put(append(append([]byte{}, txInternalTransfersPrefix...), hash.Bytes()...), data)
 }
 
-// Swap implements sort.Sort interface.
-func (s sortableAtxis) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
+// processBlock processes a block with the block processor. If the atxi
+// indexes internal transfers, the transactions are traced to collect the value
+// transfers of their internal CALLs, which are stored for indexing.
+func (bc *BlockChain) processBlock(block *types.Block, statedb *state.StateDB) (types.Receipts, vm.Logs, *big.Int, error) {
+	sp, ok := bc.processor.(*StateProcessor)
+	if bc.atxi == nil || !bc.atxi.Internal || !ok {
+		return bc.processor.Process(block, statedb)
+	}
+	tracers := make([]*vm.CallTracer, len(block.Transactions()))
+	receipts, logs, usedGas, err := sp.Trace(block, statedb, func(i int, tx *types.Transaction) vm.Config {
+		tracers[i] = vm.NewCallTracer()
+		return vm.Config{Tracer: tracers[i]}
+	})
+	if err != nil {
+		return receipts, logs, usedGas, err
+	}
+	var transfers []atxiTransfer
+	for i, tx := range block.Transactions() {
+		transfers = appendInternalTransfers(transfers, tx.Hash(), tracers[i].Frame(), true)
+	}
+	if err := writeAtxiTransfers(bc.atxi.Db, block.Hash(), transfers); err != nil {
+		return receipts, logs, usedGas, err
+	}
+	return receipts, logs, usedGas, nil
 }
-func (s sortableAtxis) TxStrings() []string {
-	var out = make([]string, 0, len(s))
-	for _, str := range s {
-		out = append(out, str.tx)
+
+// appendInternalTransfers appends the value transfers of the successful
+// internal CALLs in the call frame tree. The outermost frame is the
+// transaction itself, which is indexed as such.
+func appendInternalTransfers(transfers []atxiTransfer, txHash common.Hash, frame *vm.CallFrame, outermost bool) []atxiTransfer {
+	// a failed frame reverts the frames it spawned too
+	if frame == nil || frame.Err != nil {
+		return transfers
+	}
+	if !outermost && frame.Type == vm.CALL && frame.Value != nil && frame.Value.Sign() > 0 {
+		transfers = append(transfers, atxiTransfer{TxHash: txHash, From: frame.From, To: frame.To})
 	}
-	return out
+	for _, call := range frame.Calls {
+		transfers = appendInternalTransfers(transfers, txHash, call, false)
+	}
+	return transfers
 }
 
 func BuildAddrTxIndex(bc *BlockChain, chainDB, indexDB ethdb.Database, startIndex, stopIndex, step uint64) error {
@@ -288,110 +500,215 @@ func (bc *BlockChain) GetATXIBuildProgress() (*AtxiProgressT, error) {
 	return bc.atxi.Progress, nil
 }
 
-// GetAddrTxs gets the indexed transactions for a given account address.
-// 'reverse' means "oldest first"
-func GetAddrTxs(db ethdb.Database, address common.Address, blockStartN uint64, blockEndN uint64, direction string, kindof string, paginationStart int, paginationEnd int, reverse bool) (txs []string, err error) {
+// resolveAtxiKinds resolves the 'kind of' param of GetAddrTxs to the kindof
+// bytes it selects, empty for all of them. The param is either a word prefixed
+// by a kind, eg. "token", or a combination of kinds, eg. "si".
+func resolveAtxiKinds(kindof string) (kinds string, ok bool) {
+	if kindof == "" {
+		return "", true
+	}
+	if strings.Trim(kindof, "bscit") != "" {
+		kindof = kindof[:1]
+	}
+	if strings.Trim(kindof, "bscit") != "" {
+		return "", false
+	}
+	if strings.ContainsRune(kindof, 'b') {
+		return "", true
+	}
+	return kindof, true
+}
+
+// addrTxFilter selects the index entries of an address, see GetAddrTxs for
+// the meaning of the params.
+type addrTxFilter struct {
+	address    common.Address
+	blockStart uint64 // 0 for no lower bound
+	blockEnd   uint64 // 0 for no upper bound
+	direction  byte   // 'b', 't' or 'f'
+	kinds      string // empty for all of them
+}
+
+func newAddrTxFilter(address common.Address, blockStartN uint64, blockEndN uint64, direction string, kindof string) (*addrTxFilter, error) {
 	errWithReason := func(e error, s string) error {
 		return fmt.Errorf("%v: %s", e, s)
 	}
 
 	// validate params
 	if len(direction) > 0 && !strings.Contains("btf", direction[:1]) {
-		err = errWithReason(errAtxiInvalidUse, "Address transactions list signature requires direction param to be empty string or [b|t|f] prefix (eg. both, to, or from)")
-		return
-	}
-	if len(kindof) > 0 && !strings.Contains("bsc", kindof[:1]) {
-		err = errWithReason(errAtxiInvalidUse, "Address transactions list signature requires 'kind of' param to be empty string or [s|c] prefix (eg. both, standard, or contract)")
-		return
-	}
-	if paginationStart > 0 && paginationEnd > 0 && paginationStart > paginationEnd {
-		err = errWithReason(errAtxiInvalidUse, "Pagination start must be less than or equal to pagination end params")
-		return
-	}
-	if paginationStart < 0 {
-		paginationStart = 0
+		return nil, errWithReason(errAtxiInvalidUse, "Address transactions list signature requires direction param to be empty string or [b|t|f] prefix (eg. both, to, or from)")
 	}
-
-	// Have to cast to LevelDB to use iterator. Yuck.
-	ldb, ok := db.(*ethdb.LDBDatabase)
+	kinds, ok := resolveAtxiKinds(kindof)
 	if !ok {
-		err = errWithReason(errors.New("internal interface error; please file a bug report"), "could not cast eth db to level db")
-		return nil, nil
+		return nil, errWithReason(errAtxiInvalidUse, "Address transactions list signature requires 'kind of' param to be empty string, [b|s|c|i|t] prefix (eg. both, standard, contract, internal, or token) or a combination of [s|c|i|t] (eg. 'si')")
 	}
 
-	// This will be the returnable.
-	var hashes []string
-
 	// Map direction -> byte
-	var wantDirectionB byte = 'b'
+	var directionB byte = 'b'
 	if len(direction) > 0 {
-		wantDirectionB = direction[0]
+		directionB = direction[0]
 	}
-	var wantKindOf byte = 'b'
-	if len(kindof) > 0 {
-		wantKindOf = kindof[0]
-	}
-
-	// Create address prefix for iteration.
-	prefix := ethdb.NewBytesPrefix(formatAddrTxIterator(address))
-	it := ldb.NewIteratorRange(prefix)
+	return &addrTxFilter{address: address, blockStart: blockStartN, blockEnd: blockEndN, direction: directionB, kinds: kinds}, nil
+}
 
-	var atxis sortableAtxis
+// walk streams the distinct transactions matching the filter, in block order
+// or reverse block order, to fn until it returns false. The transactions are
+// identified by the index key without the address prefix, which can be
+// passed back as cursor to continue after it.
+//
+// A transaction can be indexed for an address under several kinds and
+// directions, all of them in the block of the transaction. The keys of the
+// cursor's block are walked again to skip the transactions already listed.
+func (f *addrTxFilter) walk(db ethdb.Database, cursor []byte, reverse bool, fn func(pos []byte, tx common.Hash) bool) error {
+	var (
+		prefix = formatAddrTxIterator(f.address)
+		it     ethdb.Iterator
+	)
+	if !reverse {
+		var start []byte
+		if cursor != nil {
+			start = cursor[:8]
+		} else if f.blockStart > 0 {
+			start = encodeAddrTxBlockNumber(f.blockStart)
+		}
+		it = db.NewIterator(prefix, start)
+	} else {
+		var start []byte
+		if cursor != nil {
+			if n := binary.BigEndian.Uint64(cursor[:8]); n < math.MaxUint64 {
+				start = encodeAddrTxBlockNumber(n + 1)
+			}
+		} else if f.blockEnd > 0 && f.blockEnd < math.MaxUint64 {
+			start = encodeAddrTxBlockNumber(f.blockEnd + 1)
+		}
+		it = db.NewReverseIterator(prefix, start)
+	}
+	defer it.Release()
 
+	var (
+		seen   = make(map[common.Hash]bool)
+		seenBn uint64
+	)
 	for it.Next() {
 		key := it.Key()
-
 		_, blockNum, torf, k, txh := resolveAddrTxBytes(key)
+		bn := binary.BigEndian.Uint64(blockNum)
 
-		bn := binary.LittleEndian.Uint64(blockNum)
-
-		// If atxi is smaller than blockstart, skip
-		if blockStartN > 0 && bn < blockStartN {
-			continue
+		// Stop past the block range
+		if !reverse && f.blockEnd > 0 && bn > f.blockEnd {
+			break
 		}
-		// If atxi is greater than blockend, skip
-		if blockEndN > 0 && bn > blockEndN {
-			continue
+		if reverse && f.blockStart > 0 && bn < f.blockStart {
+			break
 		}
 		// Ensure matching direction if spec'd
-		if wantDirectionB != 'b' && wantDirectionB != torf[0] {
+		if f.direction != 'b' && f.direction != torf[0] {
 			continue
 		}
-		// Ensure filter for/agnostic transaction kind of (contract, standard, both)
-		if wantKindOf != 'b' && wantKindOf != k[0] {
+		// Ensure filter for/agnostic transaction kind of (standard, contract, internal, token, all)
+		if f.kinds != "" && !strings.ContainsRune(f.kinds, rune(k[0])) {
+			continue
+		}
+		if bn != seenBn {
+			seen, seenBn = make(map[common.Hash]bool), bn
+		}
+		tx := common.BytesToHash(txh)
+		if seen[tx] {
 			continue
 		}
-		if len(hashes) > 0 {
+		seen[tx] = true
 
+		pos := key[len(prefix):]
+		if cursor != nil {
+			if c := bytes.Compare(pos, cursor); (!reverse && c <= 0) || (reverse && c >= 0) {
+				continue
+			}
+		}
+		if !fn(common.CopyBytes(pos), tx) {
+			break
 		}
-		tx := common.ToHex(txh)
-		atxis = append(atxis, atxi{blockN: bn, tx: tx})
 	}
-	it.Release()
-	err = it.Error()
+	return it.Error()
+}
+
+// GetAddrTxs gets the indexed transactions for a given account address.
+// 'reverse' means "oldest first"
+// The transactions are streamed from the index, so big pagination offsets
+// still walk over the skipped ones; use GetAddrTxsPage to page through many.
+func GetAddrTxs(db ethdb.Database, address common.Address, blockStartN uint64, blockEndN uint64, direction string, kindof string, paginationStart int, paginationEnd int, reverse bool) (txs []string, err error) {
+	f, err := newAddrTxFilter(address, blockStartN, blockEndN, direction, kindof)
 	if err != nil {
+		return nil, err
+	}
+	if paginationStart > 0 && paginationEnd > 0 && paginationStart > paginationEnd {
+		err = fmt.Errorf("%v: %s", errAtxiInvalidUse, "Pagination start must be less than or equal to pagination end params")
 		return
 	}
+	if paginationStart < 0 {
+		paginationStart = 0
+	}
+	if paginationEnd >= 0 && paginationEnd <= paginationStart {
+		return nil, nil
+	}
 
-	handleSorting := func(s sortableAtxis) sortableAtxis {
-		if len(s) <= 1 {
-			return s
-		}
-		sort.Sort(s) // newest txs (by blockNumber) latest
-		if reverse {
-			for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-				s[i], s[j] = s[j], s[i]
-			}
+	// Newer transactions by blockNumber are first by default.
+	var i int
+	err = f.walk(db, nil, !reverse, func(pos []byte, tx common.Hash) bool {
+		if i >= paginationStart {
+			txs = append(txs, tx.Hex())
 		}
-		if paginationStart > len(s) {
-			paginationStart = len(s)
+		i++
+		return paginationEnd < 0 || i < paginationEnd
+	})
+	return
+}
+
+// GetAddrTxsPage gets a page of at most limit indexed transactions for a given
+// account address, continuing after the opaque cursor returned along with the
+// previous page, if any. The returned cursor is empty after the last page.
+// Unlike GetAddrTxs 'reverse' means "newest first", the order of the keys
+// being the oldest first.
+func GetAddrTxsPage(db ethdb.Database, address common.Address, blockStartN uint64, blockEndN uint64, direction string, kindof string, cursor string, limit int, reverse bool) (txs []string, next string, err error) {
+	f, err := newAddrTxFilter(address, blockStartN, blockEndN, direction, kindof)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("%v: %s", errAtxiInvalidUse, "Page limit must be greater than zero")
+	}
+	var pos []byte
+	if cursor != "" {
+		if pos = common.FromHex(cursor); len(pos) != 8+1+1+common.HashLength {
+			return nil, "", fmt.Errorf("%v: %s", errAtxiInvalidUse, "Invalid page cursor")
 		}
-		if paginationEnd < 0 || paginationEnd > len(s) {
-			paginationEnd = len(s)
+	}
+	err = f.walk(db, pos, reverse, func(p []byte, tx common.Hash) bool {
+		// Only continue to a next page if there is a transaction left for it
+		if len(txs) == limit {
+			next = common.ToHex(pos)
+			return false
 		}
-		return s[paginationStart:paginationEnd]
+		txs = append(txs, tx.Hex())
+		pos = p
+		return true
+	})
+	if err != nil {
+		return nil, "", err
 	}
-	txs = handleSorting(atxis).TxStrings()
+	return txs, next, nil
+}
+
+// CountAddrTxs counts the indexed transactions for a given account address,
+// without keeping them around. The params are those of GetAddrTxs.
+func CountAddrTxs(db ethdb.Database, address common.Address, blockStartN uint64, blockEndN uint64, direction string, kindof string) (count uint64, err error) {
+	f, err := newAddrTxFilter(address, blockStartN, blockEndN, direction, kindof)
+	if err != nil {
+		return 0, err
+	}
+	err = f.walk(db, nil, false, func([]byte, common.Hash) bool {
+		count++
+		return true
+	})
 	return
 }
 
@@ -399,51 +716,32 @@ func GetAddrTxs(db ethdb.Database, address common.Address, blockStartN uint64, b
 // in the case of chain reorg.
 // It isn't an elegant function, but not a top priority for optimization because of
 // expected infrequency of it's being called.
+// Internal and token transfer entries of the tx are only removed for its sender
+// and recipient; use the block-wise deletion for a complete removal.
 func RmAddrTx(db ethdb.Database, tx *types.Transaction) error {
 	if tx == nil {
 		return nil
 	}
 
-	ldb, ok := db.(*ethdb.LDBDatabase)
-	if !ok {
-		return nil
-	}
-
 	txH := tx.Hash()
 	from, err := tx.From()
 	if err != nil {
 		return err
 	}
 
-	removals := [][]byte{}
-
-	// TODO: not DRY, could be refactored
-	pre := ethdb.NewBytesPrefix(formatAddrTxIterator(from))
-	it := ldb.NewIteratorRange(pre)
-	for it.Next() {
-		key := it.Key()
-		_, _, _, _, txh := resolveAddrTxBytes(key)
-		if bytes.Compare(txH.Bytes(), txh) == 0 {
-			removals = append(removals, key)
-			break // because there can be only one
-		}
-	}
-	it.Release()
-	if e := it.Error(); e != nil {
-		return e
+	addrs := []common.Address{from}
+	if to := tx.To(); to != nil {
+		addrs = append(addrs, *to)
 	}
 
-	to := tx.To()
-	if to != nil {
-		toRef := *to
-		pre := ethdb.NewBytesPrefix(formatAddrTxIterator(toRef))
-		it := ldb.NewIteratorRange(pre)
+	removals := [][]byte{}
+	for _, addr := range addrs {
+		it := db.NewIterator(formatAddrTxIterator(addr), nil)
 		for it.Next() {
 			key := it.Key()
 			_, _, _, _, txh := resolveAddrTxBytes(key)
-			if bytes.Compare(txH.Bytes(), txh) == 0 {
-				removals = append(removals, key)
-				break // because there can be only one
+			if bytes.Equal(txH.Bytes(), txh) {
+				removals = append(removals, common.CopyBytes(key))
 			}
 		}
 		it.Release()
@@ -452,10 +750,11 @@ func RmAddrTx(db ethdb.Database, tx *types.Transaction) error {
 		}
 	}
 
+	batch := db.NewBatch()
 	for _, r := range removals {
-		if err := db.Delete(r); err != nil {
+		if err := batch.Delete(r); err != nil {
 			return err
 		}
 	}
-	return nil
+	return batch.Write()
 }
@@ -82,7 +82,7 @@ func NewBlockValidator(config *ChainConfig, blockchain *BlockChain, pow pow.PoW)
 // false positives where a header is present but the state is not.
 func (v *BlockValidator) ValidateBlock(block *types.Block) error {
 	if v.bc.HasBlock(block.Hash()) {
-		if _, err := state.New(block.Root(), state.NewDatabase(v.bc.chainDb)); err == nil {
+		if _, err := state.New(block.Root(), v.bc.stateDb); err == nil {
 			return &KnownBlockError{block.Number(), block.Hash()}
 		}
 	}
@@ -90,7 +90,7 @@ func (v *BlockValidator) ValidateBlock(block *types.Block) error {
 	if parent == nil {
 		return ParentError(block.ParentHash())
 	}
-	if _, err := state.New(parent.Root(), state.NewDatabase(v.bc.chainDb)); err != nil {
+	if _, err := state.New(parent.Root(), v.bc.stateDb); err != nil {
 		return ParentError(block.ParentHash())
 	}
 
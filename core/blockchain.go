@@ -65,6 +65,22 @@ const (
 	// must be bumped when consensus algorithm is changed, this forces the upgradedb
 	// command to be run (forces the blocks to be imported again using the new algorithm)
 	BlockChainVersion = 3
+
+	// triesInMemory is the number of recent block states retained in memory
+	// when state garbage collection is enabled.
+	triesInMemory = 128
+	// trieFlushInterval is the number of blocks after which the oldest
+	// retained state is flushed to disk, bounding the work lost on a crash.
+	trieFlushInterval = 1024
+	// trieCacheLimit is the memory allowance of cached state trie nodes above
+	// which the oldest ones are flushed to disk.
+	trieCacheLimit = 256 * 1024 * 1024
+)
+
+// State trie garbage collection modes.
+const (
+	GCModeArchive = "archive" // Write the state of every block to disk
+	GCModeFull    = "full"    // Keep recent states in memory, garbage collect stale ones
 )
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -97,6 +113,7 @@ type BlockChain struct {
 	currentFastBlock *types.Block // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   *state.StateDB // State database to reuse between imports (contains state cache)
+	stateDb      state.Database // Trie and code database shared by all state accesses
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
@@ -113,6 +130,15 @@ type BlockChain struct {
 	validator Validator // block and state validator interface
 
 	atxi *AtxiT
+
+	gcmode    string                   // State trie garbage collection mode
+	gcmu      sync.Mutex               // Lock for the garbage collection bookkeeping
+	triegc    map[uint64][]common.Hash // State roots retained in memory, by block number
+	lastFlush uint64                   // Number of the last block whose state was flushed to disk
+
+	ancientThreshold uint64     // Number of recent blocks kept out of the freezer, 0 disables freezing (atomic)
+	freezeOnce       sync.Once  // Ensures the freezer goroutine is started only once
+	freezemu         sync.Mutex // Lock serializing the freezer migration with chain rewinds
 }
 
 type ChainInsertResult struct {
@@ -160,6 +186,9 @@ func NewBlockChain(chainDb ethdb.Database, config *ChainConfig, pow pow.PoW, mux
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
 		pow:          pow,
+		stateDb:      state.NewDatabase(chainDb),
+		gcmode:       GCModeArchive,
+		triegc:       make(map[uint64][]common.Hash),
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, pow))
 	bc.SetProcessor(NewStateProcessor(config, bc))
@@ -208,6 +237,9 @@ func NewBlockChainDryrun(chainDb ethdb.Database, config *ChainConfig, pow pow.Po
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
 		pow:          pow,
+		stateDb:      state.NewDatabase(chainDb),
+		gcmode:       GCModeArchive,
+		triegc:       make(map[uint64][]common.Hash),
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, pow))
 	bc.SetProcessor(NewStateProcessor(config, bc))
@@ -250,6 +282,20 @@ func (bc *BlockChain) SetAtxi(a *AtxiT) {
 	bc.atxi = a
 }
 
+// SetGCMode sets the state trie garbage collection mode, either GCModeArchive
+// or GCModeFull. It must be called before any blocks are imported.
+func (bc *BlockChain) SetGCMode(mode string) error {
+	switch mode {
+	case GCModeArchive, GCModeFull:
+	default:
+		return fmt.Errorf("invalid gc mode %q, want %q or %q", mode, GCModeFull, GCModeArchive)
+	}
+	bc.gcmu.Lock()
+	bc.gcmode = mode
+	bc.gcmu.Unlock()
+	return nil
+}
+
 // GetAtxi return indexes db and if atx index in use.
 func (bc *BlockChain) GetAtxi() *AtxiT {
 	return bc.atxi
@@ -608,6 +654,19 @@ func (bc *BlockChain) LoadLastState(dryrun bool) error {
 		return errors.New("nil currentBlock")
 	}
 
+	// Make sure the state of the head block is available. With state garbage
+	// collection only every few blocks are flushed to disk, so after a crash
+	// the head is rewound to the most recent block with a persisted state.
+	if !dryrun && !bc.hasState(currentBlock.Root()) {
+		if block := bc.repair(currentBlock); block != nil {
+			glog.V(logger.Warn).Errorf("Head state missing, rewinding from #%d [%x…] to #%d [%x…]", currentBlock.NumberU64(), currentBlock.Hash().Bytes()[:4], block.NumberU64(), block.Hash().Bytes()[:4])
+			if err := WriteHeadBlockHash(bc.chainDb, block.Hash()); err != nil {
+				return err
+			}
+			currentBlock = block
+		}
+	}
+
 	// If currentBlock (fullblock) is not genesis, check that it is valid
 	// and that it has a state associated with it.
 	if currentBlock.Number().Cmp(new(big.Int)) > 0 {
@@ -725,7 +784,7 @@ func (bc *BlockChain) LoadLastState(dryrun bool) error {
 	}
 
 	// Initialize a statedb cache to ensure singleton account bloom filter generation
-	statedb, err := state.New(bc.currentBlock.Root(), state.NewDatabase(bc.chainDb))
+	statedb, err := state.New(bc.currentBlock.Root(), bc.stateDb)
 	if err != nil {
 		return err
 	}
@@ -761,8 +820,8 @@ func (bc *BlockChain) LoadLastState(dryrun bool) error {
 func (bc *BlockChain) PurgeAbove(n uint64) {
 	bc.mu.Lock()
 
-	delFn := func(hash common.Hash) {
-		DeleteBody(bc.chainDb, hash)
+	delFn := func(db ethdb.Deleter, hash common.Hash) {
+		DeleteBody(db, hash)
 	}
 	bc.hc.PurgeAbove(n, delFn)
 	bc.mu.Unlock()
@@ -771,16 +830,20 @@ func (bc *BlockChain) PurgeAbove(n uint64) {
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
-// nodes after a fast sync).
+// nodes after a fast sync). The chain data is rewound in a single atomic batch.
 func (bc *BlockChain) SetHead(head uint64) error {
 	glog.V(logger.Warn).Infof("Setting blockchain head, target: %v", head)
 
+	bc.freezemu.Lock()
+	defer bc.freezemu.Unlock()
+
 	bc.mu.Lock()
 
-	delFn := func(hash common.Hash) {
-		DeleteBody(bc.chainDb, hash)
+	batch := bc.chainDb.NewBatch()
+	delFn := func(db ethdb.Deleter, hash common.Hash) {
+		DeleteBody(db, hash)
 	}
-	bc.hc.SetHead(head, delFn)
+	bc.hc.setHead(batch, head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
 	// Clear out any stale content from the caches
@@ -793,11 +856,10 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if bc.currentBlock != nil && currentHeader.Number.Uint64() < bc.currentBlock.NumberU64() {
 		bc.currentBlock = bc.GetBlock(currentHeader.Hash())
 	}
-	if bc.currentBlock != nil {
-		if _, err := state.New(bc.currentBlock.Root(), state.NewDatabase(bc.chainDb)); err != nil {
-			// Rewound state missing, rolled back to before pivot, reset to genesis
-			bc.currentBlock = nil
-		}
+	if bc.currentBlock != nil && !bc.hasState(bc.currentBlock.Root()) {
+		// Rewound state missing, try the last persisted state of a garbage
+		// collected chain, otherwise rolled back to before pivot, reset to genesis
+		bc.currentBlock = bc.repair(bc.currentBlock)
 	}
 	// Rewind the fast block in a simpleton way to the target head
 	if bc.currentFastBlock != nil && currentHeader.Number.Uint64() < bc.currentFastBlock.NumberU64() {
@@ -811,42 +873,34 @@ func (bc *BlockChain) SetHead(head uint64) error {
 		bc.currentFastBlock = bc.genesisBlock
 	}
 
-	if err := WriteHeadBlockHash(bc.chainDb, bc.currentBlock.Hash()); err != nil {
+	if err := WriteHeadBlockHash(batch, bc.currentBlock.Hash()); err != nil {
 		glog.Fatalf("failed to reset head block hash: %v", err)
 	}
-	if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
+	if err := WriteHeadFastBlockHash(batch, bc.currentFastBlock.Hash()); err != nil {
 		glog.Fatalf("failed to reset head fast block hash: %v", err)
 	}
+	if err := batch.Write(); err != nil {
+		glog.Fatalf("failed to rewind chain: %v", err)
+	}
+	bc.hc.truncateAncients(head)
 
 	if bc.atxi != nil && bc.atxi.AutoMode {
-		ldb, ok := bc.atxi.Db.(*ethdb.LDBDatabase)
-		if !ok {
-			glog.Fatal("could not cast indexes db to level db")
-		}
-
-		var removals [][]byte
-		deleteRemovalsFn := func(rs [][]byte) {
-			for _, r := range rs {
-				if e := ldb.Delete(r); e != nil {
-					glog.Fatal(e)
-				}
-			}
-		}
-
-		pre := ethdb.NewBytesPrefix(txAddressIndexPrefix)
-		it := ldb.NewIteratorRange(pre)
+		// The indexes live in their own database, remove them in batches
+		// to keep the memory use of big rollbacks in check
+		removals := bc.atxi.Db.NewBatch()
+		it := bc.atxi.Db.NewIterator(txAddressIndexPrefix, nil)
 
 		for it.Next() {
 			key := it.Key()
 			_, bn, _, _, _ := resolveAddrTxBytes(key)
-			n := binary.LittleEndian.Uint64(bn)
+			n := binary.BigEndian.Uint64(bn)
 			if n > head {
-				removals = append(removals, key)
-				// Prevent removals from getting too massive in case it's a big rollback
-				// 100000 is a guess at a big but not-too-big memory allowance
-				if len(removals) > 100000 {
-					deleteRemovalsFn(removals)
-					removals = [][]byte{}
+				removals.Delete(key)
+				if removals.ValueSize() >= ethdb.IdealBatchSize {
+					if e := removals.Write(); e != nil {
+						glog.Fatal(e)
+					}
+					removals.Reset()
 				}
 			}
 		}
@@ -854,7 +908,9 @@ func (bc *BlockChain) SetHead(head uint64) error {
 		if e := it.Error(); e != nil {
 			return e
 		}
-		deleteRemovalsFn(removals)
+		if e := removals.Write(); e != nil {
+			glog.Fatal(e)
+		}
 
 		// update atxi bookmark to lower head in the case that its progress was higher than the new head
 		if bc.atxi != nil && bc.atxi.AutoMode {
@@ -970,7 +1026,94 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, state.NewDatabase(bc.chainDb))
+	return state.New(root, bc.stateDb)
+}
+
+// StateDatabase returns the trie and code database backing all states of
+// the chain, including the ones only retained in memory.
+func (bc *BlockChain) StateDatabase() state.Database {
+	return bc.stateDb
+}
+
+// TrieNode retrieves a state trie node or contract code by hash, either from
+// the in-memory trie cache or from disk.
+func (bc *BlockChain) TrieNode(hash common.Hash) ([]byte, error) {
+	return bc.stateDb.TrieDB().Get(hash[:])
+}
+
+// hasState reports whether the state trie with the given root is available.
+func (bc *BlockChain) hasState(root common.Hash) bool {
+	_, err := bc.stateDb.OpenTrie(root)
+	return err == nil
+}
+
+// repair walks back from block to the most recent ancestor whose state is
+// available, looking at most as far back as garbage collection can lose
+// state. It returns nil if no such block was found.
+func (bc *BlockChain) repair(block *types.Block) *types.Block {
+	for i := 0; block != nil && i <= triesInMemory+trieFlushInterval; i++ {
+		if bc.hasState(block.Root()) {
+			return block
+		}
+		if block.NumberU64() == 0 {
+			break
+		}
+		block = bc.GetBlock(block.ParentHash())
+	}
+	return nil
+}
+
+// CommitState writes the state of block, as computed by the caller, to the
+// state database. In archive mode the state is written straight to disk. In
+// full mode it is retained in memory for triesInMemory blocks and dropped
+// afterwards, with a retained state flushed to disk every trieFlushInterval
+// blocks or whenever the cached nodes exceed trieCacheLimit.
+func (bc *BlockChain) CommitState(block *types.Block, statedb *state.StateDB) error {
+	bc.gcmu.Lock()
+	defer bc.gcmu.Unlock()
+
+	if bc.gcmode != GCModeFull {
+		_, err := statedb.CommitTo(bc.chainDb, false)
+		return err
+	}
+	triedb := bc.stateDb.TrieDB()
+	root, err := statedb.CommitTo(triedb, false)
+	if err != nil {
+		return err
+	}
+	triedb.Reference(root, common.Hash{})
+	number := block.NumberU64()
+	bc.triegc[number] = append(bc.triegc[number], root)
+
+	if number <= triesInMemory {
+		return nil
+	}
+	if triedb.Size() > trieCacheLimit {
+		if err := triedb.Cap(trieCacheLimit - ethdb.IdealBatchSize); err != nil {
+			return err
+		}
+	}
+	chosen := number - triesInMemory
+	if chosen >= bc.lastFlush+trieFlushInterval {
+		if header := bc.GetHeaderByNumber(chosen); header != nil {
+			glog.V(logger.Debug).Infof("Flushing state of block #%d, %d trie nodes (%v) in memory", chosen, triedb.Nodes(), triedb.Size())
+			if err := triedb.Commit(header.Root); err != nil {
+				return err
+			}
+			bc.lastFlush = chosen
+		}
+	}
+	// Garbage collect the states that fell out of the retention window
+	for n, roots := range bc.triegc {
+		if n > chosen {
+			continue
+		}
+		for _, root := range roots {
+			triedb.Dereference(root)
+		}
+		delete(bc.triegc, n)
+	}
+	return nil
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -1121,7 +1264,7 @@ func (bc *BlockChain) HasBlockAndState(hash common.Hash) bool {
 		return false
 	}
 	// Ensure the associated state is also present
-	_, err := state.New(block.Root(), state.NewDatabase(bc.chainDb))
+	_, err := state.New(block.Root(), bc.stateDb)
 	return err == nil
 }
 
@@ -1186,6 +1329,17 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Garbage collected states are only flushed periodically, persist the
+	// head state so the node can resume from it.
+	bc.gcmu.Lock()
+	if bc.gcmode == GCModeFull {
+		triedb := bc.stateDb.TrieDB()
+		if err := triedb.Commit(bc.CurrentBlock().Root()); err != nil {
+			glog.V(logger.Error).Errorf("Failed to commit head state: %v", err)
+		}
+	}
+	bc.gcmu.Unlock()
+
 	glog.V(logger.Info).Infoln("Chain manager stopped")
 }
 
@@ -1198,30 +1352,39 @@ const (
 )
 
 // Rollback is designed to remove a chain of links from the database that aren't
-// certain enough to be valid.
+// certain enough to be valid. The rewound head markers are written in a single
+// atomic batch.
 func (bc *BlockChain) Rollback(chain []common.Hash) {
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	batch := bc.chainDb.NewBatch()
 	for i := len(chain) - 1; i >= 0; i-- {
 		hash := chain[i]
 
 		if bc.hc.CurrentHeader().Hash() == hash {
-			bc.hc.SetCurrentHeader(bc.GetHeader(bc.hc.CurrentHeader().ParentHash))
+			header := bc.GetHeader(bc.hc.CurrentHeader().ParentHash)
+			if err := WriteHeadHeaderHash(batch, header.Hash()); err != nil {
+				glog.Fatalf("failed to write head header hash: %v", err)
+			}
+			bc.hc.currentHeader, bc.hc.currentHeaderHash = header, header.Hash()
 		}
 		if bc.currentFastBlock.Hash() == hash {
 			bc.currentFastBlock = bc.GetBlock(bc.currentFastBlock.ParentHash())
-			if err := WriteHeadFastBlockHash(bc.chainDb, bc.currentFastBlock.Hash()); err != nil {
+			if err := WriteHeadFastBlockHash(batch, bc.currentFastBlock.Hash()); err != nil {
 				glog.Fatalf("failed to write fast head block hash: %v", err)
 			}
 		}
 		if bc.currentBlock.Hash() == hash {
 			bc.currentBlock = bc.GetBlock(bc.currentBlock.ParentHash())
-			if err := WriteHeadBlockHash(bc.chainDb, bc.currentBlock.Hash()); err != nil {
+			if err := WriteHeadBlockHash(batch, bc.currentBlock.Hash()); err != nil {
 				glog.Fatalf("failed to write head block hash: %v", err)
 			}
 		}
 	}
+	if err := batch.Write(); err != nil {
+		glog.Fatalf("failed to roll back chain: %v", err)
+	}
 }
 
 // InsertReceiptChain attempts to complete an already existing header chain with
@@ -1328,7 +1491,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			}
 			// Store the addr-tx indexes if enabled
 			if bc.atxi != nil {
-				if err := WriteBlockAddTxIndexes(bc.atxi.Db, block); err != nil {
+				if err := bc.atxi.writeBlock(bc.chainDb, block); err != nil {
 					glog.Fatalf("failed to write block add-tx indexes", err)
 				}
 				// if buildATXI has been in use (via RPC) and is NOT finished, current < stop
@@ -1411,17 +1574,21 @@ func (bc *BlockChain) WriteBlockAddrTxIndexesBatch(indexDb ethdb.Database, start
 	block := bc.GetBlockByNumber(startBlockN)
 	batch := indexDb.NewBatch()
 
+	atxi := &AtxiT{Db: indexDb}
+	if bc.atxi != nil {
+		atxi.Internal, atxi.Tokens = bc.atxi.Internal, bc.atxi.Tokens
+	}
+
 	blockProcessedCount := uint64(0)
 	blockProcessedHead := func() uint64 {
 		return startBlockN + blockProcessedCount
 	}
 
 	for block != nil && blockProcessedHead() <= stopBlockN {
-		txP, err := putBlockAddrTxsToBatch(batch, block)
-		if err != nil {
+		if err := atxi.putBlock(batch, bc.chainDb, block); err != nil {
 			return txsCount, err
 		}
-		txsCount += txP
+		txsCount += len(block.Transactions())
 		blockProcessedCount++
 
 		// Write on stepN mod
@@ -1643,7 +1810,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (res *ChainInsertResult) {
 			return
 		}
 		// Process block using the parent state as reference point.
-		receipts, logs, usedGas, err := bc.processor.Process(block, bc.stateCache)
+		receipts, logs, usedGas, err := bc.processBlock(block, bc.stateCache)
 		if err != nil {
 			res.Error = err
 			return
@@ -1655,7 +1822,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (res *ChainInsertResult) {
 			return
 		}
 		// Write state changes to database
-		_, err = bc.stateCache.CommitTo(bc.chainDb, false)
+		err = bc.CommitState(block, bc.stateCache)
 		if err != nil {
 			res.Error = err
 			return
@@ -1701,7 +1868,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (res *ChainInsertResult) {
 			}
 			// Store the addr-tx indexes if enabled
 			if bc.atxi != nil {
-				if err := WriteBlockAddTxIndexes(bc.atxi.Db, block); err != nil {
+				if err := bc.atxi.writeBlock(bc.chainDb, block); err != nil {
 					res.Error = fmt.Errorf("failed to write block add-tx indexes: %v", err)
 					return
 				}
@@ -1854,41 +2021,31 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		).Send(mlogBlockchain)
 	}
 
-	// Remove all atxis from old chain; indexes should only reflect canonical
+	// Swap the atxis of the old chain for the ones of the new chain in a single
+	// batch; indexes should only reflect canonical.
 	// Doesn't matter whether automode or not, they should be removed.
+	var atxiBatch ethdb.Batch
 	if bc.atxi != nil {
+		atxiBatch = bc.atxi.Db.NewBatch()
 		for _, block := range oldChain {
-			for _, tx := range block.Transactions() {
-				if err := RmAddrTx(bc.atxi.Db, tx); err != nil {
-					return err
-				}
+			if err := bc.atxi.deleteBlock(atxiBatch, bc.chainDb, block); err != nil {
+				return err
 			}
 		}
 	}
 
-	var addedTxs types.Transactions
+	var (
+		addedTxs    types.Transactions
+		addedEvents []ChainEvent // new canonical blocks below the new head, descending
+	)
 	// insert blocks. Order does not matter. Last block will be written in ImportChain itbc which creates the new head properly
-	for _, block := range newChain {
+	for i, block := range newChain {
 		// insert the block in the canonical way, re-writing history
 		bc.insert(block)
 		// write canonical receipts and transactions
 		if err := WriteTransactions(bc.chainDb, block); err != nil {
 			return err
 		}
-		// Store the addr-tx indexes if enabled
-		if bc.atxi != nil {
-			if err := WriteBlockAddTxIndexes(bc.atxi.Db, block); err != nil {
-				return err
-			}
-			// if buildATXI has been in use (via RPC) and is NOT finished, current < stop
-			// if buildATXI has been in use (via RPC) and IS finished, current == stop
-			// else if builtATXI has not been in use (via RPC), then current == stop == 0
-			if bc.atxi.AutoMode && bc.atxi.Progress.Current == bc.atxi.Progress.Stop {
-				if err := bc.atxi.SetATXIBookmark(block.NumberU64()); err != nil {
-					return err
-				}
-			}
-		}
 		receipts := GetBlockReceipts(bc.chainDb, block.Hash())
 		// write receipts
 		if err := WriteReceipts(bc.chainDb, receipts); err != nil {
@@ -1898,25 +2055,71 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		if err := WriteMipmapBloom(bc.chainDb, block.NumberU64(), receipts); err != nil {
 			return err
 		}
+		// Store the addr-tx indexes if enabled
+		if atxiBatch != nil {
+			if err := bc.atxi.putBlock(atxiBatch, bc.chainDb, block); err != nil {
+				return err
+			}
+		}
 		addedTxs = append(addedTxs, block.Transactions()...)
+
+		// The new head itself is announced by the caller once inserted
+		if i > 0 {
+			var logs vm.Logs
+			for _, receipt := range receipts {
+				logs = append(logs, receipt.Logs...)
+			}
+			addedEvents = append(addedEvents, ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
+		}
+	}
+	if atxiBatch != nil {
+		if err := atxiBatch.Write(); err != nil {
+			return err
+		}
+		// if buildATXI has been in use (via RPC) and is NOT finished, current < stop
+		// if buildATXI has been in use (via RPC) and IS finished, current == stop
+		// else if builtATXI has not been in use (via RPC), then current == stop == 0
+		if bc.atxi.AutoMode && bc.atxi.Progress.Current == bc.atxi.Progress.Stop && len(newChain) > 0 {
+			if err := bc.atxi.SetATXIBookmark(newChain[0].NumberU64()); err != nil {
+				return err
+			}
+		}
 	}
 
 	// calculate the difference between deleted and added transactions
 	diff := types.TxDifference(deletedTxs, addedTxs)
 	// When transactions get deleted from the database that means the
 	// receipts that were created in the fork must also be deleted
+	batch := bc.chainDb.NewBatch()
 	for _, tx := range diff {
-		DeleteReceipt(bc.chainDb, tx.Hash())
-		DeleteTransaction(bc.chainDb, tx.Hash())
+		DeleteReceipt(batch, tx.Hash())
+		DeleteTransaction(batch, tx.Hash())
+	}
+	if err := batch.Write(); err != nil {
+		return err
 	}
 	// Must be posted in a goroutine because of the transaction pool trying
 	// to acquire the chain manager lock
 	if len(diff) > 0 {
 		go bc.eventMux.Post(RemovedTransactionEvent{diff})
 	}
-	if len(deletedLogs) > 0 {
-		go bc.eventMux.Post(RemovedLogsEvent{deletedLogs})
-	}
+	// Announce the removed logs before the blocks and logs of the new chain
+	// replacing them, the latter in ascending order.
+	go func() {
+		if len(deletedLogs) > 0 {
+			bc.eventMux.Post(RemovedLogsEvent{deletedLogs})
+		}
+		var addedLogs vm.Logs
+		for i := len(addedEvents) - 1; i >= 0; i-- {
+			addedLogs = append(addedLogs, addedEvents[i].Logs...)
+		}
+		if len(addedLogs) > 0 {
+			bc.eventMux.Post(addedLogs)
+		}
+		for i := len(addedEvents) - 1; i >= 0; i-- {
+			bc.eventMux.Post(addedEvents[i])
+		}
+	}()
 
 	if len(oldChain) > 0 {
 		go func() {
@@ -1981,7 +2184,8 @@ func (bc *BlockChain) update() {
 // The verify parameter can be used to fine tune whether nonce verification
 // should be done or not. The reason behind the optional check is because some
 // of the header retrieval mechanisms already need to verify nonces, as well as
-// because nonces can be verified sparsely, not needing to check each.
+// because nonces can be verified sparsely, not needing to check each. A checkFreq
+// of 0 skips nonce verification altogether.
 func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) *HeaderChainInsertResult {
 	// Make sure only one thread manipulates the chain at once
 	bc.chainmu.Lock()
@@ -501,6 +501,7 @@ func chm(t testing.TB, genesis *types.Block, db ethdb.Database) *BlockChain {
 		eventMux:     &eventMux,
 		pow:          FakePow{},
 		config:       config,
+		stateDb:      state.NewDatabase(db),
 	}
 	valFn := func() HeaderValidator { return bc.Validator() }
 	var err error
@@ -1574,6 +1575,92 @@ done:
 
 }
 
+// Tests that a reorganisation announces the blocks and logs of the new canonical
+// chain, not only its head.
+func TestReorgChainEvent(t *testing.T) {
+	// See TestReorgSideEvent.
+	if UseSputnikVM == "true" {
+		return
+	}
+
+	key1, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr1 := crypto.PubkeyToAddress(key1.PublicKey)
+	// this code generates a log
+	code := common.Hex2Bytes("60606040525b7f24ec1d3ff24c2f6ff210738839dbc339cd45a5294d85c79361016243157aae7b60405180905060405180910390a15b600a8060416000396000f360606040526008565b00")
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{addr1, big.NewInt(10000000000000)})
+	signer := types.NewChainIdSigner(big.NewInt(63))
+	chainConfig := MakeDiehardChainConfig()
+
+	evmux := &event.TypeMux{}
+	blockchain, err := NewBlockChain(db, chainConfig, FakePow{}, evmux)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, _ := GenerateChain(blockchain.config, genesis, db, 3, func(i int, gen *BlockGen) {})
+	if res := blockchain.InsertChain(chain); res.Error != nil {
+		t.Fatalf("failed to insert chain: %v", res.Error)
+	}
+
+	// The first two replacement blocks are side chain blocks until the third
+	// one makes the replacement chain the heavier one.
+	replacementBlocks, _ := GenerateChain(blockchain.config, genesis, db, 4, func(i int, gen *BlockGen) {
+		if i == 0 {
+			tx, err := types.NewContractCreation(gen.TxNonce(addr1), new(big.Int), big.NewInt(1000000), new(big.Int), code).WithSigner(signer).SignECDSA(key1)
+			if err != nil {
+				t.Fatalf("failed to create tx: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+		if i == 2 {
+			gen.OffsetTime(-1)
+		}
+	})
+
+	subs := evmux.Subscribe(ChainEvent{}, vm.Logs(nil))
+	if res := blockchain.InsertChain(replacementBlocks); res.Error != nil {
+		t.Fatalf("failed to insert chain: %v", res.Error)
+	}
+
+	expectedHashes := make(map[common.Hash]bool)
+	for _, block := range replacementBlocks {
+		expectedHashes[block.Hash()] = true
+	}
+	var sawLog bool
+
+	const timeoutDura = 10 * time.Second
+	timeout := time.NewTimer(timeoutDura)
+	for len(expectedHashes) > 0 || !sawLog {
+		select {
+		case ev := <-subs.Chan():
+			switch ev := ev.Data.(type) {
+			case ChainEvent:
+				if !expectedHashes[ev.Hash] {
+					t.Errorf("unexpected chain event for %x", ev.Hash)
+				}
+				delete(expectedHashes, ev.Hash)
+			case vm.Logs:
+				for _, log := range ev {
+					if log.BlockHash == replacementBlocks[0].Hash() {
+						sawLog = true
+					}
+				}
+			}
+			timeout.Reset(timeoutDura)
+
+		case <-timeout.C:
+			t.Fatalf("Timeout. Missing chain events for %d blocks, log announced: %v", len(expectedHashes), sawLog)
+		}
+	}
+}
+
 // Tests if the canonical block can be fetched from the database during chain insertion.
 func TestCanonicalBlockRetrieval(t *testing.T) {
 	t.Skip("Skipped: needs updating")
@@ -1838,3 +1925,165 @@ func TestBlockChain_BlockIsGenesis(t *testing.T) {
 		t.Errorf("expected: is not genesis block")
 	}
 }
+
+// Tests that with state garbage collection enabled only the most recent
+// states are retained, none of them touch the disk until the chain is
+// stopped, and a crashed node rewinds to the last persisted state.
+func TestTrieGC(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db)
+	WriteGenesisBlockForTesting(gendb)
+
+	// Pay the block reward to a different coinbase in every block, so that
+	// each block has a distinct state root
+	chain, _ := GenerateChain(MakeDiehardChainConfig(), genesis, gendb, 2*triesInMemory, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.BigToAddress(big.NewInt(int64(i + 1))))
+	})
+
+	blockchain, err := NewBlockChain(db, MakeDiehardChainConfig(), FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.SetGCMode(GCModeFull); err != nil {
+		t.Fatal(err)
+	}
+	if res := blockchain.InsertChain(chain); res.Error != nil {
+		t.Fatalf("failed to insert chain: %v", res.Error)
+	}
+	for i, block := range chain {
+		if ok, _ := db.Has(block.Root().Bytes()); ok {
+			t.Errorf("block #%d: state written to disk", block.NumberU64())
+		}
+		if want := i >= len(chain)-triesInMemory; blockchain.hasState(block.Root()) != want {
+			t.Errorf("block #%d: state availability mismatch: have %v, want %v", block.NumberU64(), !want, want)
+		}
+	}
+	// Simulate a crash, the head must be rewound to the last state on disk
+	crashed, err := NewBlockChain(db, MakeDiehardChainConfig(), FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head := crashed.CurrentBlock(); head.Hash() != genesis.Hash() {
+		t.Errorf("crashed head mismatch: have #%d, want genesis", head.NumberU64())
+	}
+	crashed.Stop()
+
+	// A clean shutdown persists the head state
+	WriteHeadBlockHash(db, chain[len(chain)-1].Hash())
+	blockchain.Stop()
+
+	restarted, err := NewBlockChain(db, MakeDiehardChainConfig(), FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head := restarted.CurrentBlock(); head.Hash() != chain[len(chain)-1].Hash() {
+		t.Errorf("restarted head mismatch: have #%d, want #%d", head.NumberU64(), chain[len(chain)-1].NumberU64())
+	}
+	restarted.Stop()
+}
+
+// Tests that canonical blocks moved into the freezer are removed from the
+// key-value database but still retrievable, and that rewinding the chain
+// drops them from the freezer too.
+func TestFreezeAncients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	var (
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		signer  = types.NewChainIdSigner(big.NewInt(63))
+		config  = MakeDiehardChainConfig()
+	)
+	db, _ := ethdb.NewMemDatabase()
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := WriteGenesisBlockForTesting(db, GenesisAccount{address, funds})
+	WriteGenesisBlockForTesting(gendb, GenesisAccount{address, funds})
+
+	blocks, _ := GenerateChain(config, genesis, gendb, 32, func(i int, block *BlockGen) {
+		tx, err := types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), TxGas, nil, nil).WithSigner(signer).SignECDSA(key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	fdb, err := ethdb.NewDatabaseWithFreezer(db, dir, FreezerTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockchain, err := NewBlockChain(fdb, config, FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Stop()
+	blockchain.SetAncientThreshold(0)
+
+	if res := blockchain.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert chain: %v", res.Error)
+	}
+	tds := make(map[common.Hash]*big.Int)
+	for _, block := range blocks {
+		tds[block.Hash()] = GetTd(fdb, block.Hash())
+	}
+	// Freeze the genesis and the first 16 blocks
+	if moved, err := FreezeAncients(fdb, 17, 1024); err != nil || moved != 17 {
+		t.Fatalf("freeze mismatch: have %d, %v, want 17", moved, err)
+	}
+	if frozen, _ := fdb.Ancients(); frozen != 17 {
+		t.Fatalf("ancients mismatch: have %d, want 17", frozen)
+	}
+	// Reopen the freezer to make sure everything was flushed
+	if err := fdb.Freezer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fdb.Freezer, err = ethdb.NewFreezer(dir, FreezerTables); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range blocks {
+		hash, number := block.Hash(), block.NumberU64()
+		if _, err := db.Get(append(append(blockPrefix, hash[:]...), headerSuffix...)); (err == nil) == (number < 17) {
+			t.Errorf("block #%d: header presence in database mismatch", number)
+		}
+		if have := GetCanonicalHash(fdb, number); have != hash {
+			t.Errorf("block #%d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if header := GetHeader(fdb, hash); header == nil || header.Hash() != hash {
+			t.Errorf("block #%d: header mismatch: have %v", number, header)
+		}
+		if body := GetBody(fdb, hash); body == nil || len(body.Transactions) != 1 {
+			t.Errorf("block #%d: body mismatch: have %v", number, body)
+		}
+		if receipts := GetBlockReceipts(fdb, hash); len(receipts) != 1 {
+			t.Errorf("block #%d: receipt count mismatch: have %d, want 1", number, len(receipts))
+		}
+		if td := GetTd(fdb, hash); td == nil || td.Cmp(tds[hash]) != 0 {
+			t.Errorf("block #%d: td mismatch: have %v, want %v", number, td, tds[hash])
+		}
+	}
+	if hash := GetCanonicalHash(fdb, 0); hash != genesis.Hash() {
+		t.Errorf("genesis hash mismatch: have %x, want %x", hash, genesis.Hash())
+	}
+	// Rewinding into the frozen blocks must drop them from the freezer
+	if err := blockchain.SetHead(10); err != nil {
+		t.Fatal(err)
+	}
+	if frozen, _ := fdb.Ancients(); frozen != 11 {
+		t.Fatalf("ancients mismatch after rewind: have %d, want 11", frozen)
+	}
+	for _, block := range blocks[10:17] {
+		if header := GetHeader(fdb, block.Hash()); header != nil {
+			t.Errorf("block #%d: header retrievable after rewind", block.NumberU64())
+		}
+		if hash := GetCanonicalHash(fdb, block.NumberU64()); hash != (common.Hash{}) {
+			t.Errorf("block #%d: canonical hash retrievable after rewind", block.NumberU64())
+		}
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != blocks[9].Hash() {
+		t.Errorf("head mismatch: have #%d, want #10", head.NumberU64())
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+/*
+Package bloombits implements bloom filtering on batches of data.
+
+The header blooms of a section of consecutive blocks are transposed into one
+bitset per bloom bit ("bloom bits"), bit n of which tells whether bloom n of the
+section has the bit set. Matching a section against an address or topic then
+only needs the three bitsets selected by its bloom indexes, ANDed together,
+instead of every header of the section.
+
+The Generator transposes the blooms of a section, the Matcher runs address and
+topic filters over the stored bitsets, retrieving them through a scheduler
+which deduplicates the requests of concurrently running filters.
+*/
+package bloombits
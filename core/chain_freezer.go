@@ -0,0 +1,171 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+)
+
+const (
+	// DefaultAncientThreshold is the number of recent blocks kept in the
+	// key-value database, the canonical blocks below are moved to the freezer.
+	DefaultAncientThreshold = 90000
+
+	// freezerBatchLimit is the maximum number of blocks moved to the freezer
+	// in one go.
+	freezerBatchLimit = 2048
+
+	// freezerRecheckInterval is the time between checks for new blocks to
+	// move to the freezer once it caught up with the chain.
+	freezerRecheckInterval = time.Minute
+)
+
+// errNoAncientStore is returned when freezing blocks of a database without an
+// attached freezer.
+var errNoAncientStore = errors.New("database has no ancient store")
+
+// SetAncientThreshold sets the number of recent blocks kept in the key-value
+// database. Canonical blocks older than that are moved to the freezer if the
+// chain database has one. A threshold of 0 disables the migration.
+//
+// The migration only starts with the first non-zero threshold, so that no
+// blocks are moved before the configured value is known.
+func (bc *BlockChain) SetAncientThreshold(threshold uint64) {
+	atomic.StoreUint64(&bc.ancientThreshold, threshold)
+	if threshold == 0 || atomic.LoadInt32(&bc.running) != 0 {
+		return
+	}
+	if _, ok := bc.chainDb.(ethdb.AncientStore); ok {
+		bc.freezeOnce.Do(func() {
+			bc.wg.Add(1)
+			go bc.freeze()
+		})
+	}
+}
+
+// freeze periodically moves the canonical blocks older than the ancient
+// threshold from the key-value database into its freezer.
+func (bc *BlockChain) freeze() {
+	defer bc.wg.Done()
+
+	for {
+		var moved int
+		if threshold := atomic.LoadUint64(&bc.ancientThreshold); threshold > 0 {
+			if head := bc.CurrentBlock().NumberU64(); head > threshold {
+				var err error
+
+				bc.freezemu.Lock()
+				moved, err = FreezeAncients(bc.chainDb, head-threshold, freezerBatchLimit)
+				bc.freezemu.Unlock()
+
+				if err != nil {
+					glog.V(logger.Error).Errorf("Failed to move blocks to the freezer: %v", err)
+				} else if moved > 0 {
+					glog.V(logger.Info).Infof("Moved %d blocks to the freezer, below #%d", moved, head-threshold)
+				}
+			}
+		}
+		// Keep going while there's a backlog, otherwise wait for the chain to grow
+		delay := freezerRecheckInterval
+		if moved == freezerBatchLimit {
+			delay = 0
+		}
+		select {
+		case <-bc.quit:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// FreezeAncients moves at most max canonical blocks below the limit number
+// from the key-value database into its freezer, continuing after the last
+// frozen one. Migration stops early at the first block with incomplete data,
+// e.g. a header without a body. It returns the number of blocks moved.
+func FreezeAncients(db ethdb.Database, limit uint64, max int) (int, error) {
+	store, ok := db.(ethdb.AncientStore)
+	if !ok {
+		return 0, errNoAncientStore
+	}
+	frozen, err := store.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	var hashes []common.Hash
+	for number := frozen; number < limit && len(hashes) < max; number++ {
+		hash := GetCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		header := GetHeaderRLP(db, hash)
+		body := GetBodyRLP(db, hash)
+		td, _ := db.Get(append(append(blockPrefix, hash[:]...), tdSuffix...))
+		if len(header) == 0 || len(body) == 0 || len(td) == 0 {
+			break
+		}
+		// Blocks without transactions don't store any receipts
+		receipts, _ := db.Get(append(blockReceiptsPrefix, hash[:]...))
+		if receipts == nil {
+			receipts = []byte{}
+		}
+		err := store.AppendAncient(number, map[string][]byte{
+			freezerHashTable:       hash[:],
+			freezerHeaderTable:     header,
+			freezerBodiesTable:     body,
+			freezerReceiptTable:    receipts,
+			freezerDifficultyTable: td,
+		})
+		if err != nil {
+			return len(hashes), err
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+	// Make sure the frozen data is on disk before dropping it from the database
+	if err := store.Sync(); err != nil {
+		return 0, err
+	}
+	// Index the frozen blocks and drop them from the database atomically
+	batch := db.NewBatch()
+	for i, hash := range hashes {
+		number := make([]byte, 8)
+		binary.BigEndian.PutUint64(number, frozen+uint64(i))
+		if err := batch.Put(append(ancientIndexPrefix, hash[:]...), number); err != nil {
+			return 0, err
+		}
+		DeleteCanonicalHash(batch, frozen+uint64(i))
+		DeleteHeader(batch, hash)
+		DeleteBody(batch, hash)
+		DeleteTd(batch, hash)
+		DeleteBlockReceipts(batch, hash)
+	}
+	if err := batch.Write(); err != nil {
+		return 0, fmt.Errorf("failed to index frozen blocks: %v", err)
+	}
+	return len(hashes), nil
+}
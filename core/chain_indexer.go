@@ -0,0 +1,398 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+)
+
+// ChainIndexerBackend defines the methods needed to process chain segments in
+// the background and write the segment results into the database. These can be
+// used to create filter blooms or CHTs.
+type ChainIndexerBackend interface {
+	// Reset initiates the processing of a new chain segment, potentially terminating
+	// any partially completed operations (in case of a reorg).
+	Reset(section uint64, prevHead common.Hash) error
+
+	// Process crunches through the next header in the chain segment. The caller
+	// will ensure a sequential order of headers.
+	Process(header *types.Header)
+
+	// Commit finalizes the section metadata and stores it into the database.
+	Commit() error
+}
+
+// ChainIndexer does a post-processing job for equally sized sections of the
+// canonical chain (like BloomBits and CHT structures). A ChainIndexer is
+// connected to the blockchain through the event system by subscribing to head
+// events.
+//
+// Sections are only processed once they are confirmsReq blocks deep, so most
+// reorgs never touch the index. Deeper reorgs roll the stored sections back to
+// the common ancestor, which get processed again from the new canonical chain.
+// Starting the indexer catches up with all the historical sections, so a node
+// synced before the index existed (or synced in fast mode) gets backfilled.
+type ChainIndexer struct {
+	chainDb  ethdb.Database            // Chain database to index the data from
+	indexDb  ethdb.Database            // Prefixed table-view of the db to write index metadata into
+	backend  ChainIndexerBackend       // Background processor generating the index data content
+	kind     string                    // Human readable indexer type for logging
+	sub      event.TypeMuxSubscription // Subscription to the chain head events
+	active   uint32                    // Flag whether the event loop was started
+	update   chan struct{}             // Notification channel that headers should be processed
+	quit     chan chan error           // Quit channel to tear down running goroutines
+	quitLoop chan struct{}             // Quit channel of the event loop
+
+	sectionSize uint64 // Number of blocks in a single chain segment to process
+	confirmsReq uint64 // Number of confirmations before processing a completed segment
+
+	storedSections uint64 // Number of sections successfully indexed into the database
+	knownSections  uint64 // Number of sections known to be complete (block wise)
+
+	throttling time.Duration // Disk throttling to prevent a heavy upgrade from hogging resources
+
+	lock sync.RWMutex
+}
+
+// NewChainIndexer creates a new chain indexer to do background processing on
+// chain segments of a given size after certain number of confirmations passed.
+// The throttling parameter might be used to prevent database thrashing.
+func NewChainIndexer(chainDb, indexDb ethdb.Database, backend ChainIndexerBackend, section, confirm uint64, throttling time.Duration, kind string) *ChainIndexer {
+	c := &ChainIndexer{
+		chainDb:     chainDb,
+		indexDb:     indexDb,
+		backend:     backend,
+		update:      make(chan struct{}, 1),
+		quit:        make(chan chan error),
+		quitLoop:    make(chan struct{}),
+		sectionSize: section,
+		confirmsReq: confirm,
+		throttling:  throttling,
+		kind:        kind,
+	}
+	// Initialize database dependent fields and start the updater
+	c.loadValidSections()
+	go c.updateLoop()
+
+	return c
+}
+
+// Start creates a goroutine to feed chain head events into the indexer for
+// cascading background processing, catching up from the given current head.
+func (c *ChainIndexer) Start(currentHeader *types.Header, mux *event.TypeMux) {
+	c.sub = mux.Subscribe(ChainHeadEvent{})
+	atomic.StoreUint32(&c.active, 1)
+	go c.eventLoop(currentHeader)
+}
+
+// Close tears down all goroutines belonging to the indexer and returns any error
+// that might have occurred internally.
+func (c *ChainIndexer) Close() error {
+	var errs []error
+
+	// Tear down the primary update loop
+	errc := make(chan error)
+	c.quit <- errc
+	if err := <-errc; err != nil {
+		errs = append(errs, err)
+	}
+	// If needed, tear down the secondary event loop
+	if atomic.LoadUint32(&c.active) != 0 {
+		c.sub.Unsubscribe()
+		close(c.quitLoop)
+	}
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(errs) == 1:
+		return errs[0]
+	default:
+		return fmt.Errorf("%v", errs)
+	}
+}
+
+// eventLoop is a secondary - optional - event loop of the indexer which is only
+// started for the outermost indexer to push chain head events into a processing
+// queue.
+func (c *ChainIndexer) eventLoop(currentHeader *types.Header) {
+	// Fire the initial new head event to start any outstanding processing
+	c.newHead(currentHeader.Number.Uint64(), false)
+
+	prevHeader := currentHeader
+	prevHash := currentHeader.Hash()
+
+	for {
+		select {
+		case <-c.quitLoop:
+			return
+
+		case ev, ok := <-c.sub.Chan():
+			// Received a new event, ensure it's not nil (closing) and update
+			if !ok {
+				return
+			}
+			head, ok := ev.Data.(ChainHeadEvent)
+			if !ok || head.Block == nil {
+				continue
+			}
+			header := head.Block.Header()
+			if header.ParentHash != prevHash {
+				// Reorg to the common ancestor if needed (might not exist in light sync mode, skip reorg then)
+				if ancestor := findCommonAncestor(c.chainDb, prevHeader, header); ancestor != nil {
+					c.newHead(ancestor.Number.Uint64(), true)
+				}
+			}
+			c.newHead(header.Number.Uint64(), false)
+
+			prevHeader, prevHash = header, header.Hash()
+		}
+	}
+}
+
+// findCommonAncestor returns the last header shared by the chains of the two
+// given headers, or nil if it can't be found in the database.
+func findCommonAncestor(db ethdb.Database, a, b *types.Header) *types.Header {
+	for a.Number.Cmp(b.Number) > 0 {
+		if a = GetHeader(db, a.ParentHash); a == nil {
+			return nil
+		}
+	}
+	for b.Number.Cmp(a.Number) > 0 {
+		if b = GetHeader(db, b.ParentHash); b == nil {
+			return nil
+		}
+	}
+	for a.Hash() != b.Hash() {
+		if a = GetHeader(db, a.ParentHash); a == nil {
+			return nil
+		}
+		if b = GetHeader(db, b.ParentHash); b == nil {
+			return nil
+		}
+	}
+	return a
+}
+
+// newHead notifies the indexer about new chain heads and/or reorgs.
+func (c *ChainIndexer) newHead(head uint64, reorg bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// If a reorg happened, invalidate all sections until that point
+	if reorg {
+		// Revert the known section number to the reorg point
+		known := head / c.sectionSize
+		if known < c.knownSections {
+			c.knownSections = known
+		}
+		// Revert the stored sections from the database to the reorg point
+		if stored := known; stored < c.storedSections {
+			c.setValidSections(stored)
+		}
+		return
+	}
+	// No reorg, calculate the number of newly known sections and update if high enough
+	var sections uint64
+	if head >= c.confirmsReq {
+		sections = (head + 1 - c.confirmsReq) / c.sectionSize
+		if sections > c.knownSections {
+			c.knownSections = sections
+
+			select {
+			case c.update <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// updateLoop is the main event loop of the indexer which pushes chain segments
+// down into the processing backend.
+func (c *ChainIndexer) updateLoop() {
+	var updating bool
+
+	for {
+		select {
+		case errc := <-c.quit:
+			// Chain indexer terminating, report no failure and abort
+			errc <- nil
+			return
+
+		case <-c.update:
+			// Section headers completed (or rolled back), update the index
+			c.lock.Lock()
+			if c.knownSections > c.storedSections {
+				// Periodically print an upgrade log message to the user
+				if !updating && c.knownSections > c.storedSections+1 {
+					updating = true
+					glog.V(logger.Info).Infof("Upgrading chain index: type=%s, %d of %d sections", c.kind, c.storedSections, c.knownSections)
+				}
+				// Cache the current section count and head to allow unlocking the mutex
+				section := c.storedSections
+				var oldHead common.Hash
+				if section > 0 {
+					oldHead = c.SectionHead(section - 1)
+				}
+				// Process the newly defined section in the background
+				c.lock.Unlock()
+				newHead, err := c.processSection(section, oldHead)
+				if err != nil {
+					glog.V(logger.Error).Errorf("Chain index %s: section %d processing failed: %v", c.kind, section, err)
+				}
+				c.lock.Lock()
+
+				// If processing succeeded and no reorgs occurred, mark the section completed
+				if err == nil && oldHead == c.SectionHead(section-1) {
+					c.setSectionHead(section, newHead)
+					c.setValidSections(section + 1)
+					if c.storedSections == c.knownSections && updating {
+						updating = false
+						glog.V(logger.Info).Infof("Finished upgrading chain index: type=%s", c.kind)
+					}
+				} else {
+					// If processing failed, don't retry until further notification
+					glog.V(logger.Debug).Infof("Chain index %s: section %d processing aborted", c.kind, section)
+					c.storedSections = section
+					if err != nil {
+						c.knownSections = c.storedSections
+					}
+				}
+			}
+			// If there are still further sections to process, reschedule
+			if c.knownSections > c.storedSections {
+				time.AfterFunc(c.throttling, func() {
+					select {
+					case c.update <- struct{}{}:
+					default:
+					}
+				})
+			}
+			c.lock.Unlock()
+		}
+	}
+}
+
+// processSection processes an entire section by calling backend functions while
+// ensuring the continuity of the passed headers. Since the chain mutex is not
+// held while processing, the continuity can be broken by a long reorg, in which
+// case the function returns with an error.
+func (c *ChainIndexer) processSection(section uint64, lastHead common.Hash) (common.Hash, error) {
+	glog.V(logger.Detail).Infof("Chain index %s: processing section %d", c.kind, section)
+
+	// Reset and partial processing
+	if err := c.backend.Reset(section, lastHead); err != nil {
+		c.setValidSections(0)
+		return common.Hash{}, err
+	}
+
+	for number := section * c.sectionSize; number < (section+1)*c.sectionSize; number++ {
+		hash := GetCanonicalHash(c.chainDb, number)
+		if hash == (common.Hash{}) {
+			return common.Hash{}, fmt.Errorf("canonical block #%d unknown", number)
+		}
+		header := GetHeader(c.chainDb, hash)
+		if header == nil {
+			return common.Hash{}, fmt.Errorf("block #%d [%x…] not found", number, hash[:4])
+		} else if header.ParentHash != lastHead {
+			return common.Hash{}, errors.New("chain reorged during section processing")
+		}
+		c.backend.Process(header)
+		lastHead = header.Hash()
+	}
+	if err := c.backend.Commit(); err != nil {
+		glog.V(logger.Error).Errorf("Chain index %s: section %d commit failed: %v", c.kind, section, err)
+		return common.Hash{}, err
+	}
+	return lastHead, nil
+}
+
+// Sections returns the number of processed sections maintained by the indexer
+// and also the information about the last header indexed for potential canonical
+// verifications.
+func (c *ChainIndexer) Sections() (uint64, uint64, common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.storedSections, c.storedSections*c.sectionSize - 1, c.SectionHead(c.storedSections - 1)
+}
+
+// loadValidSections reads the number of valid sections from the index database
+// and caches is into the local state.
+func (c *ChainIndexer) loadValidSections() {
+	data, _ := c.indexDb.Get([]byte("count"))
+	if len(data) == 8 {
+		c.storedSections = binary.BigEndian.Uint64(data[:])
+	}
+}
+
+// setValidSections writes the number of valid sections to the index database
+func (c *ChainIndexer) setValidSections(sections uint64) {
+	// Set the current number of valid sections in the database
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], sections)
+	c.indexDb.Put([]byte("count"), data[:])
+
+	// Remove any reorged sections, caching the valids in the mean time
+	for c.storedSections > sections {
+		c.storedSections--
+		c.removeSectionHead(c.storedSections)
+	}
+	c.storedSections = sections // needed if new > old
+}
+
+// SectionHead retrieves the last block hash of a processed section from the
+// index database.
+func (c *ChainIndexer) SectionHead(section uint64) common.Hash {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], section)
+
+	hash, _ := c.indexDb.Get(append([]byte("shead"), data[:]...))
+	if len(hash) == len(common.Hash{}) {
+		return common.BytesToHash(hash)
+	}
+	return common.Hash{}
+}
+
+// setSectionHead writes the last block hash of a processed section to the index
+// database.
+func (c *ChainIndexer) setSectionHead(section uint64, hash common.Hash) {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], section)
+
+	c.indexDb.Put(append([]byte("shead"), data[:]...), hash.Bytes())
+}
+
+// removeSectionHead removes the reference to a processed section from the index
+// database.
+func (c *ChainIndexer) removeSectionHead(section uint64) {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], section)
+
+	c.indexDb.Delete(append([]byte("shead"), data[:]...))
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+)
+
+// testIndexerBackend records the headers of the sections it processed.
+type testIndexerBackend struct {
+	lock      sync.Mutex
+	section   uint64
+	headers   []common.Hash
+	processed map[uint64][]common.Hash
+}
+
+func (b *testIndexerBackend) Reset(section uint64, prevHead common.Hash) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.section, b.headers = section, nil
+	return nil
+}
+
+func (b *testIndexerBackend) Process(header *types.Header) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.headers = append(b.headers, header.Hash())
+}
+
+func (b *testIndexerBackend) Commit() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.processed[b.section] = b.headers
+	return nil
+}
+
+// writeTestHeaders writes a canonical chain of headers on top of the parent,
+// returning the new headers. The seed differentiates competing forks.
+func writeTestHeaders(t *testing.T, db ethdb.Database, parent *types.Header, n int, seed byte) []*types.Header {
+	headers := make([]*types.Header, n)
+	for i := range headers {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+			Extra:      []byte{seed},
+		}
+		if err := WriteHeader(db, header); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if err := WriteCanonicalHash(db, header.Hash(), header.Number.Uint64()); err != nil {
+			t.Fatalf("failed to write canonical hash: %v", err)
+		}
+		headers[i], parent = header, header
+	}
+	return headers
+}
+
+// waitSections waits until the indexer stored the expected number of sections.
+func waitSections(t *testing.T, indexer *ChainIndexer, want uint64) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if have, _, _ := indexer.Sections(); have == want {
+			return
+		}
+	}
+	have, _, _ := indexer.Sections()
+	t.Fatalf("stored section count mismatch: have %d, want %d", have, want)
+}
+
+// Tests that the chain indexer backfills the sections of the existing chain,
+// follows new heads and reprocesses the sections rolled back by a reorg.
+func TestChainIndexer(t *testing.T) {
+	const (
+		sectionSize = 16
+		confirms    = 4
+	)
+	db, _ := ethdb.NewMemDatabase()
+	genesis := &types.Header{Number: big.NewInt(0)}
+	WriteHeader(db, genesis)
+	WriteCanonicalHash(db, genesis.Hash(), 0)
+
+	backend := &testIndexerBackend{processed: make(map[uint64][]common.Hash)}
+	indexer := NewChainIndexer(db, ethdb.NewTable(db, "i-"), backend, sectionSize, confirms, 0, "test")
+	defer indexer.Close()
+
+	// Index the sections of the existing chain
+	chain := writeTestHeaders(t, db, genesis, 3*sectionSize+confirms-1, 0)
+	mux := new(event.TypeMux)
+	indexer.Start(chain[len(chain)-1], mux)
+	waitSections(t, indexer, 3)
+
+	// Follow the chain head, indexing a section once it's confirmed
+	chain = append(chain, writeTestHeaders(t, db, chain[len(chain)-1], 1, 0)...)
+	mux.Post(ChainHeadEvent{types.NewBlockWithHeader(chain[len(chain)-1])})
+	waitSections(t, indexer, 3)
+
+	chain = append(chain, writeTestHeaders(t, db, chain[len(chain)-1], sectionSize, 0)...)
+	mux.Post(ChainHeadEvent{types.NewBlockWithHeader(chain[len(chain)-1])})
+	waitSections(t, indexer, 4)
+
+	// Reorg the chain back into the second section and check that the rest
+	// gets reprocessed from the new canonical chain
+	fork := writeTestHeaders(t, db, chain[sectionSize+4], 3*sectionSize, 1)
+	mux.Post(ChainHeadEvent{types.NewBlockWithHeader(fork[len(fork)-1])})
+
+	reindexed := func() bool {
+		backend.lock.Lock()
+		defer backend.lock.Unlock()
+
+		for section := uint64(1); section < 4; section++ {
+			headers := backend.processed[section]
+			if len(headers) != sectionSize || headers[sectionSize-1] != GetCanonicalHash(db, (section+1)*sectionSize-1) {
+				return false
+			}
+		}
+		return true
+	}
+	for deadline := time.Now().Add(5 * time.Second); !reindexed(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("reorged sections not reprocessed")
+		}
+	}
+	waitSections(t, indexer, 4)
+}
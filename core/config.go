@@ -93,17 +93,29 @@ func GetCacheChainConfig() *SufficientChainConfig {
 
 // SufficientChainConfig holds necessary data for externalizing a given blockchain configuration.
 type SufficientChainConfig struct {
-	ID              string           `json:"id,omitempty"` // deprecated in favor of 'Identity', method decoding should id -> identity
-	Identity        string           `json:"identity"`
-	Name            string           `json:"name,omitempty"`
-	State           *StateConfig     `json:"state"`     // don't omitempty for clarity of potential custom options
-	Network         int              `json:"network"`   // eth.NetworkId (mainnet=1, morden=2)
-	Consensus       string           `json:"consensus"` // pow type (ethash OR ethash-test)
-	Genesis         *GenesisDump     `json:"genesis"`
-	ChainConfig     *ChainConfig     `json:"chainConfig"`
-	Bootstrap       []string         `json:"bootstrap"`
-	ParsedBootstrap []*discover.Node `json:"-"`
-	Include         []string         `json:"include"` // config files to include
+	ID              string             `json:"id,omitempty"` // deprecated in favor of 'Identity', method decoding should id -> identity
+	Identity        string             `json:"identity"`
+	Name            string             `json:"name,omitempty"`
+	State           *StateConfig       `json:"state"`     // don't omitempty for clarity of potential custom options
+	Network         int                `json:"network"`   // eth.NetworkId (mainnet=1, morden=2)
+	Consensus       string             `json:"consensus"` // pow type (ethash OR ethash-test)
+	Genesis         *GenesisDump       `json:"genesis"`
+	ChainConfig     *ChainConfig       `json:"chainConfig"`
+	Bootstrap       []string           `json:"bootstrap"`
+	ParsedBootstrap []*discover.Node   `json:"-"`
+	Include         []string           `json:"include"`              // config files to include
+	Checkpoint      *TrustedCheckpoint `json:"checkpoint,omitempty"` // trusted CHT section to start header sync from
+}
+
+// TrustedCheckpoint is a canonical hash trie (CHT) section trusted by
+// configuration. Light clients start their header sync from the head of the
+// section, proving it against the CHT root instead of downloading all the
+// headers from the genesis block. Fast sync links the headers below the
+// section head to it by hash instead of verifying their proof-of-work.
+type TrustedCheckpoint struct {
+	SectionIndex uint64      `json:"sectionIndex"`
+	ChtRoot      common.Hash `json:"chtRoot"`
+	SectionHead  common.Hash `json:"sectionHead"`
 }
 
 // StateConfig hold variable data for statedb.
@@ -239,6 +251,26 @@ func (c *SufficientChainConfig) IsValid() (string, bool) {
 		return "forks", false
 	}
 
+	for _, f := range c.ChainConfig.Forks {
+		for _, ff := range f.Features {
+			if ff.ID != "precompiles" {
+				continue
+			}
+			for name := range ff.Options {
+				if _, _, err := vm.PrecompiledContract(name); err != nil {
+					return "forks.features.precompiles: " + err.Error(), false
+				}
+				if _, ok := ff.GetBigInt(name); !ok {
+					return "forks.features.precompiles." + name, false
+				}
+			}
+		}
+	}
+
+	if cp := c.Checkpoint; cp != nil && (cp.ChtRoot == (common.Hash{}) || cp.SectionHead == (common.Hash{})) {
+		return "checkpoint", false
+	}
+
 	return "", true
 }
 
@@ -334,6 +366,55 @@ func (c *ChainConfig) IsExplosion(num *big.Int) bool {
 	return false
 }
 
+// IsAtlantis returns whether num is at or after a fork configuring the "atlantis"
+// feature, which enables the Byzantium opcodes (REVERT, RETURNDATASIZE,
+// RETURNDATACOPY, STATICCALL) and EIP-658 status receipts.
+func (c *ChainConfig) IsAtlantis(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "atlantis")
+	return configured
+}
+
+// Precompiled returns the precompiled contracts active at num, keyed by
+// address. Besides the default contracts, every fork at or before num may
+// enable additional contracts with the "precompiles" feature, whose options
+// map a contract name to the address number it is deployed at, e.g.
+// {"id": "precompiles", "options": {"modexp": 5, "bn256Add": 6}}.
+func (c *ChainConfig) Precompiled(num *big.Int) map[string]*vm.PrecompiledAccount {
+	contracts := vm.PrecompiledContracts()
+	if num == nil {
+		return contracts
+	}
+	for _, f := range c.Forks {
+		if f.Block == nil || num.Cmp(f.Block) < 0 {
+			continue
+		}
+		for _, ff := range f.Features {
+			if ff.ID != "precompiles" {
+				continue
+			}
+			for name := range ff.Options {
+				_, p, err := vm.PrecompiledContract(name)
+				if err != nil {
+					panic(fmt.Errorf("%v at block: %v", err, num))
+				}
+				addr, ok := ff.GetBigInt(name)
+				if !ok {
+					panic(fmt.Errorf("Invalid address for precompiled contract '%v' at block: %v", name, num))
+				}
+				contracts[string(common.BigToAddress(addr).Bytes())] = p
+			}
+		}
+	}
+	return contracts
+}
+
+// HasPrecompiles returns whether num is at or after a fork enabling additional
+// precompiled contracts.
+func (c *ChainConfig) HasPrecompiles(num *big.Int) bool {
+	_, _, configured := c.GetFeature(num, "precompiles")
+	return configured
+}
+
 // ForkByName looks up a Fork by its name, assumed to be unique
 func (c *ChainConfig) ForkByName(name string) *Fork {
 	for i := range c.Forks {
@@ -344,7 +425,7 @@ func (c *ChainConfig) ForkByName(name string) *Fork {
 	return &Fork{}
 }
 
-// GetFeature looks up fork features by id, where id can (currently) be [difficulty, gastable, eip155].
+// GetFeature looks up fork features by id, where id can (currently) be [difficulty, gastable, eip155, reward, atlantis, precompiles].
 // GetFeature returns the feature|nil, the latest fork configuring a given id, and if the given feature id was found at all
 // If queried feature is not found, returns ForkFeature{}, Fork{}, false.
 // If queried block number and/or feature is a zero-value, returns ForkFeature{}, Fork{}, false.
@@ -83,6 +83,76 @@ func TestChainConfig_IsDiehard(t *testing.T) {
 	}
 }
 
+func TestChainConfig_IsAtlantis(t *testing.T) {
+	config := DefaultConfigMainnet.ChainConfig
+	if config.IsAtlantis(big.NewInt(10000000)) {
+		t.Errorf("Unexpected for %d", 10000000)
+	}
+
+	config = MakeDiehardChainConfig()
+	config.Forks = append(config.Forks, &Fork{
+		Name:     "Atlantis",
+		Block:    big.NewInt(100),
+		Features: []*ForkFeature{{ID: "atlantis"}},
+	})
+	if config.IsAtlantis(big.NewInt(99)) {
+		t.Errorf("Unexpected for %d", 99)
+	}
+	if !config.IsAtlantis(big.NewInt(100)) {
+		t.Errorf("Expected for %d", 100)
+	}
+	if !config.IsAtlantis(big.NewInt(101)) {
+		t.Errorf("Expected for %d", 101)
+	}
+}
+
+func TestChainConfig_Precompiled(t *testing.T) {
+	config := MakeDiehardChainConfig()
+	if n := len(config.Precompiled(big.NewInt(10000000))); n != 4 {
+		t.Errorf("Expected 4 precompiled contracts, got %d", n)
+	}
+
+	config.Forks = append(config.Forks, &Fork{
+		Name:  "Atlantis",
+		Block: big.NewInt(100),
+		Features: []*ForkFeature{{
+			ID: "precompiles",
+			Options: ChainFeatureConfigOptions{
+				"modexp":         float64(5),
+				"bn256Add":       float64(6),
+				"bn256ScalarMul": "0x7",
+				"bn256Pairing":   8,
+			},
+		}},
+	})
+	if config.HasPrecompiles(big.NewInt(99)) {
+		t.Errorf("Unexpected for %d", 99)
+	}
+	if n := len(config.Precompiled(big.NewInt(99))); n != 4 {
+		t.Errorf("Expected 4 precompiled contracts at %d, got %d", 99, n)
+	}
+	if !config.HasPrecompiles(big.NewInt(100)) {
+		t.Errorf("Expected for %d", 100)
+	}
+	contracts := config.Precompiled(big.NewInt(100))
+	if n := len(contracts); n != 8 {
+		t.Errorf("Expected 8 precompiled contracts at %d, got %d", 100, n)
+	}
+	for i := byte(1); i <= 8; i++ {
+		if contracts[string(common.BytesToAddress([]byte{i}).Bytes())] == nil {
+			t.Errorf("Expected precompiled contract at address %d", i)
+		}
+	}
+
+	config.Forks[len(config.Forks)-1].Features[0].Options["unknown"] = float64(9)
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for unknown precompiled contract")
+		}
+	}()
+	config.Precompiled(big.NewInt(100))
+}
+
 func TestChainConfig_IsExplosion(t *testing.T) {
 	config := DefaultConfigMainnet.ChainConfig
 
@@ -792,6 +862,17 @@ func TestSufficientChainConfig_IsValid(t *testing.T) {
 			}
 			scc.Bootstrap = ooo
 
+			// An optional checkpoint must be complete.
+			scc.Checkpoint = &TrustedCheckpoint{SectionIndex: 1, ChtRoot: common.Hash{1}, SectionHead: common.Hash{2}}
+			if s, ok := scc.IsValid(); !ok {
+				t.Errorf("unexpected notok: %v @ %v/%v", s, i, j)
+			}
+			scc.Checkpoint.SectionHead = common.Hash{}
+			if s, ok := scc.IsValid(); ok {
+				t.Errorf("unexpected ok: %v @ %v/%v", s, i, j)
+			}
+			scc.Checkpoint = nil
+
 			oooo := scc.Genesis.Nonce
 			scc.Genesis.Nonce = ""
 			if s, ok := scc.IsValid(); ok {
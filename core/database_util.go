@@ -52,8 +52,32 @@ var (
 
 	preimagePrefix = "secure-key-" // preimagePrefix + hash -> preimage
 	lookupPrefix   = []byte("l")   // lookupPrefix + hash -> transaction/receipt lookup metadata
+
+	ancientIndexPrefix = []byte("ancient-") // ancientIndexPrefix + hash -> number of a block moved into the freezer
+
+	bloomBitsPrefix = []byte("bloombits-") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
 )
 
+// The kinds of data moved from the database into the freezer for every
+// canonical block.
+const (
+	freezerHashTable       = "hashes"
+	freezerHeaderTable     = "headers"
+	freezerBodiesTable     = "bodies"
+	freezerReceiptTable    = "receipts"
+	freezerDifficultyTable = "diffs"
+)
+
+// FreezerTables are the tables of the ancient chain data freezer, mapped to
+// whether their items are compressed.
+var FreezerTables = map[string]bool{
+	freezerHashTable:       false,
+	freezerHeaderTable:     true,
+	freezerBodiesTable:     true,
+	freezerReceiptTable:    true,
+	freezerDifficultyTable: false,
+}
+
 // TxLookupEntry is a positional metadata to help looking up the data content of
 // a transaction or receipt given only its hash.
 type TxLookupEntry struct {
@@ -65,12 +89,40 @@ type TxLookupEntry struct {
 // GetCanonicalHash retrieves a hash assigned to a canonical block number.
 func GetCanonicalHash(db ethdb.Database, number uint64) common.Hash {
 	data, _ := db.Get(append(blockNumPrefix, big.NewInt(int64(number)).Bytes()...))
+	if len(data) == 0 {
+		if reader, ok := db.(ethdb.AncientReader); ok {
+			data, _ = reader.Ancient(freezerHashTable, number)
+		}
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
 	return common.BytesToHash(data)
 }
 
+// getAncient retrieves the data of the given kind for a canonical block moved
+// into the freezer, or nil if the database has no freezer or the block wasn't
+// frozen.
+func getAncient(db ethdb.Database, kind string, hash common.Hash) []byte {
+	reader, ok := db.(ethdb.AncientReader)
+	if !ok {
+		return nil
+	}
+	data, _ := db.Get(append(ancientIndexPrefix, hash[:]...))
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+
+	// The index isn't cleaned up when the freezer is truncated, make sure the
+	// block is still the frozen one
+	if frozen, _ := reader.Ancient(freezerHashTable, number); !bytes.Equal(frozen, hash[:]) {
+		return nil
+	}
+	data, _ = reader.Ancient(kind, number)
+	return data
+}
+
 // GetHeadHeaderHash retrieves the hash of the current canonical head block's
 // header. The difference between this and GetHeadBlockHash is that whereas the
 // last block hash is only updated upon a full block import, the last header
@@ -109,6 +161,9 @@ func GetHeadFastBlockHash(db ethdb.Database) common.Hash {
 // if the header's not found.
 func GetHeaderRLP(db ethdb.Database, hash common.Hash) rlp.RawValue {
 	data, _ := db.Get(append(append(blockPrefix, hash[:]...), headerSuffix...))
+	if len(data) == 0 {
+		data = getAncient(db, freezerHeaderTable, hash)
+	}
 	return data
 }
 
@@ -130,6 +185,9 @@ func GetHeader(db ethdb.Database, hash common.Hash) *types.Header {
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db ethdb.Database, hash common.Hash) rlp.RawValue {
 	data, _ := db.Get(append(append(blockPrefix, hash[:]...), bodySuffix...))
+	if len(data) == 0 {
+		data = getAncient(db, freezerBodiesTable, hash)
+	}
 	return data
 }
 
@@ -152,6 +210,9 @@ func GetBody(db ethdb.Database, hash common.Hash) *types.Body {
 // none found.
 func GetTd(db ethdb.Database, hash common.Hash) *big.Int {
 	data, _ := db.Get(append(append(blockPrefix, hash.Bytes()...), tdSuffix...))
+	if len(data) == 0 {
+		data = getAncient(db, freezerDifficultyTable, hash)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -187,6 +248,9 @@ func GetBlock(db ethdb.Database, hash common.Hash) *types.Block {
 // in a block given by its hash.
 func GetBlockReceipts(db ethdb.Database, hash common.Hash) types.Receipts {
 	data, _ := db.Get(append(blockReceiptsPrefix, hash[:]...))
+	if len(data) == 0 {
+		data = getAncient(db, freezerReceiptTable, hash)
+	}
 	if len(data) == 0 {
 		return nil
 	}
@@ -245,7 +309,7 @@ func GetReceipt(db ethdb.Database, txHash common.Hash) *types.Receipt {
 }
 
 // WriteCanonicalHash stores the canonical hash for the given block number.
-func WriteCanonicalHash(db ethdb.Database, hash common.Hash, number uint64) error {
+func WriteCanonicalHash(db ethdb.Putter, hash common.Hash, number uint64) error {
 	key := append(blockNumPrefix, big.NewInt(int64(number)).Bytes()...)
 	if err := db.Put(key, hash.Bytes()); err != nil {
 		glog.Fatalf("failed to store number to hash mapping into database: %v", err)
@@ -255,7 +319,7 @@ func WriteCanonicalHash(db ethdb.Database, hash common.Hash, number uint64) erro
 }
 
 // WriteHeadHeaderHash stores the head header's hash.
-func WriteHeadHeaderHash(db ethdb.Database, hash common.Hash) error {
+func WriteHeadHeaderHash(db ethdb.Putter, hash common.Hash) error {
 	if err := db.Put(headHeaderKey, hash.Bytes()); err != nil {
 		glog.Fatalf("failed to store last header's hash into database: %v", err)
 		return err
@@ -264,7 +328,7 @@ func WriteHeadHeaderHash(db ethdb.Database, hash common.Hash) error {
 }
 
 // WriteHeadBlockHash stores the head block's hash.
-func WriteHeadBlockHash(db ethdb.Database, hash common.Hash) error {
+func WriteHeadBlockHash(db ethdb.Putter, hash common.Hash) error {
 	if err := db.Put(headBlockKey, hash.Bytes()); err != nil {
 		glog.Fatalf("failed to store last block's hash into database: %v", err)
 		return err
@@ -273,7 +337,7 @@ func WriteHeadBlockHash(db ethdb.Database, hash common.Hash) error {
 }
 
 // WriteHeadFastBlockHash stores the fast head block's hash.
-func WriteHeadFastBlockHash(db ethdb.Database, hash common.Hash) error {
+func WriteHeadFastBlockHash(db ethdb.Putter, hash common.Hash) error {
 	if err := db.Put(headFastKey, hash.Bytes()); err != nil {
 		glog.Fatalf("failed to store last fast block's hash into database: %v", err)
 		return err
@@ -302,6 +366,11 @@ func WriteBody(db ethdb.Database, hash common.Hash, body *types.Body) error {
 	if err != nil {
 		return err
 	}
+	return WriteBodyRLP(db, hash, data)
+}
+
+// WriteBodyRLP stores an RLP encoded block body into the database.
+func WriteBodyRLP(db ethdb.Database, hash common.Hash, data rlp.RawValue) error {
 	key := append(append(blockPrefix, hash.Bytes()...), bodySuffix...)
 	if err := db.Put(key, data); err != nil {
 		glog.Fatalf("failed to store block body into database: %v", err)
@@ -429,27 +498,27 @@ func WriteReceipts(db ethdb.Database, receipts types.Receipts) error {
 }
 
 // DeleteCanonicalHash removes the number to hash canonical mapping.
-func DeleteCanonicalHash(db ethdb.Database, number uint64) {
+func DeleteCanonicalHash(db ethdb.Deleter, number uint64) {
 	db.Delete(append(blockNumPrefix, big.NewInt(int64(number)).Bytes()...))
 }
 
 // DeleteHeader removes all block header data associated with a hash.
-func DeleteHeader(db ethdb.Database, hash common.Hash) {
+func DeleteHeader(db ethdb.Deleter, hash common.Hash) {
 	db.Delete(append(append(blockPrefix, hash.Bytes()...), headerSuffix...))
 }
 
 // DeleteBody removes all block body data associated with a hash.
-func DeleteBody(db ethdb.Database, hash common.Hash) {
+func DeleteBody(db ethdb.Deleter, hash common.Hash) {
 	db.Delete(append(append(blockPrefix, hash.Bytes()...), bodySuffix...))
 }
 
 // DeleteTd removes all block total difficulty data associated with a hash.
-func DeleteTd(db ethdb.Database, hash common.Hash) {
+func DeleteTd(db ethdb.Deleter, hash common.Hash) {
 	db.Delete(append(append(blockPrefix, hash.Bytes()...), tdSuffix...))
 }
 
 // DeleteBlock removes all block data associated with a hash.
-func DeleteBlock(db ethdb.Database, hash common.Hash) {
+func DeleteBlock(db ethdb.Deleter, hash common.Hash) {
 	DeleteBlockReceipts(db, hash)
 	DeleteHeader(db, hash)
 	DeleteBody(db, hash)
@@ -457,18 +526,18 @@ func DeleteBlock(db ethdb.Database, hash common.Hash) {
 }
 
 // DeleteBlockReceipts removes all receipt data associated with a block hash.
-func DeleteBlockReceipts(db ethdb.Database, hash common.Hash) {
+func DeleteBlockReceipts(db ethdb.Deleter, hash common.Hash) {
 	db.Delete(append(blockReceiptsPrefix, hash.Bytes()...))
 }
 
 // DeleteTransaction removes all transaction data associated with a hash.
-func DeleteTransaction(db ethdb.Database, hash common.Hash) {
+func DeleteTransaction(db ethdb.Deleter, hash common.Hash) {
 	db.Delete(hash.Bytes())
 	db.Delete(append(hash.Bytes(), txMetaSuffix...))
 }
 
 // DeleteReceipt removes all receipt data associated with a transaction hash.
-func DeleteReceipt(db ethdb.Database, hash common.Hash) {
+func DeleteReceipt(db ethdb.Deleter, hash common.Hash) {
 	db.Delete(append(receiptsPrefix, hash.Bytes()...))
 }
 
@@ -577,6 +646,32 @@ func GetMipmapBloom(db ethdb.Database, number, level uint64) types.Bloom {
 	return types.BytesToBloom(bloomDat)
 }
 
+// bloomBitsKey returns the key of the bloom bits of a section, tied to the hash
+// of the section's last header so a reorged section is never read by mistake.
+func bloomBitsKey(bit uint, section uint64, head common.Hash) []byte {
+	key := append(append([]byte{}, bloomBitsPrefix...), make([]byte, 10)...)
+
+	binary.BigEndian.PutUint16(key[len(bloomBitsPrefix):], uint16(bit))
+	binary.BigEndian.PutUint64(key[len(bloomBitsPrefix)+2:], section)
+
+	return append(key, head.Bytes()...)
+}
+
+// GetBloomBits retrieves the compressed bloom bit vector belonging to the given
+// section and bit index.
+func GetBloomBits(db ethdb.Database, bit uint, section uint64, head common.Hash) ([]byte, error) {
+	return db.Get(bloomBitsKey(bit, section, head))
+}
+
+// WriteBloomBits writes the compressed bloom bits vector belonging to the given
+// section and bit index.
+func WriteBloomBits(db ethdb.Putter, bit uint, section uint64, head common.Hash, bits []byte) error {
+	if err := db.Put(bloomBitsKey(bit, section, head), bits); err != nil {
+		return fmt.Errorf("failed to store bloom bits of section %d, bit %d: %v", section, bit, err)
+	}
+	return nil
+}
+
 // GetBlockChainVersion reads the version number from db.
 func GetBlockChainVersion(db ethdb.Database) int {
 	var vsn uint
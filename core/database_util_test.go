@@ -302,12 +302,7 @@ func TestTdStorage(t *testing.T) {
 }
 
 func TestAddrTxStorage(t *testing.T) {
-	dbFilepath, err := ioutil.TempDir("", "geth-db-util-test")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer os.RemoveAll(dbFilepath)
-	db, _ := ethdb.NewLDBDatabase(dbFilepath, 10, 100)
+	db, _ := ethdb.NewMemDatabase()
 
 	testKey := func(hex string) (*ecdsa.PrivateKey, common.Address) {
 		key := crypto.ToECDSA(common.Hex2Bytes(hex))
@@ -377,14 +372,13 @@ func TestAddrTxStorage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	prefix := ethdb.NewBytesPrefix(txAddressIndexPrefix)
-	it := db.NewIteratorRange(prefix)
+	it := db.NewIterator(txAddressIndexPrefix, nil)
 	count := 0
 	for it.Next() {
 		count++
 		//// Debugger -- it's kinda nice to see what the indexes look like
 		//ad, bn, tf, sc, txh := resolveAddrTxBytes(it.Key())
-		//addr, blockn, direc, ko, txhash := common.BytesToAddress(ad), binary.LittleEndian.Uint64(bn), string(tf), string(sc), common.BytesToHash(txh)
+		//addr, blockn, direc, ko, txhash := common.BytesToAddress(ad), binary.BigEndian.Uint64(bn), string(tf), string(sc), common.BytesToHash(txh)
 		//t.Log(addr.Hex(), blockn, direc, ko, txhash.Hex())
 	}
 	it.Release()
@@ -480,6 +474,100 @@ func TestAddrTxStorage(t *testing.T) {
 	}
 }
 
+func TestAddrTxStorageTransfers(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	key := crypto.ToECDSA(common.Hex2Bytes("123915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8"))
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	var (
+		contract  = common.BytesToAddress([]byte{0x11})
+		internal  = common.BytesToAddress([]byte{0x22})
+		tokenFrom = common.BytesToAddress([]byte{0x33})
+		tokenTo   = common.BytesToAddress([]byte{0x44})
+	)
+
+	tx, err := types.NewTransaction(1, contract, big.NewInt(111), big.NewInt(1111), big.NewInt(11111), nil).WithSigner(types.NewChainIdSigner(big.NewInt(1))).SignECDSA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(314)}, []*types.Transaction{tx}, nil, nil)
+
+	receipt := types.NewReceipt(nil, big.NewInt(1))
+	receipt.Logs = vm.Logs{
+		// ERC20 transfer
+		{Address: contract, Topics: []common.Hash{erc20TransferTopic, tokenFrom.Hash(), tokenTo.Hash()}},
+		// ERC721 transfer, with the token id indexed
+		{Address: contract, Topics: []common.Hash{erc20TransferTopic, tokenFrom.Hash(), internal.Hash(), common.Hash{}}},
+	}
+	if err := WriteBlockReceipts(db, block.Hash(), types.Receipts{receipt}); err != nil {
+		t.Fatal(err)
+	}
+	// The contract sends value on to another account, and the sender gets
+	// some of it back.
+	transfers := []atxiTransfer{
+		{TxHash: tx.Hash(), From: contract, To: internal},
+		{TxHash: tx.Hash(), From: contract, To: from},
+	}
+	if err := writeAtxiTransfers(db, block.Hash(), transfers); err != nil {
+		t.Fatal(err)
+	}
+
+	atxi := &AtxiT{Db: db, Internal: true, Tokens: true}
+	if err := atxi.writeBlock(db, block); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address   common.Address
+		direction string
+		kindof    string
+		want      int
+	}{
+		{from, "", "", 1}, // listed once, as sender and internal recipient
+		{from, "t", "", 1},
+		{from, "t", "s", 0},
+		{from, "", "i", 1},
+		{contract, "f", "internal", 1},
+		{contract, "", "t", 0},
+		{internal, "", "", 1},
+		{internal, "", "token", 0},
+		{tokenFrom, "f", "t", 1},
+		{tokenTo, "t", "token", 1},
+		{tokenTo, "", "si", 0},
+		{tokenTo, "", "it", 1},
+		{tokenTo, "", "b", 1},
+	}
+	for i, tt := range tests {
+		out, err := GetAddrTxs(db, tt.address, 0, 0, tt.direction, tt.kindof, -1, -1, false)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if len(out) != tt.want {
+			t.Errorf("test %d: got %d txs, want %d", i, len(out), tt.want)
+		} else if len(out) == 1 && common.HexToHash(out[0]) != tx.Hash() {
+			t.Errorf("test %d: got %s, want %x", i, out[0], tx.Hash())
+		}
+	}
+	if _, err := GetAddrTxs(db, from, 0, 0, "", "x", -1, -1, false); err == nil {
+		t.Error("expected error for invalid kind of")
+	}
+
+	// Removing the block, eg. on a reorg, drops all its entries.
+	batch := db.NewBatch()
+	if err := atxi.deleteBlock(batch, db, block); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	it := db.NewIterator(txAddressIndexPrefix, nil)
+	for it.Next() {
+		t.Errorf("unexpected index entry left: %x", it.Key())
+	}
+	it.Release()
+}
+
 func TestFormatAndResolveAddrTxBytesKey(t *testing.T) {
 	testAddr := common.Address{}
 	testBN := uint64(42)
@@ -488,7 +576,7 @@ func TestFormatAndResolveAddrTxBytesKey(t *testing.T) {
 	testTxH := common.Hash{}
 
 	testBNBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(testBNBytes, testBN)
+	binary.BigEndian.PutUint64(testBNBytes, testBN)
 
 	key := formatAddrTxBytesIndex(testAddr.Bytes(), testBNBytes, []byte(testTorf), []byte(testKindOf), testTxH.Bytes())
 
@@ -504,7 +592,7 @@ func TestFormatAndResolveAddrTxBytesKey(t *testing.T) {
 	if gotAddr := common.BytesToAddress(outAddr); gotAddr != testAddr {
 		t.Errorf("got: %v, want: %v", gotAddr.Hex(), testAddr.Hex())
 	}
-	if gotBN := binary.LittleEndian.Uint64(outBNBytes); gotBN != testBN {
+	if gotBN := binary.BigEndian.Uint64(outBNBytes); gotBN != testBN {
 		t.Errorf("got: %v, want: %v", gotBN, testBN)
 	}
 	if gotTorf := string(outTorf); gotTorf != testTorf {
@@ -865,3 +953,156 @@ func TestMipmapChain(t *testing.T) {
 		t.Error("address was included in bloom and should not have")
 	}
 }
+
+func TestAddrTxPagination(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	key := crypto.ToECDSA(common.Hex2Bytes("123915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8"))
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.NewChainIdSigner(big.NewInt(1))
+
+	// Blocks of a few transactions each, sent to others and to itself, so a
+	// transaction is indexed twice for the address across page boundaries.
+	nonce := uint64(0)
+	for n := uint64(1); n <= 20; n++ {
+		var txs []*types.Transaction
+		for i := uint64(0); i < n%4; i++ {
+			to := common.BytesToAddress([]byte{byte(n), byte(i)})
+			if i%2 == 0 {
+				to = from
+			}
+			tx, err := types.NewTransaction(nonce, to, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil).WithSigner(signer).SignECDSA(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			txs = append(txs, tx)
+			nonce++
+		}
+		// Spread the blocks over byte boundaries of the block numbers
+		block := types.NewBlock(&types.Header{Number: new(big.Int).SetUint64(n * 100)}, txs, nil, nil)
+		if err := WriteBlockAddTxIndexes(db, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		blockStart, blockEnd uint64
+		direction            string
+	}{
+		{0, 0, ""},
+		{0, 0, "to"},
+		{500, 1500, ""},
+	} {
+		newest, err := GetAddrTxs(db, from, tt.blockStart, tt.blockEnd, tt.direction, "", -1, -1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldest, err := GetAddrTxs(db, from, tt.blockStart, tt.blockEnd, tt.direction, "", -1, -1, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(newest) == 0 || len(newest) != len(oldest) {
+			t.Fatalf("%v: got %d newest first and %d oldest first txs", tt, len(newest), len(oldest))
+		}
+		// The order of the transactions within a block isn't defined
+		listed := make(map[string]bool)
+		for _, tx := range newest {
+			listed[tx] = true
+		}
+		for _, tx := range oldest {
+			if !listed[tx] {
+				t.Fatalf("%v: tx %s only listed oldest first", tt, tx)
+			}
+		}
+		count, err := CountAddrTxs(db, from, tt.blockStart, tt.blockEnd, tt.direction, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != uint64(len(oldest)) {
+			t.Errorf("%v: got count %d, want %d", tt, count, len(oldest))
+		}
+
+		// Paging through in either order lists the same transactions
+		for _, limit := range []int{1, 2, 3, 7, len(oldest), len(oldest) + 1} {
+			for _, reverse := range []bool{false, true} {
+				want := oldest
+				if reverse {
+					want = newest
+				}
+				var (
+					have   []string
+					cursor string
+				)
+				for pages := 0; ; pages++ {
+					if pages > len(want) {
+						t.Fatalf("%v: limit %d: cursor doesn't move on", tt, limit)
+					}
+					page, next, err := GetAddrTxsPage(db, from, tt.blockStart, tt.blockEnd, tt.direction, "", cursor, limit, reverse)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(page) > limit {
+						t.Fatalf("%v: limit %d: got page of %d txs", tt, limit, len(page))
+					}
+					have = append(have, page...)
+					if next == "" {
+						break
+					}
+					cursor = next
+				}
+				if len(have) != len(want) {
+					t.Fatalf("%v: limit %d, reverse %v: got %d txs, want %d", tt, limit, reverse, len(have), len(want))
+				}
+				for i := range have {
+					if have[i] != want[i] {
+						t.Fatalf("%v: limit %d, reverse %v: tx %d mismatch: got %s, want %s", tt, limit, reverse, i, have[i], want[i])
+					}
+				}
+			}
+		}
+	}
+	// Offsets still work on the streamed transactions
+	all, _ := GetAddrTxs(db, from, 0, 0, "", "", -1, -1, false)
+	out, _ := GetAddrTxs(db, from, 0, 0, "", "", 2, 5, false)
+	if len(out) != 3 || out[0] != all[2] || out[2] != all[4] {
+		t.Errorf("got %v, want %v", out, all[2:5])
+	}
+	if _, _, err := GetAddrTxsPage(db, from, 0, 0, "", "", "0x1234", 10, false); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+}
+
+func TestMigrateAddrTxIndex(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	addr := common.BytesToAddress([]byte{0x11})
+	for _, n := range []uint64{1, 256, 300} {
+		bn := make([]byte, 8)
+		binary.LittleEndian.PutUint64(bn, n)
+		key := append(append(append(append([]byte{}, txAddressIndexLegacyPrefix...), addr.Bytes()...), bn...), 'f', 's')
+		key = append(key, common.BigToHash(new(big.Int).SetUint64(n)).Bytes()...)
+		db.Put(key, nil)
+	}
+	if err := MigrateAddrTxIndex(db); err != nil {
+		t.Fatal(err)
+	}
+	it := db.NewIterator(txAddressIndexLegacyPrefix, nil)
+	for it.Next() {
+		t.Errorf("legacy entry left: %x", it.Key())
+	}
+	it.Release()
+
+	out, err := GetAddrTxs(db, addr, 0, 0, "", "", -1, -1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{1, 256, 300}
+	if len(out) != len(want) {
+		t.Fatalf("got %d txs, want %d", len(out), len(want))
+	}
+	for i, n := range want {
+		if have := common.HexToHash(out[i]).Big().Uint64(); have != n {
+			t.Errorf("tx %d: got block %d, want %d", i, have, n)
+		}
+	}
+}
@@ -32,14 +32,14 @@ var (
 
 // Call executes within the given contract
 func Call(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice, value *big.Int) (ret []byte, err error) {
-	ret, _, err = exec(env, caller, &addr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value)
+	ret, _, err = exec(env, vm.CALL, caller, &addr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value)
 	return ret, err
 }
 
 // CallCode executes the given address' code as the given contract address
 func CallCode(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice, value *big.Int) (ret []byte, err error) {
 	callerAddr := caller.Address()
-	ret, _, err = exec(env, caller, &callerAddr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value)
+	ret, _, err = exec(env, vm.CALLCODE, caller, &callerAddr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, value)
 	return ret, err
 }
 
@@ -52,20 +52,63 @@ func DelegateCall(env vm.Environment, caller vm.ContractRef, addr common.Address
 	return ret, err
 }
 
+// StaticCall executes within the given contract like Call, but disallows any
+// modifications to the state for the duration of the call
+func StaticCall(env vm.Environment, caller vm.ContractRef, addr common.Address, input []byte, gas, gasPrice *big.Int) (ret []byte, err error) {
+	ret, _, err = exec(env, vm.STATICCALL, caller, &addr, &addr, env.Db().GetCodeHash(addr), input, env.Db().GetCode(addr), gas, gasPrice, new(big.Int))
+	return ret, err
+}
+
 // Create creates a new contract with the given code
 func Create(env vm.Environment, caller vm.ContractRef, code []byte, gas, gasPrice, value *big.Int) (ret []byte, address common.Address, err error) {
-	ret, address, err = exec(env, caller, nil, nil, crypto.Keccak256Hash(code), nil, code, gas, gasPrice, value)
+	ret, address, err = exec(env, vm.CREATE, caller, nil, nil, crypto.Keccak256Hash(code), nil, code, gas, gasPrice, value)
 	// Here we get an error if we run into maximum stack depth,
 	// See: https://github.com/ethereum/yellowpaper/pull/131
-	// and YP definitions for CREATE instruction
-	if err != nil {
+	// and YP definitions for CREATE instruction. The output of a reverted
+	// creation is kept as it is made available through RETURNDATACOPY.
+	if err != nil && err != vm.ErrExecutionReverted {
 		return nil, address, err
 	}
 	return ret, address, err
 }
 
-func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.Address, codeHash common.Hash, input, code []byte, gas, gasPrice, value *big.Int) (ret []byte, addr common.Address, err error) {
+// tracer returns the tracer configured on the environment's EVM, if any.
+func tracer(env vm.Environment) vm.Tracer {
+	if evm, ok := env.Vm().(*vm.EVM); ok {
+		return evm.Tracer()
+	}
+	return nil
+}
+
+// captureFrame reports a new call frame of type typ to the environment's
+// tracer, if any. The returned function must be deferred to close the frame
+// once the call returns; gas is the (mutable) gas allowance of the call.
+func captureFrame(env vm.Environment, typ vm.OpCode, from, to common.Address, input []byte, gas, value *big.Int) func(ret []byte, err error) {
+	t := tracer(env)
+	if t == nil {
+		return func([]byte, error) {}
+	}
+	t.CaptureEnter(typ, from, to, input, gas, value)
+
+	initial := new(big.Int).Set(gas)
+	return func(ret []byte, err error) {
+		t.CaptureExit(ret, new(big.Int).Sub(initial, gas), err)
+	}
+}
+
+func exec(env vm.Environment, typ vm.OpCode, caller vm.ContractRef, address, codeAddr *common.Address, codeHash common.Hash, input, code []byte, gas, gasPrice, value *big.Int) (ret []byte, addr common.Address, err error) {
 	evm := env.Vm()
+
+	// Report the call frame to the tracer. Creations report the address the
+	// contract is going to be deployed at and the init code as input.
+	frameTo, frameInput := codeAddr, input
+	if address == nil {
+		created := crypto.CreateAddress(caller.Address(), env.Db().GetNonce(caller.Address()))
+		frameTo, frameInput = &created, code
+	}
+	captureExit := captureFrame(env, typ, caller.Address(), *frameTo, frameInput, gas, value)
+	defer func() { captureExit(ret, err) }()
+
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if env.Depth() > callCreateDepthMax {
@@ -111,6 +154,9 @@ func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.A
 	// only.
 	contract := vm.NewContract(caller, to, value, gas, gasPrice)
 	contract.SetCallCode(codeAddr, codeHash, code)
+	if typ == vm.STATICCALL {
+		contract.ReadOnly = true
+	}
 	defer contract.Finalise()
 
 	ret, err = evm.Run(contract, input)
@@ -132,8 +178,11 @@ func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.A
 	// When an error was returned by the EVM or when setting the creation code
 	// above we revert to the snapshot and consume any gas remaining. Additionally
 	// when we're in homestead this also counts for code storage gas errors.
+	// A REVERT returns the remaining gas to the caller.
 	if err != nil && (env.RuleSet().IsHomestead(env.BlockNumber()) || err != vm.CodeStoreOutOfGasError) {
-		contract.UseGas(contract.Gas)
+		if err != vm.ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 
 		env.RevertToSnapshot(snapshotPreTransfer)
 	}
@@ -143,6 +192,10 @@ func exec(env vm.Environment, caller vm.ContractRef, address, codeAddr *common.A
 
 func execDelegateCall(env vm.Environment, caller vm.ContractRef, originAddr, toAddr, codeAddr *common.Address, codeHash common.Hash, input, code []byte, gas, gasPrice, value *big.Int) (ret []byte, addr common.Address, err error) {
 	evm := env.Vm()
+
+	captureExit := captureFrame(env, vm.DELEGATECALL, caller.Address(), *codeAddr, input, gas, nil)
+	defer func() { captureExit(ret, err) }()
+
 	// Depth check execution. Fail if we're trying to execute above the
 	// limit.
 	if env.Depth() > callCreateDepthMax {
@@ -166,7 +219,9 @@ func execDelegateCall(env vm.Environment, caller vm.ContractRef, originAddr, toA
 
 	ret, err = evm.Run(contract, input)
 	if err != nil {
-		contract.UseGas(contract.Gas)
+		if err != vm.ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 
 		env.RevertToSnapshot(snapshot)
 	}
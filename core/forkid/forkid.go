@@ -0,0 +1,166 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package forkid implements the fork identifier of EIP-2124, a short summary
+// of a chain's genesis and passed forks that lets peers on incompatible chains
+// tell each other apart before syncing anything.
+package forkid
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"sort"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+)
+
+var (
+	// ErrRemoteStale is returned by the validator if a remote fork checksum is a
+	// subset of our already applied forks, but the announced next fork block is
+	// not on our already passed chain.
+	ErrRemoteStale = errors.New("remote needs update")
+
+	// ErrLocalIncompatibleOrStale is returned by the validator if a remote fork
+	// checksum does not match any local checksum variation, signalling that the
+	// two chains have diverged in the past at some point (possibly at genesis).
+	ErrLocalIncompatibleOrStale = errors.New("local incompatible or needs update")
+)
+
+// ID is a fork identifier as defined by EIP-2124.
+type ID struct {
+	Hash [4]byte // CRC32 checksum of the genesis block and passed fork block numbers
+	Next uint64  // Block number of the next upcoming fork, or 0 if no forks are known
+}
+
+// Filter is a fork id filter to validate a remotely advertised ID.
+type Filter func(id ID) error
+
+// NewID calculates the fork ID of a chain from its configuration, genesis hash
+// and current head block number.
+func NewID(config *core.ChainConfig, genesis common.Hash, head uint64) ID {
+	hash := crc32.ChecksumIEEE(genesis[:])
+
+	// Fold the passed forks into the checksum, stopping at the first upcoming one
+	var next uint64
+	for _, fork := range gatherForks(config) {
+		if fork <= head {
+			hash = checksumUpdate(hash, fork)
+			continue
+		}
+		next = fork
+		break
+	}
+	return ID{Hash: checksumToBytes(hash), Next: next}
+}
+
+// NewFilter creates a filter that returns if a fork ID should be rejected or
+// not based on the local chain's configuration, genesis hash and head, the
+// latter being retrieved on every validation as it moves along with the sync.
+func NewFilter(config *core.ChainConfig, genesis common.Hash, headfn func() uint64) Filter {
+	// Calculate all the valid fork hash and fork next combos
+	forks := gatherForks(config)
+	sums := make([][4]byte, len(forks)+1) // 0th is the genesis
+	hash := crc32.ChecksumIEEE(genesis[:])
+	sums[0] = checksumToBytes(hash)
+	for i, fork := range forks {
+		hash = checksumUpdate(hash, fork)
+		sums[i+1] = checksumToBytes(hash)
+	}
+	// Add a sentinel fork that is never passed
+	forks = append(forks, math.MaxUint64)
+
+	return func(id ID) error {
+		head := headfn()
+		for i, fork := range forks {
+			// Skip the forks already passed, the checksum of the first upcoming
+			// one is our current state
+			if head >= fork {
+				continue
+			}
+			// If the checksums match, the remote is on the same fork. Reject it
+			// only if it announces a fork we've already passed without applying.
+			if sums[i] == id.Hash {
+				if id.Next > 0 && head >= id.Next {
+					return ErrLocalIncompatibleOrStale
+				}
+				return nil
+			}
+			// If the remote checksum is one of our past ones, it's still syncing
+			// and must announce the fork we applied next, or else it is stale.
+			for j := 0; j < i; j++ {
+				if sums[j] == id.Hash {
+					if forks[j] != id.Next {
+						return ErrRemoteStale
+					}
+					return nil
+				}
+			}
+			// If the remote checksum is one of our future ones, we're the one
+			// behind. Accept and let the sync sort it out.
+			for j := i + 1; j < len(sums); j++ {
+				if sums[j] == id.Hash {
+					return nil
+				}
+			}
+			return ErrLocalIncompatibleOrStale
+		}
+		glog.V(logger.Error).Errorf("Impossible fork ID validation: id=%+v head=%d", id, head)
+		return nil
+	}
+}
+
+// gatherForks returns the sorted, deduplicated block numbers of the forks that
+// change the chain rules. Forks at genesis are left out, as are the ones only
+// pinning a required block hash without configuring any features, since they
+// don't split the network by themselves.
+func gatherForks(config *core.ChainConfig) []uint64 {
+	var forks []uint64
+	for _, fork := range config.Forks {
+		if fork.Block == nil || fork.Block.Sign() <= 0 || len(fork.Features) == 0 {
+			continue
+		}
+		forks = append(forks, fork.Block.Uint64())
+	}
+	sort.Slice(forks, func(i, j int) bool { return forks[i] < forks[j] })
+
+	for i := 1; i < len(forks); i++ {
+		if forks[i] == forks[i-1] {
+			forks = append(forks[:i], forks[i+1:]...)
+			i--
+		}
+	}
+	return forks
+}
+
+// checksumUpdate calculates the next IEEE CRC32 checksum based on the previous
+// one and a fork block number (equivalent to CRC32(original-blob || fork)).
+func checksumUpdate(hash uint32, fork uint64) uint32 {
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], fork)
+	return crc32.Update(hash, crc32.IEEETable, blob[:])
+}
+
+// checksumToBytes converts a uint32 checksum into a [4]byte array.
+func checksumToBytes(hash uint32) [4]byte {
+	var blob [4]byte
+	binary.BigEndian.PutUint32(blob[:], hash)
+	return blob
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+var (
+	mainnetGenesisHash = common.HexToHash("d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+	mordenGenesisHash  = common.HexToHash("0cd786a2425d16f152c658316c423e6ce1181e15c3295826d7c9904cba9ce303")
+)
+
+// Tests that fork IDs are properly calculated given the genesis hash and the
+// fork configuration of the default chains.
+func TestCreation(t *testing.T) {
+	type testcase struct {
+		head uint64
+		want ID
+	}
+	tests := []struct {
+		config  *core.ChainConfig
+		genesis common.Hash
+		cases   []testcase
+	}{
+		// Mainnet test cases
+		{
+			core.DefaultConfigMainnet.ChainConfig,
+			mainnetGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},       // Unsynced
+				{1149999, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}}, // Last Frontier block
+				{1150000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 2500000}}, // First Homestead block
+				{1920000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 2500000}}, // DAO block, no rule change
+				{2499999, ID{Hash: checksumToBytes(0x97c2c34c), Next: 2500000}}, // Last Homestead block
+				{2500000, ID{Hash: checksumToBytes(0xdb06803f), Next: 3000000}}, // First GasReprice block
+				{3000000, ID{Hash: checksumToBytes(0xaff4bed4), Next: 5000000}}, // First Diehard block
+				{5000000, ID{Hash: checksumToBytes(0xf79a63c0), Next: 5900000}}, // First Gotham block
+				{5899999, ID{Hash: checksumToBytes(0xf79a63c0), Next: 5900000}}, // Last Gotham block
+				{5900000, ID{Hash: checksumToBytes(0x744899d6), Next: 0}},       // First Defuse Difficulty Bomb block
+				{8000000, ID{Hash: checksumToBytes(0x744899d6), Next: 0}},       // Future block
+			},
+		},
+		// Morden test cases
+		{
+			core.DefaultConfigMorden.ChainConfig,
+			mordenGenesisHash,
+			[]testcase{
+				{0, ID{Hash: checksumToBytes(0x417adbe7), Next: 494000}},
+				{494000, ID{Hash: checksumToBytes(0xaeb67dfb), Next: 1783000}},
+				{1885000, ID{Hash: checksumToBytes(0x6a495281), Next: 1915000}},
+				{2300000, ID{Hash: checksumToBytes(0x02a93060), Next: 0}},
+			},
+		},
+	}
+	for i, tt := range tests {
+		for j, ttt := range tt.cases {
+			if have := NewID(tt.config, tt.genesis, ttt.head); have != ttt.want {
+				t.Errorf("test %d, case %d: fork ID mismatch: have %x, want %x", i, j, have, ttt.want)
+			}
+		}
+	}
+}
+
+// Tests that validating remote fork IDs works correctly across various local
+// head positions of the mainnet chain.
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is mainnet Gotham, remote announces the same. No future fork is announced.
+		{5000000, ID{Hash: checksumToBytes(0xf79a63c0), Next: 0}, nil},
+
+		// Local is mainnet Gotham, remote announces the same. Remote also announces
+		// the next fork at block 5900000, which is known.
+		{5000000, ID{Hash: checksumToBytes(0xf79a63c0), Next: 5900000}, nil},
+
+		// Local is mainnet Gotham, remote announces the same. Remote also announces
+		// a future fork at block 0xffffffff, but that is uncertain.
+		{5000000, ID{Hash: checksumToBytes(0xf79a63c0), Next: math.MaxUint32}, nil},
+
+		// Local is mainnet currently in Homestead only (so it's aware of GasReprice),
+		// remote announces also Homestead, but it's not yet aware of GasReprice
+		// (e.g. no fork next is announced). Accept, since we can't tell yet.
+		{2000000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 0}, nil},
+
+		// Local is mainnet currently in Homestead only, remote announces Homestead
+		// plus GasReprice. Remote is simply ahead of us.
+		{2000000, ID{Hash: checksumToBytes(0xdb06803f), Next: 3000000}, nil},
+
+		// Local is mainnet Homestead, remote announces Frontier with Homestead as
+		// the next fork. Remote is simply out of sync, accept.
+		{2000000, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}, nil},
+
+		// Local is mainnet Gotham, remote announces Diehard with Gotham next.
+		// Remote is syncing, accept.
+		{5000000, ID{Hash: checksumToBytes(0xaff4bed4), Next: 5000000}, nil},
+
+		// Local is mainnet Homestead, remote announces Frontier with the ETH DAO
+		// fork next. Remote forked away from us, reject as stale.
+		{2000000, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1920000}, ErrRemoteStale},
+
+		// Local is mainnet Gotham, remote announces Homestead without a next
+		// fork. Remote doesn't know about GasReprice, reject as stale.
+		{5000000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 0}, ErrRemoteStale},
+
+		// Local is mainnet Homestead, remote announces the ETH DAO fork checksum.
+		// Remote is on an incompatible chain, reject.
+		{2000000, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Homestead past block 1920000, remote announces the same
+		// Homestead, with the ETH DAO fork next. We've passed it, reject.
+		{2000000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}, ErrLocalIncompatibleOrStale},
+
+		// Local is mainnet Defuse Difficulty Bomb, remote announces a random fork.
+		// Incompatible, reject.
+		{8000000, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := NewFilter(core.DefaultConfigMainnet.ChainConfig, mainnetGenesisHash, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that IDs are properly RLP encoded (specifically important because we
+// use uint32 to store the hash, but we need to encode it as [4]byte).
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, common.Hex2Bytes("c6840000000080")},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, common.Hex2Bytes("ca84deadbeef84baddcafe")},
+		{ID{Hash: checksumToBytes(math.MaxUint32), Next: math.MaxUint64}, common.Hex2Bytes("ce84ffffffff88ffffffffffffffff")},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode forkid: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
@@ -239,7 +239,9 @@ type WhCallback func(*types.Header) error
 // The verify parameter can be used to fine tune whether nonce verification
 // should be done or not. The reason behind the optional check is because some
 // of the header retrieval mechanisms already need to verfy nonces, as well as
-// because nonces can be verified sparsely, not needing to check each.
+// because nonces can be verified sparsely, not needing to check each. A checkFreq
+// of 0 skips nonce verification altogether, for headers the caller verifies by
+// other means, e.g. by linking them to a trusted checkpoint.
 func (hc *HeaderChain) InsertHeaderChain(chain []*types.Header, checkFreq int, writeHeader WhCallback) (res *HeaderChainInsertResult) {
 	res = &HeaderChainInsertResult{}
 
@@ -250,14 +252,16 @@ func (hc *HeaderChain) InsertHeaderChain(chain []*types.Header, checkFreq int, w
 
 	// Generate the list of headers that should be POW verified
 	verify := make([]bool, len(chain))
-	for i := 0; i < len(verify)/checkFreq; i++ {
-		index := i*checkFreq + hc.rand.Intn(checkFreq)
-		if index >= len(verify) {
-			index = len(verify) - 1
+	if checkFreq > 0 {
+		for i := 0; i < len(verify)/checkFreq; i++ {
+			index := i*checkFreq + hc.rand.Intn(checkFreq)
+			if index >= len(verify) {
+				index = len(verify) - 1
+			}
+			verify[index] = true
 		}
-		verify[index] = true
+		verify[len(verify)-1] = true // Last should always be verified to avoid junk
 	}
-	verify[len(verify)-1] = true // Last should always be verified to avoid junk
 
 	// Create the header verification task queue and worker functions
 	tasks := make(chan int, len(chain))
@@ -476,12 +480,15 @@ func (hc *HeaderChain) SetCurrentHeader(head *types.Header) {
 }
 
 // DeleteCallback is a callback function that is called by SetHead before
-// each header is deleted.
-type DeleteCallback func(common.Hash)
+// each header is deleted. Any deletions should go to the given batch, so
+// they are written together with the headers'.
+type DeleteCallback func(ethdb.Deleter, common.Hash)
 
 // PurgeAbove remove blockchain data above given head 'n'.
 // Similar to hc.SetHead, but uses hardcoded 2048 scan range to check
 // for existing blockchain above last found existing head.
+// The deletions are written in batches of ethdb.IdealBatchSize; an interrupted
+// purge leaves a shorter chain above 'n', which is purged by running again.
 // TODO: possibly replace with kv database iterator
 func (hc *HeaderChain) PurgeAbove(n uint64, delFn DeleteCallback) {
 
@@ -496,6 +503,7 @@ func (hc *HeaderChain) PurgeAbove(n uint64, delFn DeleteCallback) {
 		}
 	}()
 
+	batch := hc.chainDb.NewBatch()
 	lastFoundHeaderN := n
 	var head *types.Header = nil
 	for ; head != nil || n < lastFoundHeaderN+2048; n++ {
@@ -506,18 +514,39 @@ func (hc *HeaderChain) PurgeAbove(n uint64, delFn DeleteCallback) {
 			glog.V(logger.Detail).Infof("    delete header/hash/td headn=%d", head.Number.Uint64())
 			hash := head.Hash()
 			if delFn != nil {
-				delFn(hash)
+				delFn(batch, hash)
+			}
+			DeleteHeader(batch, hash)
+			DeleteTd(batch, hash)
+		}
+		DeleteCanonicalHash(batch, n)
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				glog.Fatalf("failed to purge block data: %v", err)
 			}
-			DeleteHeader(hc.chainDb, hash)
-			DeleteTd(hc.chainDb, hash)
+			batch.Reset()
 		}
-		DeleteCanonicalHash(hc.chainDb, n)
+	}
+	if err := batch.Write(); err != nil {
+		glog.Fatalf("failed to purge block data: %v", err)
 	}
 }
 
 // SetHead rewinds the local chain to a new head. Everything above the new head
-// will be deleted and the new one set.
+// will be deleted and the new one set, in a single atomic batch.
 func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
+	batch := hc.chainDb.NewBatch()
+	hc.setHead(batch, head, delFn)
+	if err := batch.Write(); err != nil {
+		glog.Fatalf("failed to rewind header chain: %v", err)
+	}
+	hc.truncateAncients(head)
+}
+
+// setHead rewinds the in-memory header chain to a new head, adding the deletion
+// of everything above it and the new head header hash to the batch.
+func (hc *HeaderChain) setHead(batch ethdb.Batch, head uint64, delFn DeleteCallback) {
 	height := uint64(0)
 	if hc.currentHeader != nil {
 		height = hc.currentHeader.Number.Uint64()
@@ -526,15 +555,15 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 	for hc.currentHeader != nil && hc.currentHeader.Number.Uint64() > head {
 		hash := hc.currentHeader.Hash()
 		if delFn != nil {
-			delFn(hash)
+			delFn(batch, hash)
 		}
-		DeleteHeader(hc.chainDb, hash)
-		DeleteTd(hc.chainDb, hash)
+		DeleteHeader(batch, hash)
+		DeleteTd(batch, hash)
 		hc.currentHeader = hc.GetHeader(hc.currentHeader.ParentHash)
 	}
 	// Roll back the canonical chain numbering
 	for i := height; i > head; i-- {
-		DeleteCanonicalHash(hc.chainDb, i)
+		DeleteCanonicalHash(batch, i)
 	}
 	// Clear out any stale content from the caches
 	hc.headerCache.Purge()
@@ -545,11 +574,22 @@ func (hc *HeaderChain) SetHead(head uint64, delFn DeleteCallback) {
 	}
 	hc.currentHeaderHash = hc.currentHeader.Hash()
 
-	if err := WriteHeadHeaderHash(hc.chainDb, hc.currentHeaderHash); err != nil {
+	if err := WriteHeadHeaderHash(batch, hc.currentHeaderHash); err != nil {
 		glog.Fatalf("failed to reset head header hash: %v", err)
 	}
 }
 
+// truncateAncients drops any frozen blocks above the new head, they're not
+// canonical anymore. It's done after the rewind batch was written, so a crash
+// in between leaves surplus ancients rather than a chain with missing blocks.
+func (hc *HeaderChain) truncateAncients(head uint64) {
+	if store, ok := hc.chainDb.(ethdb.AncientStore); ok {
+		if err := store.TruncateAncients(head + 1); err != nil {
+			glog.Fatalf("failed to truncate ancient store: %v", err)
+		}
+	}
+}
+
 // SetGenesis sets a new genesis block header for the chain
 func (hc *HeaderChain) SetGenesis(head *types.Header) {
 	hc.genesisHeader = head
@@ -574,6 +614,13 @@ type headerValidator struct {
 	Pow    pow.PoW      // Proof of work used for validating
 }
 
+// NewHeaderValidator returns a HeaderValidator checking headers against the
+// given chain configuration and header chain, for header only chains such as
+// the light client's.
+func NewHeaderValidator(config *ChainConfig, hc *HeaderChain, pow pow.PoW) HeaderValidator {
+	return &headerValidator{config: config, hc: hc, Pow: pow}
+}
+
 // ValidateHeader validates the given header and, depending on the pow arg,
 // checks the proof of work of the given header. Returns an error if the
 // validation failed.
@@ -50,6 +50,8 @@ type Database interface {
 	ContractCodeSize(addrHash, codeHash common.Hash) (int, error)
 	// CopyTrie returns an independent copy of the given trie.
 	CopyTrie(Trie) Trie
+	// TrieDB returns the node cache tries are read through.
+	TrieDB() *trie.NodeCache
 }
 
 // Trie is a Ethereum Merkle Trie.
@@ -58,6 +60,7 @@ type Trie interface {
 	TryUpdate(key, value []byte) error
 	TryDelete(key []byte) error
 	CommitTo(trie.DatabaseWriter) (common.Hash, error)
+	CommitToWithCallback(trie.DatabaseWriter, trie.LeafCallback) (common.Hash, error)
 	Hash() common.Hash
 	NodeIterator(startKey []byte) trie.NodeIterator
 	GetKey([]byte) []byte // TODO(fjl): remove this when SecureTrie is removed
@@ -66,12 +69,20 @@ type Trie interface {
 // NewDatabase creates a backing store for state. The returned database is safe for
 // concurrent use and retains cached trie nodes in memory.
 func NewDatabase(db ethdb.Database) Database {
+	return NewDatabaseWithCache(trie.NewNodeCache(db))
+}
+
+// NewDatabaseWithCache creates a backing store for state reading its tries
+// through the given node cache, so that state committed into the cache but
+// not yet flushed to disk is accessible.
+func NewDatabaseWithCache(triedb *trie.NodeCache) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
-	return &cachingDB{db: db, codeSizeCache: csc}
+	return &cachingDB{db: triedb.DiskDB(), triedb: triedb, codeSizeCache: csc}
 }
 
 type cachingDB struct {
 	db            ethdb.Database
+	triedb        *trie.NodeCache
 	mu            sync.Mutex
 	pastTries     []*trie.SecureTrie
 	codeSizeCache *lru.Cache
@@ -86,7 +97,7 @@ func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
 			return cachedTrie{db.pastTries[i].Copy(), db}, nil
 		}
 	}
-	tr, err := trie.NewSecure(root, db.db, MaxTrieCacheGen)
+	tr, err := trie.NewSecure(root, db.triedb, MaxTrieCacheGen)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +117,7 @@ func (db *cachingDB) pushTrie(t *trie.SecureTrie) {
 }
 
 func (db *cachingDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
-	return trie.NewSecure(root, db.db, 0)
+	return trie.NewSecure(root, db.triedb, 0)
 }
 
 func (db *cachingDB) CopyTrie(t Trie) Trie {
@@ -120,6 +131,10 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 	}
 }
 
+func (db *cachingDB) TrieDB() *trie.NodeCache {
+	return db.triedb
+}
+
 func (db *cachingDB) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
 	code, err := db.db.Get(codeHash[:])
 	if err == nil {
@@ -146,7 +161,11 @@ type cachedTrie struct {
 }
 
 func (m cachedTrie) CommitTo(dbw trie.DatabaseWriter) (common.Hash, error) {
-	root, err := m.SecureTrie.CommitTo(dbw)
+	return m.CommitToWithCallback(dbw, nil)
+}
+
+func (m cachedTrie) CommitToWithCallback(dbw trie.DatabaseWriter, onleaf trie.LeafCallback) (common.Hash, error) {
+	root, err := m.SecureTrie.CommitToWithCallback(dbw, onleaf)
 	if err == nil {
 		m.db.pushTrie(m.SecureTrie)
 	}
@@ -0,0 +1,292 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+// pruneSweepKey tracks the last database key handled by an interrupted sweep.
+var pruneSweepKey = []byte("prune-state-sweep")
+
+var errNoPruneRoots = errors.New("no state roots to retain")
+
+// stateBloom is a bloom filter over trie node hashes. The hashes are already
+// uniformly distributed, so the filter indexes are taken from the hash itself.
+type stateBloom []uint64
+
+func newStateBloom(size uint64) stateBloom {
+	if size < 8 {
+		size = 8
+	}
+	return make(stateBloom, size/8)
+}
+
+func (b stateBloom) add(hash common.Hash) {
+	bits := uint64(len(b)) * 64
+	for i := 0; i < common.HashLength; i += 8 {
+		bit := binary.BigEndian.Uint64(hash[i:]) % bits
+		b[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b stateBloom) contains(hash []byte) bool {
+	bits := uint64(len(b)) * 64
+	for i := 0; i < common.HashLength; i += 8 {
+		bit := binary.BigEndian.Uint64(hash[i:]) % bits
+		if b[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// PruneStats reports the progress of a state sweep.
+type PruneStats struct {
+	Scanned uint64             // Number of database entries inspected
+	Deleted uint64             // Number of stale trie nodes (to be) deleted
+	Size    common.StorageSize // Size of the stale trie nodes
+}
+
+// Pruner deletes the state trie nodes not reachable from a set of retained
+// state roots from a database. Pruning happens in two phases: Mark
+// records every node of the retained states in a bloom filter, then Sweep
+// deletes all trie nodes missing from the filter. False positives of the
+// filter only cause a few stale nodes to be kept.
+//
+// The filter can be saved to disk after marking, so that an interrupted
+// sweep can be resumed without repeating the marking.
+type Pruner struct {
+	db    ethdb.Database
+	bloom stateBloom
+}
+
+// NewPruner creates a pruner with a bloom filter of the given size in bytes.
+func NewPruner(db ethdb.Database, bloomSize uint64) *Pruner {
+	return &Pruner{db: db, bloom: newStateBloom(bloomSize)}
+}
+
+// LoadPruner creates a pruner from a bloom filter saved by an earlier run.
+func LoadPruner(db ethdb.Database, path string) (*Pruner, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) == 0 || len(blob)%8 != 0 {
+		return nil, fmt.Errorf("invalid state bloom file %s: size %d", path, len(blob))
+	}
+	bloom := make(stateBloom, len(blob)/8)
+	for i := range bloom {
+		bloom[i] = binary.LittleEndian.Uint64(blob[i*8:])
+	}
+	return &Pruner{db: db, bloom: bloom}, nil
+}
+
+// Save writes the bloom filter of marked nodes to the given file.
+func (p *Pruner) Save(path string) error {
+	blob := make([]byte, len(p.bloom)*8)
+	for i, word := range p.bloom {
+		binary.LittleEndian.PutUint64(blob[i*8:], word)
+	}
+	return ioutil.WriteFile(path, blob, 0600)
+}
+
+// Mark adds every trie node and contract code reachable from the given state
+// roots to the bloom filter. The first root is iterated completely, every
+// following one only where it differs from its predecessor, so roots should
+// be given in chain order. The progress callback, if set, is invoked with the
+// total number of nodes marked so far.
+func (p *Pruner) Mark(roots []common.Hash, progress func(marked uint64)) error {
+	if len(roots) == 0 {
+		return errNoPruneRoots
+	}
+	var marked uint64
+	mark := func(hash common.Hash) {
+		p.bloom.add(hash)
+		if marked++; progress != nil {
+			progress(marked)
+		}
+	}
+	// Mark the full first state
+	statedb, err := New(roots[0], NewDatabase(p.db))
+	if err != nil {
+		return err
+	}
+	it := NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash != (common.Hash{}) {
+			mark(it.Hash)
+		}
+	}
+	if it.Error != nil {
+		return it.Error
+	}
+	// Mark all the changes introduced by the subsequent states
+	for i := 1; i < len(roots); i++ {
+		if err := p.markDiff(roots[i-1], roots[i], mark); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markDiff marks the account trie nodes, storage trie nodes and contract code
+// of state root which aren't present at the same position in state parent.
+func (p *Pruner) markDiff(parent, root common.Hash, mark func(common.Hash)) error {
+	oldTrie, err := trie.New(parent, p.db)
+	if err != nil {
+		return err
+	}
+	newTrie, err := trie.New(root, p.db)
+	if err != nil {
+		return err
+	}
+	it, _ := trie.NewDifferenceIterator(oldTrie.NodeIterator(nil), newTrie.NodeIterator(nil))
+	for it.Next(true) {
+		if it.Hash() != (common.Hash{}) {
+			mark(it.Hash())
+		}
+		if !it.Leaf() {
+			continue
+		}
+		// A new or modified account, mark its storage and code changes
+		var account, old Account
+		if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+			return err
+		}
+		if !bytes.Equal(account.CodeHash, emptyCodeHash) {
+			mark(common.BytesToHash(account.CodeHash))
+		}
+		oldRoot := common.Hash{}
+		if blob, err := oldTrie.TryGet(it.LeafKey()); err != nil {
+			return err
+		} else if blob != nil {
+			if err := rlp.DecodeBytes(blob, &old); err != nil {
+				return err
+			}
+			oldRoot = old.Root
+		}
+		if oldRoot == account.Root {
+			continue
+		}
+		if err := p.markStorageDiff(oldRoot, account.Root, mark); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// markStorageDiff marks the nodes of the storage trie root which aren't present
+// at the same position in storage trie parent.
+func (p *Pruner) markStorageDiff(parent, root common.Hash, mark func(common.Hash)) error {
+	oldTrie, err := trie.New(parent, p.db)
+	if err != nil {
+		return err
+	}
+	newTrie, err := trie.New(root, p.db)
+	if err != nil {
+		return err
+	}
+	it, _ := trie.NewDifferenceIterator(oldTrie.NodeIterator(nil), newTrie.NodeIterator(nil))
+	for it.Next(true) {
+		if it.Hash() != (common.Hash{}) {
+			mark(it.Hash())
+		}
+	}
+	return it.Error()
+}
+
+// isTrieNode reports whether the database entry is a trie node, i.e. it's
+// stored under its own hash and encodes a short or full node. Other entries
+// keyed by hash, like transactions and contract code, are never deleted.
+func isTrieNode(key, value []byte) bool {
+	if len(key) != common.HashLength {
+		return false
+	}
+	content, _, err := rlp.SplitList(value)
+	if err != nil {
+		return false
+	}
+	if n, err := rlp.CountValues(content); err != nil || (n != 2 && n != 17) {
+		return false
+	}
+	return bytes.Equal(crypto.Keccak256(value), key)
+}
+
+// Sweep deletes every trie node not marked in the bloom filter. In dry run
+// mode nothing is deleted and the returned statistics report what would be
+// reclaimed. Sweeping records its position in the database, so an
+// interrupted sweep continues where it left off. The progress callback, if
+// set, is invoked after every scanned entry.
+func (p *Pruner) Sweep(dryRun bool, progress func(PruneStats)) (PruneStats, error) {
+	var (
+		stats PruneStats
+		start []byte
+		batch = p.db.NewBatch()
+	)
+	if !dryRun {
+		if pos, err := p.db.Get(pruneSweepKey); err == nil {
+			start = pos
+		}
+	}
+	it := p.db.NewIterator(nil, start)
+	defer it.Release()
+
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		stats.Scanned++
+
+		if isTrieNode(key, value) && !p.bloom.contains(key) {
+			stats.Deleted++
+			stats.Size += common.StorageSize(len(key) + len(value))
+
+			if !dryRun {
+				batch.Delete(key)
+				if batch.ValueSize() >= ethdb.IdealBatchSize {
+					batch.Put(pruneSweepKey, key)
+					if err := batch.Write(); err != nil {
+						return stats, err
+					}
+					batch.Reset()
+				}
+			}
+		}
+		if progress != nil {
+			progress(stats)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return stats, err
+	}
+	if !dryRun {
+		batch.Delete(pruneSweepKey)
+		if err := batch.Write(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+)
+
+// makePrunableStates commits a sequence of states into db, each one modifying
+// balances and storage of the previous one, and returns their roots.
+func makePrunableStates(t *testing.T, db ethdb.Database, n int) []common.Hash {
+	var (
+		roots []common.Hash
+		root  common.Hash
+	)
+	for i := 0; i < n; i++ {
+		state, err := New(root, NewDatabase(db))
+		if err != nil {
+			t.Fatalf("failed to open state %x: %v", root, err)
+		}
+		for j := byte(0); j < 32; j++ {
+			addr := common.BytesToAddress([]byte{j})
+			state.AddBalance(addr, big.NewInt(int64(i+1)))
+			if j%4 == 0 {
+				state.SetCode(addr, []byte{j, j, j})
+				state.SetState(addr, common.BytesToHash([]byte{byte(i)}), common.BytesToHash([]byte{j, byte(i) + 1}))
+			}
+		}
+		if root, err = state.CommitTo(db, false); err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+func TestPruneState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prune-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.NewLDBDatabase(filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	roots := makePrunableStates(t, db, 8)
+
+	// Entries keyed by their hash which aren't trie nodes must survive
+	tx := []byte{0xc9, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	db.Put(crypto.Keccak256(tx), tx)
+
+	pruner := NewPruner(db, 1024*1024)
+	if err := pruner.Mark(roots[5:], nil); err != nil {
+		t.Fatalf("failed to mark state: %v", err)
+	}
+	// A dry run must report stale nodes without deleting them
+	dry, err := pruner.Sweep(true, nil)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if dry.Deleted == 0 || dry.Size == 0 {
+		t.Fatalf("dry run found nothing to prune: %+v", dry)
+	}
+	if err := checkStateConsistency(db, roots[0]); err != nil {
+		t.Fatalf("dry run deleted state: %v", err)
+	}
+	// Save and reload the marks, as an interrupted run would
+	bloomPath := filepath.Join(dir, "prune-state.bloom")
+	if err := pruner.Save(bloomPath); err != nil {
+		t.Fatalf("failed to save bloom: %v", err)
+	}
+	if pruner, err = LoadPruner(db, bloomPath); err != nil {
+		t.Fatalf("failed to load bloom: %v", err)
+	}
+	stats, err := pruner.Sweep(false, nil)
+	if err != nil {
+		t.Fatalf("failed to sweep state: %v", err)
+	}
+	if stats.Deleted != dry.Deleted || stats.Size != dry.Size {
+		t.Errorf("sweep mismatch: have %+v, dry run %+v", stats, dry)
+	}
+	for i, root := range roots {
+		_, err := New(root, NewDatabase(db))
+		if i < 5 {
+			if err == nil {
+				t.Errorf("pruned state %d still present", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("retained state %d missing: %v", i, err)
+		} else if err := checkStateConsistency(db, root); err != nil {
+			t.Errorf("retained state %d inconsistent: %v", i, err)
+		}
+	}
+	if val, _ := db.Get(crypto.Keccak256(tx)); val == nil {
+		t.Errorf("non trie entry deleted")
+	}
+	if _, err := db.Get(pruneSweepKey); err == nil {
+		t.Errorf("sweep progress marker left behind")
+	}
+}
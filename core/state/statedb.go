@@ -113,6 +113,12 @@ func New(root common.Hash, db Database) (*StateDB, error) {
 	}, nil
 }
 
+// Error returns the first error encountered while accessing the database
+// backing the state, e.g. a trie node that couldn't be retrieved.
+func (self *StateDB) Error() error {
+	return self.dbErr
+}
+
 // setError remembers the first non-nil error it is called with.
 func (self *StateDB) setError(err error) {
 	if self.dbErr == nil {
@@ -565,9 +571,20 @@ func (s *StateDB) clearJournalAndRefund() {
 }
 
 // CommitTo writes the state to the given database.
+//
+// If dbw is a trie.NodeCache, the committed nodes are kept in memory and the
+// storage tries are referenced from the account trie nodes holding them, so
+// that the whole state can be garbage collected by dereferencing its root.
 func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (root common.Hash, err error) {
 	defer s.clearJournalAndRefund()
 
+	// Contract code isn't reference counted, so it always goes to disk.
+	codedb := dbw
+	cache, _ := dbw.(*trie.NodeCache)
+	if cache != nil {
+		codedb = cache.DiskDB()
+	}
+
 	// Commit objects to the trie.
 	for addr, stateObject := range s.stateObjects {
 		_, isDirty := s.stateObjectsDirty[addr]
@@ -579,7 +596,7 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 		case isDirty:
 			// Write any contract code associated with the state object
 			if stateObject.code != nil && stateObject.dirtyCode {
-				if err := dbw.Put(stateObject.CodeHash(), stateObject.code); err != nil {
+				if err := codedb.Put(stateObject.CodeHash(), stateObject.code); err != nil {
 					return common.Hash{}, err
 				}
 				stateObject.dirtyCode = false
@@ -594,7 +611,18 @@ func (s *StateDB) CommitTo(dbw trie.DatabaseWriter, deleteEmptyObjects bool) (ro
 		delete(s.stateObjectsDirty, addr)
 	}
 	// Write trie changes.
-	root, err = s.trie.CommitTo(dbw)
+	var onleaf trie.LeafCallback
+	if cache != nil {
+		onleaf = func(leaf []byte, parent common.Hash) error {
+			var account Account
+			if err := rlp.DecodeBytes(leaf, &account); err != nil {
+				return nil
+			}
+			cache.Reference(account.Root, parent)
+			return nil
+		}
+	}
+	root, err = s.trie.CommitToWithCallback(dbw, onleaf)
 	glog.V(logger.Debug).Infoln("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
 	return root, err
 }
@@ -64,6 +64,17 @@ func NewStateProcessor(config *ChainConfig, bc *BlockChain) *StateProcessor {
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
 func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (types.Receipts, vm.Logs, *big.Int, error) {
+	return p.process(block, statedb, nil)
+}
+
+// Trace processes the block like Process, but executes every transaction
+// with the vm configuration returned by cfgFn for it, e.g. to attach a
+// tracer. Traced blocks always run on the native EVM.
+func (p *StateProcessor) Trace(block *types.Block, statedb *state.StateDB, cfgFn func(index int, tx *types.Transaction) vm.Config) (types.Receipts, vm.Logs, *big.Int, error) {
+	return p.process(block, statedb, cfgFn)
+}
+
+func (p *StateProcessor) process(block *types.Block, statedb *state.StateDB, cfgFn func(int, *types.Transaction) vm.Config) (types.Receipts, vm.Logs, *big.Int, error) {
 	var (
 		receipts     types.Receipts
 		totalUsedGas = big.NewInt(0)
@@ -84,8 +95,15 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 			}
 		}
 		statedb.StartRecord(tx.Hash(), block.Hash(), i)
-		if UseSputnikVM != "true" {
-			receipt, logs, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas)
+		// SputnikVM does not implement the atlantis rules nor the additional
+		// precompiled contracts, blocks after those forks always run on the
+		// native EVM.
+		if UseSputnikVM != "true" || cfgFn != nil || p.config.IsAtlantis(header.Number) || p.config.HasPrecompiles(header.Number) {
+			var cfg vm.Config
+			if cfgFn != nil {
+				cfg = cfgFn(i, tx)
+			}
+			receipt, logs, _, err := ApplyTransaction(p.config, p.bc, gp, statedb, header, tx, totalUsedGas, cfg)
 			if err != nil {
 				return nil, nil, totalUsedGas, err
 			}
@@ -110,17 +128,25 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB) (ty
 //
 // ApplyTransactions returns the generated receipts and vm logs during the
 // execution of the state transition phase.
-func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int) (*types.Receipt, vm.Logs, *big.Int, error) {
+func ApplyTransaction(config *ChainConfig, bc *BlockChain, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, cfg vm.Config) (*types.Receipt, vm.Logs, *big.Int, error) {
 	tx.SetSigner(config.GetSigner(header.Number))
 
-	_, gas, failed, err := ApplyMessage(NewEnv(statedb, config, bc, tx, header), tx, gp)
+	_, gas, failed, err := ApplyMessage(NewEnv(statedb, config, bc, tx, header, cfg), tx, gp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	// Update the state with pending changes
+	// Update the state with pending changes. After the atlantis fork the
+	// receipt commits to the status of the transaction instead of the
+	// intermediate state root (EIP-658).
 	usedGas.Add(usedGas, gas)
-	receipt := types.NewReceipt(statedb.IntermediateRoot(false).Bytes(), usedGas)
+	var root []byte
+	if config.IsAtlantis(header.Number) {
+		statedb.Finalise(false)
+	} else {
+		root = statedb.IntermediateRoot(false).Bytes()
+	}
+	receipt := types.NewReceipt(root, usedGas)
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = new(big.Int).Set(gas)
 	if MessageCreatesContract(tx) {
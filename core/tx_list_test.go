@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+)
+
+// Tests that transactions can be added to strict lists and list contents and
+// nonce boundaries are correctly maintained.
+func TestStrictTxListAdd(t *testing.T) {
+	// Generate a list of transactions to insert
+	key, _ := crypto.GenerateKey()
+
+	txs := make(types.Transactions, 1024)
+	for i := 0; i < len(txs); i++ {
+		txs[i] = transaction(uint64(i), new(big.Int), key)
+	}
+	// Insert the transactions in a random order
+	list := newTxList(true)
+	for _, v := range rand.Perm(len(txs)) {
+		list.Add(txs[v], DefaultTxPoolConfig.PriceBump)
+	}
+	// Verify internal state
+	if len(list.txs.items) != len(txs) {
+		t.Errorf("transaction count mismatch: have %d, want %d", len(list.txs.items), len(txs))
+	}
+	for i, tx := range txs {
+		if list.txs.items[tx.Nonce()] != tx {
+			t.Errorf("item %d: transaction mismatch: have %v, want %v", i, list.txs.items[tx.Nonce()], tx)
+		}
+	}
+}
+
+// Tests that removing a transaction from a strict list also removes all the
+// transactions following it, and that capping drops the highest nonces.
+func TestStrictTxListRemoveAndCap(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	list := newTxList(true)
+	txs := make(types.Transactions, 10)
+	for i := 0; i < len(txs); i++ {
+		txs[i] = transaction(uint64(i), new(big.Int), key)
+		list.Add(txs[i], DefaultTxPoolConfig.PriceBump)
+	}
+	if drops := list.Cap(8); len(drops) != 2 || drops[0] != txs[9] || drops[1] != txs[8] {
+		t.Fatalf("capped transactions mismatch: have %v", drops)
+	}
+	removed, invalids := list.Remove(txs[5])
+	if !removed {
+		t.Fatalf("transaction not removed")
+	}
+	if len(invalids) != 2 {
+		t.Fatalf("invalidated transaction count mismatch: have %d, want %d", len(invalids), 2)
+	}
+	if ready := list.Ready(0); len(ready) != 5 {
+		t.Fatalf("ready transaction count mismatch: have %d, want %d", len(ready), 5)
+	}
+	if !list.Empty() {
+		t.Fatalf("list not empty after retrieving all transactions: %d left", list.Len())
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/event"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
 var (
@@ -43,14 +44,70 @@ var (
 	ErrIntrinsicGas       = errors.New("Intrinsic gas too low")
 	ErrGasLimit           = errors.New("Exceeds block gas limit")
 	ErrNegativeValue      = errors.New("Negative value")
-)
-
-const (
-	maxQueued = 64 // max limit of queued txs per address
+	ErrUnderpriced        = errors.New("Transaction underpriced")
+	ErrReplaceUnderpriced = errors.New("Replacement transaction underpriced")
 )
 
 type stateFn func() (*state.StateDB, error)
 
+// TxPoolConfig are the configuration parameters of the transaction pool.
+type TxPoolConfig struct {
+	Journal   string        // Journal of local transactions to survive node restarts
+	Rejournal time.Duration // Time interval to regenerate the local transaction journal
+
+	PriceBump uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
+
+	AccountSlots uint64 // Minimum number of executable transaction slots guaranteed per account
+	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
+	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
+	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
+}
+
+// DefaultTxPoolConfig contains the default configurations for the transaction
+// pool.
+var DefaultTxPoolConfig = TxPoolConfig{
+	Journal:   "transactions.rlp",
+	Rejournal: time.Hour,
+
+	PriceBump: 10,
+
+	AccountSlots: 16,
+	GlobalSlots:  4096,
+	AccountQueue: 64,
+	GlobalQueue:  1024,
+}
+
+// sanitize checks the provided user configurations and changes anything that's
+// unreasonable or unworkable.
+func (config *TxPoolConfig) sanitize() TxPoolConfig {
+	conf := *config
+	if conf.Rejournal < time.Second {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool journal time: have %v, updated %v", conf.Rejournal, time.Second)
+		conf.Rejournal = time.Second
+	}
+	if conf.PriceBump < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool price bump: have %d, updated %d", conf.PriceBump, DefaultTxPoolConfig.PriceBump)
+		conf.PriceBump = DefaultTxPoolConfig.PriceBump
+	}
+	if conf.AccountSlots < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool account slots: have %d, updated %d", conf.AccountSlots, DefaultTxPoolConfig.AccountSlots)
+		conf.AccountSlots = DefaultTxPoolConfig.AccountSlots
+	}
+	if conf.GlobalSlots < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool global slots: have %d, updated %d", conf.GlobalSlots, DefaultTxPoolConfig.GlobalSlots)
+		conf.GlobalSlots = DefaultTxPoolConfig.GlobalSlots
+	}
+	if conf.AccountQueue < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool account queue: have %d, updated %d", conf.AccountQueue, DefaultTxPoolConfig.AccountQueue)
+		conf.AccountQueue = DefaultTxPoolConfig.AccountQueue
+	}
+	if conf.GlobalQueue < 1 {
+		glog.V(logger.Warn).Infof("Sanitizing invalid txpool global queue: have %d, updated %d", conf.GlobalQueue, DefaultTxPoolConfig.GlobalQueue)
+		conf.GlobalQueue = DefaultTxPoolConfig.GlobalQueue
+	}
+	return conf
+}
+
 // TxPool contains all currently known transactions. Transactions
 // enter the pool when they are received from the network or submitted
 // locally. They exit the pool when they are included in the blockchain.
@@ -58,40 +115,75 @@ type stateFn func() (*state.StateDB, error)
 // The pool separates processable transactions (which can be applied to the
 // current state) and future transactions. Transactions move between those
 // two states over time as they are received and processed.
+//
+// The number of transactions kept is bounded by the pool configuration. When
+// the pool is full, the cheapest remote transactions are evicted to make room
+// for better paying ones; transactions of local accounts are never evicted and
+// are journaled to disk to survive restarts.
 type TxPool struct {
 	config       *ChainConfig
+	poolConfig   TxPoolConfig
 	signer       types.Signer
 	currentState stateFn // The state function which will allow us to do some pre checks
 	pendingState *state.ManagedState
 	gasLimit     func() *big.Int // The current gas limit function callback
 	minGasPrice  *big.Int
 	eventMux     *event.TypeMux
-	events       event.Subscription
-	localTx      *txSet
+	events       event.TypeMuxSubscription
+	locals       *accountSet // Set of local accounts to exempt from eviction rules
+	journal      *txJournal  // Journal of local transactions to back up to disk
 	mu           sync.RWMutex
-	pending      map[common.Hash]*types.Transaction // processable transactions
-	queue        map[common.Address]map[common.Hash]*types.Transaction
 
-	wg sync.WaitGroup // for shutdown sync
+	pending map[common.Address]*txList         // All currently processable transactions
+	queue   map[common.Address]*txList         // Queued but non-processable transactions
+	beats   map[common.Address]time.Time       // Last heartbeat from each known account
+	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
+	priced  *txPricedList                      // All transactions sorted by price
+
+	wg   sync.WaitGroup // for shutdown sync
+	quit chan struct{}
 
 	homestead bool
 }
 
-func NewTxPool(config *ChainConfig, eventMux *event.TypeMux, currentStateFn stateFn, gasLimitFn func() *big.Int) *TxPool {
+func NewTxPool(config *ChainConfig, poolConfig TxPoolConfig, eventMux *event.TypeMux, currentStateFn stateFn, gasLimitFn func() *big.Int) *TxPool {
+	// Sanitize the input to ensure no unworkable limits are set
+	poolConfig = (&poolConfig).sanitize()
+
+	signer := types.NewChainIdSigner(config.GetChainID())
 	pool := &TxPool{
 		config:       config,
-		signer:       types.NewChainIdSigner(config.GetChainID()),
-		pending:      make(map[common.Hash]*types.Transaction),
-		queue:        make(map[common.Address]map[common.Hash]*types.Transaction),
+		poolConfig:   poolConfig,
+		signer:       signer,
+		pending:      make(map[common.Address]*txList),
+		queue:        make(map[common.Address]*txList),
+		beats:        make(map[common.Address]time.Time),
+		all:          make(map[common.Hash]*types.Transaction),
 		eventMux:     eventMux,
 		currentState: currentStateFn,
 		gasLimit:     gasLimitFn,
 		minGasPrice:  new(big.Int),
 		pendingState: nil,
-		localTx:      newTxSet(),
+		locals:       newAccountSet(signer),
 		events:       eventMux.Subscribe(ChainHeadEvent{}, GasPriceChanged{}, RemovedTransactionEvent{}),
+		quit:         make(chan struct{}),
 	}
+	pool.priced = newTxPricedList(&pool.all)
+	pool.resetState()
 
+	// If journaling is enabled, load the local transactions from disk
+	if poolConfig.Journal != "" {
+		pool.journal = newTxJournal(poolConfig.Journal)
+
+		if err := pool.journal.load(pool.addLocal); err != nil {
+			glog.V(logger.Warn).Infof("Failed to load transaction journal: %v", err)
+		}
+		if err := pool.journal.rotate(pool.local()); err != nil {
+			glog.V(logger.Warn).Infof("Failed to rotate transaction journal: %v", err)
+		}
+		pool.wg.Add(1)
+		go pool.journalLoop()
+	}
 	pool.wg.Add(1)
 	go pool.eventLoop()
 
@@ -117,6 +209,9 @@ func (pool *TxPool) eventLoop() {
 		case GasPriceChanged:
 			pool.mu.Lock()
 			pool.minGasPrice = ev.Price
+			for _, tx := range pool.priced.Cap(ev.Price, pool.locals) {
+				pool.removeTx(tx.Hash())
+			}
 			pool.mu.Unlock()
 		case RemovedTransactionEvent:
 			pool.AddTransactions(ev.Txs)
@@ -124,44 +219,61 @@ func (pool *TxPool) eventLoop() {
 	}
 }
 
+// journalLoop periodically regenerates the journal of local transactions, so
+// that it doesn't grow indefinitely with replaced and included transactions.
+func (pool *TxPool) journalLoop() {
+	defer pool.wg.Done()
+
+	journal := time.NewTicker(pool.poolConfig.Rejournal)
+	defer journal.Stop()
+
+	for {
+		select {
+		case <-journal.C:
+			pool.mu.Lock()
+			if err := pool.journal.rotate(pool.local()); err != nil {
+				glog.V(logger.Warn).Infof("Failed to rotate local transaction journal: %v", err)
+			}
+			pool.mu.Unlock()
+
+		case <-pool.quit:
+			return
+		}
+	}
+}
+
 func (pool *TxPool) resetState() {
 	currentState, err := pool.currentState()
 	if err != nil {
-		glog.V(logger.Info).Infoln("failed to get current state: %v", err)
+		glog.V(logger.Info).Infof("failed to get current state: %v", err)
 		return
 	}
-	managedState := state.ManageState(currentState)
-	if err != nil {
-		glog.V(logger.Info).Infoln("failed to get managed state: %v", err)
-		return
-	}
-	pool.pendingState = managedState
+	pool.pendingState = state.ManageState(currentState)
 
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
 	// have been invalidated because of another transaction (e.g.
 	// higher gas price)
-	pool.validatePool()
-
-	// Loop over the pending transactions and base the nonce of the new
-	// pending transaction set.
-	for _, tx := range pool.pending {
-		if addr, err := tx.From(); err == nil {
-			// Set the nonce. Transaction nonce can never be lower
-			// than the state nonce; validatePool took care of that.
-			if pool.pendingState.GetNonce(addr) <= tx.Nonce() {
-				pool.pendingState.SetNonce(addr, tx.Nonce()+1)
-			}
-		}
+	pool.demoteUnexecutables(currentState)
+
+	// Update all accounts to the latest known pending nonce
+	for addr, list := range pool.pending {
+		txs := list.Flatten() // Heavy but will be cached and is needed by the miner anyway
+		pool.pendingState.SetNonce(addr, txs[len(txs)-1].Nonce()+1)
 	}
 	// Check the queue and move transactions over to the pending if possible
 	// or remove those that have become invalid
-	pool.checkQueue()
+	pool.promoteExecutables(nil)
 }
 
 func (pool *TxPool) Stop() {
 	pool.events.Unsubscribe()
+	close(pool.quit)
 	pool.wg.Wait()
+
+	if pool.journal != nil {
+		pool.journal.close()
+	}
 	glog.V(logger.Info).Infoln("Transaction pool stopped")
 }
 
@@ -176,9 +288,11 @@ func (pool *TxPool) Stats() (pending int, queued int) {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
-	pending = len(pool.pending)
-	for _, txs := range pool.queue {
-		queued += len(txs)
+	for _, list := range pool.pending {
+		pending += list.Len()
+	}
+	for _, list := range pool.queue {
+		queued += list.Len()
 	}
 	return
 }
@@ -189,42 +303,52 @@ func (pool *TxPool) Content() (map[common.Address]map[uint64][]*types.Transactio
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
-	// Retrieve all the pending transactions and sort by account and by nonce
-	pending := make(map[common.Address]map[uint64][]*types.Transaction)
-	for _, tx := range pool.pending {
-		account, _ := tx.From()
-
-		owned, ok := pending[account]
-		if !ok {
-			owned = make(map[uint64][]*types.Transaction)
-			pending[account] = owned
-		}
-		owned[tx.Nonce()] = append(owned[tx.Nonce()], tx)
-	}
-	// Retrieve all the queued transactions and sort by account and by nonce
-	queued := make(map[common.Address]map[uint64][]*types.Transaction)
-	for account, txs := range pool.queue {
-		owned := make(map[uint64][]*types.Transaction)
-		for _, tx := range txs {
-			owned[tx.Nonce()] = append(owned[tx.Nonce()], tx)
+	group := func(lists map[common.Address]*txList) map[common.Address]map[uint64][]*types.Transaction {
+		content := make(map[common.Address]map[uint64][]*types.Transaction)
+		for account, list := range lists {
+			owned := make(map[uint64][]*types.Transaction)
+			for _, tx := range list.Flatten() {
+				owned[tx.Nonce()] = append(owned[tx.Nonce()], tx)
+			}
+			content[account] = owned
 		}
-		queued[account] = owned
+		return content
 	}
-	return pending, queued
+	return group(pool.pending), group(pool.queue)
 }
 
-// SetLocal marks a transaction as local, skipping gas price
-//  check against local miner minimum in the future
+// SetLocal marks the sender of a transaction as a local account. Transactions
+// of local accounts skip the gas price check against the local miner minimum,
+// are never evicted to make room for others and are kept in the journal.
 func (pool *TxPool) SetLocal(tx *types.Transaction) {
+	from, err := types.Sender(pool.signer, tx)
+	if err != nil {
+		return
+	}
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
-	pool.localTx.add(tx.Hash())
+	pool.locals.add(from)
+}
+
+// local retrieves all currently known local transactions, grouped by origin
+// account and sorted by nonce.
+func (pool *TxPool) local() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for addr := range pool.locals.accounts {
+		if pending := pool.pending[addr]; pending != nil {
+			txs[addr] = append(txs[addr], pending.Flatten()...)
+		}
+		if queued := pool.queue[addr]; queued != nil {
+			txs[addr] = append(txs[addr], queued.Flatten()...)
+		}
+	}
+	return txs
 }
 
 // validateTx checks whether a transaction is valid according
 // to the consensus rules.
 func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
-	local := pool.localTx.contains(tx.Hash())
+	local := pool.locals.containsTx(tx)
 	defer func() {
 		mlogTxPoolValidateTx.AssignDetails(
 			tx.Hash().Hex(),
@@ -298,18 +422,57 @@ func (pool *TxPool) validateTx(tx *types.Transaction) (e error) {
 	return // e=nil
 }
 
-// validate and queue transactions.
+// validate and queue transactions. If the transaction has the same nonce as
+// an already pending one, it replaces it directly, provided it pays enough.
 func (self *TxPool) add(tx *types.Transaction) error {
 	hash := tx.Hash()
 
-	if self.pending[hash] != nil {
+	if self.all[hash] != nil {
 		return fmt.Errorf("Known transaction (%x)", hash[:4])
 	}
 	err := self.validateTx(tx)
 	if err != nil {
 		return err
 	}
-	self.queueTx(hash, tx)
+	// If the transaction pool is full, discard underpriced transactions
+	if uint64(len(self.all)) >= self.poolConfig.GlobalSlots+self.poolConfig.GlobalQueue {
+		// If the new transaction is underpriced, don't accept it
+		if self.priced.Underpriced(tx, self.locals) {
+			if glog.V(logger.Detail) {
+				glog.Infof("Discarding underpriced transaction %x, price %v", hash, tx.GasPrice())
+			}
+			return ErrUnderpriced
+		}
+		// New transaction is better than our worse ones, make room for it
+		drop := self.priced.Discard(len(self.all)-int(self.poolConfig.GlobalSlots+self.poolConfig.GlobalQueue-1), self.locals)
+		for _, tx := range drop {
+			if glog.V(logger.Detail) {
+				glog.Infof("Discarding freshly underpriced transaction %x, price %v", tx.Hash(), tx.GasPrice())
+			}
+			self.removeTx(tx.Hash())
+		}
+	}
+	// we can ignore the error here because From is
+	// verified in ValidateTransaction.
+	f, _ := types.Sender(self.signer, tx)
+
+	if list := self.pending[f]; list != nil && list.Overlaps(tx) {
+		// The transaction replaces an already pending one, swap them directly
+		inserted, old := list.Add(tx, self.poolConfig.PriceBump)
+		if !inserted {
+			return ErrReplaceUnderpriced
+		}
+		delete(self.all, old.Hash())
+		self.priced.Removed()
+
+		self.all[hash] = tx
+		self.priced.Put(tx)
+
+		go self.eventMux.Post(TxPreEvent{tx})
+	} else if err := self.enqueueTx(hash, tx); err != nil {
+		return err
+	}
+	self.journalTx(f, tx)
 
 	var toName, toLogName string
 	if to := tx.To(); to != nil {
@@ -319,9 +482,6 @@ func (self *TxPool) add(tx *types.Transaction) error {
 		toName = "[NEW_CONTRACT]"
 		toLogName = "[NEW_CONTRACT]"
 	}
-	// we can ignore the error here because From is
-	// verified in ValidateTransaction.
-	f, _ := types.Sender(self.signer, tx)
 	from := common.Bytes2Hex(f[:4])
 
 	if logger.MlogEnabled() {
@@ -339,33 +499,90 @@ func (self *TxPool) add(tx *types.Transaction) error {
 	return nil
 }
 
-// queueTx will queue an unknown transaction
-func (self *TxPool) queueTx(hash common.Hash, tx *types.Transaction) {
-	from, _ := types.Sender(self.signer, tx) // already validated
-	if self.queue[from] == nil {
-		self.queue[from] = make(map[common.Hash]*types.Transaction)
+// addLocal marks the sender of a transaction as local and adds the transaction
+// to the pool. It is used to reload the transaction journal.
+func (pool *TxPool) addLocal(tx *types.Transaction) error {
+	pool.SetLocal(tx)
+	return pool.Add(tx)
+}
+
+// enqueueTx inserts a new transaction into the non-executable transaction queue.
+func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) error {
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	if pool.queue[from] == nil {
+		pool.queue[from] = newTxList(false)
+	}
+	inserted, old := pool.queue[from].Add(tx, pool.poolConfig.PriceBump)
+	if !inserted {
+		// An older transaction was better, forget this one if it was being demoted
+		if pool.all[hash] != nil {
+			delete(pool.all, hash)
+			pool.priced.Removed()
+		}
+		return ErrReplaceUnderpriced
+	}
+	// Discard any previous transaction and mark this
+	if old != nil {
+		delete(pool.all, old.Hash())
+		pool.priced.Removed()
+	}
+	if pool.all[hash] == nil {
+		pool.all[hash] = tx
+		pool.priced.Put(tx)
+	}
+	if _, ok := pool.beats[from]; !ok {
+		pool.beats[from] = time.Now()
 	}
-	self.queue[from][hash] = tx
+	return nil
 }
 
-// addTx will add a transaction to the pending (processable queue) list of transactions
-func (pool *TxPool) addTx(hash common.Hash, addr common.Address, tx *types.Transaction) {
-	// init delayed since tx pool could have been started before any state sync
-	if pool.pendingState == nil {
-		pool.resetState()
+// journalTx adds the specified transaction to the local disk journal if it is
+// deemed to have been sent from a local account.
+func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
+	// Only journal if it's enabled and the transaction is local
+	if pool.journal == nil || !pool.locals.contains(from) {
+		return
 	}
+	if err := pool.journal.insert(tx); err != nil {
+		glog.V(logger.Warn).Infof("Failed to journal local transaction %x: %v", tx.Hash(), err)
+	}
+}
 
-	if _, ok := pool.pending[hash]; !ok {
-		pool.pending[hash] = tx
+// promoteTx adds a transaction to the pending (processable) list of transactions.
+func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.Transaction) {
+	// Try to insert the transaction into the pending queue
+	if pool.pending[addr] == nil {
+		pool.pending[addr] = newTxList(true)
+	}
+	list := pool.pending[addr]
 
-		// Increment the nonce on the pending state. This can only happen if
-		// the nonce is +1 to the previous one.
-		pool.pendingState.SetNonce(addr, tx.Nonce()+1)
-		// Notify the subscribers. This event is posted in a goroutine
-		// because it's possible that somewhere during the post "Remove transaction"
-		// gets called which will then wait for the global tx pool lock and deadlock.
-		go pool.eventMux.Post(TxPreEvent{tx})
+	inserted, old := list.Add(tx, pool.poolConfig.PriceBump)
+	if !inserted {
+		// An older transaction was better, discard this
+		delete(pool.all, hash)
+		pool.priced.Removed()
+		return
+	}
+	// Otherwise discard any previous transaction and mark this
+	if old != nil {
+		delete(pool.all, old.Hash())
+		pool.priced.Removed()
+	}
+	// Failsafe to work around direct pending inserts (tests)
+	if pool.all[hash] == nil {
+		pool.all[hash] = tx
+		pool.priced.Put(tx)
 	}
+	pool.beats[addr] = time.Now()
+
+	// Increment the nonce on the pending state. This can only happen if
+	// the nonce is +1 to the previous one.
+	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
+
+	// Notify the subscribers. This event is posted in a goroutine
+	// because it's possible that somewhere during the post "Remove transaction"
+	// gets called which will then wait for the global tx pool lock and deadlock.
+	go pool.eventMux.Post(TxPreEvent{tx})
 }
 
 // Add queues a single transaction in the pool if it is valid.
@@ -376,7 +593,8 @@ func (self *TxPool) Add(tx *types.Transaction) error {
 	if err := self.add(tx); err != nil {
 		return err
 	}
-	self.checkQueue()
+	from, _ := types.Sender(self.signer, tx) // already validated
+	self.promoteExecutables([]common.Address{from})
 	return nil
 }
 
@@ -385,17 +603,26 @@ func (self *TxPool) AddTransactions(txs []*types.Transaction) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
+	dirty := make(map[common.Address]struct{})
 	for _, tx := range txs {
 		if err := self.add(tx); err != nil {
 			glog.V(logger.Debug).Infoln("tx error:", err)
 		} else {
 			h := tx.Hash()
 			glog.V(logger.Debug).Infof("tx %x\n", h[:4])
+
+			from, _ := types.Sender(self.signer, tx) // already validated
+			dirty[from] = struct{}{}
 		}
 	}
-
-	// check and validate the queue
-	self.checkQueue()
+	// Only reprocess the internal state if something was actually added
+	if len(dirty) > 0 {
+		addrs := make([]common.Address, 0, len(dirty))
+		for addr := range dirty {
+			addrs = append(addrs, addr)
+		}
+		self.promoteExecutables(addrs)
+	}
 }
 
 // GetTransaction returns a transaction if it is contained in the pool
@@ -404,35 +631,17 @@ func (tp *TxPool) GetTransaction(hash common.Hash) *types.Transaction {
 	tp.mu.RLock()
 	defer tp.mu.RUnlock()
 
-	// check the txs first
-	if tx, ok := tp.pending[hash]; ok {
-		return tx
-	}
-	// check queue
-	for _, txs := range tp.queue {
-		if tx, ok := txs[hash]; ok {
-			return tx
-		}
-	}
-	return nil
+	return tp.all[hash]
 }
 
 // GetTransactions returns all currently processable transactions.
 // The returned slice may be modified by the caller.
 func (self *TxPool) GetTransactions() (txs types.Transactions) {
-	self.mu.Lock()
-	defer self.mu.Unlock()
-
-	// check queue first
-	self.checkQueue()
-	// invalidate any txs
-	self.validatePool()
+	self.mu.RLock()
+	defer self.mu.RUnlock()
 
-	txs = make(types.Transactions, len(self.pending))
-	i := 0
-	for _, tx := range self.pending {
-		txs[i] = tx
-		i++
+	for _, list := range self.pending {
+		txs = append(txs, list.Flatten()...)
 	}
 	return txs
 }
@@ -443,10 +652,8 @@ func (self *TxPool) GetQueuedTransactions() types.Transactions {
 	defer self.mu.RUnlock()
 
 	var ret types.Transactions
-	for _, txs := range self.queue {
-		for _, tx := range txs {
-			ret = append(ret, tx)
-		}
+	for _, list := range self.queue {
+		ret = append(ret, list.Flatten()...)
 	}
 	sort.Sort(types.TxByNonce(ret))
 	return ret
@@ -468,196 +675,313 @@ func (pool *TxPool) RemoveTx(hash common.Hash) {
 	pool.removeTx(hash)
 }
 
+// removeTx removes a single transaction from the queue, moving all subsequent
+// transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash) {
-	// delete from pending pool
-	delete(pool.pending, hash)
-	// delete from queue
-	for address, txs := range pool.queue {
-		if _, ok := txs[hash]; ok {
-			if len(txs) == 1 {
-				// if only one tx, remove entire address entry.
-				delete(pool.queue, address)
-			} else {
-				delete(txs, hash)
+	// Fetch the transaction we wish to delete
+	tx, ok := pool.all[hash]
+	if !ok {
+		return
+	}
+	addr, _ := types.Sender(pool.signer, tx) // already validated during insertion
+
+	// Remove it from the list of known transactions
+	delete(pool.all, hash)
+	pool.priced.Removed()
+
+	// Remove the transaction from the pending lists and reset the account nonce
+	if pending := pool.pending[addr]; pending != nil {
+		if removed, invalids := pending.Remove(tx); removed {
+			// If no more transactions are left, remove the list
+			if pending.Empty() {
+				delete(pool.pending, addr)
+			}
+			// Postpone any invalidated transactions
+			for _, tx := range invalids {
+				pool.enqueueTx(tx.Hash(), tx)
+			}
+			// Update the account nonce if needed
+			if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
+				pool.pendingState.SetNonce(addr, nonce)
 			}
-			break
+			return
+		}
+	}
+	// Transaction is in the future queue
+	if future := pool.queue[addr]; future != nil {
+		future.Remove(tx)
+		if future.Empty() {
+			delete(pool.queue, addr)
+			delete(pool.beats, addr)
 		}
 	}
 }
 
-// checkQueue moves transactions that have become processable to main pool.
-func (pool *TxPool) checkQueue() {
+// promoteExecutables moves transactions that have become processable from the
+// future queue to the set of pending transactions. During this process, all
+// invalidated transactions (low nonce, low balance) are deleted. If accounts
+// is nil, the queues of all accounts are checked.
+func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 	// init delayed since tx pool could have been started before any state sync
 	if pool.pendingState == nil {
 		pool.resetState()
+		return
+	}
+	currentState, err := pool.currentState()
+	if err != nil {
+		glog.Errorf("could not get current state: %v", err)
+		return
 	}
+	gasLimit := pool.gasLimit()
 
-	var promote txQueue
-	for address, txs := range pool.queue {
-		currentState, err := pool.currentState()
-		if err != nil {
-			glog.Errorf("could not get current state: %v", err)
-			return
+	// Gather all the accounts potentially needing updates
+	if accounts == nil {
+		accounts = make([]common.Address, 0, len(pool.queue))
+		for addr := range pool.queue {
+			accounts = append(accounts, addr)
+		}
+	}
+	// Iterate over all accounts and promote any executable transactions
+	for _, addr := range accounts {
+		list := pool.queue[addr]
+		if list == nil {
+			continue // Just in case someone calls with a non existing account
+		}
+		// Drop all transactions that are deemed too old (low nonce)
+		for _, tx := range list.Forward(currentState.GetNonce(addr)) {
+			if glog.V(logger.Core) {
+				glog.Infof("removed tx (%v) from pool queue: low tx nonce\n", tx)
+			}
+			delete(pool.all, tx.Hash())
+			pool.priced.Removed()
+		}
+		// Drop all transactions that are too costly (low balance or out of gas)
+		drops, _ := list.Filter(currentState.GetBalance(addr), gasLimit)
+		for _, tx := range drops {
+			if glog.V(logger.Core) {
+				glog.Infof("removed tx (%v) from pool queue: out of funds or gas\n", tx)
+			}
+			delete(pool.all, tx.Hash())
+			pool.priced.Removed()
+		}
+		// Gather all executable transactions and promote them
+		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
+			pool.promoteTx(addr, tx.Hash(), tx)
 		}
-		balance := currentState.GetBalance(address)
-
-		var (
-			guessedNonce = pool.pendingState.GetNonce(address) // nonce currently kept by the tx pool (pending state)
-			trueNonce    = currentState.GetNonce(address)      // nonce known by the last state
-		)
-		promote = promote[:0]
-		for hash, tx := range txs {
-			// Drop processed or out of fund transactions
-			if tx.Nonce() < trueNonce || balance.Cmp(tx.Cost()) < 0 {
-				if glog.V(logger.Core) {
-					glog.Infof("removed tx (%v) from pool queue: low tx nonce or out of funds\n", tx)
+		// Drop all transactions over the allowed limit
+		if !pool.locals.contains(addr) {
+			for _, tx := range list.Cap(int(pool.poolConfig.AccountQueue)) {
+				if glog.V(logger.Debug) {
+					glog.Infof("Queued tx limit exceeded for %s. Tx %s removed\n", common.PP(addr[:]), common.PP(tx.Hash().Bytes()))
 				}
-				delete(txs, hash)
-				continue
+				delete(pool.all, tx.Hash())
+				pool.priced.Removed()
 			}
-			// Collect the remaining transactions for the next pass.
-			promote = append(promote, txQueueEntry{hash, address, tx})
-		}
-		// Find the next consecutive nonce range starting at the current account nonce,
-		// pushing the guessed nonce forward if we add consecutive transactions.
-		sort.Sort(promote)
-		for i, entry := range promote {
-			// If we reached a gap in the nonces, enforce transaction limit and stop
-			if entry.Nonce() > guessedNonce {
-				if len(promote)-i > maxQueued {
-					if glog.V(logger.Debug) {
-						glog.Infof("Queued tx limit exceeded for %s. Tx %s removed\n", common.PP(address[:]), common.PP(entry.hash[:]))
-					}
-					for _, drop := range promote[i+maxQueued:] {
-						delete(txs, drop.hash)
+		}
+		// Delete the entire queue entry if it became empty.
+		if list.Empty() {
+			delete(pool.queue, addr)
+			delete(pool.beats, addr)
+		}
+	}
+	// If the pending limit is overflown, start equalizing allowances
+	pending := uint64(0)
+	for _, list := range pool.pending {
+		pending += uint64(list.Len())
+	}
+	if pending > pool.poolConfig.GlobalSlots {
+		// Assemble a spam order to penalize large transactors first
+		spammers := prque.New()
+		for addr, list := range pool.pending {
+			// Only evict transactions from high rollers
+			if !pool.locals.contains(addr) && uint64(list.Len()) > pool.poolConfig.AccountSlots {
+				spammers.Push(addr, float32(list.Len()))
+			}
+		}
+		// Gradually drop transactions from offenders
+		offenders := []common.Address{}
+		for pending > pool.poolConfig.GlobalSlots && !spammers.Empty() {
+			// Retrieve the next offender
+			offender, _ := spammers.Pop()
+			offenders = append(offenders, offender.(common.Address))
+
+			// Equalize balances until all the same or below threshold
+			if len(offenders) > 1 {
+				// Calculate the equalization threshold for all current offenders
+				threshold := pool.pending[offender.(common.Address)].Len()
+
+				// Iteratively reduce all offenders until below limit or threshold reached
+				for pending > pool.poolConfig.GlobalSlots && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
+					for i := 0; i < len(offenders)-1; i++ {
+						pending -= pool.capPending(offenders[i])
 					}
 				}
-				break
 			}
-			// Otherwise promote the transaction and move the guess nonce if needed
-			pool.addTx(entry.hash, address, entry.Transaction)
-			delete(txs, entry.hash)
-
-			if entry.Nonce() == guessedNonce {
-				guessedNonce++
+		}
+		// If still above threshold, reduce to limit or min allowance
+		if pending > pool.poolConfig.GlobalSlots && len(offenders) > 0 {
+			for pending > pool.poolConfig.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.poolConfig.AccountSlots {
+				for _, addr := range offenders {
+					pending -= pool.capPending(addr)
+				}
 			}
 		}
-		// Delete the entire queue entry if it became empty.
-		if len(txs) == 0 {
-			delete(pool.queue, address)
+	}
+	// If we've queued more transactions than the hard limit, drop oldest ones
+	queued := uint64(0)
+	for _, list := range pool.queue {
+		queued += uint64(list.Len())
+	}
+	if queued > pool.poolConfig.GlobalQueue {
+		// Sort all accounts with queued transactions by heartbeat
+		addresses := make(addresssByHeartbeat, 0, len(pool.queue))
+		for addr := range pool.queue {
+			if !pool.locals.contains(addr) { // don't drop locals
+				addresses = append(addresses, addressByHeartbeat{addr, pool.beats[addr]})
+			}
+		}
+		sort.Sort(addresses)
+
+		// Drop transactions until the total is below the limit or only locals remain
+		for drop := queued - pool.poolConfig.GlobalQueue; drop > 0 && len(addresses) > 0; {
+			addr := addresses[len(addresses)-1]
+			list := pool.queue[addr.address]
+
+			addresses = addresses[:len(addresses)-1]
+
+			// Drop all transactions if they are less than the overflow
+			if size := uint64(list.Len()); size <= drop {
+				for _, tx := range list.Flatten() {
+					pool.removeTx(tx.Hash())
+				}
+				drop -= size
+				continue
+			}
+			// Otherwise drop only last few transactions
+			txs := list.Flatten()
+			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+				pool.removeTx(txs[i].Hash())
+				drop--
+			}
 		}
 	}
 }
 
-// validatePool removes invalid and processed transactions from the main pool.
-// If a transaction is removed for being invalid (e.g. out of funds), all sub-
-// sequent (Still valid) transactions are moved back into the future queue. This
-// is important to prevent a drained account from DOSing the network with non
-// executable transactions.
-func (pool *TxPool) validatePool() {
-	state, err := pool.currentState()
-	if err != nil {
-		glog.V(logger.Info).Infoln("failed to get current state: %v", err)
-		return
+// capPending drops the highest nonce pending transaction of an account to make
+// room for others, returning the number of transactions dropped.
+func (pool *TxPool) capPending(addr common.Address) uint64 {
+	list := pool.pending[addr]
+
+	drops := list.Cap(list.Len() - 1)
+	for _, tx := range drops {
+		// Drop the transaction from the global pools too
+		delete(pool.all, tx.Hash())
+		pool.priced.Removed()
+
+		// Update the account nonce to the dropped transaction
+		if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
+			pool.pendingState.SetNonce(addr, nonce)
+		}
+		if glog.V(logger.Debug) {
+			glog.Infof("Pending tx limit exceeded for %s. Tx %s removed\n", common.PP(addr[:]), common.PP(tx.Hash().Bytes()))
+		}
 	}
-	balanceCache := make(map[common.Address]*big.Int)
+	return uint64(len(drops))
+}
 
-	// Clean up the pending pool, accumulating invalid nonces
-	gaps := make(map[common.Address]uint64)
+// demoteUnexecutables removes invalid and processed transactions from the pools
+// executable/pending queue. If a transaction is removed for being invalid (e.g.
+// out of funds), all sub-sequent (Still valid) transactions are moved back into
+// the future queue. This is important to prevent a drained account from DOSing
+// the network with non executable transactions.
+func (pool *TxPool) demoteUnexecutables(currentState *state.StateDB) {
+	gasLimit := pool.gasLimit()
 
-	for hash, tx := range pool.pending {
-		sender, _ := tx.From() // err already checked
+	// Iterate over all accounts and demote any non-executable transactions
+	for addr, list := range pool.pending {
+		nonce := currentState.GetNonce(addr)
 
-		// Perform light nonce and balance validation
-		balance := balanceCache[sender]
-		if balance == nil {
-			balance = state.GetBalance(sender)
-			balanceCache[sender] = balance
+		// Drop all transactions that are deemed too old (low nonce)
+		for _, tx := range list.Forward(nonce) {
+			if glog.V(logger.Core) {
+				glog.Infof("removed tx (%v) from pool: low tx nonce\n", tx)
+			}
+			delete(pool.all, tx.Hash())
+			pool.priced.Removed()
 		}
-		if past := state.GetNonce(sender) > tx.Nonce(); past || balance.Cmp(tx.Cost()) < 0 {
-			// Remove an already past it invalidated transaction
+		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
+		drops, invalids := list.Filter(currentState.GetBalance(addr), gasLimit)
+		for _, tx := range drops {
 			if glog.V(logger.Core) {
-				glog.Infof("removed tx (%v) from pool: low tx nonce or out of funds\n", tx)
+				glog.Infof("removed tx (%v) from pool: out of funds or gas\n", tx)
 			}
-			delete(pool.pending, hash)
-
-			// Track the smallest invalid nonce to postpone subsequent transactions
-			if !past {
-				if prev, ok := gaps[sender]; !ok || tx.Nonce() < prev {
-					gaps[sender] = tx.Nonce()
-				}
+			delete(pool.all, tx.Hash())
+			pool.priced.Removed()
+		}
+		for _, tx := range invalids {
+			if glog.V(logger.Core) {
+				glog.Infof("postponed tx (%v) due to introduced gap\n", tx)
 			}
+			pool.enqueueTx(tx.Hash(), tx)
 		}
-	}
-	// Move all transactions after a gap back to the future queue
-	if len(gaps) > 0 {
-		for hash, tx := range pool.pending {
-			sender, _ := tx.From()
-			if gap, ok := gaps[sender]; ok && tx.Nonce() >= gap {
-				if glog.V(logger.Core) {
-					glog.Infof("postponed tx (%v) due to introduced gap\n", tx)
-				}
-				pool.queueTx(hash, tx)
-				delete(pool.pending, hash)
+		// If there's a gap in front, warn (should never happen) and postpone all transactions
+		if list.Len() > 0 && list.txs.Get(nonce) == nil {
+			for _, tx := range list.Cap(0) {
+				glog.V(logger.Error).Infof("postponed tx (%v) due to nonce gap\n", tx)
+				pool.enqueueTx(tx.Hash(), tx)
 			}
 		}
+		// Delete the entire pending entry if it became empty.
+		if list.Empty() {
+			delete(pool.pending, addr)
+		}
 	}
 }
 
-type txQueue []txQueueEntry
-
-type txQueueEntry struct {
-	hash common.Hash
-	addr common.Address
-	*types.Transaction
+// addressByHeartbeat is an account address tagged with its last activity timestamp.
+type addressByHeartbeat struct {
+	address   common.Address
+	heartbeat time.Time
 }
 
-func (q txQueue) Len() int           { return len(q) }
-func (q txQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
-func (q txQueue) Less(i, j int) bool { return q[i].Nonce() < q[j].Nonce() }
-
-// txSet represents a set of transaction hashes in which entries
-//  are automatically dropped after txSetDuration time
-type txSet struct {
-	txMap          map[common.Hash]struct{}
-	txOrd          map[uint64]txOrdType
-	addPtr, delPtr uint64
-}
+type addresssByHeartbeat []addressByHeartbeat
 
-const txSetDuration = time.Hour * 2
+func (a addresssByHeartbeat) Len() int           { return len(a) }
+func (a addresssByHeartbeat) Less(i, j int) bool { return a[i].heartbeat.Before(a[j].heartbeat) }
+func (a addresssByHeartbeat) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
-// txOrdType represents an entry in the time-ordered list of transaction hashes
-type txOrdType struct {
-	hash common.Hash
-	time time.Time
+// accountSet is simply a set of addresses to check for existence, and a signer
+// capable of deriving addresses from transactions.
+type accountSet struct {
+	accounts map[common.Address]struct{}
+	signer   types.Signer
 }
 
-// newTxSet creates a new transaction set
-func newTxSet() *txSet {
-	return &txSet{
-		txMap: make(map[common.Hash]struct{}),
-		txOrd: make(map[uint64]txOrdType),
+// newAccountSet creates a new address set with an associated signer for sender
+// derivations.
+func newAccountSet(signer types.Signer) *accountSet {
+	return &accountSet{
+		accounts: make(map[common.Address]struct{}),
+		signer:   signer,
 	}
 }
 
-// contains returns true if the set contains the given transaction hash
-// (not thread safe, should be called from a locked environment)
-func (self *txSet) contains(hash common.Hash) bool {
-	_, ok := self.txMap[hash]
-	return ok
+// contains checks if a given address is contained within the set.
+func (as *accountSet) contains(addr common.Address) bool {
+	_, exist := as.accounts[addr]
+	return exist
 }
 
-// add adds a transaction hash to the set, then removes entries older than txSetDuration
-// (not thread safe, should be called from a locked environment)
-func (self *txSet) add(hash common.Hash) {
-	self.txMap[hash] = struct{}{}
-	now := time.Now()
-	self.txOrd[self.addPtr] = txOrdType{hash: hash, time: now}
-	self.addPtr++
-	delBefore := now.Add(-txSetDuration)
-	for self.delPtr < self.addPtr && self.txOrd[self.delPtr].time.Before(delBefore) {
-		delete(self.txMap, self.txOrd[self.delPtr].hash)
-		delete(self.txOrd, self.delPtr)
-		self.delPtr++
+// containsTx checks if the sender of a given tx is within the set. If the sender
+// cannot be derived, this method returns false.
+func (as *accountSet) containsTx(tx *types.Transaction) bool {
+	if addr, err := types.Sender(as.signer, tx); err == nil {
+		return as.contains(addr)
 	}
+	return false
+}
+
+// add inserts a new address into the set to track.
+func (as *accountSet) add(addr common.Address) {
+	as.accounts[addr] = struct{}{}
 }
@@ -18,8 +18,11 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/core/state"
@@ -29,18 +32,35 @@ import (
 	"github.com/ethereumproject/go-ethereum/event"
 )
 
+// testTxPoolConfig is a transaction pool configuration without stateful disk
+// sideeffects used during testing.
+var testTxPoolConfig TxPoolConfig
+
+func init() {
+	testTxPoolConfig = DefaultTxPoolConfig
+	testTxPoolConfig.Journal = ""
+}
+
 func transaction(nonce uint64, gaslimit *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
-	tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, big.NewInt(1), nil).SignECDSA(key)
+	return pricedTransaction(nonce, gaslimit, big.NewInt(1), key)
+}
+
+func pricedTransaction(nonce uint64, gaslimit, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, gasprice, nil).SignECDSA(key)
 	return tx
 }
 
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
+	return setupTxPoolWithConfig(testTxPoolConfig)
+}
+
+func setupTxPoolWithConfig(config TxPoolConfig) (*TxPool, *ecdsa.PrivateKey) {
 	db, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
 
 	var m event.TypeMux
 	key, _ := crypto.GenerateKey()
-	newPool := NewTxPool(testChainConfig(), &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
+	newPool := NewTxPool(testChainConfig(), config, &m, func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
 	newPool.resetState()
 	return newPool, key
 }
@@ -95,9 +115,9 @@ func TestTransactionQueue(t *testing.T) {
 	from, _ := tx.From()
 	currentState, _ := pool.currentState()
 	currentState.AddBalance(from, big.NewInt(1000))
-	pool.queueTx(tx.Hash(), tx)
+	pool.enqueueTx(tx.Hash(), tx)
 
-	pool.checkQueue()
+	pool.promoteExecutables(nil)
 	if len(pool.pending) != 1 {
 		t.Error("expected valid txs to be 1 is", len(pool.pending))
 	}
@@ -105,14 +125,14 @@ func TestTransactionQueue(t *testing.T) {
 	tx = transaction(1, big.NewInt(100), key)
 	from, _ = deriveSender(tx)
 	currentState.SetNonce(from, 2)
-	pool.queueTx(tx.Hash(), tx)
-	pool.checkQueue()
-	if _, ok := pool.pending[tx.Hash()]; ok {
+	pool.enqueueTx(tx.Hash(), tx)
+	pool.promoteExecutables(nil)
+	if _, ok := pool.pending[from].txs.items[tx.Nonce()]; ok {
 		t.Error("expected transaction to be in tx pool")
 	}
 
-	if len(pool.queue[from]) > 0 {
-		t.Error("expected transaction queue to be empty. is", len(pool.queue[from]))
+	if len(pool.queue) > 0 {
+		t.Error("expected transaction queue to be empty. is", len(pool.queue))
 	}
 
 	pool, key = setupTxPool()
@@ -122,44 +142,55 @@ func TestTransactionQueue(t *testing.T) {
 	from, _ = tx1.From()
 	currentState, _ = pool.currentState()
 	currentState.AddBalance(from, big.NewInt(1000))
-	pool.queueTx(tx1.Hash(), tx1)
-	pool.queueTx(tx2.Hash(), tx2)
-	pool.queueTx(tx3.Hash(), tx3)
+	pool.enqueueTx(tx1.Hash(), tx1)
+	pool.enqueueTx(tx2.Hash(), tx2)
+	pool.enqueueTx(tx3.Hash(), tx3)
 
-	pool.checkQueue()
+	pool.promoteExecutables(nil)
 
 	if len(pool.pending) != 1 {
 		t.Error("expected tx pool to be 1, got", len(pool.pending))
 	}
-	if len(pool.queue[from]) != 2 {
-		t.Error("expected len(queue) == 2, got", len(pool.queue[from]))
+	if pool.queue[from].Len() != 2 {
+		t.Error("expected len(queue) == 2, got", pool.queue[from].Len())
 	}
 }
 
+// Tests that removing a pending transaction postpones all subsequent ones and
+// rewinds the pending nonce of the account.
 func TestRemoveTx(t *testing.T) {
 	pool, key := setupTxPool()
-	tx := transaction(0, big.NewInt(100), key)
-	from, _ := deriveSender(tx)
+	from, _ := deriveSender(transaction(0, big.NewInt(0), key))
 	currentState, _ := pool.currentState()
-	currentState.AddBalance(from, big.NewInt(1))
-	pool.queueTx(tx.Hash(), tx)
-	pool.addTx(tx.Hash(), from, tx)
-	if len(pool.queue) != 1 {
-		t.Error("expected queue to be 1, got", len(pool.queue))
-	}
+	currentState.AddBalance(from, big.NewInt(1000000))
 
-	if len(pool.pending) != 1 {
-		t.Error("expected txs to be 1, got", len(pool.pending))
+	txs := []*types.Transaction{
+		transaction(0, big.NewInt(100000), key),
+		transaction(1, big.NewInt(100000), key),
+		transaction(3, big.NewInt(100000), key),
+	}
+	pool.AddTransactions(txs)
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 2, 1)
 	}
 
-	pool.RemoveTx(tx.Hash())
+	pool.RemoveTx(txs[0].Hash())
+	if len(pool.pending) > 0 {
+		t.Error("expected txs to be 0, got", len(pool.pending))
+	}
+	if pool.queue[from].Len() != 2 {
+		t.Error("expected queue to be 2, got", pool.queue[from].Len())
+	}
+	if nonce := pool.State().GetNonce(from); nonce != 0 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 0)
+	}
 
+	pool.RemoveTransactions(txs[1:])
 	if len(pool.queue) > 0 {
 		t.Error("expected queue to be 0, got", len(pool.queue))
 	}
-
-	if len(pool.pending) > 0 {
-		t.Error("expected txs to be 0, got", len(pool.pending))
+	if len(pool.all) > 0 {
+		t.Error("expected no known transactions, got", len(pool.all))
 	}
 }
 
@@ -214,18 +245,38 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	}
 	resetState()
 
-	tx := transaction(0, big.NewInt(100000), key)
-	tx2 := transaction(0, big.NewInt(1000000), key)
-	if err := pool.add(tx); err != nil {
+	tx1 := pricedTransaction(0, big.NewInt(100000), big.NewInt(1), key)
+	tx2 := pricedTransaction(0, big.NewInt(1000000), big.NewInt(2), key)
+	tx3 := pricedTransaction(0, big.NewInt(1000000), big.NewInt(1), key)
+
+	// Add the first two transaction, ensure higher priced stays only
+	if err := pool.add(tx1); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	if err := pool.add(tx2); err != nil {
 		t.Error("didn't expect error", err)
 	}
-
-	pool.checkQueue()
-	if len(pool.pending) != 2 {
-		t.Error("expected 2 pending txs. Got", len(pool.pending))
+	pool.promoteExecutables([]common.Address{addr})
+	if pool.pending[addr].Len() != 1 {
+		t.Error("expected 1 pending transactions, got", pool.pending[addr].Len())
+	}
+	if tx := pool.pending[addr].txs.items[0]; tx.Hash() != tx2.Hash() {
+		t.Errorf("transaction mismatch: have %x, want %x", tx.Hash(), tx2.Hash())
+	}
+	// Add the third transaction and ensure it's not saved (smaller price)
+	if err := pool.add(tx3); err != ErrReplaceUnderpriced {
+		t.Error("expected", ErrReplaceUnderpriced, "got", err)
+	}
+	pool.promoteExecutables([]common.Address{addr})
+	if pool.pending[addr].Len() != 1 {
+		t.Error("expected 1 pending transactions, got", pool.pending[addr].Len())
+	}
+	if tx := pool.pending[addr].txs.items[0]; tx.Hash() != tx2.Hash() {
+		t.Errorf("transaction mismatch: have %x, want %x", tx.Hash(), tx2.Hash())
+	}
+	// Ensure the total transaction count is correct
+	if len(pool.all) != 1 {
+		t.Error("expected 1 total transactions, got", len(pool.all))
 	}
 }
 
@@ -241,8 +292,11 @@ func TestMissingNonce(t *testing.T) {
 	if len(pool.pending) != 0 {
 		t.Error("expected 0 pending transactions, got", len(pool.pending))
 	}
-	if len(pool.queue[addr]) != 1 {
-		t.Error("expected 1 queued transaction, got", len(pool.queue[addr]))
+	if pool.queue[addr].Len() != 1 {
+		t.Error("expected 1 queued transaction, got", pool.queue[addr].Len())
+	}
+	if len(pool.all) != 1 {
+		t.Error("expected 1 total transactions, got", len(pool.all))
 	}
 }
 
@@ -258,11 +312,12 @@ func TestNonceRecovery(t *testing.T) {
 	if err := pool.Add(tx); err != nil {
 		t.Error(err)
 	}
-	// simulate some weird re-order of transactions and missing nonce(s)
+	// simulate some weird re-order of transactions and missing nonce(s), the
+	// transaction must be postponed until the gap is filled
 	currentState.SetNonce(addr, n-1)
 	pool.resetState()
-	if fn := pool.pendingState.GetNonce(addr); fn != n+1 {
-		t.Errorf("expected nonce to be %d, got %d", n+1, fn)
+	if fn := pool.pendingState.GetNonce(addr); fn != n-1 {
+		t.Errorf("expected nonce to be %d, got %d", n-1, fn)
 	}
 }
 
@@ -296,41 +351,50 @@ func TestTransactionDropping(t *testing.T) {
 		tx10 = transaction(10, big.NewInt(100), key)
 		tx11 = transaction(11, big.NewInt(200), key)
 	)
-	pool.addTx(tx0.Hash(), account, tx0)
-	pool.addTx(tx1.Hash(), account, tx1)
-	pool.queueTx(tx10.Hash(), tx10)
-	pool.queueTx(tx11.Hash(), tx11)
+	pool.promoteTx(account, tx0.Hash(), tx0)
+	pool.promoteTx(account, tx1.Hash(), tx1)
+	pool.enqueueTx(tx10.Hash(), tx10)
+	pool.enqueueTx(tx11.Hash(), tx11)
 
 	// Check that pre and post validations leave the pool as is
-	if len(pool.pending) != 2 {
-		t.Errorf("pending transaction mismatch: have %d, want %d", len(pool.pending), 2)
+	if pool.pending[account].Len() != 2 {
+		t.Errorf("pending transaction mismatch: have %d, want %d", pool.pending[account].Len(), 2)
+	}
+	if pool.queue[account].Len() != 2 {
+		t.Errorf("queued transaction mismatch: have %d, want %d", pool.queue[account].Len(), 2)
 	}
-	if len(pool.queue[account]) != 2 {
-		t.Errorf("queued transaction mismatch: have %d, want %d", len(pool.queue), 2)
+	if len(pool.all) != 4 {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 4)
 	}
 	pool.resetState()
-	if len(pool.pending) != 2 {
-		t.Errorf("pending transaction mismatch: have %d, want %d", len(pool.pending), 2)
+	if pool.pending[account].Len() != 2 {
+		t.Errorf("pending transaction mismatch: have %d, want %d", pool.pending[account].Len(), 2)
 	}
-	if len(pool.queue[account]) != 2 {
-		t.Errorf("queued transaction mismatch: have %d, want %d", len(pool.queue), 2)
+	if pool.queue[account].Len() != 2 {
+		t.Errorf("queued transaction mismatch: have %d, want %d", pool.queue[account].Len(), 2)
+	}
+	if len(pool.all) != 4 {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 4)
 	}
 	// Reduce the balance of the account, and check that invalidated transactions are dropped
 	state.AddBalance(account, big.NewInt(-750))
 	pool.resetState()
 
-	if _, ok := pool.pending[tx0.Hash()]; !ok {
+	if _, ok := pool.pending[account].txs.items[tx0.Nonce()]; !ok {
 		t.Errorf("funded pending transaction missing: %v", tx0)
 	}
-	if _, ok := pool.pending[tx1.Hash()]; ok {
+	if _, ok := pool.pending[account].txs.items[tx1.Nonce()]; ok {
 		t.Errorf("out-of-fund pending transaction present: %v", tx1)
 	}
-	if _, ok := pool.queue[account][tx10.Hash()]; !ok {
+	if _, ok := pool.queue[account].txs.items[tx10.Nonce()]; !ok {
 		t.Errorf("funded queued transaction missing: %v", tx10)
 	}
-	if _, ok := pool.queue[account][tx11.Hash()]; ok {
+	if _, ok := pool.queue[account].txs.items[tx11.Nonce()]; ok {
 		t.Errorf("out-of-fund queued transaction present: %v", tx11)
 	}
+	if len(pool.all) != 2 {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 2)
+	}
 }
 
 // Tests that if a transaction is dropped from the current pending pool (e.g. out
@@ -353,50 +417,53 @@ func TestTransactionPostponing(t *testing.T) {
 		} else {
 			tx = transaction(uint64(i), big.NewInt(500), key)
 		}
-		pool.addTx(tx.Hash(), account, tx)
+		pool.promoteTx(account, tx.Hash(), tx)
 		txns = append(txns, tx)
 	}
 	// Check that pre and post validations leave the pool as is
-	if len(pool.pending) != len(txns) {
-		t.Errorf("pending transaction mismatch: have %d, want %d", len(pool.pending), len(txns))
+	if pool.pending[account].Len() != len(txns) {
+		t.Errorf("pending transaction mismatch: have %d, want %d", pool.pending[account].Len(), len(txns))
 	}
-	if len(pool.queue[account]) != 0 {
+	if len(pool.queue) != 0 {
 		t.Errorf("queued transaction mismatch: have %d, want %d", len(pool.queue), 0)
 	}
 	pool.resetState()
-	if len(pool.pending) != len(txns) {
-		t.Errorf("pending transaction mismatch: have %d, want %d", len(pool.pending), len(txns))
+	if pool.pending[account].Len() != len(txns) {
+		t.Errorf("pending transaction mismatch: have %d, want %d", pool.pending[account].Len(), len(txns))
 	}
-	if len(pool.queue[account]) != 0 {
+	if len(pool.queue) != 0 {
 		t.Errorf("queued transaction mismatch: have %d, want %d", len(pool.queue), 0)
 	}
 	// Reduce the balance of the account, and check that transactions are reorganised
 	state.AddBalance(account, big.NewInt(-750))
 	pool.resetState()
 
-	if _, ok := pool.pending[txns[0].Hash()]; !ok {
+	if _, ok := pool.pending[account].txs.items[txns[0].Nonce()]; !ok {
 		t.Errorf("tx %d: valid and funded transaction missing from pending pool: %v", 0, txns[0])
 	}
-	if _, ok := pool.queue[account][txns[0].Hash()]; ok {
+	if _, ok := pool.queue[account].txs.items[txns[0].Nonce()]; ok {
 		t.Errorf("tx %d: valid and funded transaction present in future queue: %v", 0, txns[0])
 	}
 	for i, tx := range txns[1:] {
 		if i%2 == 1 {
-			if _, ok := pool.pending[tx.Hash()]; ok {
+			if _, ok := pool.pending[account].txs.items[tx.Nonce()]; ok {
 				t.Errorf("tx %d: valid but future transaction present in pending pool: %v", i+1, tx)
 			}
-			if _, ok := pool.queue[account][tx.Hash()]; !ok {
+			if _, ok := pool.queue[account].txs.items[tx.Nonce()]; !ok {
 				t.Errorf("tx %d: valid but future transaction missing from future queue: %v", i+1, tx)
 			}
 		} else {
-			if _, ok := pool.pending[tx.Hash()]; ok {
+			if _, ok := pool.pending[account].txs.items[tx.Nonce()]; ok {
 				t.Errorf("tx %d: out-of-fund transaction present in pending pool: %v", i+1, tx)
 			}
-			if _, ok := pool.queue[account][tx.Hash()]; ok {
+			if _, ok := pool.queue[account].txs.items[tx.Nonce()]; ok {
 				t.Errorf("tx %d: out-of-fund transaction present in future queue: %v", i+1, tx)
 			}
 		}
 	}
+	if len(pool.all) != len(txns)/2 {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), len(txns)/2)
+	}
 }
 
 // Tests that if the transaction count belonging to a single account goes above
@@ -410,23 +477,26 @@ func TestTransactionQueueLimiting(t *testing.T) {
 	state.AddBalance(account, big.NewInt(1000000))
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(1); i <= maxQueued+5; i++ {
+	for i := uint64(1); i <= testTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
 		if len(pool.pending) != 0 {
 			t.Errorf("tx %d: pending pool size mismatch: have %d, want %d", i, len(pool.pending), 0)
 		}
-		if i <= maxQueued {
-			if len(pool.queue[account]) != int(i) {
-				t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, len(pool.queue[account]), i)
+		if i <= testTxPoolConfig.AccountQueue {
+			if pool.queue[account].Len() != int(i) {
+				t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, pool.queue[account].Len(), i)
 			}
 		} else {
-			if len(pool.queue[account]) != maxQueued {
-				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, len(pool.queue[account]), maxQueued)
+			if pool.queue[account].Len() != int(testTxPoolConfig.AccountQueue) {
+				t.Errorf("tx %d: queue limit mismatch: have %d, want %d", i, pool.queue[account].Len(), testTxPoolConfig.AccountQueue)
 			}
 		}
 	}
+	if len(pool.all) != int(testTxPoolConfig.AccountQueue) {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), testTxPoolConfig.AccountQueue)
+	}
 }
 
 // Tests that even if the transaction count belonging to a single account goes
@@ -441,17 +511,20 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	state.AddBalance(account, big.NewInt(1000000))
 
 	// Keep queuing up transactions and make sure all above a limit are dropped
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < testTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool.Add(transaction(i, big.NewInt(100000), key)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
-		if len(pool.pending) != int(i)+1 {
-			t.Errorf("tx %d: pending pool size mismatch: have %d, want %d", i, len(pool.pending), i+1)
+		if pool.pending[account].Len() != int(i)+1 {
+			t.Errorf("tx %d: pending pool size mismatch: have %d, want %d", i, pool.pending[account].Len(), i+1)
 		}
-		if len(pool.queue[account]) != 0 {
-			t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, len(pool.queue[account]), 0)
+		if len(pool.queue) != 0 {
+			t.Errorf("tx %d: queue size mismatch: have %d, want %d", i, len(pool.queue), 0)
 		}
 	}
+	if len(pool.all) != int(testTxPoolConfig.AccountQueue+5) {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), testTxPoolConfig.AccountQueue+5)
+	}
 }
 
 // Tests that the transaction limits are enforced the same way irrelevant whether
@@ -466,7 +539,7 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state1, _ := pool1.currentState()
 	state1.AddBalance(account1, big.NewInt(1000000))
 
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < testTxPoolConfig.AccountQueue+5; i++ {
 		if err := pool1.Add(transaction(origin+i, big.NewInt(100000), key1)); err != nil {
 			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
 		}
@@ -478,27 +551,348 @@ func testTransactionLimitingEquivalency(t *testing.T, origin uint64) {
 	state2.AddBalance(account2, big.NewInt(1000000))
 
 	txns := []*types.Transaction{}
-	for i := uint64(0); i < maxQueued+5; i++ {
+	for i := uint64(0); i < testTxPoolConfig.AccountQueue+5; i++ {
 		txns = append(txns, transaction(origin+i, big.NewInt(100000), key2))
 	}
 	pool2.AddTransactions(txns)
 
 	// Ensure the batch optimization honors the same pool mechanics
-	if len(pool1.pending) != len(pool2.pending) {
-		t.Errorf("pending transaction count mismatch: one-by-one algo: %d, batch algo: %d", len(pool1.pending), len(pool2.pending))
+	pending1, queued1 := pool1.Stats()
+	pending2, queued2 := pool2.Stats()
+	if pending1 != pending2 {
+		t.Errorf("pending transaction count mismatch: one-by-one algo: %d, batch algo: %d", pending1, pending2)
+	}
+	if queued1 != queued2 {
+		t.Errorf("queued transaction count mismatch: one-by-one algo: %d, batch algo: %d", queued1, queued2)
+	}
+	if len(pool1.all) != len(pool2.all) {
+		t.Errorf("total transaction count mismatch: one-by-one algo %d, batch algo %d", len(pool1.all), len(pool2.all))
+	}
+}
+
+// Tests that if the transaction count belonging to multiple accounts go above
+// some hard threshold, the higher transactions are dropped to prevent DOS
+// attacks, equalizing the allowance of the offending accounts.
+func TestTransactionPendingGlobalLimiting(t *testing.T) {
+	config := testTxPoolConfig
+	config.GlobalSlots = config.AccountSlots * 10
+
+	pool, _ := setupTxPoolWithConfig(config)
+	state, _ := pool.currentState()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		state.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	// Generate and queue a batch of transactions
+	txs := types.Transactions{}
+	for _, key := range keys {
+		for j := 0; j < int(config.GlobalSlots)/len(keys)*2; j++ {
+			txs = append(txs, transaction(uint64(j), big.NewInt(100000), key))
+		}
+	}
+	pool.AddTransactions(txs)
+
+	pending := 0
+	for _, list := range pool.pending {
+		pending += list.Len()
+	}
+	if pending > int(config.GlobalSlots) {
+		t.Fatalf("total pending transactions overflow allowance: %d > %d", pending, config.GlobalSlots)
+	}
+	for addr, list := range pool.pending {
+		if list.Len() != int(config.GlobalSlots)/len(keys) {
+			t.Errorf("addr %x: pending transactions not equalized: have %d, want %d", addr, list.Len(), int(config.GlobalSlots)/len(keys))
+		}
+	}
+}
+
+// Tests that if the non-executable transaction count of multiple accounts goes
+// above the global limit, transactions are dropped, but never those of local
+// accounts.
+func TestTransactionQueueGlobalLimiting(t *testing.T) {
+	config := testTxPoolConfig
+	config.GlobalQueue = config.AccountQueue*3 - 1
+
+	pool, local := setupTxPoolWithConfig(config)
+	state, _ := pool.currentState()
+	state.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000))
+
+	// Create a number of remote test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		state.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	// Fill the queue of every account up to its own limit
+	pool.SetLocal(transaction(0, big.NewInt(0), local))
+
+	txs := types.Transactions{}
+	for _, key := range append(keys, local) {
+		for j := uint64(1); j <= config.AccountQueue; j++ {
+			txs = append(txs, transaction(j, big.NewInt(100000), key))
+		}
+	}
+	pool.AddTransactions(txs)
+
+	if _, queued := pool.Stats(); queued != int(config.GlobalQueue) {
+		t.Errorf("queued transactions mismatch: have %d, want %d", queued, config.GlobalQueue)
+	}
+	if queued := pool.queue[crypto.PubkeyToAddress(local.PublicKey)].Len(); queued != int(config.AccountQueue) {
+		t.Errorf("local queued transactions dropped: have %d, want %d", queued, config.AccountQueue)
+	}
+	if len(pool.all) != int(config.GlobalQueue) {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), config.GlobalQueue)
+	}
+}
+
+// Tests that transactions can only be replaced by another one with the same
+// nonce if the new one pays at least the configured price bump more.
+func TestTransactionReplacement(t *testing.T) {
+	pool, key := setupTxPool()
+	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
+
+	state, _ := pool.currentState()
+	state.AddBalance(account, big.NewInt(1000000000))
+
+	// Add pending transactions, ensuring the minimum price bump is enforced for replacement (for ultra low prices too)
+	price := int64(100)
+	threshold := (price * (100 + int64(testTxPoolConfig.PriceBump))) / 100
+
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add original cheap pending transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100001), big.NewInt(1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("original cheap pending transaction replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(2), key)); err != nil {
+		t.Fatalf("failed to replace original cheap pending transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(price), key)); err != nil {
+		t.Fatalf("failed to add original proper pending transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100001), big.NewInt(threshold-1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("original proper pending transaction replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(threshold), key)); err != nil {
+		t.Fatalf("failed to replace original proper pending transaction: %v", err)
+	}
+	// Add queued transactions, ensuring the minimum price bump is enforced for replacement (for ultra low prices too)
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100000), big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add original cheap queued transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100001), big.NewInt(1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("original cheap queued transaction replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100000), big.NewInt(2), key)); err != nil {
+		t.Fatalf("failed to replace original cheap queued transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100000), big.NewInt(price), key)); err != nil {
+		t.Fatalf("failed to add original proper queued transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100001), big.NewInt(threshold-1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("original proper queued transaction replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100000), big.NewInt(threshold), key)); err != nil {
+		t.Fatalf("failed to replace original proper queued transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Errorf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 1, 1)
+	}
+	if len(pool.all) != 2 {
+		t.Errorf("total transaction mismatch: have %d, want %d", len(pool.all), 2)
+	}
+}
+
+// Tests that when the pool reaches its global transaction limit, underpriced
+// transactions are gradually shifted out for more expensive ones and any gapped
+// pending transactions are moved into the queue. Local transactions are never
+// evicted.
+func TestTransactionPoolUnderpricing(t *testing.T) {
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+
+	pool, local := setupTxPoolWithConfig(config)
+	state, _ := pool.currentState()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 2)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		state.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	state.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000))
+
+	// Generate and queue a batch of transactions, both pending and queued
+	txs := types.Transactions{
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[0]),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(2), keys[0]),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(1), keys[1]),
+	}
+	pool.AddTransactions(txs)
+
+	ltx := pricedTransaction(0, big.NewInt(100000), big.NewInt(1), local)
+	pool.SetLocal(ltx)
+	if err := pool.Add(ltx); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 3, 1)
+	}
+	// Ensure that adding an underpriced transaction on block limit fails
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), keys[1])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced pending transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Ensure that adding high priced transactions drops cheap ones, but not own
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(3), keys[1])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(2, big.NewInt(100000), big.NewInt(4), keys[1])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	if err := pool.Add(pricedTransaction(3, big.NewInt(100000), big.NewInt(5), keys[1])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 2 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 2, 2)
+	}
+	if pool.pending[crypto.PubkeyToAddress(local.PublicKey)] == nil {
+		t.Fatalf("local transaction evicted")
+	}
+	// Ensure that adding local transactions can push out even higher priced ones
+	ltx = pricedTransaction(1, big.NewInt(100000), big.NewInt(0), local)
+	if err := pool.Add(ltx); err != nil {
+		t.Fatalf("failed to add underpriced local transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 2 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 2, 2)
+	}
+	if pending := pool.pending[crypto.PubkeyToAddress(local.PublicKey)].Len(); pending != 2 {
+		t.Fatalf("local pending transactions mismatch: have %d, want %d", pending, 2)
+	}
+}
+
+// Tests that raising the minimum gas price drops all cheaper remote
+// transactions from the pool, but keeps local ones.
+func TestTransactionPoolRepricing(t *testing.T) {
+	pool, local := setupTxPool()
+	state, _ := pool.currentState()
+
+	remote, _ := crypto.GenerateKey()
+	state.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+	state.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+
+	pool.AddTransactions(types.Transactions{
+		pricedTransaction(0, big.NewInt(100000), big.NewInt(1), remote),
+		pricedTransaction(1, big.NewInt(100000), big.NewInt(2), remote),
+		pricedTransaction(3, big.NewInt(100000), big.NewInt(2), remote),
+	})
+	ltx := pricedTransaction(0, big.NewInt(100000), big.NewInt(1), local)
+	pool.SetLocal(ltx)
+	if err := pool.Add(ltx); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 3, 1)
+	}
+	// Raise the minimum price, the cheap remote transaction and its dependants must go
+	pool.eventMux.Post(GasPriceChanged{big.NewInt(2)})
+	pool.eventMux.Post(GasPriceChanged{big.NewInt(2)}) // wait for the first one to be processed
+
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if pending, queued := len(pool.pending), len(pool.queue); pending != 1 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d accounts, want %d/%d", pending, queued, 1, 1)
+	}
+	if pool.pending[crypto.PubkeyToAddress(local.PublicKey)] == nil {
+		t.Fatalf("local transaction dropped")
+	}
+	if queued := pool.queue[crypto.PubkeyToAddress(remote.PublicKey)].Len(); queued != 2 {
+		t.Fatalf("remote queued transactions mismatch: have %d, want %d", queued, 2)
+	}
+}
+
+// Tests that local transactions are journaled to disk and reloaded at startup,
+// whilst remote transactions are not.
+func TestTransactionJournaling(t *testing.T) {
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	// Clean up the temporary file, we only need the path for now
+	file.Close()
+	os.Remove(journal)
+
+	// Create the original pool to inject transaction into the journal
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	config := testTxPoolConfig
+	config.Journal = journal
+	config.Rejournal = time.Second
+
+	newPool := func() *TxPool {
+		return NewTxPool(testChainConfig(), config, new(event.TypeMux), func() (*state.StateDB, error) { return statedb, nil }, func() *big.Int { return big.NewInt(1000000) })
+	}
+	pool := newPool()
+
+	// Create two test accounts to ensure remotes expire but locals do not
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	statedb.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	statedb.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add three local and a remote transactions and ensure they are queued up
+	for _, nonce := range []uint64{0, 1, 3} {
+		tx := pricedTransaction(nonce, big.NewInt(100000), big.NewInt(1), local)
+		pool.SetLocal(tx)
+		if err := pool.Add(tx); err != nil {
+			t.Fatalf("failed to add local transaction %d: %v", nonce, err)
+		}
+	}
+	if err := pool.Add(pricedTransaction(0, big.NewInt(100000), big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 3, 1)
+	}
+	// Terminate the old pool, bump the local nonce, create a new pool and ensure relevant transaction survive
+	pool.Stop()
+	statedb.SetNonce(crypto.PubkeyToAddress(local.PublicKey), 1)
+	pool = newPool()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 1, 1)
+	}
+	// Restart again, the rotated journal must keep the surviving transactions
+	pool.Stop()
+	pool = newPool()
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d, want %d/%d", pending, queued, 1, 1)
 	}
-	if len(pool1.queue[account1]) != len(pool2.queue[account2]) {
-		t.Errorf("queued transaction count mismatch: one-by-one algo: %d, batch algo: %d", len(pool1.queue[account1]), len(pool2.queue[account2]))
+	if pool.pending[crypto.PubkeyToAddress(remote.PublicKey)] != nil {
+		t.Fatalf("remote transaction survived restart")
 	}
 }
 
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
-func BenchmarkValidatePool100(b *testing.B)   { benchmarkValidatePool(b, 100) }
-func BenchmarkValidatePool1000(b *testing.B)  { benchmarkValidatePool(b, 1000) }
-func BenchmarkValidatePool10000(b *testing.B) { benchmarkValidatePool(b, 10000) }
+func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
+func BenchmarkPendingDemotion1000(b *testing.B)  { benchmarkPendingDemotion(b, 1000) }
+func BenchmarkPendingDemotion10000(b *testing.B) { benchmarkPendingDemotion(b, 10000) }
 
-func benchmarkValidatePool(b *testing.B, size int) {
+func benchmarkPendingDemotion(b *testing.B, size int) {
 	// Add a batch of transactions to a pool one by one
 	pool, key := setupTxPool()
 	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
@@ -507,22 +901,22 @@ func benchmarkValidatePool(b *testing.B, size int) {
 
 	for i := 0; i < size; i++ {
 		tx := transaction(uint64(i), big.NewInt(100000), key)
-		pool.addTx(tx.Hash(), account, tx)
+		pool.promoteTx(account, tx.Hash(), tx)
 	}
 	// Benchmark the speed of pool validation
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		pool.validatePool()
+		pool.demoteUnexecutables(state)
 	}
 }
 
 // Benchmarks the speed of scheduling the contents of the future queue of the
 // transaction pool.
-func BenchmarkCheckQueue100(b *testing.B)   { benchmarkCheckQueue(b, 100) }
-func BenchmarkCheckQueue1000(b *testing.B)  { benchmarkCheckQueue(b, 1000) }
-func BenchmarkCheckQueue10000(b *testing.B) { benchmarkCheckQueue(b, 10000) }
+func BenchmarkFuturePromotion100(b *testing.B)   { benchmarkFuturePromotion(b, 100) }
+func BenchmarkFuturePromotion1000(b *testing.B)  { benchmarkFuturePromotion(b, 1000) }
+func BenchmarkFuturePromotion10000(b *testing.B) { benchmarkFuturePromotion(b, 10000) }
 
-func benchmarkCheckQueue(b *testing.B, size int) {
+func benchmarkFuturePromotion(b *testing.B, size int) {
 	// Add a batch of transactions to a pool one by one
 	pool, key := setupTxPool()
 	account, _ := deriveSender(transaction(0, big.NewInt(0), key))
@@ -531,11 +925,11 @@ func benchmarkCheckQueue(b *testing.B, size int) {
 
 	for i := 0; i < size; i++ {
 		tx := transaction(uint64(1+i), big.NewInt(100000), key)
-		pool.queueTx(tx.Hash(), tx)
+		pool.enqueueTx(tx.Hash(), tx)
 	}
 	// Benchmark the speed of pool validation
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		pool.checkQueue()
+		pool.promoteExecutables(nil)
 	}
 }
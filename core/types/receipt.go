@@ -17,6 +17,7 @@
 package types
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"math/big"
@@ -34,7 +35,16 @@ const (
 	TxStatusUnknown ReceiptStatus = 0xFF
 )
 
+var (
+	receiptStatusFailedRLP     = []byte{}
+	receiptStatusSuccessfulRLP = []byte{0x01}
+)
+
 // Receipt represents the results of a transaction.
+//
+// Receipts created before the atlantis fork feature commit to the
+// intermediate state root in PostState. Afterwards PostState is left empty
+// and Status is a consensus field instead, see EIP-658.
 type Receipt struct {
 	// Consensus fields
 	PostState         []byte
@@ -55,9 +65,37 @@ func NewReceipt(root []byte, cumulativeGasUsed *big.Int) *Receipt {
 }
 
 // EncodeRLP implements rlp.Encoder, and flattens the consensus fields of a receipt
-// into an RLP stream.
+// into an RLP stream. If no post state is set, the status is encoded in its place.
 func (r *Receipt) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, []interface{}{r.PostState, r.CumulativeGasUsed, r.Bloom, r.Logs})
+	return rlp.Encode(w, []interface{}{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs})
+}
+
+// statusEncoding returns the post state root of the receipt, or the EIP-658
+// status flag if the receipt has none.
+func (r *Receipt) statusEncoding() []byte {
+	if len(r.PostState) > 0 {
+		return r.PostState
+	}
+	if r.Status == TxFailure {
+		return receiptStatusFailedRLP
+	}
+	return receiptStatusSuccessfulRLP
+}
+
+// setStatus loads the post state root or the EIP-658 status flag from its
+// consensus encoding.
+func (r *Receipt) setStatus(postStateOrStatus []byte) error {
+	switch {
+	case bytes.Equal(postStateOrStatus, receiptStatusSuccessfulRLP):
+		r.Status = TxSuccess
+	case bytes.Equal(postStateOrStatus, receiptStatusFailedRLP):
+		r.Status = TxFailure
+	case len(postStateOrStatus) == len(common.Hash{}):
+		r.PostState = postStateOrStatus
+	default:
+		return fmt.Errorf("invalid receipt status %x", postStateOrStatus)
+	}
+	return nil
 }
 
 // DecodeRLP implements rlp.Decoder, and loads the consensus fields of a receipt
@@ -72,7 +110,10 @@ func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
 	if err := s.Decode(&receipt); err != nil {
 		return err
 	}
-	r.PostState, r.CumulativeGasUsed, r.Bloom, r.Logs = receipt.PostState, receipt.CumulativeGasUsed, receipt.Bloom, receipt.Logs
+	if err := r.setStatus(receipt.PostState); err != nil {
+		return err
+	}
+	r.CumulativeGasUsed, r.Bloom, r.Logs = receipt.CumulativeGasUsed, receipt.Bloom, receipt.Logs
 	return nil
 }
 
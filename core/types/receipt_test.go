@@ -0,0 +1,56 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+func TestReceiptStatusEncoding(t *testing.T) {
+	root := common.HexToHash("0x01").Bytes()
+
+	tests := []struct {
+		receipt   *Receipt
+		postState []byte
+		status    ReceiptStatus
+	}{
+		{&Receipt{PostState: root, CumulativeGasUsed: big.NewInt(1), Status: TxSuccess}, root, TxStatusUnknown},
+		{&Receipt{CumulativeGasUsed: big.NewInt(1), Status: TxSuccess}, nil, TxSuccess},
+		{&Receipt{CumulativeGasUsed: big.NewInt(1), Status: TxFailure}, nil, TxFailure},
+	}
+	for i, tt := range tests {
+		enc, err := rlp.EncodeToBytes(tt.receipt)
+		if err != nil {
+			t.Fatalf("test %d: encode error: %v", i, err)
+		}
+		dec := &Receipt{Status: TxStatusUnknown}
+		if err := rlp.DecodeBytes(enc, dec); err != nil {
+			t.Fatalf("test %d: decode error: %v", i, err)
+		}
+		if !bytes.Equal(dec.PostState, tt.postState) {
+			t.Errorf("test %d: post state mismatch: have %x, want %x", i, dec.PostState, tt.postState)
+		}
+		if dec.Status != tt.status {
+			t.Errorf("test %d: status mismatch: have %v, want %v", i, dec.Status, tt.status)
+		}
+	}
+}
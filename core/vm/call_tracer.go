@@ -0,0 +1,96 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereumproject/go-ethereum/common"
+)
+
+// CallFrame is a single call frame (internal transaction) captured by the
+// CallTracer, together with the frames it spawned.
+type CallFrame struct {
+	Type    OpCode
+	From    common.Address
+	To      common.Address
+	Value   *big.Int // nil for DELEGATECALL, which does not transfer value
+	Gas     *big.Int
+	GasUsed *big.Int
+	Input   []byte
+	Output  []byte
+	Err     error
+	Calls   []*CallFrame
+}
+
+// CallTracer is a Tracer recording the tree of call frames of a
+// transaction execution. It ignores the individual steps of the VM.
+type CallTracer struct {
+	root  *CallFrame
+	stack []*CallFrame
+}
+
+// NewCallTracer returns a new call frame tracer.
+func NewCallTracer() *CallTracer {
+	return &CallTracer{}
+}
+
+// CaptureState is a no-op, the call tracer only records call frames.
+func (t *CallTracer) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnter opens a new call frame as child of the current one.
+func (t *CallTracer) CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+	frame := &CallFrame{
+		Type:  typ,
+		From:  from,
+		To:    to,
+		Gas:   new(big.Int).Set(gas),
+		Input: common.CopyBytes(input),
+	}
+	if value != nil {
+		frame.Value = new(big.Int).Set(value)
+	}
+
+	if len(t.stack) == 0 {
+		t.root = frame
+	} else {
+		parent := t.stack[len(t.stack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	}
+	t.stack = append(t.stack, frame)
+}
+
+// CaptureExit closes the current call frame.
+func (t *CallTracer) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+	if len(t.stack) == 0 {
+		return
+	}
+	frame := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+
+	frame.Output = common.CopyBytes(output)
+	frame.GasUsed = new(big.Int).Set(gasUsed)
+	frame.Err = err
+}
+
+// Frame returns the outermost call frame of the traced execution, or nil if
+// nothing was executed.
+func (t *CallTracer) Frame() *CallFrame {
+	return t.root
+}
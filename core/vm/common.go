@@ -17,6 +17,7 @@
 package vm
 
 import (
+	"math"
 	"math/big"
 
 	"github.com/ethereumproject/go-ethereum/common"
@@ -41,12 +42,16 @@ var (
 )
 
 // calculates the memory size required for a step
-func calcMemSize(off, l *big.Int) *big.Int {
-	if l.Sign() == 0 {
+func calcMemSize(off, l *Word) *big.Int {
+	if l.IsZero() {
 		return new(big.Int)
 	}
-
-	return new(big.Int).Add(off, l)
+	if off.IsUint64() && l.IsUint64() {
+		if size := off.Uint64() + l.Uint64(); size >= off.Uint64() {
+			return new(big.Int).SetUint64(size)
+		}
+	}
+	return new(big.Int).Add(off.ToBig(), l.ToBig())
 }
 
 // calculates the quadratic gas
@@ -80,9 +85,34 @@ func u256(n int64) *big.Int {
 	return big.NewInt(n)
 }
 
+// clampUint64 returns w as a uint64, or math.MaxUint64 if it doesn't fit.
+func clampUint64(w *Word) uint64 {
+	if !w.IsUint64() {
+		return math.MaxUint64
+	}
+	return w.Uint64()
+}
+
 // getData returns a slice from the data based on the start and size and pads
 // up to size with zero's. This function is overflow safe.
-func getData(data []byte, start, size *big.Int) []byte {
+func getData(data []byte, start, size uint64) []byte {
+	length := uint64(len(data))
+	if start > length {
+		start = length
+	}
+	end := start + size
+	if end > length || end < start {
+		end = length
+	}
+	if end-start == size {
+		return data[start:end]
+	}
+	return common.RightPadBytes(data[start:end], int(size))
+}
+
+// getDataBig returns a slice from the data based on the start and size and
+// pads up to size with zero's. This function is overflow safe.
+func getDataBig(data []byte, start, size *big.Int) []byte {
 	dlen := big.NewInt(int64(len(data)))
 
 	s := common.BigMin(start, dlen)
@@ -53,6 +53,11 @@ type Contract struct {
 	Args []byte
 
 	DelegateCall bool
+	// ReadOnly is set for the duration of a STATICCALL and is inherited by
+	// all nested calls, disallowing any state modifications.
+	ReadOnly bool
+
+	returnData []byte // output of the last call made from this contract
 }
 
 // NewContract returns a new contract environment for the execution of EVM.
@@ -62,6 +67,7 @@ func NewContract(caller ContractRef, object ContractRef, value, gas, price *big.
 	if parent, ok := caller.(*Contract); ok {
 		// Reuse JUMPDEST analysis from parent context if available.
 		c.jumpdests = parent.jumpdests
+		c.ReadOnly = parent.ReadOnly
 	} else {
 		c.jumpdests = make(destinations)
 	}
@@ -17,22 +17,30 @@
 package vm
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/crypto/bn256"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 )
 
+var (
+	errBn256InvalidPoint  = errors.New("bn256: invalid point")
+	errBn256InvalidLength = errors.New("bn256: invalid pairing input length")
+)
+
 // PrecompiledAccount represents a native ethereum contract
 type PrecompiledAccount struct {
-	Gas func(l int) *big.Int
-	fn  func(in []byte) []byte
+	Gas func(in []byte) *big.Int
+	fn  func(in []byte) ([]byte, error)
 }
 
 // Call calls the native function
-func (self PrecompiledAccount) Call(in []byte) []byte {
+func (self PrecompiledAccount) Call(in []byte) ([]byte, error) {
 	return self.fn(in)
 }
 
@@ -42,43 +50,92 @@ var Precompiled = PrecompiledContracts()
 // PrecompiledContracts returns the default set of precompiled ethereum
 // contracts defined by the ethereum yellow paper.
 func PrecompiledContracts() map[string]*PrecompiledAccount {
-	return map[string]*PrecompiledAccount{
-		// ECRECOVER
-		string(common.LeftPadBytes([]byte{1}, 20)): {func(l int) *big.Int {
-			return big.NewInt(3000)
-		}, ecrecoverFunc},
+	contracts := make(map[string]*PrecompiledAccount)
+	for _, name := range []string{"ecrecover", "sha256", "ripemd160", "identity"} {
+		c := precompiledRegistry[name]
+		contracts[string(c.address.Bytes())] = c.account
+	}
+	return contracts
+}
+
+// AllPrecompiledContracts returns every precompiled contract known to the
+// EVM at its mainnet address.
+func AllPrecompiledContracts() map[string]*PrecompiledAccount {
+	contracts := make(map[string]*PrecompiledAccount)
+	for _, c := range precompiledRegistry {
+		contracts[string(c.address.Bytes())] = c.account
+	}
+	return contracts
+}
 
-		// SHA256
-		string(common.LeftPadBytes([]byte{2}, 20)): {func(l int) *big.Int {
-			n := big.NewInt(int64(l+31) / 32)
-			n.Mul(n, big.NewInt(12))
-			return n.Add(n, big.NewInt(60))
-		}, sha256Func},
+// precompiledRegistry holds all precompiled contracts known to the EVM by
+// name, along with the address they are deployed at on ethereum mainnet.
+// Contracts not part of the default set can be enabled per chain with the
+// "precompiles" fork feature.
+var precompiledRegistry = map[string]struct {
+	address common.Address
+	account *PrecompiledAccount
+}{
+	"ecrecover": {common.BytesToAddress([]byte{1}), &PrecompiledAccount{func(in []byte) *big.Int {
+		return big.NewInt(3000)
+	}, ecrecoverFunc}},
 
-		// RIPEMD160
-		string(common.LeftPadBytes([]byte{3}, 20)): {func(l int) *big.Int {
-			n := big.NewInt(int64(l+31) / 32)
-			n.Mul(n, big.NewInt(120))
-			return n.Add(n, big.NewInt(600))
-		}, ripemd160Func},
+	"sha256": {common.BytesToAddress([]byte{2}), &PrecompiledAccount{func(in []byte) *big.Int {
+		n := big.NewInt(int64(len(in)+31) / 32)
+		n.Mul(n, big.NewInt(12))
+		return n.Add(n, big.NewInt(60))
+	}, sha256Func}},
+
+	"ripemd160": {common.BytesToAddress([]byte{3}), &PrecompiledAccount{func(in []byte) *big.Int {
+		n := big.NewInt(int64(len(in)+31) / 32)
+		n.Mul(n, big.NewInt(120))
+		return n.Add(n, big.NewInt(600))
+	}, ripemd160Func}},
+
+	"identity": {common.BytesToAddress([]byte{4}), &PrecompiledAccount{func(in []byte) *big.Int {
+		n := big.NewInt(int64(len(in)+31) / 32)
+		n.Mul(n, big.NewInt(3))
+		return n.Add(n, big.NewInt(15))
+	}, memCpy}},
+
+	// EIP-198
+	"modexp": {common.BytesToAddress([]byte{5}), &PrecompiledAccount{modExpGas, modExpFunc}},
+
+	// EIP-196 and EIP-197
+	"bn256Add": {common.BytesToAddress([]byte{6}), &PrecompiledAccount{func(in []byte) *big.Int {
+		return big.NewInt(500)
+	}, bn256AddFunc}},
+
+	"bn256ScalarMul": {common.BytesToAddress([]byte{7}), &PrecompiledAccount{func(in []byte) *big.Int {
+		return big.NewInt(40000)
+	}, bn256ScalarMulFunc}},
+
+	"bn256Pairing": {common.BytesToAddress([]byte{8}), &PrecompiledAccount{func(in []byte) *big.Int {
+		n := big.NewInt(int64(len(in) / 192))
+		n.Mul(n, big.NewInt(80000))
+		return n.Add(n, big.NewInt(100000))
+	}, bn256PairingFunc}},
+}
 
-		string(common.LeftPadBytes([]byte{4}, 20)): {func(l int) *big.Int {
-			n := big.NewInt(int64(l+31) / 32)
-			n.Mul(n, big.NewInt(3))
-			return n.Add(n, big.NewInt(15))
-		}, memCpy},
+// PrecompiledContract looks up a precompiled contract by name, returning
+// its mainnet address and implementation.
+func PrecompiledContract(name string) (common.Address, *PrecompiledAccount, error) {
+	c, ok := precompiledRegistry[name]
+	if !ok {
+		return common.Address{}, nil, fmt.Errorf("unknown precompiled contract %q", name)
 	}
+	return c.address, c.account, nil
 }
 
-func sha256Func(in []byte) []byte {
-	return crypto.Sha256(in)
+func sha256Func(in []byte) ([]byte, error) {
+	return crypto.Sha256(in), nil
 }
 
-func ripemd160Func(in []byte) []byte {
-	return common.LeftPadBytes(crypto.Ripemd160(in), 32)
+func ripemd160Func(in []byte) ([]byte, error) {
+	return common.LeftPadBytes(crypto.Ripemd160(in), 32), nil
 }
 
-func ecrecoverFunc(in []byte) []byte {
+func ecrecoverFunc(in []byte) ([]byte, error) {
 	in = common.RightPadBytes(in, 128)
 	// "in" is (hash, v, r, s), each 32 bytes
 	// but for ecrecover we want (r, s, v)
@@ -92,7 +149,7 @@ func ecrecoverFunc(in []byte) []byte {
 	// tighter sig s values in homestead only apply to tx sigs
 	if !crypto.ValidateSignatureValues(v, r, s, false) {
 		glog.V(logger.Detail).Infof("ECRECOVER error: v, r or s value invalid")
-		return nil
+		return nil, nil
 	}
 
 	// v needs to be at the end and normalized for libsecp256k1
@@ -103,13 +160,153 @@ func ecrecoverFunc(in []byte) []byte {
 	// make sure the public key is a valid one
 	if err != nil {
 		glog.V(logger.Detail).Infoln("ECRECOVER error: ", err)
-		return nil
+		return nil, nil
 	}
 
 	// the first byte of pubkey is bitcoin heritage
-	return common.LeftPadBytes(crypto.Keccak256(pubKey[1:])[12:], 32)
+	return common.LeftPadBytes(crypto.Keccak256(pubKey[1:])[12:], 32), nil
+}
+
+func memCpy(in []byte) ([]byte, error) {
+	return in, nil
+}
+
+// modExpLengths returns the base, exponent and modulus lengths of a modexp
+// input.
+func modExpLengths(in []byte) (baseLen, expLen, modLen *big.Int) {
+	baseLen = new(big.Int).SetBytes(getDataBig(in, big.NewInt(0), big.NewInt(32)))
+	expLen = new(big.Int).SetBytes(getDataBig(in, big.NewInt(32), big.NewInt(32)))
+	modLen = new(big.Int).SetBytes(getDataBig(in, big.NewInt(64), big.NewInt(32)))
+	return
+}
+
+// modExpGas calculates the gas of a modexp call as defined by EIP-198:
+// mult_complexity(max(len(B), len(M))) * max(adjusted_len(E), 1) / 20
+func modExpGas(in []byte) *big.Int {
+	baseLen, expLen, modLen := modExpLengths(in)
+
+	// the adjusted exponent length is derived from the first 32 bytes of E
+	var expHead *big.Int
+	if expLen.Cmp(big.NewInt(32)) > 0 {
+		expHead = new(big.Int).SetBytes(getDataBig(in, new(big.Int).Add(big.NewInt(96), baseLen), big.NewInt(32)))
+	} else {
+		expHead = new(big.Int).SetBytes(getDataBig(in, new(big.Int).Add(big.NewInt(96), baseLen), expLen))
+	}
+	adjExpLen := new(big.Int)
+	if expLen.Cmp(big.NewInt(32)) > 0 {
+		adjExpLen.Sub(expLen, big.NewInt(32))
+		adjExpLen.Mul(adjExpLen, big.NewInt(8))
+	}
+	if bitlen := expHead.BitLen(); bitlen > 0 {
+		adjExpLen.Add(adjExpLen, big.NewInt(int64(bitlen-1)))
+	}
+	if adjExpLen.Sign() == 0 {
+		adjExpLen.SetInt64(1)
+	}
+
+	// mult_complexity(x) is x² for x <= 64, x²/4 + 96x - 3072 for
+	// x <= 1024 and x²/16 + 480x - 199680 otherwise
+	x := common.BigMax(baseLen, modLen)
+	gas := new(big.Int).Mul(x, x)
+	switch {
+	case x.Cmp(big.NewInt(64)) <= 0:
+	case x.Cmp(big.NewInt(1024)) <= 0:
+		gas.Div(gas, big.NewInt(4))
+		gas.Add(gas, new(big.Int).Mul(x, big.NewInt(96)))
+		gas.Sub(gas, big.NewInt(3072))
+	default:
+		gas.Div(gas, big.NewInt(16))
+		gas.Add(gas, new(big.Int).Mul(x, big.NewInt(480)))
+		gas.Sub(gas, big.NewInt(199680))
+	}
+	gas.Mul(gas, adjExpLen)
+	return gas.Div(gas, big.NewInt(20))
 }
 
-func memCpy(in []byte) []byte {
-	return in
+// modExpFunc computes B^E % M for the input <len(B)> <len(E)> <len(M)> B E M.
+// The result is left padded to len(M).
+func modExpFunc(in []byte) ([]byte, error) {
+	baseLen, expLen, modLen := modExpLengths(in)
+	if baseLen.Sign() == 0 && modLen.Sign() == 0 {
+		return []byte{}, nil
+	}
+	// the lengths are bounded by the gas paid for the call
+	var (
+		start = big.NewInt(96)
+		base  = new(big.Int).SetBytes(getDataBig(in, start, baseLen))
+		exp   = new(big.Int).SetBytes(getDataBig(in, start.Add(start, baseLen), expLen))
+		mod   = new(big.Int).SetBytes(getDataBig(in, start.Add(start, expLen), modLen))
+	)
+	if mod.Sign() == 0 {
+		return make([]byte, modLen.Uint64()), nil
+	}
+	return common.LeftPadBytes(base.Exp(base, exp, mod).Bytes(), int(modLen.Uint64())), nil
+}
+
+// newBn256G1 unmarshals a point of G1 as used by the bn256 contracts.
+func newBn256G1(blob []byte) (*bn256.G1, error) {
+	p, ok := new(bn256.G1).Unmarshal(blob)
+	if !ok {
+		return nil, errBn256InvalidPoint
+	}
+	return p, nil
+}
+
+// newBn256G2 unmarshals a point of G2 as used by the bn256 contracts.
+func newBn256G2(blob []byte) (*bn256.G2, error) {
+	p, ok := new(bn256.G2).Unmarshal(blob)
+	if !ok {
+		return nil, errBn256InvalidPoint
+	}
+	return p, nil
+}
+
+func bn256AddFunc(in []byte) ([]byte, error) {
+	in = common.RightPadBytes(in, 128)
+
+	x, err := newBn256G1(in[:64])
+	if err != nil {
+		return nil, err
+	}
+	y, err := newBn256G1(in[64:128])
+	if err != nil {
+		return nil, err
+	}
+	return new(bn256.G1).Add(x, y).Marshal(), nil
+}
+
+func bn256ScalarMulFunc(in []byte) ([]byte, error) {
+	in = common.RightPadBytes(in, 96)
+
+	p, err := newBn256G1(in[:64])
+	if err != nil {
+		return nil, err
+	}
+	return new(bn256.G1).ScalarMult(p, new(big.Int).SetBytes(in[64:96])).Marshal(), nil
+}
+
+func bn256PairingFunc(in []byte) ([]byte, error) {
+	if len(in)%192 != 0 {
+		return nil, errBn256InvalidLength
+	}
+	var (
+		cs []*bn256.G1
+		ts []*bn256.G2
+	)
+	for i := 0; i < len(in); i += 192 {
+		c, err := newBn256G1(in[i : i+64])
+		if err != nil {
+			return nil, err
+		}
+		t, err := newBn256G2(in[i+64 : i+192])
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+		ts = append(ts, t)
+	}
+	if bn256.PairingCheck(cs, ts) {
+		return common.LeftPadBytes([]byte{1}, 32), nil
+	}
+	return make([]byte, 32), nil
 }
@@ -0,0 +1,177 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+)
+
+const (
+	bn256G1        = "00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002"
+	bn256G1Neg     = "000000000000000000000000000000000000000000000000000000000000000130644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd45"
+	bn256G1Double  = "030644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd315ed738c0e0a7c92e7845f96b2ae9c0a68a6a449e3538fc7ff3ebf7a5a18a2c4"
+	bn256G2        = "198e9393920d483a7260bfb731fb5d25f1aa493335a9e71297e485b7aef312c21800deef121f1e76426a00665e5c4479674322d4f75edadd46debd5cd992f6ed090689d0585ff075ec9e99ad690c3395bc4b313370b38ef355acdadcd122975b12c85ea5db8c6deb4aab71808dcb408fe3d1e7690c43d37b4ce6cc0166fa7daa"
+	secp256k1P     = "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"
+	secp256k1PSub1 = "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2e"
+)
+
+type precompiledTest struct {
+	name     string
+	contract string
+	input    string
+	expected string
+	gas      int64
+	fails    bool
+}
+
+var precompiledTests = []precompiledTest{
+	{
+		name:     "identity",
+		contract: "identity",
+		input:    "deadbeef",
+		expected: "deadbeef",
+		gas:      18,
+	},
+	{
+		// 3^(p-1) % p == 1 for the secp256k1 field prime (EIP-198 example)
+		name:     "modexp fermat",
+		contract: "modexp",
+		input:    "0000000000000000000000000000000000000000000000000000000000000001" + "0000000000000000000000000000000000000000000000000000000000000020" + "0000000000000000000000000000000000000000000000000000000000000020" + "03" + secp256k1PSub1 + secp256k1P,
+		expected: "0000000000000000000000000000000000000000000000000000000000000001",
+		gas:      13056,
+	},
+	{
+		// a zero length base returns zero (EIP-198 example)
+		name:     "modexp zero base",
+		contract: "modexp",
+		input:    "0000000000000000000000000000000000000000000000000000000000000000" + "0000000000000000000000000000000000000000000000000000000000000020" + "0000000000000000000000000000000000000000000000000000000000000020" + secp256k1PSub1 + secp256k1P,
+		expected: "0000000000000000000000000000000000000000000000000000000000000000",
+		gas:      13056,
+	},
+	{
+		name:     "modexp zero modulus",
+		contract: "modexp",
+		input:    "0000000000000000000000000000000000000000000000000000000000000001" + "0000000000000000000000000000000000000000000000000000000000000001" + "0000000000000000000000000000000000000000000000000000000000000001" + "02" + "03" + "00",
+		expected: "00",
+		gas:      0,
+	},
+	{
+		name:     "modexp empty",
+		contract: "modexp",
+		input:    "",
+		expected: "",
+		gas:      0,
+	},
+	{
+		name:     "bn256Add double",
+		contract: "bn256Add",
+		input:    bn256G1 + bn256G1,
+		expected: bn256G1Double,
+		gas:      500,
+	},
+	{
+		name:     "bn256Add infinity",
+		contract: "bn256Add",
+		input:    "",
+		expected: "00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+		gas:      500,
+	},
+	{
+		name:     "bn256Add invalid point",
+		contract: "bn256Add",
+		input:    "00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000003",
+		gas:      500,
+		fails:    true,
+	},
+	{
+		name:     "bn256ScalarMul double",
+		contract: "bn256ScalarMul",
+		input:    bn256G1 + "0000000000000000000000000000000000000000000000000000000000000002",
+		expected: bn256G1Double,
+		gas:      40000,
+	},
+	{
+		name:     "bn256Pairing empty",
+		contract: "bn256Pairing",
+		input:    "",
+		expected: "0000000000000000000000000000000000000000000000000000000000000001",
+		gas:      100000,
+	},
+	{
+		name:     "bn256Pairing valid",
+		contract: "bn256Pairing",
+		input:    bn256G1 + bn256G2 + bn256G1Neg + bn256G2,
+		expected: "0000000000000000000000000000000000000000000000000000000000000001",
+		gas:      260000,
+	},
+	{
+		name:     "bn256Pairing invalid",
+		contract: "bn256Pairing",
+		input:    bn256G1 + bn256G2 + bn256G1 + bn256G2,
+		expected: "0000000000000000000000000000000000000000000000000000000000000000",
+		gas:      260000,
+	},
+	{
+		name:     "bn256Pairing bad length",
+		contract: "bn256Pairing",
+		input:    bn256G1,
+		gas:      100000,
+		fails:    true,
+	},
+}
+
+func TestPrecompiledContracts(t *testing.T) {
+	for _, test := range precompiledTests {
+		_, p, err := PrecompiledContract(test.contract)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		in := common.FromHex(test.input)
+		if gas := p.Gas(in); gas.Int64() != test.gas {
+			t.Errorf("%s: gas mismatch: have %v, want %d", test.name, gas, test.gas)
+		}
+		out, err := p.Call(in)
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if want := common.FromHex(test.expected); !bytes.Equal(out, want) {
+			t.Errorf("%s: output mismatch: have %x, want %x", test.name, out, want)
+		}
+	}
+}
+
+func TestPrecompiledContractsDefault(t *testing.T) {
+	if len(Precompiled) != 4 {
+		t.Errorf("expected 4 default contracts, got %d", len(Precompiled))
+	}
+	if len(AllPrecompiledContracts()) != 8 {
+		t.Errorf("expected 8 contracts, got %d", len(AllPrecompiledContracts()))
+	}
+	if _, _, err := PrecompiledContract("unknown"); err == nil {
+		t.Error("expected error for unknown contract")
+	}
+}
@@ -29,6 +29,12 @@ type RuleSet interface {
 	// GasTable returns the gas prices for this phase, which is based on
 	// block number passed in.
 	GasTable(*big.Int) *GasTable
+	// IsAtlantis returns whether the Byzantium opcodes (REVERT,
+	// RETURNDATASIZE, RETURNDATACOPY and STATICCALL) are enabled.
+	IsAtlantis(*big.Int) bool
+	// Precompiled returns the precompiled contracts active at the given
+	// block number, keyed by address.
+	Precompiled(*big.Int) map[string]*PrecompiledAccount
 }
 
 // Environment is an EVM requirement and helper which allows access to outside
@@ -74,6 +80,8 @@ type Environment interface {
 	CallCode(me ContractRef, addr common.Address, data []byte, gas, price, value *big.Int) ([]byte, error)
 	// Same as CallCode except sender and value is propagated from parent to child scope
 	DelegateCall(me ContractRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error)
+	// Call another contract, disallowing any state modifications for the duration of the call
+	StaticCall(me ContractRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error)
 	// Create a new contract
 	Create(me ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error)
 }
@@ -25,13 +25,12 @@ import (
 
 var callStipend = big.NewInt(2300) // Free gas given at beginning of call.
 
-type instrFn func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack)
+type instrFn func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack)
 
 type instruction struct {
-	op   OpCode
-	pc   uint64
-	fn   instrFn
-	data *big.Int
+	op OpCode
+	pc uint64
+	fn instrFn
 
 	gas   *big.Int
 	spop  int
@@ -48,371 +47,315 @@ func (instr instruction) Op() OpCode {
 	return instr.op
 }
 
-func opAdd(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Add(x, y)))
+func opAdd(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.Add(&x, y)
 }
 
-func opSub(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Sub(x, y)))
+func opSub(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.Sub(&x, y)
 }
 
-func opMul(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Mul(x, y)))
+func opMul(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.Mul(&x, y)
 }
 
-func opDiv(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if y.Sign() != 0 {
-		stack.push(U256(x.Div(x, y)))
-	} else {
-		stack.push(new(big.Int))
-	}
+func opDiv(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.Div(&x, y)
 }
 
-func opSdiv(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	if y.Sign() == 0 {
-		stack.push(new(big.Int))
-		return
-	} else {
-		n := new(big.Int)
-		if new(big.Int).Mul(x, y).Sign() < 0 {
-			n.SetInt64(-1)
-		} else {
-			n.SetInt64(1)
-		}
-
-		res := x.Div(x.Abs(x), y.Abs(y))
-		res.Mul(res, n)
-
-		stack.push(U256(res))
-	}
+func opSdiv(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.SDiv(&x, y)
 }
 
-func opMod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if y.Sign() == 0 {
-		stack.push(new(big.Int))
-	} else {
-		stack.push(U256(x.Mod(x, y)))
-	}
+func opMod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.Mod(&x, y)
 }
 
-func opSmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-
-	if y.Sign() == 0 {
-		stack.push(new(big.Int))
-	} else {
-		n := new(big.Int)
-		if x.Sign() < 0 {
-			n.SetInt64(-1)
-		} else {
-			n.SetInt64(1)
-		}
-
-		res := x.Mod(x.Abs(x), y.Abs(y))
-		res.Mul(res, n)
-
-		stack.push(U256(res))
-	}
+func opSmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.SMod(&x, y)
 }
 
-func opExp(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(U256(x.Exp(x, y, Pow256)))
+func opExp(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	base, exponent := stack.pop(), stack.peek()
+	exponent.Exp(&base, exponent)
 }
 
-func opSignExtend(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	back := stack.pop()
-	if back.Cmp(big.NewInt(31)) < 0 {
-		bit := uint(back.Uint64()*8 + 7)
-		num := stack.pop()
-		mask := back.Lsh(common.Big1, bit)
-		mask.Sub(mask, common.Big1)
-		if common.BitTest(num, int(bit)) {
-			num.Or(num, mask.Not(mask))
-		} else {
-			num.And(num, mask)
-		}
-
-		stack.push(U256(num))
-	}
+func opSignExtend(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	back, num := stack.pop(), stack.peek()
+	num.SignExtend(&back, num)
 }
 
-func opNot(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x := stack.pop()
-	stack.push(U256(x.Not(x)))
+func opNot(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x := stack.peek()
+	x.Not(x)
 }
 
-func opLt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
+func opLt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
 	if x.Cmp(y) < 0 {
-		stack.push(big.NewInt(1))
+		y.SetUint64(1)
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
 }
 
-func opGt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
+func opGt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
 	if x.Cmp(y) > 0 {
-		stack.push(big.NewInt(1))
+		y.SetUint64(1)
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
 }
 
-func opSlt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	if x.Cmp(S256(y)) < 0 {
-		stack.push(big.NewInt(1))
+func opSlt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	if x.Slt(y) {
+		y.SetUint64(1)
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
 }
 
-func opSgt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	if x.Cmp(y) > 0 {
-		stack.push(big.NewInt(1))
+func opSgt(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	if x.Sgt(y) {
+		y.SetUint64(1)
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
 }
 
-func opEq(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	if x.Cmp(y) == 0 {
-		stack.push(big.NewInt(1))
+func opEq(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	if x == *y {
+		y.SetUint64(1)
 	} else {
-		stack.push(new(big.Int))
+		y.Clear()
 	}
 }
 
-func opIszero(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x := stack.pop()
-	if x.Sign() != 0 {
-		stack.push(new(big.Int))
+func opIszero(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x := stack.peek()
+	if x.IsZero() {
+		x.SetUint64(1)
 	} else {
-		stack.push(big.NewInt(1))
+		x.Clear()
 	}
 }
 
-func opAnd(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(x.And(x, y))
+func opAnd(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.And(&x, y)
 }
-func opOr(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(x.Or(x, y))
+func opOr(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.Or(&x, y)
 }
-func opXor(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(x.Xor(x, y))
+func opXor(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y := stack.pop(), stack.peek()
+	y.Xor(&x, y)
 }
-func opByte(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	th, val := stack.pop(), stack.pop()
-	if th.Cmp(big.NewInt(32)) < 0 {
-		byte := big.NewInt(int64(common.LeftPadBytes(val.Bytes(), 32)[th.Int64()]))
-		stack.push(byte)
-	} else {
-		stack.push(new(big.Int))
-	}
+func opByte(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	th, val := stack.pop(), stack.peek()
+	val.Byte(&th)
 }
-func opAddmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y, z := stack.pop(), stack.pop(), stack.pop()
-	if z.Sign() > 0 {
-		add := x.Add(x, y)
-		add.Mod(add, z)
-		stack.push(U256(add))
-	} else {
-		stack.push(new(big.Int))
-	}
+func opAddmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y, z := stack.pop(), stack.pop(), stack.peek()
+	z.AddMod(&x, &y, z)
 }
-func opMulmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	x, y, z := stack.pop(), stack.pop(), stack.pop()
-	if z.Sign() > 0 {
-		mul := x.Mul(x, y)
-		mul.Mod(mul, z)
-		stack.push(U256(mul))
-	} else {
-		stack.push(new(big.Int))
-	}
+func opMulmod(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x, y, z := stack.pop(), stack.pop(), stack.peek()
+	z.MulMod(&x, &y, z)
 }
 
-func opSha3(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	offset, size := stack.pop(), stack.pop()
-	hash := crypto.Keccak256(memory.Get(offset.Int64(), size.Int64()))
+func opSha3(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	offset, size := stack.pop(), stack.peek()
+	hash := crypto.Keccak256(memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64())))
 
-	stack.push(new(big.Int).SetBytes(hash))
+	size.SetBytes(hash)
 }
 
-func opAddress(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).SetBytes(contract.Address().Bytes()))
+func opAddress(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetBytes(contract.Address().Bytes()))
 }
 
-func opBalance(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	addr := common.BigToAddress(stack.pop())
-	balance := env.Db().GetBalance(addr)
-
-	stack.push(new(big.Int).Set(balance))
+func opBalance(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	slot := stack.peek()
+	slot.SetFromBig(env.Db().GetBalance(slot.Address()))
 }
 
-func opOrigin(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(env.Origin().Big())
+func opOrigin(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetBytes(env.Origin().Bytes()))
 }
 
-func opCaller(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(contract.Caller().Big())
+func opCaller(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetBytes(contract.Caller().Bytes()))
 }
 
-func opCallValue(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(contract.value))
+func opCallValue(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetFromBig(contract.value))
 }
 
-func opCalldataLoad(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).SetBytes(getData(contract.Input, stack.pop(), common.Big32)))
+func opCalldataLoad(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	x := stack.peek()
+	x.SetBytes(getData(contract.Input, clampUint64(x), 32))
 }
 
-func opCalldataSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(big.NewInt(int64(len(contract.Input))))
+func opCalldataSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetUint64(uint64(len(contract.Input))))
 }
 
-func opCalldataCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opCalldataCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 	var (
 		mOff = stack.pop()
 		cOff = stack.pop()
 		l    = stack.pop()
 	)
-	memory.Set(mOff.Uint64(), l.Uint64(), getData(contract.Input, cOff, l))
+	memory.Set(mOff.Uint64(), l.Uint64(), getData(contract.Input, clampUint64(&cOff), l.Uint64()))
 }
 
-func opExtCodeSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	addr := common.BigToAddress(stack.pop())
-	l := big.NewInt(int64(env.Db().GetCodeSize(addr)))
-	stack.push(l)
+func opExtCodeSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	slot := stack.peek()
+	slot.SetUint64(uint64(env.Db().GetCodeSize(slot.Address())))
 }
 
-func opCodeSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	l := big.NewInt(int64(len(contract.Code)))
-	stack.push(l)
+func opCodeSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetUint64(uint64(len(contract.Code))))
 }
 
-func opCodeCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opCodeCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 	var (
 		mOff = stack.pop()
 		cOff = stack.pop()
 		l    = stack.pop()
 	)
-	codeCopy := getData(contract.Code, cOff, l)
+	codeCopy := getData(contract.Code, clampUint64(&cOff), l.Uint64())
 
 	memory.Set(mOff.Uint64(), l.Uint64(), codeCopy)
 }
 
-func opExtCodeCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opExtCodeCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 	var (
-		addr = common.BigToAddress(stack.pop())
+		a    = stack.pop()
 		mOff = stack.pop()
 		cOff = stack.pop()
 		l    = stack.pop()
 	)
-	codeCopy := getData(env.Db().GetCode(addr), cOff, l)
+	codeCopy := getData(env.Db().GetCode(a.Address()), clampUint64(&cOff), l.Uint64())
 
 	memory.Set(mOff.Uint64(), l.Uint64(), codeCopy)
 }
 
-func opGasprice(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(contract.Price))
+func opReturnDataSize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetUint64(uint64(len(contract.returnData))))
 }
 
-func opBlockhash(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	num := stack.pop()
+func opReturnDataCopy(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	var (
+		mOff = stack.pop()
+		dOff = stack.pop()
+		l    = stack.pop()
+	)
+	// the bounds have been checked prior to the execution
+	end := dOff.Uint64() + l.Uint64()
+	memory.Set(mOff.Uint64(), l.Uint64(), contract.returnData[dOff.Uint64():end])
+}
 
-	n := new(big.Int).Sub(env.BlockNumber(), common.Big257)
-	if num.Cmp(n) > 0 && num.Cmp(env.BlockNumber()) < 0 {
-		stack.push(env.GetHash(num.Uint64()).Big())
+func opGasprice(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetFromBig(contract.Price))
+}
+
+func opBlockhash(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	num := stack.peek()
+
+	// only the 256 most recent complete blocks are available
+	current := env.BlockNumber().Uint64()
+	if n := num.Uint64(); num.IsUint64() && n < current && (current < 257 || n > current-257) {
+		num.SetBytes(env.GetHash(n).Bytes())
 	} else {
-		stack.push(new(big.Int))
+		num.Clear()
 	}
 }
 
-func opCoinbase(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(env.Coinbase().Big())
+func opCoinbase(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetBytes(env.Coinbase().Bytes()))
 }
 
-func opTimestamp(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.Time())))
+func opTimestamp(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetFromBig(env.Time()))
 }
 
-func opNumber(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.BlockNumber())))
+func opNumber(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetFromBig(env.BlockNumber()))
 }
 
-func opDifficulty(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.Difficulty())))
+func opDifficulty(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetFromBig(env.Difficulty()))
 }
 
-func opGasLimit(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(U256(new(big.Int).Set(env.GasLimit())))
+func opGasLimit(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetFromBig(env.GasLimit()))
 }
 
-func opPop(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opPop(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 	stack.pop()
 }
 
-func opMload(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	offset := stack.pop()
-	val := new(big.Int).SetBytes(memory.Get(offset.Int64(), 32))
-	stack.push(val)
+func opMload(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	v := stack.peek()
+	v.SetBytes(memory.GetPtr(int64(v.Uint64()), 32))
 }
 
-func opMstore(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opMstore(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 	// pop value of the stack
 	mStart, val := stack.pop(), stack.pop()
-	memory.Set(mStart.Uint64(), 32, common.BigToBytes(val, 256))
+	memory.Set32(mStart.Uint64(), &val)
 }
 
-func opMstore8(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	off, val := stack.pop().Int64(), stack.pop().Int64()
-	memory.store[off] = byte(val & 0xff)
+func opMstore8(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	off, val := stack.pop(), stack.pop()
+	memory.store[off.Uint64()] = byte(val.Uint64())
 }
 
-func opSload(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	loc := common.BigToHash(stack.pop())
-	val := env.Db().GetState(contract.Address(), loc).Big()
-	stack.push(val)
+func opSload(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	loc := stack.peek()
+	val := env.Db().GetState(contract.Address(), common.Hash(loc.Bytes32()))
+	loc.SetBytes(val.Bytes())
 }
 
-func opSstore(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	loc := common.BigToHash(stack.pop())
-	val := stack.pop()
-	env.Db().SetState(contract.Address(), loc, common.BigToHash(val))
+func opSstore(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	loc, val := stack.pop(), stack.pop()
+	env.Db().SetState(contract.Address(), common.Hash(loc.Bytes32()), common.Hash(val.Bytes32()))
 }
 
-func opJumpdest(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opJumpdest(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 }
 
-func opPc(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(instr.data))
+func opPc(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetUint64(*pc))
 }
 
-func opMsize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(big.NewInt(int64(memory.Len())))
+func opMsize(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetUint64(uint64(memory.Len())))
 }
 
-func opGas(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	stack.push(new(big.Int).Set(contract.Gas))
+func opGas(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	stack.push(new(Word).SetFromBig(contract.Gas))
 }
 
-func opCreate(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opCreate(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 	var (
 		value        = stack.pop()
 		offset, size = stack.pop(), stack.pop()
-		input        = memory.Get(offset.Int64(), size.Int64())
+		input        = memory.Get(int64(offset.Uint64()), int64(size.Uint64()))
 		gas          = new(big.Int).Set(contract.Gas)
 	)
 	if env.RuleSet().GasTable(env.BlockNumber()).CreateBySuicide != nil {
@@ -421,99 +364,111 @@ func opCreate(instr instruction, pc *uint64, env Environment, contract *Contract
 	}
 
 	contract.UseGas(gas)
-	_, addr, suberr := env.Create(contract, input, gas, contract.Price, value)
+	ret, addr, suberr := env.Create(contract, input, gas, contract.Price, value.ToBig())
+	if suberr == ErrExecutionReverted {
+		contract.returnData = ret
+	} else {
+		contract.returnData = nil
+	}
 	// Push item on the stack based on the returned error. If the ruleset is
 	// homestead we must check for CodeStoreOutOfGasError (homestead only
 	// rule) and treat as an error, if the ruleset is frontier we must
 	// ignore this error and pretend the operation was successful.
 	if env.RuleSet().IsHomestead(env.BlockNumber()) && suberr == CodeStoreOutOfGasError {
-		stack.push(new(big.Int))
+		stack.push(new(Word))
 	} else if suberr != nil && suberr != CodeStoreOutOfGasError {
-		stack.push(new(big.Int))
+		stack.push(new(Word))
 	} else {
-		stack.push(addr.Big())
+		stack.push(new(Word).SetBytes(addr.Bytes()))
 	}
 }
 
-func opCall(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	gas := stack.pop()
-	// pop gas and value of the stack.
-	addr, value := stack.pop(), stack.pop()
-	value = U256(value)
+func opCall(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	// pop gas, address and value of the stack. The gas item has been replaced
+	// by the actual call gas while calculating the cost of the operation.
+	gas, addr, value := stack.pop(), stack.pop(), stack.pop()
 	// pop input size and offset
 	inOffset, inSize := stack.pop(), stack.pop()
 	// pop return size and offset
 	retOffset, retSize := stack.pop(), stack.pop()
 
-	address := common.BigToAddress(addr)
-
 	// Get the arguments from the memory
-	args := memory.Get(inOffset.Int64(), inSize.Int64())
+	args := memory.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
-	if len(value.Bytes()) > 0 {
-		gas.Add(gas, callStipend)
+	callGas := gas.ToBig()
+	if !value.IsZero() {
+		callGas.Add(callGas, callStipend)
 	}
 
-	ret, err := env.Call(contract, address, args, gas, contract.Price, value)
-
-	if err != nil {
-		stack.push(new(big.Int))
-
-	} else {
-		stack.push(big.NewInt(1))
-
-		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
-	}
+	ret, err := env.Call(contract, addr.Address(), args, callGas, contract.Price, value.ToBig())
+	pushCallResult(stack, err)
+	setReturnData(contract, memory, &retOffset, &retSize, ret, err)
 }
 
-func opCallCode(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-	gas := stack.pop()
-	// pop gas and value of the stack.
-	addr, value := stack.pop(), stack.pop()
-	value = U256(value)
+func opCallCode(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	// pop gas, address and value of the stack.
+	gas, addr, value := stack.pop(), stack.pop(), stack.pop()
 	// pop input size and offset
 	inOffset, inSize := stack.pop(), stack.pop()
 	// pop return size and offset
 	retOffset, retSize := stack.pop(), stack.pop()
 
-	address := common.BigToAddress(addr)
-
 	// Get the arguments from the memory
-	args := memory.Get(inOffset.Int64(), inSize.Int64())
+	args := memory.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
-	if len(value.Bytes()) > 0 {
-		gas.Add(gas, callStipend)
+	callGas := gas.ToBig()
+	if !value.IsZero() {
+		callGas.Add(callGas, callStipend)
 	}
 
-	ret, err := env.CallCode(contract, address, args, gas, contract.Price, value)
-
-	if err != nil {
-		stack.push(new(big.Int))
+	ret, err := env.CallCode(contract, addr.Address(), args, callGas, contract.Price, value.ToBig())
+	pushCallResult(stack, err)
+	setReturnData(contract, memory, &retOffset, &retSize, ret, err)
+}
 
-	} else {
-		stack.push(big.NewInt(1))
+func opDelegateCall(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	gas, to, inOffset, inSize, outOffset, outSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
 
-		memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
-	}
+	args := memory.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+	ret, err := env.DelegateCall(contract, to.Address(), args, gas.ToBig(), contract.Price)
+	pushCallResult(stack, err)
+	setReturnData(contract, memory, &outOffset, &outSize, ret, err)
 }
 
-func opDelegateCall(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opStaticCall(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 	gas, to, inOffset, inSize, outOffset, outSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
 
-	toAddr := common.BigToAddress(to)
-	args := memory.Get(inOffset.Int64(), inSize.Int64())
-	ret, err := env.DelegateCall(contract, toAddr, args, gas, contract.Price)
+	args := memory.Get(int64(inOffset.Uint64()), int64(inSize.Uint64()))
+	ret, err := env.StaticCall(contract, to.Address(), args, gas.ToBig(), contract.Price)
+	pushCallResult(stack, err)
+	setReturnData(contract, memory, &outOffset, &outSize, ret, err)
+}
+
+// pushCallResult pushes 1 for a successful call and 0 otherwise.
+func pushCallResult(stack *Stack, err error) {
 	if err != nil {
-		stack.push(new(big.Int))
+		stack.push(new(Word))
 	} else {
-		stack.push(big.NewInt(1))
-		memory.Set(outOffset.Uint64(), outSize.Uint64(), ret)
+		stack.push(new(Word).SetUint64(1))
+	}
+}
+
+// setReturnData copies the output of a successful or reverted call into
+// memory and keeps it around for RETURNDATASIZE and RETURNDATACOPY. The
+// output of any other failed call is discarded.
+func setReturnData(contract *Contract, memory *Memory, offset, size *Word, ret []byte, err error) {
+	if err != nil && err != ErrExecutionReverted {
+		contract.returnData = nil
+		return
 	}
+	memory.Set(offset.Uint64(), size.Uint64(), ret)
+	contract.returnData = ret
 }
 
-func opSuicide(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+func opSuicide(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+	beneficiary := stack.pop()
 	balance := env.Db().GetBalance(contract.Address())
-	env.Db().AddBalance(common.BigToAddress(stack.pop()), balance)
+	env.Db().AddBalance(beneficiary.Address(), balance)
 
 	env.Db().Suicide(contract.Address())
 }
@@ -522,31 +477,31 @@ func opSuicide(instr instruction, pc *uint64, env Environment, contract *Contrac
 
 // make log instruction function
 func makeLog(size int) instrFn {
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 		topics := make([]common.Hash, size)
 		mStart, mSize := stack.pop(), stack.pop()
 		for i := 0; i < size; i++ {
-			topics[i] = common.BigToHash(stack.pop())
+			topic := stack.pop()
+			topics[i] = common.Hash(topic.Bytes32())
 		}
 
-		d := memory.Get(mStart.Int64(), mSize.Int64())
+		d := memory.Get(int64(mStart.Uint64()), int64(mSize.Uint64()))
 		log := NewLog(contract.Address(), topics, d, env.BlockNumber().Uint64())
 		env.AddLog(log)
 	}
 }
 
 // make push instruction function
-func makePush(size uint64, bsize *big.Int) instrFn {
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
-		bytes := getData(contract.Code, new(big.Int).SetUint64(*pc+1), bsize)
-		stack.push(new(big.Int).SetBytes(bytes))
+func makePush(size uint64) instrFn {
+	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
+		stack.push(new(Word).SetBytes(getData(contract.Code, *pc+1, size)))
 		*pc += size
 	}
 }
 
 // make push instruction function
 func makeDup(size int64) instrFn {
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 		stack.dup(int(size))
 	}
 }
@@ -555,7 +510,7 @@ func makeDup(size int64) instrFn {
 func makeSwap(size int64) instrFn {
 	// switch n + 1 otherwise n would be swapped with n
 	size += 1
-	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *stack) {
+	return func(instr instruction, pc *uint64, env Environment, contract *Contract, memory *Memory, stack *Stack) {
 		stack.swap(int(size))
 	}
 }
@@ -23,9 +23,14 @@ import (
 
 type ruleSet struct {
 	hs *big.Int
+	at *big.Int
 }
 
 func (r ruleSet) IsHomestead(n *big.Int) bool { return n.Cmp(r.hs) >= 0 }
+func (r ruleSet) IsAtlantis(n *big.Int) bool  { return r.at != nil && n.Cmp(r.at) >= 0 }
+func (r ruleSet) Precompiled(*big.Int) map[string]*PrecompiledAccount {
+	return Precompiled
+}
 
 func (r ruleSet) GasTable(*big.Int) *GasTable {
 	return &GasTable{
@@ -40,15 +45,40 @@ func (r ruleSet) GasTable(*big.Int) *GasTable {
 }
 
 func TestInit(t *testing.T) {
-	jumpTable := newJumpTable(ruleSet{big.NewInt(1)}, big.NewInt(0))
+	jumpTable := newJumpTable(ruleSet{big.NewInt(1), nil}, big.NewInt(0))
 	if jumpTable[DELEGATECALL].valid {
 		t.Error("Expected DELEGATECALL not to be present")
 	}
 
 	for _, n := range []int64{1, 2, 100} {
-		jumpTable := newJumpTable(ruleSet{big.NewInt(1)}, big.NewInt(n))
+		jumpTable := newJumpTable(ruleSet{big.NewInt(1), nil}, big.NewInt(n))
 		if !jumpTable[DELEGATECALL].valid {
 			t.Error("Expected DELEGATECALL to be present for block", n)
 		}
 	}
 }
+
+func TestInitAtlantis(t *testing.T) {
+	ops := []OpCode{RETURNDATASIZE, RETURNDATACOPY, STATICCALL, REVERT}
+
+	jumpTable := newJumpTable(ruleSet{big.NewInt(1), nil}, big.NewInt(100))
+	for _, op := range ops {
+		if jumpTable[op].valid {
+			t.Errorf("Expected %v not to be present without atlantis", op)
+		}
+	}
+
+	jumpTable = newJumpTable(ruleSet{big.NewInt(1), big.NewInt(10)}, big.NewInt(9))
+	for _, op := range ops {
+		if jumpTable[op].valid {
+			t.Errorf("Expected %v not to be present before atlantis", op)
+		}
+	}
+
+	jumpTable = newJumpTable(ruleSet{big.NewInt(1), big.NewInt(10)}, big.NewInt(10))
+	for _, op := range ops {
+		if !jumpTable[op].valid {
+			t.Errorf("Expected %v to be present at atlantis", op)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereumproject/go-ethereum/common"
+)
+
+// ErrTraceLimitReached is returned by the StructLogger once the configured
+// maximum number of logs has been captured.
+var ErrTraceLimitReached = errors.New("the number of logs reached the specified limit")
+
+// Storage represents a contract's storage.
+type Storage map[common.Hash]common.Hash
+
+// Copy duplicates the current storage.
+func (s Storage) Copy() Storage {
+	cpy := make(Storage)
+	for key, value := range s {
+		cpy[key] = value
+	}
+	return cpy
+}
+
+// Config are the configuration options for the EVM.
+type Config struct {
+	// Tracer, when set, is called on every step of the interpreter
+	// loop with the current VM state and on every call frame.
+	Tracer Tracer
+}
+
+// LogConfig are the configuration options for the structured logger.
+type LogConfig struct {
+	DisableMemory  bool // disable memory capture
+	DisableStack   bool // disable stack capture
+	DisableStorage bool // disable storage capture
+	Limit          int  // maximum number of logs captured, zero means unlimited
+}
+
+// StructLog is emitted by the EVM on each cycle and lists information
+// about the current internal state prior to the execution of the statement.
+type StructLog struct {
+	Pc      uint64
+	Op      OpCode
+	Gas     *big.Int
+	GasCost *big.Int
+	Memory  []byte
+	Stack   []*big.Int
+	Storage Storage
+	Depth   int
+	Err     error
+}
+
+// Tracer is used to collect execution traces from an EVM transaction
+// execution. CaptureState is called for each step of the VM with the
+// current VM state. CaptureEnter and CaptureExit are called when a call
+// frame (CALL, CALLCODE, DELEGATECALL, CREATE or SUICIDE) is entered and
+// left, and are always balanced.
+//
+// Note that reference types are actual VM data structures; make copies
+// if you need to retain them beyond the current call.
+type Tracer interface {
+	CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error
+	CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int)
+	CaptureExit(output []byte, gasUsed *big.Int, err error)
+}
+
+// StructLogger is an EVM state logger and implements Tracer.
+//
+// StructLogger can capture state based on the given log configuration and
+// also keeps track of modified storage items, so that only the storage
+// entries touched by SSTORE are included in the log of each step.
+type StructLogger struct {
+	cfg LogConfig
+
+	logs          []StructLog
+	changedValues map[common.Address]Storage
+}
+
+// NewStructLogger returns a new logger. A nil config captures everything.
+func NewStructLogger(cfg *LogConfig) *StructLogger {
+	logger := &StructLogger{
+		changedValues: make(map[common.Address]Storage),
+	}
+	if cfg != nil {
+		logger.cfg = *cfg
+	}
+	return logger
+}
+
+// CaptureState logs a new structured log message and pushes it out to the
+// environment.
+//
+// CaptureState also tracks SSTORE ops to track dirty values.
+func (l *StructLogger) CaptureState(env Environment, pc uint64, op OpCode, gas, cost *big.Int, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	// a failing step has usually been captured already prior to its
+	// execution; attach the error to that entry instead of duplicating it.
+	if err != nil && len(l.logs) > 0 {
+		last := &l.logs[len(l.logs)-1]
+		if last.Pc == pc && last.Depth == depth && last.Err == nil {
+			last.Err = err
+			return nil
+		}
+	}
+	// check if already accumulated the specified number of logs
+	if l.cfg.Limit != 0 && l.cfg.Limit <= len(l.logs) {
+		return ErrTraceLimitReached
+	}
+
+	// initialise new changed values storage container for this contract
+	// if not present.
+	if l.changedValues[contract.Address()] == nil {
+		l.changedValues[contract.Address()] = make(Storage)
+	}
+
+	// capture SSTORE opcodes and determine the changed value and store
+	// it in the local storage container. NOTE: we do not need to do any
+	// range checks here because that's already handled prior to calling
+	// this function.
+	if op == SSTORE && stack.len() >= 2 {
+		var (
+			value   = common.Hash(stack.back(1).Bytes32())
+			address = common.Hash(stack.back(0).Bytes32())
+		)
+		l.changedValues[contract.Address()][address] = value
+	}
+
+	// copy a snapshot of the current memory state to a new buffer
+	var mem []byte
+	if !l.cfg.DisableMemory {
+		mem = make([]byte, len(memory.Data()))
+		copy(mem, memory.Data())
+	}
+
+	// copy a snapshot of the current stack state to a new buffer
+	var stck []*big.Int
+	if !l.cfg.DisableStack {
+		stck = make([]*big.Int, len(stack.Data()))
+		for i := range stack.Data() {
+			stck[i] = stack.data[i].ToBig()
+		}
+	}
+
+	// copy a snapshot of the current storage to a new container
+	var storage Storage
+	if !l.cfg.DisableStorage {
+		storage = l.changedValues[contract.Address()].Copy()
+	}
+
+	// create a new snapshot of the EVM.
+	log := StructLog{pc, op, new(big.Int).Set(gas), cost, mem, stck, storage, depth, err}
+
+	l.logs = append(l.logs, log)
+	return nil
+}
+
+// CaptureEnter is a no-op, the structured logger only records steps.
+func (l *StructLogger) CaptureEnter(typ OpCode, from, to common.Address, input []byte, gas, value *big.Int) {
+}
+
+// CaptureExit is a no-op, the structured logger only records steps.
+func (l *StructLogger) CaptureExit(output []byte, gasUsed *big.Int, err error) {
+}
+
+// StructLogs returns a list of captured log entries
+func (l *StructLogger) StructLogs() []StructLog {
+	return l.logs
+}
+
+// WriteTrace writes a formatted trace to the given writer
+func WriteTrace(writer io.Writer, logs []StructLog) {
+	for _, log := range logs {
+		fmt.Fprintf(writer, "%-10spc=%08d gas=%v cost=%v", log.Op, log.Pc, log.Gas, log.GasCost)
+		if log.Err != nil {
+			fmt.Fprintf(writer, " ERROR: %v", log.Err)
+		}
+		fmt.Fprintf(writer, "\n")
+
+		if len(log.Stack) > 0 {
+			fmt.Fprintln(writer, "Stack:")
+			for i := len(log.Stack) - 1; i >= 0; i-- {
+				fmt.Fprintf(writer, "%08d  %x\n", len(log.Stack)-i-1, common.LeftPadBytes(log.Stack[i].Bytes(), 32))
+			}
+		}
+		if len(log.Memory) > 0 {
+			fmt.Fprintln(writer, "Memory:")
+			for i := 0; i+32 <= len(log.Memory); i += 32 {
+				fmt.Fprintf(writer, "%03d: % x\n", i/32, log.Memory[i:i+32])
+			}
+		}
+		if len(log.Storage) > 0 {
+			fmt.Fprintln(writer, "Storage:")
+			for h, item := range log.Storage {
+				fmt.Fprintf(writer, "%x: %x\n", h, item)
+			}
+		}
+		fmt.Fprintln(writer)
+	}
+}
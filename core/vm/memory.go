@@ -42,6 +42,17 @@ func (m *Memory) Set(offset, size uint64, value []byte) {
 	}
 }
 
+// Set32 sets the 32 bytes starting at offset to the big-endian value of val.
+func (m *Memory) Set32(offset uint64, val *Word) {
+	// length of store may never be less than offset + size.
+	// The store should be resized PRIOR to setting the memory
+	if offset+32 > uint64(len(m.store)) {
+		panic("INVALID memory: store empty")
+	}
+	b := val.Bytes32()
+	copy(m.store[offset:offset+32], b[:])
+}
+
 // Resize resizes the memory to size
 func (m *Memory) Resize(size uint64) {
 	if uint64(m.Len()) < size {
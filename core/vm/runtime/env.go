@@ -56,7 +56,11 @@ func NewEnv(cfg *Config, state *state.StateDB) vm.Environment {
 		difficulty: cfg.Difficulty,
 		gasLimit:   cfg.GasLimit,
 	}
-	env.evm = vm.New(env)
+	var vmCfg vm.Config
+	if cfg.Debug {
+		vmCfg.Tracer = cfg.Tracer
+	}
+	env.evm = vm.New(env, vmCfg)
 
 	return env
 }
@@ -104,6 +108,10 @@ func (self *Env) DelegateCall(me vm.ContractRef, addr common.Address, data []byt
 	return core.DelegateCall(self, me, addr, data, gas, price)
 }
 
+func (self *Env) StaticCall(me vm.ContractRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error) {
+	return core.StaticCall(self, me, addr, data, gas, price)
+}
+
 func (self *Env) Create(caller vm.ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error) {
 	return core.Create(self, caller, data, gas, price, value)
 }
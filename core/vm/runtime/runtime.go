@@ -32,6 +32,10 @@ import (
 type ruleSet struct{}
 
 func (ruleSet) IsHomestead(*big.Int) bool { return true }
+func (ruleSet) IsAtlantis(*big.Int) bool  { return true }
+func (ruleSet) Precompiled(*big.Int) map[string]*vm.PrecompiledAccount {
+	return vm.AllPrecompiledContracts()
+}
 func (ruleSet) GasTable(*big.Int) *vm.GasTable {
 	return &vm.GasTable{
 		ExtcodeSize:     big.NewInt(700),
@@ -59,6 +63,7 @@ type Config struct {
 	Value       *big.Int
 	DisableJit  bool // "disable" so it's enabled by default
 	Debug       bool
+	Tracer      vm.Tracer // tracer used when Debug is set
 
 	State     *state.StateDB
 	GetHashFn func(n uint64) common.Hash
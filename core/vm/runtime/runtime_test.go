@@ -117,6 +117,209 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestStructLogger(t *testing.T) {
+	logger := vm.NewStructLogger(nil)
+	_, _, err := Execute([]byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.STOP),
+	}, nil, &Config{Debug: true, Tracer: logger})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	logs := logger.StructLogs()
+	ops := []vm.OpCode{vm.PUSH1, vm.PUSH1, vm.SSTORE, vm.STOP}
+	if len(logs) != len(ops) {
+		t.Fatalf("expected %d logs, got %d", len(ops), len(logs))
+	}
+	for i, op := range ops {
+		if logs[i].Op != op {
+			t.Errorf("log %d: expected op %v, got %v", i, op, logs[i].Op)
+		}
+	}
+	if len(logs[2].Stack) != 2 {
+		t.Errorf("expected 2 stack items at SSTORE, got %d", len(logs[2].Stack))
+	}
+	if v := logs[3].Storage[common.Hash{}]; v != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("expected storage slot 0 to be 1, got %x", v)
+	}
+}
+
+func TestStructLoggerConfig(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 3,
+		byte(vm.JUMP),
+	}
+
+	logger := vm.NewStructLogger(&vm.LogConfig{DisableMemory: true, DisableStack: true})
+	if _, _, err := Execute(code, nil, &Config{Debug: true, Tracer: logger}); err == nil {
+		t.Fatal("expected invalid jump error")
+	}
+	logs := logger.StructLogs()
+	if len(logs) != 5 {
+		t.Fatalf("expected 5 logs, got %d", len(logs))
+	}
+	for i, log := range logs {
+		if log.Stack != nil || log.Memory != nil {
+			t.Errorf("log %d: expected no stack and memory capture", i)
+		}
+	}
+	if last := logs[len(logs)-1]; last.Op != vm.JUMP || last.Err == nil {
+		t.Errorf("expected failing JUMP as last log, got %v (err: %v)", last.Op, last.Err)
+	}
+
+	logger = vm.NewStructLogger(&vm.LogConfig{Limit: 2})
+	if _, _, err := Execute(code, nil, &Config{Debug: true, Tracer: logger}); err != vm.ErrTraceLimitReached {
+		t.Fatalf("expected trace limit error, got %v", err)
+	}
+	if len(logger.StructLogs()) != 2 {
+		t.Errorf("expected 2 logs, got %d", len(logger.StructLogs()))
+	}
+}
+
+func TestCallTracer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	callee := common.HexToAddress("0x0a")
+	state.SetCode(callee, []byte{
+		byte(vm.PUSH1), 10,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	})
+
+	tracer := vm.NewCallTracer()
+	_, _, err := Execute([]byte{
+		byte(vm.PUSH1), 32, // out size
+		byte(vm.PUSH1), 0, // out offset
+		byte(vm.PUSH1), 0, // in size
+		byte(vm.PUSH1), 0, // in offset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.PUSH1), 0x0a, // address
+		byte(vm.PUSH2), 0xff, 0xff, // gas
+		byte(vm.CALL),
+		byte(vm.STOP),
+	}, nil, &Config{State: state, Debug: true, Tracer: tracer})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	root := tracer.Frame()
+	if root == nil {
+		t.Fatal("expected a root call frame")
+	}
+	if root.Type != vm.CALL || root.To != common.StringToAddress("contract") {
+		t.Errorf("unexpected root frame: %v to %x", root.Type, root.To)
+	}
+	if len(root.Calls) != 1 {
+		t.Fatalf("expected 1 internal call, got %d", len(root.Calls))
+	}
+	call := root.Calls[0]
+	if call.Type != vm.CALL || call.From != root.To || call.To != callee {
+		t.Errorf("unexpected internal call: %v from %x to %x", call.Type, call.From, call.To)
+	}
+	if num := new(big.Int).SetBytes(call.Output); num.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("expected internal call to return 10, got %v", num)
+	}
+	if call.GasUsed.Sign() <= 0 || call.GasUsed.Cmp(call.Gas) > 0 {
+		t.Errorf("unexpected gas used %v of %v", call.GasUsed, call.Gas)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	callee := common.HexToAddress("0x0a")
+	state.SetCode(callee, []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.PUSH1), 10,
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.REVERT),
+	})
+
+	ret, state, err := Execute([]byte{
+		byte(vm.PUSH1), 0, // out size
+		byte(vm.PUSH1), 0, // out offset
+		byte(vm.PUSH1), 0, // in size
+		byte(vm.PUSH1), 0, // in offset
+		byte(vm.PUSH1), 0, // value
+		byte(vm.PUSH1), 0x0a, // address
+		byte(vm.PUSH2), 0xff, 0xff, // gas
+		byte(vm.CALL),
+		byte(vm.PUSH1), 32,
+		byte(vm.MSTORE), // call result at 32
+		byte(vm.RETURNDATASIZE),
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURNDATACOPY), // revert output at 0
+		byte(vm.PUSH1), 64,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}, nil, &Config{State: state})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	if num := new(big.Int).SetBytes(ret[:32]); num.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("expected revert output 10, got %v", num)
+	}
+	if num := new(big.Int).SetBytes(ret[32:]); num.Sign() != 0 {
+		t.Errorf("expected call to fail, got %v", num)
+	}
+	if v := state.GetState(callee, common.Hash{}); v != (common.Hash{}) {
+		t.Errorf("expected reverted storage, got %x", v)
+	}
+}
+
+func TestStaticCall(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	callee := common.HexToAddress("0x0a")
+	state.SetCode(callee, []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.STOP),
+	})
+
+	ret, state, err := Execute([]byte{
+		byte(vm.PUSH1), 0, // out size
+		byte(vm.PUSH1), 0, // out offset
+		byte(vm.PUSH1), 0, // in size
+		byte(vm.PUSH1), 0, // in offset
+		byte(vm.PUSH1), 0x0a, // address
+		byte(vm.PUSH2), 0xff, 0xff, // gas
+		byte(vm.STATICCALL),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}, nil, &Config{State: state})
+	if err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	if num := new(big.Int).SetBytes(ret); num.Sign() != 0 {
+		t.Errorf("expected static call to fail, got %v", num)
+	}
+	if v := state.GetState(callee, common.Hash{}); v != (common.Hash{}) {
+		t.Errorf("expected unmodified storage, got %x", v)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -18,65 +18,85 @@ package vm
 
 import (
 	"fmt"
-	"math/big"
+	"sync"
 )
 
-// stack is an object for basic stack operations. Items popped to the stack are
-// expected to be changed and modified. stack does not take care of adding newly
-// initialised objects.
-type stack struct {
-	data []*big.Int
+var stackPool = sync.Pool{
+	New: func() interface{} {
+		return &Stack{data: make([]Word, 0, 16)}
+	},
 }
 
-func newstack() *stack {
-	return &stack{}
+// Stack is an object for basic stack operations. Words are stored by value,
+// operations pop their arguments and either push a new word or modify the
+// word returned by peek in place.
+type Stack struct {
+	data []Word
 }
 
-func (st *stack) Data() []*big.Int {
+func newstack() *Stack {
+	return stackPool.Get().(*Stack)
+}
+
+// returnStack puts the stack back into the pool once the frame it was
+// allocated for has finished.
+func returnStack(st *Stack) {
+	st.data = st.data[:0]
+	stackPool.Put(st)
+}
+
+// Data returns the underlying words, the top of the stack being the last
+// element.
+func (st *Stack) Data() []Word {
 	return st.data
 }
 
-func (st *stack) push(d *big.Int) {
+func (st *Stack) push(d *Word) {
 	// NOTE push limit (1024) is checked in baseCheck
-	//stackItem := new(big.Int).Set(d)
-	//st.data = append(st.data, stackItem)
-	st.data = append(st.data, d)
+	st.data = append(st.data, *d)
 }
 
-func (st *stack) pop() (ret *big.Int) {
+func (st *Stack) pop() (ret Word) {
 	ret = st.data[len(st.data)-1]
 	st.data = st.data[:len(st.data)-1]
 	return
 }
 
-func (st *stack) len() int {
+func (st *Stack) len() int {
 	return len(st.data)
 }
 
-func (st *stack) swap(n int) {
+func (st *Stack) swap(n int) {
 	st.data[st.len()-n], st.data[st.len()-1] = st.data[st.len()-1], st.data[st.len()-n]
 }
 
-func (st *stack) dup(n int) {
-	st.push(new(big.Int).Set(st.data[st.len()-n]))
+func (st *Stack) dup(n int) {
+	st.data = append(st.data, st.data[st.len()-n])
+}
+
+// peek returns the top of the stack. The pointer is only valid until the
+// next push.
+func (st *Stack) peek() *Word {
+	return &st.data[st.len()-1]
 }
 
-func (st *stack) peek() *big.Int {
-	return st.data[st.len()-1]
+// back returns the n'th item from the top of the stack, peek being back(0).
+func (st *Stack) back(n int) *Word {
+	return &st.data[st.len()-n-1]
 }
 
-func (st *stack) require(n int) error {
+func (st *Stack) require(n int) error {
 	if st.len() < n {
 		return fmt.Errorf("stack underflow (%d <=> %d)", len(st.data), n)
 	}
 	return nil
 }
 
-func (st *stack) Print() {
+func (st *Stack) Print() {
 	fmt.Println("### stack ###")
 	if len(st.data) > 0 {
 		for i, val := range st.data {
-			fmt.Printf("%-3d  %v\n", i, val)
+			fmt.Printf("%-3d  %v\n", i, &val)
 		}
 	} else {
 		fmt.Println("-- empty --")
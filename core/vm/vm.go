@@ -31,6 +31,12 @@ import (
 var (
 	OutOfGasError          = errors.New("Out of gas")
 	CodeStoreOutOfGasError = errors.New("Contract creation code storage out of gas")
+
+	// ErrExecutionReverted is returned when the contract executed a REVERT.
+	// Unlike other errors it does not consume the remaining gas.
+	ErrExecutionReverted     = errors.New("execution reverted")
+	ErrWriteProtection       = errors.New("write protection")
+	ErrReturnDataOutOfBounds = errors.New("return data out of bounds")
 )
 
 // VirtualMachine is an EVM interface
@@ -43,27 +49,36 @@ type VirtualMachine interface {
 // The EVM will run the byte code VM or JIT VM based on the passed
 // configuration.
 type EVM struct {
-	env       Environment
-	jumpTable vmJumpTable
-	gasTable  GasTable
+	env         Environment
+	jumpTable   vmJumpTable
+	gasTable    GasTable
+	precompiled map[string]*PrecompiledAccount
+	cfg         Config
 }
 
 // New returns a new instance of the EVM.
-func New(env Environment) *EVM {
+func New(env Environment, cfg Config) *EVM {
 	return &EVM{
-		env:       env,
-		jumpTable: newJumpTable(env.RuleSet(), env.BlockNumber()),
-		gasTable:  *env.RuleSet().GasTable(env.BlockNumber()),
+		env:         env,
+		jumpTable:   newJumpTable(env.RuleSet(), env.BlockNumber()),
+		gasTable:    *env.RuleSet().GasTable(env.BlockNumber()),
+		precompiled: env.RuleSet().Precompiled(env.BlockNumber()),
+		cfg:         cfg,
 	}
 }
 
+// Tracer returns the tracer the EVM was configured with, or nil.
+func (evm *EVM) Tracer() Tracer {
+	return evm.cfg.Tracer
+}
+
 // Run loops and evaluates the contract's code with the given input data
 func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 	evm.env.SetDepth(evm.env.Depth() + 1)
 	defer evm.env.SetDepth(evm.env.Depth() - 1)
 
 	if contract.CodeAddr != nil {
-		if p := Precompiled[contract.CodeAddr.Str()]; p != nil {
+		if p := evm.precompiled[contract.CodeAddr.Str()]; p != nil {
 			return evm.RunPrecompiled(p, input, contract)
 		}
 	}
@@ -93,7 +108,7 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 
 		// jump evaluates and checks whether the given jump destination is a valid one
 		// if valid move the `pc` otherwise return an error.
-		jump = func(from uint64, to *big.Int) error {
+		jump = func(from uint64, to *Word) error {
 			if !contract.jumpdests.has(codehash, code, to) {
 				nop := contract.GetOp(to.Uint64())
 				return fmt.Errorf("invalid jump destination (%v) %v", nop, to)
@@ -108,6 +123,7 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 		cost       *big.Int
 	)
 	contract.Input = input
+	defer returnStack(stack)
 
 	if glog.V(logger.Debug) {
 		glog.Infof("running byte VM %x\n", codehash[:4])
@@ -117,15 +133,36 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 		}()
 	}
 
+	// Capture the state of the failing step, if a tracer is configured.
+	// Steps that succeed are captured prior to their execution below.
+	if evm.cfg.Tracer != nil {
+		defer func() {
+			if err != nil {
+				evm.cfg.Tracer.CaptureState(evm.env, pc, op, contract.Gas, cost, mem, stack, contract, evm.env.Depth(), err)
+			}
+		}()
+	}
+
 	for ; ; instrCount++ {
 		// Get the memory location of pc
 		op = contract.GetOp(pc)
+		// Static calls must not modify the state; fail before any gas or
+		// refund is accounted for the operation.
+		if contract.ReadOnly && writesState(op, stack) {
+			return nil, ErrWriteProtection
+		}
 		// calculate the new memory size and gas price for the current executing opcode
 		newMemSize, cost, err = calculateGasAndSize(&evm.gasTable, evm.env, contract, caller, op, statedb, mem, stack)
 		if err != nil {
 			return nil, err
 		}
 
+		if evm.cfg.Tracer != nil {
+			if err = evm.cfg.Tracer.CaptureState(evm.env, pc, op, contract.Gas, cost, mem, stack, contract, evm.env.Depth(), nil); err != nil {
+				return nil, err
+			}
+		}
+
 		// Use the calculated gas. When insufficient gas is present, use all gas and return an
 		// Out Of Gas error
 		if !contract.UseGas(cost) {
@@ -140,10 +177,9 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 				opPtr.fn(instruction{}, &pc, evm.env, contract, mem, stack)
 			} else {
 				switch op {
-				case PC:
-					opPc(instruction{data: new(big.Int).SetUint64(pc)}, &pc, evm.env, contract, mem, stack)
 				case JUMP:
-					if err := jump(pc, stack.pop()); err != nil {
+					pos := stack.pop()
+					if err := jump(pc, &pos); err != nil {
 						return nil, err
 					}
 
@@ -151,8 +187,8 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 				case JUMPI:
 					pos, cond := stack.pop(), stack.pop()
 
-					if cond.Sign() != 0 {
-						if err := jump(pc, pos); err != nil {
+					if !cond.IsZero() {
+						if err := jump(pc, &pos); err != nil {
 							return nil, err
 						}
 
@@ -160,10 +196,20 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 					}
 				case RETURN:
 					offset, size := stack.pop(), stack.pop()
-					ret := mem.GetPtr(offset.Int64(), size.Int64())
+					ret := mem.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
 
 					return ret, nil
+				case REVERT:
+					offset, size := stack.pop(), stack.pop()
+					ret := mem.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
+
+					return ret, ErrExecutionReverted
 				case SUICIDE:
+					if evm.cfg.Tracer != nil {
+						beneficiary := stack.peek().Address()
+						evm.cfg.Tracer.CaptureEnter(SUICIDE, contract.Address(), beneficiary, nil, new(big.Int), statedb.GetBalance(contract.Address()))
+						evm.cfg.Tracer.CaptureExit(nil, new(big.Int), nil)
+					}
 					opSuicide(instruction{}, nil, evm.env, contract, mem, stack)
 
 					fallthrough
@@ -180,9 +226,22 @@ func (evm *EVM) Run(contract *Contract, input []byte) (ret []byte, err error) {
 	}
 }
 
+// writesState reports whether executing op with the given stack would modify
+// the state, which is disallowed during a STATICCALL.
+func writesState(op OpCode, stack *Stack) bool {
+	switch op {
+	case SSTORE, LOG0, LOG1, LOG2, LOG3, LOG4, CREATE, SUICIDE:
+		return true
+	case CALL:
+		// only value transfers are disallowed, the stack is checked later
+		return stack.len() >= 3 && !stack.back(2).IsZero()
+	}
+	return false
+}
+
 // calculateGasAndSize calculates the required given the opcode and stack items calculates the new memorysize for
 // the operation. This does not reduce gas or resizes the memory.
-func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract, caller ContractRef, op OpCode, statedb Database, mem *Memory, stack *stack) (*big.Int, *big.Int, error) {
+func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract, caller ContractRef, op OpCode, statedb Database, mem *Memory, stack *Stack) (*big.Int, *big.Int, error) {
 	var (
 		gas                 = new(big.Int)
 		newMemSize *big.Int = new(big.Int)
@@ -198,7 +257,7 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 		// if suicide is not nil: homestead gas fork
 		if gasTable.CreateBySuicide != nil {
 			gas.Set(gasTable.Suicide)
-			if !env.Db().Exist(common.BigToAddress(stack.data[len(stack.data)-1])) {
+			if !env.Db().Exist(stack.back(0).Address()) {
 				gas.Add(gas, gasTable.CreateBySuicide)
 			}
 		}
@@ -233,20 +292,20 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 			return nil, nil, err
 		}
 
-		mSize, mStart := stack.data[stack.len()-2], stack.data[stack.len()-1]
+		mSize, mStart := stack.back(1), stack.back(0)
 
 		// log gas
 		gas.Add(gas, big.NewInt(375))
 		// log topic gass
 		gas.Add(gas, new(big.Int).Mul(big.NewInt(int64(n)), big.NewInt(375)))
 		// log data gass
-		gas.Add(gas, new(big.Int).Mul(mSize, big.NewInt(8)))
+		gas.Add(gas, new(big.Int).Mul(mSize.ToBig(), big.NewInt(8)))
 
 		newMemSize = calcMemSize(mStart, mSize)
 
 		quadMemGas(mem, newMemSize, gas)
 	case EXP:
-		expByteLen := int64(len(stack.data[stack.len()-2].Bytes()))
+		expByteLen := int64(stack.back(1).ByteLen())
 		gas.Add(gas, new(big.Int).Mul(big.NewInt(expByteLen), gasTable.ExpByte))
 	case SSTORE:
 		err := stack.require(2)
@@ -255,17 +314,17 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 		}
 
 		var g *big.Int
-		y, x := stack.data[stack.len()-2], stack.data[stack.len()-1]
-		val := statedb.GetState(contract.Address(), common.BigToHash(x))
+		y, x := stack.back(1), stack.back(0)
+		val := statedb.GetState(contract.Address(), common.Hash(x.Bytes32()))
 
 		// This checks for 3 scenario's and calculates gas accordingly
 		// 1. From a zero-value address to a non-zero value         (NEW VALUE)
 		// 2. From a non-zero value address to a zero-value address (DELETE)
 		// 3. From a non-zero to a non-zero                         (CHANGE)
-		if common.EmptyHash(val) && !common.EmptyHash(common.BigToHash(y)) {
+		if common.EmptyHash(val) && !y.IsZero() {
 			// 0 => non 0
 			g = big.NewInt(20000) // Once per SLOAD operation.
-		} else if !common.EmptyHash(val) && common.EmptyHash(common.BigToHash(y)) {
+		} else if !common.EmptyHash(val) && y.IsZero() {
 			statedb.AddRefund(big.NewInt(15000))
 			g = big.NewInt(5000)
 		} else {
@@ -275,96 +334,111 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 		gas.Set(g)
 
 	case MLOAD:
-		newMemSize = calcMemSize(stack.peek(), u256(32))
+		newMemSize = calcMemSize(stack.peek(), new(Word).SetUint64(32))
 		quadMemGas(mem, newMemSize, gas)
 	case MSTORE8:
-		newMemSize = calcMemSize(stack.peek(), u256(1))
+		newMemSize = calcMemSize(stack.peek(), new(Word).SetUint64(1))
 		quadMemGas(mem, newMemSize, gas)
 	case MSTORE:
-		newMemSize = calcMemSize(stack.peek(), u256(32))
+		newMemSize = calcMemSize(stack.peek(), new(Word).SetUint64(32))
 		quadMemGas(mem, newMemSize, gas)
-	case RETURN:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-2])
+	case RETURN, REVERT:
+		newMemSize = calcMemSize(stack.peek(), stack.back(1))
 		quadMemGas(mem, newMemSize, gas)
 	case SHA3:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-2])
+		newMemSize = calcMemSize(stack.peek(), stack.back(1))
 
-		words := toWordSize(stack.data[stack.len()-2])
+		words := toWordSize(stack.back(1).ToBig())
 		gas.Add(gas, words.Mul(words, big.NewInt(6)))
 
 		quadMemGas(mem, newMemSize, gas)
 	case CALLDATACOPY:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-3])
+		newMemSize = calcMemSize(stack.peek(), stack.back(2))
 
-		words := toWordSize(stack.data[stack.len()-3])
+		words := toWordSize(stack.back(2).ToBig())
+		gas.Add(gas, words.Mul(words, big.NewInt(3)))
+
+		quadMemGas(mem, newMemSize, gas)
+	case RETURNDATACOPY:
+		// reading past the end of the return data is an exceptional halt
+		dOff, l := stack.back(1), stack.back(2)
+		if !dOff.IsUint64() || !l.IsUint64() {
+			return nil, nil, ErrReturnDataOutOfBounds
+		}
+		if end := dOff.Uint64() + l.Uint64(); end < dOff.Uint64() || uint64(len(contract.returnData)) < end {
+			return nil, nil, ErrReturnDataOutOfBounds
+		}
+		newMemSize = calcMemSize(stack.peek(), stack.back(2))
+
+		words := toWordSize(stack.back(2).ToBig())
 		gas.Add(gas, words.Mul(words, big.NewInt(3)))
 
 		quadMemGas(mem, newMemSize, gas)
 	case CODECOPY:
-		newMemSize = calcMemSize(stack.peek(), stack.data[stack.len()-3])
+		newMemSize = calcMemSize(stack.peek(), stack.back(2))
 
-		words := toWordSize(stack.data[stack.len()-3])
+		words := toWordSize(stack.back(2).ToBig())
 		gas.Add(gas, words.Mul(words, big.NewInt(3)))
 
 		quadMemGas(mem, newMemSize, gas)
 	case EXTCODECOPY:
 		gas.Set(gasTable.ExtcodeCopy)
 
-		newMemSize = calcMemSize(stack.data[stack.len()-2], stack.data[stack.len()-4])
+		newMemSize = calcMemSize(stack.back(1), stack.back(3))
 
-		words := toWordSize(stack.data[stack.len()-4])
+		words := toWordSize(stack.back(3).ToBig())
 		gas.Add(gas, words.Mul(words, big.NewInt(3)))
 
 		quadMemGas(mem, newMemSize, gas)
 	case CREATE:
-		newMemSize = calcMemSize(stack.data[stack.len()-2], stack.data[stack.len()-3])
+		newMemSize = calcMemSize(stack.back(1), stack.back(2))
 
 		quadMemGas(mem, newMemSize, gas)
 	case CALL, CALLCODE:
 		gas.Set(gasTable.Calls)
 
 		if op == CALL {
-			if !env.Db().Exist(common.BigToAddress(stack.data[stack.len()-2])) {
+			if !env.Db().Exist(stack.back(1).Address()) {
 				gas.Add(gas, big.NewInt(25000))
 			}
 		}
-		if len(stack.data[stack.len()-3].Bytes()) > 0 {
+		if !stack.back(2).IsZero() {
 			gas.Add(gas, big.NewInt(9000))
 		}
-		x := calcMemSize(stack.data[stack.len()-6], stack.data[stack.len()-7])
-		y := calcMemSize(stack.data[stack.len()-4], stack.data[stack.len()-5])
+		x := calcMemSize(stack.back(5), stack.back(6))
+		y := calcMemSize(stack.back(3), stack.back(4))
 
 		newMemSize = common.BigMax(x, y)
 
 		quadMemGas(mem, newMemSize, gas)
 
-		cg := callGas(gasTable, contract.Gas, gas, stack.data[stack.len()-1])
+		cg := callGas(gasTable, contract.Gas, gas, stack.back(0).ToBig())
 		// Replace the stack item with the new gas calculation. This means that
 		// either the original item is left on the stack or the item is replaced by:
 		// (availableGas - gas) * 63 / 64
 		// We replace the stack item so that it's available when the opCall instruction is
 		// called. This information is otherwise lost due to the dependency on *current*
 		// available gas.
-		stack.data[stack.len()-1] = cg
+		stack.back(0).SetFromBig(cg)
 		gas.Add(gas, cg)
 
-	case DELEGATECALL:
+	case DELEGATECALL, STATICCALL:
 		gas.Set(gasTable.Calls)
 
-		x := calcMemSize(stack.data[stack.len()-5], stack.data[stack.len()-6])
-		y := calcMemSize(stack.data[stack.len()-3], stack.data[stack.len()-4])
+		x := calcMemSize(stack.back(4), stack.back(5))
+		y := calcMemSize(stack.back(2), stack.back(3))
 
 		newMemSize = common.BigMax(x, y)
 
 		quadMemGas(mem, newMemSize, gas)
 
-		cg := callGas(gasTable, contract.Gas, gas, stack.data[stack.len()-1])
+		cg := callGas(gasTable, contract.Gas, gas, stack.back(0).ToBig())
 		// Replace the stack item with the new gas calculation. This means that
 		// either the original item is left on the stack or the item is replaced by:
 		// (availableGas - gas) * 63 / 64
 		// We replace the stack item so that it's available when the opCall instruction is
 		// called.
-		stack.data[stack.len()-1] = cg
+		stack.back(0).SetFromBig(cg)
 		gas.Add(gas, cg)
 
 	}
@@ -374,11 +448,9 @@ func calculateGasAndSize(gasTable *GasTable, env Environment, contract *Contract
 
 // RunPrecompile runs and evaluate the output of a precompiled contract defined in contracts.go
 func (evm *EVM) RunPrecompiled(p *PrecompiledAccount, input []byte, contract *Contract) (ret []byte, err error) {
-	gas := p.Gas(len(input))
+	gas := p.Gas(input)
 	if contract.UseGas(gas) {
-		ret = p.Call(input)
-
-		return ret, nil
+		return p.Call(input)
 	} else {
 		return nil, OutOfGasError
 	}
@@ -0,0 +1,533 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+
+	"github.com/ethereumproject/go-ethereum/common"
+)
+
+// Word is a 256-bit EVM word stored as four 64-bit limbs, least significant
+// limb first. All arithmetic wraps modulo 2^256 and signed operations treat
+// the word as a two's complement number, as defined by the yellow paper.
+//
+// The methods follow the conventions of math/big: the receiver holds the
+// result, may alias any of the operands and is returned for chaining.
+type Word [4]uint64
+
+// Clear sets z to 0.
+func (z *Word) Clear() *Word {
+	*z = Word{}
+	return z
+}
+
+// Set sets z to x.
+func (z *Word) Set(x *Word) *Word {
+	*z = *x
+	return z
+}
+
+// SetUint64 sets z to x.
+func (z *Word) SetUint64(x uint64) *Word {
+	*z = Word{x}
+	return z
+}
+
+// SetBytes interprets b as a big-endian unsigned integer and sets z to its
+// value. Only the last 32 bytes are used if b is longer than that.
+func (z *Word) SetBytes(b []byte) *Word {
+	if len(b) > 32 {
+		b = b[len(b)-32:]
+	}
+	*z = Word{}
+	for i := 0; i < len(b); i++ {
+		z[i/8] |= uint64(b[len(b)-1-i]) << (8 * uint(i%8))
+	}
+	return z
+}
+
+// SetFromBig sets z to x modulo 2^256. Negative numbers are converted to
+// their two's complement representation.
+func (z *Word) SetFromBig(x *big.Int) *Word {
+	if x.Sign() >= 0 && x.BitLen() <= 64 {
+		return z.SetUint64(x.Uint64())
+	}
+	z.SetBytes(x.Bytes())
+	if x.Sign() < 0 {
+		z.Neg(z)
+	}
+	return z
+}
+
+// Uint64 returns the lowest 64 bits of w.
+func (w *Word) Uint64() uint64 {
+	return w[0]
+}
+
+// IsUint64 reports whether w can be represented as a uint64.
+func (w *Word) IsUint64() bool {
+	return w[1]|w[2]|w[3] == 0
+}
+
+// IsZero reports whether w is 0.
+func (w *Word) IsZero() bool {
+	return w[0]|w[1]|w[2]|w[3] == 0
+}
+
+// isNeg reports whether the sign bit of w is set.
+func (w *Word) isNeg() bool {
+	return w[3]>>63 == 1
+}
+
+// BitLen returns the length of the absolute value of w in bits.
+func (w *Word) BitLen() int {
+	for i := 3; i >= 0; i-- {
+		if w[i] != 0 {
+			return 64*i + bits.Len64(w[i])
+		}
+	}
+	return 0
+}
+
+// ByteLen returns the number of bytes required to represent w.
+func (w *Word) ByteLen() int {
+	return (w.BitLen() + 7) / 8
+}
+
+// Bytes32 returns the big-endian 32 byte representation of w.
+func (w *Word) Bytes32() (b [32]byte) {
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(b[24-8*i:32-8*i], w[i])
+	}
+	return b
+}
+
+// Address returns the lowest 20 bytes of w as an address.
+func (w *Word) Address() common.Address {
+	b := w.Bytes32()
+	return common.BytesToAddress(b[12:])
+}
+
+// ToBig returns w as a newly allocated big.Int.
+func (w *Word) ToBig() *big.Int {
+	b := w.Bytes32()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// String returns the decimal representation of w.
+func (w *Word) String() string {
+	return w.ToBig().String()
+}
+
+// Cmp compares w and y and returns -1, 0 or +1 if w is respectively less
+// than, equal to or greater than y.
+func (w *Word) Cmp(y *Word) int {
+	for i := 3; i >= 0; i-- {
+		switch {
+		case w[i] < y[i]:
+			return -1
+		case w[i] > y[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Slt reports whether w < y, interpreting both as signed numbers.
+func (w *Word) Slt(y *Word) bool {
+	if wNeg, yNeg := w.isNeg(), y.isNeg(); wNeg != yNeg {
+		return wNeg
+	}
+	return w.Cmp(y) < 0
+}
+
+// Sgt reports whether w > y, interpreting both as signed numbers.
+func (w *Word) Sgt(y *Word) bool {
+	return y.Slt(w)
+}
+
+// Add sets z to x + y.
+func (z *Word) Add(x, y *Word) *Word {
+	var c uint64
+	z[0], c = bits.Add64(x[0], y[0], 0)
+	z[1], c = bits.Add64(x[1], y[1], c)
+	z[2], c = bits.Add64(x[2], y[2], c)
+	z[3], _ = bits.Add64(x[3], y[3], c)
+	return z
+}
+
+// Sub sets z to x - y.
+func (z *Word) Sub(x, y *Word) *Word {
+	var b uint64
+	z[0], b = bits.Sub64(x[0], y[0], 0)
+	z[1], b = bits.Sub64(x[1], y[1], b)
+	z[2], b = bits.Sub64(x[2], y[2], b)
+	z[3], _ = bits.Sub64(x[3], y[3], b)
+	return z
+}
+
+// Neg sets z to -x.
+func (z *Word) Neg(x *Word) *Word {
+	return z.Sub(&Word{}, x)
+}
+
+// abs sets z to the absolute value of the signed number x.
+func (z *Word) abs(x *Word) *Word {
+	if x.isNeg() {
+		return z.Neg(x)
+	}
+	*z = *x
+	return z
+}
+
+// Mul sets z to x * y.
+func (z *Word) Mul(x, y *Word) *Word {
+	var res Word
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4-i; j++ {
+			hi, lo := bits.Mul64(x[i], y[j])
+			lo, c := bits.Add64(lo, res[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			res[i+j], carry = lo, hi
+		}
+	}
+	*z = res
+	return z
+}
+
+// Div sets z to x / y, or 0 if y is 0.
+func (z *Word) Div(x, y *Word) *Word {
+	if y.IsZero() || y.Cmp(x) > 0 {
+		return z.Clear()
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x.Uint64() / y.Uint64())
+	}
+	var quot Word
+	udivrem(quot[:], x[:], y)
+	*z = quot
+	return z
+}
+
+// Mod sets z to x % y, or 0 if y is 0.
+func (z *Word) Mod(x, y *Word) *Word {
+	if y.IsZero() {
+		return z.Clear()
+	}
+	if x.Cmp(y) < 0 {
+		*z = *x
+		return z
+	}
+	if x.IsUint64() {
+		return z.SetUint64(x.Uint64() % y.Uint64())
+	}
+	var quot Word
+	*z = udivrem(quot[:], x[:], y)
+	return z
+}
+
+// SDiv sets z to the signed division x / y, truncated towards zero, or 0
+// if y is 0.
+func (z *Word) SDiv(x, y *Word) *Word {
+	if y.IsZero() {
+		return z.Clear()
+	}
+	neg := x.isNeg() != y.isNeg()
+
+	var a, b Word
+	z.Div(a.abs(x), b.abs(y))
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// SMod sets z to the signed modulus x % y, which takes the sign of x, or 0
+// if y is 0.
+func (z *Word) SMod(x, y *Word) *Word {
+	if y.IsZero() {
+		return z.Clear()
+	}
+	neg := x.isNeg()
+
+	var a, b Word
+	z.Mod(a.abs(x), b.abs(y))
+	if neg {
+		z.Neg(z)
+	}
+	return z
+}
+
+// AddMod sets z to (x + y) % m without truncating the intermediate sum, or
+// 0 if m is 0.
+func (z *Word) AddMod(x, y, m *Word) *Word {
+	if m.IsZero() {
+		return z.Clear()
+	}
+	var (
+		sum  [5]uint64
+		quot [5]uint64
+		c    uint64
+	)
+	sum[0], c = bits.Add64(x[0], y[0], 0)
+	sum[1], c = bits.Add64(x[1], y[1], c)
+	sum[2], c = bits.Add64(x[2], y[2], c)
+	sum[3], sum[4] = bits.Add64(x[3], y[3], c)
+
+	*z = udivrem(quot[:], sum[:], m)
+	return z
+}
+
+// MulMod sets z to (x * y) % m without truncating the intermediate
+// product, or 0 if m is 0.
+func (z *Word) MulMod(x, y, m *Word) *Word {
+	if m.IsZero() {
+		return z.Clear()
+	}
+	var quot [8]uint64
+	p := umul(x, y)
+
+	*z = udivrem(quot[:], p[:], m)
+	return z
+}
+
+// Exp sets z to base**exponent.
+func (z *Word) Exp(base, exponent *Word) *Word {
+	var (
+		res = Word{1}
+		b   = *base
+		n   = exponent.BitLen()
+	)
+	for i := 0; i < n; i++ {
+		if exponent[i/64]>>uint(i%64)&1 == 1 {
+			res.Mul(&res, &b)
+		}
+		b.Mul(&b, &b)
+	}
+	*z = res
+	return z
+}
+
+// SignExtend sets z to x sign extended from the byte at position back,
+// counting from the least significant byte. x is left unchanged if back is
+// 31 or larger.
+func (z *Word) SignExtend(back, x *Word) *Word {
+	*z = *x
+	if !back.IsUint64() || back.Uint64() >= 31 {
+		return z
+	}
+	var (
+		bit  = uint(back.Uint64()*8 + 7)
+		limb = bit / 64
+		off  = bit % 64
+	)
+	if z[limb]>>off&1 == 1 {
+		z[limb] |= ^uint64(0) << off
+		for i := limb + 1; i < 4; i++ {
+			z[i] = ^uint64(0)
+		}
+	} else {
+		z[limb] &= uint64(1)<<(off+1) - 1
+		for i := limb + 1; i < 4; i++ {
+			z[i] = 0
+		}
+	}
+	return z
+}
+
+// Not sets z to ^x.
+func (z *Word) Not(x *Word) *Word {
+	z[0], z[1], z[2], z[3] = ^x[0], ^x[1], ^x[2], ^x[3]
+	return z
+}
+
+// And sets z to x & y.
+func (z *Word) And(x, y *Word) *Word {
+	z[0], z[1], z[2], z[3] = x[0]&y[0], x[1]&y[1], x[2]&y[2], x[3]&y[3]
+	return z
+}
+
+// Or sets z to x | y.
+func (z *Word) Or(x, y *Word) *Word {
+	z[0], z[1], z[2], z[3] = x[0]|y[0], x[1]|y[1], x[2]|y[2], x[3]|y[3]
+	return z
+}
+
+// Xor sets z to x ^ y.
+func (z *Word) Xor(x, y *Word) *Word {
+	z[0], z[1], z[2], z[3] = x[0]^y[0], x[1]^y[1], x[2]^y[2], x[3]^y[3]
+	return z
+}
+
+// Byte sets z to the n'th byte of z, counting from the most significant
+// byte, or 0 if n is 32 or larger.
+func (z *Word) Byte(n *Word) *Word {
+	if !n.IsUint64() || n.Uint64() >= 32 {
+		return z.Clear()
+	}
+	i := n.Uint64()
+	return z.SetUint64(z[3-i/8] >> (56 - 8*(i%8)) & 0xff)
+}
+
+// umul returns the full 512-bit product of x and y.
+func umul(x, y *Word) (res [8]uint64) {
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(x[i], y[j])
+			lo, c := bits.Add64(lo, res[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			res[i+j], carry = lo, hi
+		}
+		res[i+4] = carry
+	}
+	return res
+}
+
+// udivrem divides u by the non-zero d, storing the quotient in quot and
+// returning the remainder. quot must be zeroed and hold at least len(u)
+// limbs. The division is Knuth's algorithm D (TAOCP vol. 2, 4.3.1).
+func udivrem(quot, u []uint64, d *Word) (rem Word) {
+	dLen := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i] != 0 {
+			dLen = i + 1
+			break
+		}
+	}
+	uLen := 0
+	for i := len(u) - 1; i >= 0; i-- {
+		if u[i] != 0 {
+			uLen = i + 1
+			break
+		}
+	}
+	if uLen < dLen {
+		copy(rem[:], u)
+		return rem
+	}
+
+	// normalise the divisor so its most significant bit is set, and shift
+	// the dividend by the same amount.
+	shift := uint(bits.LeadingZeros64(d[dLen-1]))
+
+	var dnStorage Word
+	dn := dnStorage[:dLen]
+	for i := dLen - 1; i > 0; i-- {
+		dn[i] = d[i]<<shift | d[i-1]>>(64-shift)
+	}
+	dn[0] = d[0] << shift
+
+	var unStorage [9]uint64
+	un := unStorage[:uLen+1]
+	un[uLen] = u[uLen-1] >> (64 - shift)
+	for i := uLen - 1; i > 0; i-- {
+		un[i] = u[i]<<shift | u[i-1]>>(64-shift)
+	}
+	un[0] = u[0] << shift
+
+	if dLen == 1 {
+		r := un[uLen]
+		for j := uLen - 1; j >= 0; j-- {
+			quot[j], r = bits.Div64(r, un[j], dn[0])
+		}
+		return *rem.SetUint64(r >> shift)
+	}
+	udivremKnuth(quot, un, dn)
+
+	for i := 0; i < dLen-1; i++ {
+		rem[i] = un[i]>>shift | un[i+1]<<(64-shift)
+	}
+	rem[dLen-1] = un[dLen-1] >> shift
+	return rem
+}
+
+// udivremKnuth divides the normalised u by the normalised d of at least two
+// limbs, leaving the remainder in u.
+func udivremKnuth(quot, u, d []uint64) {
+	var (
+		dh = d[len(d)-1]
+		dl = d[len(d)-2]
+	)
+	for j := len(u) - len(d) - 1; j >= 0; j-- {
+		u2, u1, u0 := u[j+len(d)], u[j+len(d)-1], u[j+len(d)-2]
+
+		// estimate the quotient digit from the top limbs, it is at most
+		// one too large after the correction below.
+		var (
+			qhat, rhat uint64
+			overflow   bool
+		)
+		if u2 >= dh {
+			qhat = ^uint64(0)
+			var c uint64
+			rhat, c = bits.Add64(u1, dh, 0)
+			overflow = c != 0
+		} else {
+			qhat, rhat = bits.Div64(u2, u1, dh)
+		}
+		for !overflow {
+			ph, pl := bits.Mul64(qhat, dl)
+			if ph < rhat || (ph == rhat && pl <= u0) {
+				break
+			}
+			qhat--
+			var c uint64
+			rhat, c = bits.Add64(rhat, dh, 0)
+			overflow = c != 0
+		}
+
+		// multiply and subtract, adding back once if qhat was too large
+		borrow := subMulTo(u[j:], d, qhat)
+		u[j+len(d)] = u2 - borrow
+		if u2 < borrow {
+			qhat--
+			u[j+len(d)] += addTo(u[j:], d)
+		}
+		quot[j] = qhat
+	}
+}
+
+// subMulTo computes x -= y * m and returns the borrow.
+func subMulTo(x, y []uint64, m uint64) uint64 {
+	var borrow uint64
+	for i := 0; i < len(y); i++ {
+		s, c1 := bits.Sub64(x[i], borrow, 0)
+		ph, pl := bits.Mul64(y[i], m)
+		t, c2 := bits.Sub64(s, pl, 0)
+		x[i] = t
+		borrow = ph + c1 + c2
+	}
+	return borrow
+}
+
+// addTo computes x += y and returns the carry.
+func addTo(x, y []uint64) uint64 {
+	var carry uint64
+	for i := 0; i < len(y); i++ {
+		x[i], carry = bits.Add64(x[i], y[i], carry)
+	}
+	return carry
+}
@@ -0,0 +1,175 @@
+package vm
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+)
+
+// wordTestValues returns a set of edge case and random 256-bit values.
+func wordTestValues() []*big.Int {
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(2),
+		big.NewInt(31),
+		big.NewInt(32),
+		big.NewInt(255),
+		new(big.Int).SetUint64(^uint64(0)),
+		common.BigPow(2, 64),
+		common.BigPow(2, 128),
+		new(big.Int).Sub(common.BigPow(2, 128), common.Big1),
+		common.BigPow(2, 255),
+		new(big.Int).Sub(common.BigPow(2, 255), common.Big1),
+		new(big.Int).Sub(Pow256, common.Big1),
+		new(big.Int).Sub(Pow256, common.Big2),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 40; i++ {
+		// random values of varying length to exercise all division paths
+		b := make([]byte, 1+rnd.Intn(32))
+		rnd.Read(b)
+		values = append(values, new(big.Int).SetBytes(b))
+	}
+	return values
+}
+
+func TestWordConversion(t *testing.T) {
+	for _, x := range wordTestValues() {
+		w := new(Word).SetFromBig(x)
+		if w.ToBig().Cmp(x) != 0 {
+			t.Errorf("SetFromBig(%x).ToBig() = %x", x, w.ToBig())
+		}
+		if w.BitLen() != x.BitLen() {
+			t.Errorf("BitLen(%x) = %d, want %d", x, w.BitLen(), x.BitLen())
+		}
+		b := w.Bytes32()
+		if new(Word).SetBytes(b[:]).Cmp(w) != 0 {
+			t.Errorf("SetBytes(Bytes32(%x)) mismatch", x)
+		}
+		if neg := new(Word).SetFromBig(new(big.Int).Neg(x)); neg.ToBig().Cmp(U256(new(big.Int).Neg(x))) != 0 {
+			t.Errorf("SetFromBig(-%x) = %x", x, neg.ToBig())
+		}
+	}
+}
+
+func TestWordBinaryOps(t *testing.T) {
+	ops := []struct {
+		name string
+		word func(z, x, y *Word) *Word
+		big  func(x, y *big.Int) *big.Int
+	}{
+		{"Add", (*Word).Add, func(x, y *big.Int) *big.Int { return U256(new(big.Int).Add(x, y)) }},
+		{"Sub", (*Word).Sub, func(x, y *big.Int) *big.Int { return U256(new(big.Int).Sub(x, y)) }},
+		{"Mul", (*Word).Mul, func(x, y *big.Int) *big.Int { return U256(new(big.Int).Mul(x, y)) }},
+		{"Div", (*Word).Div, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Div(x, y)
+		}},
+		{"Mod", (*Word).Mod, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Mod(x, y)
+		}},
+		{"SDiv", (*Word).SDiv, func(x, y *big.Int) *big.Int {
+			x, y = S256(new(big.Int).Set(x)), S256(new(big.Int).Set(y))
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return U256(new(big.Int).Quo(x, y))
+		}},
+		{"SMod", (*Word).SMod, func(x, y *big.Int) *big.Int {
+			x, y = S256(new(big.Int).Set(x)), S256(new(big.Int).Set(y))
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return U256(new(big.Int).Rem(x, y))
+		}},
+		{"Exp", (*Word).Exp, func(x, y *big.Int) *big.Int { return new(big.Int).Exp(x, y, Pow256) }},
+		{"SignExtend", (*Word).SignExtend, func(back, num *big.Int) *big.Int {
+			if back.Cmp(big.NewInt(31)) >= 0 {
+				return num
+			}
+			bit := uint(back.Uint64()*8 + 7)
+			mask := new(big.Int).Lsh(common.Big1, bit)
+			mask.Sub(mask, common.Big1)
+			if num.Bit(int(bit)) == 1 {
+				return U256(new(big.Int).Or(num, new(big.Int).Not(mask)))
+			}
+			return new(big.Int).And(num, mask)
+		}},
+	}
+	values := wordTestValues()
+	for _, op := range ops {
+		for _, x := range values {
+			for _, y := range values {
+				want := op.big(x, y)
+				have := op.word(new(Word), new(Word).SetFromBig(x), new(Word).SetFromBig(y))
+				if have.ToBig().Cmp(want) != 0 {
+					t.Errorf("%s(%x, %x) = %x, want %x", op.name, x, y, have.ToBig(), want)
+				}
+			}
+		}
+	}
+}
+
+func TestWordModularOps(t *testing.T) {
+	values := wordTestValues()
+	for _, x := range values {
+		for _, y := range values {
+			for _, m := range values {
+				var wantAdd, wantMul *big.Int
+				if m.Sign() == 0 {
+					wantAdd, wantMul = new(big.Int), new(big.Int)
+				} else {
+					wantAdd = new(big.Int).Mod(new(big.Int).Add(x, y), m)
+					wantMul = new(big.Int).Mod(new(big.Int).Mul(x, y), m)
+				}
+				wx, wy, wm := new(Word).SetFromBig(x), new(Word).SetFromBig(y), new(Word).SetFromBig(m)
+				if have := new(Word).AddMod(wx, wy, wm); have.ToBig().Cmp(wantAdd) != 0 {
+					t.Errorf("AddMod(%x, %x, %x) = %x, want %x", x, y, m, have.ToBig(), wantAdd)
+				}
+				if have := new(Word).MulMod(wx, wy, wm); have.ToBig().Cmp(wantMul) != 0 {
+					t.Errorf("MulMod(%x, %x, %x) = %x, want %x", x, y, m, have.ToBig(), wantMul)
+				}
+			}
+		}
+	}
+}
+
+func TestWordCompare(t *testing.T) {
+	values := wordTestValues()
+	for _, x := range values {
+		for _, y := range values {
+			wx, wy := new(Word).SetFromBig(x), new(Word).SetFromBig(y)
+			if have, want := wx.Cmp(wy), x.Cmp(y); have != want {
+				t.Errorf("Cmp(%x, %x) = %d, want %d", x, y, have, want)
+			}
+			sx, sy := S256(new(big.Int).Set(x)), S256(new(big.Int).Set(y))
+			if have, want := wx.Slt(wy), sx.Cmp(sy) < 0; have != want {
+				t.Errorf("Slt(%x, %x) = %v, want %v", x, y, have, want)
+			}
+			if have, want := wx.Sgt(wy), sx.Cmp(sy) > 0; have != want {
+				t.Errorf("Sgt(%x, %x) = %v, want %v", x, y, have, want)
+			}
+		}
+	}
+}
+
+func TestWordByte(t *testing.T) {
+	x := new(Word).SetBytes(common.Hex2Bytes("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"))
+	for i := uint64(0); i < 34; i++ {
+		want := i
+		if i >= 32 {
+			want = 0
+		}
+		if have := new(Word).Set(x).Byte(new(Word).SetUint64(i)); have.Uint64() != want || !have.IsUint64() {
+			t.Errorf("Byte(%d) = %v, want %d", i, have, want)
+		}
+	}
+}
@@ -52,17 +52,28 @@ type VMEnv struct {
 	getHashFn func(uint64) common.Hash // getHashFn callback is used to retrieve block hashes
 }
 
-func NewEnv(state *state.StateDB, chainConfig *ChainConfig, chain *BlockChain, msg Message, header *types.Header) *VMEnv {
+// NewEnv returns a new environment for executing msg on top of the given
+// state and header. The vm configuration may carry a tracer used to record
+// the execution.
+func NewEnv(state *state.StateDB, chainConfig *ChainConfig, chain *BlockChain, msg Message, header *types.Header, cfg vm.Config) *VMEnv {
+	env := NewEnvWithHashFn(state, chainConfig, GetHashFn(header.ParentHash, chain), msg, header, cfg)
+	env.chain = chain
+	return env
+}
+
+// NewEnvWithHashFn returns a new environment like NewEnv, but resolving the
+// BLOCKHASH opcode through getHash instead of a full block chain. Light
+// clients, which only keep the headers, use it to execute calls.
+func NewEnvWithHashFn(state *state.StateDB, chainConfig *ChainConfig, getHash func(uint64) common.Hash, msg Message, header *types.Header, cfg vm.Config) *VMEnv {
 	env := &VMEnv{
 		chainConfig: chainConfig,
-		chain:       chain,
 		state:       state,
 		header:      header,
 		msg:         msg,
-		getHashFn:   GetHashFn(header.ParentHash, chain),
+		getHashFn:   getHash,
 	}
 
-	env.evm = vm.New(env)
+	env.evm = vm.New(env, cfg)
 	return env
 }
 
@@ -112,6 +123,10 @@ func (self *VMEnv) DelegateCall(me vm.ContractRef, addr common.Address, data []b
 	return DelegateCall(self, me, addr, data, gas, price)
 }
 
+func (self *VMEnv) StaticCall(me vm.ContractRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error) {
+	return StaticCall(self, me, addr, data, gas, price)
+}
+
 func (self *VMEnv) Create(me vm.ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error) {
 	return Create(self, me, data, gas, price, value)
 }
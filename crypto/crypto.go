@@ -114,6 +114,35 @@ func FromECDSAPub(pub *ecdsa.PublicKey) []byte {
 	return elliptic.Marshal(secp256k1.S256(), pub.X, pub.Y)
 }
 
+// CompressPubkey encodes a public key to the 33-byte compressed format.
+func CompressPubkey(pub *ecdsa.PublicKey) []byte {
+	return append([]byte{byte(0x02 + pub.Y.Bit(0))}, common.LeftPadBytes(pub.X.Bytes(), 32)...)
+}
+
+// DecompressPubkey parses a public key in the 33-byte compressed format.
+func DecompressPubkey(pub []byte) (*ecdsa.PublicKey, error) {
+	if len(pub) != 33 || (pub[0] != 0x02 && pub[0] != 0x03) {
+		return nil, errors.New("invalid compressed public key")
+	}
+	curve := secp256k1.S256()
+	x := new(big.Int).SetBytes(pub[1:])
+	if x.Cmp(curve.P) >= 0 {
+		return nil, errors.New("invalid compressed public key")
+	}
+	// Solve y² = x³ + b, the field prime being 3 mod 4 the root is (x³+b)^((p+1)/4)
+	y := new(big.Int).Exp(x, big.NewInt(3), curve.P)
+	y.Add(y, curve.B)
+	y.Mod(y, curve.P)
+	y.Exp(y, new(big.Int).Rsh(new(big.Int).Add(curve.P, big.NewInt(1)), 2), curve.P)
+	if y.Bit(0) != uint(pub[0]&1) {
+		y.Sub(curve.P, y)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("invalid compressed public key")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
 // HexToECDSA parses a secp256k1 private key.
 func HexToECDSA(hexkey string) (*ecdsa.PrivateKey, error) {
 	b, err := hex.DecodeString(hexkey)
@@ -131,6 +131,27 @@ func TestSign(t *testing.T) {
 
 }
 
+func TestCompressPubkey(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		key, _ := GenerateKey()
+
+		compressed := CompressPubkey(&key.PublicKey)
+		if len(compressed) != 33 {
+			t.Fatalf("compressed key length mismatch: have %d, want 33", len(compressed))
+		}
+		pub, err := DecompressPubkey(compressed)
+		if err != nil {
+			t.Fatalf("failed to decompress key: %v", err)
+		}
+		if pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+			t.Fatalf("decompressed key mismatch: have %x, want %x", FromECDSAPub(pub), FromECDSAPub(&key.PublicKey))
+		}
+	}
+	if _, err := DecompressPubkey(make([]byte, 33)); err == nil {
+		t.Error("expected error for invalid compressed key")
+	}
+}
+
 func TestInvalidSign(t *testing.T) {
 	_, err := Sign(make([]byte, 1), nil)
 	if err == nil {
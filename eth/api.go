@@ -41,6 +41,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/core/types"
 	"github.com/ethereumproject/go-ethereum/core/vm"
 	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/eth/filters"
 	"github.com/ethereumproject/go-ethereum/ethdb"
 	"github.com/ethereumproject/go-ethereum/event"
 	"github.com/ethereumproject/go-ethereum/logger"
@@ -86,7 +87,7 @@ func stateAndBlockByNumber(m *miner.Miner, bc *core.BlockChain, blockNr rpc.Bloc
 	if block == nil {
 		return nil, nil, nil
 	}
-	stateDb, err := state.New(block.Root(), state.NewDatabase(chainDb))
+	stateDb, err := bc.StateAt(block.Root())
 	return stateDb, block, err
 }
 
@@ -553,50 +554,45 @@ func (s *PrivateAccountAPI) SignAndSendTransaction(args SendTxArgs, passwd strin
 // PublicBlockChainAPI provides an API to access the Ethereum blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
-	config                  *core.ChainConfig
-	bc                      *core.BlockChain
-	chainDb                 ethdb.Database
-	indexesDb               ethdb.Database
-	eventMux                *event.TypeMux
-	muNewBlockSubscriptions sync.Mutex                             // protects newBlocksSubscriptions
-	newBlockSubscriptions   map[string]func(core.ChainEvent) error // callbacks for new block subscriptions
-	am                      *accounts.Manager
-	miner                   *miner.Miner
-	gpo                     *GasPriceOracle
+	config    *core.ChainConfig
+	bc        *core.BlockChain
+	chainDb   ethdb.Database
+	indexesDb ethdb.Database
+	eventMux  *event.TypeMux
+	am        *accounts.Manager
+	miner     *miner.Miner
+	gpo       *GasPriceOracle
+	subs      *filters.PublicSubscriptionAPI
 }
 
 // NewPublicBlockChainAPI creates a new Etheruem blockchain API.
-func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, m *miner.Miner, chainDb ethdb.Database, gpo *GasPriceOracle, eventMux *event.TypeMux, am *accounts.Manager) *PublicBlockChainAPI {
-	api := &PublicBlockChainAPI{
+func NewPublicBlockChainAPI(config *core.ChainConfig, bc *core.BlockChain, m *miner.Miner, chainDb ethdb.Database, gpo *GasPriceOracle, eventMux *event.TypeMux, am *accounts.Manager, subs *filters.PublicSubscriptionAPI) *PublicBlockChainAPI {
+	return &PublicBlockChainAPI{
 		config:   config,
 		bc:       bc,
 		miner:    m,
 		chainDb:  chainDb,
 		eventMux: eventMux,
 		am:       am,
-		newBlockSubscriptions: make(map[string]func(core.ChainEvent) error),
-		gpo: gpo,
+		gpo:      gpo,
+		subs:     subs,
 	}
+}
 
-	go api.subscriptionLoop()
-
-	return api
+// NewBlocksArgs allows the user to specify if the returned block should include transactions and in which format.
+type NewBlocksArgs struct {
+	IncludeTransactions bool `json:"includeTransactions"`
+	TransactionDetails  bool `json:"transactionDetails"`
 }
 
-// subscriptionLoop reads events from the global event mux and creates notifications for the matched subscriptions.
-func (s *PublicBlockChainAPI) subscriptionLoop() {
-	sub := s.eventMux.Subscribe(core.ChainEvent{})
-	for event := range sub.Chan() {
-		if chainEvent, ok := event.Data.(core.ChainEvent); ok {
-			s.muNewBlockSubscriptions.Lock()
-			for id, notifyOf := range s.newBlockSubscriptions {
-				if notifyOf(chainEvent) == rpc.ErrNotificationNotFound {
-					delete(s.newBlockSubscriptions, id)
-				}
-			}
-			s.muNewBlockSubscriptions.Unlock()
-		}
-	}
+// NewBlocks triggers a new block event each time a block is appended to the chain. It accepts an argument which allows
+// the caller to specify whether the output should contain transactions and in what format.
+//
+// Deprecated: subscribe to newHeads instead, and fetch the blocks whose transactions are needed.
+func (s *PublicBlockChainAPI) NewBlocks(ctx context.Context, args NewBlocksArgs) (rpc.Subscription, error) {
+	return filters.SubscribeBlocks(ctx, s.subs, func(b *types.Block) (interface{}, error) {
+		return s.rpcOutputBlock(b, args.IncludeTransactions, args.TransactionDetails)
+	})
 }
 
 // BlockNumber returns the block number of the chain head.
@@ -686,45 +682,6 @@ func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(blockHash common.Hash) *r
 	return nil
 }
 
-// NewBlocksArgs allows the user to specify if the returned block should include transactions and in which format.
-type NewBlocksArgs struct {
-	IncludeTransactions bool `json:"includeTransactions"`
-	TransactionDetails  bool `json:"transactionDetails"`
-}
-
-// NewBlocks triggers a new block event each time a block is appended to the chain. It accepts an argument which allows
-// the caller to specify whether the output should contain transactions and in what format.
-func (s *PublicBlockChainAPI) NewBlocks(ctx context.Context, args NewBlocksArgs) (rpc.Subscription, error) {
-	notifier, supported := rpc.NotifierFromContext(ctx)
-	if !supported {
-		return nil, rpc.ErrNotificationsUnsupported
-	}
-
-	// create a subscription that will remove itself when unsubscribed/cancelled
-	subscription, err := notifier.NewSubscription(func(subId string) {
-		s.muNewBlockSubscriptions.Lock()
-		delete(s.newBlockSubscriptions, subId)
-		s.muNewBlockSubscriptions.Unlock()
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	// add a callback that is called on chain events which will format the block and notify the client
-	s.muNewBlockSubscriptions.Lock()
-	s.newBlockSubscriptions[subscription.ID()] = func(e core.ChainEvent) error {
-		notification, err := s.rpcOutputBlock(e.Block, args.IncludeTransactions, args.TransactionDetails)
-		if err == nil {
-			return subscription.Notify(notification)
-		}
-		glog.V(logger.Warn).Info("unable to format block %v\n", err)
-		return nil
-	}
-	s.muNewBlockSubscriptions.Unlock()
-	return subscription, nil
-}
-
 // GetCode returns the code stored at the given address in the state for the given block number.
 func (s *PublicBlockChainAPI) GetCode(address common.Address, blockNr rpc.BlockNumber) (string, error) {
 	state, _, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
@@ -817,7 +774,7 @@ func (s *PublicBlockChainAPI) doCall(args CallArgs, blockNr rpc.BlockNumber) (st
 	}
 
 	// Execute the call and return
-	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
+	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header(), vm.Config{})
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 
 	res, requiredGas, _, err := core.NewStateTransition(vmenv, msg, gp).TransitionDb()
@@ -844,6 +801,12 @@ func (s *PublicBlockChainAPI) EstimateGas(args CallArgs) (*rpc.HexNumber, error)
 // returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
 // transaction hashes.
 func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
+	return RPCMarshalBlock(b, s.bc.GetTd(b.Hash()), s.bc.Config(), inclTx, fullTx)
+}
+
+// RPCMarshalBlock converts the given block with its total difficulty to the RPC output, like rpcOutputBlock. It is
+// shared with the light client, which has no core.BlockChain.
+func RPCMarshalBlock(b *types.Block, td *big.Int, config *core.ChainConfig, inclTx bool, fullTx bool) (map[string]interface{}, error) {
 	fields := map[string]interface{}{
 		"number":           rpc.NewHexNumber(b.Number()),
 		"hash":             b.Hash(),
@@ -855,7 +818,7 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		"stateRoot":        b.Root(),
 		"miner":            b.Coinbase(),
 		"difficulty":       rpc.NewHexNumber(b.Difficulty()),
-		"totalDifficulty":  rpc.NewHexNumber(s.bc.GetTd(b.Hash())),
+		"totalDifficulty":  rpc.NewHexNumber(td),
 		"extraData":        fmt.Sprintf("0x%x", b.Extra()),
 		"size":             rpc.NewHexNumber(b.Size().Int64()),
 		"gasLimit":         rpc.NewHexNumber(b.GasLimit()),
@@ -873,7 +836,7 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		if fullTx {
 			formatTx = func(tx *types.Transaction) (interface{}, error) {
 				if tx.Protected() {
-					tx.SetSigner(types.NewChainIdSigner(s.bc.Config().GetChainID()))
+					tx.SetSigner(types.NewChainIdSigner(config.GetChainID()))
 				}
 				return newRPCTransaction(b, tx.Hash())
 			}
@@ -915,6 +878,9 @@ type RPCTransaction struct {
 	Value            *rpc.HexNumber  `json:"value"`
 	ReplayProtected  bool            `json:"replayProtected"`
 	ChainId          *big.Int        `json:"chainId,omitempty"`
+	V                *rpc.HexNumber  `json:"v"`
+	R                *rpc.HexNumber  `json:"r"`
+	S                *rpc.HexNumber  `json:"s"`
 }
 
 // newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
@@ -928,6 +894,7 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 		chainId = tx.ChainId()
 	}
 
+	v, r, s := tx.RawSignatureValues()
 	return &RPCTransaction{
 		From:            from,
 		Gas:             rpc.NewHexNumber(tx.Gas()),
@@ -939,6 +906,9 @@ func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 		Value:           rpc.NewHexNumber(tx.Value()),
 		ReplayProtected: protected,
 		ChainId:         chainId,
+		V:               rpc.NewHexNumber(v),
+		R:               rpc.NewHexNumber(r),
+		S:               rpc.NewHexNumber(s),
 	}
 }
 
@@ -955,6 +925,7 @@ func newRPCTransactionFromBlockIndex(b *types.Block, txIndex int) (*RPCTransacti
 			chainId = tx.ChainId()
 		}
 		from, _ := types.Sender(signer, tx)
+		v, r, s := tx.RawSignatureValues()
 
 		return &RPCTransaction{
 			BlockHash:        b.Hash(),
@@ -970,6 +941,9 @@ func newRPCTransactionFromBlockIndex(b *types.Block, txIndex int) (*RPCTransacti
 			Value:            rpc.NewHexNumber(tx.Value()),
 			ReplayProtected:  protected,
 			ChainId:          chainId,
+			V:                rpc.NewHexNumber(v),
+			R:                rpc.NewHexNumber(r),
+			S:                rpc.NewHexNumber(s),
 		}, nil
 	}
 
@@ -989,55 +963,40 @@ func newRPCTransaction(b *types.Block, txHash common.Hash) (*RPCTransaction, err
 
 // PublicTransactionPoolAPI exposes methods for the RPC interface
 type PublicTransactionPoolAPI struct {
-	eventMux        *event.TypeMux
-	chainDb         ethdb.Database
-	gpo             *GasPriceOracle
-	bc              *core.BlockChain
-	miner           *miner.Miner
-	am              *accounts.Manager
-	txPool          *core.TxPool
-	txMu            *sync.Mutex
-	muPendingTxSubs sync.Mutex
-	pendingTxSubs   map[string]rpc.Subscription
+	eventMux *event.TypeMux
+	chainDb  ethdb.Database
+	gpo      *GasPriceOracle
+	bc       *core.BlockChain
+	miner    *miner.Miner
+	am       *accounts.Manager
+	txPool   *core.TxPool
+	txMu     *sync.Mutex
+	subs     *filters.PublicSubscriptionAPI
 }
 
 // NewPublicTransactionPoolAPI creates a new RPC service with methods specific for the transaction pool.
 func NewPublicTransactionPoolAPI(e *Ethereum) *PublicTransactionPoolAPI {
-	api := &PublicTransactionPoolAPI{
-		eventMux:      e.eventMux,
-		gpo:           e.gpo,
-		chainDb:       e.chainDb,
-		bc:            e.blockchain,
-		am:            e.accountManager,
-		txPool:        e.txPool,
-		txMu:          &e.txMu,
-		miner:         e.miner,
-		pendingTxSubs: make(map[string]rpc.Subscription),
-	}
-	go api.subscriptionLoop()
-
-	return api
-}
-
-// subscriptionLoop listens for events on the global event mux and creates notifications for subscriptions.
-func (s *PublicTransactionPoolAPI) subscriptionLoop() {
-	sub := s.eventMux.Subscribe(core.TxPreEvent{})
-	for event := range sub.Chan() {
-		tx := event.Data.(core.TxPreEvent)
-		if from, err := tx.Tx.From(); err == nil {
-			if s.am.HasAddress(from) {
-				s.muPendingTxSubs.Lock()
-				for id, sub := range s.pendingTxSubs {
-					if sub.Notify(tx.Tx.Hash()) == rpc.ErrNotificationNotFound {
-						delete(s.pendingTxSubs, id)
-					}
-				}
-				s.muPendingTxSubs.Unlock()
-			}
-		}
+	return &PublicTransactionPoolAPI{
+		eventMux: e.eventMux,
+		gpo:      e.gpo,
+		chainDb:  e.chainDb,
+		bc:       e.blockchain,
+		am:       e.accountManager,
+		txPool:   e.txPool,
+		txMu:     &e.txMu,
+		miner:    e.miner,
+		subs:     e.subscriptionAPI,
 	}
 }
 
+// NewPendingTransactions creates a subscription that is triggered each time a transaction enters the transaction pool.
+//
+// Deprecated: use the newPendingTransactions subscription of filters.PublicSubscriptionAPI, which this calls. It
+// notifies of all pending transactions, not only those sent from the accounts of this node.
+func (s *PublicTransactionPoolAPI) NewPendingTransactions(ctx context.Context) (rpc.Subscription, error) {
+	return s.subs.NewPendingTransactions(ctx)
+}
+
 func getTransaction(chainDb ethdb.Database, txPool *core.TxPool, txHash common.Hash) (*types.Transaction, bool, error) {
 	txData, err := chainDb.Get(txHash.Bytes())
 	isPending := false
@@ -1563,31 +1522,6 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() []*RPCTransaction {
 	return transactions
 }
 
-// NewPendingTransactions creates a subscription that is triggered each time a transaction enters the transaction pool
-// and is send from one of the transactions this nodes manages.
-func (s *PublicTransactionPoolAPI) NewPendingTransactions(ctx context.Context) (rpc.Subscription, error) {
-	notifier, supported := rpc.NotifierFromContext(ctx)
-	if !supported {
-		return nil, rpc.ErrNotificationsUnsupported
-	}
-
-	subscription, err := notifier.NewSubscription(func(id string) {
-		s.muPendingTxSubs.Lock()
-		delete(s.pendingTxSubs, id)
-		s.muPendingTxSubs.Unlock()
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	s.muPendingTxSubs.Lock()
-	s.pendingTxSubs[subscription.ID()] = subscription
-	s.muPendingTxSubs.Unlock()
-
-	return subscription, nil
-}
-
 // Resend accepts an existing transaction and a new gas price and limit. It will remove the given transaction from the
 // pool and reinsert it with the new gas price and limit.
 func (s *PublicTransactionPoolAPI) Resend(tx Tx, gasPrice, gasLimit *rpc.HexNumber) (common.Hash, error) {
@@ -1740,6 +1674,9 @@ func (api *PublicGethAPI) GetTransactionsByAddress(address common.Address, block
 
 // AddressTransactions gets transactions for a given address.
 // Optional values include start and stop block numbers, and to/from/both value for tx/address relation.
+// The kind of transactions is standard (s), contract creation (c), internal value transfer (i) or
+// ERC20 token transfer (t), or a combination of them, eg. 'it'; the latter two are only indexed with
+// --atxi.internal and --atxi.tokens.
 // Returns a slice of strings of transactions hashes.
 func (api *PublicGethAPI) GetAddressTransactions(address common.Address, blockStartN uint64, blockEndN rpc.BlockNumber, toOrFrom string, txKindOf string, pagStart, pagEnd int, reverse bool) (list []string, err error) {
 	glog.V(logger.Debug).Infoln("RPC call: debug_getAddressTransactions %s %d %d %s %s", address, blockStartN, blockEndN, toOrFrom, txKindOf)
@@ -1754,10 +1691,7 @@ func (api *PublicGethAPI) GetAddressTransactions(address common.Address, blockSt
 	if toOrFrom == "tf" || toOrFrom == "ft" {
 		toOrFrom = "b"
 	}
-	// _s_tandard OR _c_ontract
-	if txKindOf == "sc" || txKindOf == "cs" {
-		txKindOf = "b"
-	}
+	// _s_tandard, _c_ontract, _i_nternal and _t_oken combine, eg. 'sc' or 'it'
 
 	if blockEndN == rpc.LatestBlockNumber || blockEndN == rpc.PendingBlockNumber {
 		blockEndN = 0
@@ -1776,6 +1710,71 @@ func (api *PublicGethAPI) GetAddressTransactions(address common.Address, blockSt
 	return list, nil
 }
 
+// maxAddressTransactionsPage is the most transactions listed in a page of
+// geth_getAddressTransactionsPage.
+const maxAddressTransactionsPage = 1000
+
+// AddressTransactionsPage is a page of the transactions of an address.
+type AddressTransactionsPage struct {
+	Transactions []string `json:"transactions"`
+	// Next is the cursor of the following page, empty after the last one.
+	Next string `json:"next"`
+}
+
+// GetAddressTransactionsPage gets a page of at most limit transactions for a given address,
+// continuing after the cursor of the previous page, if any. The params are those of
+// GetAddressTransactions, but the transactions are listed oldest first unless reverse is set.
+// Unlike pagination offsets the cursor stays cheap deep into the history of busy addresses.
+func (api *PublicGethAPI) GetAddressTransactionsPage(address common.Address, blockStartN uint64, blockEndN rpc.BlockNumber, toOrFrom string, txKindOf string, cursor string, limit int, reverse bool) (*AddressTransactionsPage, error) {
+	glog.V(logger.Debug).Infof("RPC call: geth_getAddressTransactionsPage %s %d %d %s %s %s %d", address, blockStartN, blockEndN, toOrFrom, txKindOf, cursor, limit)
+
+	atxi := api.eth.BlockChain().GetAtxi()
+	if atxi == nil {
+		return nil, errors.New("addr-tx indexing not enabled")
+	}
+	if toOrFrom == "tf" || toOrFrom == "ft" {
+		toOrFrom = "b"
+	}
+	if blockEndN == rpc.LatestBlockNumber || blockEndN == rpc.PendingBlockNumber {
+		blockEndN = 0
+	}
+	if limit <= 0 || limit > maxAddressTransactionsPage {
+		limit = maxAddressTransactionsPage
+	}
+
+	list, next, err := core.GetAddrTxsPage(atxi.Db, address, blockStartN, uint64(blockEndN.Int64()), toOrFrom, txKindOf, cursor, limit, reverse)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []string{}
+	}
+	return &AddressTransactionsPage{Transactions: list, Next: next}, nil
+}
+
+// GetAddressTransactionCount counts the transactions for a given address, with the
+// optional values of GetAddressTransactions.
+func (api *PublicGethAPI) GetAddressTransactionCount(address common.Address, blockStartN uint64, blockEndN rpc.BlockNumber, toOrFrom string, txKindOf string) (*rpc.HexNumber, error) {
+	glog.V(logger.Debug).Infof("RPC call: geth_getAddressTransactionCount %s %d %d %s %s", address, blockStartN, blockEndN, toOrFrom, txKindOf)
+
+	atxi := api.eth.BlockChain().GetAtxi()
+	if atxi == nil {
+		return nil, errors.New("addr-tx indexing not enabled")
+	}
+	if toOrFrom == "tf" || toOrFrom == "ft" {
+		toOrFrom = "b"
+	}
+	if blockEndN == rpc.LatestBlockNumber || blockEndN == rpc.PendingBlockNumber {
+		blockEndN = 0
+	}
+
+	count, err := core.CountAddrTxs(atxi.Db, address, blockStartN, uint64(blockEndN.Int64()), toOrFrom, txKindOf)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewHexNumber(count), nil
+}
+
 func (api *PublicGethAPI) BuildATXI(start, stop, step rpc.BlockNumber) (bool, error) {
 	glog.V(logger.Debug).Infoln("RPC call: geth_buildATXI %v %v %v", start, stop, step)
 
@@ -2019,12 +2018,167 @@ func (api *PublicDebugAPI) Vmodule(s string) (string, error) {
 // while replaying a transaction in debug mode as well as the amount of
 // gas used and the return value
 type ExecutionResult struct {
-	Gas         *big.Int `json:"gas"`
-	ReturnValue string   `json:"returnValue"`
+	Gas         *big.Int       `json:"gas"`
+	Failed      bool           `json:"failed"`
+	ReturnValue string         `json:"returnValue"`
+	StructLogs  []structLogRes `json:"structLogs"`
+}
+
+// structLogRes stores a structured log emitted by the EVM while replaying a
+// transaction in debug mode
+type structLogRes struct {
+	Pc      uint64            `json:"pc"`
+	Op      string            `json:"op"`
+	Gas     *big.Int          `json:"gas"`
+	GasCost *big.Int          `json:"gasCost"`
+	Depth   int               `json:"depth"`
+	Error   string            `json:"error,omitempty"`
+	Stack   []string          `json:"stack,omitempty"`
+	Memory  []string          `json:"memory,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
+}
+
+// callTracerName selects the call frame tracer in TraceArgs.
+const callTracerName = "callTracer"
+
+// TraceArgs holds extra parameters to trace functions.
+type TraceArgs struct {
+	DisableMemory  bool   `json:"disableMemory"`
+	DisableStack   bool   `json:"disableStack"`
+	DisableStorage bool   `json:"disableStorage"`
+	Limit          int    `json:"limit"`
+	Tracer         string `json:"tracer"` // "" for structured logs, "callTracer" for call frames
+}
+
+// newTracer returns the tracer selected by the (optional) trace arguments.
+func (args *TraceArgs) newTracer() (vm.Tracer, error) {
+	if args == nil || args.Tracer == "" {
+		return vm.NewStructLogger(args.logConfig()), nil
+	}
+	if args.Tracer == callTracerName {
+		return vm.NewCallTracer(), nil
+	}
+	return nil, fmt.Errorf("unknown tracer %q", args.Tracer)
+}
+
+// logConfig converts the (optional) trace arguments to a vm.LogConfig.
+func (args *TraceArgs) logConfig() *vm.LogConfig {
+	if args == nil {
+		return nil
+	}
+	return &vm.LogConfig{
+		DisableMemory:  args.DisableMemory,
+		DisableStack:   args.DisableStack,
+		DisableStorage: args.DisableStorage,
+		Limit:          args.Limit,
+	}
+}
+
+// formatLogs formats EVM returned structured logs for json output
+func formatLogs(structLogs []vm.StructLog) []structLogRes {
+	formatted := make([]structLogRes, len(structLogs))
+	for index, trace := range structLogs {
+		formatted[index] = structLogRes{
+			Pc:      trace.Pc,
+			Op:      trace.Op.String(),
+			Gas:     trace.Gas,
+			GasCost: trace.GasCost,
+			Depth:   trace.Depth,
+		}
+		if trace.Err != nil {
+			formatted[index].Error = trace.Err.Error()
+		}
+		if trace.Stack != nil {
+			stack := make([]string, len(trace.Stack))
+			for i, stackValue := range trace.Stack {
+				stack[i] = fmt.Sprintf("%x", common.LeftPadBytes(stackValue.Bytes(), 32))
+			}
+			formatted[index].Stack = stack
+		}
+		if trace.Memory != nil {
+			memory := make([]string, 0, (len(trace.Memory)+31)/32)
+			for i := 0; i+32 <= len(trace.Memory); i += 32 {
+				memory = append(memory, fmt.Sprintf("%x", trace.Memory[i:i+32]))
+			}
+			formatted[index].Memory = memory
+		}
+		if trace.Storage != nil {
+			storage := make(map[string]string)
+			for i, storageValue := range trace.Storage {
+				storage[fmt.Sprintf("%x", i)] = fmt.Sprintf("%x", storageValue)
+			}
+			formatted[index].Storage = storage
+		}
+	}
+	return formatted
+}
+
+// callFrameRes stores a call frame captured by the EVM while replaying a
+// transaction in debug mode
+type callFrameRes struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      common.Address  `json:"to"`
+	Value   *rpc.HexNumber  `json:"value,omitempty"`
+	Gas     *rpc.HexNumber  `json:"gas"`
+	GasUsed *rpc.HexNumber  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []*callFrameRes `json:"calls,omitempty"`
+}
+
+// formatCallFrame formats an EVM call frame tree for json output
+func formatCallFrame(frame *vm.CallFrame) *callFrameRes {
+	if frame == nil {
+		return nil
+	}
+	formatted := &callFrameRes{
+		Type:    frame.Type.String(),
+		From:    frame.From,
+		To:      frame.To,
+		Gas:     rpc.NewHexNumber(frame.Gas),
+		GasUsed: rpc.NewHexNumber(frame.GasUsed),
+		Input:   frame.Input,
+		Output:  frame.Output,
+	}
+	if frame.Value != nil {
+		formatted.Value = rpc.NewHexNumber(frame.Value)
+	}
+	if frame.Err != nil {
+		formatted.Error = frame.Err.Error()
+	}
+	for _, call := range frame.Calls {
+		formatted.Calls = append(formatted.Calls, formatCallFrame(call))
+	}
+	return formatted
+}
+
+// traceResult formats the outcome of a traced execution according to the
+// kind of tracer used.
+func traceResult(tracer vm.Tracer, gas *big.Int, failed bool, ret []byte) interface{} {
+	switch tracer := tracer.(type) {
+	case *vm.CallTracer:
+		return formatCallFrame(tracer.Frame())
+	case *vm.StructLogger:
+		return &ExecutionResult{
+			Gas:         gas,
+			Failed:      failed,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  formatLogs(tracer.StructLogs()),
+		}
+	}
+	return nil
 }
 
-// TraceCall executes a call and returns the amount of gas and optionally returned values.
-func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber) (*ExecutionResult, error) {
+// TraceCall executes a call and returns the amount of gas, the returned value
+// and the structured logs created during the execution of the EVM. When the
+// callTracer is selected, the call frames of the execution are returned instead.
+func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber, config *TraceArgs) (interface{}, error) {
+	tracer, err := config.newTracer()
+	if err != nil {
+		return nil, err
+	}
 	// Fetch the state associated with the block number
 	stateDb, block, err := stateAndBlockByNumber(s.miner, s.bc, blockNr, s.chainDb)
 	if stateDb == nil || err != nil {
@@ -2055,47 +2209,119 @@ func (s *PublicBlockChainAPI) TraceCall(args CallArgs, blockNr rpc.BlockNumber)
 		value:    args.Value.BigInt(),
 		data:     common.FromHex(args.Data),
 	}
-	if msg.gas.Sign() == 0 {
+	if msg.gas == nil || msg.gas.Sign() == 0 {
 		msg.gas = big.NewInt(50000000)
 	}
-	if msg.gasPrice.Sign() == 0 {
+	if msg.gasPrice == nil || msg.gasPrice.Sign() == 0 {
 		msg.gasPrice = new(big.Int).Mul(big.NewInt(50), common.Shannon)
 	}
 
 	// Execute the call and return
-	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header())
+	vmenv := core.NewEnv(stateDb, s.config, s.bc, msg, block.Header(), vm.Config{Tracer: tracer})
 	gp := new(core.GasPool).AddGas(common.MaxBig)
 
-	ret, gas, _, err := core.ApplyMessage(vmenv, msg, gp)
-	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
-	}, nil
+	ret, gas, failed, err := core.ApplyMessage(vmenv, msg, gp)
+	if err != nil {
+		return nil, err
+	}
+	return traceResult(tracer, gas, failed, ret), nil
 }
 
-// TraceTransaction returns the amount of gas and execution result of the given transaction.
-func (s *PublicDebugAPI) TraceTransaction(txHash common.Hash) (*ExecutionResult, error) {
-	var result *ExecutionResult
+// TraceTransaction returns the amount of gas, the execution result and the
+// structured logs created while replaying the given transaction. When the
+// callTracer is selected, the call frames of the transaction are returned
+// instead.
+func (s *PublicDebugAPI) TraceTransaction(txHash common.Hash, config *TraceArgs) (interface{}, error) {
+	tracer, err := config.newTracer()
+	if err != nil {
+		return nil, err
+	}
 	tx, blockHash, _, txIndex := core.GetTransaction(s.eth.ChainDb(), txHash)
 	if tx == nil {
-		return result, fmt.Errorf("tx '%x' not found", txHash)
+		return nil, fmt.Errorf("tx '%x' not found", txHash)
 	}
 
-	msg, vmenv, err := s.computeTxEnv(blockHash, int(txIndex))
+	msg, vmenv, err := s.computeTxEnv(blockHash, int(txIndex), vm.Config{Tracer: tracer})
 	if err != nil {
 		return nil, err
 	}
 
 	gp := new(core.GasPool).AddGas(tx.Gas())
-	ret, gas, _, err := core.ApplyMessage(vmenv, msg, gp)
-	return &ExecutionResult{
-		Gas:         gas,
-		ReturnValue: fmt.Sprintf("%x", ret),
-	}, nil
+	ret, gas, failed, err := core.ApplyMessage(vmenv, msg, gp)
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	return traceResult(tracer, gas, failed, ret), nil
+}
+
+// txTraceResult is the trace of a single transaction of a traced block.
+type txTraceResult struct {
+	TxHash common.Hash `json:"txHash"`
+	Result interface{} `json:"result"`
+}
+
+// TraceBlockByNumber replays the block with the given number on top of its
+// parent state and returns the traces of all its transactions.
+func (s *PublicDebugAPI) TraceBlockByNumber(number rpc.BlockNumber, config *TraceArgs) ([]*txTraceResult, error) {
+	block := blockByNumber(s.eth.Miner(), s.eth.BlockChain(), number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return s.traceBlock(block, config)
+}
+
+// TraceBlockByHash replays the block with the given hash on top of its parent
+// state and returns the traces of all its transactions.
+func (s *PublicDebugAPI) TraceBlockByHash(hash common.Hash, config *TraceArgs) ([]*txTraceResult, error) {
+	block := s.eth.BlockChain().GetBlock(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+	return s.traceBlock(block, config)
+}
+
+// traceBlock processes the given block on its parent state with a fresh
+// tracer attached to every transaction.
+func (s *PublicDebugAPI) traceBlock(block *types.Block, config *TraceArgs) ([]*txTraceResult, error) {
+	parent := s.eth.BlockChain().GetBlock(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := s.eth.BlockChain().StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	txs := block.Transactions()
+	tracers := make([]vm.Tracer, len(txs))
+	for i := range tracers {
+		if tracers[i], err = config.newTracer(); err != nil {
+			return nil, err
+		}
+	}
+	proc := core.NewStateProcessor(s.eth.chainConfig, s.eth.BlockChain())
+	receipts, _, _, err := proc.Trace(block, statedb, func(i int, tx *types.Transaction) vm.Config {
+		return vm.Config{Tracer: tracers[i]}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*txTraceResult, len(txs))
+	for i, tx := range txs {
+		failed := receipts[i].Status == types.TxFailure
+		results[i] = &txTraceResult{
+			TxHash: tx.Hash(),
+			Result: traceResult(tracers[i], receipts[i].GasUsed, failed, nil),
+		}
+	}
+	return results, nil
 }
 
 // computeTxEnv returns the execution environment of a certain transaction.
-func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, *core.VMEnv, error) {
+// The given vm configuration is only applied to the environment of the
+// target transaction, the preceding ones are replayed without it.
+func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, cfg vm.Config) (core.Message, *core.VMEnv, error) {
 
 	// Create the parent state.
 	block := s.eth.BlockChain().GetBlock(blockHash)
@@ -2136,11 +2362,11 @@ func (s *PublicDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.
 			data:     tx.Data(),
 		}
 
-		vmenv := core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header())
 		if idx == txIndex {
-			return msg, vmenv, nil
+			return msg, core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header(), cfg), nil
 		}
 
+		vmenv := core.NewEnv(statedb, s.eth.chainConfig, s.eth.BlockChain(), msg, block.Header(), vm.Config{})
 		gp := new(core.GasPool).AddGas(tx.Gas())
 		_, _, _, err := core.ApplyMessage(vmenv, msg, gp)
 		if err != nil {
@@ -35,11 +35,13 @@ import (
 	"github.com/ethereumproject/go-ethereum/common/httpclient"
 	"github.com/ethereumproject/go-ethereum/common/registrar/ethreg"
 	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/bloombits"
 	"github.com/ethereumproject/go-ethereum/core/types"
 	"github.com/ethereumproject/go-ethereum/eth/downloader"
 	"github.com/ethereumproject/go-ethereum/eth/filters"
 	"github.com/ethereumproject/go-ethereum/ethdb"
 	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/light"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/ethereumproject/go-ethereum/miner"
@@ -63,12 +65,19 @@ type Config struct {
 	NetworkId int // Network ID to use for selecting peers to connect to
 	Genesis   *core.GenesisDump
 	FastSync  bool // Enables the state download based fast synchronisation algorithm
+	LightServ int  // Maximum percentage of time allowed for serving LES requests (0 = disabled)
 	MaxPeers  int
 
+	Checkpoint *core.TrustedCheckpoint // Trusted CHT section light clients start header sync from, and fast sync links headers to
+
 	BlockChainVersion  int
 	SkipBcVersionCheck bool // e.g. blockchain export
 	DatabaseCache      int
 	DatabaseHandles    int
+	GCMode             string // State trie garbage collection mode ("full" or "archive")
+	AncientThreshold   uint64 // Number of recent blocks kept out of the ancient store (0 = disabled)
+
+	TxPool core.TxPoolConfig // Transaction pool limits and local transaction journal
 
 	NatSpec   bool
 	DocRoot   string
@@ -82,7 +91,9 @@ type Config struct {
 	MinerThreads   int
 	SolcPath       string
 
-	UseAddrTxIndex bool
+	UseAddrTxIndex      bool
+	AddrTxIndexInternal bool // Index addresses reached by internal value transfers
+	AddrTxIndexTokens   bool // Index addresses of ERC20 token transfers
 
 	GpoMinGasPrice          *big.Int
 	GpoMaxGasPrice          *big.Int
@@ -113,6 +124,7 @@ type Ethereum struct {
 	accountManager  *accounts.Manager
 	pow             *ethash.Ethash
 	protocolManager *ProtocolManager
+	lesServer       LesServer
 	SolcPath        string
 	solc            *compiler.Solidity
 	gpo             *GasPriceOracle
@@ -128,6 +140,11 @@ type Ethereum struct {
 
 	eventMux *event.TypeMux
 	miner    *miner.Miner
+	enrSub   event.TypeMuxSubscription // Chain head subscription updating the node record
+
+	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
+	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	chtIndexer    *core.ChainIndexer             // Canonical hash trie indexer operating during block imports
 
 	Mining        bool
 	MinerThreads  int
@@ -138,6 +155,8 @@ type Ethereum struct {
 	etherbase     common.Address
 	netVersionId  int
 	netRPCService *PublicNetAPI
+
+	subscriptionAPI *filters.PublicSubscriptionAPI // eth_subscribe service, stopped with the node
 }
 
 func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
@@ -152,6 +171,15 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if err := addMipmapBloomBins(chainDb); err != nil {
 		return nil, err
 	}
+	// Keep the immutable chain history in the ancient store of persistent nodes
+	if dir := ctx.ResolvePath(filepath.Join("chaindata", "ancient")); dir != "" {
+		freezerDb, err := ethdb.NewDatabaseWithFreezer(chainDb, dir, core.FreezerTables)
+		if err != nil {
+			chainDb.Close()
+			return nil, err
+		}
+		chainDb = freezerDb
+	}
 
 	dappDb, err := ctx.OpenDatabase("dapp", config.DatabaseCache, config.DatabaseHandles)
 	if err != nil {
@@ -216,6 +244,12 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		GpobaseStepUp:           config.GpobaseStepUp,
 		GpobaseCorrectionFactor: config.GpobaseCorrectionFactor,
 		httpclient:              httpclient.New(config.DocRoot),
+		bloomRequests:           make(chan chan *bloombits.Retrieval),
+		bloomIndexer:            NewBloomIndexer(chainDb, bloomBitsBlocks),
+	}
+	// Only les servers need the canonical hash tries to prove headers with
+	if config.LightServ > 0 {
+		eth.chtIndexer = light.NewChtIndexer(chainDb)
 	}
 	switch {
 	case config.PowTest:
@@ -247,6 +281,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		if err != nil {
 			return nil, err
 		}
+		if err := core.MigrateAddrTxIndex(indexesDb); err != nil {
+			return nil, err
+		}
 		eth.indexesDb = indexesDb
 	}
 
@@ -287,16 +324,27 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		}
 		return nil, err
 	}
+	if config.GCMode != "" {
+		if err := eth.blockchain.SetGCMode(config.GCMode); err != nil {
+			return nil, err
+		}
+	}
+	eth.blockchain.SetAncientThreshold(config.AncientThreshold)
 	// Configure enabled atxi for blockchain
 	if config.UseAddrTxIndex {
 		eth.blockchain.SetAtxi(&core.AtxiT{
-			Db: eth.indexesDb,
+			Db:       eth.indexesDb,
+			Internal: config.AddrTxIndexInternal,
+			Tokens:   config.AddrTxIndexTokens,
 		})
 	}
 
 	eth.gpo = NewGasPriceOracle(eth)
 
-	newPool := core.NewTxPool(eth.chainConfig, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
+	if config.TxPool.Journal != "" {
+		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
+	}
+	newPool := core.NewTxPool(eth.chainConfig, config.TxPool, eth.EventMux(), eth.blockchain.State, eth.blockchain.GasLimit)
 	eth.txPool = newPool
 
 	m := downloader.FullSync
@@ -306,10 +354,14 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, m, uint64(config.NetworkId), eth.eventMux, eth.txPool, eth.pow, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	if cp := config.Checkpoint; cp != nil {
+		eth.protocolManager.downloader.SetCheckpoint(light.ChtSectionHead(cp.SectionIndex), cp.SectionHead)
+	}
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.pow)
 	if err = eth.miner.SetGasPrice(config.GasPrice); err != nil {
 		return nil, err
 	}
+	eth.subscriptionAPI = filters.NewPublicSubscriptionAPI(eth.eventMux, eth.protocolManager.downloader)
 
 	return eth, nil
 }
@@ -336,7 +388,7 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   NewPublicBlockChainAPI(s.chainConfig, s.blockchain, s.miner, s.chainDb, s.gpo, s.eventMux, s.accountManager),
+			Service:   NewPublicBlockChainAPI(s.chainConfig, s.blockchain, s.miner, s.chainDb, s.gpo, s.eventMux, s.accountManager, s.subscriptionAPI),
 			Public:    true,
 		}, {
 			Namespace: "eth",
@@ -351,7 +403,7 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
+			Service:   s.subscriptionAPI,
 			Public:    true,
 		}, {
 			Namespace: "miner",
@@ -366,7 +418,7 @@ func (s *Ethereum) APIs() []rpc.API {
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.chainDb, s.eventMux),
+			Service:   filters.NewPublicFilterAPI(s.chainDb, s.eventMux, s, s.subscriptionAPI),
 			Public:    true,
 		}, {
 			Namespace: "admin",
@@ -433,10 +485,29 @@ func (s *Ethereum) NetVersion() int                    { return s.netVersionId }
 func (s *Ethereum) ChainConfig() *core.ChainConfig     { return s.chainConfig }
 func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
 
+// LesServer serves light clients from the full chain, run alongside the eth
+// protocol.
+type LesServer interface {
+	Start(srvr *p2p.Server)
+	Stop()
+	Protocols() []p2p.Protocol
+}
+
+// AddLesServer sets the light client server to run with the eth protocol. It
+// must be called before the node is started.
+func (s *Ethereum) AddLesServer(ls LesServer) {
+	s.lesServer = ls
+}
+
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
-	return s.protocolManager.SubProtocols
+	if s.lesServer == nil {
+		return s.protocolManager.SubProtocols
+	}
+	// Copy the eth protocols, appending could overwrite their spare capacity
+	protos := append([]p2p.Protocol{}, s.protocolManager.SubProtocols...)
+	return append(protos, s.lesServer.Protocols()...)
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
@@ -445,7 +516,18 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	if s.AutoDAG {
 		s.StartAutoDAG()
 	}
+	// Start the bloom bits servicing goroutines and index the chain
+	s.startBloomHandlers()
+	s.bloomIndexer.Start(s.blockchain.CurrentHeader(), s.eventMux)
+	if s.chtIndexer != nil {
+		s.chtIndexer.Start(s.blockchain.CurrentHeader(), s.eventMux)
+	}
+
 	s.protocolManager.Start(s.config.MaxPeers)
+	s.startENRUpdater(srvr)
+	if s.lesServer != nil {
+		s.lesServer.Start(srvr)
+	}
 	s.netRPCService = NewPublicNetAPI(srvr, s.NetVersion())
 	return nil
 }
@@ -453,8 +535,19 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
+	s.bloomIndexer.Close()
+	if s.chtIndexer != nil {
+		s.chtIndexer.Close()
+	}
 	s.blockchain.Stop()
+	s.subscriptionAPI.Stop()
+	if s.enrSub != nil {
+		s.enrSub.Unsubscribe()
+	}
 	s.protocolManager.Stop()
+	if s.lesServer != nil {
+		s.lesServer.Stop()
+	}
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.eventMux.Stop()
@@ -581,46 +674,46 @@ func upgradeChainDatabase(db ethdb.Database) error {
 	// At least some of the database is still the old format, upgrade (skip the head block!)
 	glog.V(logger.Info).Info("Old database detected, upgrading...")
 
-	if db, ok := db.(*ethdb.LDBDatabase); ok {
-		blockPrefix := []byte("block-hash-")
-		for it := db.NewIterator(); it.Next(); {
-			// Skip anything other than a combined block
-			if !bytes.HasPrefix(it.Key(), blockPrefix) {
-				continue
-			}
-			// Skip the head block (merge last to signal upgrade completion)
-			if bytes.HasSuffix(it.Key(), head.Bytes()) {
-				continue
-			}
-			// Load the block, split and serialize (order!)
-			block := core.GetBlockByHashOld(db, common.BytesToHash(bytes.TrimPrefix(it.Key(), blockPrefix)))
+	blockPrefix := []byte("block-hash-")
+	it := db.NewIterator(blockPrefix, nil)
+	defer it.Release()
 
-			if err := core.WriteTd(db, block.Hash(), block.DeprecatedTd()); err != nil {
-				return err
-			}
-			if err := core.WriteBody(db, block.Hash(), block.Body()); err != nil {
-				return err
-			}
-			if err := core.WriteHeader(db, block.Header()); err != nil {
-				return err
-			}
-			if err := db.Delete(it.Key()); err != nil {
-				return err
-			}
+	for it.Next() {
+		// Skip the head block (merge last to signal upgrade completion)
+		if bytes.HasSuffix(it.Key(), head.Bytes()) {
+			continue
 		}
-		// Lastly, upgrade the head block, disabling the upgrade mechanism
-		current := core.GetBlockByHashOld(db, head)
+		// Load the block, split and serialize (order!)
+		block := core.GetBlockByHashOld(db, common.BytesToHash(bytes.TrimPrefix(it.Key(), blockPrefix)))
 
-		if err := core.WriteTd(db, current.Hash(), current.DeprecatedTd()); err != nil {
+		if err := core.WriteTd(db, block.Hash(), block.DeprecatedTd()); err != nil {
+			return err
+		}
+		if err := core.WriteBody(db, block.Hash(), block.Body()); err != nil {
 			return err
 		}
-		if err := core.WriteBody(db, current.Hash(), current.Body()); err != nil {
+		if err := core.WriteHeader(db, block.Header()); err != nil {
 			return err
 		}
-		if err := core.WriteHeader(db, current.Header()); err != nil {
+		if err := db.Delete(it.Key()); err != nil {
 			return err
 		}
 	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	// Lastly, upgrade the head block, disabling the upgrade mechanism
+	current := core.GetBlockByHashOld(db, head)
+
+	if err := core.WriteTd(db, current.Hash(), current.DeprecatedTd()); err != nil {
+		return err
+	}
+	if err := core.WriteBody(db, current.Hash(), current.Body()); err != nil {
+		return err
+	}
+	if err := core.WriteHeader(db, current.Header()); err != nil {
+		return err
+	}
 	return nil
 }
 
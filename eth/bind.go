@@ -19,12 +19,21 @@ package eth
 import (
 	"math/big"
 
+	"github.com/ethereumproject/go-ethereum/accounts/abi/bind"
 	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
 	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/eth/filters"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
 	"github.com/ethereumproject/go-ethereum/rlp"
 	"github.com/ethereumproject/go-ethereum/rpc"
 )
 
+// This nil assignment ensures compile time that ContractBackend implements bind.ContractBackend.
+var _ bind.ContractBackend = (*ContractBackend)(nil)
+
 // ContractBackend implements bind.ContractBackend with direct calls to Ethereum
 // internals to support operating on contracts within subprotocols like eth and
 // swarm.
@@ -36,6 +45,10 @@ type ContractBackend struct {
 	eapi  *PublicEthereumAPI        // Wrapper around the Ethereum object to access metadata
 	bcapi *PublicBlockChainAPI      // Wrapper around the blockchain to access chain data
 	txapi *PublicTransactionPoolAPI // Wrapper around the transaction pool to access transaction data
+
+	chainDb  ethdb.Database     // Block chain database to search for logs
+	index    filters.BloomIndex // Bloom bits index to speed up log searches
+	eventMux *event.TypeMux     // Event mux to watch for new logs
 }
 
 // NewContractBackend creates a new native contract backend using an existing
@@ -43,8 +56,12 @@ type ContractBackend struct {
 func NewContractBackend(eth *Ethereum) *ContractBackend {
 	return &ContractBackend{
 		eapi:  NewPublicEthereumAPI(eth),
-		bcapi: NewPublicBlockChainAPI(eth.chainConfig, eth.blockchain, eth.miner, eth.chainDb, eth.gpo, eth.eventMux, eth.accountManager),
+		bcapi: NewPublicBlockChainAPI(eth.chainConfig, eth.blockchain, eth.miner, eth.chainDb, eth.gpo, eth.eventMux, eth.accountManager, eth.subscriptionAPI),
 		txapi: NewPublicTransactionPoolAPI(eth),
+
+		chainDb:  eth.chainDb,
+		index:    eth,
+		eventMux: eth.eventMux,
 	}
 }
 
@@ -112,3 +129,65 @@ func (b *ContractBackend) SendTransaction(tx *types.Transaction) error {
 	_, err := b.txapi.SendRawTransaction(common.ToHex(raw))
 	return err
 }
+
+// FilterLogs implements bind.ContractFilterer searching the local chain for the
+// logs matching the query.
+func (b *ContractBackend) FilterLogs(query bind.FilterQuery) ([]vm.Log, error) {
+	var logs []vm.Log
+	for _, log := range b.logFilter(query).Find() {
+		logs = append(logs, *log)
+	}
+	return logs, nil
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer streaming the logs
+// matching the query from every new block imported into the local chain. If
+// the query has a starting block, the matching logs already imported since are
+// delivered first.
+func (b *ContractBackend) SubscribeFilterLogs(query bind.FilterQuery, ch chan<- vm.Log) (event.Subscription, error) {
+	filter := b.logFilter(query)
+
+	// Subscribe before collecting the past logs, not to miss any block
+	events := b.eventMux.Subscribe(core.ChainEvent{})
+
+	var past vm.Logs
+	if query.FromBlock != nil {
+		past = filter.Find()
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer events.Unsubscribe()
+
+		for _, log := range past {
+			select {
+			case ch <- *log:
+			case <-quit:
+				return nil
+			}
+		}
+		for {
+			select {
+			case ev, ok := <-events.Chan():
+				if !ok {
+					return nil
+				}
+				for _, log := range filter.FilterLogs(ev.Data.(core.ChainEvent).Logs) {
+					select {
+					case ch <- *log:
+					case <-quit:
+						return nil
+					}
+				}
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// logFilter creates a log filter over the chain database from a filter query,
+// using the bloom bits index.
+func (b *ContractBackend) logFilter(query bind.FilterQuery) *filters.Filter {
+	filter := filters.NewQueryFilter(b.chainDb, query.FromBlock, query.ToBlock, query.Addresses, query.Topics)
+	filter.SetBloomIndex(b.index)
+	return filter
+}
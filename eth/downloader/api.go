@@ -18,12 +18,9 @@ package downloader
 
 import (
 	"context"
-	"sync"
-
 	"math/big"
 
 	"github.com/ethereumproject/go-ethereum/common"
-	"github.com/ethereumproject/go-ethereum/event"
 	"github.com/ethereumproject/go-ethereum/rpc"
 )
 
@@ -42,49 +39,6 @@ type FailedEvent struct {
 	Err  error
 }
 
-// PublicDownloaderAPI provides an API which gives information about the current synchronisation status.
-// It offers only methods that operates on data that can be available to anyone without security risks.
-type PublicDownloaderAPI struct {
-	d                   *Downloader
-	mux                 *event.TypeMux
-	muSyncSubscriptions sync.Mutex
-	syncSubscriptions   map[string]rpc.Subscription
-}
-
-// NewPublicDownloaderAPI create a new PublicDownloaderAPI.
-func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAPI {
-	api := &PublicDownloaderAPI{d: d, mux: m, syncSubscriptions: make(map[string]rpc.Subscription)}
-
-	go api.run()
-
-	return api
-}
-
-func (api *PublicDownloaderAPI) run() {
-	sub := api.mux.Subscribe(StartEvent{}, DoneEvent{}, FailedEvent{})
-
-	for event := range sub.Chan() {
-		var notification interface{}
-
-		switch event.Data.(type) {
-		case StartEvent:
-			result := &SyncingResult{Syncing: true}
-			result.Status.Origin, result.Status.Current, result.Status.Height, result.Status.Pulled, result.Status.Known = api.d.Progress()
-			notification = result
-		case DoneEvent, FailedEvent:
-			notification = false
-		}
-
-		api.muSyncSubscriptions.Lock()
-		for id, sub := range api.syncSubscriptions {
-			if sub.Notify(notification) == rpc.ErrNotificationNotFound {
-				delete(api.syncSubscriptions, id)
-			}
-		}
-		api.muSyncSubscriptions.Unlock()
-	}
-}
-
 // Progress gives progress indications when the node is synchronising with the Ethereum network.
 type Progress struct {
 	Origin  uint64 `json:"startingBlock"`
@@ -100,26 +54,27 @@ type SyncingResult struct {
 	Status  Progress `json:"status"`
 }
 
-// Syncing provides information when this nodes starts synchronising with the Ethereum network and when it's finished.
-func (api *PublicDownloaderAPI) Syncing(ctx context.Context) (rpc.Subscription, error) {
-	notifier, supported := rpc.NotifierFromContext(ctx)
-	if !supported {
-		return nil, rpc.ErrNotificationsUnsupported
-	}
-
-	subscription, err := notifier.NewSubscription(func(id string) {
-		api.muSyncSubscriptions.Lock()
-		delete(api.syncSubscriptions, id)
-		api.muSyncSubscriptions.Unlock()
-	})
+// SyncSubscriber creates subscriptions to the synchronisation status, see
+// filters.PublicSubscriptionAPI.
+type SyncSubscriber interface {
+	Syncing(ctx context.Context) (rpc.Subscription, error)
+}
 
-	if err != nil {
-		return nil, err
-	}
+// PublicDownloaderAPI provides an API which gives information about the current synchronisation status.
+//
+// Deprecated: the syncing subscription is served by filters.PublicSubscriptionAPI.
+type PublicDownloaderAPI struct {
+	subs SyncSubscriber
+}
 
-	api.muSyncSubscriptions.Lock()
-	api.syncSubscriptions[subscription.ID()] = subscription
-	api.muSyncSubscriptions.Unlock()
+// NewPublicDownloaderAPI create a new PublicDownloaderAPI, serving the subscriptions of subs.
+func NewPublicDownloaderAPI(subs SyncSubscriber) *PublicDownloaderAPI {
+	return &PublicDownloaderAPI{subs: subs}
+}
 
-	return subscription, nil
+// Syncing provides information when this nodes starts synchronising with the Ethereum network and when it's finished.
+//
+// Deprecated: use the syncing subscription of filters.PublicSubscriptionAPI, which this calls.
+func (api *PublicDownloaderAPI) Syncing(ctx context.Context) (rpc.Subscription, error) {
+	return api.subs.Syncing(ctx)
 }
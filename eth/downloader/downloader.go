@@ -33,6 +33,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/ethereumproject/go-ethereum/metrics"
+	"github.com/ethereumproject/go-ethereum/trie"
 )
 
 const (
@@ -88,10 +89,12 @@ var (
 	errCancelBodyFetch         = errors.New("block body download canceled (requested)")
 	errCancelReceiptFetch      = errors.New("receipt download canceled (requested)")
 	errCancelStateFetch        = errors.New("state data download canceled (requested)")
+	errStateUnavailable        = errors.New("state data unavailable from all peers")
 	errCancelHeaderProcessing  = errors.New("header processing canceled (requested)")
 	errCancelContentProcessing = errors.New("content processing canceled (requested)")
 	errNoSyncActive            = errors.New("no sync active")
 	errTooOld                  = errors.New("peer doesn't speak recent enough protocol version (need version >= 62)")
+	errCheckpointMismatch      = errors.New("checkpoint header mismatch")
 )
 
 func ErrWasRequested(e error) bool {
@@ -155,6 +158,11 @@ type Downloader struct {
 	lightchain LightChain
 	blockchain BlockChain
 
+	// Trusted checkpoint, fast sync links the headers below it by hash instead
+	// of verifying their proof-of-work
+	checkpoint     uint64      // Number of the trusted checkpoint header, 0 if none
+	checkpointHash common.Hash // Hash of the trusted checkpoint header
+
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
 
@@ -163,6 +171,11 @@ type Downloader struct {
 	synchronising   int32
 	committed       int32
 
+	// Fast sync state download
+	stateBloom     *trie.SyncBloom // Filter of the downloaded state nodes, nil if it can't be trusted
+	stateBloomInit bool            // Whether the filter was set up already
+	stateBloomLock sync.Mutex      // Lock protecting the state bloom fields
+
 	// Channels
 	headerCh      chan dataPack        // [eth/62] Channel receiving inbound block headers
 	bodyCh        chan dataPack        // [eth/62] Channel receiving inbound block bodies
@@ -275,6 +288,14 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+// SetCheckpoint sets the header of a trusted checkpoint. Fast sync imports the
+// headers below it without proof-of-work verification, relying on their hashes
+// linking up with the checkpoint instead. Peers whose chain doesn't contain the
+// checkpoint are dropped. It must be called before synchronising.
+func (d *Downloader) SetCheckpoint(number uint64, hash common.Hash) {
+	d.checkpoint, d.checkpointHash = number, hash
+}
+
 func (d *Downloader) currentLocalChainHeight() (current uint64) {
 	current = d.lightchain.CurrentHeader().Number.Uint64() // "LightSync"
 	switch d.mode {
@@ -399,7 +420,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 		glog.V(logger.Debug).Warnln("sync busy")
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errCheckpointMismatch:
 		glog.V(logger.Core).Warnf("Peer %s: drop: %s", id, err)
 		d.dropPeer(id)
 
@@ -543,6 +564,13 @@ func (d *Downloader) syncWithPeer(p *peer, hash common.Hash, td *big.Int) (err e
 			origin = 0
 		} else {
 			pivot = height - uint64(fsMinFullBlocks)
+
+			// Keep the pivot of an interrupted fast sync if it isn't stale yet,
+			// so its state download can be resumed
+			if progress := d.readSyncProgress(); progress != nil && progress.Pivot < pivot && pivot <= progress.Pivot+2*uint64(fsMinFullBlocks) {
+				glog.V(logger.Info).Infof("Resuming fast sync state download of pivot #%d", progress.Pivot)
+				pivot = progress.Pivot
+			}
 			if pivot <= origin {
 				origin = pivot - 1
 			}
@@ -552,6 +580,15 @@ func (d *Downloader) syncWithPeer(p *peer, hash common.Hash, td *big.Int) (err e
 	if d.mode == FastSync && pivot != 0 {
 		d.committed = 0
 	}
+	// Link the headers up to a trusted checkpoint by hash if the sync crosses
+	// it, making sure first that the peer is on the checkpoint's chain
+	var anchor uint64
+	if d.mode == FastSync && d.checkpoint != 0 && origin < d.checkpoint && d.checkpoint <= height {
+		if err := d.fetchCheckpoint(p); err != nil {
+			return err
+		}
+		anchor = d.checkpoint
+	}
 	// Initiate the sync using a concurrent header and content retrieval algorithm
 	d.queue.Prepare(origin+1, d.mode)
 	if d.syncInitHook != nil {
@@ -562,10 +599,10 @@ func (d *Downloader) syncWithPeer(p *peer, hash common.Hash, td *big.Int) (err e
 		func() error { return d.fetchHeaders(p, origin+1, pivot) }, // Headers are always retrieved
 		func() error { return d.fetchBodies(origin + 1) },          // Bodies are retrieved during normal and fast sync
 		func() error { return d.fetchReceipts(origin + 1) },        // Receipts are retrieved during fast sync
-		func() error { return d.processHeaders(origin+1, pivot, td) },
+		func() error { return d.processHeaders(origin+1, pivot, anchor, td) },
 	}
 	if d.mode == FastSync {
-		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
+		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest, pivot) })
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
@@ -637,6 +674,9 @@ func (d *Downloader) Terminate() {
 
 	// Cancel any pending download requests
 	d.Cancel()
+
+	// Save the state bloom for an interrupted fast sync to resume with
+	d.saveStateBloom()
 }
 
 // fetchHeight retrieves the head header of the remote peer to aid in estimating
@@ -685,6 +725,50 @@ func (d *Downloader) fetchHeight(p *peer) (*types.Header, error) {
 	}
 }
 
+// fetchCheckpoint retrieves the header of the remote peer at the number of the
+// trusted checkpoint, making sure that the peer's chain contains it.
+func (d *Downloader) fetchCheckpoint(p *peer) error {
+	glog.V(logger.Debug).Infof("%v: retrieving checkpoint header #%d", p, d.checkpoint)
+
+	go p.getAbsHeaders(d.checkpoint, 1, 0, false)
+
+	ttl := d.requestTTL()
+	timer := time.NewTimer(ttl)
+	defer timer.Stop()
+	for {
+		select {
+		case <-d.cancelCh:
+			return errCancelBlockFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				glog.V(logger.Debug).Infof("Received headers from incorrect peer(%s)", packet.PeerId())
+				break
+			}
+			// Make sure the peer gave us the checkpoint
+			headers := packet.(*headerPack).headers
+			if len(headers) != 1 {
+				glog.V(logger.Debug).Infof("%v: invalid number of checkpoint headers: %d != 1", p, len(headers))
+				return errBadPeer
+			}
+			if hash := headers[0].Hash(); headers[0].Number.Uint64() != d.checkpoint || hash != d.checkpointHash {
+				glog.V(logger.Debug).Infof("%v: checkpoint mismatch: have #%d [%x…], want #%d [%x…]", p, headers[0].Number, hash[:4], d.checkpoint, d.checkpointHash[:4])
+				return errCheckpointMismatch
+			}
+			return nil
+
+		case <-timer.C:
+			glog.V(logger.Debug).Infof("%v: checkpoint header timeout (%v)", p, ttl)
+			return errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
 // findAncestor tries to locate the common ancestor link of the local chain and
 // a remote peers blockchain. In the general case when our node was in sync and
 // on the correct chain, checking the top N links should already get us a match.
@@ -1240,28 +1324,40 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 // processHeaders takes batches of retrieved headers from an input channel and
 // keeps processing and scheduling them into the header chain and downloader's
 // queue until the stream ends or a failure occurs.
-func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) error {
-	// Keep a count of uncertain headers to roll back
+//
+// Headers up to a non-zero anchor, the number of the trusted checkpoint, are
+// imported without proof-of-work verification. They're all rolled back unless
+// the header at the anchor matches the checkpoint.
+func (d *Downloader) processHeaders(origin uint64, pivot uint64, anchor uint64, td *big.Int) error {
+	// Keep a count of uncertain headers to roll back, and the first header not
+	// yet linked up with the checkpoint
 	rollback := []*types.Header{}
+	unanchored := uint64(0)
 	defer func() {
-		if len(rollback) > 0 {
-			// Flatten the headers and roll them back
-			hashes := make([]common.Hash, len(rollback))
-			for i, header := range rollback {
-				hashes[i] = header.Hash()
-			}
+		if len(rollback) > 0 || unanchored != 0 {
 			lastHeader, lastFastBlock, lastBlock := d.lightchain.CurrentHeader().Number, common.Big0, common.Big0
 			if d.mode != LightSync {
 				lastFastBlock = d.blockchain.CurrentFastBlock().Number()
 				lastBlock = d.blockchain.CurrentBlock().Number()
 			}
-			d.lightchain.Rollback(hashes)
+			count := 0
+			if unanchored != 0 {
+				count = d.rollbackFrom(unanchored)
+			} else {
+				// Flatten the headers and roll them back
+				hashes := make([]common.Hash, len(rollback))
+				for i, header := range rollback {
+					hashes[i] = header.Hash()
+				}
+				d.lightchain.Rollback(hashes)
+				count = len(hashes)
+			}
 			curFastBlock, curBlock := common.Big0, common.Big0
 			if d.mode != LightSync {
 				curFastBlock = d.blockchain.CurrentFastBlock().Number()
 				curBlock = d.blockchain.CurrentBlock().Number()
 			}
-			glog.V(logger.Warn).Warnln("Rolled back headers", "count", len(hashes),
+			glog.V(logger.Warn).Warnln("Rolled back headers", "count", count,
 				"header", fmt.Sprintf("%d->%d", lastHeader, d.lightchain.CurrentHeader().Number),
 				"fast", fmt.Sprintf("%d->%d", lastFastBlock, curFastBlock),
 				"block", fmt.Sprintf("%d->%d", lastBlock, curBlock))
@@ -1317,6 +1413,10 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 						return errStallingPeer
 					}
 				}
+				// The checkpoint must have been delivered if the sync crossed it
+				if unanchored != 0 {
+					return errStallingPeer
+				}
 				// Disable any rollback and return
 				rollback = nil
 				return nil
@@ -1347,10 +1447,18 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 							unknown = append(unknown, header)
 						}
 					}
-					// If we're importing pure headers, verify based on their recentness
+					// If we're importing pure headers, verify based on their recentness,
+					// the ones up to the checkpoint are verified by linking up with it
+					first, last := chunk[0].Number.Uint64(), chunk[len(chunk)-1].Number.Uint64()
+
 					frequency := fsHeaderCheckFrequency
-					if chunk[len(chunk)-1].Number.Uint64()+uint64(fsHeaderForceVerify) > pivot {
+					if last+uint64(fsHeaderForceVerify) > pivot {
 						frequency = 1
+					} else if last <= anchor {
+						frequency = 0
+						if unanchored == 0 {
+							unanchored = first
+						}
 					}
 					res := d.lightchain.InsertHeaderChain(chunk, frequency)
 					// TODO(whilei): again, send error to events
@@ -1368,6 +1476,14 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 					if len(rollback) > fsHeaderSafetyNet {
 						rollback = append(rollback[:0], rollback[len(rollback)-fsHeaderSafetyNet:]...)
 					}
+					// Once the checkpoint is imported, the headers below it are verified
+					if first <= anchor && anchor <= last {
+						if hash := chunk[anchor-first].Hash(); hash != d.checkpointHash {
+							glog.V(logger.Debug).Infof("Checkpoint mismatch: have #%d [%x…], want [%x…]", anchor, hash[:4], d.checkpointHash[:4])
+							return errCheckpointMismatch
+						}
+						unanchored = 0
+					}
 				}
 				// Unless we're doing light chains, schedule the headers for associated content retrieval
 				if d.mode == FullSync || d.mode == FastSync {
@@ -1408,6 +1524,35 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 	}
 }
 
+// rollbackFrom rolls the local chain back to below the given block number in
+// batches of fsHeaderSafetyNet headers, returning the number of headers rolled
+// back.
+func (d *Downloader) rollbackFrom(number uint64) int {
+	count := 0
+	for {
+		// Collect the next batch from the head down, ordered by ascending number
+		head := d.lightchain.CurrentHeader()
+
+		var hashes []common.Hash
+		for header := head; header != nil && header.Number.Uint64() >= number && len(hashes) < fsHeaderSafetyNet; header = d.lightchain.GetHeaderByHash(header.ParentHash) {
+			hashes = append(hashes, header.Hash())
+		}
+		if len(hashes) == 0 {
+			return count
+		}
+		for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+			hashes[i], hashes[j] = hashes[j], hashes[i]
+		}
+		d.lightchain.Rollback(hashes)
+		count += len(hashes)
+
+		// Bail out if the head didn't move, to avoid spinning forever
+		if d.lightchain.CurrentHeader().Hash() == head.Hash() {
+			return count
+		}
+	}
+}
+
 // processFullSyncContent takes fetch results from the queue and imports them into the chain.
 func (d *Downloader) processFullSyncContent() error {
 	for {
@@ -1456,27 +1601,31 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 
 // processFastSyncContent takes fetch results from the queue and writes them to the
 // database. It also controls the synchronisation of state nodes of the pivot block.
-func (d *Downloader) processFastSyncContent(latest *types.Header) error {
-	// Start syncing state of the reported head block.
-	// This should get us most of the state of the pivot block.
-	stateSync := d.syncState(latest.Root)
+// Note, that the pivot goalpost may move if the sync takes long enough for the
+// chain head to move significantly, or if the peers drop its state meanwhile.
+func (d *Downloader) processFastSyncContent(latest *types.Header, pivot uint64) error {
+	// Start syncing state of the reported head block, or resume the state
+	// download of an interrupted sync of the same pivot. This should get us
+	// most of the state of the pivot block.
+	root := latest.Root
+	if progress := d.readSyncProgress(); progress != nil && progress.Pivot == pivot {
+		root = progress.Root
+	} else {
+		d.writeSyncProgress(pivot, root)
+	}
+	stateSync := d.syncState(root)
 	defer stateSync.Cancel()
 	go func() {
-		if err := stateSync.Wait(); err != nil {
+		if err := stateSync.Wait(); err != nil && err != errStateUnavailable {
 			d.queue.Close() // wake up WaitResults
 		}
 	}()
-	// Figure out the ideal pivot block. Note, that this goalpost may move if the
-	// sync takes long enough for the chain head to move significantly.
-	pivot := uint64(0)
-	if height := latest.Number.Uint64(); height > uint64(fsMinFullBlocks) {
-		pivot = height - uint64(fsMinFullBlocks)
-	}
 	// To cater for moving pivot points, track the pivot block and subsequently
 	// accumulated download results separatey.
 	var (
 		oldPivot *fetchResult   // Locked in pivot block, might change eventually
 		oldTail  []*fetchResult // Downloaded content after the pivot
+		restart  bool           // Whether to retry the state download of the same pivot
 	)
 	for {
 		// Wait for the next batch of downloaded data to be available, and if the pivot
@@ -1514,21 +1663,38 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 		}
 		if P != nil {
 			// If new pivot block found, cancel old state retrieval and restart
-			if oldPivot != P {
+			if oldPivot != P || restart {
 				stateSync.Cancel()
 
+				d.writeSyncProgress(pivot, P.Header.Root)
 				stateSync = d.syncState(P.Header.Root)
 				defer stateSync.Cancel()
 				go func() {
-					if err := stateSync.Wait(); err != nil {
+					if err := stateSync.Wait(); err != nil && err != errStateUnavailable {
 						d.queue.Close() // wake up WaitResults
 					}
 				}()
-				oldPivot = P
+				oldPivot, restart = P, false
 			}
 			// Wait for completion, occasionally checking for pivot staleness
 			select {
 			case <-stateSync.done:
+				if stateSync.err == errStateUnavailable {
+					// The peers dropped the pivot state already, move the pivot
+					// forward as soon as the chain head allows it and retry
+					if height := latest.Number.Uint64(); height > pivot+uint64(fsMinFullBlocks) {
+						glog.V(logger.Warn).Warnln("Pivot state unavailable, moving", "old", pivot, "new", height-uint64(fsMinFullBlocks))
+						pivot = height - uint64(fsMinFullBlocks)
+					} else {
+						select {
+						case <-d.cancelCh:
+							return stateSync.Cancel()
+						case <-time.After(fsHeaderContCheck):
+						}
+					}
+					oldTail, restart = afterP, true
+					continue
+				}
 				if stateSync.err != nil {
 					return stateSync.err
 				}
@@ -1573,7 +1739,7 @@ func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *state
 	case <-d.quitCh:
 		return errCancelContentProcessing
 	case <-stateSync.done:
-		if err := stateSync.Wait(); err != nil {
+		if err := stateSync.Wait(); err != nil && err != errStateUnavailable {
 			return err
 		}
 	default:
@@ -1611,6 +1777,7 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 		return err
 	}
 	atomic.StoreInt32(&d.committed, 1)
+	d.finishSyncProgress()
 	// TODO(whilei): pass error in Receipt and Full chain events through
 	go d.mux.Post(InsertReceiptChainEvent{ReceiptChainInsertEvent: res.ReceiptChainInsertEvent, Pivot: false})
 	return nil
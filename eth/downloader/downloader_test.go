@@ -64,6 +64,7 @@ type downloadTester struct {
 	ownBlocks   map[common.Hash]*types.Block   // Blocks belonging to the tester
 	ownReceipts map[common.Hash]types.Receipts // Receipts belonging to the tester
 	ownChainTd  map[common.Hash]*big.Int       // Total difficulties of the blocks in the local chain
+	unverified  int                            // Number of headers imported without proof-of-work verification
 
 	peerHashes   map[string][]common.Hash                  // Hash chain belonging to different test peers
 	peerHeaders  map[string]map[common.Hash]*types.Header  // Headers belonging to different test peers
@@ -346,6 +347,9 @@ func (dl *downloadTester) InsertHeaderChain(headers []*types.Header, checkFreq i
 		dl.ownHashes = append(dl.ownHashes, header.Hash())
 		dl.ownHeaders[header.Hash()] = header
 		dl.ownChainTd[header.Hash()] = new(big.Int).Add(dl.ownChainTd[header.ParentHash], header.Difficulty)
+		if checkFreq == 0 {
+			dl.unverified++
+		}
 	}
 	res.Index = len(headers)
 	return
@@ -1801,3 +1805,92 @@ func testDeliverHeadersHang(t *testing.T, protocol int, mode SyncMode) {
 		//tester.downloader.peers.peers["peer"]
 	}
 }
+
+// Tests that the state bloom of an unfinished fast sync is persisted on a clean
+// shutdown and resumed, but isn't trusted after an unclean one, while the sync
+// progress survives both.
+func TestFastSyncProgressResumption(t *testing.T) {
+	tester := newTester()
+
+	// A new sync starts with an empty filter, saved on shutdown
+	d := tester.downloader
+	bloom := d.syncBloom()
+	if bloom == nil {
+		t.Fatal("no state bloom for a new fast sync")
+	}
+	d.writeSyncProgress(100, common.Hash{1})
+	bloom.Add(common.Hash{2})
+	d.Terminate()
+
+	// A restart resumes the saved filter, dropping it from the database
+	resumed := New(FastSync, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+	defer resumed.Terminate()
+
+	if bloom := resumed.syncBloom(); bloom == nil || !bloom.Contains(common.Hash{2}) {
+		t.Fatalf("state bloom not resumed: %v", bloom)
+	}
+	if data, _ := tester.stateDb.Get(fastSyncBloomKey); len(data) != 0 {
+		t.Error("resumed state bloom left in the database")
+	}
+	// A restart without a clean shutdown has no filter, but the same progress
+	unclean := New(FastSync, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+	defer unclean.Terminate()
+
+	if bloom := unclean.syncBloom(); bloom != nil {
+		t.Error("state bloom trusted after an unclean shutdown")
+	}
+	if progress := unclean.readSyncProgress(); progress == nil || progress.Pivot != 100 || progress.Root != (common.Hash{1}) {
+		t.Fatalf("sync progress mismatch: have %+v, want pivot 100, root %x", progress, common.Hash{1})
+	}
+	// A finished sync drops its progress
+	unclean.finishSyncProgress()
+	if progress := unclean.readSyncProgress(); progress != nil {
+		t.Errorf("sync progress left after finishing: %+v", progress)
+	}
+}
+
+// Tests that fast sync imports the headers up to a trusted checkpoint without
+// proof-of-work verification, and drops peers whose chain doesn't contain it.
+func TestCheckpointFastSync63(t *testing.T) { testCheckpointFastSync(t, 63) }
+func TestCheckpointFastSync64(t *testing.T) { testCheckpointFastSync(t, 64) }
+
+func testCheckpointFastSync(t *testing.T, protocol int) {
+	t.Parallel()
+
+	targetBlocks := 4*blockCacheItems - 15
+	checkpoint := uint64(2 * blockCacheItems)
+
+	// Synchronise with a peer on the checkpoint's chain
+	tester := newTester()
+	defer tester.terminate()
+
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	tester.downloader.SetCheckpoint(checkpoint, hashes[len(hashes)-1-int(checkpoint)])
+
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+	if tester.unverified == 0 || uint64(tester.unverified) > checkpoint {
+		t.Errorf("unverified headers mismatch: have %d, want 1..%d", tester.unverified, checkpoint)
+	}
+	// Failing to link up with the checkpoint rolls back all the unlinked headers
+	if count := tester.downloader.rollbackFrom(checkpoint + 1); count != targetBlocks-int(checkpoint) {
+		t.Errorf("rolled back headers mismatch: have %d, want %d", count, targetBlocks-int(checkpoint))
+	}
+	if have := len(tester.ownHashes); have != int(checkpoint)+1 {
+		t.Errorf("remaining headers mismatch: have %d, want %d", have, checkpoint+1)
+	}
+	// Synchronise with a peer whose chain doesn't contain the checkpoint
+	tester = newTester()
+	defer tester.terminate()
+
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	tester.downloader.SetCheckpoint(checkpoint, common.Hash{0x01})
+
+	if err := tester.sync("peer", nil, FastSync); err != errCheckpointMismatch {
+		t.Fatalf("checkpoint error mismatch: have %v, want %v", err, errCheckpointMismatch)
+	}
+	assertOwnChain(t, tester, 1)
+}
@@ -28,9 +28,27 @@ import (
 	"github.com/ethereumproject/go-ethereum/ethdb"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/rlp"
 	"github.com/ethereumproject/go-ethereum/trie"
 )
 
+// stateBloomSize is the size in bytes of the bloom filter of the state nodes
+// downloaded by fast sync.
+const stateBloomSize = 16 * 1024 * 1024
+
+var (
+	fastSyncProgressKey = []byte("fastSyncProgress") // RLP encoded syncProgress of an unfinished fast sync
+	fastSyncBloomKey    = []byte("fastSyncBloom")    // State bloom saved at the last clean shutdown
+)
+
+// syncProgress is the persisted progress of a fast sync: the pivot block and
+// the state root being downloaded. The pending trie nodes themselves are
+// journaled by the trie sync.
+type syncProgress struct {
+	Pivot uint64
+	Root  common.Hash
+}
+
 // stateReq represents a batch of state fetch requests groupped together into
 // a single data retrieval network packet.
 type stateReq struct {
@@ -237,9 +255,13 @@ type stateTask struct {
 // newStateSync creates a new state trie download scheduler. This method does not
 // yet start the sync. The user needs to call run to initiate.
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
+	sched := state.NewStateSync(root, d.stateDB)
+	if bloom := d.syncBloom(); bloom != nil {
+		sched.SetBloom(bloom)
+	}
 	return &stateSync{
 		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB),
+		sched:   sched,
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
@@ -329,12 +351,20 @@ func (s *stateSync) commit(force bool) error {
 	}
 	start := time.Now()
 	b := s.d.stateDB.NewBatch()
-	if written, err := s.sched.Commit(b); written == 0 || err != nil {
+	written, err := s.sched.Commit(b)
+	if err != nil {
+		return err
+	}
+	// Journal the pending nodes along, so a restart can resume from them
+	if err := s.sched.Journal(b); err != nil {
 		return err
 	}
 	if err := b.Write(); err != nil {
 		return fmt.Errorf("DB write error: %v", err)
 	}
+	if written == 0 {
+		return nil
+	}
 	s.updateStats(s.numUncommitted, 0, 0, time.Since(start))
 	s.numUncommitted = 0
 	s.bytesUncommitted = 0
@@ -438,10 +468,12 @@ func (s *stateSync) process(req *stateReq) error {
 		if len(req.response) > 0 || req.timedOut() {
 			delete(task.attempts, req.peer.id)
 		}
-		// If we've requested the node too many times already, it may be a malicious
-		// sync where nobody has the right data. Abort.
+		// If we've requested the node too many times already, the peers may have
+		// dropped the state already, or it may be a malicious sync where nobody
+		// has the right data. Abort.
 		if len(task.attempts) >= npeers {
-			return fmt.Errorf("state node %s failed with all peers (%d tries, %d peers)", hash.Hex(), len(task.attempts), npeers)
+			glog.V(logger.Debug).Warnln("State node failed with all peers", "hash", hash.Hex(), "tries", len(task.attempts), "peers", npeers)
+			return errStateUnavailable
 		}
 		// Missing item, place into the retry queue.
 		s.tasks[hash] = task
@@ -476,3 +508,81 @@ func (s *stateSync) updateStats(written, duplicate, unexpected int, duration tim
 		glog.V(logger.Debug).Infoln("Imported new state entries", "count", written, "elapsed", duration.String(), "processed", s.d.syncStatsState.processed, "pending", s.d.syncStatsState.pending, "retry", len(s.tasks), "duplicate", s.d.syncStatsState.duplicate, "unexpected", s.d.syncStatsState.unexpected)
 	}
 }
+
+// readSyncProgress returns the persisted progress of an unfinished fast sync,
+// or nil if there is none.
+func (d *Downloader) readSyncProgress() *syncProgress {
+	data, _ := d.stateDB.Get(fastSyncProgressKey)
+	if len(data) == 0 {
+		return nil
+	}
+	progress := new(syncProgress)
+	if err := rlp.DecodeBytes(data, progress); err != nil {
+		glog.V(logger.Error).Errorf("Invalid fast sync progress: %v", err)
+		return nil
+	}
+	return progress
+}
+
+// writeSyncProgress persists the pivot and state root of the fast sync.
+func (d *Downloader) writeSyncProgress(pivot uint64, root common.Hash) {
+	data, err := rlp.EncodeToBytes(&syncProgress{Pivot: pivot, Root: root})
+	if err != nil {
+		glog.Fatalf("failed to encode fast sync progress: %v", err)
+	}
+	if err := d.stateDB.Put(fastSyncProgressKey, data); err != nil {
+		glog.Fatalf("failed to store fast sync progress: %v", err)
+	}
+}
+
+// finishSyncProgress drops the fast sync progress and the state bloom once the
+// pivot state is complete.
+func (d *Downloader) finishSyncProgress() {
+	if err := d.stateDB.Delete(fastSyncProgressKey); err != nil {
+		glog.V(logger.Error).Errorf("Failed to delete fast sync progress: %v", err)
+	}
+	d.stateBloomLock.Lock()
+	d.stateBloom, d.stateBloomInit = nil, true
+	d.stateBloomLock.Unlock()
+}
+
+// syncBloom returns the bloom filter of the state nodes downloaded by fast
+// sync, setting it up on first use. A new sync starts with an empty filter and
+// a resumed one with the filter saved at shutdown. After an unclean shutdown
+// the saved filter misses the nodes written since, so there is none and every
+// node is looked up in the database.
+func (d *Downloader) syncBloom() *trie.SyncBloom {
+	d.stateBloomLock.Lock()
+	defer d.stateBloomLock.Unlock()
+
+	if d.stateBloomInit {
+		return d.stateBloom
+	}
+	d.stateBloomInit = true
+
+	if data, _ := d.stateDB.Get(fastSyncBloomKey); len(data) > 0 {
+		// Drop the saved filter, it's only valid until the next write
+		if err := d.stateDB.Delete(fastSyncBloomKey); err != nil {
+			glog.V(logger.Error).Errorf("Failed to delete fast sync state bloom: %v", err)
+			return nil
+		}
+		d.stateBloom = trie.LoadSyncBloom(data)
+	} else if d.readSyncProgress() == nil {
+		d.stateBloom = trie.NewSyncBloom(stateBloomSize)
+	}
+	return d.stateBloom
+}
+
+// saveStateBloom persists the state bloom of an unfinished fast sync, so it can
+// be resumed after a restart.
+func (d *Downloader) saveStateBloom() {
+	d.stateBloomLock.Lock()
+	defer d.stateBloomLock.Unlock()
+
+	if d.stateBloom == nil || d.readSyncProgress() == nil {
+		return
+	}
+	if err := d.stateDB.Put(fastSyncBloomKey, d.stateBloom.Bytes()); err != nil {
+		glog.V(logger.Error).Errorf("Failed to store fast sync state bloom: %v", err)
+	}
+}
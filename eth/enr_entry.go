@@ -0,0 +1,69 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/forkid"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+// ethEntry is the "eth" entry of the node record, which advertises the eth
+// protocol and the fork ID (EIP-2124) of the local chain on the discovery
+// network.
+type ethEntry struct {
+	ForkID forkid.ID // Fork identifier per EIP-2124
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ethEntry) ENRKey() string {
+	return "eth"
+}
+
+// currentEthEntry constructs the "eth" node record entry for the current
+// chain head.
+func (pm *ProtocolManager) currentEthEntry() *ethEntry {
+	return &ethEntry{ForkID: forkid.NewID(pm.chainConfig, pm.blockchain.Genesis().Hash(), pm.blockchain.CurrentHeader().Number.Uint64())}
+}
+
+// startENRUpdater updates the "eth" node record entry whenever the fork ID
+// changes with a new chain head. It runs until the subscription is closed.
+func (s *Ethereum) startENRUpdater(srvr *p2p.Server) {
+	s.enrSub = s.eventMux.Subscribe(core.ChainHeadEvent{})
+
+	go func(sub <-chan *event.Event, current *ethEntry) {
+		for ev := range sub {
+			head := ev.Data.(core.ChainHeadEvent).Block
+			next := &ethEntry{ForkID: forkid.NewID(s.chainConfig, s.blockchain.Genesis().Hash(), head.NumberU64())}
+			if next.ForkID == current.ForkID {
+				continue
+			}
+			if err := srvr.SetNodeRecordEntry(next); err != nil {
+				glog.V(logger.Warn).Infof("Failed to update node record fork ID: %v", err)
+				continue
+			}
+			current = next
+		}
+	}(s.enrSub.Chan(), s.protocolManager.currentEthEntry())
+}
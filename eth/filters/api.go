@@ -53,6 +53,7 @@ type PublicFilterAPI struct {
 
 	quit    chan struct{}
 	chainDb ethdb.Database
+	index   BloomIndex
 
 	filterManager *FilterSystem
 
@@ -67,13 +68,18 @@ type PublicFilterAPI struct {
 
 	transactionMu    sync.RWMutex
 	transactionQueue map[int]*hashQueue
+
+	subs *PublicSubscriptionAPI // Serves the deprecated logs subscription
 }
 
-// NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(chainDb ethdb.Database, mux *event.TypeMux) *PublicFilterAPI {
+// NewPublicFilterAPI returns a new PublicFilterAPI instance. Log searches use
+// the bloom bits index if one is given.
+func NewPublicFilterAPI(chainDb ethdb.Database, mux *event.TypeMux, index BloomIndex, subs *PublicSubscriptionAPI) *PublicFilterAPI {
 	svc := &PublicFilterAPI{
 		mux:              mux,
 		chainDb:          chainDb,
+		index:            index,
+		subs:             subs,
 		filterManager:    NewFilterSystem(mux),
 		filterMapping:    make(map[string]int),
 		logQueue:         make(map[int]*logQueue),
@@ -207,12 +213,13 @@ func (s *PublicFilterAPI) NewPendingTransactionFilter() (string, error) {
 }
 
 // newLogFilter creates a new log filter.
-func (s *PublicFilterAPI) newLogFilter(earliest, latest int64, addresses []common.Address, topics [][]common.Hash, callback func(log *vm.Log, removed bool)) (int, error) {
+func (s *PublicFilterAPI) newLogFilter(earliest, latest int64, addresses []common.Address, topics [][]common.Hash) (int, error) {
 	// protect filterManager.Add() and setting of filter fields
 	s.filterManager.Lock()
 	defer s.filterManager.Unlock()
 
 	filter := New(s.chainDb)
+	filter.SetBloomIndex(s.index)
 	id, err := s.filterManager.Add(filter, LogFilter)
 	if err != nil {
 		return 0, err
@@ -227,14 +234,10 @@ func (s *PublicFilterAPI) newLogFilter(earliest, latest int64, addresses []commo
 	filter.SetAddresses(addresses)
 	filter.SetTopics(topics)
 	filter.LogCallback = func(log *vm.Log, removed bool) {
-		if callback != nil {
-			callback(log, removed)
-		} else {
-			s.logMu.Lock()
-			defer s.logMu.Unlock()
-			if queue := s.logQueue[id]; queue != nil {
-				queue.add(vmlog{log, removed})
-			}
+		s.logMu.Lock()
+		defer s.logMu.Unlock()
+		if queue := s.logQueue[id]; queue != nil {
+			queue.add(vmlog{log, removed})
 		}
 	}
 
@@ -242,54 +245,11 @@ func (s *PublicFilterAPI) newLogFilter(earliest, latest int64, addresses []commo
 }
 
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
+//
+// Deprecated: use the logs subscription of PublicSubscriptionAPI, which this calls. It sends each log in a notification
+// of its own, rather than in a list.
 func (s *PublicFilterAPI) Logs(ctx context.Context, args NewFilterArgs) (rpc.Subscription, error) {
-	notifier, supported := rpc.NotifierFromContext(ctx)
-	if !supported {
-		return nil, rpc.ErrNotificationsUnsupported
-	}
-
-	var (
-		externalId   string
-		subscription rpc.Subscription
-		err          error
-	)
-
-	if externalId, err = newFilterId(); err != nil {
-		return nil, err
-	}
-
-	// uninstall filter when subscription is unsubscribed/cancelled
-	if subscription, err = notifier.NewSubscription(func(string) {
-		s.UninstallFilter(externalId)
-	}); err != nil {
-		return nil, err
-	}
-
-	notifySubscriber := func(log *vm.Log, removed bool) {
-		rpcLog := toRPCLogs(vm.Logs{log}, removed)
-		if err := subscription.Notify(rpcLog); err != nil {
-			subscription.Cancel()
-		}
-	}
-
-	// from and to block number are not used since subscriptions don't allow you to travel to "time"
-	var id int
-	if len(args.Addresses) > 0 {
-		id, err = s.newLogFilter(-1, -1, args.Addresses, args.Topics, notifySubscriber)
-	} else {
-		id, err = s.newLogFilter(-1, -1, nil, args.Topics, notifySubscriber)
-	}
-
-	if err != nil {
-		subscription.Cancel()
-		return nil, err
-	}
-
-	s.filterMapMu.Lock()
-	s.filterMapping[externalId] = id
-	s.filterMapMu.Unlock()
-
-	return subscription, err
+	return s.subs.Logs(ctx, args)
 }
 
 // NewFilterArgs represents a request to create a new filter.
@@ -423,9 +383,9 @@ func (s *PublicFilterAPI) NewFilter(args NewFilterArgs) (string, error) {
 
 	var id int
 	if len(args.Addresses) > 0 {
-		id, err = s.newLogFilter(args.FromBlock.Int64(), args.ToBlock.Int64(), args.Addresses, args.Topics, nil)
+		id, err = s.newLogFilter(args.FromBlock.Int64(), args.ToBlock.Int64(), args.Addresses, args.Topics)
 	} else {
-		id, err = s.newLogFilter(args.FromBlock.Int64(), args.ToBlock.Int64(), nil, args.Topics, nil)
+		id, err = s.newLogFilter(args.FromBlock.Int64(), args.ToBlock.Int64(), nil, args.Topics)
 	}
 	if err != nil {
 		return "", err
@@ -441,6 +401,7 @@ func (s *PublicFilterAPI) NewFilter(args NewFilterArgs) (string, error) {
 // GetLogs returns the logs matching the given argument.
 func (s *PublicFilterAPI) GetLogs(args NewFilterArgs) []vmlog {
 	filter := New(s.chainDb)
+	filter.SetBloomIndex(s.index)
 	filter.SetBeginBlock(args.FromBlock.Int64())
 	filter.SetEndBlock(args.ToBlock.Int64())
 	filter.SetAddresses(args.Addresses)
@@ -583,6 +544,27 @@ type vmlog struct {
 	Removed bool `json:"removed"`
 }
 
+// MarshalJSON adds the removed flag to the encoding of the log, as the promoted
+// (*vm.Log).MarshalJSON would otherwise leave it out.
+// MarshalJSON encodes the fields of the log, see vm.Log.MarshalJSON, together
+// with its removed flag. A missing log is encoded as null.
+func (l vmlog) MarshalJSON() ([]byte, error) {
+	if l.Log == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(map[string]interface{}{
+		"address":          l.Address,
+		"data":             fmt.Sprintf("%#x", l.Data),
+		"blockNumber":      fmt.Sprintf("%#x", l.BlockNumber),
+		"logIndex":         fmt.Sprintf("%#x", l.Index),
+		"blockHash":        l.BlockHash,
+		"transactionHash":  l.TxHash,
+		"transactionIndex": fmt.Sprintf("%#x", l.TxIndex),
+		"topics":           l.Topics,
+		"removed":          l.Removed,
+	})
+}
+
 type logQueue struct {
 	mu sync.Mutex
 
@@ -17,16 +17,35 @@
 package filters
 
 import (
+	"context"
+	"fmt"
 	"math"
+	"math/big"
 	"time"
 
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/bloombits"
 	"github.com/ethereumproject/go-ethereum/core/types"
 	"github.com/ethereumproject/go-ethereum/core/vm"
 	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
 )
 
+// BloomIndex is implemented by services maintaining a sectioned index of the
+// header bloom bits, which lets a filter skip the blocks that can't contain
+// any matching log without looking at their blooms one by one.
+type BloomIndex interface {
+	// BloomStatus returns the number of blocks in a section and the number
+	// of sections indexed so far.
+	BloomStatus() (uint64, uint64)
+
+	// ServiceFilter starts serving the bloom bit retrievals of a matcher
+	// session until the session is closed.
+	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+}
+
 type AccountChange struct {
 	Address, StateAddress []byte
 }
@@ -36,6 +55,7 @@ type Filter struct {
 	created time.Time
 
 	db         ethdb.Database
+	index      BloomIndex
 	begin, end int64
 	addresses  []common.Address
 	topics     [][]common.Hash
@@ -51,6 +71,35 @@ func New(db ethdb.Database) *Filter {
 	return &Filter{db: db}
 }
 
+// NewQueryFilter creates a filter over db from the criteria of a contract
+// binding filter query. A nil begin block means the genesis block, a nil end
+// block the latest one. An empty set of topic alternatives matches any topic,
+// so it's replaced by the zero hash wildcard.
+func NewQueryFilter(db ethdb.Database, begin, end *big.Int, addresses []common.Address, topics [][]common.Hash) *Filter {
+	filter := New(db)
+
+	filter.SetBeginBlock(0)
+	if begin != nil {
+		filter.SetBeginBlock(begin.Int64())
+	}
+	filter.SetEndBlock(-1)
+	if end != nil {
+		filter.SetEndBlock(end.Int64())
+	}
+	filter.SetAddresses(addresses)
+
+	wildcards := make([][]common.Hash, len(topics))
+	for i, alternatives := range topics {
+		if len(alternatives) == 0 {
+			alternatives = []common.Hash{{}}
+		}
+		wildcards[i] = alternatives
+	}
+	filter.SetTopics(wildcards)
+
+	return filter
+}
+
 // Set the earliest and latest block for filtering.
 // -1 = latest block (i.e., the current block)
 // hash = particular hash from-to
@@ -70,6 +119,12 @@ func (self *Filter) SetTopics(topics [][]common.Hash) {
 	self.topics = topics
 }
 
+// SetBloomIndex sets the bloom bits index used to find the candidate blocks
+// of the indexed part of the chain.
+func (self *Filter) SetBloomIndex(index BloomIndex) {
+	self.index = index
+}
+
 // Run filters logs with the current parameters set
 func (self *Filter) Find() vm.Logs {
 	latestBlock := core.GetBlock(self.db, core.GetHeadBlockHash(self.db))
@@ -85,6 +140,88 @@ func (self *Filter) Find() vm.Logs {
 		endBlockNo = latestBlock.NumberU64()
 	}
 
+	// search the indexed sections through the bloom bits index, and only
+	// fall back to the blocks' own blooms for the rest of the range
+	if self.index != nil && beginBlockNo <= endBlockNo {
+		size, sections := self.index.BloomStatus()
+		if indexed := size * sections; indexed > beginBlockNo {
+			last := endBlockNo
+			if indexed-1 < last {
+				last = indexed - 1
+			}
+			logs, err := self.indexedLogs(beginBlockNo, last)
+			if err == nil {
+				if last == endBlockNo {
+					return logs
+				}
+				return append(logs, self.unindexedLogs(last+1, endBlockNo)...)
+			}
+			glog.V(logger.Warn).Infof("Bloom bits index lookup failed, falling back to block blooms: %v", err)
+		}
+	}
+	return self.unindexedLogs(beginBlockNo, endBlockNo)
+}
+
+// indexedLogs returns the logs matching the filter criteria between the two
+// blocks, using the bloom bits index to find the candidate blocks.
+func (self *Filter) indexedLogs(start, end uint64) (vm.Logs, error) {
+	size, _ := self.index.BloomStatus()
+	matcher := bloombits.NewMatcher(size, self.bloomFilters())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	matches := make(chan uint64, 64)
+	session, err := matcher.Start(ctx, start, end, matches)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	self.index.ServiceFilter(ctx, session)
+
+	var logs vm.Logs
+	for number := range matches {
+		hash := core.GetCanonicalHash(self.db, number)
+		if hash == (common.Hash{}) {
+			return nil, fmt.Errorf("missing canonical hash for block #%d", number)
+		}
+		block := core.GetBlock(self.db, hash)
+		if block == nil {
+			return nil, fmt.Errorf("missing block #%d [%x]", number, hash[:4])
+		}
+		logs = append(logs, self.blockLogs(block)...)
+	}
+	return logs, session.Error()
+}
+
+// bloomFilters returns the address and topic sets of the filter in the form
+// taken by the bloom bits matcher. The zero hash topic wildcard becomes a nil
+// clause, which lets the matcher skip the whole set.
+func (self *Filter) bloomFilters() [][][]byte {
+	var filters [][][]byte
+	if len(self.addresses) > 0 {
+		filter := make([][]byte, len(self.addresses))
+		for i, address := range self.addresses {
+			filter[i] = address.Bytes()
+		}
+		filters = append(filters, filter)
+	}
+	for _, topics := range self.topics {
+		filter := make([][]byte, len(topics))
+		for i, topic := range topics {
+			if topic != (common.Hash{}) {
+				filter[i] = topic.Bytes()
+			}
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// unindexedLogs returns the logs matching the filter criteria between the two
+// blocks, checking the MIP mapped blooms and the blooms of the blocks.
+func (self *Filter) unindexedLogs(beginBlockNo, endBlockNo uint64) vm.Logs {
 	// if no addresses are present we can't make use of fast search which
 	// uses the mipmap bloom filters to check for fast inclusion and uses
 	// higher range probability in order to ensure at least a false positive
@@ -135,24 +272,30 @@ func (self *Filter) getLogs(start, end uint64) (logs vm.Logs) {
 			return logs
 		}
 
-		// Use bloom filtering to see if this block is interesting given the
-		// current parameters
-		if self.bloomFilter(block) {
-			// Get the logs of the block
-			var (
-				receipts   = core.GetBlockReceipts(self.db, block.Hash())
-				unfiltered vm.Logs
-			)
-			for _, receipt := range receipts {
-				unfiltered = append(unfiltered, receipt.Logs...)
-			}
-			logs = append(logs, self.FilterLogs(unfiltered)...)
-		}
+		logs = append(logs, self.blockLogs(block)...)
 	}
 
 	return logs
 }
 
+// blockLogs returns the logs of the block matching the filter criteria.
+func (self *Filter) blockLogs(block *types.Block) vm.Logs {
+	// Use bloom filtering to see if this block is interesting given the
+	// current parameters
+	if !self.bloomFilter(block) {
+		return nil
+	}
+	// Get the logs of the block
+	var (
+		receipts   = core.GetBlockReceipts(self.db, block.Hash())
+		unfiltered vm.Logs
+	)
+	for _, receipt := range receipts {
+		unfiltered = append(unfiltered, receipt.Logs...)
+	}
+	return self.FilterLogs(unfiltered)
+}
+
 func includes(addresses []common.Address, a common.Address) bool {
 	for _, addr := range addresses {
 		if addr == a {
@@ -205,10 +348,16 @@ Logs:
 }
 
 func (self *Filter) bloomFilter(block *types.Block) bool {
+	return self.MatchBloom(block.Bloom())
+}
+
+// MatchBloom reports whether a block with the given logs bloom may hold logs
+// matching the addresses and topics of the filter.
+func (self *Filter) MatchBloom(bloom types.Bloom) bool {
 	if len(self.addresses) > 0 {
 		var included bool
 		for _, addr := range self.addresses {
-			if types.BloomLookup(block.Bloom(), addr[:]) {
+			if types.BloomLookup(bloom, addr[:]) {
 				included = true
 				break
 			}
@@ -222,7 +371,7 @@ func (self *Filter) bloomFilter(block *types.Block) bool {
 	for _, sub := range self.topics {
 		var included bool
 		for _, topic := range sub {
-			if (topic == common.Hash{}) || types.BloomLookup(block.Bloom(), topic[:]) {
+			if (topic == common.Hash{}) || types.BloomLookup(bloom, topic[:]) {
 				included = true
 				break
 			}
@@ -17,13 +17,16 @@
 package filters
 
 import (
+	"context"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"sync/atomic"
 	"testing"
 
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/bloombits"
 	"github.com/ethereumproject/go-ethereum/core/types"
 	"github.com/ethereumproject/go-ethereum/core/vm"
 	"github.com/ethereumproject/go-ethereum/crypto"
@@ -286,3 +289,171 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// testBloomIndex is an in-memory bloom bits index over the first sections of
+// a chain.
+type testBloomIndex struct {
+	size     uint64
+	bitsets  [][types.BloomBitLength][]byte // Rotated bloom bits per section
+	requests chan chan *bloombits.Retrieval
+	served   uint32 // Number of sections served to matchers
+}
+
+func newTestBloomIndex(t *testing.T, chain []*types.Block, size uint64, sections int) *testBloomIndex {
+	index := &testBloomIndex{size: size, requests: make(chan chan *bloombits.Retrieval)}
+	for section := 0; section < sections; section++ {
+		gen, err := bloombits.NewGenerator(uint(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint64(0); i < size; i++ {
+			var bloom types.Bloom
+			// the genesis block isn't part of the generated chain
+			if number := uint64(section)*size + i; number > 0 {
+				bloom = chain[number-1].Bloom()
+			}
+			if err := gen.AddBloom(uint(i), bloom); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var bitsets [types.BloomBitLength][]byte
+		for bit := range bitsets {
+			bitsets[bit], _ = gen.Bitset(uint(bit))
+		}
+		index.bitsets = append(index.bitsets, bitsets)
+	}
+	go func() {
+		for request := range index.requests {
+			task := <-request
+			task.Bitsets = make([][]byte, len(task.Sections))
+			for i, section := range task.Sections {
+				task.Bitsets[i] = index.bitsets[section][task.Bit]
+				atomic.AddUint32(&index.served, 1)
+			}
+			request <- task
+		}
+	}()
+	return index
+}
+
+func (index *testBloomIndex) BloomStatus() (uint64, uint64) {
+	return index.size, uint64(len(index.bitsets))
+}
+
+func (index *testBloomIndex) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
+	go session.Multiplex(16, 0, index.requests)
+}
+
+func TestIndexedFilters(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key1.PublicKey)
+
+		hash1 = common.BytesToHash([]byte("topic1"))
+		hash2 = common.BytesToHash([]byte("topic2"))
+		hash3 = common.BytesToHash([]byte("topic3"))
+		hash4 = common.BytesToHash([]byte("topic4"))
+	)
+	// logs of blocks 2, 3 and 700 are within the indexed sections, 1000 isn't
+	topics := map[int]common.Hash{1: hash1, 2: hash2, 699: hash3, 999: hash4}
+
+	genesis := core.WriteGenesisBlockForTesting(db, core.GenesisAccount{Address: addr, Balance: big.NewInt(1000000)})
+	chain, receipts := core.GenerateChain(core.DefaultConfigMorden.ChainConfig, genesis, db, 1000, func(i int, gen *core.BlockGen) {
+		var receipts types.Receipts
+		if topic, ok := topics[i]; ok {
+			receipt := types.NewReceipt(nil, new(big.Int))
+			receipt.Logs = vm.Logs{&vm.Log{Address: addr, Topics: []common.Hash{topic}}}
+			gen.AddUncheckedReceipt(receipt)
+			receipts = types.Receipts{receipt}
+		}
+		core.WriteMipmapBloom(db, uint64(i+1), receipts)
+	})
+	for i, block := range chain {
+		core.WriteBlock(db, block)
+		if err := core.WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteHeadBlockHash(db, block.Hash()); err != nil {
+			t.Fatalf("failed to insert block number: %v", err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), receipts[i]); err != nil {
+			t.Fatal("error writing block receipts:", err)
+		}
+	}
+	index := newTestBloomIndex(t, chain, 256, 3)
+	defer close(index.requests)
+
+	tests := []struct {
+		addresses  []common.Address
+		topics     [][]common.Hash
+		begin, end int64
+		want       []common.Hash
+	}{
+		{[]common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}}, 0, -1, []common.Hash{hash1, hash2, hash3, hash4}},
+		{[]common.Address{addr}, [][]common.Hash{{common.Hash{}}}, 0, -1, []common.Hash{hash1, hash2, hash3, hash4}},
+		{nil, [][]common.Hash{{hash3}}, 600, 900, []common.Hash{hash3}},
+		{nil, [][]common.Hash{{hash1, hash2}}, 1, 10, []common.Hash{hash1, hash2}},
+		{nil, [][]common.Hash{{hash4}}, 0, -1, []common.Hash{hash4}},
+		{nil, [][]common.Hash{{common.BytesToHash([]byte("fail"))}}, 0, -1, nil},
+		{[]common.Address{common.BytesToAddress([]byte("failmenow"))}, nil, 0, -1, nil},
+	}
+	for i, tt := range tests {
+		filter := New(db)
+		filter.SetBloomIndex(index)
+		filter.SetAddresses(tt.addresses)
+		filter.SetTopics(tt.topics)
+		filter.SetBeginBlock(tt.begin)
+		filter.SetEndBlock(tt.end)
+
+		logs := filter.Find()
+		if len(logs) != len(tt.want) {
+			t.Errorf("test %d: expected %d logs, got %d", i, len(tt.want), len(logs))
+			continue
+		}
+		for j, log := range logs {
+			if log.Topics[0] != tt.want[j] {
+				t.Errorf("test %d: expected log[%d].Topics[0] to be %x, got %x", i, j, tt.want[j], log.Topics[0])
+			}
+		}
+	}
+	if atomic.LoadUint32(&index.served) == 0 {
+		t.Error("expected the bloom bits index to be used")
+	}
+	// A matching block missing from the database must fail the indexed search
+	// instead of silently truncating its results
+	core.DeleteBlock(db, chain[1].Hash())
+
+	filter := New(db)
+	filter.SetBloomIndex(index)
+	filter.SetTopics([][]common.Hash{{hash1, hash2}})
+	if logs, err := filter.indexedLogs(1, 10); err == nil {
+		t.Errorf("expected missing block error, got %d logs", len(logs))
+	}
+}
+
+func TestNewQueryFilter(t *testing.T) {
+	var (
+		addr   = common.Address{1}
+		topic1 = common.Hash{1}
+		topic2 = common.Hash{2}
+	)
+	filter := NewQueryFilter(nil, nil, nil, []common.Address{addr}, [][]common.Hash{nil, {topic2}})
+	if filter.begin != 0 || filter.end != -1 {
+		t.Errorf("range mismatch: have %d..%d, want 0..-1", filter.begin, filter.end)
+	}
+	logs := vm.Logs{
+		{Address: addr, Topics: []common.Hash{topic1, topic2}},
+		{Address: addr, Topics: []common.Hash{topic2, topic1}},
+		{Address: common.Address{2}, Topics: []common.Hash{topic1, topic2}},
+	}
+	// The empty first position matches any topic
+	if matched := filter.FilterLogs(logs); len(matched) != 1 || matched[0] != logs[0] {
+		t.Errorf("matched logs mismatch: have %v, want the first log", matched)
+	}
+	filter = NewQueryFilter(nil, big.NewInt(5), big.NewInt(10), nil, nil)
+	if filter.begin != 5 || filter.end != 10 {
+		t.Errorf("range mismatch: have %d..%d, want 5..10", filter.begin, filter.end)
+	}
+}
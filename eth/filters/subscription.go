@@ -0,0 +1,279 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/eth/downloader"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+// subscriptionBuffer is the number of notifications queued for a subscription.
+// Subscribers falling further behind are dropped, rather than holding up the
+// event mux and with it the chain insertion posting the events.
+const subscriptionBuffer = 256
+
+// SyncProgress is implemented by the downloaders reporting the progress of the
+// chain synchronisation, see downloader.Downloader.Progress.
+type SyncProgress interface {
+	Progress() (origin uint64, current uint64, height uint64, pulled uint64, known uint64)
+}
+
+// PublicSubscriptionAPI offers the eth_subscribe subscriptions to new chain
+// heads, logs, pending transactions and the synchronisation status.
+type PublicSubscriptionAPI struct {
+	progress SyncProgress
+	sub      event.TypeMuxSubscription
+
+	mu          sync.Mutex
+	subscribers map[string]*subscriber
+}
+
+// subscriber queues the notifications of a subscription, and sends them to the
+// client from its own goroutine.
+type subscriber struct {
+	sub    rpc.Subscription
+	notify func(ev interface{}) []interface{} // Returns the notifications of an event
+	queue  chan interface{}
+	quit   chan struct{} // Closed when the subscriber is removed
+}
+
+// NewPublicSubscriptionAPI returns a new PublicSubscriptionAPI instance,
+// reporting the synchronisation progress of the given downloader.
+func NewPublicSubscriptionAPI(mux *event.TypeMux, progress SyncProgress) *PublicSubscriptionAPI {
+	api := &PublicSubscriptionAPI{
+		progress:    progress,
+		subscribers: make(map[string]*subscriber),
+	}
+	api.sub = mux.Subscribe(
+		core.ChainEvent{},
+		core.TxPreEvent{},
+		vm.Logs(nil),
+		core.RemovedLogsEvent{},
+		downloader.StartEvent{},
+		downloader.DoneEvent{},
+		downloader.FailedEvent{},
+	)
+	go api.loop()
+	return api
+}
+
+// Stop stops delivering events and ends all subscriptions.
+func (api *PublicSubscriptionAPI) Stop() {
+	api.sub.Unsubscribe()
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	for id, s := range api.subscribers {
+		delete(api.subscribers, id)
+		close(s.quit)
+		go s.sub.Cancel()
+	}
+}
+
+// loop queues the notifications of the mux events for the subscribers. It never
+// blocks on a subscriber: those whose queue is full are dropped.
+func (api *PublicSubscriptionAPI) loop() {
+	for ev := range api.sub.Chan() {
+		api.mu.Lock()
+		for id, s := range api.subscribers {
+		queue:
+			for _, n := range s.notify(ev.Data) {
+				select {
+				case s.queue <- n:
+				default:
+					glog.V(logger.Warn).Infof("Dropping subscription %s: too many pending notifications", id)
+					delete(api.subscribers, id)
+					close(s.quit)
+					go s.sub.Cancel()
+					break queue
+				}
+			}
+		}
+		api.mu.Unlock()
+	}
+}
+
+// subscribe creates a subscription notified of the notifications the given
+// function returns for each event.
+func (api *PublicSubscriptionAPI) subscribe(ctx context.Context, notify func(ev interface{}) []interface{}) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub, err := notifier.NewSubscription(api.remove)
+	if err != nil {
+		return nil, err
+	}
+	s := &subscriber{
+		sub:    sub,
+		notify: notify,
+		queue:  make(chan interface{}, subscriptionBuffer),
+		quit:   make(chan struct{}),
+	}
+	api.mu.Lock()
+	api.subscribers[sub.ID()] = s
+	api.mu.Unlock()
+
+	go api.forward(s)
+	return sub, nil
+}
+
+// remove removes a subscriber, when it's unsubscribed or its connection closed.
+func (api *PublicSubscriptionAPI) remove(id string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if s, ok := api.subscribers[id]; ok {
+		delete(api.subscribers, id)
+		close(s.quit)
+	}
+}
+
+// forward sends the queued notifications of a subscriber until it's removed.
+func (api *PublicSubscriptionAPI) forward(s *subscriber) {
+	for {
+		select {
+		case n := <-s.queue:
+			if err := s.sub.Notify(n); err != nil {
+				api.remove(s.sub.ID())
+				return
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// NewHeads sends a notification each time a new header is appended to the
+// canonical chain. On reorganisations, every block of the new chain is sent,
+// in ascending order.
+func (api *PublicSubscriptionAPI) NewHeads(ctx context.Context) (rpc.Subscription, error) {
+	return api.subscribe(ctx, func(ev interface{}) []interface{} {
+		if ev, ok := ev.(core.ChainEvent); ok {
+			return []interface{}{rpcMarshalHeader(ev.Block.Header())}
+		}
+		return nil
+	})
+}
+
+// Logs sends a notification for each new log matching the addresses and topics
+// of the filter criteria; the block range is ignored. Logs of blocks dropped
+// from the canonical chain by a reorganisation are sent again, with their
+// removed flag set, followed by the logs of the new chain.
+func (api *PublicSubscriptionAPI) Logs(ctx context.Context, args NewFilterArgs) (rpc.Subscription, error) {
+	filter := New(nil)
+	filter.SetAddresses(args.Addresses)
+	filter.SetTopics(args.Topics)
+
+	return api.subscribe(ctx, func(ev interface{}) []interface{} {
+		var (
+			logs    vm.Logs
+			removed bool
+		)
+		switch ev := ev.(type) {
+		case vm.Logs:
+			logs = ev
+		case core.RemovedLogsEvent:
+			logs, removed = ev.Logs, true
+		}
+		var notifications []interface{}
+		for _, log := range filter.FilterLogs(logs) {
+			notifications = append(notifications, vmlog{log, removed})
+		}
+		return notifications
+	})
+}
+
+// NewPendingTransactions sends a notification with the hash of each transaction
+// entering the transaction pool.
+func (api *PublicSubscriptionAPI) NewPendingTransactions(ctx context.Context) (rpc.Subscription, error) {
+	return api.subscribe(ctx, func(ev interface{}) []interface{} {
+		if ev, ok := ev.(core.TxPreEvent); ok {
+			return []interface{}{ev.Tx.Hash()}
+		}
+		return nil
+	})
+}
+
+// Syncing sends a notification with the synchronisation progress when the node
+// starts synchronising with the network, and false when it's finished.
+func (api *PublicSubscriptionAPI) Syncing(ctx context.Context) (rpc.Subscription, error) {
+	return api.subscribe(ctx, func(ev interface{}) []interface{} {
+		switch ev.(type) {
+		case downloader.StartEvent:
+			result := &downloader.SyncingResult{Syncing: true}
+			result.Status.Origin, result.Status.Current, result.Status.Height, result.Status.Pulled, result.Status.Known = api.progress.Progress()
+			return []interface{}{result}
+		case downloader.DoneEvent, downloader.FailedEvent:
+			return []interface{}{false}
+		}
+		return nil
+	})
+}
+
+// SubscribeBlocks creates a subscription of api sending the representation of
+// each block appended to the canonical chain, as returned by format. Blocks
+// failing to format are skipped. It serves the deprecated newBlocks
+// subscription of the eth API, which formats blocks as its other methods do.
+func SubscribeBlocks(ctx context.Context, api *PublicSubscriptionAPI, format func(*types.Block) (interface{}, error)) (rpc.Subscription, error) {
+	return api.subscribe(ctx, func(ev interface{}) []interface{} {
+		chainEvent, ok := ev.(core.ChainEvent)
+		if !ok {
+			return nil
+		}
+		block, err := format(chainEvent.Block)
+		if err != nil {
+			glog.V(logger.Warn).Infof("Unable to format block: %v", err)
+			return nil
+		}
+		return []interface{}{block}
+	})
+}
+
+// rpcMarshalHeader converts a header into the RPC representation of the blocks,
+// without the fields of the body.
+func rpcMarshalHeader(head *types.Header) map[string]interface{} {
+	return map[string]interface{}{
+		"number":           rpc.NewHexNumber(head.Number),
+		"hash":             head.Hash(),
+		"parentHash":       head.ParentHash,
+		"nonce":            head.Nonce,
+		"mixHash":          head.MixDigest,
+		"sha3Uncles":       head.UncleHash,
+		"logsBloom":        head.Bloom,
+		"stateRoot":        head.Root,
+		"miner":            head.Coinbase,
+		"difficulty":       rpc.NewHexNumber(head.Difficulty),
+		"extraData":        fmt.Sprintf("0x%x", head.Extra),
+		"gasLimit":         rpc.NewHexNumber(head.GasLimit),
+		"gasUsed":          rpc.NewHexNumber(head.GasUsed),
+		"timestamp":        rpc.NewHexNumber(head.Time),
+		"transactionsRoot": head.TxHash,
+		"receiptsRoot":     head.ReceiptHash,
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/eth/downloader"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+type testProgress struct{}
+
+func (testProgress) Progress() (uint64, uint64, uint64, uint64, uint64) {
+	return 1, 2, 3, 4, 5
+}
+
+// newTestSubscriptionAPI serves a subscription API in process.
+func newTestSubscriptionAPI(t *testing.T) (*event.TypeMux, *rpc.ClientConn, func()) {
+	mux := new(event.TypeMux)
+	api := NewPublicSubscriptionAPI(mux, testProgress{})
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	return mux, client, func() {
+		client.Close()
+		server.Stop()
+		api.Stop()
+	}
+}
+
+// receive waits for a notification of a subscription.
+func receive(t *testing.T, sub *rpc.ClientSubscription, ch <-chan json.RawMessage) json.RawMessage {
+	select {
+	case msg := <-ch:
+		return msg
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a notification")
+	}
+	return nil
+}
+
+func TestSubscribeNewHeads(t *testing.T) {
+	mux, client, stop := newTestSubscriptionAPI(t)
+	defer stop()
+
+	ch := make(chan json.RawMessage)
+	sub, err := client.Subscribe(context.Background(), ch, "newHeads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	block := types.NewBlockWithHeader(&types.Header{
+		Number:     big.NewInt(10),
+		Difficulty: big.NewInt(131072),
+		GasLimit:   big.NewInt(5000),
+		GasUsed:    big.NewInt(0),
+		Time:       big.NewInt(1),
+	})
+	mux.Post(core.ChainEvent{Block: block, Hash: block.Hash()})
+
+	var head struct {
+		Hash   common.Hash    `json:"hash"`
+		Number *rpc.HexNumber `json:"number"`
+	}
+	if err := json.Unmarshal(receive(t, sub, ch), &head); err != nil {
+		t.Fatal(err)
+	}
+	if head.Hash != block.Hash() || head.Number.Int() != 10 {
+		t.Errorf("head mismatch: got %x #%d, want %x #10", head.Hash, head.Number.Int(), block.Hash())
+	}
+}
+
+func TestSubscribeLogs(t *testing.T) {
+	mux, client, stop := newTestSubscriptionAPI(t)
+	defer stop()
+
+	var (
+		addr  = common.Address{0x01}
+		topic = common.Hash{0x02}
+	)
+	ch := make(chan json.RawMessage)
+	sub, err := client.Subscribe(context.Background(), ch, "logs", map[string]interface{}{
+		"address": addr,
+		"topics":  []interface{}{topic},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	match := &vm.Log{Address: addr, Topics: []common.Hash{topic}, BlockNumber: 7}
+	mux.Post(vm.Logs{
+		{Address: common.Address{0x03}, Topics: []common.Hash{topic}},
+		{Address: addr, Topics: []common.Hash{{0x04}}},
+		match,
+	})
+	mux.Post(core.RemovedLogsEvent{Logs: vm.Logs{match}})
+
+	for _, removed := range []bool{false, true} {
+		var log struct {
+			Address     common.Address `json:"address"`
+			BlockNumber string         `json:"blockNumber"`
+			Removed     bool           `json:"removed"`
+		}
+		if err := json.Unmarshal(receive(t, sub, ch), &log); err != nil {
+			t.Fatal(err)
+		}
+		if log.Address != addr || log.BlockNumber != "0x7" || log.Removed != removed {
+			t.Errorf("log mismatch: got %+v, want address %x, block 0x7, removed %v", log, addr, removed)
+		}
+	}
+}
+
+func TestSubscribePendingTransactions(t *testing.T) {
+	mux, client, stop := newTestSubscriptionAPI(t)
+	defer stop()
+
+	ch := make(chan common.Hash)
+	sub, err := client.Subscribe(context.Background(), ch, "newPendingTransactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	tx := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+	mux.Post(core.TxPreEvent{Tx: tx})
+
+	select {
+	case hash := <-ch:
+		if hash != tx.Hash() {
+			t.Errorf("hash mismatch: got %x, want %x", hash, tx.Hash())
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the transaction")
+	}
+}
+
+func TestSubscribeSyncing(t *testing.T) {
+	mux, client, stop := newTestSubscriptionAPI(t)
+	defer stop()
+
+	ch := make(chan json.RawMessage)
+	sub, err := client.Subscribe(context.Background(), ch, "syncing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	mux.Post(downloader.StartEvent{})
+	mux.Post(downloader.DoneEvent{})
+
+	var started downloader.SyncingResult
+	if err := json.Unmarshal(receive(t, sub, ch), &started); err != nil {
+		t.Fatal(err)
+	}
+	want := downloader.SyncingResult{Syncing: true, Status: downloader.Progress{Origin: 1, Current: 2, Height: 3, Pulled: 4, Known: 5}}
+	if started != want {
+		t.Errorf("start notification mismatch: got %+v, want %+v", started, want)
+	}
+	if done := string(receive(t, sub, ch)); done != "false" {
+		t.Errorf("done notification: got %s, want false", done)
+	}
+}
+
+// blockedSubscription is a subscription whose client never reads the
+// notifications.
+type blockedSubscription struct {
+	unblock  chan struct{}
+	once     sync.Once
+	canceled chan struct{}
+}
+
+func (s *blockedSubscription) Notify(data interface{}) error {
+	<-s.unblock
+	return nil
+}
+
+func (s *blockedSubscription) ID() string { return "0x1" }
+
+func (s *blockedSubscription) Cancel() error {
+	s.once.Do(func() { close(s.canceled) })
+	return nil
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	mux := new(event.TypeMux)
+	api := NewPublicSubscriptionAPI(mux, testProgress{})
+	defer api.Stop()
+
+	sub := &blockedSubscription{unblock: make(chan struct{}), canceled: make(chan struct{})}
+	defer close(sub.unblock)
+
+	s := &subscriber{
+		sub:    sub,
+		notify: func(ev interface{}) []interface{} { return []interface{}{ev} },
+		queue:  make(chan interface{}, subscriptionBuffer),
+		quit:   make(chan struct{}),
+	}
+	api.subscribers[sub.ID()] = s
+	go api.forward(s)
+
+	// Posting more events than the subscriber queues must not block the mux.
+	posted := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*subscriptionBuffer; i++ {
+			mux.Post(core.TxPreEvent{})
+		}
+		close(posted)
+	}()
+	select {
+	case <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event mux blocked by a slow subscriber")
+	}
+	select {
+	case <-sub.canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow subscription not canceled")
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.subscribers) != 0 {
+		t.Errorf("slow subscriber not removed")
+	}
+}
+
+func TestStopEndsSubscriptions(t *testing.T) {
+	mux := new(event.TypeMux)
+	api := NewPublicSubscriptionAPI(mux, testProgress{})
+
+	sub := &blockedSubscription{unblock: make(chan struct{}), canceled: make(chan struct{})}
+	defer close(sub.unblock)
+
+	s := &subscriber{
+		sub:    sub,
+		notify: func(ev interface{}) []interface{} { return []interface{}{ev} },
+		queue:  make(chan interface{}, subscriptionBuffer),
+		quit:   make(chan struct{}),
+	}
+	api.subscribers[sub.ID()] = s
+	go api.forward(s)
+
+	api.Stop()
+	select {
+	case <-sub.canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription not canceled on stop")
+	}
+	select {
+	case <-s.quit:
+	default:
+		t.Error("subscriber not quit on stop")
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.subscribers) != 0 {
+		t.Errorf("subscriber not removed on stop")
+	}
+}
+
+// BlockNumberAPI serves a newBlocks subscription formatting blocks by number.
+type BlockNumberAPI struct {
+	api *PublicSubscriptionAPI
+}
+
+func (s *BlockNumberAPI) NewBlocks(ctx context.Context) (rpc.Subscription, error) {
+	return SubscribeBlocks(ctx, s.api, func(b *types.Block) (interface{}, error) {
+		if b.NumberU64() == 0 {
+			return nil, errors.New("genesis")
+		}
+		return b.NumberU64(), nil
+	})
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	mux := new(event.TypeMux)
+	api := NewPublicSubscriptionAPI(mux, testProgress{})
+	defer api.Stop()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &BlockNumberAPI{api}); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	ch := make(chan json.RawMessage)
+	sub, err := client.Subscribe(context.Background(), ch, "newBlocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// Blocks failing to format are skipped
+	for _, n := range []int64{0, 7} {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(n)})
+		mux.Post(core.ChainEvent{Block: block, Hash: block.Hash()})
+	}
+	if msg := receive(t, sub, ch); string(msg) != "7" {
+		t.Errorf("notification mismatch: got %s, want 7", msg)
+	}
+}
+
+func TestMarshalLog(t *testing.T) {
+	log := &vm.Log{Address: common.Address{0x01}, Topics: []common.Hash{}, BlockNumber: 3}
+	enc, err := json.Marshal(vmlog{log, true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec map[string]interface{}
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatalf("invalid encoding %s: %v", enc, err)
+	}
+	if dec["removed"] != true || dec["blockNumber"] != "0x3" || dec["address"] != log.Address.Hex() {
+		t.Errorf("encoding mismatch: got %s", enc)
+	}
+	// A missing log doesn't break the enclosing encoding
+	if enc, err := json.Marshal([]vmlog{{nil, false}}); err != nil || string(enc) != "[null]" {
+		t.Errorf("nil log encoding mismatch: got %s, %v", enc, err)
+	}
+}
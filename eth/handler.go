@@ -28,6 +28,7 @@ import (
 
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/forkid"
 	"github.com/ethereumproject/go-ethereum/core/types"
 	"github.com/ethereumproject/go-ethereum/eth/downloader"
 	"github.com/ethereumproject/go-ethereum/eth/fetcher"
@@ -37,6 +38,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/ethereumproject/go-ethereum/p2p"
 	"github.com/ethereumproject/go-ethereum/p2p/discover"
+	"github.com/ethereumproject/go-ethereum/p2p/enr"
 	"github.com/ethereumproject/go-ethereum/pow"
 	"github.com/ethereumproject/go-ethereum/rlp"
 )
@@ -72,6 +74,7 @@ type ProtocolManager struct {
 	blockchain  *core.BlockChain
 	chaindb     ethdb.Database
 	chainConfig *core.ChainConfig
+	forkFilter  forkid.Filter // Fork ID filter, constant across the lifetime of the node
 	maxPeers    int
 
 	downloader *downloader.Downloader
@@ -81,8 +84,8 @@ type ProtocolManager struct {
 	SubProtocols []p2p.Protocol
 
 	eventMux      *event.TypeMux
-	txSub         event.Subscription
-	minedBlockSub event.Subscription
+	txSub         event.TypeMuxSubscription
+	minedBlockSub event.TypeMuxSubscription
 
 	// channels for fetcher, syncer, txsyncLoop
 	newPeerCh   chan *peer
@@ -112,6 +115,9 @@ func NewProtocolManager(config *core.ChainConfig, mode downloader.SyncMode, netw
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
 	}
+	manager.forkFilter = forkid.NewFilter(config, blockchain.Genesis().Hash(), func() uint64 {
+		return blockchain.CurrentHeader().Number.Uint64()
+	})
 	// Figure out whether to allow fast sync or not
 	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
 		glog.V(logger.Warn).Infoln("Blockchain not empty, fast sync disabled")
@@ -155,6 +161,7 @@ func NewProtocolManager(config *core.ChainConfig, mode downloader.SyncMode, netw
 				}
 				return nil
 			},
+			Attributes: []enr.Entry{manager.currentEthEntry()},
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
@@ -261,9 +268,10 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 	glog.V(logger.Debug).Infof("handler: %s ->connected", p)
 
-	// Execute the Ethereum handshake
+	// Execute the Ethereum handshake, rejecting peers on a different fork
 	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	forkID := forkid.NewID(pm.chainConfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
+	if err := p.Handshake(pm.networkId, td, head, genesis, forkID, pm.forkFilter); err != nil {
 		glog.V(logger.Debug).Infof("handler: %s ->handshakefailed err=%v", p, err)
 		return err
 	}
@@ -577,7 +585,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) (err error) {
 				return
 			}
 			// Retrieve the requested state entry, stopping if enough was found
-			if entry, e := pm.chaindb.Get(hash.Bytes()); e == nil {
+			if entry, e := pm.blockchain.TrieNode(hash); e == nil {
 				data = append(data, entry)
 				bytes += len(entry)
 			}
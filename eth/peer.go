@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/forkid"
 	"github.com/ethereumproject/go-ethereum/core/types"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
@@ -364,8 +365,9 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks. Since eth/64 the fork
+// IDs are exchanged too, the remote one being validated by the fork filter.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
 	// Send out own handshake in a new thread
 	sendErrc := make(chan error, 1)
 	recErrc := make(chan error, 1)
@@ -383,13 +385,24 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 
 	go func() {
 		var e error
-		sendSize, e = p2p.Send(p.rw, StatusMsg, d)
+		if p.version >= eth64 {
+			sendSize, e = p2p.Send(p.rw, StatusMsg, &statusData64{
+				ProtocolVersion: d.ProtocolVersion,
+				NetworkId:       d.NetworkId,
+				TD:              d.TD,
+				CurrentBlock:    d.CurrentBlock,
+				GenesisBlock:    d.GenesisBlock,
+				ForkID:          forkID,
+			})
+		} else {
+			sendSize, e = p2p.Send(p.rw, StatusMsg, d)
+		}
 		sendErrc <- e
 	}()
 	go func() {
 		var e error
 		var s uint32
-		s, e = p.readStatusReturnSize(network, &status, genesis)
+		s, e = p.readStatusReturnSize(network, &status, genesis, forkFilter)
 		recSize = int(s)
 		recErrc <- e
 	}()
@@ -420,7 +433,7 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	return nil
 }
 
-func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis common.Hash) (size uint32, err error) {
+func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis common.Hash, forkFilter forkid.Filter) (size uint32, err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return msg.Size, err
@@ -432,7 +445,21 @@ func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis
 		return msg.Size, errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
 	}
 	// Decode the handshake and make sure everything matches
-	if err := msg.Decode(&status); err != nil {
+	var forkID forkid.ID
+	if p.version >= eth64 {
+		var status64 statusData64
+		if err := msg.Decode(&status64); err != nil {
+			return msg.Size, errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		*status = statusData{
+			ProtocolVersion: status64.ProtocolVersion,
+			NetworkId:       status64.NetworkId,
+			TD:              status64.TD,
+			CurrentBlock:    status64.CurrentBlock,
+			GenesisBlock:    status64.GenesisBlock,
+		}
+		forkID = status64.ForkID
+	} else if err := msg.Decode(&status); err != nil {
 		return msg.Size, errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
@@ -444,11 +471,16 @@ func (p *peer) readStatusReturnSize(network uint64, status *statusData, genesis
 	if int(status.ProtocolVersion) != p.version {
 		return msg.Size, errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
 	}
+	if p.version >= eth64 {
+		if err := forkFilter(forkID); err != nil {
+			return msg.Size, errResp(ErrForkIDRejected, "%v", err)
+		}
+	}
 	return msg.Size, nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
-	_, err = p.readStatusReturnSize(network, status, genesis)
+func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash, forkFilter forkid.Filter) (err error) {
+	_, err = p.readStatusReturnSize(network, status, genesis, forkFilter)
 	return
 }
 
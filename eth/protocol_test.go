@@ -23,10 +23,12 @@ import (
 	"time"
 
 	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/forkid"
 	"github.com/ethereumproject/go-ethereum/core/types"
 	"github.com/ethereumproject/go-ethereum/crypto"
 	"github.com/ethereumproject/go-ethereum/eth/downloader"
 	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/p2p/enr"
 	"github.com/ethereumproject/go-ethereum/rlp"
 )
 
@@ -41,12 +43,21 @@ var testAccount, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6
 func TestStatusMsgErrors61(t *testing.T) { testStatusMsgErrors(t, 61) }
 func TestStatusMsgErrors62(t *testing.T) { testStatusMsgErrors(t, 62) }
 func TestStatusMsgErrors63(t *testing.T) { testStatusMsgErrors(t, 63) }
+func TestStatusMsgErrors64(t *testing.T) { testStatusMsgErrors(t, 64) }
 
 func testStatusMsgErrors(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
 	td, currentBlock, genesis := pm.blockchain.Status()
+	forkID := forkid.NewID(pm.chainConfig, genesis, pm.blockchain.CurrentHeader().Number.Uint64())
 	defer pm.Stop()
 
+	// status assembles the status message of the tested protocol version
+	status := func(version uint32, network uint32, genesis common.Hash, forkID forkid.ID) interface{} {
+		if protocol >= eth64 {
+			return statusData64{version, network, td, currentBlock, genesis, forkID}
+		}
+		return statusData{version, network, td, currentBlock, genesis}
+	}
 	tests := []struct {
 		code      uint64
 		data      interface{}
@@ -57,18 +68,28 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
 		},
 		{
-			code: StatusMsg, data: statusData{10, NetworkId, td, currentBlock, genesis},
+			code: StatusMsg, data: status(10, NetworkId, genesis, forkID),
 			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", protocol),
 		},
 		{
-			code: StatusMsg, data: statusData{uint32(protocol), 999, td, currentBlock, genesis},
+			code: StatusMsg, data: status(uint32(protocol), 999, genesis, forkID),
 			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
 		},
 		{
-			code: StatusMsg, data: statusData{uint32(protocol), NetworkId, td, currentBlock, common.Hash{3}},
+			code: StatusMsg, data: status(uint32(protocol), NetworkId, common.Hash{3}, forkID),
 			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000000000000000000000000000000000000000000000000000 (!= %x…)", genesis.Bytes()[:8]),
 		},
 	}
+	if protocol >= eth64 {
+		tests = append(tests, struct {
+			code      uint64
+			data      interface{}
+			wantError error
+		}{
+			code: StatusMsg, data: status(uint32(protocol), NetworkId, genesis, forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}),
+			wantError: errResp(ErrForkIDRejected, "%v", forkid.ErrLocalIncompatibleOrStale),
+		})
+	}
 
 	for i, test := range tests {
 		p, errc := newTestPeer("peer", protocol, pm, false)
@@ -94,6 +115,7 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 func TestRecvTransactions61(t *testing.T) { testRecvTransactions(t, 61) }
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -125,6 +147,7 @@ func testRecvTransactions(t *testing.T, protocol int) {
 func TestSendTransactions61(t *testing.T) { testSendTransactions(t, 61) }
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions64(t *testing.T) { testSendTransactions(t, 64) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -223,3 +246,29 @@ func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the eth protocols advertise the fork ID of the local chain in the
+// node record, and that the entry round-trips through a signed record.
+func TestENREntry(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 3, nil, nil)
+	defer pm.Stop()
+
+	want := forkid.NewID(pm.chainConfig, pm.blockchain.Genesis().Hash(), pm.blockchain.CurrentHeader().Number.Uint64())
+	for _, proto := range pm.SubProtocols {
+		if len(proto.Attributes) != 1 {
+			t.Fatalf("eth/%d: attribute count mismatch: have %d, want 1", proto.Version, len(proto.Attributes))
+		}
+		var rec enr.Record
+		rec.Set(proto.Attributes[0])
+		if err := rec.Sign(testAccount); err != nil {
+			t.Fatalf("eth/%d: failed to sign record: %v", proto.Version, err)
+		}
+		var entry ethEntry
+		if err := rec.Load(&entry); err != nil {
+			t.Fatalf("eth/%d: failed to load entry: %v", proto.Version, err)
+		}
+		if entry.ForkID != want {
+			t.Errorf("eth/%d: fork ID mismatch: have %x, want %x", proto.Version, entry.ForkID, want)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ethclient provides a client for the Ethereum RPC API, decoding the
+// responses into the core types.
+package ethclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/common/hexutil"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+// NotFound is returned by the retrieval methods if the requested item does
+// not exist.
+var NotFound = errors.New("not found")
+
+// Client defines typed wrappers for the Ethereum RPC API.
+type Client struct {
+	c *rpc.ClientConn
+}
+
+// Dial connects a client to the given URL, see rpc.Dial for the supported
+// transports.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL, the context bounding the
+// connection attempt.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC connection.
+func NewClient(c *rpc.ClientConn) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (ec *Client) Close() {
+	ec.c.Close()
+}
+
+// Blockchain Access
+
+// ChainID retrieves the EIP-155 chain ID used for replay protected
+// transactions, zero if it isn't configured.
+func (ec *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	var result hexutil.Big
+	if err := ec.call(ctx, &result, "eth_chainId"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&result), nil
+}
+
+// BlockNumber returns the number of the most recent block.
+func (ec *Client) BlockNumber(ctx context.Context) (*big.Int, error) {
+	var result hexutil.Big
+	if err := ec.call(ctx, &result, "eth_blockNumber"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&result), nil
+}
+
+// BlockByHash returns the given full block.
+//
+// Note that loading full blocks requires two requests. Use HeaderByHash
+// if you don't need all transactions or uncle headers.
+func (ec *Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return ec.getBlock(ctx, "eth_getBlockByHash", hash, true)
+}
+
+// BlockByNumber returns a block from the current canonical chain. If number is
+// nil, the latest known block is returned.
+//
+// Note that loading full blocks requires two requests. Use HeaderByNumber
+// if you don't need all transactions or uncle headers.
+func (ec *Client) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return ec.getBlock(ctx, "eth_getBlockByNumber", toBlockNumArg(number), true)
+}
+
+func (ec *Client) getBlock(ctx context.Context, method string, args ...interface{}) (*types.Block, error) {
+	var raw rpcBlock
+	if err := ec.call(ctx, &raw, method, args...); err != nil {
+		return nil, err
+	}
+	head, err := raw.header()
+	if err != nil {
+		return nil, err
+	}
+	// Quick-verify transaction and uncle lists. This mostly helps with
+	// debugging the server.
+	if head.UncleHash == types.EmptyUncleHash && len(raw.UncleHashes) > 0 {
+		return nil, fmt.Errorf("server returned non-empty uncle list but block header indicates no uncles")
+	}
+	if head.UncleHash != types.EmptyUncleHash && len(raw.UncleHashes) == 0 {
+		return nil, fmt.Errorf("server returned empty uncle list but block header indicates uncles")
+	}
+	if head.TxHash == types.EmptyRootHash && len(raw.Transactions) > 0 {
+		return nil, fmt.Errorf("server returned non-empty transaction list but block header indicates no transactions")
+	}
+	if head.TxHash != types.EmptyRootHash && len(raw.Transactions) == 0 {
+		return nil, fmt.Errorf("server returned empty transaction list but block header indicates transactions")
+	}
+	// Load uncles because they are not included in the block response.
+	var uncles []*types.Header
+	if len(raw.UncleHashes) > 0 {
+		rawUncles := make([]rpcHeader, len(raw.UncleHashes))
+		reqs := make([]rpc.BatchElem, len(raw.UncleHashes))
+		for i := range reqs {
+			reqs[i] = rpc.BatchElem{
+				Method: "eth_getUncleByBlockHashAndIndex",
+				Args:   []interface{}{raw.Hash, hexutil.EncodeUint64(uint64(i))},
+				Result: &rawUncles[i],
+			}
+		}
+		if err := ec.c.BatchCall(ctx, reqs); err != nil {
+			return nil, err
+		}
+		for i := range reqs {
+			if reqs[i].Error != nil {
+				return nil, reqs[i].Error
+			}
+			uncle, err := rawUncles[i].header()
+			if err != nil {
+				return nil, err
+			}
+			uncles = append(uncles, uncle)
+		}
+	}
+	txs := make([]*types.Transaction, len(raw.Transactions))
+	for i, rawTx := range raw.Transactions {
+		if txs[i], err = rawTx.transaction(); err != nil {
+			return nil, err
+		}
+	}
+	return types.NewBlockWithHeader(head).WithBody(txs, uncles), nil
+}
+
+// HeaderByHash returns the block header with the given hash.
+func (ec *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	var raw rpcHeader
+	if err := ec.call(ctx, &raw, "eth_getBlockByHash", hash, false); err != nil {
+		return nil, err
+	}
+	return raw.header()
+}
+
+// HeaderByNumber returns a block header from the current canonical chain. If
+// number is nil, the latest known header is returned.
+func (ec *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var raw rpcHeader
+	if err := ec.call(ctx, &raw, "eth_getBlockByNumber", toBlockNumArg(number), false); err != nil {
+		return nil, err
+	}
+	return raw.header()
+}
+
+// TransactionByHash returns the transaction with the given hash, and whether
+// it is still pending.
+func (ec *Client) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	var raw rpcTransaction
+	if err := ec.call(ctx, &raw, "eth_getTransactionByHash", hash); err != nil {
+		return nil, false, err
+	}
+	if tx, err = raw.transaction(); err != nil {
+		return nil, false, err
+	}
+	return tx, raw.BlockNumber == nil, nil
+}
+
+// TransactionCount returns the total number of transactions in the given block.
+func (ec *Client) TransactionCount(ctx context.Context, blockHash common.Hash) (uint, error) {
+	var num hexutil.Uint
+	err := ec.call(ctx, &num, "eth_getBlockTransactionCountByHash", blockHash)
+	return uint(num), err
+}
+
+// TransactionInBlock returns a single transaction at index in the given block.
+func (ec *Client) TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (*types.Transaction, error) {
+	var raw rpcTransaction
+	if err := ec.call(ctx, &raw, "eth_getTransactionByBlockHashAndIndex", blockHash, hexutil.EncodeUint64(uint64(index))); err != nil {
+		return nil, err
+	}
+	return raw.transaction()
+}
+
+// TransactionReceipt returns the receipt of a mined transaction. Note that the
+// receipt is not available for pending transactions.
+func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	var raw rpcReceipt
+	if err := ec.call(ctx, &raw, "eth_getTransactionReceipt", txHash); err != nil {
+		return nil, err
+	}
+	return raw.receipt()
+}
+
+// SyncProgress retrieves the current progress of the sync algorithm. If there's
+// no sync currently running, it returns nil.
+func (ec *Client) SyncProgress(ctx context.Context) (*SyncProgress, error) {
+	var raw json.RawMessage
+	if err := ec.call(ctx, &raw, "eth_syncing"); err != nil {
+		return nil, err
+	}
+	// Handle the possible response types
+	var syncing bool
+	if err := json.Unmarshal(raw, &syncing); err == nil {
+		return nil, nil // Not syncing (always false)
+	}
+	var progress struct {
+		StartingBlock hexutil.Uint64 `json:"startingBlock"`
+		CurrentBlock  hexutil.Uint64 `json:"currentBlock"`
+		HighestBlock  hexutil.Uint64 `json:"highestBlock"`
+		PulledStates  hexutil.Uint64 `json:"pulledStates"`
+		KnownStates   hexutil.Uint64 `json:"knownStates"`
+	}
+	if err := json.Unmarshal(raw, &progress); err != nil {
+		return nil, err
+	}
+	return &SyncProgress{
+		StartingBlock: uint64(progress.StartingBlock),
+		CurrentBlock:  uint64(progress.CurrentBlock),
+		HighestBlock:  uint64(progress.HighestBlock),
+		PulledStates:  uint64(progress.PulledStates),
+		KnownStates:   uint64(progress.KnownStates),
+	}, nil
+}
+
+// SubscribeNewHead subscribes to notifications about the current blockchain
+// head on the given channel.
+func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (*rpc.ClientSubscription, error) {
+	raw := make(chan rpcHeader)
+	sub, err := ec.c.Subscribe(ctx, raw, "newHeads")
+	if err != nil {
+		return nil, err
+	}
+	go forwardHeaders(sub, raw, ch)
+	return sub, nil
+}
+
+// forwardHeaders converts the raw head notifications of a subscription until
+// it ends. Malformed headers are skipped.
+func forwardHeaders(sub *rpc.ClientSubscription, raw <-chan rpcHeader, ch chan<- *types.Header) {
+	for {
+		select {
+		case r := <-raw:
+			head, err := r.header()
+			if err != nil {
+				continue
+			}
+			select {
+			case ch <- head:
+			case <-sub.Err():
+				return
+			}
+		case <-sub.Err():
+			return
+		}
+	}
+}
+
+// State Access
+
+// BalanceAt returns the wei balance of the given account. The block number can
+// be nil, in which case the balance is taken from the latest known block.
+func (ec *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	var result hexutil.Big
+	err := ec.call(ctx, &result, "eth_getBalance", account, toBlockNumArg(blockNumber))
+	return (*big.Int)(&result), err
+}
+
+// StorageAt returns the value of key in the contract storage of the given
+// account. The block number can be nil, in which case the value is taken from
+// the latest known block.
+func (ec *Client) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	var result string
+	if err := ec.call(ctx, &result, "eth_getStorageAt", account, key.Hex(), toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	return common.FromHex(result), nil
+}
+
+// CodeAt returns the contract code of the given account. The block number can
+// be nil, in which case the code is taken from the latest known block.
+func (ec *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	err := ec.call(ctx, &result, "eth_getCode", account, toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// NonceAt returns the account nonce of the given account. The block number can
+// be nil, in which case the nonce is taken from the latest known block.
+func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	var result hexutil.Uint64
+	err := ec.call(ctx, &result, "eth_getTransactionCount", account, toBlockNumArg(blockNumber))
+	return uint64(result), err
+}
+
+// Filters
+
+// FilterLogs executes a filter query.
+func (ec *Client) FilterLogs(ctx context.Context, q FilterQuery) ([]vm.Log, error) {
+	var raw []rpcLog
+	if err := ec.call(ctx, &raw, "eth_getLogs", toFilterArg(q)); err != nil {
+		return nil, err
+	}
+	logs := make([]vm.Log, len(raw))
+	for i, r := range raw {
+		logs[i] = r.log()
+	}
+	return logs, nil
+}
+
+// SubscribeFilterLogs subscribes to the results of a streaming filter query.
+// The block range of the query is ignored, only new logs are delivered.
+func (ec *Client) SubscribeFilterLogs(ctx context.Context, q FilterQuery, ch chan<- vm.Log) (*rpc.ClientSubscription, error) {
+	raw := make(chan rpcLog)
+	sub, err := ec.c.Subscribe(ctx, raw, "logs", toFilterArg(q))
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			select {
+			case r := <-raw:
+				select {
+				case ch <- r.log():
+				case <-sub.Err():
+					return
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// Pending State
+
+// PendingBalanceAt returns the wei balance of the given account in the pending state.
+func (ec *Client) PendingBalanceAt(ctx context.Context, account common.Address) (*big.Int, error) {
+	var result hexutil.Big
+	err := ec.call(ctx, &result, "eth_getBalance", account, "pending")
+	return (*big.Int)(&result), err
+}
+
+// PendingCodeAt returns the contract code of the given account in the pending state.
+func (ec *Client) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	var result hexutil.Bytes
+	err := ec.call(ctx, &result, "eth_getCode", account, "pending")
+	return result, err
+}
+
+// PendingNonceAt returns the account nonce of the given account in the pending
+// state. This is the nonce that should be used for the next transaction.
+func (ec *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	var result hexutil.Uint64
+	err := ec.call(ctx, &result, "eth_getTransactionCount", account, "pending")
+	return uint64(result), err
+}
+
+// Contract Calling
+
+// CallContract executes a message call transaction, which is directly executed
+// in the VM of the node, but never mined into the blockchain.
+//
+// The block number selects the block in which the call runs. It can be nil, in
+// which case the code is taken from the latest known block.
+func (ec *Client) CallContract(ctx context.Context, msg CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var hex string
+	if err := ec.call(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+	return common.FromHex(hex), nil
+}
+
+// SuggestGasPrice retrieves the currently suggested gas price to allow a timely
+// execution of a transaction.
+func (ec *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	var hex hexutil.Big
+	if err := ec.call(ctx, &hex, "eth_gasPrice"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&hex), nil
+}
+
+// EstimateGas tries to estimate the gas needed to execute a specific
+// transaction based on the current pending state of the chain. There is no
+// guarantee that this is the true gas limit requirement as other transactions
+// may be added or removed by miners, but it should provide a basis for setting
+// a reasonable default.
+func (ec *Client) EstimateGas(ctx context.Context, msg CallMsg) (*big.Int, error) {
+	var hex hexutil.Big
+	if err := ec.call(ctx, &hex, "eth_estimateGas", toCallArg(msg)); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&hex), nil
+}
+
+// SendTransaction injects a signed transaction into the pending pool for
+// execution.
+//
+// If the transaction was a contract creation use the TransactionReceipt method
+// to get the contract address after the transaction has been mined.
+func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	return ec.c.CallContext(ctx, nil, "eth_sendRawTransaction", common.ToHex(data))
+}
+
+// call performs the RPC call, translating a missing result to NotFound.
+func (ec *Client) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	err := ec.c.CallContext(ctx, result, method, args...)
+	if err == rpc.ErrNoResult {
+		return NotFound
+	}
+	return err
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+func toCallArg(msg CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = common.ToHex(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = hexutil.EncodeBig(msg.Value)
+	}
+	if msg.Gas != nil {
+		arg["gas"] = hexutil.EncodeBig(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = hexutil.EncodeBig(msg.GasPrice)
+	}
+	return arg
+}
+
+func toFilterArg(q FilterQuery) interface{} {
+	// Empty topic positions match anything, single ones are sent flat
+	topics := make([]interface{}, len(q.Topics))
+	for i, position := range q.Topics {
+		switch len(position) {
+		case 0:
+			topics[i] = nil
+		case 1:
+			topics[i] = position[0]
+		default:
+			topics[i] = position
+		}
+	}
+	arg := map[string]interface{}{
+		"fromBlock": toBlockNumArg(q.FromBlock),
+		"toBlock":   toBlockNumArg(q.ToBlock),
+		"address":   q.Addresses,
+		"topics":    topics,
+	}
+	if q.FromBlock == nil {
+		arg["fromBlock"] = "0x0"
+	}
+	return arg
+}
@@ -0,0 +1,199 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/eth"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// EthService serves a single block and the receipt of its transaction in
+// the format of the eth API.
+type EthService struct {
+	block   *types.Block
+	receipt *types.Receipt
+}
+
+func (s *EthService) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	if number != rpc.LatestBlockNumber && number.Int64() != s.block.Number().Int64() {
+		return nil, nil
+	}
+	return eth.RPCMarshalBlock(s.block, s.block.Difficulty(), core.DefaultConfigMainnet.ChainConfig, true, fullTx)
+}
+
+func (s *EthService) GetTransactionReceipt(hash common.Hash) (map[string]interface{}, error) {
+	if hash != s.receipt.TxHash {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"root":              common.Bytes2Hex(s.receipt.PostState),
+		"transactionHash":   s.receipt.TxHash,
+		"gasUsed":           rpc.NewHexNumber(s.receipt.GasUsed),
+		"cumulativeGasUsed": rpc.NewHexNumber(s.receipt.CumulativeGasUsed),
+		"contractAddress":   nil,
+		"logs":              s.receipt.Logs,
+		"status":            rpc.NewHexNumber(s.receipt.Status),
+	}, nil
+}
+
+func (s *EthService) NewHeads(ctx context.Context) (rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	subscription, err := notifier.NewSubscription(nil)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		block, _ := eth.RPCMarshalBlock(s.block, s.block.Difficulty(), core.DefaultConfigMainnet.ChainConfig, false, false)
+		subscription.Notify(block)
+	}()
+	return subscription, nil
+}
+
+func newTestBackend(t *testing.T) (*rpc.Server, *EthService) {
+	tx, err := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), []byte{0xca, 0xfe}).SignECDSA(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt := types.NewReceipt(common.Hash{0x02}.Bytes(), big.NewInt(21000))
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = big.NewInt(21000)
+	receipt.Status = types.TxSuccess
+	receipt.Logs = vm.Logs{{
+		Address: common.Address{0x03},
+		Topics:  []common.Hash{{0x04}},
+		Data:    []byte{0x05},
+		TxHash:  tx.Hash(),
+	}}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	header := &types.Header{
+		ParentHash: common.Hash{0x06},
+		Coinbase:   common.Address{0x07},
+		Difficulty: big.NewInt(131072),
+		Number:     big.NewInt(1),
+		GasLimit:   big.NewInt(4712388),
+		GasUsed:    big.NewInt(21000),
+		Time:       big.NewInt(1500000000),
+		Extra:      []byte("ethclient"),
+	}
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, []*types.Receipt{receipt})
+	receipt.Logs[0].BlockHash = block.Hash()
+	receipt.Logs[0].BlockNumber = 1
+
+	service := &EthService{block: block, receipt: receipt}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	return server, service
+}
+
+func TestBlockByNumber(t *testing.T) {
+	server, service := newTestBackend(t)
+	defer server.Stop()
+	client := NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	block, err := client.BlockByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Hash() != service.block.Hash() {
+		t.Errorf("block hash mismatch: have %x, want %x", block.Hash(), service.block.Hash())
+	}
+	if len(block.Transactions()) != 1 {
+		t.Fatalf("transaction count mismatch: have %d, want 1", len(block.Transactions()))
+	}
+	have, want := block.Transactions()[0], service.block.Transactions()[0]
+	if have.Hash() != want.Hash() {
+		t.Errorf("transaction hash mismatch: have %x, want %x", have.Hash(), want.Hash())
+	}
+	from, err := have.From()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := crypto.PubkeyToAddress(testKey.PublicKey); from != addr {
+		t.Errorf("sender mismatch: have %x, want %x", from, addr)
+	}
+}
+
+func TestHeaderNotFound(t *testing.T) {
+	server, _ := newTestBackend(t)
+	defer server.Stop()
+	client := NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	if _, err := client.HeaderByNumber(context.Background(), big.NewInt(2)); err != NotFound {
+		t.Errorf("have error %v, want %v", err, NotFound)
+	}
+}
+
+func TestTransactionReceipt(t *testing.T) {
+	server, service := newTestBackend(t)
+	defer server.Stop()
+	client := NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	receipt, err := client.TransactionReceipt(context.Background(), service.receipt.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(receipt, service.receipt) {
+		t.Errorf("receipt mismatch:\nhave %+v\nwant %+v", receipt, service.receipt)
+	}
+}
+
+func TestSubscribeNewHead(t *testing.T) {
+	server, service := newTestBackend(t)
+	defer server.Stop()
+	client := NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	heads := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(context.Background(), heads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case head := <-heads:
+		if head.Hash() != service.block.Hash() {
+			t.Errorf("head hash mismatch: have %x, want %x", head.Hash(), service.block.Hash())
+		}
+	case err := <-sub.Err():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the new head")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/common/hexutil"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+// CallMsg contains parameters for contract calls.
+type CallMsg struct {
+	From     common.Address  // the sender of the 'transaction'
+	To       *common.Address // the destination contract (nil for contract creation)
+	Gas      *big.Int        // if nil, the call executes with near-infinite gas
+	GasPrice *big.Int        // wei <-> gas exchange ratio
+	Value    *big.Int        // amount of wei sent along with the call
+	Data     []byte          // input data, usually an ABI-encoded contract method invocation
+}
+
+// FilterQuery contains options for contract log filtering.
+type FilterQuery struct {
+	FromBlock *big.Int         // beginning of the queried range, nil means genesis block
+	ToBlock   *big.Int         // end of the range, nil means latest block
+	Addresses []common.Address // restricts matches to events created by specific contracts
+
+	// The Topic list restricts matches to particular event topics. Each event has a list
+	// of topics. Topics matches a prefix of that list. An empty element slice matches any
+	// topic. Non-empty elements represent an alternative that matches any of the
+	// contained topics.
+	Topics [][]common.Hash
+}
+
+// SyncProgress gives progress indications when the node is synchronising with
+// the network.
+type SyncProgress struct {
+	StartingBlock uint64 // Block number where sync began
+	CurrentBlock  uint64 // Current block number where sync is at
+	HighestBlock  uint64 // Highest alleged block number in the chain
+	PulledStates  uint64 // Number of state trie entries already downloaded
+	KnownStates   uint64 // Total number of state trie entries known about
+}
+
+// rpcHeader is the RPC representation of a block, decoded into a header.
+type rpcHeader struct {
+	Hash        common.Hash    `json:"hash"`
+	ParentHash  common.Hash    `json:"parentHash"`
+	UncleHash   common.Hash    `json:"sha3Uncles"`
+	Coinbase    common.Address `json:"miner"`
+	Root        common.Hash    `json:"stateRoot"`
+	TxHash      common.Hash    `json:"transactionsRoot"`
+	ReceiptHash common.Hash    `json:"receiptsRoot"`
+	Bloom       hexutil.Bytes  `json:"logsBloom"`
+	Difficulty  *hexutil.Big   `json:"difficulty"`
+	Number      *hexutil.Big   `json:"number"`
+	GasLimit    *hexutil.Big   `json:"gasLimit"`
+	GasUsed     *hexutil.Big   `json:"gasUsed"`
+	Time        *hexutil.Big   `json:"timestamp"`
+	Extra       hexutil.Bytes  `json:"extraData"`
+	MixDigest   common.Hash    `json:"mixHash"`
+	Nonce       hexutil.Bytes  `json:"nonce"`
+}
+
+// header assembles the block header, verifying it against the reported hash.
+func (r *rpcHeader) header() (*types.Header, error) {
+	if r.Number == nil || r.Difficulty == nil || r.GasLimit == nil || r.GasUsed == nil || r.Time == nil {
+		return nil, fmt.Errorf("incomplete header %x", r.Hash)
+	}
+	head := &types.Header{
+		ParentHash:  r.ParentHash,
+		UncleHash:   r.UncleHash,
+		Coinbase:    r.Coinbase,
+		Root:        r.Root,
+		TxHash:      r.TxHash,
+		ReceiptHash: r.ReceiptHash,
+		Bloom:       types.BytesToBloom(r.Bloom),
+		Difficulty:  r.Difficulty.ToInt(),
+		Number:      r.Number.ToInt(),
+		GasLimit:    r.GasLimit.ToInt(),
+		GasUsed:     r.GasUsed.ToInt(),
+		Time:        r.Time.ToInt(),
+		Extra:       r.Extra,
+		MixDigest:   r.MixDigest,
+	}
+	copy(head.Nonce[:], r.Nonce)
+
+	if hash := head.Hash(); hash != r.Hash {
+		return nil, fmt.Errorf("header hash mismatch: have %x, want %x", hash, r.Hash)
+	}
+	return head, nil
+}
+
+// rpcBlock is the RPC representation of a block with full transactions.
+type rpcBlock struct {
+	rpcHeader
+	Transactions []rpcTransaction `json:"transactions"`
+	UncleHashes  []common.Hash    `json:"uncles"`
+}
+
+// rpcTransaction is the RPC representation of a transaction.
+type rpcTransaction struct {
+	Hash        common.Hash     `json:"hash"`
+	BlockHash   *common.Hash    `json:"blockHash"`
+	BlockNumber *hexutil.Big    `json:"blockNumber"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	GasPrice    *hexutil.Big    `json:"gasPrice"`
+	Gas         *hexutil.Big    `json:"gas"`
+	To          *common.Address `json:"to"`
+	Value       *hexutil.Big    `json:"value"`
+	Input       hexutil.Bytes   `json:"input"`
+	V           *hexutil.Big    `json:"v"`
+	R           *hexutil.Big    `json:"r"`
+	S           *hexutil.Big    `json:"s"`
+}
+
+// transaction assembles the signed transaction, verifying it against the
+// reported hash.
+func (r *rpcTransaction) transaction() (*types.Transaction, error) {
+	if r.GasPrice == nil || r.Gas == nil || r.Value == nil {
+		return nil, fmt.Errorf("incomplete transaction %x", r.Hash)
+	}
+	if r.V == nil || r.R == nil || r.S == nil {
+		return nil, fmt.Errorf("server returned transaction %x without signature", r.Hash)
+	}
+	// Transactions can only be assembled with their signature through RLP,
+	// the signer is derived from V in the process.
+	enc, err := rlp.EncodeToBytes([]interface{}{
+		uint64(r.Nonce), r.GasPrice.ToInt(), r.Gas.ToInt(), recipient(r.To), r.Value.ToInt(), []byte(r.Input),
+		r.V.ToInt(), r.R.ToInt(), r.S.ToInt(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(enc, tx); err != nil {
+		return nil, err
+	}
+	if hash := tx.Hash(); hash != r.Hash {
+		return nil, fmt.Errorf("transaction hash mismatch: have %x, want %x", hash, r.Hash)
+	}
+	return tx, nil
+}
+
+// recipient returns the RLP encodable recipient, empty for contract creations.
+func recipient(to *common.Address) []byte {
+	if to == nil {
+		return nil
+	}
+	return to.Bytes()
+}
+
+// rpcReceipt is the RPC representation of a transaction receipt.
+type rpcReceipt struct {
+	TxHash            common.Hash     `json:"transactionHash"`
+	Root              string          `json:"root"`
+	CumulativeGasUsed *hexutil.Big    `json:"cumulativeGasUsed"`
+	GasUsed           *hexutil.Big    `json:"gasUsed"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+	Logs              []rpcLog        `json:"logs"`
+	Status            *hexutil.Uint64 `json:"status"`
+}
+
+func (r *rpcReceipt) receipt() (*types.Receipt, error) {
+	if r.CumulativeGasUsed == nil || r.GasUsed == nil {
+		return nil, fmt.Errorf("incomplete receipt of transaction %x", r.TxHash)
+	}
+	receipt := types.NewReceipt(common.FromHex(r.Root), r.CumulativeGasUsed.ToInt())
+	receipt.TxHash = r.TxHash
+	receipt.GasUsed = r.GasUsed.ToInt()
+	if r.ContractAddress != nil {
+		receipt.ContractAddress = *r.ContractAddress
+	}
+	if r.Status != nil {
+		receipt.Status = types.ReceiptStatus(*r.Status)
+	}
+	receipt.Logs = make(vm.Logs, len(r.Logs))
+	for i, l := range r.Logs {
+		log := l.log()
+		receipt.Logs[i] = &log
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, nil
+}
+
+// rpcLog is the RPC representation of a contract log.
+type rpcLog struct {
+	Address     common.Address `json:"address"`
+	Topics      []common.Hash  `json:"topics"`
+	Data        hexutil.Bytes  `json:"data"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	TxIndex     hexutil.Uint   `json:"transactionIndex"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	Index       hexutil.Uint   `json:"logIndex"`
+}
+
+func (r *rpcLog) log() vm.Log {
+	return vm.Log{
+		Address:     r.Address,
+		Topics:      r.Topics,
+		Data:        r.Data,
+		BlockNumber: uint64(r.BlockNumber),
+		TxHash:      r.TxHash,
+		TxIndex:     uint(r.TxIndex),
+		BlockHash:   r.BlockHash,
+		Index:       uint(r.Index),
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+)
+
+// boltFile is the name of the BoltDB data file inside the database directory.
+const boltFile = "bolt.db"
+
+// boltIteratorChunk is the number of entries an iterator loads per read
+// transaction.
+const boltIteratorChunk = 1024
+
+// boltBucket is the single bucket holding all the key/value pairs.
+var boltBucket = []byte("ethdb")
+
+var errBoltNotFound = errors.New("not found")
+
+// BoltDatabase is a database backed by BoltDB, a single file memory mapped
+// B+tree store. Compared to LevelDB it trades write throughput for cheaper
+// reads and no background compaction.
+type BoltDatabase struct {
+	file string
+	db   *bolt.DB
+}
+
+// NewBoltDatabase opens (or creates) a BoltDB store in the given directory.
+func NewBoltDatabase(file string) (*BoltDatabase, error) {
+	if err := os.MkdirAll(file, 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(file, boltFile), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	glog.V(logger.Info).Infof("Opened BoltDB database %s", file)
+	return &BoltDatabase{file: file, db: db}, nil
+}
+
+// Path returns the path to the database directory.
+func (db *BoltDatabase) Path() string {
+	return db.file
+}
+
+func (db *BoltDatabase) Put(key []byte, value []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, common.CopyBytes(value))
+	})
+}
+
+// Get returns the given key if it's present. The value is copied out of the
+// memory map, so it stays valid after the read transaction.
+func (db *BoltDatabase) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(boltBucket).Cursor().Seek(key)
+		if k == nil || !bytes.Equal(k, key) {
+			return errBoltNotFound
+		}
+		value = common.CopyBytes(v)
+		return nil
+	})
+	return value, err
+}
+
+func (db *BoltDatabase) Has(key []byte) (bool, error) {
+	var ok bool
+	err := db.db.View(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(boltBucket).Cursor().Seek(key)
+		ok = k != nil && bytes.Equal(k, key)
+		return nil
+	})
+	return ok, err
+}
+
+func (db *BoltDatabase) Delete(key []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(key)
+	})
+}
+
+// NewIterator creates an iterator over the keys starting with prefix,
+// beginning at prefix+start. Unlike LevelDB iterators it doesn't iterate over
+// a snapshot: entries are loaded in chunks, each in its own read transaction,
+// so that writes in between don't block on a long running transaction.
+func (db *BoltDatabase) NewIterator(prefix []byte, start []byte) Iterator {
+	return &boltIterator{
+		db:     db.db,
+		prefix: common.CopyBytes(prefix),
+		next:   append(common.CopyBytes(prefix), start...),
+		index:  -1,
+	}
+}
+
+// NewReverseIterator creates an iterator over the keys starting with prefix,
+// in descending order, beginning before prefix+start. Like NewIterator it
+// loads the entries in chunks.
+func (db *BoltDatabase) NewReverseIterator(prefix []byte, start []byte) Iterator {
+	it := &boltIterator{
+		db:      db.db,
+		prefix:  common.CopyBytes(prefix),
+		index:   -1,
+		reverse: true,
+	}
+	if len(start) > 0 {
+		it.next = append(common.CopyBytes(prefix), start...)
+	}
+	return it
+}
+
+// Stat returns the internal stats of the database for the "bolt.stats"
+// property.
+func (db *BoltDatabase) Stat(property string) (string, error) {
+	if property != "bolt.stats" {
+		return "", errors.New("unknown property")
+	}
+	stats := db.db.Stats()
+	return fmt.Sprintf("Free pages: %d\nPending pages: %d\nFree alloc: %d\nFreelist in use: %d\nRead txs: %d\nOpen read txs: %d\n",
+		stats.FreePageN, stats.PendingPageN, stats.FreeAlloc, stats.FreelistInuse, stats.TxN, stats.OpenTxN), nil
+}
+
+// Compact is a no-op, BoltDB reuses freed pages without compaction.
+func (db *BoltDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+func (db *BoltDatabase) Close() {
+	if err := db.db.Close(); err != nil {
+		glog.Errorf("eth: DB %s: %s", db.file, err)
+	}
+}
+
+func (db *BoltDatabase) NewBatch() Batch {
+	return &boltBatch{db: db.db}
+}
+
+type boltBatch struct {
+	db     *bolt.DB
+	writes []kv
+	size   int
+}
+
+func (b *boltBatch) Put(key, value []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), common.CopyBytes(value), false})
+	b.size += len(value)
+	return nil
+}
+
+func (b *boltBatch) Delete(key []byte) error {
+	b.writes = append(b.writes, kv{common.CopyBytes(key), nil, true})
+	b.size += len(key)
+	return nil
+}
+
+// Write applies all the batched writes in a single, atomic transaction.
+func (b *boltBatch) Write() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, kv := range b.writes {
+			var err error
+			if kv.del {
+				err = bucket.Delete(kv.k)
+			} else {
+				err = bucket.Put(kv.k, kv.v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltBatch) ValueSize() int {
+	return b.size
+}
+
+func (b *boltBatch) Reset() {
+	b.writes = b.writes[:0]
+	b.size = 0
+}
+
+// boltIterator iterates over the keys with a given prefix, loading them in
+// chunks of boltIteratorChunk entries.
+type boltIterator struct {
+	db      *bolt.DB
+	prefix  []byte // Prefix of the iterated keys
+	next    []byte // Key to continue from with the next chunk, exclusive if reverse
+	done    bool   // Whether the last chunk has been loaded
+	reverse bool   // Whether to iterate in descending key order
+
+	keys   [][]byte
+	values [][]byte
+	index  int
+	err    error
+}
+
+func (it *boltIterator) Next() bool {
+	if it.index+1 < len(it.keys) {
+		it.index++
+		return true
+	}
+	if it.done || it.err != nil {
+		it.keys, it.values, it.index = nil, nil, -1
+		return false
+	}
+	it.load()
+	if len(it.keys) == 0 {
+		return false
+	}
+	it.index = 0
+	return true
+}
+
+// load reads the next chunk of entries.
+func (it *boltIterator) load() {
+	it.keys, it.values = nil, nil
+	if it.reverse {
+		it.loadReverse()
+		return
+	}
+	it.err = it.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		for k, v := c.Seek(it.next); k != nil && bytes.HasPrefix(k, it.prefix); k, v = c.Next() {
+			if len(it.keys) == boltIteratorChunk {
+				it.next = common.CopyBytes(k)
+				return nil
+			}
+			it.keys = append(it.keys, common.CopyBytes(k))
+			it.values = append(it.values, common.CopyBytes(v))
+		}
+		it.done = true
+		return nil
+	})
+}
+
+// loadReverse reads the next chunk of entries in descending order.
+func (it *boltIterator) loadReverse() {
+	it.err = it.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+
+		// Position the cursor on the last key before the limit, either the
+		// chunk continuation or the end of the prefix range.
+		limit := it.next
+		if limit == nil {
+			limit = prefixLimit(it.prefix)
+		}
+		var k, v []byte
+		if limit == nil {
+			k, v = c.Last()
+		} else if k, v = c.Seek(limit); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, it.prefix); k, v = c.Prev() {
+			if len(it.keys) == boltIteratorChunk {
+				it.next = it.keys[len(it.keys)-1]
+				return nil
+			}
+			it.keys = append(it.keys, common.CopyBytes(k))
+			it.values = append(it.values, common.CopyBytes(v))
+		}
+		it.done = true
+		return nil
+	})
+}
+
+// prefixLimit returns the smallest key greater than all the keys with the
+// given prefix, or nil if there is none.
+func prefixLimit(prefix []byte) []byte {
+	limit := common.CopyBytes(prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] < 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+	return nil
+}
+
+func (it *boltIterator) Error() error {
+	return it.err
+}
+
+func (it *boltIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.index]
+}
+
+func (it *boltIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+func (it *boltIterator) Release() {
+	it.keys, it.values, it.done = nil, nil, true
+}
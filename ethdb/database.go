@@ -21,12 +21,13 @@ import (
 
 	"strconv"
 
+	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/filter"
-	"github.com/syndtr/goleveldb/leveldb/iterator"
+	ldbiter "github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	ldbutil "github.com/syndtr/goleveldb/leveldb/util"
 	"sync"
@@ -128,16 +129,32 @@ func (self *LDBDatabase) Delete(key []byte) error {
 	return self.db.Delete(key, nil)
 }
 
-func (self *LDBDatabase) NewIterator() iterator.Iterator {
-	return self.db.NewIterator(nil, nil)
+// NewIterator creates an iterator over the keys starting with prefix,
+// beginning at prefix+start.
+func (self *LDBDatabase) NewIterator(prefix []byte, start []byte) Iterator {
+	r := ldbutil.BytesPrefix(prefix)
+	r.Start = append(r.Start, start...)
+	return self.db.NewIterator(r, nil)
 }
 
-func (self *LDBDatabase) NewIteratorRange(slice *ldbutil.Range) iterator.Iterator {
-	return self.db.NewIterator(slice, nil)
+// NewReverseIterator creates an iterator over the keys starting with prefix,
+// in descending order, beginning before prefix+start.
+func (self *LDBDatabase) NewReverseIterator(prefix []byte, start []byte) Iterator {
+	r := ldbutil.BytesPrefix(prefix)
+	if len(start) > 0 {
+		r.Limit = append(common.CopyBytes(prefix), start...)
+	}
+	return &ldbReverseIterator{Iterator: self.db.NewIterator(r, nil)}
+}
+
+// Stat returns a particular internal stat of the database, e.g. "leveldb.stats".
+func (self *LDBDatabase) Stat(property string) (string, error) {
+	return self.db.GetProperty(property)
 }
 
-func NewBytesPrefix(prefix []byte) *ldbutil.Range {
-	return ldbutil.BytesPrefix(prefix)
+// Compact flattens the underlying data store for the given key range.
+func (self *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return self.db.CompactRange(ldbutil.Range{Start: start, Limit: limit})
 }
 
 func (self *LDBDatabase) Close() {
@@ -168,6 +185,12 @@ func (b *ldbBatch) Put(key, value []byte) error {
 	return nil
 }
 
+func (b *ldbBatch) Delete(key []byte) error {
+	b.b.Delete(key)
+	b.size += len(key)
+	return nil
+}
+
 func (b *ldbBatch) Write() error {
 	return b.db.Write(b.b, nil)
 }
@@ -176,6 +199,25 @@ func (b *ldbBatch) ValueSize() int {
 	return b.size
 }
 
+func (b *ldbBatch) Reset() {
+	b.b.Reset()
+	b.size = 0
+}
+
+// ldbReverseIterator walks a LevelDB iterator backwards.
+type ldbReverseIterator struct {
+	ldbiter.Iterator
+	started bool
+}
+
+func (it *ldbReverseIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		return it.Last()
+	}
+	return it.Prev()
+}
+
 type table struct {
 	db     Database
 	prefix string
@@ -206,10 +248,56 @@ func (dt *table) Delete(key []byte) error {
 	return dt.db.Delete(append([]byte(dt.prefix), key...))
 }
 
+func (dt *table) NewIterator(prefix []byte, start []byte) Iterator {
+	it := dt.db.NewIterator(append([]byte(dt.prefix), prefix...), start)
+	return &tableIterator{it: it, prefix: len(dt.prefix)}
+}
+
+func (dt *table) NewReverseIterator(prefix []byte, start []byte) Iterator {
+	it := dt.db.NewReverseIterator(append([]byte(dt.prefix), prefix...), start)
+	return &tableIterator{it: it, prefix: len(dt.prefix)}
+}
+
+func (dt *table) Stat(property string) (string, error) {
+	return dt.db.Stat(property)
+}
+
+// Compact flattens the table's key range of the underlying data store. A nil
+// limit compacts up to the end of the table.
+func (dt *table) Compact(start []byte, limit []byte) error {
+	prefix := []byte(dt.prefix)
+	start = append(common.CopyBytes(prefix), start...)
+	if limit != nil {
+		limit = append(common.CopyBytes(prefix), limit...)
+	} else {
+		limit = ldbutil.BytesPrefix(prefix).Limit
+	}
+	return dt.db.Compact(start, limit)
+}
+
 func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
 
+// tableIterator wraps an iterator of the underlying database, stripping the
+// table prefix from the keys.
+type tableIterator struct {
+	it     Iterator
+	prefix int
+}
+
+func (it *tableIterator) Next() bool    { return it.it.Next() }
+func (it *tableIterator) Error() error  { return it.it.Error() }
+func (it *tableIterator) Value() []byte { return it.it.Value() }
+func (it *tableIterator) Release()      { it.it.Release() }
+
+func (it *tableIterator) Key() []byte {
+	if key := it.it.Key(); key != nil {
+		return key[it.prefix:]
+	}
+	return nil
+}
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
@@ -228,6 +316,10 @@ func (tb *tableBatch) Put(key, value []byte) error {
 	return tb.batch.Put(append([]byte(tb.prefix), key...), value)
 }
 
+func (tb *tableBatch) Delete(key []byte) error {
+	return tb.batch.Delete(append([]byte(tb.prefix), key...))
+}
+
 func (tb *tableBatch) Write() error {
 	return tb.batch.Write()
 }
@@ -235,3 +327,7 @@ func (tb *tableBatch) Write() error {
 func (tb *tableBatch) ValueSize() int {
 	return tb.batch.ValueSize()
 }
+
+func (tb *tableBatch) Reset() {
+	tb.batch.Reset()
+}
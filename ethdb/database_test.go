@@ -0,0 +1,277 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testDatabases runs the test against every database implementation.
+func testDatabases(t *testing.T, test func(t *testing.T, db Database)) {
+	t.Run("memory", func(t *testing.T) {
+		db, _ := NewMemDatabase()
+		test(t, db)
+	})
+	t.Run("table", func(t *testing.T) {
+		db, _ := NewMemDatabase()
+		db.Put([]byte("t"), []byte("outside of the table"))
+		db.Put([]byte("tablf"), []byte("after the table"))
+		test(t, NewTable(db, "table-"))
+	})
+	for _, engine := range []string{EngineLevelDB, EngineBoltDB} {
+		engine := engine
+		t.Run(engine, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ethdb-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			db, err := NewDatabase(engine, dir, 16, 16)
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+			defer db.Close()
+			test(t, db)
+		})
+	}
+}
+
+func TestDatabasePutGet(t *testing.T) {
+	testDatabases(t, func(t *testing.T, db Database) {
+		if err := db.Put([]byte("key"), []byte("value")); err != nil {
+			t.Fatalf("failed to put: %v", err)
+		}
+		if value, err := db.Get([]byte("key")); err != nil || !bytes.Equal(value, []byte("value")) {
+			t.Fatalf("get mismatch: have %q (%v), want %q", value, err, "value")
+		}
+		if ok, _ := db.Has([]byte("key")); !ok {
+			t.Fatalf("stored key missing")
+		}
+		if _, err := db.Get([]byte("ke")); err == nil {
+			t.Fatalf("found key prefix")
+		}
+		if err := db.Delete([]byte("key")); err != nil {
+			t.Fatalf("failed to delete: %v", err)
+		}
+		if ok, _ := db.Has([]byte("key")); ok {
+			t.Fatalf("deleted key present")
+		}
+		batch := db.NewBatch()
+		batch.Put([]byte("a"), []byte("1"))
+		batch.Put([]byte("b"), []byte("2"))
+		if ok, _ := db.Has([]byte("a")); ok {
+			t.Fatalf("batched key present before write")
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatalf("failed to write batch: %v", err)
+		}
+		for _, key := range []string{"a", "b"} {
+			if ok, _ := db.Has([]byte(key)); !ok {
+				t.Fatalf("batched key %q missing", key)
+			}
+		}
+	})
+}
+
+func TestDatabaseBatch(t *testing.T) {
+	testDatabases(t, func(t *testing.T, db Database) {
+		db.Put([]byte("a"), []byte("1"))
+		db.Put([]byte("b"), []byte("2"))
+
+		batch := db.NewBatch()
+		batch.Put([]byte("c"), []byte("3"))
+		batch.Delete([]byte("a"))
+		batch.Put([]byte("b"), []byte("4"))
+		batch.Delete([]byte("b"))
+		if ok, _ := db.Has([]byte("a")); !ok {
+			t.Fatalf("batched deletion applied before write")
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatalf("failed to write batch: %v", err)
+		}
+		for key, want := range map[string]bool{"a": false, "b": false, "c": true} {
+			if ok, _ := db.Has([]byte(key)); ok != want {
+				t.Errorf("key %q presence mismatch: have %v, want %v", key, ok, want)
+			}
+		}
+		// A reset batch must not write its discarded operations
+		batch.Reset()
+		if size := batch.ValueSize(); size != 0 {
+			t.Errorf("reset batch size mismatch: have %d, want 0", size)
+		}
+		batch.Delete([]byte("c"))
+		batch.Reset()
+		batch.Put([]byte("d"), []byte("5"))
+		if err := batch.Write(); err != nil {
+			t.Fatalf("failed to write batch: %v", err)
+		}
+		for key, want := range map[string]bool{"c": true, "d": true} {
+			if ok, _ := db.Has([]byte(key)); ok != want {
+				t.Errorf("key %q presence mismatch after reset: have %v, want %v", key, ok, want)
+			}
+		}
+	})
+}
+
+func TestDatabaseIterator(t *testing.T) {
+	testDatabases(t, func(t *testing.T, db Database) {
+		// Insert more keys than a BoltDB iterator chunk, in random order
+		var keys []string
+		for i := 0; i < 2*boltIteratorChunk+10; i++ {
+			keys = append(keys, fmt.Sprintf("a%05d", i))
+		}
+		for i := len(keys) - 1; i >= 0; i-- {
+			db.Put([]byte(keys[i]), []byte("v"+keys[i]))
+		}
+		db.Put([]byte("b"), []byte("vb"))
+		db.Put([]byte("c0"), []byte("vc0"))
+
+		tests := []struct {
+			prefix, start string
+			want          []string
+		}{
+			{"", "", append(append([]string{}, keys...), "b", "c0")},
+			{"a", "", keys},
+			{"a", "01000", keys[1000:]},
+			{"a", "020", keys[2000:]},
+			{"", "b", []string{"b", "c0"}},
+			{"c", "", []string{"c0"}},
+			{"c", "1", nil},
+			{"d", "", nil},
+		}
+		for i, tt := range tests {
+			var have []string
+			it := db.NewIterator([]byte(tt.prefix), []byte(tt.start))
+			for it.Next() {
+				if value := string(it.Value()); value != "v"+string(it.Key()) {
+					t.Errorf("test %d: key %q value mismatch: have %q", i, it.Key(), value)
+				}
+				have = append(have, string(it.Key()))
+			}
+			if err := it.Error(); err != nil {
+				t.Errorf("test %d: iterator failed: %v", i, err)
+			}
+			it.Release()
+
+			if len(have) != len(tt.want) {
+				t.Errorf("test %d: key count mismatch: have %d, want %d", i, len(have), len(tt.want))
+				continue
+			}
+			for j := range have {
+				if have[j] != tt.want[j] {
+					t.Errorf("test %d: key %d mismatch: have %q, want %q", i, j, have[j], tt.want[j])
+					break
+				}
+			}
+		}
+		if err := db.Compact(nil, nil); err != nil {
+			t.Errorf("failed to compact: %v", err)
+		}
+	})
+}
+
+func TestDatabaseReverseIterator(t *testing.T) {
+	testDatabases(t, func(t *testing.T, db Database) {
+		// Insert more keys than a BoltDB iterator chunk, in random order
+		var keys []string
+		for i := 0; i < 2*boltIteratorChunk+10; i++ {
+			keys = append(keys, fmt.Sprintf("a%05d", i))
+		}
+		for i := len(keys) - 1; i >= 0; i-- {
+			db.Put([]byte(keys[i]), []byte("v"+keys[i]))
+		}
+		db.Put([]byte("b"), []byte("vb"))
+		db.Put([]byte("c0"), []byte("vc0"))
+
+		reversed := func(keys []string) []string {
+			out := make([]string, len(keys))
+			for i, key := range keys {
+				out[len(keys)-1-i] = key
+			}
+			return out
+		}
+		tests := []struct {
+			prefix, start string
+			want          []string
+		}{
+			{"", "", append([]string{"c0", "b"}, reversed(keys)...)},
+			{"a", "", reversed(keys)},
+			{"a", "01000", reversed(keys[:1000])},
+			{"a", "020", reversed(keys[:2000])},
+			{"", "b", reversed(keys)},
+			{"c", "", []string{"c0"}},
+			{"c", "0", nil},
+			{"d", "", nil},
+		}
+		for i, tt := range tests {
+			var have []string
+			it := db.NewReverseIterator([]byte(tt.prefix), []byte(tt.start))
+			for it.Next() {
+				if value := string(it.Value()); value != "v"+string(it.Key()) {
+					t.Errorf("test %d: key %q value mismatch: have %q", i, it.Key(), value)
+				}
+				have = append(have, string(it.Key()))
+			}
+			if err := it.Error(); err != nil {
+				t.Errorf("test %d: iterator failed: %v", i, err)
+			}
+			it.Release()
+
+			if len(have) != len(tt.want) {
+				t.Errorf("test %d: key count mismatch: have %d, want %d", i, len(have), len(tt.want))
+				continue
+			}
+			for j := range have {
+				if have[j] != tt.want[j] {
+					t.Errorf("test %d: key %d mismatch: have %q, want %q", i, j, have[j], tt.want[j])
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestDatabaseEngineMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDatabase(EngineLevelDB, filepath.Join(dir, "chaindata"), 16, 16)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.Close()
+
+	if _, err := NewDatabase(EngineBoltDB, filepath.Join(dir, "chaindata"), 16, 16); err == nil {
+		t.Fatalf("opened LevelDB database with BoltDB")
+	}
+	if db, err = NewDatabase("", filepath.Join(dir, "chaindata"), 16, 16); err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	db.Close()
+	if _, err := NewDatabase("rocksdb", filepath.Join(dir, "other"), 16, 16); err == nil {
+		t.Fatalf("opened database with unknown engine")
+	}
+}
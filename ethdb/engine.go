@@ -0,0 +1,59 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Storage engines of the persistent databases.
+const (
+	EngineLevelDB = "leveldb"
+	EngineBoltDB  = "boltdb"
+)
+
+// NewDatabase opens (or creates) the database in the given directory with the
+// named storage engine, LevelDB if empty. The cache and handles allowances
+// only apply to LevelDB. Opening a directory written by the other engine is
+// refused, rather than silently starting over with an empty database.
+func NewDatabase(engine string, file string, cache int, handles int) (Database, error) {
+	if existing := detectEngine(file); existing != "" && engine != "" && existing != engine {
+		return nil, fmt.Errorf("database %s uses storage engine %q, not %q", file, existing, engine)
+	}
+	switch engine {
+	case "", EngineLevelDB:
+		return NewLDBDatabase(file, cache, handles)
+	case EngineBoltDB:
+		return NewBoltDatabase(file)
+	default:
+		return nil, fmt.Errorf("unknown database engine %q, want %q or %q", engine, EngineLevelDB, EngineBoltDB)
+	}
+}
+
+// detectEngine returns the storage engine of an existing database directory,
+// or an empty string if there is none.
+func detectEngine(file string) string {
+	if _, err := os.Stat(filepath.Join(file, "CURRENT")); err == nil {
+		return EngineLevelDB
+	}
+	if _, err := os.Stat(filepath.Join(file, boltFile)); err == nil {
+		return EngineBoltDB
+	}
+	return ""
+}
@@ -0,0 +1,213 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+)
+
+// errUnknownTable is returned if the user attempts to read from a table that is
+// not tracked by the freezer.
+var errUnknownTable = errors.New("unknown table")
+
+// Freezer is an append-only store of immutable items, grouped into tables of
+// the same kind (e.g. headers, bodies), which are all extended together: item
+// n of every table belongs to the same entity (e.g. block n). It keeps the
+// bulk of the old chain data out of the key-value database, where it would
+// only add to the compaction overhead.
+type Freezer struct {
+	frozen uint64 // Number of items already frozen in every table (atomic, keep first for alignment)
+
+	tables map[string]*freezerTable // Data tables for storing everything
+	lock   sync.Mutex               // Mutex serializing appends and truncations
+}
+
+// NewFreezer opens the freezer tables in the given directory, creating them if
+// needed. The tables map holds the kinds of the tables and whether their items
+// should be snappy compressed. Tables of different lengths, left by a crash
+// during an append, are truncated to the shortest one.
+func NewFreezer(dir string, tables map[string]bool) (*Freezer, error) {
+	freezer := &Freezer{
+		tables: make(map[string]*freezerTable),
+	}
+	for name, compress := range tables {
+		table, err := newTable(dir, name, compress)
+		if err != nil {
+			freezer.Close()
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	glog.V(logger.Info).Infof("Opened ancient database %s with %d items", dir, freezer.frozen)
+	return freezer, nil
+}
+
+// repair truncates all tables to the same length.
+func (f *Freezer) repair() error {
+	min := ^uint64(0)
+	for _, table := range f.tables {
+		if items := table.Items(); items < min {
+			min = items
+		}
+	}
+	if len(f.tables) == 0 {
+		min = 0
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *Freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if _, ok := f.tables[kind]; !ok {
+		return false, errUnknownTable
+	}
+	return number < atomic.LoadUint64(&f.frozen), nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *Freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	table, ok := f.tables[kind]
+	if !ok {
+		return nil, errUnknownTable
+	}
+	if number >= atomic.LoadUint64(&f.frozen) {
+		return nil, errOutOfBounds
+	}
+	return table.Retrieve(number)
+}
+
+// Ancients returns the number of items frozen in the ancient store.
+func (f *Freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AppendAncient injects the items of a new entity into every table of the
+// freezer. The number must be the next one in line and every table must be
+// given an item. If any table fails, all of them are rolled back.
+func (f *Freezer) AppendAncient(number uint64, items map[string][]byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if frozen := atomic.LoadUint64(&f.frozen); number != frozen {
+		return fmt.Errorf("%v: appending item %d, expected %d", errOutOrderInsertion, number, frozen)
+	}
+	for name := range f.tables {
+		if _, ok := items[name]; !ok {
+			return fmt.Errorf("missing %s item for ancient %d", name, number)
+		}
+	}
+	for name, table := range f.tables {
+		if err := table.Append(number, items[name]); err != nil {
+			for _, table := range f.tables {
+				if err := table.truncate(number); err != nil {
+					glog.V(logger.Error).Errorf("Failed to roll back ancient table: %v", err)
+				}
+			}
+			return fmt.Errorf("failed to append ancient %s %d: %v", name, number, err)
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// TruncateAncients discards any recent items above the provided threshold
+// number.
+func (f *Freezer) TruncateAncients(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes all the tables to disk.
+func (f *Freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// Close terminates the freezer, closing all the data files.
+func (f *Freezer) Close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// FreezerDatabase is a key-value database extended with a freezer holding its
+// immutable ancient data.
+type FreezerDatabase struct {
+	Database
+	*Freezer
+}
+
+// NewDatabaseWithFreezer opens a freezer in the given directory and attaches
+// it to the key-value database.
+func NewDatabaseWithFreezer(db Database, dir string, tables map[string]bool) (*FreezerDatabase, error) {
+	freezer, err := NewFreezer(dir, tables)
+	if err != nil {
+		return nil, err
+	}
+	return &FreezerDatabase{Database: db, Freezer: freezer}, nil
+}
+
+// Close closes both the freezer and the key-value database.
+func (db *FreezerDatabase) Close() {
+	if err := db.Freezer.Close(); err != nil {
+		glog.V(logger.Error).Errorf("Failed to close ancient database: %v", err)
+	}
+	db.Database.Close()
+}
@@ -0,0 +1,282 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+)
+
+var (
+	// errOutOfBounds is returned if the item requested is not contained within
+	// the freezer table.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+
+	// errClosed is returned if an operation attempts to read from or write to
+	// the freezer table after it has already been closed.
+	errClosed = errors.New("closed")
+)
+
+// indexEntrySize is the size of an index entry, the big endian end offset of
+// an item in the data file.
+const indexEntrySize = 8
+
+// freezerTable is an append-only table of binary blobs, numbered from zero.
+// The blobs are stored back to back in a data file, optionally snappy
+// compressed, and an index file holds the end offset of each of them. The
+// index starts with a zero entry, so item i spans the data between the index
+// entries i and i+1.
+type freezerTable struct {
+	items    uint64 // Number of items stored in the table (atomic, keep first for alignment)
+	compress bool   // Whether the blobs are snappy compressed
+
+	index *os.File // File descriptor of the item end offsets
+	data  *os.File // File descriptor of the item data
+	size  uint64   // Size of the data file, i.e. the end offset of the last item
+
+	lock sync.RWMutex // Mutex protecting the files and the size
+}
+
+// newTable opens a freezer table, creating the data and index files if they
+// don't exist yet, and repairs any inconsistency between them left by a crash.
+func newTable(dir string, name string, compress bool) (*freezerTable, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	idxName, datName := name+".ridx", name+".rdat"
+	if compress {
+		idxName, datName = name+".cidx", name+".cdat"
+	}
+	index, err := os.OpenFile(filepath.Join(dir, idxName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.OpenFile(filepath.Join(dir, datName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	tab := &freezerTable{
+		compress: compress,
+		index:    index,
+		data:     data,
+	}
+	if err := tab.repair(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	return tab, nil
+}
+
+// repair cross checks the index and data files, truncating them to the last
+// item fully written to both.
+func (t *freezerTable) repair() error {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	// Ensure the index contains at least the initial zero entry and only
+	// whole entries
+	indexSize := stat.Size()
+	if indexSize < indexEntrySize {
+		if _, err := t.index.WriteAt(make([]byte, indexEntrySize), 0); err != nil {
+			return err
+		}
+		indexSize = indexEntrySize
+	}
+	indexSize -= indexSize % indexEntrySize
+	if err := t.index.Truncate(indexSize); err != nil {
+		return err
+	}
+	if stat, err = t.data.Stat(); err != nil {
+		return err
+	}
+	dataSize := uint64(stat.Size())
+
+	// Drop the index entries pointing past the end of the data, which may
+	// only happen if the data file was damaged
+	for {
+		end, err := t.offset(uint64(indexSize/indexEntrySize) - 1)
+		if err != nil {
+			return err
+		}
+		if end <= dataSize {
+			dataSize = end
+			break
+		}
+		indexSize -= indexEntrySize
+		if err := t.index.Truncate(indexSize); err != nil {
+			return err
+		}
+	}
+	// Drop any data written after the last indexed item
+	if err := t.data.Truncate(int64(dataSize)); err != nil {
+		return err
+	}
+	t.size = dataSize
+	atomic.StoreUint64(&t.items, uint64(indexSize/indexEntrySize)-1)
+
+	return nil
+}
+
+// offset retrieves the index entry at the given position.
+func (t *freezerTable) offset(entry uint64) (uint64, error) {
+	buf := make([]byte, indexEntrySize)
+	if _, err := t.index.ReadAt(buf, int64(entry*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// Items returns the number of items stored in the table.
+func (t *freezerTable) Items() uint64 {
+	return atomic.LoadUint64(&t.items)
+}
+
+// Append injects a binary blob at the end of the table. The item number must
+// be the next one in line, otherwise errOutOrderInsertion is returned.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if items := atomic.LoadUint64(&t.items); item != items {
+		return fmt.Errorf("%v: appending item %d, expected %d", errOutOrderInsertion, item, items)
+	}
+	if t.compress {
+		blob = snappy.Encode(nil, blob)
+	}
+	// Write the data before the index entry, so a crash in between leaves only
+	// unindexed data which the repair on open drops
+	if _, err := t.data.WriteAt(blob, int64(t.size)); err != nil {
+		return err
+	}
+	end := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint64(end, t.size+uint64(len(blob)))
+	if _, err := t.index.WriteAt(end, int64((item+1)*indexEntrySize)); err != nil {
+		return err
+	}
+	t.size += uint64(len(blob))
+	atomic.AddUint64(&t.items, 1)
+	return nil
+}
+
+// Retrieve looks up the data offsets of an item and returns its blob.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return nil, errClosed
+	}
+	if item >= atomic.LoadUint64(&t.items) {
+		return nil, errOutOfBounds
+	}
+	buf := make([]byte, 2*indexEntrySize)
+	if _, err := t.index.ReadAt(buf, int64(item*indexEntrySize)); err != nil {
+		return nil, err
+	}
+	start, end := binary.BigEndian.Uint64(buf), binary.BigEndian.Uint64(buf[indexEntrySize:])
+	if start > end || end > t.size {
+		return nil, fmt.Errorf("corrupted index entry for item %d: [%d, %d)", item, start, end)
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.data.ReadAt(blob, int64(start)); err != nil {
+		return nil, err
+	}
+	if t.compress {
+		return snappy.Decode(nil, blob)
+	}
+	return blob, nil
+}
+
+// truncate discards any items above the given count.
+func (t *freezerTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if atomic.LoadUint64(&t.items) <= items {
+		return nil
+	}
+	end, err := t.offset(items)
+	if err != nil {
+		return err
+	}
+	if err := t.index.Truncate(int64((items + 1) * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.size = end
+	atomic.StoreUint64(&t.items, items)
+	return nil
+}
+
+// Sync pushes any pending data from memory out to disk.
+func (t *freezerTable) Sync() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+// Close closes all opened files.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	if t.index != nil {
+		if err := t.index.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.index = nil
+	}
+	if t.data != nil {
+		if err := t.data.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.data = nil
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
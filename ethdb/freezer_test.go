@@ -0,0 +1,180 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testTables = map[string]bool{"raw": false, "compressed": true}
+
+// testItem returns the deterministic test blob of the given table and number.
+func testItem(kind string, number uint64) []byte {
+	return bytes.Repeat([]byte(fmt.Sprintf("%s-%d;", kind, number)), int(number%7)+1)
+}
+
+func appendTestItems(t *testing.T, f *Freezer, from, to uint64) {
+	for i := from; i < to; i++ {
+		items := make(map[string][]byte)
+		for kind := range testTables {
+			items[kind] = testItem(kind, i)
+		}
+		if err := f.AppendAncient(i, items); err != nil {
+			t.Fatalf("failed to append item %d: %v", i, err)
+		}
+	}
+}
+
+func checkTestItems(t *testing.T, f *Freezer, items uint64) {
+	if have, _ := f.Ancients(); have != items {
+		t.Fatalf("item count mismatch: have %d, want %d", have, items)
+	}
+	for i := uint64(0); i < items; i++ {
+		for kind := range testTables {
+			blob, err := f.Ancient(kind, i)
+			if err != nil {
+				t.Fatalf("failed to retrieve %s item %d: %v", kind, i, err)
+			}
+			if want := testItem(kind, i); !bytes.Equal(blob, want) {
+				t.Fatalf("%s item %d mismatch: have %q, want %q", kind, i, blob, want)
+			}
+		}
+	}
+	for kind := range testTables {
+		if _, err := f.Ancient(kind, items); err != errOutOfBounds {
+			t.Fatalf("%s item %d: error mismatch: have %v, want %v", kind, items, err, errOutOfBounds)
+		}
+	}
+}
+
+// Tests that items appended to the freezer can be retrieved, also after it was
+// reopened, and that only in-order appends are accepted.
+func TestFreezerAppendRetrieve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := NewFreezer(dir, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestItems(t, f, 0, 100)
+	checkTestItems(t, f, 100)
+
+	if err := f.AppendAncient(101, map[string][]byte{"raw": nil, "compressed": nil}); err == nil {
+		t.Fatal("out of order append succeeded")
+	}
+	if err := f.AppendAncient(100, map[string][]byte{"raw": nil}); err == nil {
+		t.Fatal("append with missing table succeeded")
+	}
+	if _, err := f.Ancient("unknown", 0); err != errUnknownTable {
+		t.Fatalf("unknown table error mismatch: have %v, want %v", err, errUnknownTable)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = NewFreezer(dir, testTables); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	checkTestItems(t, f, 100)
+	appendTestItems(t, f, 100, 150)
+	checkTestItems(t, f, 150)
+}
+
+// Tests that truncating the freezer drops the items above the limit and new
+// items can be appended in their place.
+func TestFreezerTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := NewFreezer(dir, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	appendTestItems(t, f, 0, 50)
+	if err := f.TruncateAncients(20); err != nil {
+		t.Fatal(err)
+	}
+	checkTestItems(t, f, 20)
+
+	// Truncating above the item count is a noop
+	if err := f.TruncateAncients(30); err != nil {
+		t.Fatal(err)
+	}
+	checkTestItems(t, f, 20)
+
+	appendTestItems(t, f, 20, 40)
+	checkTestItems(t, f, 40)
+}
+
+// Tests that tables left inconsistent by a crash are repaired on open.
+func TestFreezerRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := NewFreezer(dir, testTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTestItems(t, f, 0, 30)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Append an extra item to one table only, as if the others failed
+	table, err := newTable(dir, "raw", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Append(30, testItem("raw", 30)); err != nil {
+		t.Fatal(err)
+	}
+	table.Close()
+
+	// Chop off half of the last item of the other table
+	stat, err := os.Stat(filepath.Join(dir, "compressed.cdat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filepath.Join(dir, "compressed.cdat"), stat.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = NewFreezer(dir, testTables); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	checkTestItems(t, f, 29)
+	appendTestItems(t, f, 29, 35)
+	checkTestItems(t, f, 35)
+}
@@ -25,17 +25,101 @@ type Putter interface {
 	Put(key []byte, value []byte) error
 }
 
+// Deleter wraps the database delete operation supported by both batches and regular databases.
+type Deleter interface {
+	Delete(key []byte) error
+}
+
 type Database interface {
 	Putter
+	Deleter
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
-	Delete(key []byte) error
 	Close()
 	NewBatch() Batch
+
+	// NewIterator creates an iterator over the entries whose keys start with
+	// prefix, in ascending key order, beginning at prefix+start (or the first
+	// key after it, if it does not exist).
+	NewIterator(prefix []byte, start []byte) Iterator
+
+	// NewReverseIterator creates an iterator over the entries whose keys start
+	// with prefix, in descending key order, beginning at the last key before
+	// prefix+start (the last key with the prefix if start is empty).
+	NewReverseIterator(prefix []byte, start []byte) Iterator
+
+	// Stat returns a particular internal stat of the database.
+	Stat(property string) (string, error)
+
+	// Compact flattens the underlying data store for the given key range.
+	// A nil start is treated as a key before all keys in the data store; a nil
+	// limit is treated as a key after all keys in the data store.
+	Compact(start []byte, limit []byte) error
+}
+
+// Iterator iterates over a database's key/value pairs in key order.
+// The key and value slices are only valid until the next call to Next, and
+// the iterator must be released after use.
+type Iterator interface {
+	// Next moves the iterator to the next key/value pair. It returns false once
+	// the iterator is exhausted.
+	Next() bool
+
+	// Error returns any accumulated error. Exhausting all the key/value pairs
+	// is not considered to be an error.
+	Error() error
+
+	// Key returns the key of the current key/value pair.
+	Key() []byte
+
+	// Value returns the value of the current key/value pair.
+	Value() []byte
+
+	// Release releases associated resources.
+	Release()
 }
 
+// Batch is a write-only database that commits its changes to the host database
+// atomically when Write is called. A batch cannot be used concurrently.
 type Batch interface {
 	Putter
+	Deleter
 	ValueSize() int // amount of data in the batch
 	Write() error
+	// Reset resets the batch for reuse
+	Reset()
+}
+
+// AncientReader contains the methods required to read from immutable ancient
+// data, stored by kind and item number.
+type AncientReader interface {
+	// HasAncient returns an indicator whether the specified data exists in the
+	// ancient store.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of items frozen in the ancient store.
+	Ancients() (uint64, error)
+}
+
+// AncientWriter contains the methods required to write to immutable ancient data.
+type AncientWriter interface {
+	// AppendAncient injects the items of every kind of a new entity into the
+	// ancient store.
+	AppendAncient(number uint64, items map[string][]byte) error
+
+	// TruncateAncients discards all but the first n ancient items.
+	TruncateAncients(n uint64) error
+
+	// Sync flushes all in-memory ancient store data to disk.
+	Sync() error
+}
+
+// AncientStore contains all the methods required to read from and write to
+// immutable ancient data.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
 }
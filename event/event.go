@@ -33,15 +33,25 @@ type Event struct {
 
 // Subscription is implemented by event subscriptions.
 type Subscription interface {
-	// Chan returns a channel that carries events.
-	// Implementations should return the same channel
-	// for any subsequent calls to Chan.
-	Chan() <-chan *Event
-
 	// Unsubscribe stops delivery of events to a subscription.
 	// The event channel is closed.
 	// Unsubscribe can be called more than once.
 	Unsubscribe()
+
+	// Err returns a channel that carries the error which ended the
+	// subscription, if any. The channel is closed on Unsubscribe.
+	Err() <-chan error
+}
+
+// TypeMuxSubscription is a subscription to a TypeMux, delivering its events
+// through a channel owned by the subscription.
+type TypeMuxSubscription interface {
+	Subscription
+
+	// Chan returns a channel that carries events.
+	// Implementations should return the same channel
+	// for any subsequent calls to Chan.
+	Chan() <-chan *Event
 }
 
 // A TypeMux dispatches events to registered receivers. Receivers can be
@@ -61,7 +71,7 @@ var ErrMuxClosed = errors.New("event: mux closed")
 // Subscribe creates a subscription for events of the given types. The
 // subscription's channel is closed when it is unsubscribed
 // or the mux is closed.
-func (mux *TypeMux) Subscribe(types ...interface{}) Subscription {
+func (mux *TypeMux) Subscribe(types ...interface{}) TypeMuxSubscription {
 	sub := newsub(mux)
 	mux.mutex.Lock()
 	defer mux.mutex.Unlock()
@@ -161,6 +171,7 @@ type muxsub struct {
 	closeMu sync.Mutex
 	closing chan struct{}
 	closed  bool
+	err     chan error
 
 	// these two are the same channel. they are stored separately so
 	// postC can be set to nil without affecting the return value of
@@ -178,6 +189,7 @@ func newsub(mux *TypeMux) *muxsub {
 		readC:   c,
 		postC:   c,
 		closing: make(chan struct{}),
+		err:     make(chan error),
 	}
 }
 
@@ -185,6 +197,10 @@ func (s *muxsub) Chan() <-chan *Event {
 	return s.readC
 }
 
+func (s *muxsub) Err() <-chan error {
+	return s.err
+}
+
 func (s *muxsub) Unsubscribe() {
 	s.mux.del(s)
 	s.closewait()
@@ -197,6 +213,7 @@ func (s *muxsub) closewait() {
 		return
 	}
 	close(s.closing)
+	close(s.err)
 	s.closed = true
 
 	s.postMu.Lock()
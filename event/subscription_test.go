@@ -0,0 +1,103 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errProducer = errors.New("producer failed")
+
+func subscribeInts(max, fail int, c chan<- int) Subscription {
+	return NewSubscription(func(quit <-chan struct{}) error {
+		for i := 0; i < max; i++ {
+			if i >= fail {
+				return errProducer
+			}
+			select {
+			case c <- i:
+			case <-quit:
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func TestNewSubscriptionError(t *testing.T) {
+	t.Parallel()
+
+	channel := make(chan int)
+	sub := subscribeInts(10, 2, channel)
+loop:
+	for want := 0; ; want++ {
+		select {
+		case got := <-channel:
+			if got != want {
+				t.Fatalf("wrong int %d, want %d", got, want)
+			}
+		case err := <-sub.Err():
+			if err != errProducer {
+				t.Fatalf("wrong error: got %q, want %q", err, errProducer)
+			}
+			if want != 2 {
+				t.Fatalf("got errProducer at %d, should be at 2", want)
+			}
+			break loop
+		}
+	}
+	sub.Unsubscribe()
+
+	err, ok := <-sub.Err()
+	if err != nil {
+		t.Fatal("got non-nil error after Unsubscribe")
+	}
+	if ok {
+		t.Fatal("channel still open after Unsubscribe")
+	}
+}
+
+func TestNewSubscriptionUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	channel := make(chan int)
+	sub := subscribeInts(10, 10, channel)
+	<-channel
+	sub.Unsubscribe()
+	sub.Unsubscribe() // double unsubscribe is a noop
+
+	select {
+	case err, ok := <-sub.Err():
+		if err != nil || ok {
+			t.Fatalf("error channel not closed cleanly: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error channel not closed after Unsubscribe")
+	}
+}
+
+func TestMuxSubscriptionErr(t *testing.T) {
+	mux := new(TypeMux)
+	sub := mux.Subscribe(testEvent(0))
+	sub.Unsubscribe()
+
+	if _, ok := <-sub.Err(); ok {
+		t.Fatal("error channel still open after Unsubscribe")
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// maxQueryDepth is the deepest nesting of selections a query may have, which
+// bounds the work of queries walking long parent chains.
+const maxQueryDepth = 20
+
+var errTimeout = errors.New("query execution timed out")
+
+// object is an instance of a GraphQL object type, resolving its own fields.
+type object interface {
+	// typeName returns the name of the object's GraphQL type.
+	typeName() string
+
+	// resolve returns the value of a field: an object, a list of objects
+	// ([]object) or a JSON encodable scalar. Unknown fields are reported with
+	// errUnknownField.
+	resolve(ctx context.Context, field string, args arguments) (interface{}, error)
+}
+
+var errUnknownField = errors.New("unknown field")
+
+// Request is a GraphQL request, as sent in the body of POST requests.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of a GraphQL request. Data is missing if the request
+// failed before the execution, eg. on syntax or validation errors, and null if
+// a non-null field of the query root resolved to null.
+type Response struct {
+	Data   interface{}   `json:"data,omitempty"`
+	Errors []*QueryError `json:"errors,omitempty"`
+}
+
+// QueryError is an error of a GraphQL request, located by the response path
+// of the field it occurred on, if any.
+type QueryError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+// resultMap is a JSON object of field results, in the order of the selection.
+type resultMap struct {
+	keys   []string
+	values []interface{}
+}
+
+func (m *resultMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// execute validates the query of the request against the schema, and runs it
+// against the root object unless its estimated cost exceeds the budget.
+func execute(ctx context.Context, s *schema, root object, req *Request, maxCost int) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*QueryError{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*QueryError{{Message: err.Error()}}}
+	}
+	if op.kind != "query" {
+		return &Response{Errors: []*QueryError{{Message: fmt.Sprintf("%s operations are not supported", op.kind)}}}
+	}
+	depth, err := doc.depth(op.sel, make(map[string]int))
+	if err != nil {
+		return &Response{Errors: []*QueryError{{Message: err.Error()}}}
+	}
+	if depth > maxQueryDepth {
+		return &Response{Errors: []*QueryError{{Message: fmt.Sprintf("query depth %d exceeds the maximum of %d", depth, maxQueryDepth)}}}
+	}
+	v, err := validate(s, doc, op, req.Variables)
+	if err != nil {
+		return &Response{Errors: []*QueryError{{Message: err.Error()}}}
+	}
+	v.budget = maxCost
+	if v.cost(s.types[s.query], op.sel, nil, 1); v.spent > maxCost {
+		return &Response{Errors: []*QueryError{{Message: fmt.Sprintf("query cost exceeds the maximum of %d", maxCost)}}}
+	}
+	e := &executor{ctx: ctx, schema: s, doc: doc, args: v.args, skipped: v.skipped}
+	data, ok := e.object(root, op.sel, nil)
+	if !ok {
+		data = json.RawMessage("null")
+	}
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// operation selects the operation to execute from the document.
+func (doc *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("operation name required for documents with several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// depth returns the nesting depth of a selection set, through the fragments
+// it spreads. The depths of the fragments are kept in frags, with -1 marking
+// those being measured: fragments spreading themselves are rejected, as they
+// would recurse for as long as the data does.
+func (doc *document) depth(sel []selection, frags map[string]int) (int, error) {
+	var max int
+	for _, s := range sel {
+		var (
+			d   int
+			err error
+		)
+		switch s := s.(type) {
+		case *field:
+			if len(s.sel) > 0 {
+				d, err = doc.depth(s.sel, frags)
+				d++
+			}
+		case *inlineFragment:
+			d, err = doc.depth(s.sel, frags)
+		case *fragmentSpread:
+			var known bool
+			if d, known = frags[s.name]; !known {
+				frag := doc.fragments[s.name]
+				if frag == nil {
+					return 0, fmt.Errorf("unknown fragment %q", s.name)
+				}
+				frags[s.name] = -1
+				if d, err = doc.depth(frag.sel, frags); err == nil {
+					frags[s.name] = d
+				}
+			} else if d < 0 {
+				return 0, fmt.Errorf("fragment %q spreads itself", s.name)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max, nil
+}
+
+// fieldGroup is the fields of a selection sharing a response key, which are
+// merged into a single result.
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// collectFields gathers the fields selected on an object of the given type by
+// response key, expanding the fragments that apply to it. Skipped selections
+// are left out.
+func collectFields(doc *document, skipped map[selection]bool, typeName string, sel []selection) []*fieldGroup {
+	var (
+		groups  []*fieldGroup
+		visited = make(map[string]bool)
+		collect func(sel []selection)
+	)
+	collect = func(sel []selection) {
+		for _, s := range sel {
+			if skipped[s] {
+				continue
+			}
+			switch s := s.(type) {
+			case *field:
+				var group *fieldGroup
+				for _, g := range groups {
+					if g.key == s.key() {
+						group = g
+						break
+					}
+				}
+				if group == nil {
+					group = &fieldGroup{key: s.key()}
+					groups = append(groups, group)
+				}
+				group.fields = append(group.fields, s)
+
+			case *fragmentSpread:
+				frag := doc.fragments[s.name]
+				if frag == nil || visited[s.name] {
+					continue
+				}
+				visited[s.name] = true
+				if frag.on == typeName {
+					collect(frag.sel)
+				}
+
+			case *inlineFragment:
+				if s.on == "" || s.on == typeName {
+					collect(s.sel)
+				}
+			}
+		}
+	}
+	collect(sel)
+	return groups
+}
+
+// executor executes a single validated operation, collecting the field errors.
+type executor struct {
+	ctx     context.Context
+	schema  *schema
+	doc     *document
+	args    map[*field]arguments // Coerced field arguments
+	skipped map[selection]bool   // Selections excluded by their directives
+	errors  []*QueryError
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, &QueryError{Message: err.Error(), Path: path})
+}
+
+// object resolves the selected fields of an object. It reports false if a
+// non-null field resolved to null, nulling the object.
+func (e *executor) object(obj object, sel []selection, path []interface{}) (interface{}, bool) {
+	t := e.schema.types[obj.typeName()]
+	res := new(resultMap)
+	for _, group := range collectFields(e.doc, e.skipped, t.name, sel) {
+		f := group.fields[0]
+		def := e.schema.field(t, f.name)
+		fieldPath := append(path[:len(path):len(path)], group.key)
+
+		val, err := e.resolve(obj, f)
+		if err == errUnknownField {
+			err = fmt.Errorf("field %q of type %q is not implemented", f.name, t.name)
+		}
+		if err != nil {
+			e.fail(fieldPath, err)
+			if def.typ.nonNull {
+				return nil, false
+			}
+			val = nil
+		}
+		var subsel []selection
+		for _, f := range group.fields {
+			subsel = append(subsel, f.sel...)
+		}
+		completed, ok := e.complete(def.typ, val, subsel, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		res.keys = append(res.keys, group.key)
+		res.values = append(res.values, completed)
+	}
+	return res, true
+}
+
+// resolve resolves a field of an object, answering the meta fields from the
+// schema.
+func (e *executor) resolve(obj object, f *field) (interface{}, error) {
+	switch f.name {
+	case typenameField.name:
+		return obj.typeName(), nil
+	case schemaField.name:
+		return &schemaObject{s: e.schema}, nil
+	case typeField.name:
+		name, _ := e.args[f].str("name")
+		return newTypeObject(e.schema, name), nil
+	}
+	if err := e.ctx.Err(); err != nil {
+		if err == context.DeadlineExceeded {
+			err = errTimeout
+		}
+		return nil, err
+	}
+	return obj.resolve(e.ctx, f.name, e.args[f])
+}
+
+// complete completes the resolved value of a field of the given type with its
+// selection. It reports false if the value is null despite the type being
+// non-null, in which case the null propagates to the nearest nullable field.
+func (e *executor) complete(typ *typeRef, val interface{}, sel []selection, path []interface{}) (interface{}, bool) {
+	if isNull(val) {
+		if typ.nonNull {
+			e.fail(path, fmt.Errorf("cannot return null for non-null type %s", typ))
+			return nil, false
+		}
+		return nil, true
+	}
+	ok := true
+	switch {
+	case typ.elem != nil:
+		objs, isObjects := val.([]object)
+		if !isObjects {
+			// Lists of scalars are returned as they are, but never as null.
+			if v := reflect.ValueOf(val); v.Kind() == reflect.Slice && v.IsNil() {
+				val = []interface{}{}
+			}
+			break
+		}
+		list := make([]interface{}, len(objs))
+		for i, item := range objs {
+			if list[i], ok = e.complete(typ.elem, item, sel, append(path[:len(path):len(path)], i)); !ok {
+				break
+			}
+		}
+		val = list
+	default:
+		if obj, isObject := val.(object); isObject {
+			val, ok = e.object(obj, sel, path)
+		}
+	}
+	if !ok {
+		return nil, !typ.nonNull
+	}
+	return val, true
+}
+
+// isNull reports whether a resolved value is null, including typed nils.
+func isNull(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	v := reflect.ValueOf(val)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
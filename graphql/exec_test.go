@@ -0,0 +1,137 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testSchema = mustParseSchema(introspectionSchema + `
+	schema { query: Query }
+	type Query {
+		item: Item
+		strict: Item!
+		items: [Item!]
+		strictItems: [Item!]!
+	}
+	type Item {
+		name: String!
+		nick: String
+		tags: [String!]!
+		child: Item
+	}
+`)
+
+// testItem is an object of the test schema, resolving the fields to the
+// values of the map.
+type testItem map[string]interface{}
+
+func (i testItem) typeName() string { return "Item" }
+
+func (i testItem) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	return i[field], nil
+}
+
+// testQuery is the root of the test schema.
+type testQuery map[string]interface{}
+
+func (q testQuery) typeName() string { return "Query" }
+
+func (q testQuery) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	return q[field], nil
+}
+
+// executeJSON executes a query against the root, returning the JSON encoded
+// response.
+func executeJSON(t *testing.T, root object, query string, maxCost int) string {
+	res := execute(context.Background(), testSchema, root, &Request{Query: query}, maxCost)
+	blob, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(blob)
+}
+
+func TestExecuteNonNull(t *testing.T) {
+	good := testItem{"name": "a", "tags": []string{"x"}}
+	unnamed := testItem{"nick": "b", "tags": []string{}}
+	root := testQuery{
+		"item":        testItem{"name": "c", "child": unnamed, "tags": []string(nil)},
+		"strict":      unnamed,
+		"items":       []object{good, nil},
+		"strictItems": []object{good},
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// Nulls of non-null fields propagate to the nearest nullable field.
+		{
+			`{ item { name child { nick name } } }`,
+			`{"data":{"item":{"name":"c","child":null}},"errors":[{"message":"cannot return null for non-null type String!","path":["item","child","name"]}]}`,
+		},
+		// Null items of non-null lists null the list.
+		{
+			`{ items { name } }`,
+			`{"data":{"items":null},"errors":[{"message":"cannot return null for non-null type Item!","path":["items",1]}]}`,
+		},
+		// Nulls propagating to the root null the data.
+		{
+			`{ strictItems { name } strict { name } }`,
+			`{"data":null,"errors":[{"message":"cannot return null for non-null type String!","path":["strict","name"]}]}`,
+		},
+		// Nil lists of scalars are empty lists.
+		{
+			`{ strictItems { tags } item { tags } }`,
+			`{"data":{"strictItems":[{"tags":["x"]}],"item":{"tags":[]}}}`,
+		},
+	}
+	for _, tt := range tests {
+		if got := executeJSON(t, root, tt.query, DefaultMaxCost); got != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteCost(t *testing.T) {
+	root := testQuery{"items": []object{}, "item": testItem{"name": "a", "tags": []string{}}}
+	tests := []struct {
+		query string
+		cost  int
+	}{
+		{`{ item { name } }`, 2},
+		{`{ item { name name __typename } }`, 3},
+		// List fields multiply the cost of their selection.
+		{`{ items { name } }`, 1 + defaultListSize},
+		{`{ items { name child { name } } }`, 1 + 3*defaultListSize},
+		{`{ items @skip(if: true) { name } item { name } }`, 2},
+		// Fragments count once per selection.
+		{`{ item { ...F ...F } } fragment F on Item { name nick }`, 3},
+		{`{ __schema { types { name } } }`, 2 + len(testSchema.names)},
+	}
+	for _, tt := range tests {
+		if res := executeJSON(t, root, tt.query, tt.cost); strings.Contains(res, "cost") {
+			t.Errorf("%s: rejected at cost %d: %s", tt.query, tt.cost, res)
+		}
+		if res := executeJSON(t, root, tt.query, tt.cost-1); !strings.Contains(res, "query cost exceeds the maximum") {
+			t.Errorf("%s: accepted at cost %d: %s", tt.query, tt.cost-1, res)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// introspectionSchema defines the built-in scalars and directives, and the
+// types of the introspection queries.
+const introspectionSchema = `
+# The Int scalar type represents a signed 32 bit integer.
+scalar Int
+# The Float scalar type represents a double precision floating point number.
+scalar Float
+# The String scalar type represents UTF-8 text.
+scalar String
+# The Boolean scalar type represents true or false.
+scalar Boolean
+# The ID scalar type represents a unique identifier, serialized as a string.
+scalar ID
+
+# Skip excludes the selection if the argument is true.
+directive @skip(if: Boolean!) on FIELD | FRAGMENT_SPREAD | INLINE_FRAGMENT
+# Include only includes the selection if the argument is true.
+directive @include(if: Boolean!) on FIELD | FRAGMENT_SPREAD | INLINE_FRAGMENT
+
+type __Schema {
+    types: [__Type!]!
+    queryType: __Type!
+    mutationType: __Type
+    subscriptionType: __Type
+    directives: [__Directive!]!
+}
+
+type __Type {
+    kind: __TypeKind!
+    name: String
+    description: String
+    fields(includeDeprecated: Boolean = false): [__Field!]
+    interfaces: [__Type!]
+    possibleTypes: [__Type!]
+    enumValues(includeDeprecated: Boolean = false): [__EnumValue!]
+    inputFields: [__InputValue!]
+    ofType: __Type
+}
+
+type __Field {
+    name: String!
+    description: String
+    args: [__InputValue!]!
+    type: __Type!
+    isDeprecated: Boolean!
+    deprecationReason: String
+}
+
+type __InputValue {
+    name: String!
+    description: String
+    type: __Type!
+    defaultValue: String
+}
+
+type __EnumValue {
+    name: String!
+    description: String
+    isDeprecated: Boolean!
+    deprecationReason: String
+}
+
+enum __TypeKind {
+    SCALAR
+    OBJECT
+    INTERFACE
+    UNION
+    ENUM
+    INPUT_OBJECT
+    LIST
+    NON_NULL
+}
+
+type __Directive {
+    name: String!
+    description: String
+    locations: [__DirectiveLocation!]!
+    args: [__InputValue!]!
+}
+
+enum __DirectiveLocation {
+    QUERY
+    MUTATION
+    SUBSCRIPTION
+    FIELD
+    FRAGMENT_DEFINITION
+    FRAGMENT_SPREAD
+    INLINE_FRAGMENT
+    SCHEMA
+    SCALAR
+    OBJECT
+    FIELD_DEFINITION
+    ARGUMENT_DEFINITION
+    INTERFACE
+    UNION
+    ENUM
+    ENUM_VALUE
+    INPUT_OBJECT
+    INPUT_FIELD_DEFINITION
+}
+`
+
+// description returns a description for the introspection, null if empty.
+func description(desc string) interface{} {
+	if desc == "" {
+		return nil
+	}
+	return desc
+}
+
+// schemaObject is the __Schema of the introspection queries.
+type schemaObject struct {
+	s *schema
+}
+
+func (o *schemaObject) typeName() string { return "__Schema" }
+
+func (o *schemaObject) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "types":
+		types := make([]object, len(o.s.names))
+		for i, name := range o.s.names {
+			types[i] = newTypeObject(o.s, name)
+		}
+		return types, nil
+	case "queryType":
+		return newTypeObject(o.s, o.s.query), nil
+	case "mutationType", "subscriptionType":
+		return nil, nil
+	case "directives":
+		dirs := make([]object, len(o.s.directives))
+		for i, d := range o.s.directives {
+			dirs[i] = &directiveObject{s: o.s, def: d}
+		}
+		return dirs, nil
+	}
+	return nil, errUnknownField
+}
+
+// typeObject is a __Type, describing a named type or a list or non-null
+// wrapper of a type.
+type typeObject struct {
+	s   *schema
+	ref *typeRef
+}
+
+// newTypeObject returns the named type, or a typed nil which resolves to null
+// if there is no such type.
+func newTypeObject(s *schema, name string) *typeObject {
+	if s.types[name] == nil {
+		return nil
+	}
+	return &typeObject{s: s, ref: &typeRef{name: name}}
+}
+
+func (o *typeObject) typeName() string { return "__Type" }
+
+func (o *typeObject) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	// Wrapping types only have a kind and the type they wrap.
+	if o.ref.nonNull || o.ref.elem != nil {
+		switch field {
+		case "kind":
+			if o.ref.nonNull {
+				return "NON_NULL", nil
+			}
+			return "LIST", nil
+		case "ofType":
+			if o.ref.nonNull {
+				inner := *o.ref
+				inner.nonNull = false
+				return &typeObject{s: o.s, ref: &inner}, nil
+			}
+			return &typeObject{s: o.s, ref: o.ref.elem}, nil
+		}
+		return nil, nil
+	}
+	t := o.s.types[o.ref.name]
+	switch field {
+	case "kind":
+		return t.kind, nil
+	case "name":
+		return t.name, nil
+	case "description":
+		return description(t.desc), nil
+	case "fields":
+		if t.kind != "OBJECT" {
+			return nil, nil
+		}
+		fields := make([]object, len(t.fields))
+		for i, f := range t.fields {
+			fields[i] = &fieldObject{s: o.s, def: f}
+		}
+		return fields, nil
+	case "interfaces":
+		if t.kind != "OBJECT" {
+			return nil, nil
+		}
+		return []object{}, nil
+	case "enumValues":
+		if t.kind != "ENUM" {
+			return nil, nil
+		}
+		values := make([]object, len(t.values))
+		for i, v := range t.values {
+			values[i] = &enumValueObject{def: v}
+		}
+		return values, nil
+	case "inputFields":
+		if t.kind != "INPUT_OBJECT" {
+			return nil, nil
+		}
+		return newInputValueObjects(o.s, t.inputs), nil
+	case "possibleTypes", "ofType":
+		return nil, nil
+	}
+	return nil, errUnknownField
+}
+
+// fieldObject is a __Field, describing a field of an object type.
+type fieldObject struct {
+	s   *schema
+	def *fieldDef
+}
+
+func (o *fieldObject) typeName() string { return "__Field" }
+
+func (o *fieldObject) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "name":
+		return o.def.name, nil
+	case "description":
+		return description(o.def.desc), nil
+	case "args":
+		return newInputValueObjects(o.s, o.def.args), nil
+	case "type":
+		return &typeObject{s: o.s, ref: o.def.typ}, nil
+	case "isDeprecated":
+		return false, nil
+	case "deprecationReason":
+		return nil, nil
+	}
+	return nil, errUnknownField
+}
+
+// inputValueObject is an __InputValue, describing an argument or a field of
+// an input object.
+type inputValueObject struct {
+	s   *schema
+	def *inputValue
+}
+
+func newInputValueObjects(s *schema, list []*inputValue) []object {
+	objs := make([]object, len(list))
+	for i, in := range list {
+		objs[i] = &inputValueObject{s: s, def: in}
+	}
+	return objs
+}
+
+func (o *inputValueObject) typeName() string { return "__InputValue" }
+
+func (o *inputValueObject) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "name":
+		return o.def.name, nil
+	case "description":
+		return description(o.def.desc), nil
+	case "type":
+		return &typeObject{s: o.s, ref: o.def.typ}, nil
+	case "defaultValue":
+		if o.def.def == nil {
+			return nil, nil
+		}
+		return formatValue(o.def.def), nil
+	}
+	return nil, errUnknownField
+}
+
+// enumValueObject is an __EnumValue, describing a value of an enum type.
+type enumValueObject struct {
+	def *enumValue
+}
+
+func (o *enumValueObject) typeName() string { return "__EnumValue" }
+
+func (o *enumValueObject) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "name":
+		return o.def.name, nil
+	case "description":
+		return description(o.def.desc), nil
+	case "isDeprecated":
+		return false, nil
+	case "deprecationReason":
+		return nil, nil
+	}
+	return nil, errUnknownField
+}
+
+// directiveObject is a __Directive, describing a directive.
+type directiveObject struct {
+	s   *schema
+	def *directiveDef
+}
+
+func (o *directiveObject) typeName() string { return "__Directive" }
+
+func (o *directiveObject) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "name":
+		return o.def.name, nil
+	case "description":
+		return description(o.def.desc), nil
+	case "locations":
+		return o.def.locations, nil
+	case "args":
+		return newInputValueObjects(o.s, o.def.args), nil
+	}
+	return nil, errUnknownField
+}
+
+// formatValue formats a parsed input value as a GraphQL value literal.
+func formatValue(val interface{}) string {
+	switch val := val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(val)
+	case json.Number:
+		return string(val)
+	case string:
+		s, _ := json.Marshal(val)
+		return string(s)
+	case []interface{}:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = formatValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]string, len(names))
+		for i, name := range names {
+			fields[i] = name + ": " + formatValue(val[name])
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return ""
+}
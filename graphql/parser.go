@@ -0,0 +1,567 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL query document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query of the document. Mutations and subscriptions are parsed,
+// but not executed.
+type operation struct {
+	kind string // "query", "mutation" or "subscription"
+	name string
+	vars []*variableDef
+	dirs []*directive
+	sel  []selection
+}
+
+// variableDef declares a variable of an operation.
+type variableDef struct {
+	name string
+	typ  *typeRef
+	def  interface{} // Default value, nil if none
+}
+
+// selection is a field, a fragment spread or an inline fragment.
+type selection interface{}
+
+type field struct {
+	alias string
+	name  string
+	args  []*argument
+	dirs  []*directive
+	sel   []selection
+}
+
+// key returns the name of the field in the response.
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name string
+	dirs []*directive
+}
+
+type inlineFragment struct {
+	on   string // Type condition, empty if none
+	dirs []*directive
+	sel  []selection
+}
+
+type fragment struct {
+	name string
+	on   string
+	dirs []*directive
+	sel  []selection
+}
+
+type argument struct {
+	name string
+	val  interface{}
+}
+
+type directive struct {
+	name string
+	args []*argument
+}
+
+// Values are parsed into the types JSON variables decode to: strings (also for
+// enum values), json.Number, bool, nil, []interface{} and map[string]interface{},
+// plus variable references.
+type variable string
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokNumber
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+	desc string // Comment lines preceding the token
+}
+
+// syntaxError is raised by the parser, and recovered into an error by parse.
+type syntaxError struct {
+	pos int
+	msg string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.pos, e.msg)
+}
+
+// parser is a recursive descent parser of GraphQL query documents.
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// parse parses a GraphQL query document.
+func parse(src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			serr, ok := r.(*syntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, serr
+		}
+	}()
+	p := &parser{src: strings.TrimPrefix(src, "\ufeff")}
+	p.advance()
+
+	doc = &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek(tokPunct, "{"):
+			doc.operations = append(doc.operations, &operation{kind: "query", sel: p.selectionSet()})
+		case p.peek(tokName, "query"), p.peek(tokName, "mutation"), p.peek(tokName, "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.peek(tokName, "fragment"):
+			p.advance()
+			frag := &fragment{name: p.name()}
+			if frag.name == "on" {
+				p.fail("invalid fragment name")
+			}
+			p.expectName("on")
+			frag.on = p.name()
+			frag.dirs = p.directives()
+			frag.sel = p.selectionSet()
+			if doc.fragments[frag.name] != nil {
+				p.fail(fmt.Sprintf("duplicate fragment %q", frag.name))
+			}
+			doc.fragments[frag.name] = frag
+		default:
+			p.fail(fmt.Sprintf("unexpected %q", p.tok.val))
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &syntaxError{pos: p.pos, msg: "no operation in document"}
+	}
+	return doc, nil
+}
+
+// parseSchema parses a schema definition document. The descriptions of the
+// definitions are taken from the comments preceding them.
+func parseSchema(src string) (s *schema, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			serr, ok := r.(*syntaxError)
+			if !ok {
+				panic(r)
+			}
+			s, err = nil, serr
+		}
+	}()
+	p := &parser{src: src}
+	p.advance()
+
+	s = &schema{types: make(map[string]*typeDef)}
+	for p.tok.kind != tokEOF {
+		desc := p.tok.desc
+		switch kw := p.name(); kw {
+		case "schema":
+			p.expect("{")
+			for !p.skip("}") {
+				if op := p.name(); op != "query" {
+					p.fail(fmt.Sprintf("%s operations are not supported", op))
+				}
+				p.expect(":")
+				s.query = p.name()
+			}
+		case "scalar", "type", "input", "enum":
+			t := &typeDef{desc: desc}
+			t.name = p.name()
+			switch kw {
+			case "scalar":
+				t.kind = "SCALAR"
+			case "type":
+				t.kind = "OBJECT"
+				p.expect("{")
+				for !p.skip("}") {
+					f := &fieldDef{desc: p.tok.desc}
+					f.name = p.name()
+					if p.skip("(") {
+						f.args = p.inputValues(")")
+					}
+					p.expect(":")
+					f.typ = p.typeRef()
+					t.fields = append(t.fields, f)
+				}
+			case "input":
+				t.kind = "INPUT_OBJECT"
+				p.expect("{")
+				t.inputs = p.inputValues("}")
+			case "enum":
+				t.kind = "ENUM"
+				p.expect("{")
+				for !p.skip("}") {
+					value := &enumValue{desc: p.tok.desc}
+					value.name = p.name()
+					t.values = append(t.values, value)
+				}
+			}
+			if s.types[t.name] != nil {
+				p.fail(fmt.Sprintf("duplicate type %q", t.name))
+			}
+			s.types[t.name] = t
+			s.names = append(s.names, t.name)
+		case "directive":
+			p.expect("@")
+			d := &directiveDef{name: p.name(), desc: desc}
+			if p.skip("(") {
+				d.args = p.inputValues(")")
+			}
+			p.expectName("on")
+			p.skip("|")
+			d.locations = append(d.locations, p.name())
+			for p.skip("|") {
+				d.locations = append(d.locations, p.name())
+			}
+			s.directives = append(s.directives, d)
+		default:
+			p.fail(fmt.Sprintf("unexpected %q", kw))
+		}
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// inputValues parses argument or input field definitions, up to the closing
+// punctuator.
+func (p *parser) inputValues(end string) []*inputValue {
+	var list []*inputValue
+	for !p.skip(end) {
+		in := &inputValue{desc: p.tok.desc}
+		in.name = p.name()
+		p.expect(":")
+		in.typ = p.typeRef()
+		if p.skip("=") {
+			in.def = p.value(true)
+		}
+		list = append(list, in)
+	}
+	return list
+}
+
+func (p *parser) fail(msg string) {
+	panic(&syntaxError{pos: p.tok.pos, msg: msg})
+}
+
+func (p *parser) peek(kind tokenKind, val string) bool {
+	return p.tok.kind == kind && p.tok.val == val
+}
+
+// skip advances over the punctuator if it is the current token.
+func (p *parser) skip(punct string) bool {
+	if p.peek(tokPunct, punct) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) {
+	if !p.skip(punct) {
+		p.fail(fmt.Sprintf("expected %q, found %q", punct, p.tok.val))
+	}
+}
+
+func (p *parser) expectName(name string) {
+	if !p.peek(tokName, name) {
+		p.fail(fmt.Sprintf("expected %q, found %q", name, p.tok.val))
+	}
+	p.advance()
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokName {
+		p.fail(fmt.Sprintf("expected name, found %q", p.tok.val))
+	}
+	name := p.tok.val
+	p.advance()
+	return name
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.name()}
+	if p.tok.kind == tokName {
+		op.name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			def := &variableDef{name: p.name()}
+			p.expect(":")
+			def.typ = p.typeRef()
+			if p.skip("=") {
+				def.def = p.value(true)
+			}
+			op.vars = append(op.vars, def)
+		}
+	}
+	op.dirs = p.directives()
+	op.sel = p.selectionSet()
+	return op
+}
+
+// typeRef parses a type reference.
+func (p *parser) typeRef() *typeRef {
+	var t *typeRef
+	if p.skip("[") {
+		t = &typeRef{elem: p.typeRef()}
+		p.expect("]")
+	} else {
+		t = &typeRef{name: p.name()}
+	}
+	t.nonNull = p.skip("!")
+	return t
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect("{")
+	var sel []selection
+	for !p.skip("}") {
+		if p.tok.kind == tokEOF {
+			p.fail("unterminated selection set")
+		}
+		sel = append(sel, p.selection())
+	}
+	if len(sel) == 0 {
+		p.fail("empty selection set")
+	}
+	return sel
+}
+
+func (p *parser) selection() selection {
+	if p.skip("...") {
+		if p.tok.kind == tokName && p.tok.val != "on" {
+			return &fragmentSpread{name: p.name(), dirs: p.directives()}
+		}
+		frag := new(inlineFragment)
+		if p.peek(tokName, "on") {
+			p.advance()
+			frag.on = p.name()
+		}
+		frag.dirs = p.directives()
+		frag.sel = p.selectionSet()
+		return frag
+	}
+	f := &field{name: p.name()}
+	if p.skip(":") {
+		f.alias, f.name = f.name, p.name()
+	}
+	f.args = p.arguments()
+	f.dirs = p.directives()
+	if p.peek(tokPunct, "{") {
+		f.sel = p.selectionSet()
+	}
+	return f
+}
+
+func (p *parser) arguments() []*argument {
+	var args []*argument
+	if p.skip("(") {
+		for !p.skip(")") {
+			arg := &argument{name: p.name()}
+			p.expect(":")
+			arg.val = p.value(false)
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+func (p *parser) directives() []*directive {
+	var dirs []*directive
+	for p.skip("@") {
+		dirs = append(dirs, &directive{name: p.name(), args: p.arguments()})
+	}
+	return dirs
+}
+
+// value parses an input value, refusing variables in constant contexts.
+func (p *parser) value(constant bool) interface{} {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		p.advance()
+		return json.Number(tok.val)
+	case tokString:
+		p.advance()
+		return tok.val
+	case tokName:
+		p.advance()
+		switch tok.val {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return tok.val
+	case tokPunct:
+		switch tok.val {
+		case "$":
+			if constant {
+				p.fail("unexpected variable")
+			}
+			p.advance()
+			return variable(p.name())
+		case "[":
+			p.advance()
+			list := []interface{}{}
+			for !p.skip("]") {
+				list = append(list, p.value(constant))
+			}
+			return list
+		case "{":
+			p.advance()
+			obj := make(map[string]interface{})
+			for !p.skip("}") {
+				name := p.name()
+				p.expect(":")
+				obj[name] = p.value(constant)
+			}
+			return obj
+		}
+	}
+	p.fail(fmt.Sprintf("unexpected %q", tok.val))
+	return nil
+}
+
+// advance lexes the next token.
+func (p *parser) advance() {
+	// Skip whitespace, commas and comments, keeping the comments as the
+	// description of the token they precede
+	var comments []string
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			start := p.pos + 1
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			comments = append(comments, strings.TrimSpace(p.src[start:p.pos]))
+		} else {
+			break
+		}
+	}
+	defer func() { p.tok.desc = strings.Join(comments, "\n") }()
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokPunct, val: "...", pos: start}
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokPunct, val: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, val: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.pos++
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || strings.IndexByte(".eE+-", p.src[p.pos]) >= 0) {
+			p.pos++
+		}
+		p.tok = token{kind: tokNumber, val: p.src[start:p.pos], pos: start}
+		if _, err := json.Number(p.tok.val).Float64(); err != nil {
+			p.fail(fmt.Sprintf("invalid number %q", p.tok.val))
+		}
+	case c == '"':
+		p.tok = token{kind: tokString, val: p.string(), pos: start}
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.tok = token{kind: tokPunct, val: string(r), pos: start}
+		p.fail(fmt.Sprintf("unexpected character %q", r))
+	}
+}
+
+// string lexes a quoted or block string.
+func (p *parser) string() string {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.fail("unterminated block string")
+		}
+		s := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		return strings.TrimSpace(s)
+	}
+	// Quoted strings share their escapes with JSON, except for \u surrogates
+	// which don't matter here.
+	end := p.pos + 1
+	for ; end < len(p.src) && p.src[end] != '"'; end++ {
+		if p.src[end] == '\\' {
+			end++
+		} else if p.src[end] == '\n' || p.src[end] == '\r' {
+			break
+		}
+	}
+	if end >= len(p.src) || p.src[end] != '"' {
+		p.fail("unterminated string")
+	}
+	var s string
+	if err := json.Unmarshal([]byte(p.src[p.pos:end+1]), &s); err != nil {
+		p.fail(fmt.Sprintf("invalid string: %v", err))
+	}
+	p.pos = end + 1
+	return s
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
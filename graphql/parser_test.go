@@ -0,0 +1,191 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# A query with everything
+		query Page($number: Long = 1, $hash: Bytes32!) {
+			head: block { ...BlockFields }
+			block(number: $number, hash: "0x00") @include(if: true) {
+				... on Block { hash }
+				logs(filter: {addresses: ["0x01", "0x02"], topics: [[], null]})
+			}
+		}
+		fragment BlockFields on Block { number, hash }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 1 || len(doc.fragments) != 1 {
+		t.Fatalf("got %d operations and %d fragments, want 1 and 1", len(doc.operations), len(doc.fragments))
+	}
+	op := doc.operations[0]
+	if op.kind != "query" || op.name != "Page" {
+		t.Errorf("operation = %s %s, want query Page", op.kind, op.name)
+	}
+	wantVars := []*variableDef{
+		{name: "number", typ: &typeRef{name: "Long"}, def: json.Number("1")},
+		{name: "hash", typ: &typeRef{name: "Bytes32", nonNull: true}},
+	}
+	if !reflect.DeepEqual(op.vars, wantVars) {
+		t.Errorf("variables mismatch: got %+v, want %+v", op.vars, wantVars)
+	}
+	if len(op.sel) != 2 {
+		t.Fatalf("got %d selections, want 2", len(op.sel))
+	}
+	head := op.sel[0].(*field)
+	if head.key() != "head" || head.name != "block" {
+		t.Errorf("aliased field: got key %q name %q", head.key(), head.name)
+	}
+	if spread, ok := head.sel[0].(*fragmentSpread); !ok || spread.name != "BlockFields" {
+		t.Errorf("fragment spread mismatch: %+v", head.sel[0])
+	}
+	block := op.sel[1].(*field)
+	wantArgs := []*argument{{name: "number", val: variable("number")}, {name: "hash", val: "0x00"}}
+	if !reflect.DeepEqual(block.args, wantArgs) {
+		t.Errorf("arguments mismatch: got %+v, want %+v", block.args, wantArgs)
+	}
+	if len(block.dirs) != 1 || block.dirs[0].name != "include" {
+		t.Errorf("directives mismatch: %+v", block.dirs)
+	}
+	if inline, ok := block.sel[0].(*inlineFragment); !ok || inline.on != "Block" {
+		t.Errorf("inline fragment mismatch: %+v", block.sel[0])
+	}
+	filter := block.sel[1].(*field).args[0].val
+	wantFilter := map[string]interface{}{
+		"addresses": []interface{}{"0x01", "0x02"},
+		"topics":    []interface{}{[]interface{}{}, nil},
+	}
+	if !reflect.DeepEqual(filter, wantFilter) {
+		t.Errorf("input object mismatch: got %#v, want %#v", filter, wantFilter)
+	}
+}
+
+func TestParseShorthand(t *testing.T) {
+	doc, err := parse(`{ block { number } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op := doc.operations[0]; op.kind != "query" || op.name != "" || len(op.sel) != 1 {
+		t.Errorf("shorthand query mismatch: %+v", op)
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	doc, err := parse(`{ a(s: "tab\té", b: """block "quoted" string""") }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.operations[0].sel[0].(*field).args
+	if args[0].val != "tab\té" {
+		t.Errorf("string = %q", args[0].val)
+	}
+	if args[1].val != `block "quoted" string` {
+		t.Errorf("block string = %q", args[1].val)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{``, "no operation"},
+		{`{ block `, "unterminated selection set"},
+		{`{ block(number: ) }`, "unexpected"},
+		{`query { block(number: 1.2.3) }`, "invalid number"},
+		{`{ block(hash: "0x00) }`, "unterminated string"},
+		{`fragment F on Block { number }`, "no operation"},
+		{`{ a } fragment F on Block { a } fragment F on Block { a }`, "duplicate fragment"},
+		{`{ a(x: $v) } `, ""},
+		{`query Q($v: Long = $w) { a }`, "unexpected"},
+	}
+	for _, tt := range tests {
+		_, err := parse(tt.query)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %v", tt.query, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%q: expected error containing %q", tt.query, tt.err)
+		} else if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: error %q doesn't contain %q", tt.query, err, tt.err)
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	s, err := parseSchema(`
+		schema { query: Query }
+		scalar String
+		# Query is the root.
+		type Query {
+			# Items lists the items.
+			items(first: Int = 20, filter: Filter): [Item!]!
+		}
+		input Filter { names: [String!] }
+		type Item { name: String }
+		enum Kind { A B }
+		directive @skip(if: Boolean!) on FIELD | INLINE_FRAGMENT
+		scalar Int
+		scalar Boolean
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := s.types[s.query]
+	if query == nil || query.name != "Query" || query.kind != "OBJECT" || query.desc != "Query is the root." {
+		t.Fatalf("query type mismatch: %+v", query)
+	}
+	items := query.field("items")
+	if items == nil || items.desc != "Items lists the items." || items.typ.String() != "[Item!]!" {
+		t.Fatalf("field mismatch: %+v", items)
+	}
+	if first := findInput(items.args, "first"); first == nil || first.typ.String() != "Int" || first.def != json.Number("20") {
+		t.Errorf("argument mismatch: %+v", first)
+	}
+	if filter := s.types["Filter"]; filter.kind != "INPUT_OBJECT" || len(filter.inputs) != 1 {
+		t.Errorf("input type mismatch: %+v", filter)
+	}
+	if kind := s.types["Kind"]; kind.kind != "ENUM" || len(kind.values) != 2 {
+		t.Errorf("enum type mismatch: %+v", kind)
+	}
+	if skip := s.directive("skip"); skip == nil || !reflect.DeepEqual(skip.locations, []string{"FIELD", "INLINE_FRAGMENT"}) {
+		t.Errorf("directive mismatch: %+v", skip)
+	}
+
+	// References to undefined types and misused input types are rejected.
+	for _, src := range []string{
+		`schema { query: Query } type Query { a: Missing }`,
+		`schema { query: Query } type Query { a: In } input In { b: Query }`,
+		`schema { query: Query } type Query { a: Query } type Query { b: Query }`,
+	} {
+		if _, err := parseSchema(src); err == nil {
+			t.Errorf("%q: expected error", src)
+		}
+	}
+}
@@ -0,0 +1,625 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/common/hexutil"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/state"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/eth/filters"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+)
+
+const (
+	maxBlockRange   = 1000 // Maximum number of blocks listed by a blocks query
+	defaultPageSize = 20   // Default number of transactions of an account history page
+	maxPageSize     = 1000 // Maximum number of transactions of an account history page
+)
+
+// Backend is the chain the GraphQL queries are resolved against. If it also
+// implements filters.BloomIndex, log queries use its bloom bits index.
+type Backend interface {
+	BlockChain() *core.BlockChain
+	ChainDb() ethdb.Database
+}
+
+// arguments are the values of the arguments of a field, coerced to the Go
+// values of their types by the validation. Missing optional arguments without
+// defaults are left out.
+type arguments map[string]interface{}
+
+// long returns an optional Int or Long argument.
+func (a arguments) long(name string) (int64, bool) {
+	n, ok := a[name].(int64)
+	return n, ok
+}
+
+// str returns an optional String argument.
+func (a arguments) str(name string) (string, bool) {
+	s, ok := a[name].(string)
+	return s, ok
+}
+
+// boolean returns an optional Boolean argument, false if missing.
+func (a arguments) boolean(name string) bool {
+	b, _ := a[name].(bool)
+	return b
+}
+
+// hash returns an optional Bytes32 argument.
+func (a arguments) hash(name string) (common.Hash, bool) {
+	h, ok := a[name].(common.Hash)
+	return h, ok
+}
+
+// address returns an optional Address argument.
+func (a arguments) address(name string) (common.Address, bool) {
+	addr, ok := a[name].(common.Address)
+	return addr, ok
+}
+
+// list returns an optional list argument.
+func (a arguments) list(name string) []interface{} {
+	list, _ := a[name].([]interface{})
+	return list
+}
+
+// toLong converts a number, or a decimal or hex string, to a Long.
+func toLong(v interface{}) (int64, error) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return 0, errors.New("not a number")
+	}
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+func toBytes(v interface{}, size int) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("not a hex string")
+	}
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex string %q: %v", s, err)
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("invalid hex string %q: want %d bytes", s, size)
+	}
+	return b, nil
+}
+
+func toHash(v interface{}) (common.Hash, error) {
+	b, err := toBytes(v, common.HashLength)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(b), nil
+}
+
+func toAddress(v interface{}) (common.Address, error) {
+	b, err := toBytes(v, common.AddressLength)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(b), nil
+}
+
+// bigInt formats a BigInt scalar.
+func bigInt(n *big.Int) interface{} {
+	if n == nil {
+		return nil
+	}
+	return (*hexutil.Big)(n)
+}
+
+// query is the root object of the queries.
+type query struct {
+	b Backend
+}
+
+func (q *query) typeName() string { return "Query" }
+
+func (q *query) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	bc := q.b.BlockChain()
+	switch field {
+	case "block":
+		number, hasNumber := args.long("number")
+		hash, hasHash := args.hash("hash")
+		switch {
+		case hasNumber && hasHash:
+			return nil, errors.New("only one of number and hash can be given")
+		case hasHash:
+			return newBlock(q.b, bc.GetBlock(hash)), nil
+		case hasNumber:
+			if number < 0 {
+				return nil, errors.New("block number must not be negative")
+			}
+			return newBlock(q.b, bc.GetBlockByNumber(uint64(number))), nil
+		default:
+			return newBlock(q.b, bc.CurrentBlock()), nil
+		}
+
+	case "blocks":
+		from, _ := args.long("from")
+		to, hasTo := args.long("to")
+		head := int64(bc.CurrentBlock().NumberU64())
+		if !hasTo || to > head {
+			to = head
+		}
+		if from < 0 {
+			return nil, errors.New("block number must not be negative")
+		}
+		if to-from >= maxBlockRange {
+			return nil, fmt.Errorf("block range exceeds the maximum of %d blocks", maxBlockRange)
+		}
+		var blocks []object
+		for n := from; n <= to; n++ {
+			blk := bc.GetBlockByNumber(uint64(n))
+			if blk == nil {
+				break
+			}
+			blocks = append(blocks, newBlock(q.b, blk))
+		}
+		return blocks, nil
+
+	case "transaction":
+		hash, _ := args.hash("hash")
+		return newTransaction(q.b, hash), nil
+
+	case "logs":
+		crit, _ := args["filter"].(map[string]interface{})
+		from, hasFrom := arguments(crit).long("fromBlock")
+		to, hasTo := arguments(crit).long("toBlock")
+		head := int64(bc.CurrentBlock().NumberU64())
+		if !hasFrom || from < 0 {
+			from = head
+		}
+		if !hasTo || to < 0 || to > head {
+			to = head
+		}
+		if to-from >= maxBlockRange {
+			return nil, fmt.Errorf("block range exceeds the maximum of %d blocks", maxBlockRange)
+		}
+		return filterLogs(q.b, from, to, crit), nil
+	}
+	return nil, errUnknownField
+}
+
+// filterLogs returns the logs of the block range matching the addresses and
+// topics of the filter criteria.
+func filterLogs(b Backend, from, to int64, crit arguments) []object {
+	var addresses []common.Address
+	for _, addr := range crit.list("addresses") {
+		addresses = append(addresses, addr.(common.Address))
+	}
+	var topics [][]common.Hash
+	for _, position := range crit.list("topics") {
+		list, _ := position.([]interface{})
+		var hashes []common.Hash
+		for _, hash := range list {
+			hashes = append(hashes, hash.(common.Hash))
+		}
+		topics = append(topics, hashes)
+	}
+	filter := filters.New(b.ChainDb())
+	filter.SetBeginBlock(from)
+	filter.SetEndBlock(to)
+	filter.SetAddresses(addresses)
+	filter.SetTopics(topics)
+	if index, ok := b.(filters.BloomIndex); ok {
+		filter.SetBloomIndex(index)
+	}
+	return newLogs(b, filter.Find())
+}
+
+// block is a block of the chain, or an ommer of which only the header is known.
+type block struct {
+	b      Backend
+	header *types.Header
+	block  *types.Block // Nil for ommers
+}
+
+// newBlock returns the block object, or a typed nil which resolves to null.
+func newBlock(b Backend, blk *types.Block) *block {
+	if blk == nil {
+		return nil
+	}
+	return &block{b: b, header: blk.Header(), block: blk}
+}
+
+func (blk *block) typeName() string { return "Block" }
+
+func (blk *block) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	h := blk.header
+	switch field {
+	case "number":
+		return h.Number.Uint64(), nil
+	case "hash":
+		return h.Hash(), nil
+	case "parent":
+		return newBlock(blk.b, blk.b.BlockChain().GetBlock(h.ParentHash)), nil
+	case "nonce":
+		return hexutil.Bytes(h.Nonce[:]), nil
+	case "transactionsRoot":
+		return h.TxHash, nil
+	case "stateRoot":
+		return h.Root, nil
+	case "receiptsRoot":
+		return h.ReceiptHash, nil
+	case "ommerHash":
+		return h.UncleHash, nil
+	case "miner":
+		return accountAt(blk.b, h.Coinbase, args)
+	case "extraData":
+		return hexutil.Bytes(h.Extra), nil
+	case "gasLimit":
+		return h.GasLimit.Uint64(), nil
+	case "gasUsed":
+		return h.GasUsed.Uint64(), nil
+	case "timestamp":
+		return bigInt(h.Time), nil
+	case "logsBloom":
+		return hexutil.Bytes(h.Bloom.Bytes()), nil
+	case "mixHash":
+		return h.MixDigest, nil
+	case "difficulty":
+		return bigInt(h.Difficulty), nil
+	case "totalDifficulty":
+		return bigInt(blk.b.BlockChain().GetTd(h.Hash())), nil
+	case "account":
+		addr, _ := args.address("address")
+		return &account{b: blk.b, address: addr, header: h}, nil
+	}
+
+	// The remaining fields need the block body, unknown for ommers.
+	if blk.block == nil {
+		switch field {
+		case "ommerCount", "ommers", "transactionCount", "transactions", "transactionAt", "logs":
+			return nil, nil
+		}
+		return nil, errUnknownField
+	}
+	switch field {
+	case "ommerCount":
+		return len(blk.block.Uncles()), nil
+	case "ommers":
+		ommers := make([]object, len(blk.block.Uncles()))
+		for i, uncle := range blk.block.Uncles() {
+			ommers[i] = &block{b: blk.b, header: uncle}
+		}
+		return ommers, nil
+	case "transactionCount":
+		return len(blk.block.Transactions()), nil
+	case "transactions":
+		txs := make([]object, len(blk.block.Transactions()))
+		for i, tx := range blk.block.Transactions() {
+			txs[i] = &transaction{b: blk.b, tx: tx, blockHash: h.Hash(), blockNumber: h.Number.Uint64(), index: uint64(i)}
+		}
+		return txs, nil
+	case "transactionAt":
+		index, ok := args.long("index")
+		txs := blk.block.Transactions()
+		if !ok || index < 0 || index >= int64(len(txs)) {
+			return (*transaction)(nil), nil
+		}
+		return &transaction{b: blk.b, tx: txs[index], blockHash: h.Hash(), blockNumber: h.Number.Uint64(), index: uint64(index)}, nil
+	case "logs":
+		crit, _ := args["filter"].(map[string]interface{})
+		number := int64(h.Number.Uint64())
+		return filterLogs(blk.b, number, number, crit), nil
+	}
+	return nil, errUnknownField
+}
+
+// transaction is a transaction included in the chain.
+type transaction struct {
+	b           Backend
+	tx          *types.Transaction
+	blockHash   common.Hash
+	blockNumber uint64
+	index       uint64
+
+	receipt *types.Receipt // Loaded on demand
+}
+
+// newTransaction looks up a transaction by hash, returning a typed nil which
+// resolves to null if it isn't in the chain.
+func newTransaction(b Backend, hash common.Hash) *transaction {
+	tx, blockHash, blockNumber, index := core.GetTransaction(b.ChainDb(), hash)
+	if tx == nil {
+		return nil
+	}
+	return &transaction{b: b, tx: tx, blockHash: blockHash, blockNumber: blockNumber, index: index}
+}
+
+func (t *transaction) getReceipt() (*types.Receipt, error) {
+	if t.receipt == nil {
+		if t.receipt = core.GetReceipt(t.b.ChainDb(), t.tx.Hash()); t.receipt == nil {
+			return nil, fmt.Errorf("receipt of transaction %x not found", t.tx.Hash())
+		}
+	}
+	return t.receipt, nil
+}
+
+func (t *transaction) typeName() string { return "Transaction" }
+
+func (t *transaction) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "hash":
+		return t.tx.Hash(), nil
+	case "nonce":
+		return t.tx.Nonce(), nil
+	case "index":
+		return t.index, nil
+	case "from":
+		var signer types.Signer = types.BasicSigner{}
+		if t.tx.Protected() {
+			signer = types.NewChainIdSigner(t.tx.ChainId())
+		}
+		from, err := types.Sender(signer, t.tx)
+		if err != nil {
+			return nil, err
+		}
+		return accountAt(t.b, from, args)
+	case "to":
+		if t.tx.To() == nil {
+			return (*account)(nil), nil
+		}
+		return accountAt(t.b, *t.tx.To(), args)
+	case "value":
+		return bigInt(t.tx.Value()), nil
+	case "gasPrice":
+		return bigInt(t.tx.GasPrice()), nil
+	case "gas":
+		return t.tx.Gas().Uint64(), nil
+	case "inputData":
+		return hexutil.Bytes(t.tx.Data()), nil
+	case "block":
+		return newBlock(t.b, t.b.BlockChain().GetBlock(t.blockHash)), nil
+	case "v", "r", "s":
+		v, r, s := t.tx.RawSignatureValues()
+		return bigInt(map[string]*big.Int{"v": v, "r": r, "s": s}[field]), nil
+	case "status":
+		receipt, err := t.getReceipt()
+		if err != nil {
+			return nil, err
+		}
+		if receipt.Status == types.TxStatusUnknown {
+			return nil, nil
+		}
+		return uint64(receipt.Status), nil
+	case "gasUsed":
+		receipt, err := t.getReceipt()
+		if err != nil {
+			return nil, err
+		}
+		return receipt.GasUsed.Uint64(), nil
+	case "cumulativeGasUsed":
+		receipt, err := t.getReceipt()
+		if err != nil {
+			return nil, err
+		}
+		return receipt.CumulativeGasUsed.Uint64(), nil
+	case "createdContract":
+		if t.tx.To() != nil {
+			return (*account)(nil), nil
+		}
+		receipt, err := t.getReceipt()
+		if err != nil {
+			return nil, err
+		}
+		return accountAt(t.b, receipt.ContractAddress, args)
+	case "logs":
+		receipt, err := t.getReceipt()
+		if err != nil {
+			return nil, err
+		}
+		logs := make(vm.Logs, len(receipt.Logs))
+		for i, l := range receipt.Logs {
+			// The receipts are stored without the derived fields of their logs.
+			cpy := *l
+			cpy.TxHash, cpy.TxIndex = t.tx.Hash(), uint(t.index)
+			cpy.BlockHash, cpy.BlockNumber = t.blockHash, t.blockNumber
+			logs[i] = &cpy
+		}
+		return newLogs(t.b, logs), nil
+	}
+	return nil, errUnknownField
+}
+
+// log is a log emitted by a transaction.
+type log struct {
+	b   Backend
+	log *vm.Log
+}
+
+func newLogs(b Backend, logs vm.Logs) []object {
+	objs := make([]object, len(logs))
+	for i, l := range logs {
+		objs[i] = &log{b: b, log: l}
+	}
+	return objs
+}
+
+func (l *log) typeName() string { return "Log" }
+
+func (l *log) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "index":
+		return l.log.Index, nil
+	case "account":
+		return accountAt(l.b, l.log.Address, args)
+	case "topics":
+		return l.log.Topics, nil
+	case "data":
+		return hexutil.Bytes(l.log.Data), nil
+	case "transaction":
+		return newTransaction(l.b, l.log.TxHash), nil
+	}
+	return nil, errUnknownField
+}
+
+// account is the state of an account at a block.
+type account struct {
+	b       Backend
+	address common.Address
+	header  *types.Header
+
+	state *state.StateDB // Loaded on demand
+}
+
+// accountAt returns the account at the block of the optional block argument,
+// or at the head of the chain.
+func accountAt(b Backend, address common.Address, args arguments) (*account, error) {
+	number, ok := args.long("block")
+	bc := b.BlockChain()
+	header := bc.CurrentBlock().Header()
+	if ok {
+		if number < 0 {
+			return nil, errors.New("block number must not be negative")
+		}
+		if header = bc.GetHeaderByNumber(uint64(number)); header == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+	}
+	return &account{b: b, address: address, header: header}, nil
+}
+
+func (a *account) getState() (*state.StateDB, error) {
+	if a.state == nil {
+		st, err := a.b.BlockChain().StateAt(a.header.Root)
+		if err != nil {
+			return nil, fmt.Errorf("state of block #%d not available", a.header.Number)
+		}
+		a.state = st
+	}
+	return a.state, nil
+}
+
+func (a *account) typeName() string { return "Account" }
+
+func (a *account) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "address":
+		return a.address, nil
+	case "history":
+		return newAccountHistory(a.b, a.address, args)
+	}
+	st, err := a.getState()
+	if err != nil {
+		return nil, err
+	}
+	switch field {
+	case "balance":
+		return bigInt(st.GetBalance(a.address)), nil
+	case "transactionCount":
+		return st.GetNonce(a.address), nil
+	case "code":
+		return hexutil.Bytes(st.GetCode(a.address)), nil
+	case "storage":
+		slot, _ := args.hash("slot")
+		return st.GetState(a.address, slot), nil
+	}
+	return nil, errUnknownField
+}
+
+// accountHistory is a page of the transactions of an account, as listed by
+// the address transaction index.
+type accountHistory struct {
+	b       Backend
+	address common.Address
+
+	from, to        uint64
+	direction, kind string
+
+	txs  []string
+	next string
+}
+
+func newAccountHistory(b Backend, address common.Address, args arguments) (*accountHistory, error) {
+	if b.BlockChain().GetAtxi() == nil {
+		return nil, errors.New("the address transaction index is not enabled")
+	}
+	h := &accountHistory{b: b, address: address}
+
+	first, ok := args.long("first")
+	if !ok {
+		first = defaultPageSize
+	}
+	if first <= 0 || first > maxPageSize {
+		return nil, fmt.Errorf("argument \"first\" must be between 1 and %d", maxPageSize)
+	}
+	after, _ := args.str("after")
+	h.direction, _ = args.str("direction")
+	h.kind, _ = args.str("kind")
+	for name, n := range map[string]*uint64{"fromBlock": &h.from, "toBlock": &h.to} {
+		v, _ := args.long(name)
+		if v < 0 {
+			return nil, fmt.Errorf("argument %q must not be negative", name)
+		}
+		*n = uint64(v)
+	}
+	reverse := args.boolean("reverse")
+	db := b.BlockChain().GetAtxi().Db
+	var err error
+	h.txs, h.next, err = core.GetAddrTxsPage(db, address, h.from, h.to, h.direction, h.kind, after, int(first), reverse)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *accountHistory) typeName() string { return "AccountHistory" }
+
+func (h *accountHistory) resolve(ctx context.Context, field string, args arguments) (interface{}, error) {
+	switch field {
+	case "transactions":
+		txs := make([]object, len(h.txs))
+		for i, hash := range h.txs {
+			txs[i] = newTransaction(h.b, common.HexToHash(hash))
+		}
+		return txs, nil
+	case "next":
+		if h.next == "" {
+			return nil, nil
+		}
+		return h.next, nil
+	case "count":
+		db := h.b.BlockChain().GetAtxi().Db
+		return core.CountAddrTxs(db, h.address, h.from, h.to, h.direction, h.kind)
+	}
+	return nil, errUnknownField
+}
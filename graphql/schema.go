@@ -0,0 +1,399 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import "fmt"
+
+// Schema is the GraphQL schema of the queries served, in the schema definition
+// language. The queries are validated against it, and the comments preceding
+// the definitions are their descriptions in the introspection.
+const Schema = `
+# Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
+scalar Bytes32
+# Address is a 20 byte Ethereum address, represented as 0x-prefixed hexadecimal.
+scalar Address
+# Bytes is an arbitrary length binary string, represented as 0x-prefixed hexadecimal.
+# An empty byte string is represented as '0x'.
+scalar Bytes
+# BigInt is a large integer, represented as 0x-prefixed hexadecimal.
+scalar BigInt
+# Long is a 64 bit unsigned integer. Input is accepted as a number or as a
+# decimal or 0x-prefixed hexadecimal string.
+scalar Long
+
+schema {
+    query: Query
+}
+
+type Query {
+    # Block fetches a block by number or by hash, or the head of the chain if
+    # neither is given.
+    block(number: Long, hash: Bytes32): Block
+    # Blocks returns the blocks between two numbers, inclusive. If to is
+    # omitted, the blocks up to the head of the chain are returned. At most
+    # 1000 blocks can be requested at once.
+    blocks(from: Long!, to: Long): [Block!]!
+    # Transaction returns a transaction of the chain by hash.
+    transaction(hash: Bytes32!): Transaction
+    # Logs returns the logs matching the filter criteria.
+    logs(filter: FilterCriteria!): [Log!]!
+}
+
+# FilterCriteria selects the logs of a block range of at most 1000 blocks.
+# Block numbers default to the head of the chain.
+input FilterCriteria {
+    fromBlock: Long
+    toBlock: Long
+    addresses: [Address!]
+    # Topics lists the topics matched at each position, an empty or null
+    # list matching any topic.
+    topics: [[Bytes32!]]
+}
+
+# BlockFilterCriteria selects the logs of a single block.
+input BlockFilterCriteria {
+    addresses: [Address!]
+    topics: [[Bytes32!]]
+}
+
+# Block is a block of the chain. The body of ommers isn't known, so their
+# body fields are null.
+type Block {
+    number: Long!
+    hash: Bytes32!
+    parent: Block
+    nonce: Bytes!
+    transactionsRoot: Bytes32!
+    transactionCount: Int
+    stateRoot: Bytes32!
+    receiptsRoot: Bytes32!
+    # Miner is the account the mining reward is credited to, at the given
+    # block or at the head of the chain.
+    miner(block: Long): Account!
+    extraData: Bytes!
+    gasLimit: Long!
+    gasUsed: Long!
+    timestamp: BigInt!
+    logsBloom: Bytes!
+    mixHash: Bytes32!
+    difficulty: BigInt!
+    totalDifficulty: BigInt!
+    ommerCount: Int
+    ommers: [Block]
+    ommerHash: Bytes32!
+    transactions: [Transaction!]
+    transactionAt(index: Int!): Transaction
+    logs(filter: BlockFilterCriteria!): [Log!]
+    # Account returns an account at the state of this block.
+    account(address: Address!): Account!
+}
+
+# Transaction is a transaction included in the chain. The fields returning
+# accounts take the block to look at the account state at, defaulting to the
+# head of the chain.
+type Transaction {
+    hash: Bytes32!
+    nonce: Long!
+    index: Int!
+    from(block: Long): Account!
+    # To is null for contract creations.
+    to(block: Long): Account
+    value: BigInt!
+    gasPrice: BigInt!
+    gas: Long!
+    inputData: Bytes!
+    block: Block
+    # Status is 1 for successful and 0 for failed transactions, null if the
+    # receipt predates the status field.
+    status: Long
+    gasUsed: Long!
+    cumulativeGasUsed: Long!
+    createdContract(block: Long): Account
+    logs: [Log!]!
+    r: BigInt!
+    s: BigInt!
+    v: BigInt!
+}
+
+type Log {
+    index: Int!
+    account(block: Long): Account!
+    topics: [Bytes32!]!
+    data: Bytes!
+    transaction: Transaction!
+}
+
+# Account is the state of an account at a block.
+type Account {
+    address: Address!
+    balance: BigInt!
+    transactionCount: Long!
+    code: Bytes!
+    storage(slot: Bytes32!): Bytes32!
+    # History lists the transactions of the account from the address
+    # transaction index, oldest first unless reverse is set. Pages are
+    # continued by passing the next cursor of a page as after argument.
+    # Direction is one of "both", "to" and "from", kind is one of "both",
+    # "standard" and "contract", or a combination of the s, c, i and t kinds.
+    history(first: Int = 20, after: String, direction: String, kind: String,
+        fromBlock: Long, toBlock: Long, reverse: Boolean = false): AccountHistory!
+}
+
+type AccountHistory {
+    transactions: [Transaction!]!
+    # Next is the cursor of the next page, null after the last page.
+    next: String
+    # Count is the number of transactions matching the history filter.
+    count: Long!
+}
+`
+
+// querySchema is the parsed Schema, along with the introspection types. The
+// queries are validated against it before their execution.
+var querySchema = mustParseSchema(introspectionSchema + Schema)
+
+// schema is a parsed GraphQL schema.
+type schema struct {
+	query      string // Name of the query root type
+	types      map[string]*typeDef
+	names      []string // Type names in definition order
+	directives []*directiveDef
+
+	// sizes are the average lengths of the introspection lists, estimating
+	// the cost of introspection queries.
+	sizes map[string]int
+}
+
+// typeDef is a named type of the schema.
+type typeDef struct {
+	kind   string // The __TypeKind: SCALAR, OBJECT, INPUT_OBJECT or ENUM
+	name   string
+	desc   string
+	fields []*fieldDef   // Fields of objects
+	inputs []*inputValue // Fields of input objects
+	values []*enumValue  // Values of enums
+}
+
+// leaf reports whether the values of the type are returned without a
+// selection of subfields.
+func (t *typeDef) leaf() bool {
+	return t.kind == "SCALAR" || t.kind == "ENUM"
+}
+
+// field returns the definition of a field of an object type, or nil if there
+// is none.
+func (t *typeDef) field(name string) *fieldDef {
+	for _, f := range t.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// fieldDef defines a field of an object type.
+type fieldDef struct {
+	name string
+	desc string
+	args []*inputValue
+	typ  *typeRef
+}
+
+// inputValue defines an argument of a field or directive, or a field of an
+// input object.
+type inputValue struct {
+	name string
+	desc string
+	typ  *typeRef
+	def  interface{} // Default value, nil if none
+}
+
+// findInput returns the named input value of the list, or nil if there is none.
+func findInput(list []*inputValue, name string) *inputValue {
+	for _, in := range list {
+		if in.name == name {
+			return in
+		}
+	}
+	return nil
+}
+
+// enumValue defines a value of an enum type.
+type enumValue struct {
+	name string
+	desc string
+}
+
+// directiveDef defines a directive and the locations it may be used at.
+type directiveDef struct {
+	name      string
+	desc      string
+	locations []string
+	args      []*inputValue
+}
+
+// typeRef references a named type or a list, either of which may be non-null.
+type typeRef struct {
+	name    string   // Named type, empty for lists
+	elem    *typeRef // Element type of lists
+	nonNull bool
+}
+
+// named returns the name of the type, or of the element type of lists.
+func (t *typeRef) named() string {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.name
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// Meta fields are defined on the types implicitly. Only the query root type
+// has __schema and __type.
+var (
+	typenameField = &fieldDef{name: "__typename", typ: &typeRef{name: "String", nonNull: true}}
+	schemaField   = &fieldDef{name: "__schema", typ: &typeRef{name: "__Schema", nonNull: true}}
+	typeField     = &fieldDef{
+		name: "__type",
+		args: []*inputValue{{name: "name", typ: &typeRef{name: "String", nonNull: true}}},
+		typ:  &typeRef{name: "__Type"},
+	}
+)
+
+// field returns the definition of a field selected on an object type,
+// including the meta fields, or nil if there is none.
+func (s *schema) field(t *typeDef, name string) *fieldDef {
+	switch {
+	case name == typenameField.name:
+		return typenameField
+	case name == schemaField.name && t.name == s.query:
+		return schemaField
+	case name == typeField.name && t.name == s.query:
+		return typeField
+	}
+	return t.field(name)
+}
+
+// directive returns the definition of a directive, or nil if there is none.
+func (s *schema) directive(name string) *directiveDef {
+	for _, d := range s.directives {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// check verifies that the types referenced by the schema are defined, and are
+// input or output types as their use requires.
+func (s *schema) check() error {
+	root := s.types[s.query]
+	if root == nil || root.kind != "OBJECT" {
+		return fmt.Errorf("query root type %q is not an object type", s.query)
+	}
+	ref := func(where string, t *typeRef, input bool) error {
+		def := s.types[t.named()]
+		switch {
+		case def == nil:
+			return fmt.Errorf("%s: unknown type %q", where, t.named())
+		case input && def.kind == "OBJECT":
+			return fmt.Errorf("%s: object type %q is not an input type", where, def.name)
+		case !input && def.kind == "INPUT_OBJECT":
+			return fmt.Errorf("%s: input type %q is not an output type", where, def.name)
+		}
+		return nil
+	}
+	for _, name := range s.names {
+		t := s.types[name]
+		for _, f := range t.fields {
+			if err := ref(name+"."+f.name, f.typ, false); err != nil {
+				return err
+			}
+			for _, arg := range f.args {
+				if err := ref(name+"."+f.name+"("+arg.name+")", arg.typ, true); err != nil {
+					return err
+				}
+			}
+		}
+		for _, in := range t.inputs {
+			if err := ref(name+"."+in.name, in.typ, true); err != nil {
+				return err
+			}
+		}
+	}
+	for _, d := range s.directives {
+		for _, arg := range d.args {
+			if err := ref("@"+d.name+"("+arg.name+")", arg.typ, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// averageSizes computes the average lengths of the introspection lists.
+func (s *schema) averageSizes() {
+	var fields, args, inputs, values, dirArgs int
+	for _, t := range s.types {
+		fields += len(t.fields)
+		inputs += len(t.inputs)
+		values += len(t.values)
+		for _, f := range t.fields {
+			args += len(f.args)
+		}
+	}
+	for _, d := range s.directives {
+		dirArgs += len(d.args)
+	}
+	average := func(n, count int) int {
+		if count == 0 {
+			return 0
+		}
+		return (n + count - 1) / count
+	}
+	s.sizes = map[string]int{
+		"__Schema.types":       len(s.types),
+		"__Schema.directives":  len(s.directives),
+		"__Type.fields":        average(fields, len(s.types)),
+		"__Type.inputFields":   average(inputs, len(s.types)),
+		"__Type.enumValues":    average(values, len(s.types)),
+		"__Type.interfaces":    0,
+		"__Type.possibleTypes": 0,
+		"__Field.args":         average(args, fields),
+		"__Directive.args":     average(dirArgs, len(s.directives)),
+	}
+}
+
+// mustParseSchema parses a schema definition, panicking on errors.
+func mustParseSchema(src string) *schema {
+	s, err := parseSchema(src)
+	if err != nil {
+		panic(fmt.Sprintf("invalid GraphQL schema: %v", err))
+	}
+	s.averageSizes()
+	return s
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package graphql implements a GraphQL endpoint serving the chain data, so that
+// a page of a block explorer can be fetched in a single query instead of a
+// round trip per block, receipt and account. See Schema for the queries
+// supported.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/node"
+	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/rpc"
+	"github.com/rs/cors"
+)
+
+const (
+	// maxRequestSize is the default maximum size of a request body.
+	maxRequestSize = 1024 * 128
+
+	// policyMethod is the method the queries are checked as by the access
+	// policy, allowed along with the "graphql" module.
+	policyMethod = "graphql_query"
+
+	// DefaultMaxCost is the default budget of the estimated cost of a query,
+	// in fields resolved.
+	DefaultMaxCost = 100000
+
+	// DefaultTimeout is the default time limit of the execution of a query.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Config is the configuration of the GraphQL service.
+type Config struct {
+	Endpoint string   // Address to listen on, host:port
+	CORS     string   // Comma separated list of allowed CORS domains
+	VHosts   []string // Allowed virtual hosts
+
+	// TLSCert and TLSKey are the PEM encoded certificate and private key files
+	// of the listener. TLS is served if both are set.
+	TLSCert string
+	TLSKey  string
+
+	// Policy restricts the queries as it does the calls of the HTTP RPC
+	// interface. Queries are checked as calls of the graphql_query method.
+	Policy rpc.Policy
+
+	MaxCost int           // Budget of the estimated cost of a query, DefaultMaxCost if zero
+	Timeout time.Duration // Time limit of the execution of a query, DefaultTimeout if zero
+}
+
+// Service is a node service exposing the GraphQL endpoint on its own HTTP
+// listener.
+type Service struct {
+	backend Backend
+	config  Config
+	cors    []string // Allowed CORS domains
+
+	listener net.Listener
+}
+
+// New creates a GraphQL service resolving the queries against the backend.
+func New(backend Backend, config Config) (*Service, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("no GraphQL endpoint given")
+	}
+	if config.MaxCost <= 0 {
+		config.MaxCost = DefaultMaxCost
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	var origins []string
+	for _, domain := range strings.Split(config.CORS, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			origins = append(origins, domain)
+		}
+	}
+	return &Service{backend: backend, config: config, cors: origins}, nil
+}
+
+// Protocols implements node.Service, returning no protocols.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning no RPC APIs.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting the GraphQL HTTP listener.
+func (s *Service) Start(server *p2p.Server) error {
+	listener, err := node.ListenTLS(s.config.Endpoint, s.config.TLSCert, s.config.TLSKey)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	// The policy limits the size of the request bodies.
+	h := rpc.NewPolicyHandler(s.config.Policy, policyMethod, &handler{backend: s.backend, maxCost: s.config.MaxCost, timeout: s.config.Timeout})
+	h = cors.New(cors.Options{
+		AllowedOrigins: s.cors,
+		AllowedMethods: []string{"POST", "GET"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         600,
+	}).Handler(h)
+
+	// The server timeouts bound the connections of slow clients, leaving the
+	// queries their time to execute and write the response.
+	srv := &http.Server{
+		Handler:      rpc.NewVirtualHostHandler(s.config.VHosts, h),
+		ReadTimeout:  s.config.Timeout,
+		WriteTimeout: 2 * s.config.Timeout,
+	}
+	go srv.Serve(listener)
+
+	glog.V(logger.Info).Infof("GraphQL endpoint opened: %s", s.url())
+	return nil
+}
+
+// Stop implements node.Service, closing the GraphQL HTTP listener.
+func (s *Service) Stop() error {
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+		glog.V(logger.Info).Infof("GraphQL endpoint closed: %s", s.url())
+	}
+	return nil
+}
+
+// url returns the URL of the endpoint.
+func (s *Service) url() string {
+	if s.config.TLSCert != "" {
+		return "https://" + s.config.Endpoint
+	}
+	return "http://" + s.config.Endpoint
+}
+
+// handler serves GraphQL queries over HTTP.
+type handler struct {
+	backend Backend
+	maxSize int64 // Maximum size of a request body, zero if limited by the policy
+	maxCost int
+	timeout time.Duration
+}
+
+// NewHandler returns an HTTP handler of GraphQL queries, with the default
+// limits. Queries are POSTed as JSON encoded Request, or sent in the query
+// parameters of GET requests.
+func NewHandler(backend Backend) http.Handler {
+	return &handler{backend: backend, maxSize: maxRequestSize, maxCost: DefaultMaxCost, timeout: DefaultTimeout}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case "GET":
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			dec := json.NewDecoder(strings.NewReader(vars))
+			dec.UseNumber()
+			if err := dec.Decode(&req.Variables); err != nil {
+				http.Error(w, "invalid variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case "POST":
+		var body io.Reader = r.Body
+		if h.maxSize > 0 {
+			body = http.MaxBytesReader(w, r.Body, h.maxSize)
+		}
+		dec := json.NewDecoder(body)
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+	res := execute(ctx, querySchema, &query{b: h.backend}, &req, h.maxCost)
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.Data == nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		glog.V(logger.Debug).Infof("Failed to write GraphQL response: %v", err)
+	}
+}
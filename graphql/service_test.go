@@ -0,0 +1,517 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+var (
+	testBankKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testBankAddress = crypto.PubkeyToAddress(testBankKey.PublicKey)
+	testRecipient   = common.HexToAddress("0x0000000000000000000000000000000000000abc")
+)
+
+// logCode is contract init code emitting a log with a single topic 0x2a.
+var logCode = common.FromHex("0x602a60006000a100")
+
+type testBackend struct {
+	db ethdb.Database
+	bc *core.BlockChain
+}
+
+func (b *testBackend) BlockChain() *core.BlockChain { return b.bc }
+func (b *testBackend) ChainDb() ethdb.Database      { return b.db }
+
+// newTestBackend creates a chain of two blocks, the first with a transfer
+// from the bank account, the second with a contract creation emitting a log.
+func newTestBackend(t *testing.T) (*testBackend, types.Blocks) {
+	db, _ := ethdb.NewMemDatabase()
+	config := &core.ChainConfig{Forks: []*core.Fork{{Name: "Homestead", Block: big.NewInt(0)}}}
+	genesis := core.WriteGenesisBlockForTesting(db, core.GenesisAccount{Address: testBankAddress, Balance: big.NewInt(1000000000)})
+
+	transfer, err := types.NewTransaction(0, testRecipient, big.NewInt(1000), big.NewInt(21000), big.NewInt(1), nil).SignECDSA(testBankKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create, err := types.NewContractCreation(1, big.NewInt(0), big.NewInt(100000), big.NewInt(1), logCode).SignECDSA(testBankKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, _ := core.GenerateChain(config, genesis, db, 2, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+		if i == 0 {
+			gen.AddTx(transfer)
+		} else {
+			gen.AddTx(create)
+		}
+	})
+	bc, err := core.NewBlockChain(db, config, core.FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.SetAtxi(&core.AtxiT{Db: db})
+	if res := bc.InsertChain(blocks); res.Error != nil {
+		t.Fatalf("failed to insert block %d: %v", res.Index, res.Error)
+	}
+	return &testBackend{db: db, bc: bc}, blocks
+}
+
+// post sends a query to the handler, returning the decoded response.
+func post(t *testing.T, h http.Handler, req *Request) map[string]interface{} {
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q, body: %s", ct, rec.Body)
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// checkResponse compares a response to its expected JSON encoding.
+func checkResponse(t *testing.T, res map[string]interface{}, want string) {
+	var exp map[string]interface{}
+	if err := json.Unmarshal([]byte(want), &exp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, exp) {
+		got, _ := json.MarshalIndent(res, "", "  ")
+		t.Errorf("response mismatch:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestQueryBlock(t *testing.T) {
+	backend, blocks := newTestBackend(t)
+	h := NewHandler(backend)
+	tx := blocks[0].Transactions()[0]
+
+	res := post(t, h, &Request{Query: `
+		query Block($n: Long!) {
+			block(number: $n) {
+				__typename
+				number
+				hash
+				parent { number }
+				transactionCount
+				transactions {
+					hash
+					index
+					from { address }
+					to { address balance }
+					value
+					gasUsed
+					status
+				}
+				miner { address }
+			}
+		}`,
+		Variables: map[string]interface{}{"n": 1},
+	})
+	checkResponse(t, res, `{"data": {"block": {
+		"__typename": "Block",
+		"number": 1,
+		"hash": "`+blocks[0].Hash().Hex()+`",
+		"parent": {"number": 0},
+		"transactionCount": 1,
+		"transactions": [{
+			"hash": "`+tx.Hash().Hex()+`",
+			"index": 0,
+			"from": {"address": "`+strings.ToLower(testBankAddress.Hex())+`"},
+			"to": {"address": "0x0000000000000000000000000000000000000abc", "balance": "0x3e8"},
+			"value": "0x3e8",
+			"gasUsed": 21000,
+			"status": 1
+		}],
+		"miner": {"address": "0x0100000000000000000000000000000000000000"}
+	}}}`)
+}
+
+func TestQueryTransactionLogs(t *testing.T) {
+	backend, blocks := newTestBackend(t)
+	h := NewHandler(backend)
+	tx := blocks[1].Transactions()[0]
+	contract := crypto.CreateAddress(testBankAddress, 1)
+
+	res := post(t, h, &Request{Query: `{
+		transaction(hash: "` + tx.Hash().Hex() + `") {
+			to { address }
+			createdContract { address code }
+			logs { topics account { address } transaction { hash } }
+			block { number }
+		}
+		logs(filter: {fromBlock: 0, topics: [["0x000000000000000000000000000000000000000000000000000000000000002a"]]}) {
+			index
+			data
+		}
+		missing: transaction(hash: "0x0000000000000000000000000000000000000000000000000000000000000001") { hash }
+	}`})
+	checkResponse(t, res, `{"data": {
+		"transaction": {
+			"to": null,
+			"createdContract": {"address": "`+strings.ToLower(contract.Hex())+`", "code": "0x"},
+			"logs": [{
+				"topics": ["0x000000000000000000000000000000000000000000000000000000000000002a"],
+				"account": {"address": "`+strings.ToLower(contract.Hex())+`"},
+				"transaction": {"hash": "`+tx.Hash().Hex()+`"}
+			}],
+			"block": {"number": 2}
+		},
+		"logs": [{"index": 0, "data": "0x"}],
+		"missing": null
+	}}`)
+}
+
+func TestQueryAccount(t *testing.T) {
+	backend, blocks := newTestBackend(t)
+	h := NewHandler(backend)
+
+	res := post(t, h, &Request{Query: `{
+		genesis: block(number: 0) {
+			account(address: "` + testBankAddress.Hex() + `") { balance transactionCount }
+		}
+		head: block {
+			account(address: "` + testBankAddress.Hex() + `") {
+				transactionCount
+				storage(slot: "0x0000000000000000000000000000000000000000000000000000000000000000")
+				history(first: 1) {
+					transactions { hash }
+					next
+					count
+				}
+			}
+		}
+	}`})
+	data := res["data"].(map[string]interface{})
+	checkResponse(t, data["genesis"].(map[string]interface{}), `{"account": {"balance": "0x3b9aca00", "transactionCount": 0}}`)
+
+	account := data["head"].(map[string]interface{})["account"].(map[string]interface{})
+	if account["transactionCount"] != 2.0 {
+		t.Errorf("transaction count = %v, want 2", account["transactionCount"])
+	}
+	if account["storage"] != (common.Hash{}).Hex() {
+		t.Errorf("storage = %v, want zero", account["storage"])
+	}
+	history := account["history"].(map[string]interface{})
+	if history["count"] != 2.0 {
+		t.Errorf("history count = %v, want 2", history["count"])
+	}
+	first := blocks[0].Transactions()[0].Hash().Hex()
+	if txs := history["transactions"].([]interface{}); len(txs) != 1 || txs[0].(map[string]interface{})["hash"] != first {
+		t.Errorf("history page mismatch: %v", txs)
+	}
+	next, ok := history["next"].(string)
+	if !ok {
+		t.Fatalf("missing next page cursor")
+	}
+
+	// The second page holds the remaining transaction, and no cursor.
+	res = post(t, h, &Request{
+		Query: `query Next($addr: Address!, $after: String) {
+			block { account(address: $addr) { history(first: 1, after: $after) { transactions { hash } next } } }
+		}`,
+		Variables: map[string]interface{}{"addr": testBankAddress.Hex(), "after": next},
+	})
+	second := blocks[1].Transactions()[0].Hash().Hex()
+	checkResponse(t, res, `{"data": {"block": {"account": {"history": {
+		"transactions": [{"hash": "`+second+`"}],
+		"next": null
+	}}}}}`)
+}
+
+func TestQueryFeatures(t *testing.T) {
+	backend, blocks := newTestBackend(t)
+	h := NewHandler(backend)
+	hash := blocks[1].Hash().Hex()
+
+	res := post(t, h, &Request{
+		OperationName: "Second",
+		Query: `
+			query First { block { number } }
+			query Second($skip: Boolean!) {
+				a: block(number: 1) { ...Fields }
+				b: block(hash: "` + hash + `") {
+					... on Block { number }
+					hash @skip(if: $skip)
+					difficulty @include(if: $skip)
+				}
+				blocks(from: 1) { number }
+			}
+			fragment Fields on Block { number number: number }`,
+		Variables: map[string]interface{}{"skip": true},
+	})
+	checkResponse(t, res, `{"data": {
+		"a": {"number": 1},
+		"b": {"number": 2, "difficulty": "`+"0x"+blocks[1].Difficulty().Text(16)+`"},
+		"blocks": [{"number": 1}, {"number": 2}]
+	}}`)
+}
+
+func TestQueryErrors(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	h := NewHandler(backend)
+	hash := "0x0000000000000000000000000000000000000000000000000000000000000001"
+
+	// Field errors null the field, and are reported along with the path.
+	res := post(t, h, &Request{Query: `{ block { number } head: block(number: 1, hash: "` + hash + `") { number } }`})
+	checkResponse(t, res, `{
+		"data": {"block": {"number": 2}, "head": null},
+		"errors": [{"message": "only one of number and hash can be given", "path": ["head"]}]
+	}`)
+
+	// Request errors, including those of the validation against the schema,
+	// are reported without data.
+	tests := []struct {
+		req Request
+		err string
+	}{
+		{Request{Query: `{ block { number }`}, "syntax error"},
+		{Request{Query: `query Q($n: Long!) { block(number: $n) { number } }`}, "variable $n is required"},
+		{Request{Query: `mutation { block { number } }`}, "mutation operations are not supported"},
+		{Request{Query: `query A { block { number } } query B { block { hash } }`}, "operation name required"},
+		{Request{Query: `{ block { number unknown } }`}, `cannot query field "unknown" on type "Block"`},
+		{Request{Query: `{ block(numbr: 1) { number } }`}, `Query.block: unknown argument "numbr"`},
+		{Request{Query: `{ block(number: 1, number: 2) { number } }`}, `duplicate argument "number"`},
+		{Request{Query: `{ block(hash: "0x00") { number } }`}, `argument "hash": invalid hex string "0x00": want 32 bytes`},
+		{Request{Query: `{ block(number: true) { number } }`}, `argument "number": not a number`},
+		{Request{Query: `{ transaction { hash } }`}, `argument "hash" of type Bytes32! is required`},
+		{Request{Query: `{ blocks(from: null) { number } }`}, `null value for non-null type Long!`},
+		{Request{Query: `{ logs(filter: {toBlok: 1}) { index } }`}, `unknown input field "toBlok"`},
+		{Request{Query: `{ logs(filter: 1) { index } }`}, `1 is not an input object`},
+		{Request{Query: `{ block { miner(block: 1, extra: 2) { address } } }`}, `Block.miner: unknown argument "extra"`},
+		{Request{Query: `{ block { number { value } } }`}, `must not have a selection`},
+		{Request{Query: `{ block }`}, `must have a selection of subfields`},
+		{Request{Query: `{ block { ... on Transaction { hash } } }`}, `can never apply to type "Block"`},
+		{Request{Query: `{ block { ...F } } fragment F on Log { index }`}, `can never apply to type "Block"`},
+		{Request{Query: `{ block { ... on Missing { hash } } }`}, `unknown type "Missing"`},
+		{Request{Query: `{ block { number @deprecated } }`}, `unknown directive @deprecated`},
+		{Request{Query: `{ block { number @skip } }`}, `argument "if" of type Boolean! is required`},
+		{Request{Query: `query @skip(if: true) { block { number } }`}, `directive @skip is not allowed on QUERY`},
+		{Request{Query: `{ block(number: $n) { number } }`}, `variable $n is not defined`},
+		{Request{Query: `query Q($n: Long) { block { number } }`}, `variable $n is not used`},
+		{Request{Query: `query Q($n: Long) { blocks(from: $n) { number } }`}, `variable $n of type Long cannot be used as Long!`},
+		{Request{Query: `query Q($n: Int!) { block(number: $n) { number } }`, Variables: map[string]interface{}{"n": 1}}, `variable $n of type Int! cannot be used as Long`},
+		{Request{Query: `query Q($b: Block) { block { number } }`}, `object type "Block" is not an input type`},
+		{Request{Query: `query Q($n: Long!) { block(number: $n) { number } }`, Variables: map[string]interface{}{"n": "x"}}, `variable $n: invalid number "x"`},
+		{Request{Query: `{ block { number } block { number: hash } }`}, `fields "number" and "hash" conflict`},
+		{Request{Query: `{ a: block(number: 1) { number } a: block(number: 2) { number } }`}, `differing arguments`},
+		{Request{Query: `{ __type { name } }`}, `argument "name" of type String! is required`},
+		{Request{Query: `{ block { __schema { types { name } } } }`}, `cannot query field "__schema" on type "Block"`},
+	}
+	for _, tt := range tests {
+		res := post(t, h, &tt.req)
+		if _, ok := res["data"]; ok {
+			t.Errorf("%q: unexpected data in response", tt.req.Query)
+		}
+		errs, _ := res["errors"].([]interface{})
+		if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), tt.err) {
+			t.Errorf("%q: errors %v, want %q", tt.req.Query, errs, tt.err)
+		}
+	}
+}
+
+func TestQueryDepth(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	h := NewHandler(backend)
+
+	nested := func(depth int) string {
+		return "{ block { " + strings.Repeat("parent { ", depth-1) + "number" + strings.Repeat(" }", depth-1) + " } }"
+	}
+	res := post(t, h, &Request{Query: nested(maxQueryDepth)})
+	if errs, ok := res["errors"]; ok {
+		t.Errorf("unexpected errors at maximum depth: %v", errs)
+	}
+	res = post(t, h, &Request{Query: nested(maxQueryDepth + 1)})
+	errs, _ := res["errors"].([]interface{})
+	if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), "exceeds the maximum") {
+		t.Errorf("errors %v, want a depth error", errs)
+	}
+
+	// Fragments spreading themselves would recurse down to the genesis.
+	res = post(t, h, &Request{Query: `{ block { ...F } } fragment F on Block { parent { ...F } }`})
+	errs, _ = res["errors"].([]interface{})
+	if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), "spreads itself") {
+		t.Errorf("errors %v, want a fragment cycle error", errs)
+	}
+}
+
+func TestHandlerGet(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	h := NewHandler(backend)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?query="+url.QueryEscape(`{ block { number } }`), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body: %s", rec.Code, rec.Body)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"data":{"block":{"number":2}}}` {
+		t.Errorf("body = %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestQueryIntrospection(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	h := NewHandler(backend)
+
+	res := post(t, h, &Request{Query: `{
+		__schema { queryType { name } mutationType { name } }
+		block: __type(name: "Block") { kind name fields { name } }
+		account: __type(name: "Account") {
+			fields {
+				name
+				args { name defaultValue type { kind name ofType { name } } }
+				type { kind ofType { kind name } }
+			}
+		}
+		long: __type(name: "Long") { kind description }
+		missing: __type(name: "Missing") { name }
+	}`})
+	data := res["data"].(map[string]interface{})
+	checkResponse(t, data["__schema"].(map[string]interface{}), `{"queryType": {"name": "Query"}, "mutationType": null}`)
+	checkResponse(t, data["long"].(map[string]interface{}), `{
+		"kind": "SCALAR",
+		"description": "Long is a 64 bit unsigned integer. Input is accepted as a number or as a\ndecimal or 0x-prefixed hexadecimal string."
+	}`)
+	if data["missing"] != nil {
+		t.Errorf("unknown type resolved to %v", data["missing"])
+	}
+	block := data["block"].(map[string]interface{})
+	if block["kind"] != "OBJECT" || block["name"] != "Block" {
+		t.Errorf("block type mismatch: %v", block)
+	}
+	if fields := block["fields"].([]interface{}); len(fields) != len(querySchema.types["Block"].fields) {
+		t.Errorf("got %d block fields, want %d", len(fields), len(querySchema.types["Block"].fields))
+	}
+	for _, field := range data["account"].(map[string]interface{})["fields"].([]interface{}) {
+		field := field.(map[string]interface{})
+		if field["name"] != "history" {
+			continue
+		}
+		args := field["args"].([]interface{})
+		checkResponse(t, args[0].(map[string]interface{}), `{
+			"name": "first",
+			"defaultValue": "20",
+			"type": {"kind": "SCALAR", "name": "Int", "ofType": null}
+		}`)
+		checkResponse(t, field["type"].(map[string]interface{}), `{"kind": "NON_NULL", "ofType": {"kind": "OBJECT", "name": "AccountHistory"}}`)
+		return
+	}
+	t.Error("history field missing from the account type")
+}
+
+func TestQueryCost(t *testing.T) {
+	backend, _ := newTestBackend(t)
+
+	tests := []struct {
+		query string
+		cost  int
+	}{
+		// Block ranges are sized by their bounds, or by the maximum range.
+		{`{ blocks(from: 0, to: 9) { number transactions { hash } } }`, 1 + 10*(2+defaultListSize)},
+		{`{ blocks(from: 1) { number } }`, 1 + maxBlockRange},
+		{`{ logs(filter: {fromBlock: 0, toBlock: 499}) { index } }`, 1 + 500},
+		{`{ logs(filter: {fromBlock: 0}) { index } }`, 1 + maxBlockRange},
+		{`{ logs(filter: {}) { index } }`, 1 + defaultListSize},
+		// History pages are sized by the requested page size.
+		{`{ block { account(address: "` + testBankAddress.Hex() + `") { history(first: 5) { transactions { hash } count } } } }`, 10},
+		{`{ block { ommers { number } } }`, 2 + maxOmmers},
+	}
+	for _, tt := range tests {
+		h := &handler{backend: backend, maxCost: tt.cost, timeout: DefaultTimeout}
+		if res := post(t, h, &Request{Query: tt.query}); res["errors"] != nil {
+			t.Errorf("%s: errors at cost %d: %v", tt.query, tt.cost, res["errors"])
+		}
+		h.maxCost--
+		res := post(t, h, &Request{Query: tt.query})
+		errs, _ := res["errors"].([]interface{})
+		if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), "query cost exceeds") {
+			t.Errorf("%s: errors %v at cost %d, want a cost error", tt.query, errs, h.maxCost)
+		}
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	h := &handler{backend: backend, maxCost: DefaultMaxCost, timeout: time.Nanosecond}
+
+	res := post(t, h, &Request{Query: `{ block { number } }`})
+	checkResponse(t, res, `{
+		"data": {"block": null},
+		"errors": [{"message": "query execution timed out", "path": ["block"]}]
+	}`)
+}
+
+func TestServicePolicy(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	srv, err := New(backend, Config{
+		Endpoint: "127.0.0.1:0",
+		VHosts:   []string{"*"},
+		Policy:   rpc.Policy{AuthTokens: []string{"secret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+	endpoint := "http://" + srv.listener.Addr().String() + "/?query=" + url.QueryEscape(`{ block { number } }`)
+
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without token %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	req, _ := http.NewRequest("GET", endpoint, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status with token %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,571 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/ethereumproject/go-ethereum/common/hexutil"
+)
+
+const (
+	defaultListSize = 100 // Estimated length of the lists not bounded by arguments
+	maxOmmers       = 2   // Maximum number of ommers of a block
+)
+
+// validator checks an operation against the schema before its execution. The
+// arguments of the fields are coerced to their Go values on the way, and the
+// directives evaluated. Only the operation executed and the fragments it
+// spreads are validated.
+type validator struct {
+	schema *schema
+	doc    *document
+
+	vars   map[string]*variableDef // Variables declared by the operation
+	values map[string]interface{}  // Coerced variable values, missing if not given
+	used   map[string]bool         // Variables referenced by the operation
+	frags  map[string]bool         // Fragments validated
+
+	args    map[*field]arguments // Coerced field arguments
+	skipped map[selection]bool   // Selections excluded by their directives
+
+	budget, spent int // Cost budget of the operation and its estimated cost
+}
+
+// validate checks the operation against the schema, coercing the variables.
+func validate(s *schema, doc *document, op *operation, given map[string]interface{}) (*validator, error) {
+	v := &validator{
+		schema:  s,
+		doc:     doc,
+		vars:    make(map[string]*variableDef),
+		values:  make(map[string]interface{}),
+		used:    make(map[string]bool),
+		frags:   make(map[string]bool),
+		args:    make(map[*field]arguments),
+		skipped: make(map[selection]bool),
+	}
+	if err := v.variables(op.vars, given); err != nil {
+		return nil, err
+	}
+	if _, err := v.directives(op.dirs, "QUERY"); err != nil {
+		return nil, err
+	}
+	if err := v.selectionSet(s.types[s.query], op.sel); err != nil {
+		return nil, err
+	}
+	for _, def := range op.vars {
+		if !v.used[def.name] {
+			return nil, fmt.Errorf("variable $%s is not used", def.name)
+		}
+	}
+	return v, nil
+}
+
+// variables coerces the values given for the variables of the operation, or
+// their defaults.
+func (v *validator) variables(defs []*variableDef, given map[string]interface{}) error {
+	for _, def := range defs {
+		if v.vars[def.name] != nil {
+			return fmt.Errorf("duplicate variable $%s", def.name)
+		}
+		t := v.schema.types[def.typ.named()]
+		if t == nil {
+			return fmt.Errorf("variable $%s: unknown type %q", def.name, def.typ.named())
+		}
+		if t.kind == "OBJECT" {
+			return fmt.Errorf("variable $%s: object type %q is not an input type", def.name, t.name)
+		}
+		v.vars[def.name] = def
+
+		val, ok := given[def.name]
+		if !ok {
+			if def.def == nil {
+				if def.typ.nonNull {
+					return fmt.Errorf("variable $%s is required", def.name)
+				}
+				continue
+			}
+			val = def.def
+		}
+		val, err := v.coerce(val, def.typ)
+		if err != nil {
+			return fmt.Errorf("variable $%s: %v", def.name, err)
+		}
+		v.values[def.name] = val
+	}
+	return nil
+}
+
+// selectionSet validates the selections on an object type.
+func (v *validator) selectionSet(t *typeDef, sel []selection) error {
+	for _, s := range sel {
+		var (
+			include bool
+			err     error
+		)
+		switch s := s.(type) {
+		case *field:
+			if include, err = v.directives(s.dirs, "FIELD"); err == nil {
+				err = v.field(t, s)
+			}
+		case *fragmentSpread:
+			if include, err = v.directives(s.dirs, "FRAGMENT_SPREAD"); err == nil {
+				err = v.fragment(t, s.name)
+			}
+		case *inlineFragment:
+			if include, err = v.directives(s.dirs, "INLINE_FRAGMENT"); err == nil && s.on != "" {
+				err = v.condition(t, s.on)
+			}
+			if err == nil {
+				err = v.selectionSet(t, s.sel)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !include {
+			v.skipped[s] = true
+		}
+	}
+	return v.merge(t, sel)
+}
+
+// field validates a field selected on an object type and coerces its
+// arguments.
+func (v *validator) field(t *typeDef, f *field) error {
+	def := v.schema.field(t, f.name)
+	if def == nil {
+		return fmt.Errorf("cannot query field %q on type %q", f.name, t.name)
+	}
+	args, err := v.arguments(f.args, def.args)
+	if err != nil {
+		return fmt.Errorf("%s.%s: %v", t.name, f.name, err)
+	}
+	v.args[f] = args
+
+	ft := v.schema.types[def.typ.named()]
+	if ft.leaf() {
+		if len(f.sel) > 0 {
+			return fmt.Errorf("field %q of type %q must not have a selection", f.name, def.typ)
+		}
+		return nil
+	}
+	if len(f.sel) == 0 {
+		return fmt.Errorf("field %q of type %q must have a selection of subfields", f.name, def.typ)
+	}
+	return v.selectionSet(ft, f.sel)
+}
+
+// fragment validates a fragment spread on an object type. The selections of
+// fragments are validated once, as they don't depend on where they are spread.
+func (v *validator) fragment(t *typeDef, name string) error {
+	frag := v.doc.fragments[name]
+	if frag == nil {
+		return fmt.Errorf("unknown fragment %q", name)
+	}
+	if err := v.condition(t, frag.on); err != nil {
+		return fmt.Errorf("fragment %q: %v", name, err)
+	}
+	if v.frags[name] {
+		return nil
+	}
+	v.frags[name] = true
+	if _, err := v.directives(frag.dirs, "FRAGMENT_DEFINITION"); err != nil {
+		return fmt.Errorf("fragment %q: %v", name, err)
+	}
+	return v.selectionSet(t, frag.sel)
+}
+
+// condition checks the type condition of a fragment spread on an object type.
+// The schema has neither interfaces nor unions, so only the object type itself
+// can apply.
+func (v *validator) condition(t *typeDef, on string) error {
+	cond := v.schema.types[on]
+	switch {
+	case cond == nil:
+		return fmt.Errorf("unknown type %q", on)
+	case cond.kind != "OBJECT":
+		return fmt.Errorf("type condition on non-object type %q", on)
+	case cond != t:
+		return fmt.Errorf("fragment on %q can never apply to type %q", on, t.name)
+	}
+	return nil
+}
+
+// merge checks that the fields selected under the same response key, directly
+// or through fragments, are the same field with the same arguments, so that
+// their results can be merged.
+func (v *validator) merge(t *typeDef, sel []selection) error {
+	for _, group := range collectFields(v.doc, nil, t.name, sel) {
+		first := group.fields[0]
+		for _, f := range group.fields[1:] {
+			if f.name != first.name {
+				return fmt.Errorf("fields %q and %q conflict on response key %q", first.name, f.name, group.key)
+			}
+			if !reflect.DeepEqual(v.args[f], v.args[first]) {
+				return fmt.Errorf("field %q has differing arguments on response key %q", f.name, group.key)
+			}
+		}
+		if len(group.fields) > 1 && len(first.sel) > 0 {
+			var sub []selection
+			for _, f := range group.fields {
+				sub = append(sub, f.sel...)
+			}
+			if err := v.merge(v.schema.types[v.schema.field(t, first.name).typ.named()], sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// directives validates the directives at a location, evaluating whether the
+// selection they are on is included. The only directives defined are @skip
+// and @include.
+func (v *validator) directives(dirs []*directive, location string) (bool, error) {
+	include := true
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		def := v.schema.directive(dir.name)
+		if def == nil {
+			return false, fmt.Errorf("unknown directive @%s", dir.name)
+		}
+		allowed := false
+		for _, loc := range def.locations {
+			allowed = allowed || loc == location
+		}
+		if !allowed {
+			return false, fmt.Errorf("directive @%s is not allowed on %s", dir.name, location)
+		}
+		if seen[dir.name] {
+			return false, fmt.Errorf("duplicate directive @%s", dir.name)
+		}
+		seen[dir.name] = true
+
+		args, err := v.arguments(dir.args, def.args)
+		if err != nil {
+			return false, fmt.Errorf("directive @%s: %v", dir.name, err)
+		}
+		if args.boolean("if") == (dir.name == "skip") {
+			include = false
+		}
+	}
+	return include, nil
+}
+
+// arguments coerces the arguments of a field or directive.
+func (v *validator) arguments(args []*argument, defs []*inputValue) (arguments, error) {
+	given := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		if findInput(defs, arg.name) == nil {
+			return nil, fmt.Errorf("unknown argument %q", arg.name)
+		}
+		if _, ok := given[arg.name]; ok {
+			return nil, fmt.Errorf("duplicate argument %q", arg.name)
+		}
+		given[arg.name] = arg.val
+	}
+	return v.inputs(given, defs, "argument")
+}
+
+// inputs coerces the given arguments or input object fields, filling in the
+// defaults of those missing. Arguments given variables without a value count
+// as missing.
+func (v *validator) inputs(given map[string]interface{}, defs []*inputValue, kind string) (map[string]interface{}, error) {
+	names := make([]string, 0, len(given))
+	for name := range given {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if findInput(defs, name) == nil {
+			return nil, fmt.Errorf("unknown %s %q", kind, name)
+		}
+	}
+	vals := make(map[string]interface{}, len(defs))
+	for _, def := range defs {
+		val, ok := given[def.name]
+		if name, isVar := val.(variable); isVar {
+			var err error
+			if val, ok, err = v.variable(name, def.typ); err != nil {
+				return nil, fmt.Errorf("%s %q: %v", kind, def.name, err)
+			}
+			if ok {
+				vals[def.name] = val
+				continue
+			}
+		}
+		if !ok {
+			if def.def == nil {
+				if def.typ.nonNull {
+					return nil, fmt.Errorf("%s %q of type %s is required", kind, def.name, def.typ)
+				}
+				continue
+			}
+			val = def.def
+		}
+		val, err := v.coerce(val, def.typ)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %v", kind, def.name, err)
+		}
+		vals[def.name] = val
+	}
+	return vals, nil
+}
+
+// coerce validates an input value against its type, converting it to the Go
+// value the resolvers take. Variables are replaced by their coerced values.
+func (v *validator) coerce(val interface{}, typ *typeRef) (interface{}, error) {
+	if name, ok := val.(variable); ok {
+		val, _, err := v.variable(name, typ)
+		return val, err
+	}
+	if val == nil {
+		if typ.nonNull {
+			return nil, fmt.Errorf("null value for non-null type %s", typ)
+		}
+		return nil, nil
+	}
+	if typ.elem != nil {
+		list, ok := val.([]interface{})
+		if !ok {
+			// A single value is coerced to a list of one.
+			item, err := v.coerce(val, typ.elem)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		items := make([]interface{}, len(list))
+		for i, item := range list {
+			var err error
+			if items[i], err = v.coerce(item, typ.elem); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	t := v.schema.types[typ.name]
+	switch t.kind {
+	case "INPUT_OBJECT":
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is not an input object", formatValue(val))
+		}
+		return v.inputs(obj, t.inputs, "input field")
+	case "ENUM":
+		if s, ok := val.(string); ok {
+			for _, value := range t.values {
+				if value.name == s {
+					return s, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("invalid %s value %s", t.name, formatValue(val))
+	}
+	return coerceScalar(t.name, val)
+}
+
+// variable returns the coerced value of a variable used as a value of the given
+// type, and whether it has a value.
+func (v *validator) variable(name variable, typ *typeRef) (interface{}, bool, error) {
+	def := v.vars[string(name)]
+	if def == nil {
+		return nil, false, fmt.Errorf("variable $%s is not defined", name)
+	}
+	v.used[string(name)] = true
+	if !assignable(def.typ, typ, def.def != nil) {
+		return nil, false, fmt.Errorf("variable $%s of type %s cannot be used as %s", name, def.typ, typ)
+	}
+	val, ok := v.values[string(name)]
+	if ok && val == nil && typ.nonNull {
+		return nil, false, fmt.Errorf("variable $%s must not be null", name)
+	}
+	return val, ok, nil
+}
+
+// assignable reports whether a variable of type from can be used where a value
+// of type to is expected.
+func assignable(from, to *typeRef, hasDefault bool) bool {
+	if to.nonNull && !from.nonNull && !hasDefault {
+		return false
+	}
+	if (from.elem == nil) != (to.elem == nil) {
+		return false
+	}
+	if from.elem != nil {
+		return assignable(from.elem, to.elem, false)
+	}
+	return from.name == to.name
+}
+
+// coerceScalar converts an input value to the Go value of a scalar type: Int
+// and Long to int64, Float to float64, hexadecimal scalars to their types in
+// the common packages and BigInt to *big.Int.
+func coerceScalar(name string, val interface{}) (interface{}, error) {
+	switch name {
+	case "Int":
+		if n, ok := val.(json.Number); ok {
+			if i, err := strconv.ParseInt(string(n), 10, 32); err == nil {
+				return i, nil
+			}
+		}
+	case "Float":
+		if n, ok := val.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	case "String":
+		if s, ok := val.(string); ok {
+			return s, nil
+		}
+	case "ID":
+		switch id := val.(type) {
+		case string:
+			return id, nil
+		case json.Number:
+			if _, err := strconv.ParseInt(string(id), 10, 64); err == nil {
+				return string(id), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := val.(bool); ok {
+			return b, nil
+		}
+	case "Long":
+		n, err := toLong(val)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "Bytes32":
+		hash, err := toHash(val)
+		if err != nil {
+			return nil, err
+		}
+		return hash, nil
+	case "Address":
+		addr, err := toAddress(val)
+		if err != nil {
+			return nil, err
+		}
+		return addr, nil
+	case "Bytes":
+		if s, ok := val.(string); ok {
+			b, err := hexutil.Decode(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex string %q: %v", s, err)
+			}
+			return hexutil.Bytes(b), nil
+		}
+		return nil, errors.New("not a hex string")
+	case "BigInt":
+		var s string
+		switch n := val.(type) {
+		case string:
+			s = n
+		case json.Number:
+			s = string(n)
+		}
+		if n, ok := new(big.Int).SetString(s, 0); ok {
+			return n, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown scalar %q", name)
+	}
+	return nil, fmt.Errorf("invalid %s value %s", name, formatValue(val))
+}
+
+// cost estimates the work of the selections on an object type: each field
+// costs one, multiplied by the lengths of the lists it is nested in. Lists
+// bounded by arguments are estimated by them. The estimation stops once the
+// budget is spent.
+func (v *validator) cost(t *typeDef, sel []selection, parent arguments, mult int) {
+	for _, group := range collectFields(v.doc, v.skipped, t.name, sel) {
+		if v.spent += mult; v.spent > v.budget {
+			return
+		}
+		f := group.fields[0]
+		def := v.schema.field(t, f.name)
+		ft := v.schema.types[def.typ.named()]
+		if ft.leaf() {
+			continue
+		}
+		var sub []selection
+		for _, f := range group.fields {
+			sub = append(sub, f.sel...)
+		}
+		n := mult
+		if def.typ.elem != nil {
+			if n *= v.listSize(t, f, parent); n > v.budget {
+				n = v.budget + 1
+			}
+		}
+		v.cost(ft, sub, v.args[f], n)
+	}
+}
+
+// listSize estimates the length of a list field, from its arguments or those
+// of its parent field.
+func (v *validator) listSize(t *typeDef, f *field, parent arguments) int {
+	args := v.args[f]
+	switch t.name + "." + f.name {
+	case "Query.blocks":
+		from, _ := args.long("from")
+		to, ok := args.long("to")
+		switch {
+		case !ok || from < 0 || to-from >= maxBlockRange:
+			return maxBlockRange
+		case to < from:
+			return 0
+		}
+		return int(to - from + 1)
+	case "Query.logs":
+		// Blocks hold any number of logs, so a range counts as many logs as
+		// blocks, but never fewer than an unsized list.
+		crit, _ := args["filter"].(map[string]interface{})
+		from, hasFrom := arguments(crit).long("fromBlock")
+		to, hasTo := arguments(crit).long("toBlock")
+		switch {
+		case !hasFrom || from < 0:
+			return defaultListSize
+		case !hasTo || to < 0 || to-from >= maxBlockRange:
+			return maxBlockRange
+		case to-from+1 < defaultListSize:
+			return defaultListSize
+		}
+		return int(to - from + 1)
+	case "AccountHistory.transactions":
+		if first, ok := parent.long("first"); ok && first > 0 && first <= maxPageSize {
+			return int(first)
+		}
+		return maxPageSize
+	case "Block.ommers":
+		return maxOmmers
+	}
+	if size, ok := v.schema.sizes[t.name+"."+f.name]; ok {
+		return size
+	}
+	return defaultListSize
+}
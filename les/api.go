@@ -0,0 +1,337 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/state"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/core/vm"
+	"github.com/ethereumproject/go-ethereum/eth"
+	"github.com/ethereumproject/go-ethereum/eth/filters"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+// maxLogsRange is the maximum number of blocks a log search spans, as each
+// block matching the filter costs requests to the servers.
+const maxLogsRange = 1000
+
+// PublicLightAPI provides the eth namespace of a light client, retrieving the
+// blocks, receipts and state on demand from les servers.
+//
+// Transactions can't be looked up by hash: the light client keeps no index of
+// the blocks holding them, and the les protocol has no request to locate one.
+// eth_getTransactionByHash and eth_getTransactionReceipt are therefore not
+// served.
+type PublicLightAPI struct {
+	le *LightEthereum
+	bc *light.LightChain
+}
+
+// NewPublicLightAPI creates the eth API of a light client.
+func NewPublicLightAPI(le *LightEthereum) *PublicLightAPI {
+	return &PublicLightAPI{le: le, bc: le.blockchain}
+}
+
+// headerByNumber returns the header of a block number, retrieving it on
+// demand if it's not in the local chain. The pending block is the head.
+func (s *PublicLightAPI) headerByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+		return s.bc.CurrentHeader(), nil
+	}
+	return s.bc.GetHeaderByNumberOdr(ctx, uint64(blockNr))
+}
+
+// stateByNumber returns the on demand state of a block number.
+func (s *PublicLightAPI) stateByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, err := s.headerByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	return light.NewState(ctx, header, s.bc.Odr()), header, nil
+}
+
+// ProtocolVersion returns the current les protocol version.
+func (s *PublicLightAPI) ProtocolVersion() *rpc.HexNumber {
+	return rpc.NewHexNumber(s.le.protocolManager.SubProtocols[0].Version)
+}
+
+// GasPrice returns the configured gas price.
+func (s *PublicLightAPI) GasPrice() *big.Int {
+	return s.le.config.GasPrice
+}
+
+// BlockNumber returns the block number of the header chain head.
+func (s *PublicLightAPI) BlockNumber() *big.Int {
+	return s.bc.CurrentHeader().Number
+}
+
+// GetBlockByNumber returns the requested block, retrieving its body on demand.
+// When fullTx is true all transactions in the block are returned in full
+// detail, otherwise only the transaction hash is returned.
+func (s *PublicLightAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	header, err := s.headerByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return s.GetBlockByHash(ctx, header.Hash(), fullTx)
+}
+
+// GetBlockByHash returns the requested block, retrieving its body on demand.
+func (s *PublicLightAPI) GetBlockByHash(ctx context.Context, blockHash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	block, err := s.bc.GetBlock(ctx, blockHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	return eth.RPCMarshalBlock(block, s.bc.GetTd(blockHash), s.bc.Config(), true, fullTx)
+}
+
+// GetBlockTransactionCountByNumber returns the number of transactions in the
+// block with the given block number.
+func (s *PublicLightAPI) GetBlockTransactionCountByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*rpc.HexNumber, error) {
+	header, err := s.headerByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return s.GetBlockTransactionCountByHash(ctx, header.Hash())
+}
+
+// GetBlockTransactionCountByHash returns the number of transactions in the
+// block with the given hash.
+func (s *PublicLightAPI) GetBlockTransactionCountByHash(ctx context.Context, blockHash common.Hash) (*rpc.HexNumber, error) {
+	body, err := s.bc.GetBody(ctx, blockHash)
+	if body == nil || err != nil {
+		return nil, err
+	}
+	return rpc.NewHexNumber(len(body.Transactions)), nil
+}
+
+// GetBalance returns the amount of wei for the given address in the state of
+// the given block number.
+func (s *PublicLightAPI) GetBalance(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
+	st, _, err := s.stateByNumber(ctx, blockNr)
+	if st == nil || err != nil {
+		return nil, err
+	}
+	balance := st.GetBalance(address)
+	return balance, st.Error()
+}
+
+// GetTransactionCount returns the nonce of the given address in the state of
+// the given block number.
+func (s *PublicLightAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*rpc.HexNumber, error) {
+	st, _, err := s.stateByNumber(ctx, blockNr)
+	if st == nil || err != nil {
+		return nil, err
+	}
+	nonce := st.GetNonce(address)
+	return rpc.NewHexNumber(nonce), st.Error()
+}
+
+// GetCode returns the code stored at the given address in the state of the
+// given block number.
+func (s *PublicLightAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (string, error) {
+	st, _, err := s.stateByNumber(ctx, blockNr)
+	if st == nil || err != nil {
+		return "", err
+	}
+	res := st.GetCode(address)
+	if err := st.Error(); err != nil {
+		return "", err
+	}
+	if len(res) == 0 { // backwards compatibility
+		return "0x", nil
+	}
+	return common.ToHex(res), nil
+}
+
+// GetStorageAt returns the storage from the state at the given address, key
+// and block number.
+func (s *PublicLightAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNr rpc.BlockNumber) (string, error) {
+	st, _, err := s.stateByNumber(ctx, blockNr)
+	if st == nil || err != nil {
+		return "0x", err
+	}
+	value := st.GetState(address, common.HexToHash(key))
+	return value.Hex(), st.Error()
+}
+
+// GetLogs returns the logs matching the filter criteria. The receipts of the
+// blocks whose bloom matches the filter are retrieved on demand.
+func (s *PublicLightAPI) GetLogs(ctx context.Context, args filters.NewFilterArgs) (vm.Logs, error) {
+	head := s.bc.CurrentHeader().Number.Uint64()
+	from, to := head, head
+	if args.FromBlock >= 0 {
+		from = uint64(args.FromBlock)
+	}
+	if args.ToBlock >= 0 && uint64(args.ToBlock) < head {
+		to = uint64(args.ToBlock)
+	}
+	if to >= from && to-from >= maxLogsRange {
+		return nil, fmt.Errorf("block range exceeds the maximum of %d blocks", maxLogsRange)
+	}
+	filter := filters.New(nil)
+	filter.SetAddresses(args.Addresses)
+	filter.SetTopics(args.Topics)
+
+	logs := vm.Logs{}
+	for n := from; n <= to; n++ {
+		header, err := s.bc.GetHeaderByNumberOdr(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		if !filter.MatchBloom(header.Bloom) {
+			continue
+		}
+		blockLogs, err := s.blockLogs(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, filter.FilterLogs(blockLogs)...)
+	}
+	return logs, nil
+}
+
+// blockLogs retrieves the logs of a block, filling in the fields derived from
+// their position in the chain, which the receipts of the servers don't carry.
+func (s *PublicLightAPI) blockLogs(ctx context.Context, header *types.Header) (vm.Logs, error) {
+	hash := header.Hash()
+	receipts, err := s.bc.GetBlockReceipts(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.bc.GetBody(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(body.Transactions) {
+		return nil, fmt.Errorf("block %x has %d receipts for %d transactions", hash[:4], len(receipts), len(body.Transactions))
+	}
+	var logs vm.Logs
+	for i, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			log.BlockNumber = header.Number.Uint64()
+			log.BlockHash = hash
+			log.TxHash = body.Transactions[i].Hash()
+			log.TxIndex = uint(i)
+			log.Index = uint(len(logs))
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// callmsg is the message type used for call transactions.
+type callmsg struct {
+	from          *state.StateObject
+	to            *common.Address
+	gas, gasPrice *big.Int
+	value         *big.Int
+	data          []byte
+}
+
+// accessor boilerplate to implement core.Message
+func (m callmsg) From() (common.Address, error)         { return m.from.Address(), nil }
+func (m callmsg) FromFrontier() (common.Address, error) { return m.from.Address(), nil }
+func (m callmsg) Nonce() uint64                         { return m.from.Nonce() }
+func (m callmsg) To() *common.Address                   { return m.to }
+func (m callmsg) GasPrice() *big.Int                    { return m.gasPrice }
+func (m callmsg) Gas() *big.Int                         { return m.gas }
+func (m callmsg) Value() *big.Int                       { return m.value }
+func (m callmsg) Data() []byte                          { return m.data }
+
+func (s *PublicLightAPI) doCall(ctx context.Context, args eth.CallArgs, blockNr rpc.BlockNumber) (string, *big.Int, error) {
+	st, header, err := s.stateByNumber(ctx, blockNr)
+	if st == nil || err != nil {
+		return "0x", nil, err
+	}
+	from := st.GetOrNewStateObject(args.From)
+	from.SetBalance(common.MaxBig)
+
+	// Assemble the CALL invocation
+	msg := callmsg{
+		from:     from,
+		to:       args.To,
+		gas:      args.Gas.BigInt(),
+		gasPrice: args.GasPrice.BigInt(),
+		value:    args.Value.BigInt(),
+		data:     common.FromHex(args.Data),
+	}
+	if msg.gas == nil {
+		msg.gas = big.NewInt(50000000)
+	}
+	if msg.gasPrice == nil {
+		msg.gasPrice = new(big.Int)
+		if price := s.le.config.GasPrice; price != nil {
+			msg.gasPrice.Set(price)
+		}
+	}
+	// Block hashes are served from the local header chain
+	getHash := func(n uint64) common.Hash {
+		if h := s.bc.GetHeaderByNumber(n); h != nil {
+			return h.Hash()
+		}
+		return common.Hash{}
+	}
+	vmenv := core.NewEnvWithHashFn(st, s.bc.Config(), getHash, msg, header, vm.Config{})
+	gp := new(core.GasPool).AddGas(common.MaxBig)
+
+	res, requiredGas, _, err := core.NewStateTransition(vmenv, msg, gp).TransitionDb()
+	if odrErr := st.Error(); odrErr != nil {
+		return "0x", nil, odrErr
+	}
+	if len(res) == 0 { // backwards compatibility
+		return "0x", requiredGas, err
+	}
+	return common.ToHex(res), requiredGas, err
+}
+
+// Call executes the given transaction on the state of the given block number,
+// retrieving the accessed state on demand.
+func (s *PublicLightAPI) Call(ctx context.Context, args eth.CallArgs, blockNr rpc.BlockNumber) (string, error) {
+	result, _, err := s.doCall(ctx, args, blockNr)
+	return result, err
+}
+
+// EstimateGas returns an estimate of the amount of gas needed to execute the
+// given transaction on the head state.
+func (s *PublicLightAPI) EstimateGas(ctx context.Context, args eth.CallArgs) (*rpc.HexNumber, error) {
+	_, gas, err := s.doCall(ctx, args, rpc.LatestBlockNumber)
+	return rpc.NewHexNumber(gas), err
+}
+
+// SendRawTransaction relays the signed transaction to the connected servers.
+func (s *PublicLightAPI) SendRawTransaction(encodedTx string) (string, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(encodedTx), tx); err != nil {
+		return "", err
+	}
+	if !s.le.protocolManager.relayTx(tx) {
+		return "", light.ErrNoPeers
+	}
+	glog.V(logger.Info).Infof("Tx(%x) relayed to the light servers", tx.Hash())
+	return tx.Hash().Hex(), nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereumproject/ethash"
+	"github.com/ethereumproject/go-ethereum/accounts"
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/eth"
+	"github.com/ethereumproject/go-ethereum/eth/downloader"
+	"github.com/ethereumproject/go-ethereum/eth/filters"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/node"
+	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/pow"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+// LightEthereum is a light client node service: it syncs only the headers and
+// retrieves everything else on demand from les servers.
+type LightEthereum struct {
+	config *eth.Config
+
+	odr             *LesOdr
+	chainDb         ethdb.Database // Header chain and on demand retrieved data
+	blockchain      *light.LightChain
+	protocolManager *ProtocolManager
+	accountManager  *accounts.Manager
+	eventMux        *event.TypeMux
+	pow             pow.PoW
+
+	netVersionId  int
+	netRPCService *eth.PublicNetAPI
+
+	subscriptionAPI *filters.PublicSubscriptionAPI // eth_subscribe service, stopped with the node
+}
+
+// New creates a light client node service.
+func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
+	chainDb, err := ctx.OpenDatabase("lightchaindata", config.DatabaseCache, config.DatabaseHandles)
+	if err != nil {
+		return nil, err
+	}
+	// Load up any custom genesis block if requested, or the mainnet one if
+	// the database is empty
+	if config.Genesis != nil {
+		if _, err := core.WriteGenesisBlock(chainDb, config.Genesis); err != nil {
+			return nil, err
+		}
+	} else if core.GetCanonicalHash(chainDb, 0) == (common.Hash{}) {
+		if _, err := core.WriteGenesisBlock(chainDb, core.DefaultConfigMainnet.Genesis); err != nil {
+			return nil, err
+		}
+	}
+	if config.ChainConfig == nil {
+		return nil, errors.New("missing chain config")
+	}
+	le := &LightEthereum{
+		config:         config,
+		odr:            NewLesOdr(chainDb),
+		chainDb:        chainDb,
+		accountManager: config.AccountManager,
+		eventMux:       ctx.EventMux,
+		netVersionId:   config.NetworkId,
+	}
+	if config.PowTest {
+		glog.V(logger.Info).Infof("Consensus: ethash used in test mode")
+		if le.pow, err = ethash.NewForTesting(); err != nil {
+			return nil, err
+		}
+	} else {
+		le.pow = ethash.New()
+	}
+	if le.blockchain, err = light.NewLightChain(le.odr, config.ChainConfig, le.pow, le.eventMux); err != nil {
+		if err == core.ErrNoGenesis {
+			return nil, fmt.Errorf(`No chain found. Please initialise a new chain using the "init" subcommand.`)
+		}
+		return nil, err
+	}
+	if config.Checkpoint != nil {
+		if err := le.blockchain.AddTrustedCheckpoint(config.Checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	if le.protocolManager, err = NewProtocolManager(config.ChainConfig, true, uint64(config.NetworkId), le.eventMux, le.blockchain, nil, chainDb, nil, le.odr); err != nil {
+		return nil, err
+	}
+	le.subscriptionAPI = filters.NewPublicSubscriptionAPI(le.eventMux, le.protocolManager.downloader)
+	glog.V(logger.Info).Infof("Light client protocol versions: %v, Network Id: %v", ProtocolVersions, config.NetworkId)
+	return le, nil
+}
+
+// APIs returns the collection of RPC services the light client offers.
+func (s *LightEthereum) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicLightAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   eth.NewPublicAccountAPI(s.accountManager),
+			Public:    true,
+		}, {
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   s.subscriptionAPI,
+			Public:    true,
+		}, {
+			Namespace: "net",
+			Version:   "1.0",
+			Service:   s.netRPCService,
+			Public:    true,
+		},
+	}
+}
+
+func (s *LightEthereum) BlockChain() *light.LightChain      { return s.blockchain }
+func (s *LightEthereum) Odr() *LesOdr                       { return s.odr }
+func (s *LightEthereum) ChainDb() ethdb.Database            { return s.chainDb }
+func (s *LightEthereum) EventMux() *event.TypeMux           { return s.eventMux }
+func (s *LightEthereum) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
+
+// Protocols implements node.Service, returning the les protocols to start.
+func (s *LightEthereum) Protocols() []p2p.Protocol {
+	return s.protocolManager.SubProtocols
+}
+
+// Start implements node.Service, starting the header sync.
+func (s *LightEthereum) Start(srvr *p2p.Server) error {
+	s.protocolManager.Start(s.config.MaxPeers)
+	s.netRPCService = eth.NewPublicNetAPI(srvr, s.netVersionId)
+	return nil
+}
+
+// Stop implements node.Service, terminating the header sync and the pending
+// on demand retrievals.
+func (s *LightEthereum) Stop() error {
+	s.odr.Stop()
+	s.blockchain.Stop()
+	s.subscriptionAPI.Stop()
+	s.protocolManager.Stop()
+	s.eventMux.Stop()
+	s.chainDb.Close()
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package flowcontrol implements a client side flow control mechanism for the
+// light client protocol. Servers assign each client a buffer of request cost
+// units that recharges over time; clients track an estimate of their buffer
+// and don't send requests it can't cover.
+package flowcontrol
+
+import (
+	"sync"
+	"time"
+)
+
+// fcTimeConst is the time unit of the recharge rate.
+const fcTimeConst = time.Millisecond
+
+// ServerParams are the flow control parameters a server assigns to its
+// clients: the buffer size and the recharge rate per millisecond.
+type ServerParams struct {
+	BufLimit, MinRecharge uint64
+}
+
+// ClientNode is the server side view of a client's buffer.
+type ClientNode struct {
+	params   *ServerParams
+	bufValue uint64
+	lastTime time.Time
+	lock     sync.Mutex
+}
+
+// NewClientNode creates the buffer of a new client, starting full.
+func NewClientNode(params *ServerParams) *ClientNode {
+	return &ClientNode{
+		params:   params,
+		bufValue: params.BufLimit,
+		lastTime: time.Now(),
+	}
+}
+
+// recalcBV recharges the buffer for the time elapsed since the last update.
+func (peer *ClientNode) recalcBV(now time.Time) {
+	dt := uint64(now.Sub(peer.lastTime) / fcTimeConst)
+	if now.Before(peer.lastTime) {
+		dt = 0
+	}
+	peer.bufValue += peer.params.MinRecharge * dt
+	if peer.bufValue > peer.params.BufLimit {
+		peer.bufValue = peer.params.BufLimit
+	}
+	peer.lastTime = now
+}
+
+// AcceptRequest deducts the maximum cost of a request from the buffer,
+// returning false if the buffer can't cover it. In that case the client
+// ignored its flow control and should be dropped.
+func (peer *ClientNode) AcceptRequest(maxCost uint64) bool {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	peer.recalcBV(time.Now())
+	if maxCost > peer.bufValue {
+		return false
+	}
+	peer.bufValue -= maxCost
+	return true
+}
+
+// RequestProcessed refunds the difference between the maximum and the real
+// cost of a served request and returns the buffer value to send to the client
+// along with the reply.
+func (peer *ClientNode) RequestProcessed(maxCost, realCost uint64) uint64 {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	peer.recalcBV(time.Now())
+	if realCost < maxCost {
+		peer.bufValue += maxCost - realCost
+		if peer.bufValue > peer.params.BufLimit {
+			peer.bufValue = peer.params.BufLimit
+		}
+	}
+	return peer.bufValue
+}
+
+// ServerNode is the client side estimate of its buffer at a server.
+type ServerNode struct {
+	params   *ServerParams
+	bufEst   uint64
+	lastTime time.Time
+	pending  map[uint64]uint64 // Maximum costs of the requests awaiting a reply
+	lock     sync.RWMutex
+}
+
+// NewServerNode creates the buffer estimate for a new server, starting full.
+func NewServerNode(params *ServerParams) *ServerNode {
+	return &ServerNode{
+		params:   params,
+		bufEst:   params.BufLimit,
+		lastTime: time.Now(),
+		pending:  make(map[uint64]uint64),
+	}
+}
+
+func (peer *ServerNode) recalcBLE(now time.Time) {
+	dt := uint64(now.Sub(peer.lastTime) / fcTimeConst)
+	if now.Before(peer.lastTime) {
+		dt = 0
+	}
+	peer.bufEst += peer.params.MinRecharge * dt
+	if peer.bufEst > peer.params.BufLimit {
+		peer.bufEst = peer.params.BufLimit
+	}
+	peer.lastTime = now
+}
+
+// CanSend returns the time to wait until the buffer estimate covers the given
+// maximum cost, zero if the request can be sent right away.
+func (peer *ServerNode) CanSend(maxCost uint64) time.Duration {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	peer.recalcBLE(time.Now())
+	if maxCost > peer.params.BufLimit {
+		maxCost = peer.params.BufLimit
+	}
+	if peer.bufEst >= maxCost {
+		return 0
+	}
+	if peer.params.MinRecharge == 0 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration((maxCost-peer.bufEst+peer.params.MinRecharge-1)/peer.params.MinRecharge) * fcTimeConst
+}
+
+// QueueRequest deducts the maximum cost of a request being sent from the
+// buffer estimate. Requests without a reply are sent with a zero reqID and
+// aren't tracked as pending.
+func (peer *ServerNode) QueueRequest(reqID, maxCost uint64) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	peer.recalcBLE(time.Now())
+	if maxCost > peer.bufEst {
+		peer.bufEst = 0
+	} else {
+		peer.bufEst -= maxCost
+	}
+	if reqID != 0 {
+		peer.pending[reqID] = maxCost
+	}
+}
+
+// GotReply adjusts the buffer estimate to the buffer value reported by the
+// server with the reply to a request, less the costs of the other requests
+// still awaiting a reply, which the server may not have received yet.
+func (peer *ServerNode) GotReply(reqID, bv uint64) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	if _, ok := peer.pending[reqID]; !ok {
+		return
+	}
+	delete(peer.pending, reqID)
+
+	for _, cost := range peer.pending {
+		if cost > bv {
+			bv = 0
+		} else {
+			bv -= cost
+		}
+	}
+	if bv > peer.params.BufLimit {
+		bv = peer.params.BufLimit
+	}
+	peer.bufEst = bv
+	peer.lastTime = time.Now()
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package flowcontrol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientNodeBuffer(t *testing.T) {
+	params := &ServerParams{BufLimit: 1000, MinRecharge: 1}
+	node := NewClientNode(params)
+
+	if !node.AcceptRequest(600) {
+		t.Fatal("request within the buffer rejected")
+	}
+	if node.AcceptRequest(600) {
+		t.Fatal("request exceeding the buffer accepted")
+	}
+	if bv := node.RequestProcessed(600, 100); bv < 900 || bv > params.BufLimit {
+		t.Fatalf("buffer value after refund mismatch: have %d, want 900..%d", bv, params.BufLimit)
+	}
+	// Recharging must never go beyond the limit
+	node.lastTime = node.lastTime.Add(-time.Hour)
+	if bv := node.RequestProcessed(0, 0); bv != params.BufLimit {
+		t.Fatalf("recharged buffer value mismatch: have %d, want %d", bv, params.BufLimit)
+	}
+}
+
+func TestServerNodeEstimate(t *testing.T) {
+	params := &ServerParams{BufLimit: 1000, MinRecharge: 10}
+	node := NewServerNode(params)
+
+	if wait := node.CanSend(800); wait != 0 {
+		t.Fatalf("wait for a full buffer: have %v, want 0", wait)
+	}
+	node.QueueRequest(1, 800)
+	node.QueueRequest(2, 100)
+	if wait := node.CanSend(800); wait == 0 {
+		t.Fatal("no wait for an exhausted buffer")
+	}
+	// The server reports its buffer after the first request, the second one is
+	// still pending and must remain deducted.
+	node.GotReply(1, 500)
+	if node.bufEst != 400 {
+		t.Fatalf("buffer estimate mismatch: have %d, want 400", node.bufEst)
+	}
+	// Replies to unknown requests are ignored
+	node.GotReply(1, 1000)
+	if node.bufEst != 400 {
+		t.Fatalf("buffer estimate changed by a duplicate reply: have %d", node.bufEst)
+	}
+	if wait := node.CanSend(500); wait <= 0 || wait > 10*time.Millisecond {
+		t.Fatalf("wait for recharge mismatch: have %v, want 0..10ms", wait)
+	}
+}
@@ -0,0 +1,859 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/state"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/eth/downloader"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/p2p/discover"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+const (
+	softResponseLimit = 2 * 1024 * 1024 // Target maximum size of returned blocks, headers or node data.
+	estHeaderRlpSize  = 500             // Approximate size of an RLP encoded block header
+
+	maxResponseErrors = 50 // Number of invalid responses tolerated from a server before dropping it
+
+	ethVersion = 63 // Equivalent eth version of les servers for the downloader
+)
+
+// errIncompatibleConfig is returned if the requested protocols and configs are
+// not compatible.
+var errIncompatibleConfig = errors.New("incompatible configuration")
+
+func errResp(code errCode, format string, v ...interface{}) error {
+	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+}
+
+// BlockChain is the header chain the protocol manager serves from or syncs to,
+// either a full *core.BlockChain or a *light.LightChain.
+type BlockChain interface {
+	Status() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash)
+	CurrentHeader() *types.Header
+	GetTd(hash common.Hash) *big.Int
+	GetHeader(hash common.Hash) *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+	GetBlockHashesFromHash(hash common.Hash, max uint64) []common.Hash
+}
+
+type txPool interface {
+	// AddTransactions adds remote transactions to the pool.
+	AddTransactions(txs []*types.Transaction)
+}
+
+// ProtocolManager manages the les peers, serving requests on the server side
+// and syncing the header chain and dispatching ODR replies on the client side.
+type ProtocolManager struct {
+	lightSync   bool
+	networkId   uint64
+	chainConfig *core.ChainConfig
+	blockchain  BlockChain
+	chainDb     ethdb.Database
+	triedb      trie.Database
+	txpool      txPool
+	odr         *LesOdr
+	server      *LesServer
+	maxPeers    int
+
+	downloader *downloader.Downloader
+	peers      *peerSet
+
+	SubProtocols []p2p.Protocol
+
+	eventMux *event.TypeMux
+
+	// channels for the syncer
+	newPeerCh   chan *peer
+	quitSync    chan struct{}
+	noMorePeers chan struct{}
+
+	// wait group is used for graceful shutdowns during downloading
+	// and processing
+	wg sync.WaitGroup
+}
+
+// NewProtocolManager returns a new light ethereum sub protocol manager. With
+// lightSync set it runs a light client syncing a *light.LightChain and
+// retrieving everything else through odr; otherwise it serves light clients
+// from a full chain.
+func NewProtocolManager(config *core.ChainConfig, lightSync bool, networkId uint64, mux *event.TypeMux, blockchain BlockChain, txpool txPool, chainDb ethdb.Database, triedb trie.Database, odr *LesOdr) (*ProtocolManager, error) {
+	// Create the protocol manager with the base fields
+	manager := &ProtocolManager{
+		lightSync:   lightSync,
+		networkId:   networkId,
+		chainConfig: config,
+		blockchain:  blockchain,
+		chainDb:     chainDb,
+		triedb:      triedb,
+		txpool:      txpool,
+		odr:         odr,
+		eventMux:    mux,
+		peers:       newPeerSet(),
+		newPeerCh:   make(chan *peer),
+		quitSync:    make(chan struct{}),
+		noMorePeers: make(chan struct{}),
+	}
+	if odr != nil {
+		odr.peers = manager.peers
+		odr.removePeer = manager.removePeer
+	}
+	// Initiate a sub-protocol for every implemented version we can handle
+	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
+	for i, version := range ProtocolVersions {
+		version := version // Closure for the run
+		manager.SubProtocols = append(manager.SubProtocols, p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  ProtocolLengths[i],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				peer := newPeer(int(version), networkId, p, rw)
+				select {
+				case manager.newPeerCh <- peer:
+					manager.wg.Add(1)
+					defer manager.wg.Done()
+					return manager.handle(peer)
+				case <-manager.quitSync:
+					return p2p.DiscQuitting
+				}
+			},
+			NodeInfo: func() interface{} {
+				return manager.NodeInfo()
+			},
+			PeerInfo: func(id discover.NodeID) interface{} {
+				if p := manager.peers.Peer(fmt.Sprintf("%x", id[:8])); p != nil {
+					return p.Info()
+				}
+				return nil
+			},
+		})
+	}
+	if len(manager.SubProtocols) == 0 {
+		return nil, errIncompatibleConfig
+	}
+	if lightSync {
+		lightchain, ok := blockchain.(downloader.LightChain)
+		if !ok {
+			return nil, errIncompatibleConfig
+		}
+		manager.downloader = downloader.New(downloader.LightSync, chainDb, manager.eventMux, nil, lightchain, manager.removePeer)
+	}
+	return manager, nil
+}
+
+func (pm *ProtocolManager) removePeer(id string) {
+	// Short circuit if the peer was already removed
+	peer := pm.peers.Peer(id)
+	if peer == nil {
+		return
+	}
+	glog.V(logger.Debug).Infoln("Removing light peer", id)
+
+	if pm.lightSync {
+		pm.downloader.UnregisterPeer(id)
+	}
+	if err := pm.peers.Unregister(id); err != nil {
+		glog.V(logger.Error).Infoln("Removal failed:", err)
+	}
+	// Hard disconnect at the networking layer
+	peer.Peer.Disconnect(p2p.DiscUselessPeer)
+}
+
+func (pm *ProtocolManager) Start(maxPeers int) {
+	pm.maxPeers = maxPeers
+
+	if pm.lightSync {
+		go pm.syncer()
+	} else {
+		go pm.announceLoop()
+		go pm.drainPeers()
+	}
+}
+
+func (pm *ProtocolManager) Stop() {
+	glog.V(logger.Info).Infoln("Stopping light ethereum protocol handler...")
+
+	// Quit the sync loop.
+	// After this send has completed, no new peers will be accepted.
+	if pm.lightSync {
+		pm.noMorePeers <- struct{}{}
+	}
+	close(pm.quitSync)
+
+	// Disconnect existing sessions.
+	// This also closes the gate for any new registrations on the peer set.
+	pm.peers.Close()
+
+	// Wait for all peer handler goroutines and the loops to come down.
+	pm.wg.Wait()
+	glog.V(logger.Info).Infoln("Light ethereum protocol handler stopped")
+}
+
+// drainPeers accepts the new peers of a server, which has no syncer to
+// consume them.
+func (pm *ProtocolManager) drainPeers() {
+	for {
+		select {
+		case <-pm.newPeerCh:
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
+// handle is the callback invoked to manage the life cycle of a les peer. When
+// this function terminates, the peer is disconnected.
+func (pm *ProtocolManager) handle(p *peer) error {
+	// Ignore maxPeers if this is a trusted peer
+	if l := pm.peers.Len(); l >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
+		return p2p.DiscTooManyPeers
+	}
+	glog.V(logger.Debug).Infof("handler: %s ->connected", p)
+
+	// Execute the les handshake
+	td, head, genesis := pm.blockchain.Status()
+	headNum := pm.blockchain.CurrentHeader().Number.Uint64()
+	if err := p.Handshake(td, head, headNum, genesis, pm.server); err != nil {
+		glog.V(logger.Debug).Infof("handler: %s ->handshakefailed err=%v", p, err)
+		return err
+	}
+	// Register the peer locally
+	if err := pm.peers.Register(p); err != nil {
+		glog.V(logger.Error).Errorf("handler: %s ->addpeer err=%v", p, err)
+		return err
+	}
+	defer pm.removePeer(p.id)
+
+	if pm.lightSync {
+		// Register the server in the downloader, only to fetch headers
+		if err := pm.downloader.RegisterPeer(p.id, ethVersion, p.Name(), p.Head,
+			pm.requestHeadersByHash(p), pm.requestHeadersByNumber(p), noBodies, noReceipts, noNodeData); err != nil {
+			return err
+		}
+	} else {
+		go p.announcer()
+	}
+	// main loop. handle incoming messages.
+	for {
+		if err := pm.handleMsg(p); err != nil {
+			glog.V(logger.Debug).Infof("handler: %s ->msghandlefailed err=%v", p, err)
+			return err
+		}
+	}
+}
+
+// errLightFetch is returned by the downloader fetchers a light client doesn't
+// support: it only syncs headers.
+var errLightFetch = errors.New("light client only syncs headers")
+
+func noBodies([]common.Hash) error   { return errLightFetch }
+func noReceipts([]common.Hash) error { return errLightFetch }
+func noNodeData([]common.Hash) error { return errLightFetch }
+
+// requestHeadersByHash returns the downloader header fetcher of a server,
+// waiting for its flow control buffer before sending the request.
+func (pm *ProtocolManager) requestHeadersByHash(p *peer) func(common.Hash, int, int, bool) error {
+	return func(origin common.Hash, amount int, skip int, reverse bool) error {
+		cost := p.GetRequestCost(GetBlockHeadersMsg, amount)
+		if err := pm.waitBuffer(p, cost); err != nil {
+			return err
+		}
+		return p.RequestHeadersByHash(genReqID(), cost, origin, amount, skip, reverse)
+	}
+}
+
+// requestHeadersByNumber returns the downloader header fetcher of a server by
+// block number, waiting for its flow control buffer before sending the request.
+func (pm *ProtocolManager) requestHeadersByNumber(p *peer) func(uint64, int, int, bool) error {
+	return func(origin uint64, amount int, skip int, reverse bool) error {
+		cost := p.GetRequestCost(GetBlockHeadersMsg, amount)
+		if err := pm.waitBuffer(p, cost); err != nil {
+			return err
+		}
+		return p.RequestHeadersByNumber(genReqID(), cost, origin, amount, skip, reverse)
+	}
+}
+
+// waitBuffer blocks until the estimated flow control buffer at the server
+// covers the given cost.
+func (pm *ProtocolManager) waitBuffer(p *peer, cost uint64) error {
+	for {
+		wait := p.fcServer.CanSend(cost)
+		if wait == 0 {
+			return nil
+		}
+		select {
+		case <-time.After(wait):
+		case <-pm.quitSync:
+			return p2p.DiscQuitting
+		}
+	}
+}
+
+// announceLoop announces the new chain heads to the connected clients.
+func (pm *ProtocolManager) announceLoop() {
+	sub := pm.eventMux.Subscribe(core.ChainHeadEvent{})
+	defer sub.Unsubscribe()
+
+	var lastHead *types.Header
+	for {
+		select {
+		case ev, ok := <-sub.Chan():
+			if !ok {
+				return
+			}
+			block := ev.Data.(core.ChainHeadEvent).Block
+			header := block.Header()
+			td := pm.blockchain.GetTd(header.Hash())
+			if td == nil {
+				continue
+			}
+			var reorg uint64
+			if lastHead != nil {
+				reorg = pm.reorgDepth(lastHead, header)
+			}
+			lastHead = header
+			announce := announceData{Hash: header.Hash(), Number: header.Number.Uint64(), Td: td, ReorgDepth: reorg}
+			for _, p := range pm.peers.AllPeers() {
+				p.AsyncAnnounce(announce)
+			}
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
+// reorgDepth returns how many blocks of the previous head are rolled back by
+// the new head, walking both back to their common ancestor.
+func (pm *ProtocolManager) reorgDepth(prev, head *types.Header) uint64 {
+	a, b := prev, head
+	for a != nil && b != nil && a.Hash() != b.Hash() {
+		if a.Number.Cmp(b.Number) >= 0 {
+			a = pm.blockchain.GetHeader(a.ParentHash)
+		} else {
+			b = pm.blockchain.GetHeader(b.ParentHash)
+		}
+	}
+	if a == nil {
+		return prev.Number.Uint64()
+	}
+	return prev.Number.Uint64() - a.Number.Uint64()
+}
+
+// handleMsg is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func (pm *ProtocolManager) handleMsg(p *peer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	// accept deducts the maximum cost of a request from the client's buffer,
+	// rejecting requests for too many entries or the client's buffer can't
+	// cover, as it isn't respecting the flow control
+	var maxCost uint64
+	accept := func(reqCnt, maxCnt uint64) bool {
+		if p.fcClient == nil || reqCnt > maxCnt {
+			return false
+		}
+		costs := p.fcCosts[msg.Code]
+		maxCost = costs.baseCost + reqCnt*costs.reqCost
+		return p.fcClient.AcceptRequest(maxCost)
+	}
+	// served refunds the cost of the entries not served and returns the
+	// client's buffer value for the reply
+	served := func(cnt int) uint64 {
+		costs := p.fcCosts[msg.Code]
+		realCost := costs.baseCost + uint64(cnt)*costs.reqCost
+		if realCost > maxCost {
+			realCost = maxCost
+		}
+		return p.fcClient.RequestProcessed(maxCost, realCost)
+	}
+	// Handle the message depending on its contents
+	switch msg.Code {
+	case StatusMsg:
+		// Status messages should never arrive after the handshake
+		return errResp(ErrExtraStatusMsg, "uncontrolled status message")
+
+	case AnnounceMsg:
+		if !p.server {
+			return errResp(ErrUnexpectedResponse, "announcement from client")
+		}
+		var req announceData
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		glog.V(logger.Detail).Infof("%v: announced block #%d [%x…]", p, req.Number, req.Hash[:4])
+		p.SetHead(req)
+		select {
+		case pm.newPeerCh <- p:
+		case <-pm.quitSync:
+		}
+
+	case GetBlockHeadersMsg:
+		// Decode the complex header query
+		var req struct {
+			ReqID uint64
+			Query getBlockHeadersData
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		query := req.Query
+		if !accept(query.Amount, MaxHeaderFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		hashMode := query.Origin.Hash != (common.Hash{})
+
+		// Gather headers until the fetch or network limits is reached
+		var (
+			bytes   common.StorageSize
+			headers []*types.Header
+			unknown bool
+		)
+		for !unknown && len(headers) < int(query.Amount) && bytes < softResponseLimit {
+			// Retrieve the next header satisfying the query
+			var origin *types.Header
+			if hashMode {
+				origin = pm.blockchain.GetHeader(query.Origin.Hash)
+			} else {
+				origin = pm.blockchain.GetHeaderByNumber(query.Origin.Number)
+			}
+			if origin == nil {
+				break
+			}
+			headers = append(headers, origin)
+			bytes += estHeaderRlpSize
+
+			// Advance to the next header of the query
+			switch {
+			case hashMode && query.Reverse:
+				// Hash based traversal towards the genesis block
+				for i := 0; i < int(query.Skip)+1; i++ {
+					if header := pm.blockchain.GetHeader(query.Origin.Hash); header != nil {
+						query.Origin.Hash = header.ParentHash
+					} else {
+						unknown = true
+						break
+					}
+				}
+			case hashMode && !query.Reverse:
+				// Hash based traversal towards the leaf block
+				var (
+					current = origin.Number.Uint64()
+					next    = current + query.Skip + 1
+				)
+				if next <= current {
+					glog.V(logger.Warn).Infof("%v: GetBlockHeaders skip overflow attack (current %v, skip %v, next %v)", p, current, query.Skip, next)
+					unknown = true
+				} else if header := pm.blockchain.GetHeaderByNumber(next); header != nil {
+					if pm.blockchain.GetBlockHashesFromHash(header.Hash(), query.Skip+1)[query.Skip] == query.Origin.Hash {
+						query.Origin.Hash = header.Hash()
+					} else {
+						unknown = true
+					}
+				} else {
+					unknown = true
+				}
+			case query.Reverse:
+				// Number based traversal towards the genesis block
+				if query.Origin.Number >= query.Skip+1 {
+					query.Origin.Number -= (query.Skip + 1)
+				} else {
+					unknown = true
+				}
+
+			case !query.Reverse:
+				// Number based traversal towards the leaf block
+				query.Origin.Number += (query.Skip + 1)
+			}
+		}
+		return p.SendBlockHeaders(req.ReqID, served(len(headers)), headers)
+
+	case BlockHeadersMsg:
+		if pm.downloader == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+		// A batch of headers arrived to one of our previous requests
+		var resp struct {
+			ReqID, BV uint64
+			Headers   []*types.Header
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		if err := pm.downloader.DeliverHeaders(p.id, resp.Headers); err != nil {
+			glog.V(logger.Debug).Infof("%v: failed to deliver headers: %v", p, err)
+		}
+
+	case GetBlockBodiesMsg:
+		var req struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if !accept(uint64(len(req.Hashes)), MaxBodyFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		// Gather blocks until the fetch or network limits is reached
+		var (
+			bytes  int
+			bodies []rlp.RawValue
+		)
+		for _, hash := range req.Hashes {
+			if bytes >= softResponseLimit {
+				break
+			}
+			if data := core.GetBodyRLP(pm.chainDb, hash); len(data) != 0 {
+				bodies = append(bodies, data)
+				bytes += len(data)
+			}
+		}
+		return p.SendBlockBodiesRLP(req.ReqID, served(len(bodies)), bodies)
+
+	case BlockBodiesMsg:
+		var resp struct {
+			ReqID, BV uint64
+			Data      []*types.Body
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return pm.deliver(p, MsgBlockBodies, resp.ReqID, resp.BV, resp.Data)
+
+	case GetReceiptsMsg:
+		var req struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if !accept(uint64(len(req.Hashes)), MaxReceiptFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		// Gather state data until the fetch or network limits is reached
+		var (
+			bytes    int
+			receipts []rlp.RawValue
+		)
+		for _, hash := range req.Hashes {
+			if bytes >= softResponseLimit {
+				break
+			}
+			// Retrieve the requested block's receipts, skipping if unknown to us
+			results := core.GetBlockReceipts(pm.chainDb, hash)
+			if results == nil {
+				if header := pm.blockchain.GetHeader(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+					continue
+				}
+			}
+			// If known, encode and queue for response packet
+			if encoded, err := rlp.EncodeToBytes(results); err != nil {
+				glog.V(logger.Error).Infof("failed to encode receipt: %v", err)
+			} else {
+				receipts = append(receipts, encoded)
+				bytes += len(encoded)
+			}
+		}
+		return p.SendReceiptsRLP(req.ReqID, served(len(receipts)), receipts)
+
+	case ReceiptsMsg:
+		var resp struct {
+			ReqID, BV uint64
+			Receipts  []types.Receipts
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return pm.deliver(p, MsgReceipts, resp.ReqID, resp.BV, resp.Receipts)
+
+	case GetProofsMsg:
+		var req struct {
+			ReqID uint64
+			Reqs  []ProofReq
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if !accept(uint64(len(req.Reqs)), MaxProofsFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		// Gather state data until the fetch or network limits is reached
+		var (
+			bytes  int
+			proofs [][][]byte
+		)
+		for _, r := range req.Reqs {
+			if bytes >= softResponseLimit {
+				break
+			}
+			tr := pm.openTrie(r.BHash, r.AccKey)
+			if tr == nil {
+				continue
+			}
+			var proof light.NodeList
+			if err := tr.Prove(r.Key, r.FromLevel, &proof); err != nil {
+				continue
+			}
+			proofs = append(proofs, proof)
+			bytes += proof.DataSize()
+		}
+		return p.SendProofs(req.ReqID, served(len(proofs)), proofs)
+
+	case ProofsMsg:
+		var resp struct {
+			ReqID, BV uint64
+			Data      [][][]byte
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return pm.deliver(p, MsgProofs, resp.ReqID, resp.BV, resp.Data)
+
+	case GetCodeMsg:
+		var req struct {
+			ReqID uint64
+			Reqs  []CodeReq
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if !accept(uint64(len(req.Reqs)), MaxCodeFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		// Gather contract codes until the fetch or network limits is reached
+		var (
+			bytes int
+			data  [][]byte
+		)
+		for _, r := range req.Reqs {
+			if bytes >= softResponseLimit {
+				break
+			}
+			header := pm.blockchain.GetHeader(r.BHash)
+			if header == nil {
+				continue
+			}
+			account, err := pm.getAccount(header.Root, r.AccKey)
+			if err != nil {
+				continue
+			}
+			code, _ := pm.chainDb.Get(account.CodeHash)
+			data = append(data, code)
+			bytes += len(code)
+		}
+		return p.SendCode(req.ReqID, served(len(data)), data)
+
+	case CodeMsg:
+		var resp struct {
+			ReqID, BV uint64
+			Data      [][]byte
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return pm.deliver(p, MsgCode, resp.ReqID, resp.BV, resp.Data)
+
+	case GetHeaderProofsMsg:
+		var req struct {
+			ReqID uint64
+			Reqs  []ChtReq
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if !accept(uint64(len(req.Reqs)), MaxHeaderProofsFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		// Gather the headers with their CHT proofs until the fetch or
+		// network limits is reached
+		var (
+			bytes  int
+			proofs []ChtResp
+		)
+		for _, r := range req.Reqs {
+			if bytes >= softResponseLimit {
+				break
+			}
+			header := pm.blockchain.GetHeaderByNumber(r.BlockNum)
+			root := light.GetChtRoot(pm.chainDb, r.ChtNum)
+			if header == nil || root == (common.Hash{}) {
+				continue
+			}
+			tr, err := trie.New(root, ethdb.NewTable(pm.chainDb, light.ChtTablePrefix))
+			if err != nil {
+				continue
+			}
+			var proof light.NodeList
+			if err := tr.Prove(light.ChtKey(r.BlockNum), uint(r.FromLevel), &proof); err != nil {
+				continue
+			}
+			proofs = append(proofs, ChtResp{Header: header, Proof: proof})
+			bytes += proof.DataSize() + estHeaderRlpSize
+		}
+		return p.SendHeaderProofs(req.ReqID, served(len(proofs)), proofs)
+
+	case HeaderProofsMsg:
+		var resp struct {
+			ReqID, BV uint64
+			Data      []ChtResp
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return pm.deliver(p, MsgHeaderProofs, resp.ReqID, resp.BV, resp.Data)
+
+	case SendTxMsg:
+		if pm.txpool == nil {
+			return errResp(ErrRequestRejected, "")
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if !accept(uint64(len(txs)), MaxTxSend) {
+			return errResp(ErrRequestRejected, "")
+		}
+		pm.txpool.AddTransactions(txs)
+		served(len(txs))
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	return nil
+}
+
+// deliver hands a reply to an ODR request over to the retriever, dropping the
+// server if it keeps sending unrequested or invalid replies.
+func (pm *ProtocolManager) deliver(p *peer, msgType int, reqID, bv uint64, obj interface{}) error {
+	if pm.odr == nil || p.fcServer == nil {
+		return errResp(ErrUnexpectedResponse, "")
+	}
+	p.fcServer.GotReply(reqID, bv)
+	if err := pm.odr.Deliver(p, &Msg{MsgType: msgType, ReqID: reqID, Obj: obj}); err != nil {
+		p.responseErrors++
+		if p.responseErrors > maxResponseErrors {
+			return err
+		}
+	}
+	return nil
+}
+
+// openTrie opens the state trie of a block, or the storage trie of the
+// account with the hashed address accKey if it isn't empty.
+func (pm *ProtocolManager) openTrie(blockHash common.Hash, accKey []byte) *trie.Trie {
+	header := pm.blockchain.GetHeader(blockHash)
+	if header == nil {
+		return nil
+	}
+	root := header.Root
+	if len(accKey) > 0 {
+		account, err := pm.getAccount(root, accKey)
+		if err != nil {
+			return nil
+		}
+		root = account.Root
+	}
+	tr, err := trie.New(root, pm.triedb)
+	if err != nil {
+		return nil
+	}
+	return tr
+}
+
+// getAccount retrieves an account from the state trie with the given root.
+func (pm *ProtocolManager) getAccount(root common.Hash, accKey []byte) (state.Account, error) {
+	var account state.Account
+	tr, err := trie.New(root, pm.triedb)
+	if err != nil {
+		return account, err
+	}
+	blob, err := tr.TryGet(accKey)
+	if err != nil {
+		return account, err
+	}
+	if len(blob) == 0 {
+		return account, errors.New("account not found")
+	}
+	err = rlp.DecodeBytes(blob, &account)
+	return account, err
+}
+
+// NodeInfo represents a short summary of the light ethereum sub-protocol
+// metadata known about the host peer.
+type NodeInfo struct {
+	Network    uint64   `json:"network"`    // Ethereum network ID
+	Difficulty *big.Int `json:"difficulty"` // Total difficulty of the host's blockchain
+	Genesis    string   `json:"genesis"`    // SHA3 hash of the host's genesis block
+	Head       string   `json:"head"`       // SHA3 hash of the host's best owned block
+}
+
+// NodeInfo retrieves some protocol metadata about the running host node.
+func (pm *ProtocolManager) NodeInfo() *NodeInfo {
+	td, head, genesis := pm.blockchain.Status()
+	return &NodeInfo{
+		Network:    pm.networkId,
+		Difficulty: td,
+		Genesis:    fmt.Sprintf("%x", genesis),
+		Head:       fmt.Sprintf("%x", head),
+	}
+}
+
+// relayTx sends a transaction to all the servers whose flow control buffer
+// can take it, returning whether any of them did.
+func (pm *ProtocolManager) relayTx(tx *types.Transaction) bool {
+	var sent bool
+	for _, p := range pm.peers.AllPeers() {
+		if !p.server {
+			continue
+		}
+		cost := p.GetRequestCost(SendTxMsg, 1)
+		if p.fcServer.CanSend(cost) != 0 {
+			continue
+		}
+		if err := p.SendTxs(cost, types.Transactions{tx}); err == nil {
+			sent = true
+		}
+	}
+	return sent
+}
@@ -0,0 +1,233 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+// sendRequest sends a request with the given ID from a simulated client.
+func (p *testPeer) sendRequest(t *testing.T, code, reqID uint64, data interface{}) {
+	if _, err := p2p.Send(p.app, code, struct {
+		ReqID uint64
+		Data  interface{}
+	}{reqID, data}); err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+}
+
+// expectReply reads a reply to the request with the given ID, decoding its
+// data into result.
+func (p *testPeer) expectReply(t *testing.T, code, reqID uint64, result interface{}) {
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	defer msg.Discard()
+	if msg.Code != code {
+		t.Fatalf("reply code mismatch: have %d, want %d", msg.Code, code)
+	}
+	var resp struct {
+		ReqID, BV uint64
+		Data      rlp.RawValue
+	}
+	if err := msg.Decode(&resp); err != nil {
+		t.Fatalf("failed to decode reply: %v", err)
+	}
+	if resp.ReqID != reqID {
+		t.Fatalf("reply id mismatch: have %d, want %d", resp.ReqID, reqID)
+	}
+	if resp.BV > defaultBufLimit {
+		t.Fatalf("buffer value %d exceeds the limit", resp.BV)
+	}
+	if err := rlp.DecodeBytes(resp.Data, result); err != nil {
+		t.Fatalf("failed to decode reply data: %v", err)
+	}
+}
+
+// Tests that block headers can be retrieved from a server by number and hash.
+func TestGetBlockHeaders(t *testing.T) {
+	pm, blockchain := newTestServer(t, 16)
+	defer pm.Stop()
+	p, _ := newTestPeer(t, pm)
+	defer p.close()
+
+	tests := []struct {
+		query  *getBlockHeadersData
+		expect []uint64
+	}{
+		{&getBlockHeadersData{Origin: hashOrNumber{Number: 2}, Amount: 3}, []uint64{2, 3, 4}},
+		{&getBlockHeadersData{Origin: hashOrNumber{Number: 2}, Amount: 3, Skip: 2}, []uint64{2, 5, 8}},
+		{&getBlockHeadersData{Origin: hashOrNumber{Hash: blockchain.GetBlockByNumber(10).Hash()}, Amount: 3, Reverse: true}, []uint64{10, 9, 8}},
+		{&getBlockHeadersData{Origin: hashOrNumber{Number: 15}, Amount: 3}, []uint64{15, 16}},
+	}
+	for i, tt := range tests {
+		reqID := uint64(i + 1)
+		p.sendRequest(t, GetBlockHeadersMsg, reqID, tt.query)
+
+		var headers []*types.Header
+		p.expectReply(t, BlockHeadersMsg, reqID, &headers)
+		if len(headers) != len(tt.expect) {
+			t.Fatalf("test %d: header count mismatch: have %d, want %d", i, len(headers), len(tt.expect))
+		}
+		for j, header := range headers {
+			if want := blockchain.GetBlockByNumber(tt.expect[j]).Hash(); header.Hash() != want {
+				t.Errorf("test %d: header %d mismatch: have %x, want %x", i, j, header.Hash(), want)
+			}
+		}
+	}
+}
+
+// Tests that block bodies and receipts can be retrieved from a server.
+func TestGetBodiesAndReceipts(t *testing.T) {
+	pm, blockchain := newTestServer(t, 4)
+	defer pm.Stop()
+	p, _ := newTestPeer(t, pm)
+	defer p.close()
+
+	block := blockchain.GetBlockByNumber(1)
+	p.sendRequest(t, GetBlockBodiesMsg, 1, []common.Hash{block.Hash(), {0xff}})
+
+	var bodies []*types.Body
+	p.expectReply(t, BlockBodiesMsg, 1, &bodies)
+	if len(bodies) != 1 {
+		t.Fatalf("body count mismatch: have %d, want 1", len(bodies))
+	}
+	if have, want := types.DeriveSha(types.Transactions(bodies[0].Transactions)), block.TxHash(); have != want {
+		t.Errorf("transaction root mismatch: have %x, want %x", have, want)
+	}
+
+	p.sendRequest(t, GetReceiptsMsg, 2, []common.Hash{block.Hash()})
+
+	var receipts []types.Receipts
+	p.expectReply(t, ReceiptsMsg, 2, &receipts)
+	if len(receipts) != 1 {
+		t.Fatalf("receipts count mismatch: have %d, want 1", len(receipts))
+	}
+	if have, want := types.DeriveSha(receipts[0]), block.ReceiptHash(); have != want {
+		t.Errorf("receipt root mismatch: have %x, want %x", have, want)
+	}
+}
+
+// Tests that state and storage proofs and contract code can be retrieved from
+// a server.
+func TestGetProofsAndCode(t *testing.T) {
+	pm, blockchain := newTestServer(t, 4)
+	defer pm.Stop()
+	p, _ := newTestPeer(t, pm)
+	defer p.close()
+
+	header := blockchain.CurrentBlock().Header()
+	accKey := crypto.Keccak256(testContractAddr[:])
+	slotKey := crypto.Keccak256(common.BigToHash(big.NewInt(1)).Bytes())
+
+	p.sendRequest(t, GetProofsMsg, 1, []ProofReq{
+		{BHash: header.Hash(), Key: accKey},
+		{BHash: header.Hash(), AccKey: accKey, Key: slotKey},
+	})
+	var proofs [][][]byte
+	p.expectReply(t, ProofsMsg, 1, &proofs)
+	if len(proofs) != 2 {
+		t.Fatalf("proof count mismatch: have %d, want 2", len(proofs))
+	}
+	st, _ := blockchain.State()
+	if _, err, _ := trie.VerifyProof(header.Root, accKey, light.NodeList(proofs[0]).NodeSet()); err != nil {
+		t.Errorf("invalid account proof: %v", err)
+	}
+	account, err := pm.getAccount(header.Root, accKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err, _ := trie.VerifyProof(account.Root, slotKey, light.NodeList(proofs[1]).NodeSet()); err != nil {
+		t.Errorf("invalid storage proof: %v", err)
+	}
+
+	p.sendRequest(t, GetCodeMsg, 2, []CodeReq{{BHash: header.Hash(), AccKey: accKey}})
+	var code [][]byte
+	p.expectReply(t, CodeMsg, 2, &code)
+	if len(code) != 1 || !bytes.Equal(code[0], st.GetCode(testContractAddr)) {
+		t.Errorf("code mismatch: have %x, want %x", code, st.GetCode(testContractAddr))
+	}
+}
+
+// Tests that transactions sent by clients are added to the server's pool.
+func TestSendTx(t *testing.T) {
+	pm, _ := newTestServer(t, 0)
+	defer pm.Stop()
+	p, _ := newTestPeer(t, pm)
+	defer p.close()
+
+	tx, _ := types.NewTransaction(0, acc1Addr, big.NewInt(1), big.NewInt(21000), new(big.Int), nil).SignECDSA(testBankKey)
+	if _, err := p2p.Send(p.app, SendTxMsg, types.Transactions{tx}); err != nil {
+		t.Fatal(err)
+	}
+	added := <-pm.txpool.(*testTxPool).added
+	if len(added) != 1 || added[0].Hash() != tx.Hash() {
+		t.Errorf("added transactions mismatch: have %v, want %x", added, tx.Hash())
+	}
+}
+
+// Tests that a client requesting more than the allowed entries is dropped,
+// including amounts overflowing an int.
+func TestRequestLimit(t *testing.T) {
+	for _, amount := range []uint64{MaxHeaderFetch + 1, math.MaxUint64} {
+		pm, _ := newTestServer(t, 4)
+		p, errc := newTestPeer(t, pm)
+
+		p.sendRequest(t, GetBlockHeadersMsg, 1, &getBlockHeadersData{Origin: hashOrNumber{Number: 0}, Amount: amount})
+		if err := <-errc; err == nil {
+			t.Errorf("request for %d headers accepted", amount)
+		}
+		p.close()
+		pm.Stop()
+	}
+}
+
+// Tests that the costs of the requests not fully served are refunded.
+func TestRequestRefund(t *testing.T) {
+	pm, _ := newTestServer(t, 0)
+	defer pm.Stop()
+	p, _ := newTestPeer(t, pm)
+	defer p.close()
+
+	p.sendRequest(t, GetBlockBodiesMsg, 1, []common.Hash{{1}, {2}, {3}})
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		ReqID, BV uint64
+		Data      rlp.RawValue
+	}
+	if err := msg.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.BV != defaultBufLimit {
+		t.Errorf("buffer value mismatch: have %d, want %d", resp.BV, defaultBufLimit)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// This file contains some shared testing functionality, common to multiple
+// different files and modules being tested.
+
+package les
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/les/flowcontrol"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/p2p/discover"
+)
+
+var (
+	testBankKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testBankAddress = crypto.PubkeyToAddress(testBankKey.PublicKey)
+	testBankFunds   = big.NewInt(1000000)
+
+	acc1Addr = common.HexToAddress("0x0000000000000000000000000000000000001001")
+	acc2Addr = common.HexToAddress("0x0000000000000000000000000000000000001002")
+
+	// testContractCode stores 1 in slot 1, emits an empty log and deploys the
+	// single byte 0x00.
+	testContractCode = common.Hex2Bytes("600160015560006000a060016000f3")
+	testContractAddr = crypto.CreateAddress(testBankAddress, 0)
+
+	testConfig = core.DefaultConfigMorden.ChainConfig
+	testBank   = core.GenesisAccount{Address: testBankAddress, Balance: testBankFunds}
+)
+
+func testChainGen(i int, block *core.BlockGen) {
+	switch i {
+	case 0:
+		// The bank deploys the test contract and sends some wei to acc1.
+		tx, _ := types.NewContractCreation(block.TxNonce(testBankAddress), new(big.Int), big.NewInt(200000), new(big.Int), testContractCode).SignECDSA(testBankKey)
+		block.AddTx(tx)
+		tx, _ = types.NewTransaction(block.TxNonce(testBankAddress), acc1Addr, big.NewInt(10000), big.NewInt(21000), new(big.Int), nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	case 2:
+		tx, _ := types.NewTransaction(block.TxNonce(testBankAddress), acc2Addr, big.NewInt(1000), big.NewInt(21000), new(big.Int), nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}
+}
+
+// testTxPool is a fake transaction pool collecting the relayed transactions.
+type testTxPool struct {
+	added chan []*types.Transaction
+}
+
+func (p *testTxPool) AddTransactions(txs []*types.Transaction) {
+	p.added <- txs
+}
+
+// newTestServer creates a server protocol manager with the given number of
+// blocks in its chain.
+func newTestServer(t *testing.T, blocks int) (*ProtocolManager, *core.BlockChain) {
+	var (
+		evmux = new(event.TypeMux)
+		db, _ = ethdb.NewMemDatabase()
+	)
+	genesis := core.WriteGenesisBlockForTesting(db, testBank)
+	blockchain, err := core.NewBlockChain(db, testConfig, core.FakePow{}, evmux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, _ := core.GenerateChain(testConfig, genesis, db, blocks, testChainGen)
+	if res := blockchain.InsertChain(chain); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	pm, err := NewProtocolManager(testConfig, false, NetworkId, evmux, blockchain, &testTxPool{added: make(chan []*types.Transaction, 1)}, db, blockchain.StateDatabase().TrieDB(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.server = &LesServer{
+		protocolManager: pm,
+		defParams:       &flowcontrol.ServerParams{BufLimit: defaultBufLimit, MinRecharge: defaultMinRecharge},
+		costs:           defaultCosts,
+	}
+	pm.Start(1000)
+	return pm, blockchain
+}
+
+// newTestClient creates a light client protocol manager knowing only the
+// genesis header.
+func newTestClient(t *testing.T) (*ProtocolManager, *light.LightChain, *LesOdr) {
+	var (
+		evmux = new(event.TypeMux)
+		db, _ = ethdb.NewMemDatabase()
+	)
+	core.WriteGenesisBlockForTesting(db, testBank)
+	odr := NewLesOdr(db)
+	lightchain, err := light.NewLightChain(odr, testConfig, core.FakePow{}, evmux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm, err := NewProtocolManager(testConfig, true, NetworkId, evmux, lightchain, nil, db, nil, odr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.Start(1000)
+	return pm, lightchain, odr
+}
+
+// newTestPeerPair connects a client and a server protocol manager over a
+// message pipe, returning the server's peer on the client side once both
+// handshakes are done.
+func newTestPeerPair(t *testing.T, client, server *ProtocolManager) *peer {
+	app, net := p2p.MsgPipe()
+
+	var id1, id2 discover.NodeID
+	rand.Read(id1[:])
+	rand.Read(id2[:])
+
+	serverPeer := newPeer(lpv1, NetworkId, p2p.NewPeer(id1, "server", nil), app)
+	clientPeer := newPeer(lpv1, NetworkId, p2p.NewPeer(id2, "client", nil), net)
+
+	go server.handle(clientPeer)
+	go client.handle(serverPeer)
+
+	for i := 0; i < 100; i++ {
+		if client.peers.Peer(serverPeer.id) != nil && server.peers.Peer(clientPeer.id) != nil {
+			return serverPeer
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("handshake timed out")
+	return nil
+}
+
+// testPeer is a simulated client to test the server side message handling
+// directly.
+type testPeer struct {
+	app *p2p.MsgPipeRW // Application layer reader/writer to simulate the client
+	*peer
+}
+
+// newTestPeer connects a simulated client to a server, executing the
+// handshake.
+func newTestPeer(t *testing.T, server *ProtocolManager) (*testPeer, <-chan error) {
+	app, net := p2p.MsgPipe()
+
+	var id discover.NodeID
+	rand.Read(id[:])
+	p := newPeer(lpv1, NetworkId, p2p.NewPeer(id, "client", nil), net)
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.handle(p) }()
+
+	td, head, genesis := server.blockchain.Status()
+	var status statusData
+	msg, err := app.ReadMsg()
+	if err != nil {
+		t.Fatalf("status recv: %v", err)
+	}
+	if err := msg.Decode(&status); err != nil {
+		t.Fatalf("status decode: %v", err)
+	}
+	if !status.ServeHeaders || status.BufLimit != defaultBufLimit {
+		t.Fatalf("server status mismatch: %+v", status)
+	}
+	mine := &statusData{
+		ProtocolVersion: lpv1,
+		NetworkId:       NetworkId,
+		TD:              td,
+		HeadHash:        head,
+		HeadNum:         server.blockchain.CurrentHeader().Number.Uint64(),
+		GenesisHash:     genesis,
+	}
+	if _, err := p2p.Send(app, StatusMsg, mine); err != nil {
+		t.Fatalf("status send: %v", err)
+	}
+	return &testPeer{app: app, peer: p}, errc
+}
+
+// close terminates the simulated client.
+func (p *testPeer) close() {
+	p.app.Close()
+}
@@ -0,0 +1,223 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+)
+
+// softRequestTimeout is the time a server is given to answer an ODR request
+// before the request is sent to the next server.
+const softRequestTimeout = 5 * time.Second
+
+var (
+	errOdrStopped        = errors.New("odr retriever stopped")
+	errInvalidOdrRequest = errors.New("unsupported odr request")
+)
+
+// Message types of the replies delivered to the retriever.
+const (
+	MsgBlockBodies = iota
+	MsgCode
+	MsgReceipts
+	MsgProofs
+	MsgHeaderProofs
+)
+
+// Msg is a reply to an ODR request, delivered by the protocol manager.
+type Msg struct {
+	MsgType int
+	ReqID   uint64
+	Obj     interface{}
+}
+
+// reqIDCounter generates the request IDs, starting from a random value.
+var reqIDCounter = uint64(rand.Int63())
+
+// genReqID returns a new request ID. Zero is skipped, it marks requests
+// without a reply.
+func genReqID() uint64 {
+	for {
+		if id := atomic.AddUint64(&reqIDCounter, 1); id != 0 {
+			return id
+		}
+	}
+}
+
+// sentReq is an ODR request awaiting the reply of a server.
+type sentReq struct {
+	req   LesOdrRequest
+	peer  *peer
+	valid chan bool // Receives whether the reply was valid
+}
+
+// LesOdr implements light.OdrBackend, retrieving data on demand from the
+// connected les servers.
+type LesOdr struct {
+	db         ethdb.Database
+	peers      *peerSet
+	removePeer func(string)
+	stop       chan struct{}
+
+	lock     sync.Mutex
+	sentReqs map[uint64]*sentReq
+}
+
+// NewLesOdr creates an ODR backend storing the retrieved data in db.
+func NewLesOdr(db ethdb.Database) *LesOdr {
+	return &LesOdr{
+		db:       db,
+		stop:     make(chan struct{}),
+		sentReqs: make(map[uint64]*sentReq),
+	}
+}
+
+// Stop cancels the pending requests.
+func (odr *LesOdr) Stop() {
+	close(odr.stop)
+}
+
+// Database returns the local database the retrieved data is stored in.
+func (odr *LesOdr) Database() ethdb.Database {
+	return odr.db
+}
+
+// Deliver validates the reply to a sent request and signals the result to the
+// retriever waiting for it. Replies to unknown requests are rejected.
+func (odr *LesOdr) Deliver(p *peer, msg *Msg) error {
+	odr.lock.Lock()
+	req, ok := odr.sentReqs[msg.ReqID]
+	if ok && req.peer == p {
+		delete(odr.sentReqs, msg.ReqID)
+	}
+	odr.lock.Unlock()
+
+	if !ok || req.peer != p {
+		return errResp(ErrUnexpectedResponse, "reqID = %v", msg.ReqID)
+	}
+	valid := req.req.Validate(odr.db, msg)
+	req.valid <- valid
+	if !valid {
+		return errResp(ErrInvalidResponse, "reqID = %v", msg.ReqID)
+	}
+	return nil
+}
+
+// Retrieve sends the request to the connected servers one by one until one
+// of them delivers a valid reply, then stores the result in the local
+// database. It returns light.ErrNoPeers if none of them could.
+func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) error {
+	lreq := LesRequest(req)
+	if lreq == nil {
+		return errInvalidOdrRequest
+	}
+	tried := make(map[*peer]bool)
+	for {
+		p := odr.selectPeer(lreq, tried)
+		if p == nil {
+			return light.ErrNoPeers
+		}
+		tried[p] = true
+
+		valid, err := odr.send(ctx, p, lreq)
+		if err != nil {
+			return err
+		}
+		if valid {
+			req.StoreResult(odr.db)
+			return nil
+		}
+	}
+}
+
+// selectPeer picks the server with the highest head that can serve the
+// request and hasn't been tried yet.
+func (odr *LesOdr) selectPeer(req LesOdrRequest, tried map[*peer]bool) *peer {
+	if odr.peers == nil {
+		return nil
+	}
+	var best *peer
+	for _, p := range odr.peers.AllPeers() {
+		if tried[p] || !p.server || !req.CanSend(p) {
+			continue
+		}
+		if best == nil || p.headBlockInfo().Number > best.headBlockInfo().Number {
+			best = p
+		}
+	}
+	return best
+}
+
+// send sends a request to a server once its flow control buffer allows and
+// waits for the reply. It returns whether a valid reply arrived in time, or
+// an error if the request was cancelled.
+func (odr *LesOdr) send(ctx context.Context, p *peer, req LesOdrRequest) (bool, error) {
+	cost := req.GetCost(p)
+	for {
+		wait := p.fcServer.CanSend(cost)
+		if wait == 0 {
+			break
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-odr.stop:
+			return false, errOdrStopped
+		}
+	}
+	reqID := genReqID()
+	sent := &sentReq{req: req, peer: p, valid: make(chan bool, 1)}
+
+	odr.lock.Lock()
+	odr.sentReqs[reqID] = sent
+	odr.lock.Unlock()
+	defer func() {
+		odr.lock.Lock()
+		delete(odr.sentReqs, reqID)
+		odr.lock.Unlock()
+	}()
+
+	if err := req.Request(reqID, cost, p); err != nil {
+		glog.V(logger.Debug).Infof("%v: failed to send odr request: %v", p, err)
+		return false, nil
+	}
+	timeout := time.NewTimer(softRequestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case valid := <-sent.valid:
+		return valid, nil
+	case <-timeout.C:
+		glog.V(logger.Debug).Infof("%v: odr request timed out", p)
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-odr.stop:
+		return false, errOdrStopped
+	}
+}
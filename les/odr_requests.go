@@ -0,0 +1,268 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+// LesOdrRequest is a light.OdrRequest that can be sent to a les server and
+// validated against the reply.
+type LesOdrRequest interface {
+	// GetCost returns the maximum flow control cost of the request at a server.
+	GetCost(*peer) uint64
+	// CanSend returns whether a server serves the request.
+	CanSend(*peer) bool
+	// Request sends the request to a server.
+	Request(reqID, cost uint64, p *peer) error
+	// Validate checks the reply and fills in the result if it's valid.
+	Validate(ethdb.Database, *Msg) bool
+}
+
+// LesRequest converts a light.OdrRequest to its les form, or returns nil if
+// the request type isn't supported.
+func LesRequest(req light.OdrRequest) LesOdrRequest {
+	switch r := req.(type) {
+	case *light.BlockRequest:
+		return (*BlockRequest)(r)
+	case *light.ReceiptsRequest:
+		return (*ReceiptsRequest)(r)
+	case *light.TrieRequest:
+		return (*TrieRequest)(r)
+	case *light.CodeRequest:
+		return (*CodeRequest)(r)
+	case *light.ChtRequest:
+		return (*ChtRequest)(r)
+	default:
+		return nil
+	}
+}
+
+// BlockRequest is the les form of light.BlockRequest.
+type BlockRequest light.BlockRequest
+
+func (r *BlockRequest) GetCost(p *peer) uint64 {
+	return p.GetRequestCost(GetBlockBodiesMsg, 1)
+}
+
+func (r *BlockRequest) CanSend(p *peer) bool {
+	return true
+}
+
+func (r *BlockRequest) Request(reqID, cost uint64, p *peer) error {
+	return p.RequestBodies(reqID, cost, []common.Hash{r.Hash})
+}
+
+// Validate checks the transactions and uncles of the body against the local
+// header.
+func (r *BlockRequest) Validate(db ethdb.Database, msg *Msg) bool {
+	if msg.MsgType != MsgBlockBodies {
+		return false
+	}
+	bodies := msg.Obj.([]*types.Body)
+	if len(bodies) != 1 {
+		return false
+	}
+	header := core.GetHeader(db, r.Hash)
+	if header == nil {
+		glog.V(logger.Debug).Infof("odr: header %x not found for body", r.Hash[:4])
+		return false
+	}
+	body := bodies[0]
+	if types.DeriveSha(types.Transactions(body.Transactions)) != header.TxHash {
+		return false
+	}
+	if types.CalcUncleHash(body.Uncles) != header.UncleHash {
+		return false
+	}
+	data, err := rlp.EncodeToBytes(body)
+	if err != nil {
+		return false
+	}
+	r.Rlp = data
+	return true
+}
+
+// ReceiptsRequest is the les form of light.ReceiptsRequest.
+type ReceiptsRequest light.ReceiptsRequest
+
+func (r *ReceiptsRequest) GetCost(p *peer) uint64 {
+	return p.GetRequestCost(GetReceiptsMsg, 1)
+}
+
+func (r *ReceiptsRequest) CanSend(p *peer) bool {
+	return true
+}
+
+func (r *ReceiptsRequest) Request(reqID, cost uint64, p *peer) error {
+	return p.RequestReceipts(reqID, cost, []common.Hash{r.Hash})
+}
+
+// Validate checks the receipts against the receipt root of the local header.
+func (r *ReceiptsRequest) Validate(db ethdb.Database, msg *Msg) bool {
+	if msg.MsgType != MsgReceipts {
+		return false
+	}
+	receipts := msg.Obj.([]types.Receipts)
+	if len(receipts) != 1 {
+		return false
+	}
+	header := core.GetHeader(db, r.Hash)
+	if header == nil {
+		glog.V(logger.Debug).Infof("odr: header %x not found for receipts", r.Hash[:4])
+		return false
+	}
+	if types.DeriveSha(receipts[0]) != header.ReceiptHash {
+		return false
+	}
+	r.Receipts = receipts[0]
+	return true
+}
+
+// TrieRequest is the les form of light.TrieRequest.
+type TrieRequest light.TrieRequest
+
+func (r *TrieRequest) GetCost(p *peer) uint64 {
+	return p.GetRequestCost(GetProofsMsg, 1)
+}
+
+func (r *TrieRequest) CanSend(p *peer) bool {
+	return p.serveState
+}
+
+func (r *TrieRequest) Request(reqID, cost uint64, p *peer) error {
+	req := ProofReq{
+		BHash:  r.Id.BlockHash,
+		AccKey: r.Id.AccKey,
+		Key:    r.Key,
+	}
+	return p.RequestProofs(reqID, cost, []ProofReq{req})
+}
+
+// Validate verifies the merkle proof against the trie root.
+func (r *TrieRequest) Validate(db ethdb.Database, msg *Msg) bool {
+	if msg.MsgType != MsgProofs {
+		return false
+	}
+	proofs := msg.Obj.([][][]byte)
+	if len(proofs) != 1 {
+		return false
+	}
+	if _, err, _ := trie.VerifyProof(r.Id.Root, r.Key, light.NodeList(proofs[0]).NodeSet()); err != nil {
+		glog.V(logger.Debug).Infof("odr: invalid proof: %v", err)
+		return false
+	}
+	r.Proof = proofs[0]
+	return true
+}
+
+// CodeRequest is the les form of light.CodeRequest.
+type CodeRequest light.CodeRequest
+
+func (r *CodeRequest) GetCost(p *peer) uint64 {
+	return p.GetRequestCost(GetCodeMsg, 1)
+}
+
+func (r *CodeRequest) CanSend(p *peer) bool {
+	return p.serveState
+}
+
+func (r *CodeRequest) Request(reqID, cost uint64, p *peer) error {
+	req := CodeReq{
+		BHash:  r.Id.BlockHash,
+		AccKey: r.Id.AccKey,
+	}
+	return p.RequestCode(reqID, cost, []CodeReq{req})
+}
+
+// Validate checks the code against the requested code hash.
+func (r *CodeRequest) Validate(db ethdb.Database, msg *Msg) bool {
+	if msg.MsgType != MsgCode {
+		return false
+	}
+	data := msg.Obj.([][]byte)
+	if len(data) != 1 {
+		return false
+	}
+	if crypto.Keccak256Hash(data[0]) != r.Hash {
+		return false
+	}
+	r.Data = data[0]
+	return true
+}
+
+// ChtRequest is the les form of light.ChtRequest.
+type ChtRequest light.ChtRequest
+
+func (r *ChtRequest) GetCost(p *peer) uint64 {
+	return p.GetRequestCost(GetHeaderProofsMsg, 1)
+}
+
+func (r *ChtRequest) CanSend(p *peer) bool {
+	return p.headBlockInfo().Number >= (r.ChtNum+1)*light.ChtFrequency
+}
+
+func (r *ChtRequest) Request(reqID, cost uint64, p *peer) error {
+	req := ChtReq{
+		ChtNum:   r.ChtNum,
+		BlockNum: r.BlockNum,
+	}
+	return p.RequestHeaderProofs(reqID, cost, []ChtReq{req})
+}
+
+// Validate verifies the CHT proof of the header and its total difficulty
+// against the trusted CHT root.
+func (r *ChtRequest) Validate(db ethdb.Database, msg *Msg) bool {
+	if msg.MsgType != MsgHeaderProofs {
+		return false
+	}
+	proofs := msg.Obj.([]ChtResp)
+	if len(proofs) != 1 {
+		return false
+	}
+	proof := proofs[0]
+	if proof.Header == nil || proof.Header.Number.Uint64() != r.BlockNum {
+		return false
+	}
+	value, err, _ := trie.VerifyProof(r.ChtRoot, light.ChtKey(r.BlockNum), light.NodeList(proof.Proof).NodeSet())
+	if err != nil {
+		glog.V(logger.Debug).Infof("odr: invalid CHT proof: %v", err)
+		return false
+	}
+	var node light.ChtNode
+	if err := rlp.Decode(bytes.NewReader(value), &node); err != nil {
+		return false
+	}
+	if node.Hash != proof.Header.Hash() {
+		return false
+	}
+	r.Header = proof.Header
+	r.Td = node.Td
+	r.Proof = proof.Proof
+	return true
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/eth/downloader"
+	"github.com/ethereumproject/go-ethereum/eth/filters"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/rpc"
+)
+
+// Tests that a light client syncs the headers of a server and retrieves
+// blocks, receipts and state on demand.
+func TestLightSyncAndOdr(t *testing.T) {
+	server, blockchain := newTestServer(t, 8)
+	defer server.Stop()
+	client, lightchain, odr := newTestClient(t)
+	defer client.Stop()
+
+	p := newTestPeerPair(t, client, server)
+	pHead, pTd := p.Head()
+	if err := client.downloader.Synchronise(p.id, pHead, pTd, downloader.LightSync); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if have, want := lightchain.CurrentHeader().Hash(), blockchain.CurrentBlock().Hash(); have != want {
+		t.Fatalf("head mismatch: have %x, want %x", have, want)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := uint64(0); i <= 8; i++ {
+		want := blockchain.GetBlockByNumber(i)
+		block, err := light.GetBlock(ctx, odr, want.Hash(), i)
+		if err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		if block.Hash() != want.Hash() || len(block.Transactions()) != len(want.Transactions()) {
+			t.Errorf("block %d mismatch", i)
+		}
+		receipts, err := light.GetBlockReceipts(ctx, odr, want.Hash(), i)
+		if err != nil {
+			t.Fatalf("receipts %d: %v", i, err)
+		}
+		if types.DeriveSha(receipts) != want.ReceiptHash() {
+			t.Errorf("receipts %d mismatch", i)
+		}
+	}
+
+	st := light.NewState(ctx, lightchain.CurrentHeader(), odr)
+	want, _ := blockchain.State()
+	for _, addr := range []common.Address{testBankAddress, acc1Addr, acc2Addr, testContractAddr} {
+		if have, want := st.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("balance of %x mismatch: have %v, want %v", addr, have, want)
+		}
+	}
+	if have, want := st.GetCode(testContractAddr), want.GetCode(testContractAddr); !bytes.Equal(have, want) {
+		t.Errorf("code mismatch: have %x, want %x", have, want)
+	}
+	if have := st.GetState(testContractAddr, common.BigToHash(big.NewInt(1))); have != common.BigToHash(big.NewInt(1)) {
+		t.Errorf("storage mismatch: have %x, want 1", have)
+	}
+	if err := st.Error(); err != nil {
+		t.Errorf("state retrieval failed: %v", err)
+	}
+}
+
+// Tests that a light client finds logs by retrieving the receipts of the blocks
+// matching the filter on demand.
+func TestLightGetLogs(t *testing.T) {
+	server, blockchain := newTestServer(t, 8)
+	defer server.Stop()
+	client, lightchain, _ := newTestClient(t)
+	defer client.Stop()
+
+	p := newTestPeerPair(t, client, server)
+	pHead, pTd := p.Head()
+	if err := client.downloader.Synchronise(p.id, pHead, pTd, downloader.LightSync); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	api := &PublicLightAPI{bc: lightchain}
+	logs, err := api.GetLogs(ctx, filters.NewFilterArgs{FromBlock: 0, ToBlock: rpc.LatestBlockNumber, Addresses: []common.Address{testContractAddr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The contract creation in block 1 emits the only log
+	tx := blockchain.GetBlockByNumber(1).Transactions()[0]
+	if len(logs) != 1 {
+		t.Fatalf("log count mismatch: have %d, want 1", len(logs))
+	}
+	if log := logs[0]; log.Address != testContractAddr || log.BlockNumber != 1 || log.TxHash != tx.Hash() || log.TxIndex != 0 {
+		t.Errorf("log mismatch: have %+v", log)
+	}
+	logs, err = api.GetLogs(ctx, filters.NewFilterArgs{FromBlock: 0, ToBlock: rpc.LatestBlockNumber, Addresses: []common.Address{acc1Addr}})
+	if err != nil || len(logs) != 0 {
+		t.Errorf("unexpected logs of another address: %v, %v", logs, err)
+	}
+}
+
+// Tests that retrievals fail without a server to ask.
+func TestOdrNoPeers(t *testing.T) {
+	client, _, odr := newTestClient(t)
+	defer client.Stop()
+
+	if err := odr.Retrieve(context.Background(), &light.BlockRequest{Hash: common.Hash{1}, Number: 1}); err != light.ErrNoPeers {
+		t.Errorf("error mismatch: have %v, want %v", err, light.ErrNoPeers)
+	}
+}
@@ -0,0 +1,478 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/les/flowcontrol"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/p2p"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+var (
+	errClosed            = errors.New("peer set is closed")
+	errAlreadyRegistered = errors.New("peer is already registered")
+	errNotRegistered     = errors.New("peer is not registered")
+)
+
+const (
+	maxQueuedAnns    = 20 // Maximum number of block announcements to queue up before dropping them
+	handshakeTimeout = 5 * time.Second
+)
+
+// PeerInfo represents a short summary of the les sub-protocol metadata known
+// about a connected peer.
+type PeerInfo struct {
+	Version    int      `json:"version"`    // Light protocol version negotiated
+	Difficulty *big.Int `json:"difficulty"` // Total difficulty of the peer's blockchain
+	Head       string   `json:"head"`       // SHA3 hash of the peer's best owned block
+	Server     bool     `json:"server"`     // Whether the peer serves light clients
+}
+
+type peer struct {
+	id string
+
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+
+	version int    // Protocol version negotiated
+	network uint64 // Network ID being on
+
+	headInfo announceData
+	lock     sync.RWMutex
+
+	server         bool                    // Whether the remote side is a server
+	serveState     bool                    // Whether the remote server serves state (proofs and code)
+	fcClient       *flowcontrol.ClientNode // Server side: the buffer of the remote client
+	fcServer       *flowcontrol.ServerNode // Client side: the estimated buffer at the remote server
+	fcCosts        requestCostTable        // Request costs announced by the server
+	queuedAnns     chan announceData       // Server side: queue of blocks to announce to the client
+	term           chan struct{}           // Termination channel to stop the announcer
+	responseErrors int                     // Number of invalid responses received from the server
+}
+
+func newPeer(version int, network uint64, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	id := p.ID()
+
+	return &peer{
+		Peer:       p,
+		rw:         rw,
+		version:    version,
+		network:    network,
+		id:         fmt.Sprintf("%x", id[:8]),
+		queuedAnns: make(chan announceData, maxQueuedAnns),
+		term:       make(chan struct{}),
+	}
+}
+
+// announcer is a write loop sending the queued block announcements to a
+// client, so that a slow client doesn't block the chain event handling.
+func (p *peer) announcer() {
+	for {
+		select {
+		case announce := <-p.queuedAnns:
+			if _, err := p2p.Send(p.rw, AnnounceMsg, announce); err != nil {
+				return
+			}
+			glog.V(logger.Detail).Infof("%v: announced block #%d [%x…]", p, announce.Number, announce.Hash[:4])
+
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// close signals the announcer to terminate.
+func (p *peer) close() {
+	close(p.term)
+}
+
+// Info gathers and returns a collection of metadata known about a peer.
+func (p *peer) Info() *PeerInfo {
+	hash, td := p.Head()
+	return &PeerInfo{
+		Version:    p.version,
+		Difficulty: td,
+		Head:       fmt.Sprintf("%x", hash),
+		Server:     p.server,
+	}
+}
+
+// Head retrieves a copy of the current head (most recent) hash of the peer.
+func (p *peer) Head() (hash common.Hash, td *big.Int) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.headInfo.Hash, new(big.Int).Set(p.headInfo.Td)
+}
+
+// headBlockInfo returns the announced head of the peer.
+func (p *peer) headBlockInfo() announceData {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.headInfo
+}
+
+// SetHead updates the head of the peer from an announcement.
+func (p *peer) SetHead(head announceData) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.headInfo = head
+}
+
+// AsyncAnnounce queues a block announcement for the client, dropping it if
+// the client can't keep up.
+func (p *peer) AsyncAnnounce(announce announceData) {
+	select {
+	case p.queuedAnns <- announce:
+	default:
+		glog.V(logger.Debug).Infof("%v: dropping block announcement #%d", p, announce.Number)
+	}
+}
+
+// GetRequestCost returns the maximum flow control cost of a request for the
+// given amount of entries, according to the server's cost table.
+func (p *peer) GetRequestCost(msgcode uint64, amount int) uint64 {
+	costs := p.fcCosts[msgcode]
+	if costs == nil {
+		return 0
+	}
+	return costs.baseCost + costs.reqCost*uint64(amount)
+}
+
+// sendRequest queues the cost of a request at the flow control estimate and
+// sends it to the server.
+func (p *peer) sendRequest(msgcode, reqID, cost uint64, data interface{}) error {
+	p.fcServer.QueueRequest(reqID, cost)
+	_, err := p2p.Send(p.rw, msgcode, struct {
+		ReqID uint64
+		Data  interface{}
+	}{reqID, data})
+	return err
+}
+
+// sendResponse sends a reply to a request, along with the client's remaining
+// buffer value.
+func (p *peer) sendResponse(msgcode, reqID, bv uint64, data interface{}) error {
+	_, err := p2p.Send(p.rw, msgcode, struct {
+		ReqID, BV uint64
+		Data      interface{}
+	}{reqID, bv, data})
+	return err
+}
+
+// SendBlockHeaders sends a batch of block headers to the remote peer.
+func (p *peer) SendBlockHeaders(reqID, bv uint64, headers []*types.Header) error {
+	return p.sendResponse(BlockHeadersMsg, reqID, bv, headers)
+}
+
+// SendBlockBodiesRLP sends a batch of block contents to the remote peer from
+// an already RLP encoded format.
+func (p *peer) SendBlockBodiesRLP(reqID, bv uint64, bodies []rlp.RawValue) error {
+	return p.sendResponse(BlockBodiesMsg, reqID, bv, bodies)
+}
+
+// SendReceiptsRLP sends a batch of transaction receipts, corresponding to the
+// ones requested from an already RLP encoded format.
+func (p *peer) SendReceiptsRLP(reqID, bv uint64, receipts []rlp.RawValue) error {
+	return p.sendResponse(ReceiptsMsg, reqID, bv, receipts)
+}
+
+// SendProofs sends a batch of merkle proofs, corresponding to the ones
+// requested.
+func (p *peer) SendProofs(reqID, bv uint64, proofs [][][]byte) error {
+	return p.sendResponse(ProofsMsg, reqID, bv, proofs)
+}
+
+// SendCode sends a batch of contract codes, corresponding to the ones
+// requested.
+func (p *peer) SendCode(reqID, bv uint64, data [][]byte) error {
+	return p.sendResponse(CodeMsg, reqID, bv, data)
+}
+
+// SendHeaderProofs sends a batch of canonical headers with their CHT proofs,
+// corresponding to the ones requested.
+func (p *peer) SendHeaderProofs(reqID, bv uint64, proofs []ChtResp) error {
+	return p.sendResponse(HeaderProofsMsg, reqID, bv, proofs)
+}
+
+// RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
+// specified header query, based on the hash of an origin block.
+func (p *peer) RequestHeadersByHash(reqID, cost uint64, origin common.Hash, amount int, skip int, reverse bool) error {
+	glog.V(logger.Debug).Infof("%v fetching %d headers from %x, skipping %d (reverse = %v)", p, amount, origin[:4], skip, reverse)
+	return p.sendRequest(GetBlockHeadersMsg, reqID, cost, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+}
+
+// RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
+// specified header query, based on the number of an origin block.
+func (p *peer) RequestHeadersByNumber(reqID, cost, origin uint64, amount int, skip int, reverse bool) error {
+	glog.V(logger.Debug).Infof("%v fetching %d headers from #%d, skipping %d (reverse = %v)", p, amount, origin, skip, reverse)
+	return p.sendRequest(GetBlockHeadersMsg, reqID, cost, &getBlockHeadersData{Origin: hashOrNumber{Number: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
+}
+
+// RequestBodies fetches a batch of blocks' bodies corresponding to the hashes
+// specified.
+func (p *peer) RequestBodies(reqID, cost uint64, hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("%v fetching %d block bodies", p, len(hashes))
+	return p.sendRequest(GetBlockBodiesMsg, reqID, cost, hashes)
+}
+
+// RequestReceipts fetches a batch of transaction receipts from a remote node.
+func (p *peer) RequestReceipts(reqID, cost uint64, hashes []common.Hash) error {
+	glog.V(logger.Debug).Infof("%v fetching %d receipts", p, len(hashes))
+	return p.sendRequest(GetReceiptsMsg, reqID, cost, hashes)
+}
+
+// RequestProofs fetches a batch of merkle proofs from a remote node.
+func (p *peer) RequestProofs(reqID, cost uint64, reqs []ProofReq) error {
+	glog.V(logger.Debug).Infof("%v fetching %d proofs", p, len(reqs))
+	return p.sendRequest(GetProofsMsg, reqID, cost, reqs)
+}
+
+// RequestCode fetches a batch of arbitrary data from a node's known state
+// data, corresponding to the specified hashes.
+func (p *peer) RequestCode(reqID, cost uint64, reqs []CodeReq) error {
+	glog.V(logger.Debug).Infof("%v fetching %d codes", p, len(reqs))
+	return p.sendRequest(GetCodeMsg, reqID, cost, reqs)
+}
+
+// RequestHeaderProofs fetches a batch of header merkle proofs from a remote node.
+func (p *peer) RequestHeaderProofs(reqID, cost uint64, reqs []ChtReq) error {
+	glog.V(logger.Debug).Infof("%v fetching %d header proofs", p, len(reqs))
+	return p.sendRequest(GetHeaderProofsMsg, reqID, cost, reqs)
+}
+
+// SendTxs sends a batch of transactions to the server, which adds them to its
+// pool without replying.
+func (p *peer) SendTxs(cost uint64, txs types.Transactions) error {
+	glog.V(logger.Debug).Infof("%v sending %d transactions", p, len(txs))
+	p.fcServer.QueueRequest(0, cost)
+	_, err := p2p.Send(p.rw, SendTxMsg, txs)
+	return err
+}
+
+// Handshake executes the les protocol handshake, negotiating version number,
+// network IDs, difficulties, head and genesis blocks. A server additionally
+// announces what it serves and its flow control parameters.
+func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis common.Hash, server *LesServer) error {
+	status := &statusData{
+		ProtocolVersion: uint32(p.version),
+		NetworkId:       uint32(p.network),
+		TD:              td,
+		HeadHash:        head,
+		HeadNum:         headNum,
+		GenesisHash:     genesis,
+	}
+	if server != nil {
+		status.ServeHeaders = true
+		status.ServeState = true
+		status.BufLimit = server.defParams.BufLimit
+		status.MinRecharge = server.defParams.MinRecharge
+		status.CostTable = server.costs.encode()
+	}
+	// Send out own handshake in a new thread
+	errc := make(chan error, 2)
+	var remote statusData // safe to read after two values have been received from errc
+
+	go func() {
+		_, err := p2p.Send(p.rw, StatusMsg, status)
+		errc <- err
+	}()
+	go func() {
+		errc <- p.readStatus(&remote, genesis)
+	}()
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		}
+	}
+	if server != nil {
+		// Serving a client, its buffer starts full
+		p.fcClient = flowcontrol.NewClientNode(server.defParams)
+		p.fcCosts = server.costs
+	} else {
+		// Connecting to a server, which must announce what it serves
+		if !remote.ServeHeaders {
+			return errResp(ErrUselessPeer, "peer cannot serve requests")
+		}
+		params := &flowcontrol.ServerParams{BufLimit: remote.BufLimit, MinRecharge: remote.MinRecharge}
+		p.fcServer = flowcontrol.NewServerNode(params)
+		p.fcCosts = remote.CostTable.decode()
+		p.server = true
+		p.serveState = remote.ServeState
+	}
+	p.headInfo = announceData{Hash: remote.HeadHash, Number: remote.HeadNum, Td: remote.TD}
+	return nil
+}
+
+func (p *peer) readStatus(status *statusData, genesis common.Hash) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Code != StatusMsg {
+		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	// Decode the handshake and make sure everything matches
+	if err := msg.Decode(status); err != nil {
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if status.GenesisHash != genesis {
+		return errResp(ErrGenesisBlockMismatch, "%x (!= %x…)", status.GenesisHash, genesis.Bytes()[:8])
+	}
+	if status.NetworkId != uint32(p.network) {
+		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, p.network)
+	}
+	if int(status.ProtocolVersion) != p.version {
+		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+	}
+	if status.TD == nil {
+		return errResp(ErrDecode, "missing total difficulty")
+	}
+	return nil
+}
+
+// String implements fmt.Stringer.
+func (p *peer) String() string {
+	return fmt.Sprintf("peer:%s@[%s] les/%d", p.id, p.Name(), p.version)
+}
+
+// peerSet represents the collection of active peers currently participating in
+// the Light Ethereum sub-protocol.
+type peerSet struct {
+	peers  map[string]*peer
+	lock   sync.RWMutex
+	closed bool
+}
+
+// newPeerSet creates a new peer set to track the active participants.
+func newPeerSet() *peerSet {
+	return &peerSet{
+		peers: make(map[string]*peer),
+	}
+}
+
+// Register injects a new peer into the working set, or returns an error if the
+// peer is already known.
+func (ps *peerSet) Register(p *peer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if ps.closed {
+		return errClosed
+	}
+	if _, ok := ps.peers[p.id]; ok {
+		return errAlreadyRegistered
+	}
+	ps.peers[p.id] = p
+	return nil
+}
+
+// Unregister removes a remote peer from the active set, disabling any further
+// actions to/from that particular entity.
+func (ps *peerSet) Unregister(id string) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	p, ok := ps.peers[id]
+	if !ok {
+		return errNotRegistered
+	}
+	delete(ps.peers, id)
+	p.close()
+	return nil
+}
+
+// Peer retrieves the registered peer with the given id.
+func (ps *peerSet) Peer(id string) *peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return ps.peers[id]
+}
+
+// Len returns if the current number of peers in the set.
+func (ps *peerSet) Len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
+
+// AllPeers returns all the peers in the set.
+func (ps *peerSet) AllPeers() []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		list = append(list, p)
+	}
+	return list
+}
+
+// BestPeer retrieves the known peer with the currently highest total difficulty.
+func (ps *peerSet) BestPeer() *peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	var (
+		bestPeer *peer
+		bestTd   *big.Int
+	)
+	for _, p := range ps.peers {
+		if _, td := p.Head(); bestPeer == nil || td.Cmp(bestTd) > 0 {
+			bestPeer, bestTd = p, td
+		}
+	}
+	return bestPeer
+}
+
+// Close disconnects all peers.
+// No new peers can be registered after Close has returned.
+func (ps *peerSet) Close() {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	for _, p := range ps.peers {
+		p.Disconnect(p2p.DiscQuitting)
+	}
+	ps.closed = true
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"github.com/ethereumproject/go-ethereum/eth"
+	"github.com/ethereumproject/go-ethereum/les/flowcontrol"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/p2p"
+)
+
+// Flow control parameters assigned to every client. The recharge rate is
+// scaled by the percentage of time the server is willing to spend serving.
+const (
+	defaultBufLimit    = 300000000
+	defaultMinRecharge = 50000
+)
+
+// defaultCosts are the request costs announced to the clients.
+var defaultCosts = requestCostTable{
+	GetBlockHeadersMsg: {150000, 30000},
+	GetBlockBodiesMsg:  {0, 700000},
+	GetReceiptsMsg:     {0, 1000000},
+	GetCodeMsg:         {0, 450000},
+	GetProofsMsg:       {0, 600000},
+	GetHeaderProofsMsg: {0, 1000000},
+	SendTxMsg:          {0, 450000},
+}
+
+// LesServer serves light clients from the chain of a full node.
+type LesServer struct {
+	protocolManager *ProtocolManager
+	defParams       *flowcontrol.ServerParams
+	costs           requestCostTable
+}
+
+// NewLesServer creates a light client server on top of a full node, spending
+// up to config.LightServ percent of its time serving.
+func NewLesServer(e *eth.Ethereum, config *eth.Config) (*LesServer, error) {
+	blockchain := e.BlockChain()
+	pm, err := NewProtocolManager(blockchain.Config(), false, uint64(config.NetworkId), e.EventMux(), blockchain, e.TxPool(), e.ChainDb(), blockchain.StateDatabase().TrieDB(), nil)
+	if err != nil {
+		return nil, err
+	}
+	srv := &LesServer{
+		protocolManager: pm,
+		defParams: &flowcontrol.ServerParams{
+			BufLimit:    defaultBufLimit,
+			MinRecharge: defaultMinRecharge * uint64(config.LightServ) / 100,
+		},
+		costs: defaultCosts,
+	}
+	pm.server = srv
+	return srv, nil
+}
+
+// Protocols returns the les protocols to run alongside eth.
+func (s *LesServer) Protocols() []p2p.Protocol {
+	return s.protocolManager.SubProtocols
+}
+
+// Start starts serving light clients.
+func (s *LesServer) Start(srvr *p2p.Server) {
+	s.protocolManager.Start(srvr.MaxPeers)
+	glog.V(logger.Info).Infof("Light ethereum server started, serving %d%% of the time", s.defParams.MinRecharge*100/defaultMinRecharge)
+}
+
+// Stop stops serving light clients.
+func (s *LesServer) Stop() {
+	s.protocolManager.Stop()
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/eth/downloader"
+	"github.com/ethereumproject/go-ethereum/light"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+)
+
+// forceSyncCycle is the time interval to force syncs, even if no new peer or
+// announcement arrived.
+const forceSyncCycle = 10 * time.Second
+
+// checkpointTimeout is the time allowed for retrieving the head of the trusted
+// checkpoint before a header sync.
+const checkpointTimeout = 30 * time.Second
+
+// syncer is responsible for periodically synchronising the light chain with
+// the servers, whenever a new server connects or announces a new head.
+func (pm *ProtocolManager) syncer() {
+	// Ensure cleanup of the sync mechanism
+	defer pm.downloader.Terminate()
+
+	// Wait for different events to fire synchronisation operations
+	forceSync := time.NewTicker(forceSyncCycle)
+	defer forceSync.Stop()
+
+	for {
+		select {
+		case <-pm.newPeerCh:
+			if !pm.downloader.Synchronising() {
+				go pm.synchronise(pm.peers.BestPeer())
+			}
+
+		case <-forceSync.C:
+			// Force a sync even if no announcement arrived
+			if !pm.downloader.Synchronising() {
+				go pm.synchronise(pm.peers.BestPeer())
+			}
+
+		case <-pm.noMorePeers:
+			return
+		}
+	}
+}
+
+// synchronise tries to sync up the local header chain with a remote server.
+func (pm *ProtocolManager) synchronise(peer *peer) {
+	// Short circuit if no peers are available
+	if peer == nil {
+		return
+	}
+	// Make sure the peer's TD is higher than our own
+	head := pm.blockchain.CurrentHeader()
+	td := pm.blockchain.GetTd(head.Hash())
+	pHead, pTd := peer.Head()
+	if td != nil && pTd.Cmp(td) <= 0 {
+		return
+	}
+	// Jump to the trusted checkpoint first if the server is past it, so the
+	// headers before it don't need to be downloaded
+	if lc, ok := pm.blockchain.(*light.LightChain); ok {
+		if cp := lc.TrustedCheckpoint(); cp != nil && peer.headBlockInfo().Number >= light.ChtSectionHead(cp.SectionIndex) {
+			ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+			err := lc.SyncCheckpoint(ctx)
+			cancel()
+			if err != nil {
+				glog.V(logger.Warn).Infof("Failed to sync trusted checkpoint: %v", err)
+				return
+			}
+		}
+	}
+	if err := pm.downloader.Synchronise(peer.id, pHead, pTd, downloader.LightSync); err != nil {
+		glog.V(logger.Debug).Infof("%v: light sync failed: %v", peer, err)
+	}
+}
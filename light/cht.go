@@ -0,0 +1,179 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+// ChtFrequency is the number of blocks covered by a canonical hash trie (CHT)
+// section. The CHT of section n maps the numbers of all the blocks up to
+// (n+1)*ChtFrequency-1 to their canonical hashes and total difficulties.
+const ChtFrequency = 32768
+
+// ChtConfirmations is the number of confirmations a section needs before its
+// CHT is generated, so that it isn't affected by the usual reorgs.
+const ChtConfirmations = 2048
+
+// ChtTablePrefix is the database table holding the CHT trie nodes.
+const ChtTablePrefix = "cht-"
+
+const (
+	chtIndexPrefix = "chtIndex-"            // Database table of the CHT indexer progress
+	chtThrottling  = 100 * time.Millisecond // Wait between processing two sections
+)
+
+var (
+	chtRootPrefix     = []byte("chtRoot-")          // chtRootPrefix + section (uint64 big endian) -> CHT root
+	chtTrustedSection = []byte("chtTrustedSection") // Section of the trusted checkpoint (uint64 big endian)
+)
+
+// ChtNode is the value stored in the CHT for each block number.
+type ChtNode struct {
+	Hash common.Hash
+	Td   *big.Int
+}
+
+// ChtKey returns the CHT key of a block number.
+func ChtKey(number uint64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], number)
+	return key[:]
+}
+
+// GetChtRoot returns the root of the CHT of a section, or the zero hash if it
+// isn't known.
+func GetChtRoot(db ethdb.Database, section uint64) common.Hash {
+	data, _ := db.Get(append(chtRootPrefix, ChtKey(section)...))
+	return common.BytesToHash(data)
+}
+
+// StoreChtRoot stores the root of the CHT of a section.
+func StoreChtRoot(db ethdb.Database, section uint64, root common.Hash) error {
+	return db.Put(append(chtRootPrefix, ChtKey(section)...), root.Bytes())
+}
+
+// getTrustedChtSection returns the section of the trusted checkpoint, if any.
+func getTrustedChtSection(db ethdb.Database) (uint64, bool) {
+	data, _ := db.Get(chtTrustedSection)
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// storeTrustedChtSection stores the section of the trusted checkpoint.
+func storeTrustedChtSection(db ethdb.Database, section uint64) error {
+	return db.Put(chtTrustedSection, ChtKey(section))
+}
+
+// ChtSectionHead returns the number of the last block covered by the CHT of a
+// section.
+func ChtSectionHead(section uint64) uint64 {
+	return (section+1)*ChtFrequency - 1
+}
+
+// ChtIndexerBackend implements core.ChainIndexerBackend, generating the CHT of
+// each section on top of the CHT of the previous one.
+type ChtIndexerBackend struct {
+	db   ethdb.Database // Chain database the headers and total difficulties are read from
+	cdb  ethdb.Database // Table the CHT nodes are written to
+	size uint64         // Number of blocks in a section
+
+	section uint64
+	head    common.Hash
+	trie    *trie.Trie
+	err     error
+}
+
+// NewChtIndexer returns a chain indexer that generates the canonical hash
+// tries of the chain, storing them in the chain database.
+func NewChtIndexer(db ethdb.Database) *core.ChainIndexer {
+	backend := &ChtIndexerBackend{
+		db:   db,
+		cdb:  ethdb.NewTable(db, ChtTablePrefix),
+		size: ChtFrequency,
+	}
+	table := ethdb.NewTable(db, chtIndexPrefix)
+
+	return core.NewChainIndexer(db, table, backend, ChtFrequency, ChtConfirmations, chtThrottling, "cht")
+}
+
+// Reset implements core.ChainIndexerBackend, opening the CHT of the previous
+// section to extend it with the blocks of the new one.
+func (c *ChtIndexerBackend) Reset(section uint64, lastSectionHead common.Hash) error {
+	var root common.Hash
+	if section > 0 {
+		if root = GetChtRoot(c.db, section-1); root == (common.Hash{}) {
+			return fmt.Errorf("missing CHT root of section %d", section-1)
+		}
+	}
+	t, err := trie.New(root, c.cdb)
+	c.trie, c.err, c.section, c.head = t, err, section, common.Hash{}
+	return err
+}
+
+// Process implements core.ChainIndexerBackend, adding the hash and total
+// difficulty of a new header to the CHT.
+func (c *ChtIndexerBackend) Process(header *types.Header) {
+	hash := header.Hash()
+	td := core.GetTd(c.db, hash)
+	if td == nil {
+		if c.err == nil {
+			c.err = fmt.Errorf("missing total difficulty of block #%d [%x…]", header.Number, hash[:4])
+		}
+		return
+	}
+	data, err := rlp.EncodeToBytes(ChtNode{Hash: hash, Td: td})
+	if err != nil {
+		if c.err == nil {
+			c.err = err
+		}
+		return
+	}
+	c.trie.Update(ChtKey(header.Number.Uint64()), data)
+	c.head = hash
+}
+
+// Commit implements core.ChainIndexerBackend, writing the CHT nodes and the
+// root of the finished section into the database.
+func (c *ChtIndexerBackend) Commit() error {
+	if c.err != nil {
+		return c.err
+	}
+	root, err := c.trie.CommitTo(c.cdb)
+	if err != nil {
+		return err
+	}
+	if err := StoreChtRoot(c.db, c.section, root); err != nil {
+		return err
+	}
+	glog.V(logger.Info).Infof("Generated CHT section %d: root %x, head #%d [%x]", c.section, root, (c.section+1)*c.size-1, c.head)
+	return nil
+}
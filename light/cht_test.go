@@ -0,0 +1,167 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+// newTestHeaderChain writes a canonical chain of n bare headers on top of the
+// test genesis block, returning all of them including the genesis header.
+func newTestHeaderChain(db ethdb.Database, n int) []*types.Header {
+	genesis := core.WriteGenesisBlockForTesting(db)
+
+	headers := []*types.Header{genesis.Header()}
+	td := new(big.Int).Set(genesis.Difficulty())
+	for i := 1; i <= n; i++ {
+		header := &types.Header{
+			ParentHash: headers[i-1].Hash(),
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(131072),
+			Time:       big.NewInt(int64(i * 15)),
+			GasLimit:   new(big.Int).Set(genesis.GasLimit()),
+			GasUsed:    new(big.Int),
+		}
+		td.Add(td, header.Difficulty)
+
+		hash := header.Hash()
+		core.WriteHeader(db, header)
+		core.WriteTd(db, hash, td)
+		core.WriteCanonicalHash(db, hash, uint64(i))
+		headers = append(headers, header)
+	}
+	core.WriteHeadHeaderHash(db, headers[n].Hash())
+	return headers
+}
+
+// indexCht runs a CHT indexer backend with the given section size over the
+// headers directly, without the chain indexer around it.
+func indexCht(t *testing.T, db ethdb.Database, headers []*types.Header, size uint64) {
+	backend := &ChtIndexerBackend{db: db, cdb: ethdb.NewTable(db, ChtTablePrefix), size: size}
+	for section := uint64(0); (section+1)*size <= uint64(len(headers)); section++ {
+		if err := backend.Reset(section, common.Hash{}); err != nil {
+			t.Fatalf("section %d: reset failed: %v", section, err)
+		}
+		for _, header := range headers[section*size : (section+1)*size] {
+			backend.Process(header)
+		}
+		if err := backend.Commit(); err != nil {
+			t.Fatalf("section %d: commit failed: %v", section, err)
+		}
+	}
+}
+
+func TestChtIndexer(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	headers := newTestHeaderChain(db, 47)
+	indexCht(t, db, headers, 16)
+
+	// The CHT of the last section covers the blocks of all the sections
+	root := GetChtRoot(db, 2)
+	if root == (common.Hash{}) {
+		t.Fatal("missing CHT root of the last section")
+	}
+	tr, err := trie.New(root, ethdb.NewTable(db, ChtTablePrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range headers {
+		number := header.Number.Uint64()
+		proof, _ := ethdb.NewMemDatabase()
+		if err := tr.Prove(ChtKey(number), 0, proof); err != nil {
+			t.Fatalf("block #%d: proof failed: %v", number, err)
+		}
+		data, err, _ := trie.VerifyProof(root, ChtKey(number), proof)
+		if err != nil {
+			t.Fatalf("block #%d: invalid proof: %v", number, err)
+		}
+		var node ChtNode
+		if err := rlp.DecodeBytes(data, &node); err != nil {
+			t.Fatalf("block #%d: invalid CHT node: %v", number, err)
+		}
+		if node.Hash != header.Hash() {
+			t.Errorf("block #%d: hash mismatch: have %x, want %x", number, node.Hash, header.Hash())
+		}
+		if td := core.GetTd(db, header.Hash()); node.Td.Cmp(td) != 0 {
+			t.Errorf("block #%d: td mismatch: have %v, want %v", number, node.Td, td)
+		}
+	}
+}
+
+func TestSyncCheckpoint(t *testing.T) {
+	sdb, _ := ethdb.NewMemDatabase()
+	ldb, _ := ethdb.NewMemDatabase()
+
+	headers := newTestHeaderChain(sdb, ChtFrequency-1)
+	indexCht(t, sdb, headers, ChtFrequency)
+	core.WriteGenesisBlockForTesting(ldb)
+
+	odr := &testOdr{sdb: sdb, ldb: ldb}
+	lightchain, err := NewLightChain(odr, core.DefaultConfigMorden.ChainConfig, core.FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := headers[ChtFrequency-1]
+
+	// A checkpoint whose head isn't in its CHT is rejected
+	bad := &core.TrustedCheckpoint{SectionIndex: 0, ChtRoot: GetChtRoot(sdb, 0), SectionHead: common.Hash{1}}
+	if err := lightchain.AddTrustedCheckpoint(bad); err != nil {
+		t.Fatal(err)
+	}
+	if err := lightchain.SyncCheckpoint(context.Background()); err == nil {
+		t.Fatal("mismatching checkpoint head accepted")
+	}
+	if number := lightchain.CurrentHeader().Number.Uint64(); number != 0 {
+		t.Fatalf("head moved to #%d on a mismatching checkpoint", number)
+	}
+	if hash := core.GetCanonicalHash(ldb, head.Number.Uint64()); hash != (common.Hash{}) {
+		t.Fatalf("mismatching checkpoint head kept as canonical: %x", hash)
+	}
+
+	// A valid checkpoint moves the head to the proven section head
+	good := &core.TrustedCheckpoint{SectionIndex: 0, ChtRoot: GetChtRoot(sdb, 0), SectionHead: head.Hash()}
+	if err := lightchain.AddTrustedCheckpoint(good); err != nil {
+		t.Fatal(err)
+	}
+	if err := lightchain.SyncCheckpoint(context.Background()); err != nil {
+		t.Fatalf("checkpoint sync failed: %v", err)
+	}
+	if have, want := lightchain.CurrentHeader().Hash(), head.Hash(); have != want {
+		t.Fatalf("head mismatch: have %x, want %x", have, want)
+	}
+	if have, want := lightchain.GetTd(head.Hash()), core.GetTd(sdb, head.Hash()); have == nil || have.Cmp(want) != 0 {
+		t.Fatalf("head td mismatch: have %v, want %v", have, want)
+	}
+	// The headers before the checkpoint are proven against its CHT on demand
+	header, err := lightchain.GetHeaderByNumberOdr(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to retrieve header before the checkpoint: %v", err)
+	}
+	if header.Hash() != headers[1000].Hash() {
+		t.Errorf("header #1000 mismatch: have %x, want %x", header.Hash(), headers[1000].Hash())
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/state"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"github.com/ethereumproject/go-ethereum/pow"
+	"github.com/ethereumproject/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	bodyCacheLimit  = 256
+	blockCacheLimit = 256
+)
+
+// LightChain represents a canonical chain that by default only handles block
+// headers, downloading block bodies and receipts on demand through an ODR
+// interface. It only does header validation during chain insertion.
+type LightChain struct {
+	hc           *core.HeaderChain
+	chainDb      ethdb.Database
+	odr          OdrBackend
+	eventMux     *event.TypeMux
+	genesisBlock *types.Block
+	config       *core.ChainConfig
+	validator    core.HeaderValidator
+	checkpoint   *core.TrustedCheckpoint // Trusted CHT section to start header sync from
+
+	mu      sync.RWMutex // Protects the head header
+	chainmu sync.RWMutex // Serialises chain insertions
+
+	bodyCache    *lru.Cache // Cache for the most recent block bodies
+	bodyRLPCache *lru.Cache // Cache for the most recent block bodies in RLP encoded format
+	blockCache   *lru.Cache // Cache for the most recent entire blocks
+
+	quit          chan struct{}
+	procInterrupt int32 // interrupt signaler for header insertion (must be atomically accessed)
+	wg            sync.WaitGroup
+}
+
+// NewLightChain returns a fully initialised light chain using information
+// available in the database. The genesis block must already be stored in it.
+func NewLightChain(odr OdrBackend, config *core.ChainConfig, pow pow.PoW, mux *event.TypeMux) (*LightChain, error) {
+	bodyCache, _ := lru.New(bodyCacheLimit)
+	bodyRLPCache, _ := lru.New(bodyCacheLimit)
+	blockCache, _ := lru.New(blockCacheLimit)
+
+	bc := &LightChain{
+		chainDb:      odr.Database(),
+		odr:          odr,
+		eventMux:     mux,
+		config:       config,
+		quit:         make(chan struct{}),
+		bodyCache:    bodyCache,
+		bodyRLPCache: bodyRLPCache,
+		blockCache:   blockCache,
+	}
+	var err error
+	bc.hc, err = core.NewHeaderChain(odr.Database(), config, mux, bc.Validator, bc.getProcInterrupt)
+	if err != nil {
+		return nil, err
+	}
+	bc.validator = core.NewHeaderValidator(config, bc.hc, pow)
+
+	genesis := bc.hc.GetHeaderByNumber(0)
+	if genesis == nil {
+		return nil, core.ErrNoGenesis
+	}
+	bc.genesisBlock = types.NewBlockWithHeader(genesis)
+	// Restore the last known head header, the header chain starts from the
+	// head block which a light client doesn't have
+	if head := core.GetHeadHeaderHash(bc.chainDb); head != (common.Hash{}) {
+		if header := bc.hc.GetHeader(head); header != nil {
+			bc.hc.SetCurrentHeader(header)
+		}
+	}
+	glog.V(logger.Info).Infof("Loaded most recent local header: #%d [%x…] TD=%v", bc.hc.CurrentHeader().Number, bc.hc.CurrentHeader().Hash().Bytes()[:4], bc.GetTd(bc.hc.CurrentHeader().Hash()))
+	return bc, nil
+}
+
+func (bc *LightChain) getProcInterrupt() bool {
+	return atomic.LoadInt32(&bc.procInterrupt) == 1
+}
+
+// Odr returns the ODR backend of the chain.
+func (bc *LightChain) Odr() OdrBackend {
+	return bc.odr
+}
+
+// Config retrieves the chain configuration.
+func (bc *LightChain) Config() *core.ChainConfig {
+	return bc.config
+}
+
+// Validator returns the current header validator.
+func (bc *LightChain) Validator() core.HeaderValidator {
+	return bc.validator
+}
+
+// Genesis returns the genesis block.
+func (bc *LightChain) Genesis() *types.Block {
+	return bc.genesisBlock
+}
+
+// Status returns status information about the current chain such as the HEAD
+// Td, the HEAD hash and the hash of the genesis block.
+func (bc *LightChain) Status() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash) {
+	head := bc.CurrentHeader()
+	hash := head.Hash()
+	return bc.GetTd(hash), hash, bc.genesisBlock.Hash()
+}
+
+// State returns a state database for the current head, retrieving the
+// missing state on demand.
+func (bc *LightChain) State(ctx context.Context) *state.StateDB {
+	return NewState(ctx, bc.CurrentHeader(), bc.odr)
+}
+
+// GetBodyRLP retrieves a block body in RLP encoding from the database or
+// ODR service by hash, caching it if found.
+func (bc *LightChain) GetBodyRLP(ctx context.Context, hash common.Hash) (rlp.RawValue, error) {
+	// Short circuit if the body's already in the cache, retrieve otherwise
+	if cached, ok := bc.bodyRLPCache.Get(hash); ok {
+		return cached.(rlp.RawValue), nil
+	}
+	header := bc.GetHeader(hash)
+	if header == nil {
+		return nil, errNoHeader
+	}
+	body, err := GetBodyRLP(ctx, bc.odr, hash, header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	// Cache the found body for next time and return
+	bc.bodyRLPCache.Add(hash, body)
+	return body, nil
+}
+
+// GetBody retrieves a block body (transactions and uncles) from the database
+// or ODR service by hash, caching it if found.
+func (bc *LightChain) GetBody(ctx context.Context, hash common.Hash) (*types.Body, error) {
+	// Short circuit if the body's already in the cache, retrieve otherwise
+	if cached, ok := bc.bodyCache.Get(hash); ok {
+		return cached.(*types.Body), nil
+	}
+	header := bc.GetHeader(hash)
+	if header == nil {
+		return nil, errNoHeader
+	}
+	body, err := GetBody(ctx, bc.odr, hash, header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	// Cache the found body for next time and return
+	bc.bodyCache.Add(hash, body)
+	return body, nil
+}
+
+// GetBlock retrieves a block from the database or ODR service by hash,
+// caching it if found.
+func (bc *LightChain) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	// Short circuit if the block's already in the cache, retrieve otherwise
+	if block, ok := bc.blockCache.Get(hash); ok {
+		return block.(*types.Block), nil
+	}
+	header := bc.GetHeader(hash)
+	if header == nil {
+		return nil, errNoHeader
+	}
+	block, err := GetBlock(ctx, bc.odr, hash, header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	// Cache the found block for next time and return
+	bc.blockCache.Add(block.Hash(), block)
+	return block, nil
+}
+
+// GetBlockByNumber retrieves a canonical block from the database or ODR
+// service by number.
+func (bc *LightChain) GetBlockByNumber(ctx context.Context, number uint64) (*types.Block, error) {
+	header, err := GetHeaderByNumber(ctx, bc.odr, number)
+	if err != nil {
+		return nil, err
+	}
+	return bc.GetBlock(ctx, header.Hash())
+}
+
+// GetBlockReceipts retrieves the receipts of a block from the database or ODR
+// service by hash.
+func (bc *LightChain) GetBlockReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	header := bc.GetHeader(hash)
+	if header == nil {
+		return nil, errNoHeader
+	}
+	return GetBlockReceipts(ctx, bc.odr, hash, header.Number.Uint64())
+}
+
+// Stop stops the light chain service. If any imports are currently in
+// progress it will abort them using the procInterrupt.
+func (bc *LightChain) Stop() {
+	select {
+	case <-bc.quit:
+		return
+	default:
+		close(bc.quit)
+	}
+	atomic.StoreInt32(&bc.procInterrupt, 1)
+
+	bc.wg.Wait()
+	glog.V(logger.Info).Infoln("Light chain manager stopped")
+}
+
+// Rollback is designed to remove a chain of links from the database that aren't
+// certain enough to be valid.
+func (bc *LightChain) Rollback(chain []common.Hash) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		hash := chain[i]
+
+		if head := bc.hc.CurrentHeader(); head.Hash() == hash {
+			bc.hc.SetCurrentHeader(bc.GetHeader(head.ParentHash))
+		}
+	}
+}
+
+// InsertHeaderChain attempts to insert the given header chain in to the local
+// chain, possibly creating a reorg. If an error is returned, it will return the
+// index number of the failing header as well an error describing what went wrong.
+//
+// In the case of a light chain, InsertHeaderChain also creates and posts light
+// chain events when necessary.
+func (bc *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int) *core.HeaderChainInsertResult {
+	// Make sure only one thread manipulates the chain at once
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	var events []interface{}
+	whFunc := func(header *types.Header) error {
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+
+		status, err := bc.hc.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		block := types.NewBlockWithHeader(header)
+		switch status {
+		case core.CanonStatTy:
+			glog.V(logger.Debug).Infof("inserted header #%d (%x…)", header.Number, header.Hash().Bytes()[0:4])
+			events = append(events, core.ChainEvent{Block: block, Hash: block.Hash()})
+
+		case core.SideStatTy:
+			glog.V(logger.Detail).Infof("inserted forked header #%d (TD=%v) (%x…)", header.Number, header.Difficulty, header.Hash().Bytes()[0:4])
+			events = append(events, core.ChainSideEvent{Block: block})
+		}
+		return nil
+	}
+	res := bc.hc.InsertHeaderChain(chain, checkFreq, whFunc)
+	if len(events) > 0 {
+		events = append(events, core.ChainHeadEvent{Block: types.NewBlockWithHeader(bc.CurrentHeader())})
+		go bc.postChainEvents(events)
+	}
+	return res
+}
+
+// postChainEvents posts the events generated by a header chain insertion.
+func (bc *LightChain) postChainEvents(events []interface{}) {
+	for _, event := range events {
+		bc.eventMux.Post(event)
+	}
+}
+
+// CurrentHeader retrieves the current head header of the canonical chain. The
+// header is retrieved from the HeaderChain's internal cache.
+func (bc *LightChain) CurrentHeader() *types.Header {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.hc.CurrentHeader()
+}
+
+// GetTd retrieves a block's total difficulty in the canonical chain from the
+// database by hash, caching it if found.
+func (bc *LightChain) GetTd(hash common.Hash) *big.Int {
+	return bc.hc.GetTd(hash)
+}
+
+// GetHeader retrieves a block header from the database by hash, caching it if
+// found.
+func (bc *LightChain) GetHeader(hash common.Hash) *types.Header {
+	return bc.hc.GetHeader(hash)
+}
+
+// GetHeaderByHash retrieves a block header from the database by hash, caching
+// it if found.
+func (bc *LightChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return bc.hc.GetHeader(hash)
+}
+
+// HasHeader checks if a block header is present in the database or not, caching
+// it if present.
+func (bc *LightChain) HasHeader(hash common.Hash) bool {
+	return bc.hc.HasHeader(hash)
+}
+
+// GetBlockHashesFromHash retrieves a number of block hashes starting at a given
+// hash, fetching towards the genesis block.
+func (bc *LightChain) GetBlockHashesFromHash(hash common.Hash, max uint64) []common.Hash {
+	return bc.hc.GetBlockHashesFromHash(hash, max)
+}
+
+// GetHeaderByNumber retrieves a canonical block header from the local database
+// by number, caching it (associated with its hash) if found.
+func (bc *LightChain) GetHeaderByNumber(number uint64) *types.Header {
+	return bc.hc.GetHeaderByNumber(number)
+}
+
+// AddTrustedCheckpoint adds a trusted CHT section to the chain, storing its
+// root so that the headers it covers can be retrieved with proofs against it.
+func (bc *LightChain) AddTrustedCheckpoint(cp *core.TrustedCheckpoint) error {
+	if err := StoreChtRoot(bc.chainDb, cp.SectionIndex, cp.ChtRoot); err != nil {
+		return err
+	}
+	if err := storeTrustedChtSection(bc.chainDb, cp.SectionIndex); err != nil {
+		return err
+	}
+	bc.mu.Lock()
+	bc.checkpoint = cp
+	bc.mu.Unlock()
+
+	glog.V(logger.Info).Infof("Added trusted checkpoint: section %d, head #%d [%x…]", cp.SectionIndex, ChtSectionHead(cp.SectionIndex), cp.SectionHead[:4])
+	return nil
+}
+
+// TrustedCheckpoint returns the trusted CHT section of the chain, if any.
+func (bc *LightChain) TrustedCheckpoint() *core.TrustedCheckpoint {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	return bc.checkpoint
+}
+
+// SyncCheckpoint moves the head of the chain forward to the head of the
+// trusted checkpoint if it's still behind it, retrieving the header with a
+// proof against the checkpoint's CHT root. Header sync then continues from
+// the checkpoint instead of the genesis block.
+func (bc *LightChain) SyncCheckpoint(ctx context.Context) error {
+	bc.mu.RLock()
+	cp, head := bc.checkpoint, bc.hc.CurrentHeader().Number.Uint64()
+	bc.mu.RUnlock()
+
+	if cp == nil || head >= ChtSectionHead(cp.SectionIndex) {
+		return nil
+	}
+	number := ChtSectionHead(cp.SectionIndex)
+	header, err := GetHeaderByNumber(ctx, bc.odr, number)
+	if err != nil {
+		return err
+	}
+	if hash := header.Hash(); hash != cp.SectionHead {
+		// The proof matched the trusted root, so the configuration itself is
+		// inconsistent; don't keep the header as canonical
+		core.DeleteCanonicalHash(bc.chainDb, number)
+		return fmt.Errorf("%v: have %x, want %x", errCheckpointMismatch, hash, cp.SectionHead)
+	}
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.hc.CurrentHeader().Number.Uint64() < number {
+		bc.hc.SetCurrentHeader(header)
+		glog.V(logger.Info).Infof("Moved to trusted checkpoint #%d [%x…]", number, cp.SectionHead[:4])
+	}
+	return nil
+}
+
+// GetHeaderByNumberOdr retrieves a canonical block header by number, proving
+// it against a canonical hash trie if it isn't available locally.
+func (bc *LightChain) GetHeaderByNumberOdr(ctx context.Context, number uint64) (*types.Header, error) {
+	if header := bc.hc.GetHeaderByNumber(number); header != nil {
+		return header, nil
+	}
+	return GetHeaderByNumber(ctx, bc.odr, number)
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+)
+
+// NodeList is a list of trie nodes, collecting a merkle proof in the order the
+// nodes are written by trie.Prove. It implements trie.DatabaseWriter.
+type NodeList [][]byte
+
+// Put appends a node to the list.
+func (n *NodeList) Put(key []byte, value []byte) error {
+	*n = append(*n, common.CopyBytes(value))
+	return nil
+}
+
+// DataSize returns the aggregated data size of the nodes in the list.
+func (n NodeList) DataSize() int {
+	var size int
+	for _, node := range n {
+		size += len(node)
+	}
+	return size
+}
+
+// Store writes the nodes to a database keyed by their hashes, the way a trie
+// reads them.
+func (n NodeList) Store(db ethdb.Database) {
+	for _, node := range n {
+		db.Put(crypto.Keccak256(node), node)
+	}
+}
+
+// NodeSet returns the nodes in an in-memory database keyed by their hashes,
+// to verify a proof against.
+func (n NodeList) NodeSet() *ethdb.MemDatabase {
+	db, _ := ethdb.NewMemDatabase()
+	n.Store(db)
+	return db
+}
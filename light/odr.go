@@ -0,0 +1,151 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package light implements on-demand retrieval capable state and chain objects
+// for the Ethereum Light Client.
+package light
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+)
+
+// NoOdr is the default context passed to an ODR capable function when the ODR
+// service is not required.
+var NoOdr = context.Background()
+
+// ErrNoPeers is returned if no peers capable of serving a queued request are
+// available.
+var ErrNoPeers = errors.New("no suitable peers available")
+
+// OdrBackend is an interface to a backend service that handles ODR retrievals.
+type OdrBackend interface {
+	Database() ethdb.Database
+	Retrieve(ctx context.Context, req OdrRequest) error
+}
+
+// OdrRequest is an interface for retrieval requests. Once a request has been
+// answered and the answer validated by the backend, StoreResult saves it into
+// the local database, so the same data isn't retrieved again.
+type OdrRequest interface {
+	StoreResult(db ethdb.Database)
+}
+
+// TrieID identifies a state or account storage trie.
+type TrieID struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Root        common.Hash
+	AccKey      []byte // Hashed address of the account, nil for the state trie
+}
+
+// StateTrieID returns a TrieID for the state trie belonging to a certain block
+// header.
+func StateTrieID(header *types.Header) *TrieID {
+	return &TrieID{
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number.Uint64(),
+		Root:        header.Root,
+	}
+}
+
+// StorageTrieID returns a TrieID for the storage trie of the account with the
+// given hashed address and storage root, in the state identified by state.
+func StorageTrieID(state *TrieID, addrHash, root common.Hash) *TrieID {
+	return &TrieID{
+		BlockHash:   state.BlockHash,
+		BlockNumber: state.BlockNumber,
+		Root:        root,
+		AccKey:      addrHash[:],
+	}
+}
+
+// TrieRequest is the ODR request type for state and storage trie entries. Key
+// is the hashed key of the entry, Proof holds the trie nodes on its path.
+type TrieRequest struct {
+	Id    *TrieID
+	Key   []byte
+	Proof [][]byte
+}
+
+// StoreResult stores the retrieved proof nodes in the local database.
+func (req *TrieRequest) StoreResult(db ethdb.Database) {
+	for _, node := range req.Proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+}
+
+// CodeRequest is the ODR request type for retrieving contract code.
+type CodeRequest struct {
+	Id   *TrieID // Account to retrieve the code for
+	Hash common.Hash
+	Data []byte
+}
+
+// StoreResult stores the retrieved code in the local database.
+func (req *CodeRequest) StoreResult(db ethdb.Database) {
+	db.Put(req.Hash[:], req.Data)
+}
+
+// BlockRequest is the ODR request type for retrieving block bodies.
+type BlockRequest struct {
+	Hash   common.Hash
+	Number uint64
+	Rlp    []byte
+}
+
+// StoreResult stores the retrieved body in the local database.
+func (req *BlockRequest) StoreResult(db ethdb.Database) {
+	core.WriteBodyRLP(db, req.Hash, req.Rlp)
+}
+
+// ReceiptsRequest is the ODR request type for retrieving the receipts of a
+// block.
+type ReceiptsRequest struct {
+	Hash     common.Hash
+	Number   uint64
+	Receipts types.Receipts
+}
+
+// StoreResult stores the retrieved receipts in the local database.
+func (req *ReceiptsRequest) StoreResult(db ethdb.Database) {
+	core.WriteBlockReceipts(db, req.Hash, req.Receipts)
+}
+
+// ChtRequest is the ODR request type for retrieving a canonical header with
+// its total difficulty, proven against the root of a canonical hash trie.
+type ChtRequest struct {
+	ChtNum, BlockNum uint64
+	ChtRoot          common.Hash
+	Header           *types.Header
+	Td               *big.Int
+	Proof            [][]byte
+}
+
+// StoreResult stores the retrieved header as canonical in the local database.
+func (req *ChtRequest) StoreResult(db ethdb.Database) {
+	hash := req.Header.Hash()
+	core.WriteHeader(db, req.Header)
+	core.WriteTd(db, hash, req.Td)
+	core.WriteCanonicalHash(db, hash, req.BlockNum)
+}
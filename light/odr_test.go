@@ -0,0 +1,234 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/state"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+	"github.com/ethereumproject/go-ethereum/event"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+var (
+	testBankKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testBankAddress = crypto.PubkeyToAddress(testBankKey.PublicKey)
+	testBankFunds   = big.NewInt(1000000)
+
+	acc1Addr = common.HexToAddress("0x0000000000000000000000000000000000001001")
+	acc2Addr = common.HexToAddress("0x0000000000000000000000000000000000001002")
+
+	// testContractCode stores 1 in slot 1 and deploys the single byte 0x00.
+	testContractCode = common.Hex2Bytes("600160015560016000f3")
+	testContractAddr = crypto.CreateAddress(testBankAddress, 0)
+)
+
+// testOdr serves the requests of a light database from a full one.
+type testOdr struct {
+	OdrBackend
+	sdb, ldb ethdb.Database
+}
+
+func (odr *testOdr) Database() ethdb.Database {
+	return odr.ldb
+}
+
+func (odr *testOdr) Retrieve(ctx context.Context, req OdrRequest) error {
+	switch req := req.(type) {
+	case *BlockRequest:
+		req.Rlp = core.GetBodyRLP(odr.sdb, req.Hash)
+	case *ReceiptsRequest:
+		req.Receipts = core.GetBlockReceipts(odr.sdb, req.Hash)
+	case *TrieRequest:
+		t, err := trie.New(req.Id.Root, odr.sdb)
+		if err != nil {
+			return err
+		}
+		proof, _ := ethdb.NewMemDatabase()
+		if err := t.Prove(req.Key, 0, proof); err != nil {
+			return err
+		}
+		for _, key := range proof.Keys() {
+			node, _ := proof.Get(key)
+			req.Proof = append(req.Proof, node)
+		}
+	case *CodeRequest:
+		req.Data, _ = odr.sdb.Get(req.Hash[:])
+	case *ChtRequest:
+		t, err := trie.New(GetChtRoot(odr.sdb, req.ChtNum), ethdb.NewTable(odr.sdb, ChtTablePrefix))
+		if err != nil {
+			return err
+		}
+		proof, _ := ethdb.NewMemDatabase()
+		if err := t.Prove(ChtKey(req.BlockNum), 0, proof); err != nil {
+			return err
+		}
+		for _, key := range proof.Keys() {
+			node, _ := proof.Get(key)
+			req.Proof = append(req.Proof, node)
+		}
+		hash := core.GetCanonicalHash(odr.sdb, req.BlockNum)
+		req.Header, req.Td = core.GetHeader(odr.sdb, hash), core.GetTd(odr.sdb, hash)
+	}
+	req.StoreResult(odr.ldb)
+	return nil
+}
+
+func testChainGen(i int, block *core.BlockGen) {
+	switch i {
+	case 0:
+		// The bank deploys the test contract and sends some wei to acc1.
+		tx, _ := types.NewContractCreation(block.TxNonce(testBankAddress), new(big.Int), big.NewInt(200000), new(big.Int), testContractCode).SignECDSA(testBankKey)
+		block.AddTx(tx)
+		tx, _ = types.NewTransaction(block.TxNonce(testBankAddress), acc1Addr, big.NewInt(10000), big.NewInt(21000), new(big.Int), nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	case 2:
+		tx, _ := types.NewTransaction(block.TxNonce(testBankAddress), acc2Addr, big.NewInt(1000), big.NewInt(21000), new(big.Int), nil).SignECDSA(testBankKey)
+		block.AddTx(tx)
+	}
+}
+
+// newTestChains generates a full chain and a light chain with only its headers.
+func newTestChains(t *testing.T, blocks int) (*core.BlockChain, *LightChain, *testOdr) {
+	var (
+		config = core.DefaultConfigMorden.ChainConfig
+		sdb, _ = ethdb.NewMemDatabase()
+		ldb, _ = ethdb.NewMemDatabase()
+		bank   = core.GenesisAccount{Address: testBankAddress, Balance: testBankFunds}
+	)
+	genesis := core.WriteGenesisBlockForTesting(sdb, bank)
+	core.WriteGenesisBlockForTesting(ldb, bank)
+
+	blockchain, err := core.NewBlockChain(sdb, config, core.FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, _ := core.GenerateChain(config, genesis, sdb, blocks, testChainGen)
+	if res := blockchain.InsertChain(chain); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+
+	odr := &testOdr{sdb: sdb, ldb: ldb}
+	lightchain, err := NewLightChain(odr, config, core.FakePow{}, new(event.TypeMux))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := make([]*types.Header, len(chain))
+	for i, block := range chain {
+		headers[i] = block.Header()
+	}
+	if res := lightchain.InsertHeaderChain(headers, 1); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	return blockchain, lightchain, odr
+}
+
+func TestLightChainHeaders(t *testing.T) {
+	blockchain, lightchain, _ := newTestChains(t, 4)
+
+	if have, want := lightchain.CurrentHeader().Hash(), blockchain.CurrentBlock().Hash(); have != want {
+		t.Fatalf("head mismatch: have %x, want %x", have, want)
+	}
+	if have, want := lightchain.GetTd(lightchain.CurrentHeader().Hash()), blockchain.GetTd(blockchain.CurrentBlock().Hash()); have.Cmp(want) != 0 {
+		t.Errorf("head td mismatch: have %v, want %v", have, want)
+	}
+	// Roll back the head and check that it moves to the parent
+	head := lightchain.CurrentHeader()
+	lightchain.Rollback([]common.Hash{head.Hash()})
+	if have := lightchain.CurrentHeader().Hash(); have != head.ParentHash {
+		t.Errorf("head after rollback mismatch: have %x, want %x", have, head.ParentHash)
+	}
+}
+
+func TestOdrGetBlock(t *testing.T) {
+	blockchain, lightchain, _ := newTestChains(t, 4)
+
+	for i := uint64(0); i <= blockchain.CurrentBlock().NumberU64(); i++ {
+		want := blockchain.GetBlockByNumber(i)
+		have, err := lightchain.GetBlockByNumber(NoOdr, i)
+		if err != nil {
+			t.Fatalf("block %d: retrieval failed: %v", i, err)
+		}
+		if have.Hash() != want.Hash() || len(have.Transactions()) != len(want.Transactions()) {
+			t.Errorf("block %d: mismatch: have %x (%d txs), want %x (%d txs)", i, have.Hash(), len(have.Transactions()), want.Hash(), len(want.Transactions()))
+		}
+		if types.DeriveSha(have.Transactions()) != have.TxHash() {
+			t.Errorf("block %d: transactions don't match the header", i)
+		}
+	}
+}
+
+func TestOdrGetReceipts(t *testing.T) {
+	blockchain, lightchain, odr := newTestChains(t, 4)
+
+	for i := uint64(0); i <= blockchain.CurrentBlock().NumberU64(); i++ {
+		hash := blockchain.GetBlockByNumber(i).Hash()
+		want := core.GetBlockReceipts(odr.sdb, hash)
+		have, err := lightchain.GetBlockReceipts(NoOdr, hash)
+		if err != nil {
+			t.Fatalf("block %d: retrieval failed: %v", i, err)
+		}
+		if len(have) != len(want) {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want %d", i, len(have), len(want))
+		}
+		for j := range have {
+			if have[j].CumulativeGasUsed.Cmp(want[j].CumulativeGasUsed) != 0 {
+				t.Errorf("block %d receipt %d: gas mismatch: have %v, want %v", i, j, have[j].CumulativeGasUsed, want[j].CumulativeGasUsed)
+			}
+		}
+	}
+}
+
+func TestOdrState(t *testing.T) {
+	blockchain, lightchain, odr := newTestChains(t, 4)
+
+	for i := uint64(0); i <= blockchain.CurrentBlock().NumberU64(); i++ {
+		header := lightchain.GetHeaderByNumber(i)
+		full, err := state.New(header.Root, state.NewDatabase(odr.sdb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		light := NewState(NoOdr, header, odr)
+
+		for _, addr := range []common.Address{testBankAddress, acc1Addr, acc2Addr, testContractAddr} {
+			if have, want := light.GetBalance(addr), full.GetBalance(addr); have.Cmp(want) != 0 {
+				t.Errorf("block %d: balance of %x mismatch: have %v, want %v", i, addr, have, want)
+			}
+			if have, want := light.GetNonce(addr), full.GetNonce(addr); have != want {
+				t.Errorf("block %d: nonce of %x mismatch: have %d, want %d", i, addr, have, want)
+			}
+		}
+		if have, want := light.GetCode(testContractAddr), full.GetCode(testContractAddr); !bytes.Equal(have, want) {
+			t.Errorf("block %d: code mismatch: have %x, want %x", i, have, want)
+		}
+		slot := common.BigToHash(big.NewInt(1))
+		if have, want := light.GetState(testContractAddr, slot), full.GetState(testContractAddr, slot); have != want {
+			t.Errorf("block %d: storage mismatch: have %x, want %x", i, have, want)
+		}
+		if err := light.Error(); err != nil {
+			t.Errorf("block %d: state retrieval failed: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+var sha3Nil = crypto.Keccak256Hash(nil)
+
+var (
+	errNoHeader      = errors.New("block header not found")
+	errNoTrustedCht  = errors.New("no trusted canonical hash trie")
+	errInvalidBodies = errors.New("invalid block body")
+
+	errCheckpointMismatch = errors.New("checkpoint head mismatch")
+)
+
+// GetHeaderByNumber retrieves the canonical header with the given number,
+// from the local database if present, otherwise by a proof against the
+// canonical hash trie of its section, or of the trusted checkpoint covering
+// it.
+func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*types.Header, error) {
+	db := odr.Database()
+	if hash := core.GetCanonicalHash(db, number); hash != (common.Hash{}) {
+		if header := core.GetHeader(db, hash); header != nil {
+			return header, nil
+		}
+	}
+	section := number / ChtFrequency
+	root := GetChtRoot(db, section)
+	if root == (common.Hash{}) {
+		// The CHT of a section covers all the blocks before it too
+		if trusted, ok := getTrustedChtSection(db); ok && trusted > section {
+			section, root = trusted, GetChtRoot(db, trusted)
+		}
+	}
+	if root == (common.Hash{}) {
+		return nil, errNoTrustedCht
+	}
+	r := &ChtRequest{ChtRoot: root, ChtNum: section, BlockNum: number}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Header, nil
+}
+
+// GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
+func GetBodyRLP(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (rlp.RawValue, error) {
+	if data := core.GetBodyRLP(odr.Database(), hash); data != nil {
+		return data, nil
+	}
+	r := &BlockRequest{Hash: hash, Number: number}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Rlp, nil
+}
+
+// GetBody retrieves the block body (transactons, uncles) corresponding to the
+// hash.
+func GetBody(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (*types.Body, error) {
+	data, err := GetBodyRLP(ctx, odr, hash, number)
+	if err != nil {
+		return nil, err
+	}
+	body := new(types.Body)
+	if err := rlp.Decode(bytes.NewReader(data), body); err != nil {
+		return nil, errInvalidBodies
+	}
+	return body, nil
+}
+
+// GetBlock retrieves an entire block corresponding to the hash, assembling it
+// back from the stored header and body.
+func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (*types.Block, error) {
+	// Retrieve the block header and body contents
+	header := core.GetHeader(odr.Database(), hash)
+	if header == nil {
+		return nil, errNoHeader
+	}
+	body, err := GetBody(ctx, odr, hash, number)
+	if err != nil {
+		return nil, err
+	}
+	// Reassemble the block and return
+	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles), nil
+}
+
+// GetBlockReceipts retrieves the receipts generated by the transactions
+// included in a block given by its hash.
+func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (types.Receipts, error) {
+	if receipts := core.GetBlockReceipts(odr.Database(), hash); receipts != nil {
+		return receipts, nil
+	}
+	r := &ReceiptsRequest{Hash: hash, Number: number}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Receipts, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/core/state"
+	"github.com/ethereumproject/go-ethereum/core/types"
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/trie"
+)
+
+// NewState creates a state database for the state of the given header,
+// retrieving the accounts, storage slots and code it accesses on demand.
+// Retrieval errors are reported through the Error method of the state.
+func NewState(ctx context.Context, head *types.Header, odr OdrBackend) *state.StateDB {
+	statedb, _ := state.New(head.Root, NewStateDatabase(ctx, head, odr))
+	return statedb
+}
+
+// NewStateDatabase returns a state.Database retrieving the missing parts of
+// the state of the given header through the ODR backend.
+func NewStateDatabase(ctx context.Context, head *types.Header, odr OdrBackend) state.Database {
+	return &odrDatabase{ctx: ctx, id: StateTrieID(head), backend: odr, triedb: trie.NewNodeCache(odr.Database())}
+}
+
+type odrDatabase struct {
+	ctx     context.Context
+	id      *TrieID
+	backend OdrBackend
+	triedb  *trie.NodeCache
+}
+
+func (db *odrDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	return &odrTrie{db: db, id: db.id}, nil
+}
+
+func (db *odrDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	return &odrTrie{db: db, id: StorageTrieID(db.id, addrHash, root)}, nil
+}
+
+func (db *odrDatabase) CopyTrie(t state.Trie) state.Trie {
+	switch t := t.(type) {
+	case *odrTrie:
+		cpy := &odrTrie{db: t.db, id: t.id}
+		if t.trie != nil {
+			cpytrie := *t.trie
+			cpy.trie = &cpytrie
+		}
+		return cpy
+	default:
+		panic(fmt.Errorf("unknown trie type %T", t))
+	}
+}
+
+func (db *odrDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	if codeHash == sha3Nil {
+		return nil, nil
+	}
+	if code, err := db.backend.Database().Get(codeHash[:]); err == nil {
+		return code, nil
+	}
+	id := *db.id
+	id.AccKey = addrHash[:]
+	req := &CodeRequest{Id: &id, Hash: codeHash}
+	err := db.backend.Retrieve(db.ctx, req)
+	return req.Data, err
+}
+
+func (db *odrDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	code, err := db.ContractCode(addrHash, codeHash)
+	return len(code), err
+}
+
+func (db *odrDatabase) TrieDB() *trie.NodeCache {
+	return db.triedb
+}
+
+// odrTrie is a state.Trie resolving its missing nodes with merkle proofs of
+// the accessed keys. The keys are hashed like in a secure trie.
+type odrTrie struct {
+	db   *odrDatabase
+	id   *TrieID
+	trie *trie.Trie
+}
+
+func (t *odrTrie) TryGet(key []byte) ([]byte, error) {
+	key = crypto.Keccak256(key)
+	var res []byte
+	err := t.do(key, func() (err error) {
+		res, err = t.trie.TryGet(key)
+		return err
+	})
+	return res, err
+}
+
+func (t *odrTrie) TryUpdate(key, value []byte) error {
+	key = crypto.Keccak256(key)
+	return t.do(key, func() error {
+		return t.trie.TryUpdate(key, value)
+	})
+}
+
+func (t *odrTrie) TryDelete(key []byte) error {
+	key = crypto.Keccak256(key)
+	return t.do(key, func() error {
+		return t.trie.TryDelete(key)
+	})
+}
+
+func (t *odrTrie) CommitTo(db trie.DatabaseWriter) (common.Hash, error) {
+	return t.CommitToWithCallback(db, nil)
+}
+
+func (t *odrTrie) CommitToWithCallback(db trie.DatabaseWriter, onleaf trie.LeafCallback) (common.Hash, error) {
+	if t.trie == nil {
+		return t.id.Root, nil
+	}
+	return t.trie.CommitToWithCallback(db, onleaf)
+}
+
+func (t *odrTrie) Hash() common.Hash {
+	if t.trie == nil {
+		return t.id.Root
+	}
+	return t.trie.Hash()
+}
+
+// NodeIterator iterates over the locally available nodes of the trie. Missing
+// nodes aren't retrieved, the iterator reports them through its Error method.
+func (t *odrTrie) NodeIterator(startkey []byte) trie.NodeIterator {
+	if t.trie == nil {
+		tr, err := trie.New(t.id.Root, t.db.backend.Database())
+		if err != nil {
+			tr, _ = trie.New(common.Hash{}, t.db.backend.Database())
+		}
+		return tr.NodeIterator(startkey)
+	}
+	return t.trie.NodeIterator(startkey)
+}
+
+// GetKey returns nil, the light client doesn't store key preimages.
+func (t *odrTrie) GetKey(sha []byte) []byte {
+	return nil
+}
+
+// do tries and retries to execute a function until it returns with no error or
+// an error type other than MissingNodeError, retrieving the proof of key after
+// each missing node. It gives up if the proof doesn't contain the missing node,
+// as it may be off the path of key (e.g. the sibling of a deleted node).
+func (t *odrTrie) do(key []byte, fn func() error) error {
+	var lastMissing common.Hash
+	for {
+		var err error
+		if t.trie == nil {
+			t.trie, err = trie.New(t.id.Root, t.db.backend.Database())
+		}
+		if err == nil {
+			err = fn()
+		}
+		missing, ok := err.(*trie.MissingNodeError)
+		if !ok {
+			return err
+		}
+		if missing.NodeHash == lastMissing {
+			return err
+		}
+		lastMissing = missing.NodeHash
+
+		r := &TrieRequest{Id: t.id, Key: key}
+		if err := t.db.backend.Retrieve(t.db.ctx, r); err != nil {
+			return err
+		}
+	}
+}
@@ -95,7 +95,7 @@ type worker struct {
 
 	// update loop
 	mux    *event.TypeMux
-	events event.Subscription
+	events event.TypeMuxSubscription
 	wg     sync.WaitGroup
 
 	agents map[Agent]struct{}
@@ -267,7 +267,10 @@ func (self *worker) wait() {
 				}
 				go self.mux.Post(core.NewMinedBlockEvent{Block: block})
 			} else {
-				work.state.CommitTo(self.chainDb, false)
+				if err := self.chain.CommitState(block, work.state); err != nil {
+					glog.V(logger.Error).Infoln("error committing mined block state", err)
+					continue
+				}
 				parent := self.chain.GetBlock(block.ParentHash())
 				if parent == nil {
 					glog.V(logger.Error).Infoln("Invalid block found during mining")
@@ -692,7 +695,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, transactions types.Trans
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, gp *core.GasPool) (error, vm.Logs) {
 	snap := env.state.Snapshot()
 
-	receipt, logs, _, err := core.ApplyTransaction(env.config, bc, gp, env.state, env.header, tx, env.header.GasUsed)
+	receipt, logs, _, err := core.ApplyTransaction(env.config, bc, gp, env.state, env.header, tx, env.header.GasUsed, vm.Config{})
 
 	if logger.MlogEnabled() {
 		defer func() {
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/ethereumproject/go-ethereum/p2p/discover"
 	"github.com/ethereumproject/go-ethereum/p2p/nat"
+	"github.com/ethereumproject/go-ethereum/rpc"
 	"github.com/spf13/afero"
 )
 
@@ -59,6 +60,10 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DatabaseEngine is the storage engine of the databases opened by services
+	// ("leveldb" or "boltdb"). An empty engine defaults to LevelDB.
+	DatabaseEngine string
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the chaindata directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -130,6 +135,17 @@ type Config struct {
 	// exposed.
 	HTTPModules []string
 
+	// HTTPVirtualHosts is the list of virtual hostnames which are allowed on incoming
+	// requests, guarding against DNS rebinding attacks. Requests to IP addresses are
+	// always accepted, '*' accepts any hostname. If the list is empty, the Host
+	// header is not validated.
+	HTTPVirtualHosts []string
+
+	// HTTPTLSCert and HTTPTLSKey are the PEM encoded certificate and private key
+	// files to serve HTTPS with. If both are empty, plain HTTP is served.
+	HTTPTLSCert string
+	HTTPTLSKey  string
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string
@@ -148,6 +164,21 @@ type Config struct {
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
 	WSModules []string
+
+	// WSVirtualHosts is the list of virtual hostnames which are allowed on incoming
+	// websocket requests, like HTTPVirtualHosts.
+	WSVirtualHosts []string
+
+	// WSTLSCert and WSTLSKey are the PEM encoded certificate and private key files
+	// to serve secure websockets (wss) with. If both are empty, plain websockets
+	// are served.
+	WSTLSCert string
+	WSTLSKey  string
+
+	// RPCPolicy restricts the requests served over the HTTP and websocket RPC
+	// interfaces with request size and rate limits, method allow and deny lists
+	// and bearer token authentication. The IPC interface is not restricted.
+	RPCPolicy rpc.Policy
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -18,7 +18,9 @@
 package node
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/spf13/afero"
 	"net"
 	"path/filepath"
@@ -46,6 +48,7 @@ var (
 // be registered.
 type Node struct {
 	datadir  string         // Path to the currently used data directory
+	dbEngine string         // Storage engine of the service databases
 	eventmux *event.TypeMux // Event multiplexer used between the services of a stack
 
 	serverConfig p2p.Config
@@ -66,6 +69,9 @@ type Node struct {
 	httpEndpoint  string       // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
 	httpWhitelist []string     // HTTP RPC modules to allow through this endpoint
 	httpCors      string       // HTTP RPC Cross-Origin Resource Sharing header
+	httpVhosts    []string     // HTTP RPC virtual hostnames to accept requests for
+	httpTLSCert   string       // HTTP RPC TLS certificate file (empty = plain HTTP)
+	httpTLSKey    string       // HTTP RPC TLS private key file
 	httpListener  net.Listener // HTTP RPC listener socket to server API requests
 	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
 
@@ -74,9 +80,14 @@ type Node struct {
 	wsEndpoint  string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsWhitelist []string     // Websocket RPC modules to allow through this endpoint
 	wsOrigins   string       // Websocket RPC allowed origin domains
+	wsVhosts    []string     // Websocket RPC virtual hostnames to accept requests for
+	wsTLSCert   string       // Websocket RPC TLS certificate file (empty = plain websockets)
+	wsTLSKey    string       // Websocket RPC TLS private key file
 	wsListener  net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler   *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	rpcPolicy rpc.Policy // Access policy of the HTTP and websocket RPC endpoints
+
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
 }
@@ -99,7 +110,8 @@ func New(conf *Config) (*Node, error) {
 		nodeDbPath = filepath.Join(conf.DataDir, datadirNodeDatabase)
 	}
 	return &Node{
-		datadir: conf.DataDir,
+		datadir:  conf.DataDir,
+		dbEngine: conf.DatabaseEngine,
 		serverConfig: p2p.Config{
 			PrivateKey:      conf.NodeKey(),
 			Name:            conf.Name,
@@ -122,11 +134,18 @@ func New(conf *Config) (*Node, error) {
 		httpEndpoint:  conf.HTTPEndpoint(),
 		httpWhitelist: conf.HTTPModules,
 		httpCors:      conf.HTTPCors,
+		httpVhosts:    conf.HTTPVirtualHosts,
+		httpTLSCert:   conf.HTTPTLSCert,
+		httpTLSKey:    conf.HTTPTLSKey,
 		wsHost:        conf.WSHost,
 		wsPort:        conf.WSPort,
 		wsEndpoint:    conf.WSEndpoint(),
 		wsWhitelist:   conf.WSModules,
 		wsOrigins:     conf.WSOrigins,
+		wsVhosts:      conf.WSVirtualHosts,
+		wsTLSCert:     conf.WSTLSCert,
+		wsTLSKey:      conf.WSTLSKey,
+		rpcPolicy:     conf.RPCPolicy,
 		eventmux:      new(event.TypeMux),
 	}, nil
 }
@@ -160,6 +179,7 @@ func (n *Node) Start() error {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
 			datadir:  n.datadir,
+			dbEngine: n.dbEngine,
 			services: make(map[reflect.Type]Service),
 			EventMux: n.eventmux,
 		}
@@ -364,12 +384,16 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 		listener net.Listener
 		err      error
 	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	if listener, err = ListenTLS(endpoint, n.httpTLSCert, n.httpTLSKey); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, handler).Serve(listener)
-	glog.V(logger.Info).Infof("HTTP endpoint opened: http://%s", endpoint)
-	glog.D(logger.Warn).Infof("HTTP endpoint: http://%s", logger.ColorGreen(endpoint))
+	go rpc.NewHTTPServer(cors, n.httpVhosts, n.rpcPolicy, handler).Serve(listener)
+	scheme := "http"
+	if n.httpTLSCert != "" {
+		scheme = "https"
+	}
+	glog.V(logger.Info).Infof("HTTP endpoint opened: %s://%s", scheme, endpoint)
+	glog.D(logger.Warn).Infof("HTTP endpoint: %s://%s", scheme, logger.ColorGreen(endpoint))
 
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
@@ -421,12 +445,16 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 		listener net.Listener
 		err      error
 	)
-	if listener, err = net.Listen("tcp", endpoint); err != nil {
+	if listener, err = ListenTLS(endpoint, n.wsTLSCert, n.wsTLSKey); err != nil {
 		return err
 	}
-	go rpc.NewWSServer(wsOrigins, handler).Serve(listener)
-	glog.V(logger.Info).Infof("WebSocket endpoint opened: ws://%s", endpoint)
-	glog.D(logger.Warn).Infof("WebSocket endpoint opened: ws://%s", logger.ColorGreen(endpoint))
+	go rpc.NewWSServer(wsOrigins, n.wsVhosts, n.rpcPolicy, handler).Serve(listener)
+	scheme := "ws"
+	if n.wsTLSCert != "" {
+		scheme = "wss"
+	}
+	glog.V(logger.Info).Infof("WebSocket endpoint opened: %s://%s", scheme, endpoint)
+	glog.D(logger.Warn).Infof("WebSocket endpoint opened: %s://%s", scheme, logger.ColorGreen(endpoint))
 
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
@@ -437,6 +465,29 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	return nil
 }
 
+// ListenTLS opens a TCP listener on the endpoint, serving TLS with the given
+// certificate and key files if set.
+func ListenTLS(endpoint string, certFile string, keyFile string) (net.Listener, error) {
+	if certFile == "" && keyFile == "" {
+		return net.Listen("tcp", endpoint)
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("TLS needs both a certificate and a key file")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(listener, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
 // stopWS terminates the websocket RPC endpoint.
 func (n *Node) stopWS() {
 	if n.wsListener != nil {
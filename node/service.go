@@ -31,6 +31,7 @@ import (
 // as well as utility methods to operate on the service environment.
 type ServiceContext struct {
 	datadir  string                   // Data directory for protocol persistence
+	dbEngine string                   // Storage engine of the persistent databases
 	services map[reflect.Type]Service // Index of the already constructed services
 	EventMux *event.TypeMux           // Event multiplexer used for decoupled notifications
 }
@@ -42,7 +43,20 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (et
 	if ctx.datadir == "" {
 		return ethdb.NewMemDatabase()
 	}
-	return ethdb.NewLDBDatabase(filepath.Join(ctx.datadir, name), cache, handles)
+	return ethdb.NewDatabase(ctx.dbEngine, filepath.Join(ctx.datadir, name), cache, handles)
+}
+
+// ResolvePath resolves a user path into the data directory if that was relative
+// and if the node actually uses persistent storage. It returns an empty string
+// for ephemeral storage and the user's own input for absolute paths.
+func (ctx *ServiceContext) ResolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if ctx.datadir == "" {
+		return ""
+	}
+	return filepath.Join(ctx.datadir, path)
 }
 
 // Service retrieves a currently running service registered of a specific type.
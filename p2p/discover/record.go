@@ -0,0 +1,98 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"sync"
+
+	"github.com/ethereumproject/go-ethereum/p2p/enr"
+)
+
+var errNoRecord = errors.New("no local node record")
+
+// localRecord holds the signed node record (EIP-778) of the local node, which
+// is served to other nodes on request (EIP-868).
+type localRecord struct {
+	priv *ecdsa.PrivateKey
+
+	mu  sync.Mutex
+	rec enr.Record
+}
+
+func newLocalRecord(priv *ecdsa.PrivateKey, ep rpcEndpoint) (*localRecord, error) {
+	lr := &localRecord{priv: priv}
+	if ep.IP != nil && !ep.IP.IsUnspecified() {
+		lr.rec.Set(enr.IP(ep.IP))
+	}
+	lr.rec.Set(enr.UDP(ep.UDP))
+	lr.rec.Set(enr.TCP(ep.TCP))
+	if err := lr.rec.Sign(priv); err != nil {
+		return nil, err
+	}
+	return lr, nil
+}
+
+// record returns a copy of the current record.
+func (lr *localRecord) record() enr.Record {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.rec
+}
+
+// seq returns the sequence number of the current record.
+func (lr *localRecord) seq() uint64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.rec.Seq()
+}
+
+// set adds or updates an entry and re-signs the record. The previous record
+// is kept if the update can't be signed, e.g. because it exceeds the size
+// limit.
+func (lr *localRecord) set(e enr.Entry) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	rec := lr.rec
+	rec.Set(e)
+	if err := rec.Sign(lr.priv); err != nil {
+		return err
+	}
+	lr.rec = rec
+	return nil
+}
+
+// Record returns the signed node record of the local node.
+func (tab *Table) Record() (*enr.Record, error) {
+	if tab.local == nil {
+		return nil, errNoRecord
+	}
+	rec := tab.local.record()
+	return &rec, nil
+}
+
+// SetRecordEntry adds or updates an entry of the local node record. The
+// record is re-signed with an incremented sequence number, which is announced
+// to other nodes in subsequent ping and pong packets.
+func (tab *Table) SetRecordEntry(e enr.Entry) error {
+	if tab.local == nil {
+		return errNoRecord
+	}
+	return tab.local.set(e)
+}
@@ -94,8 +94,9 @@ type Table struct {
 
 	nodeAddedHook func(*Node) // for testing
 
-	net  transport
-	self *Node // metadata of the local node
+	net   transport
+	self  *Node        // metadata of the local node
+	local *localRecord // signed node record of the local node
 }
 
 type bondproc struct {
@@ -36,6 +36,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereumproject/go-ethereum/common"
 	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/p2p/enr"
 	"github.com/ethereumproject/go-ethereum/rlp"
 )
 
@@ -177,6 +178,7 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, pongPacket, &pong{ReplyTok: []byte{}, Expiration: futureExp})
 	test.packetIn(errUnknownNode, findnodePacket, &findnode{Expiration: futureExp})
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
 }
 
 func TestUDP_pingTimeout(t *testing.T) {
@@ -321,6 +323,40 @@ func TestUDP_findnode(t *testing.T) {
 	waitNeighbors(expected.Nodes[maxNeighbors:])
 }
 
+func TestUDP_enrRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	if err := test.table.SetRecordEntry(enr.WithEntry("test", uint(7))); err != nil {
+		t.Fatal("can't update record:", err)
+	}
+	// ensure there's a bond with the test node,
+	// enrRequest won't be accepted otherwise.
+	test.table.db.updateNode(NewNode(
+		PubkeyID(&test.remotekey.PublicKey),
+		test.remoteaddr.IP,
+		uint16(test.remoteaddr.Port),
+		99,
+	))
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponse) {
+		reqhash := test.sent[0][:macSize]
+		if !bytes.Equal(p.ReplyTok, reqhash) {
+			t.Errorf("got enrResponse.ReplyTok %x, want %x", p.ReplyTok, reqhash)
+		}
+		if id := p.Record.NodeAddr(); !bytes.Equal(id, test.table.self.ID[:]) {
+			t.Errorf("record node address mismatch: got %x, want %x", id, test.table.self.ID[:])
+		}
+		if p.Record.Seq() != 1 {
+			t.Errorf("record seq mismatch: got %d, want 1", p.Record.Seq())
+		}
+		var v uint
+		if err := p.Record.Load(enr.WithEntry("test", &v)); err != nil || v != 7 {
+			t.Errorf("record entry mismatch: got %d (err %v), want 7", v, err)
+		}
+	})
+}
+
 func TestUDP_findnodeMultiReply(t *testing.T) {
 	test := newUDPTest(t)
 	defer test.table.Close()
@@ -397,6 +433,10 @@ func TestUDP_successfulPing(t *testing.T) {
 		if !reflect.DeepEqual(p.To, wantTo) {
 			t.Errorf("got pong.To %v, want %v", p.To, wantTo)
 		}
+		// The pong announces the sequence number of the local record.
+		if len(p.Rest) != 1 || !bytes.Equal(p.Rest[0], []byte{0x80}) {
+			t.Errorf("got pong.Rest %x, want record seq 0", p.Rest)
+		}
 	})
 
 	// remote is unknown, the table pings back.
@@ -0,0 +1,297 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package enr implements Ethereum Node Records as defined in EIP-778. A node
+// record holds arbitrary information about a node on the peer-to-peer network,
+// as key/value pairs sorted by key and signed by the node's key.
+//
+// Records are signed with the "v4" identity scheme, the only one supported:
+// the signature is a secp256k1 signature of the keccak256 hash of the record
+// content, and the record carries the compressed public key of the node under
+// the "secp256k1" key.
+package enr
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+// SizeLimit is the maximum encoded size of a node record in bytes.
+const SizeLimit = 300
+
+var (
+	errNoID           = errors.New("unknown or unspecified identity scheme")
+	errInvalidSig     = errors.New("invalid signature")
+	errNotSorted      = errors.New("record key/value pairs are not sorted by key")
+	errDuplicateKey   = errors.New("record contains duplicate key")
+	errIncompletePair = errors.New("record contains incomplete k/v pair")
+	errTooBig         = fmt.Errorf("record bigger than %d bytes", SizeLimit)
+	errEncodeUnsigned = errors.New("can't encode unsigned record")
+	errNotFound       = errors.New("no such key in record")
+)
+
+// Record represents a node record. The zero value is an empty record.
+type Record struct {
+	seq       uint64 // sequence number
+	signature []byte // the signature
+	raw       []byte // RLP encoded record
+	pairs     []pair // sorted list of all key/value pairs
+}
+
+// pair is a key/value pair in a record.
+type pair struct {
+	k string
+	v rlp.RawValue
+}
+
+// Signed reports whether the record has a valid signature.
+func (r *Record) Signed() bool {
+	return r.signature != nil
+}
+
+// Seq returns the sequence number.
+func (r *Record) Seq() uint64 {
+	return r.seq
+}
+
+// SetSeq updates the record sequence number. This invalidates any signature on
+// the record. Calling SetSeq is usually not required because setting any key
+// in a signed record increments the sequence number.
+func (r *Record) SetSeq(s uint64) {
+	r.signature = nil
+	r.raw = nil
+	r.seq = s
+}
+
+// Load retrieves the value of a key/value pair. The given Entry must be a
+// pointer and will be set to the value of the entry in the record.
+//
+// Errors returned by Load are wrapped in KeyError. You can distinguish decoding
+// errors from missing keys using the IsNotFound function.
+func (r *Record) Load(e Entry) error {
+	i := sort.Search(len(r.pairs), func(i int) bool { return r.pairs[i].k >= e.ENRKey() })
+	if i < len(r.pairs) && r.pairs[i].k == e.ENRKey() {
+		if err := rlp.DecodeBytes(r.pairs[i].v, e); err != nil {
+			return &KeyError{Key: e.ENRKey(), Err: err}
+		}
+		return nil
+	}
+	return &KeyError{Key: e.ENRKey(), Err: errNotFound}
+}
+
+// Set adds or updates the given entry in the record. It panics if the value
+// can't be encoded. If the record is signed, Set increments the sequence
+// number and invalidates the signature.
+func (r *Record) Set(e Entry) {
+	blob, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		panic(fmt.Errorf("enr: can't encode %s: %v", e.ENRKey(), err))
+	}
+	r.invalidate()
+
+	pairs := make([]pair, len(r.pairs))
+	copy(pairs, r.pairs)
+	i := sort.Search(len(pairs), func(i int) bool { return pairs[i].k >= e.ENRKey() })
+	switch {
+	case i < len(pairs) && pairs[i].k == e.ENRKey():
+		// element is present at r.pairs[i]
+		pairs[i].v = blob
+	case i < len(r.pairs):
+		// insert pair before i-th elem
+		el := pair{e.ENRKey(), blob}
+		pairs = append(pairs, pair{})
+		copy(pairs[i+1:], pairs[i:])
+		pairs[i] = el
+	default:
+		// element should be placed at the end of r.pairs
+		pairs = append(pairs, pair{e.ENRKey(), blob})
+	}
+	r.pairs = pairs
+}
+
+func (r *Record) invalidate() {
+	if r.signature != nil {
+		r.seq++
+	}
+	r.signature = nil
+	r.raw = nil
+}
+
+// EncodeRLP implements rlp.Encoder. Encoding fails if the record is unsigned.
+func (r Record) EncodeRLP(w io.Writer) error {
+	if !r.Signed() {
+		return errEncodeUnsigned
+	}
+	_, err := w.Write(r.raw)
+	return err
+}
+
+// DecodeRLP implements rlp.Decoder. Decoding verifies the signature.
+func (r *Record) DecodeRLP(s *rlp.Stream) error {
+	raw, err := s.Raw()
+	if err != nil {
+		return err
+	}
+	if len(raw) > SizeLimit {
+		return errTooBig
+	}
+	// Decode the record
+	var dec Record
+	s = rlp.NewStream(bytes.NewReader(raw), 0)
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	if dec.signature, err = s.Bytes(); err != nil {
+		return err
+	}
+	if dec.seq, err = s.Uint(); err != nil {
+		if err == rlp.EOL {
+			err = errIncompletePair
+		}
+		return err
+	}
+	// The rest of the record contains sorted k/v pairs
+	var prevkey string
+	for i := 0; ; i++ {
+		var kv pair
+		if err := s.Decode(&kv.k); err != nil {
+			if err == rlp.EOL {
+				break
+			}
+			return err
+		}
+		if err := s.Decode(&kv.v); err != nil {
+			if err == rlp.EOL {
+				return errIncompletePair
+			}
+			return err
+		}
+		if i > 0 {
+			if kv.k == prevkey {
+				return errDuplicateKey
+			}
+			if kv.k < prevkey {
+				return errNotSorted
+			}
+		}
+		dec.pairs = append(dec.pairs, kv)
+		prevkey = kv.k
+	}
+	if err := s.ListEnd(); err != nil {
+		return err
+	}
+	if err := dec.verifySignature(); err != nil {
+		return err
+	}
+	dec.raw = raw
+	*r = dec
+	return nil
+}
+
+// NodeAddr returns the node address, the uncompressed public key of the node
+// without its 0x04 prefix. The return value is nil if the record is unsigned.
+func (r *Record) NodeAddr() []byte {
+	var pub Secp256k1
+	if !r.Signed() || r.Load(&pub) != nil {
+		return nil
+	}
+	return crypto.FromECDSAPub((*ecdsa.PublicKey)(&pub))[1:]
+}
+
+// Sign signs the record with the given private key using the "v4" identity
+// scheme. It sets the "id" and "secp256k1" entries of the record, and updates
+// the signature and encoding. The record must not exceed SizeLimit bytes once
+// encoded.
+func (r *Record) Sign(priv *ecdsa.PrivateKey) error {
+	r.Set(IDv4)
+	r.Set(Secp256k1(priv.PublicKey))
+
+	sig, err := crypto.Sign(crypto.Keccak256(r.content()), priv)
+	if err != nil {
+		return err
+	}
+	sig = sig[:len(sig)-1] // remove the recovery id
+	raw, err := r.encode(sig)
+	if err != nil {
+		return err
+	}
+	r.signature, r.raw = sig, raw
+	return nil
+}
+
+// content returns the RLP encoding of the signed part of the record, its
+// sequence number and key/value pairs.
+func (r *Record) content() []byte {
+	list := make([]interface{}, 1, 2*len(r.pairs)+1)
+	list[0] = r.seq
+	for _, p := range r.pairs {
+		list = append(list, p.k, p.v)
+	}
+	content, err := rlp.EncodeToBytes(list)
+	if err != nil {
+		panic(err) // the keys and raw values always encode
+	}
+	return content
+}
+
+// encode assembles the RLP encoding of the record with the given signature.
+func (r *Record) encode(sig []byte) ([]byte, error) {
+	list := make([]interface{}, 2, 2*len(r.pairs)+2)
+	list[0], list[1] = sig, r.seq
+	for _, p := range r.pairs {
+		list = append(list, p.k, p.v)
+	}
+	raw, err := rlp.EncodeToBytes(list)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > SizeLimit {
+		return nil, errTooBig
+	}
+	return raw, nil
+}
+
+// verifySignature checks the signature of a decoded record against the public
+// key it contains.
+func (r *Record) verifySignature() error {
+	var id ID
+	if err := r.Load(&id); err != nil || id != IDv4 {
+		return errNoID
+	}
+	var pub Secp256k1
+	if err := r.Load(&pub); err != nil {
+		return err
+	}
+	if len(r.signature) != 64 {
+		return errInvalidSig
+	}
+	// The signature lacks the recovery id, try both candidates
+	hash, want := crypto.Keccak256(r.content()), crypto.FromECDSAPub((*ecdsa.PublicKey)(&pub))
+	for v := byte(0); v < 2; v++ {
+		have, err := crypto.Ecrecover(hash, append(append([]byte{}, r.signature...), v))
+		if err == nil && bytes.Equal(have, want) {
+			return nil
+		}
+	}
+	return errInvalidSig
+}
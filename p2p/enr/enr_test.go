@@ -0,0 +1,157 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package enr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/crypto"
+	"github.com/ethereumproject/go-ethereum/rlp"
+)
+
+var (
+	privkey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+	// pyRecord is the example record of EIP-778.
+	pyRecord, _ = hex.DecodeString("f884b8407098ad865b00a582051940cb9cf36836572411a47278783077011599ed5cd16b76f2635f4e234738f30813a89eb9137e3e3df5266e3a1f11df72ecf1145ccb9c01826964827634826970847f00000189736563703235366b31a103ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd31388375647082765f")
+)
+
+// Tests that the example record of EIP-778 is decoded and verified, and that
+// signing the same entries reproduces its content.
+func TestPythonInterop(t *testing.T) {
+	var r Record
+	if err := rlp.DecodeBytes(pyRecord, &r); err != nil {
+		t.Fatalf("can't decode: %v", err)
+	}
+	var (
+		ip  IP
+		udp UDP
+	)
+	if err := r.Load(&ip); err != nil || !net.IP(ip).Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("ip mismatch: have %v, %v", net.IP(ip), err)
+	}
+	if err := r.Load(&udp); err != nil || udp != 30303 {
+		t.Errorf("udp mismatch: have %d, %v", udp, err)
+	}
+	if r.Seq() != 1 {
+		t.Errorf("seq mismatch: have %d, want 1", r.Seq())
+	}
+	if have, want := r.NodeAddr(), crypto.FromECDSAPub(&privkey.PublicKey)[1:]; !bytes.Equal(have, want) {
+		t.Errorf("node address mismatch: have %x, want %x", have, want)
+	}
+	// Rebuild and sign the record locally
+	var local Record
+	local.SetSeq(1)
+	local.Set(IP(net.IPv4(127, 0, 0, 1)))
+	local.Set(UDP(30303))
+	if err := local.Sign(privkey); err != nil {
+		t.Fatalf("can't sign: %v", err)
+	}
+	if have, want := local.content(), r.content(); !bytes.Equal(have, want) {
+		t.Errorf("content mismatch:\nhave %x\nwant %x", have, want)
+	}
+}
+
+// Tests that records round-trip through their encoding and that setting an
+// entry of a signed record bumps its sequence number.
+func TestSignEncodeAndDecode(t *testing.T) {
+	var r Record
+	r.Set(UDP(30303))
+	r.Set(TCP(30304))
+	r.Set(WithEntry("eth", []uint{1, 2}))
+
+	if _, err := rlp.EncodeToBytes(r); err != errEncodeUnsigned {
+		t.Fatalf("unsigned encoding error mismatch: have %v, want %v", err, errEncodeUnsigned)
+	}
+	if err := r.Sign(privkey); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Record
+	if err := rlp.DecodeBytes(blob, &dec); err != nil {
+		t.Fatalf("can't decode: %v", err)
+	}
+	var (
+		tcp TCP
+		eth []uint
+	)
+	if err := dec.Load(&tcp); err != nil || tcp != 30304 {
+		t.Errorf("tcp mismatch: have %d, %v", tcp, err)
+	}
+	if err := dec.Load(WithEntry("eth", &eth)); err != nil || len(eth) != 2 || eth[1] != 2 {
+		t.Errorf("eth mismatch: have %v, %v", eth, err)
+	}
+	if err := dec.Load(new(IP)); !IsNotFound(err) {
+		t.Errorf("missing key error mismatch: have %v", err)
+	}
+	// Updating a signed record must bump its sequence number
+	dec.Set(TCP(30305))
+	if dec.Signed() || dec.Seq() != 1 {
+		t.Errorf("record not invalidated: signed %v, seq %d", dec.Signed(), dec.Seq())
+	}
+}
+
+// Tests that records with invalid signatures or content are rejected.
+func TestDecodeInvalid(t *testing.T) {
+	var r Record
+	r.Set(UDP(30303))
+	if err := r.Sign(privkey); err != nil {
+		t.Fatal(err)
+	}
+	// Tamper with the content after signing
+	tampered := make([]pair, len(r.pairs))
+	copy(tampered, r.pairs)
+	for i := range tampered {
+		if tampered[i].k == "udp" {
+			tampered[i].v, _ = rlp.EncodeToBytes(uint(30304))
+		}
+	}
+	forged := Record{seq: r.seq, signature: r.signature, pairs: tampered}
+	raw, err := forged.encode(r.signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rlp.DecodeBytes(raw, new(Record)); err != errInvalidSig {
+		t.Errorf("tampered record error mismatch: have %v, want %v", err, errInvalidSig)
+	}
+	// Unsorted and duplicate keys
+	for _, test := range []struct {
+		list []interface{}
+		err  error
+	}{
+		{[]interface{}{make([]byte, 64), uint(1), "udp", uint(1), "tcp", uint(1)}, errNotSorted},
+		{[]interface{}{make([]byte, 64), uint(1), "udp", uint(1), "udp", uint(1)}, errDuplicateKey},
+		{[]interface{}{make([]byte, 64), uint(1), "udp"}, errIncompletePair},
+	} {
+		raw, _ := rlp.EncodeToBytes(test.list)
+		if err := rlp.DecodeBytes(raw, new(Record)); err != test.err {
+			t.Errorf("decode error mismatch: have %v, want %v", err, test.err)
+		}
+	}
+	// Records must not exceed the size limit
+	var big Record
+	big.Set(WithEntry("x", make([]byte, SizeLimit)))
+	if err := big.Sign(privkey); err != errTooBig {
+		t.Errorf("oversized record error mismatch: have %v, want %v", err, errTooBig)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/ethereumproject/go-ethereum/p2p/discover"
+	"github.com/ethereumproject/go-ethereum/p2p/enr"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,11 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Attributes contains protocol specific information for the node record
+	// advertised by the discovery protocol. Entries can be updated at runtime
+	// using Server.SetNodeRecordEntry.
+	Attributes []enr.Entry
 }
 
 func (p Protocol) cap() Cap {
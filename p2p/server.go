@@ -29,6 +29,7 @@ import (
 	"github.com/ethereumproject/go-ethereum/logger"
 	"github.com/ethereumproject/go-ethereum/logger/glog"
 	"github.com/ethereumproject/go-ethereum/p2p/discover"
+	"github.com/ethereumproject/go-ethereum/p2p/enr"
 	"github.com/ethereumproject/go-ethereum/p2p/nat"
 )
 
@@ -308,6 +309,18 @@ func (srv *Server) Self() *discover.Node {
 	return srv.ntab.Self()
 }
 
+// SetNodeRecordEntry adds or updates an entry of the node record advertised
+// by the discovery protocol. It does nothing if discovery is disabled.
+func (srv *Server) SetNodeRecordEntry(e enr.Entry) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running || srv.ntab == nil {
+		return nil
+	}
+	return srv.ntab.SetRecordEntry(e)
+}
+
 // Stop terminates the server and all active peer connections.
 // It blocks until all active connections have been closed.
 func (srv *Server) Stop() {
@@ -364,6 +377,13 @@ func (srv *Server) Start() (err error) {
 		if err := ntab.SetFallbackNodes(srv.BootstrapNodes); err != nil {
 			return err
 		}
+		for _, p := range srv.Protocols {
+			for _, e := range p.Attributes {
+				if err := ntab.SetRecordEntry(e); err != nil {
+					return err
+				}
+			}
+		}
 		srv.ntab = ntab
 	}
 
@@ -0,0 +1,331 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clientLimitsExpiry is the time after which the rate limits of an idle
+	// client are dropped. Its buckets would have been refilled by then.
+	clientLimitsExpiry = 10 * time.Minute
+)
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid bearer token")
+)
+
+// Policy restricts the requests served over a public HTTP or websocket endpoint.
+// The zero value imposes no restrictions.
+type Policy struct {
+	// MaxRequestSize is the maximum size in bytes of a HTTP request body or of a
+	// websocket message. Zero means the transport default: 128 KiB over HTTP
+	// and unlimited over websockets.
+	MaxRequestSize int64
+
+	// Allow lists the methods (e.g. "eth_call") or modules (e.g. "net") that may
+	// be called. An empty list allows every method the server offers.
+	Allow []string
+
+	// Deny lists the methods or modules that may not be called, taking
+	// precedence over Allow.
+	Deny []string
+
+	// RateLimit is the request rate allowed per client IP over all methods.
+	RateLimit RateLimit
+
+	// MethodRateLimits are the request rates allowed per client IP for single
+	// methods, on top of RateLimit.
+	MethodRateLimits map[string]RateLimit
+
+	// AuthTokens are the bearer tokens accepted as is. If neither tokens nor a
+	// JWT secret are set, no authentication is required.
+	AuthTokens []string
+
+	// JWTSecret is the key of the HS256 signed JWT bearer tokens accepted. The
+	// tokens' "exp" and "nbf" claims are honoured.
+	JWTSecret []byte
+}
+
+// RateLimit is a token bucket rate limit. The zero value is unlimited.
+type RateLimit struct {
+	Rate  float64 // Requests per second
+	Burst int     // Requests allowed at once, at least one
+}
+
+// tokenBucket is the state of a single rate limit.
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+// take refills the bucket for the time passed and takes a token from it, if
+// there is one.
+func (b *tokenBucket) take(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if burst := float64(b.limit.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// clientLimits are the rate limit buckets of a single client.
+type clientLimits struct {
+	all     *tokenBucket
+	methods map[string]*tokenBucket
+	seen    time.Time
+}
+
+// accessControl enforces a Policy, tracking the rate limits of the clients.
+type accessControl struct {
+	policy Policy
+	allow  map[string]bool
+	deny   map[string]bool
+
+	lock    sync.Mutex
+	clients map[string]*clientLimits // Rate limits by client IP
+	swept   time.Time                // Last time the idle clients were dropped
+	now     func() time.Time         // Clock, replaceable in tests
+}
+
+func newAccessControl(policy Policy) *accessControl {
+	ac := &accessControl{
+		policy:  policy,
+		allow:   make(map[string]bool),
+		deny:    make(map[string]bool),
+		clients: make(map[string]*clientLimits),
+		now:     time.Now,
+	}
+	for _, name := range policy.Allow {
+		if name = strings.TrimSpace(name); name != "" {
+			ac.allow[name] = true
+		}
+	}
+	for _, name := range policy.Deny {
+		if name = strings.TrimSpace(name); name != "" {
+			ac.deny[name] = true
+		}
+	}
+	return ac
+}
+
+// maxRequestSize returns the configured request size limit, or the given
+// transport default.
+func (ac *accessControl) maxRequestSize(def int64) int64 {
+	if ac.policy.MaxRequestSize > 0 {
+		return ac.policy.MaxRequestSize
+	}
+	return def
+}
+
+// authenticate checks the bearer token of a HTTP or websocket upgrade request.
+func (ac *accessControl) authenticate(r *http.Request) error {
+	if len(ac.policy.AuthTokens) == 0 && len(ac.policy.JWTSecret) == 0 {
+		return nil
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return errMissingToken
+	}
+	token := strings.TrimSpace(auth[7:])
+	for _, accepted := range ac.policy.AuthTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(accepted)) == 1 {
+			return nil
+		}
+	}
+	if len(ac.policy.JWTSecret) > 0 {
+		return verifyJWT(token, ac.policy.JWTSecret, ac.now())
+	}
+	return errInvalidToken
+}
+
+// verifyJWT checks that token is a HS256 JWT signed with secret and valid at
+// the given time.
+func verifyJWT(token string, secret []byte, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if blob, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil || json.Unmarshal(blob, &header) != nil {
+		return errInvalidToken
+	}
+	if header.Alg != "HS256" {
+		return errInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errInvalidToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errInvalidToken
+	}
+	var claims struct {
+		Exp *float64 `json:"exp"`
+		Nbf *float64 `json:"nbf"`
+	}
+	if blob, err := base64.RawURLEncoding.DecodeString(parts[1]); err != nil || json.Unmarshal(blob, &claims) != nil {
+		return errInvalidToken
+	}
+	unix := float64(now.Unix())
+	if claims.Exp != nil && unix >= *claims.Exp {
+		return errors.New("bearer token expired")
+	}
+	if claims.Nbf != nil && unix < *claims.Nbf {
+		return errors.New("bearer token not yet valid")
+	}
+	return nil
+}
+
+// check returns an error if the client may not call the method, either
+// because the policy denies it or because the client exceeded its rate limits.
+// Clients without an IP, i.e. local ones, are not rate limited.
+func (ac *accessControl) check(client string, method string) RPCError {
+	module := method
+	if i := strings.Index(method, serviceMethodSeparator); i >= 0 {
+		module = method[:i]
+	}
+	if ac.deny[method] || ac.deny[module] {
+		return &methodNotAllowedError{method}
+	}
+	if len(ac.allow) > 0 && !ac.allow[method] && !ac.allow[module] {
+		return &methodNotAllowedError{method}
+	}
+	if client == "" {
+		return nil
+	}
+	methodLimit, limited := ac.policy.MethodRateLimits[method]
+	if ac.policy.RateLimit.Rate <= 0 && !limited {
+		return nil
+	}
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+
+	now := ac.now()
+	if now.Sub(ac.swept) > clientLimitsExpiry {
+		for ip, limits := range ac.clients {
+			if now.Sub(limits.seen) > clientLimitsExpiry {
+				delete(ac.clients, ip)
+			}
+		}
+		ac.swept = now
+	}
+	limits := ac.clients[client]
+	if limits == nil {
+		limits = &clientLimits{methods: make(map[string]*tokenBucket)}
+		if ac.policy.RateLimit.Rate > 0 {
+			limits.all = newTokenBucket(ac.policy.RateLimit, now)
+		}
+		ac.clients[client] = limits
+	}
+	limits.seen = now
+
+	if limited {
+		bucket := limits.methods[method]
+		if bucket == nil {
+			bucket = newTokenBucket(methodLimit, now)
+			limits.methods[method] = bucket
+		}
+		if !bucket.take(now) {
+			return &rateLimitError{method}
+		}
+	}
+	if limits.all != nil && !limits.all.take(now) {
+		return &rateLimitError{method}
+	}
+	return nil
+}
+
+// accessKey is the context key of the requestAccess of a connection.
+type accessKey struct{}
+
+// requestAccess is the access control applying to the requests of a connection
+// and the client they are checked for.
+type requestAccess struct {
+	control *accessControl
+	client  string
+}
+
+// withAccessControl returns a connection context enforcing the access control
+// for the client at the given remote address.
+func withAccessControl(ctx context.Context, ac *accessControl, remoteAddr string) context.Context {
+	client, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		client = remoteAddr
+	}
+	return context.WithValue(ctx, accessKey{}, &requestAccess{control: ac, client: client})
+}
+
+// checkAccess checks the method against the access control of the connection,
+// if there is any.
+func checkAccess(ctx context.Context, method string) RPCError {
+	access, ok := ctx.Value(accessKey{}).(*requestAccess)
+	if !ok {
+		return nil
+	}
+	return access.control.check(access.client, method)
+}
+
+// limitReader fails reads once the remaining allowance is used up.
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, &requestTooLargeError{l.limit}
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
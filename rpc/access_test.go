@@ -0,0 +1,252 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessControlMethods(t *testing.T) {
+	ac := newAccessControl(Policy{
+		Allow: []string{"eth_call", "net"},
+		Deny:  []string{"net_peerCount"},
+	})
+	tests := []struct {
+		method  string
+		allowed bool
+	}{
+		{"eth_call", true},
+		{"eth_sendTransaction", false},
+		{"net_version", true},
+		{"net_peerCount", false},
+		{"personal_unlockAccount", false},
+	}
+	for _, tt := range tests {
+		err := ac.check("", tt.method)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("%s: allowed %v, want %v", tt.method, allowed, tt.allowed)
+		}
+		if err != nil {
+			if _, ok := err.(*methodNotAllowedError); !ok {
+				t.Errorf("%s: have error %v, want method not allowed", tt.method, err)
+			}
+		}
+	}
+}
+
+func TestAccessControlRateLimits(t *testing.T) {
+	ac := newAccessControl(Policy{
+		RateLimit:        RateLimit{Rate: 10, Burst: 3},
+		MethodRateLimits: map[string]RateLimit{"eth_call": {Rate: 1, Burst: 1}},
+	})
+	now := time.Unix(1500000000, 0)
+	ac.now = func() time.Time { return now }
+
+	// The method limit is hit first, then the overall one
+	if err := ac.check("1.2.3.4", "eth_call"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if err := ac.check("1.2.3.4", "eth_call"); err == nil {
+		t.Fatal("second call within a second not limited")
+	}
+	for i := 0; i < 2; i++ {
+		if err := ac.check("1.2.3.4", "eth_blockNumber"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := ac.check("1.2.3.4", "eth_blockNumber"); err == nil {
+		t.Fatal("request beyond the burst not limited")
+	}
+	// Other and local clients have their own limits
+	if err := ac.check("5.6.7.8", "eth_call"); err != nil {
+		t.Fatalf("other client: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := ac.check("", "eth_call"); err != nil {
+			t.Fatalf("local client: %v", err)
+		}
+	}
+	// Buckets refill over time
+	now = now.Add(time.Second)
+	if err := ac.check("1.2.3.4", "eth_call"); err != nil {
+		t.Fatalf("call after refill: %v", err)
+	}
+}
+
+func makeJWT(secret []byte, claims string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x42}, 32)
+	now := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		token string
+		valid bool
+	}{
+		{makeJWT(secret, `{}`), true},
+		{makeJWT(secret, `{"exp":1500000060,"nbf":1499999940}`), true},
+		{makeJWT(secret, `{"exp":1500000000}`), false},
+		{makeJWT(secret, `{"nbf":1500000060}`), false},
+		{makeJWT([]byte("wrong"), `{}`), false},
+		{"not.a.jwt", false},
+	}
+	for i, tt := range tests {
+		if err := verifyJWT(tt.token, secret, now); (err == nil) != tt.valid {
+			t.Errorf("test %d: have error %v, want valid %v", i, err, tt.valid)
+		}
+	}
+}
+
+// postRPC sends a raw JSON-RPC request to the given HTTP endpoint.
+func postRPC(t *testing.T, url string, token string, body string) (int, *JSONResponse) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	blob, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	var msg JSONResponse
+	if err := json.Unmarshal(blob, &msg); err != nil {
+		t.Fatalf("invalid response %q: %v", blob, err)
+	}
+	return resp.StatusCode, &msg
+}
+
+func TestHTTPPolicy(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	hs := httptest.NewServer(NewHTTPServer("*", nil, Policy{
+		MaxRequestSize: 256,
+		Deny:           []string{"test_echo"},
+		AuthTokens:     []string{"secret"},
+	}, server).Handler)
+	defer hs.Close()
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"test_rets"}`
+	if code, _ := postRPC(t, hs.URL, "", request); code != http.StatusUnauthorized {
+		t.Errorf("missing token: have status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code, _ := postRPC(t, hs.URL, "guess", request); code != http.StatusUnauthorized {
+		t.Errorf("invalid token: have status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if _, resp := postRPC(t, hs.URL, "secret", request); resp == nil || resp.Error != nil {
+		t.Errorf("allowed method: have response %+v, want result", resp)
+	}
+	_, resp := postRPC(t, hs.URL, "secret", `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["a",1,{"S":"b"}]}`)
+	if resp == nil || resp.Error == nil || resp.Error.Code != new(methodNotAllowedError).Code() {
+		t.Errorf("denied method: have response %+v, want method not allowed", resp)
+	}
+	large := `{"jsonrpc":"2.0","id":1,"method":"test_rets","params":["` + strings.Repeat("x", 256) + `"]}`
+	if code, _ := postRPC(t, hs.URL, "secret", large); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large request: have status %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestPolicyHandler(t *testing.T) {
+	var served int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		served++
+	})
+	handler := NewPolicyHandler(Policy{
+		MaxRequestSize: 16,
+		AuthTokens:     []string{"secret"},
+		RateLimit:      RateLimit{Rate: 0.001, Burst: 1},
+	}, "graphql_query", next)
+
+	send := func(token, body string) int {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := send("", "{}"); code != http.StatusUnauthorized {
+		t.Errorf("missing token: have status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := send("secret", strings.Repeat("x", 17)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large request: have status %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+	if code := send("secret", "{}"); code != http.StatusOK {
+		t.Errorf("allowed request: have status %d, want %d", code, http.StatusOK)
+	}
+	if code := send("secret", "{}"); code != http.StatusTooManyRequests {
+		t.Errorf("rate limited request: have status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if served != 1 {
+		t.Errorf("served %d requests, want 1", served)
+	}
+
+	denied := NewPolicyHandler(Policy{Allow: []string{"eth"}}, "graphql_query", next)
+	rec := httptest.NewRecorder()
+	denied.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader("{}")))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("denied method: have status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestLimitedJSONCodec(t *testing.T) {
+	small := `{"jsonrpc":"2.0","id":1,"method":"test_rets"}`
+	large := `{"jsonrpc":"2.0","id":2,"method":"test_rets","params":["` + strings.Repeat("x", 100) + `"]}`
+
+	// Messages up to the limit pass, even if read together with the next one
+	in := &httpReadWriteNopCloser{strings.NewReader(small + small + large), ioutil.Discard}
+	codec := newLimitedJSONCodec(in, int64(len(small)))
+	for i := 0; i < 2; i++ {
+		if _, _, err := codec.ReadRequestHeaders(); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	if _, _, err := codec.ReadRequestHeaders(); err == nil {
+		t.Fatal("large message not refused")
+	}
+}
@@ -0,0 +1,779 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereumproject/go-ethereum/logger"
+	"github.com/ethereumproject/go-ethereum/logger/glog"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	// ErrClientQuit is returned when a call is made on a closed client.
+	ErrClientQuit = errors.New("client is closed")
+
+	// ErrNoResult is returned when the server responds without a result, e.g.
+	// because the requested object doesn't exist.
+	ErrNoResult = errors.New("no result in JSON-RPC response")
+
+	// ErrSubscriptionQueueOverflow is returned when the notifications of a
+	// subscription pile up because they aren't read from the channel.
+	ErrSubscriptionQueueOverflow = errors.New("subscription queue overflow")
+)
+
+const (
+	// maxClientSubscriptionBuffer is the number of notifications buffered per
+	// subscription before it is dropped with ErrSubscriptionQueueOverflow.
+	maxClientSubscriptionBuffer = 20000
+
+	// unsubscribeTimeout bounds the eth_unsubscribe call sent to the server
+	// when a subscription ends on the client side.
+	unsubscribeTimeout = 5 * time.Second
+)
+
+// BatchElem is an element in a batch request.
+type BatchElem struct {
+	Method string
+	Args   []interface{}
+	// The result is unmarshaled into this field. Result must be set to a
+	// non-nil pointer value of the desired type, otherwise the response will be
+	// discarded.
+	Result interface{}
+	// Error is set if the server returns an error for this request, or if
+	// unmarshaling into Result fails. It is not set for I/O errors.
+	Error error
+}
+
+// jsonrpcMessage is any JSON-RPC message the client sends or receives:
+// requests, responses and subscription notifications.
+type jsonrpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Error   *JSONError      `json:"error,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+}
+
+func (msg *jsonrpcMessage) isNotification() bool {
+	return msg.ID == nil && msg.Method == notificationMethod
+}
+
+func (msg *jsonrpcMessage) isResponse() bool {
+	return msg.ID != nil && msg.Method == ""
+}
+
+// ClientConn is a connection to an RPC server. Unlike Client it matches the
+// responses to their requests itself, so it can be used concurrently from
+// multiple goroutines, and calls can be bounded with a context.
+//
+// Over IPC, websockets and in-process connections the server may also push
+// subscription notifications, see Subscribe.
+type ClientConn struct {
+	idCounter uint32
+
+	// HTTP connections are request/response, everything else is a stream
+	// served by a single read loop.
+	http *httpConn
+	conn io.ReadWriteCloser
+
+	writeMu sync.Mutex // serializes the writes to conn
+	enc     *json.Encoder
+
+	mu       sync.Mutex                     // protects the fields below
+	respWait map[string]*requestOp          // calls waiting for their response
+	subs     map[string]*ClientSubscription // active subscriptions by server ID
+	err      error                          // set once the connection is gone
+	didQuit  chan struct{}                  // closed when the read loop exits
+}
+
+// requestOp is a call, or a batch of calls, waiting for its responses.
+type requestOp struct {
+	ids  []json.RawMessage
+	err  error
+	resp chan *jsonrpcMessage // receives up to len(ids) responses
+	sub  *ClientSubscription  // only set for subscribe requests
+}
+
+func (op *requestOp) wait(ctx context.Context) (*jsonrpcMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case resp, ok := <-op.resp:
+		if !ok {
+			return nil, op.err
+		}
+		return resp, nil
+	}
+}
+
+// Dial creates a new client for the given URL. The supported transports are
+// HTTP ("http://", "https://" or "rpc:"), websockets ("ws://" or "wss://") and
+// IPC, given as a file path with an optional "ipc:" prefix.
+func Dial(rawurl string) (*ClientConn, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext creates a new client for the given URL, like Dial. The context
+// bounds the connection attempt, it doesn't affect the returned client.
+func DialContext(ctx context.Context, rawurl string) (*ClientConn, error) {
+	switch {
+	case strings.HasPrefix(rawurl, "ipc:"):
+		return DialIPC(ctx, rawurl[4:])
+	case strings.HasPrefix(rawurl, "rpc:"):
+		return DialHTTP(rawurl[4:])
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return DialHTTP(rawurl)
+	case "ws", "wss":
+		return DialWebsocket(ctx, rawurl, "")
+	case "":
+		return DialIPC(ctx, rawurl)
+	default:
+		return nil, fmt.Errorf("unsupported RPC schema %q", u.Scheme)
+	}
+}
+
+// DialIPC creates a new IPC client that connects to the given endpoint. On
+// Unix it is a Unix socket, on Windows a named pipe.
+func DialIPC(ctx context.Context, endpoint string) (*ClientConn, error) {
+	conn, err := dialContext(ctx, func() (net.Conn, error) { return newIPCConnection(endpoint) })
+	if err != nil {
+		return nil, err
+	}
+	return newClientConn(conn), nil
+}
+
+// DialWebsocket creates a new websocket client. The origin is sent in the
+// handshake, it defaults to the local host name.
+func DialWebsocket(ctx context.Context, endpoint, origin string) (*ClientConn, error) {
+	if origin == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		origin = "http://" + hostname
+	}
+	config, err := websocket.NewConfig(endpoint, origin)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialContext(ctx, func() (net.Conn, error) { return websocket.DialConfig(config) })
+	if err != nil {
+		return nil, err
+	}
+	return newClientConn(conn), nil
+}
+
+// DialHTTP creates a new client posting every call to the given endpoint.
+// HTTP doesn't support subscriptions.
+func DialHTTP(endpoint string) (*ClientConn, error) {
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+	return &ClientConn{http: &httpConn{endpoint: endpoint}}, nil
+}
+
+// DialInProc attaches an in-process client to the given RPC server.
+func DialInProc(handler *Server) *ClientConn {
+	p1, p2 := net.Pipe()
+	go handler.ServeCodec(NewJSONCodec(p1), OptionMethodInvocation|OptionSubscriptions)
+	return newClientConn(p2)
+}
+
+// dialContext runs the blocking dial function, giving up when the context is
+// done. A connection established after that is closed right away.
+func dialContext(ctx context.Context, dial func() (net.Conn, error)) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := dial()
+		done <- result{conn, err}
+	}()
+	select {
+	case res := <-done:
+		return res.conn, res.err
+	case <-ctx.Done():
+		go func() {
+			if res := <-done; res.conn != nil {
+				res.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func newClientConn(conn io.ReadWriteCloser) *ClientConn {
+	c := &ClientConn{
+		conn:     conn,
+		enc:      json.NewEncoder(conn),
+		respWait: make(map[string]*requestOp),
+		subs:     make(map[string]*ClientSubscription),
+		didQuit:  make(chan struct{}),
+	}
+	go c.read()
+	return c
+}
+
+// Close closes the client, aborting any in-flight requests and ending all
+// subscriptions.
+func (c *ClientConn) Close() {
+	if c.http != nil {
+		return
+	}
+	c.conn.Close()
+	<-c.didQuit
+}
+
+// SupportedModules returns the collection of API's that the RPC server offers.
+func (c *ClientConn) SupportedModules() (map[string]string, error) {
+	var result map[string]string
+	ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+	defer cancel()
+	err := c.CallContext(ctx, &result, MetadataApi+"_modules")
+	return result, err
+}
+
+// Call performs a JSON-RPC call with the given arguments and unmarshals into
+// result if no error occurred.
+//
+// The result must be a pointer so that package json can unmarshal into it. You
+// can also pass nil, in which case the result is ignored.
+func (c *ClientConn) Call(result interface{}, method string, args ...interface{}) error {
+	return c.CallContext(context.Background(), result, method, args...)
+}
+
+// CallContext performs a JSON-RPC call with the given arguments. If the context
+// is canceled before the call has successfully returned, CallContext returns
+// immediately.
+//
+// The result must be a pointer so that package json can unmarshal into it. You
+// can also pass nil, in which case the result is ignored.
+func (c *ClientConn) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	msg, err := c.newMessage(method, args...)
+	if err != nil {
+		return err
+	}
+	var resp *jsonrpcMessage
+	if c.http != nil {
+		var resps []*jsonrpcMessage
+		if resps, err = c.http.send(ctx, msg, false); err == nil {
+			resp = resps[0]
+		}
+	} else {
+		op := &requestOp{ids: []json.RawMessage{msg.ID}, resp: make(chan *jsonrpcMessage, 1)}
+		if err = c.send(op, msg); err == nil {
+			resp, err = op.wait(ctx)
+			c.forget(op)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.Error != nil:
+		return resp.Error
+	case result == nil:
+		return nil
+	case len(resp.Result) == 0 || string(resp.Result) == "null":
+		return ErrNoResult
+	default:
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+// BatchCall sends all given requests as a single batch and waits for the server
+// to return a response for all of them. The wait duration is bounded by the
+// context's deadline.
+//
+// In contrast to CallContext, BatchCall only returns errors that have occurred
+// while sending the request. Any error specific to a request is reported
+// through the Error field of the corresponding BatchElem.
+func (c *ClientConn) BatchCall(ctx context.Context, b []BatchElem) error {
+	msgs := make([]*jsonrpcMessage, len(b))
+	byID := make(map[string]int, len(b))
+	for i, elem := range b {
+		msg, err := c.newMessage(elem.Method, elem.Args...)
+		if err != nil {
+			return err
+		}
+		msgs[i] = msg
+		byID[string(msg.ID)] = i
+	}
+	var resps []*jsonrpcMessage
+	if c.http != nil {
+		var err error
+		if resps, err = c.http.send(ctx, msgs, true); err != nil {
+			return err
+		}
+	} else {
+		op := &requestOp{resp: make(chan *jsonrpcMessage, len(msgs))}
+		for _, msg := range msgs {
+			op.ids = append(op.ids, msg.ID)
+		}
+		if err := c.send(op, msgs); err != nil {
+			return err
+		}
+		defer c.forget(op)
+		for range msgs {
+			resp, err := op.wait(ctx)
+			if err != nil {
+				return err
+			}
+			resps = append(resps, resp)
+		}
+	}
+	for _, resp := range resps {
+		i, ok := byID[string(resp.ID)]
+		if !ok {
+			continue
+		}
+		elem := &b[i]
+		switch {
+		case resp.Error != nil:
+			elem.Error = resp.Error
+		case elem.Result == nil:
+		case len(resp.Result) == 0 || string(resp.Result) == "null":
+			elem.Error = ErrNoResult
+		default:
+			elem.Error = json.Unmarshal(resp.Result, elem.Result)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a subscription through eth_subscribe. Notifications
+// are delivered to the channel argument, which must be a writable channel of
+// the type the notifications unmarshal into. The first argument is the name of
+// the subscription (e.g. "logs"), followed by its parameters.
+//
+// Slow consumers are not dropped until the buffer of pending notifications
+// (about 20000 of them) fills up, after that the subscription is ended with
+// ErrSubscriptionQueueOverflow. Subscriptions aren't supported over HTTP.
+func (c *ClientConn) Subscribe(ctx context.Context, channel interface{}, args ...interface{}) (*ClientSubscription, error) {
+	// Check type of channel first
+	chanVal := reflect.ValueOf(channel)
+	if chanVal.Kind() != reflect.Chan || chanVal.Type().ChanDir()&reflect.SendDir == 0 {
+		panic("first argument to Subscribe must be a writable channel")
+	}
+	if chanVal.IsNil() {
+		panic("channel given to Subscribe must not be nil")
+	}
+	if c.http != nil {
+		return nil, ErrNotificationsUnsupported
+	}
+	msg, err := c.newMessage(subscribeMethod, args...)
+	if err != nil {
+		return nil, err
+	}
+	op := &requestOp{
+		ids:  []json.RawMessage{msg.ID},
+		resp: make(chan *jsonrpcMessage, 1),
+		sub:  newClientSubscription(c, chanVal),
+	}
+	// Send the subscription request. The read loop registers the subscription
+	// as it handles the response, so no notification is missed.
+	if err := c.send(op, msg); err != nil {
+		return nil, err
+	}
+	resp, err := op.wait(ctx)
+	if err != nil {
+		// The response may still arrive. The op stays registered so that the
+		// read loop can unsubscribe it on the server then.
+		op.sub.quitWithError(nil, true)
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if op.sub.subid == "" {
+		return nil, ErrNoResult
+	}
+	return op.sub, nil
+}
+
+// newMessage assembles a request with a fresh ID.
+func (c *ClientConn) newMessage(method string, args ...interface{}) (*jsonrpcMessage, error) {
+	params, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	id := strconv.AppendUint(nil, uint64(atomic.AddUint32(&c.idCounter, 1)), 10)
+	return &jsonrpcMessage{Version: JSONRPCVersion, ID: id, Method: method, Params: params}, nil
+}
+
+// send registers op with the read loop and writes the request(s) to the
+// connection.
+func (c *ClientConn) send(op *requestOp, msg interface{}) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	for _, id := range op.ids {
+		c.respWait[string(id)] = op
+	}
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	err := c.enc.Encode(msg)
+	c.writeMu.Unlock()
+
+	if err != nil {
+		c.forget(op)
+		return err
+	}
+	return nil
+}
+
+// forget stops waiting for the responses to op.
+func (c *ClientConn) forget(op *requestOp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range op.ids {
+		if c.respWait[string(id)] == op {
+			delete(c.respWait, string(id))
+		}
+	}
+}
+
+// read decodes the incoming messages and routes them to the waiting calls and
+// subscriptions until the connection fails or is closed.
+func (c *ClientConn) read() {
+	defer close(c.didQuit)
+
+	dec := json.NewDecoder(c.conn)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			c.fail(err)
+			return
+		}
+		var msgs []*jsonrpcMessage
+		if isBatch(raw) {
+			if err := json.Unmarshal(raw, &msgs); err != nil {
+				glog.V(logger.Debug).Infof("rpc client: invalid batch response: %v", err)
+				continue
+			}
+		} else {
+			msg := new(jsonrpcMessage)
+			if err := json.Unmarshal(raw, msg); err != nil {
+				glog.V(logger.Debug).Infof("rpc client: invalid message: %v", err)
+				continue
+			}
+			msgs = append(msgs, msg)
+		}
+		for _, msg := range msgs {
+			switch {
+			case msg.isNotification():
+				c.handleNotification(msg)
+			case msg.isResponse():
+				c.handleResponse(msg)
+			default:
+				glog.V(logger.Debug).Infof("rpc client: unexpected message %s", raw)
+			}
+		}
+	}
+}
+
+func (c *ClientConn) handleNotification(msg *jsonrpcMessage) {
+	var params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		glog.V(logger.Debug).Infof("rpc client: invalid notification: %v", err)
+		return
+	}
+	c.mu.Lock()
+	sub := c.subs[params.Subscription]
+	c.mu.Unlock()
+
+	if sub != nil {
+		sub.deliver(params.Result)
+	}
+}
+
+func (c *ClientConn) handleResponse(msg *jsonrpcMessage) {
+	c.mu.Lock()
+	op := c.respWait[string(msg.ID)]
+	delete(c.respWait, string(msg.ID))
+	c.mu.Unlock()
+
+	if op == nil {
+		glog.V(logger.Debug).Infof("rpc client: unsolicited response %s", msg.ID)
+		return
+	}
+	// Register subscriptions before handing out the response, notifications
+	// may follow right after it.
+	if op.sub != nil && msg.Error == nil {
+		var subid string
+		if err := json.Unmarshal(msg.Result, &subid); err == nil && subid != "" {
+			c.mu.Lock()
+			select {
+			case <-op.sub.quit:
+				// The subscriber stopped waiting, drop it on the server too
+				go c.unsubscribe(subid)
+			default:
+				op.sub.subid = subid
+				c.subs[subid] = op.sub
+				go op.sub.start()
+			}
+			c.mu.Unlock()
+		}
+	}
+	op.resp <- msg
+}
+
+// fail aborts all pending calls and ends all subscriptions with the read error.
+func (c *ClientConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == io.EOF || isClosedErr(err) {
+		err = ErrClientQuit
+	}
+	c.err = err
+	for id, op := range c.respWait {
+		if op.err == nil {
+			op.err = err
+			close(op.resp)
+		}
+		delete(c.respWait, id)
+	}
+	for id, sub := range c.subs {
+		go sub.quitWithError(err, false)
+		delete(c.subs, id)
+	}
+}
+
+// isClosedErr reports whether err is the result of reading from a connection
+// closed on our side.
+func isClosedErr(err error) bool {
+	return err == io.ErrClosedPipe || strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// ClientSubscription is a subscription established through ClientConn's
+// Subscribe method.
+type ClientSubscription struct {
+	client  *ClientConn
+	etype   reflect.Type
+	channel reflect.Value
+	subid   string
+	in      chan json.RawMessage
+
+	quitOnce sync.Once     // ensures quit is closed once
+	quit     chan struct{} // quit is closed when the subscription exits
+	errOnce  sync.Once     // ensures err is closed once
+	err      chan error
+}
+
+func newClientSubscription(c *ClientConn, channel reflect.Value) *ClientSubscription {
+	return &ClientSubscription{
+		client:  c,
+		etype:   channel.Type().Elem(),
+		channel: channel,
+		quit:    make(chan struct{}),
+		err:     make(chan error, 1),
+		in:      make(chan json.RawMessage),
+	}
+}
+
+// Err returns the subscription error channel. The intended use of Err is to
+// schedule resubscription when the client connection is closed unexpectedly.
+//
+// The error channel receives a value when the subscription has ended due to
+// an error. The received error is ErrClientQuit if Close has been called on
+// the underlying client and no other error has occurred.
+//
+// The error channel is closed when Unsubscribe is called on the subscription.
+func (sub *ClientSubscription) Err() <-chan error {
+	return sub.err
+}
+
+// Unsubscribe unsubscribes the notification and closes the error channel.
+// It can safely be called more than once.
+func (sub *ClientSubscription) Unsubscribe() {
+	sub.quitWithError(nil, true)
+	sub.errOnce.Do(func() { close(sub.err) })
+}
+
+func (sub *ClientSubscription) quitWithError(err error, unsubscribeServer bool) {
+	sub.quitOnce.Do(func() {
+		// The dispatch loop won't be able to execute the unsubscribe call if
+		// it is blocked on deliver. Close sub.quit first because it unblocks
+		// deliver.
+		close(sub.quit)
+		if unsubscribeServer {
+			sub.requestUnsubscribe()
+		}
+		if err != nil {
+			sub.err <- err
+		}
+	})
+}
+
+// deliver hands a notification to the forwarding loop, reporting whether the
+// subscription is still active.
+func (sub *ClientSubscription) deliver(result json.RawMessage) (ok bool) {
+	select {
+	case sub.in <- result:
+		return true
+	case <-sub.quit:
+		return false
+	}
+}
+
+func (sub *ClientSubscription) start() {
+	sub.quitWithError(sub.forward())
+}
+
+// forward moves the notifications into the subscriber's channel, buffering the
+// ones the subscriber isn't ready for yet.
+func (sub *ClientSubscription) forward() (err error, unsubscribeServer bool) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sub.quit)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sub.in)},
+		{Dir: reflect.SelectSend, Chan: sub.channel},
+	}
+	buffer := list.New()
+	for {
+		var chosen int
+		var recv reflect.Value
+		if buffer.Len() == 0 {
+			// Idle, omit send case.
+			chosen, recv, _ = reflect.Select(cases[:2])
+		} else {
+			// Non-empty buffer, send the first queued item.
+			cases[2].Send = reflect.ValueOf(buffer.Front().Value)
+			chosen, recv, _ = reflect.Select(cases)
+		}
+
+		switch chosen {
+		case 0: // <-sub.quit
+			return nil, false
+		case 1: // <-sub.in
+			val, err := sub.unmarshal(recv.Interface().(json.RawMessage))
+			if err != nil {
+				return err, true
+			}
+			if buffer.Len() == maxClientSubscriptionBuffer {
+				return ErrSubscriptionQueueOverflow, true
+			}
+			buffer.PushBack(val)
+		case 2: // sub.channel<-
+			cases[2].Send = reflect.Value{} // Don't hold onto the value.
+			buffer.Remove(buffer.Front())
+		}
+	}
+}
+
+func (sub *ClientSubscription) unmarshal(result json.RawMessage) (interface{}, error) {
+	val := reflect.New(sub.etype)
+	err := json.Unmarshal(result, val.Interface())
+	return val.Elem().Interface(), err
+}
+
+// requestUnsubscribe drops the subscription on the client and tells the server
+// to stop sending notifications for it.
+func (sub *ClientSubscription) requestUnsubscribe() {
+	c := sub.client
+
+	c.mu.Lock()
+	subid := sub.subid
+	if subid != "" && c.subs[subid] == sub {
+		delete(c.subs, subid)
+	}
+	c.mu.Unlock()
+
+	// Not registered yet, the read loop unsubscribes when the response arrives
+	if subid != "" {
+		go c.unsubscribe(subid)
+	}
+}
+
+// unsubscribe tells the server to end the subscription with the given ID.
+func (c *ClientConn) unsubscribe(subid string) {
+	ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+	defer cancel()
+	c.CallContext(ctx, nil, unsubscribeMethod, subid)
+}
+
+// httpConn posts the requests of a ClientConn to an HTTP endpoint.
+type httpConn struct {
+	endpoint string
+	client   http.Client
+}
+
+// send posts a single request or a batch and returns the response(s).
+func (hc *httpConn) send(ctx context.Context, msg interface{}, batch bool) ([]*jsonrpcMessage, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", hc.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hc.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	}
+	var resps []*jsonrpcMessage
+	if batch {
+		err = json.NewDecoder(resp.Body).Decode(&resps)
+	} else {
+		msg := new(jsonrpcMessage)
+		err = json.NewDecoder(resp.Body).Decode(msg)
+		resps = append(resps, msg)
+	}
+	return resps, err
+}
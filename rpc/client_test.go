@@ -0,0 +1,286 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// SleepService has a call that blocks for a while, to test call timeouts.
+type SleepService struct{}
+
+func (s *SleepService) Sleep(ctx context.Context, ms int) error {
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CounterService has a subscription counting up from a given value.
+type CounterService struct{}
+
+func (s *CounterService) Counter(ctx context.Context, n, val int) (Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	subscription, err := notifier.NewSubscription(nil)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := subscription.Notify(val + i); err != nil {
+				return
+			}
+		}
+	}()
+	return subscription, nil
+}
+
+// DelayedService creates its subscription after a delay, to test subscribe
+// timeouts. The IDs of ended subscriptions are sent on unsubscribed.
+type DelayedService struct {
+	unsubscribed chan string
+}
+
+func (s *DelayedService) Delayed(ctx context.Context, ms int) (Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return notifier.NewSubscription(func(id string) { s.unsubscribed <- id })
+}
+
+func newTestServer(t *testing.T) *Server {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("sleep", new(SleepService)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("eth", new(CounterService)); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestClientRequest(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp Result
+	if err := client.Call(&resp, "test_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{"hello", 10, &Args{"world"}}); !reflect.DeepEqual(resp, want) {
+		t.Errorf("incorrect result %#v, want %#v", resp, want)
+	}
+	// Calls without a return value only succeed if the result is ignored
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Errorf("ignored result: have error %v", err)
+	}
+	if err := client.Call(&resp, "test_noArgsRets"); err != ErrNoResult {
+		t.Errorf("missing result: have error %v, want %v", err, ErrNoResult)
+	}
+	// Errors are returned as reported by the server
+	err := client.Call(&resp, "test_nonexistent")
+	if jsonErr, ok := err.(*JSONError); !ok || jsonErr.Code != new(methodNotFoundError).Code() {
+		t.Errorf("unknown method: have error %v, want method not found", err)
+	}
+}
+
+func TestClientBatchRequest(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := []BatchElem{
+		{
+			Method: "test_echo",
+			Args:   []interface{}{"hello", 10, &Args{"world"}},
+			Result: new(Result),
+		},
+		{
+			Method: "test_echo",
+			Args:   []interface{}{"hello2", 11, &Args{"world"}},
+			Result: new(Result),
+		},
+		{
+			Method: "test_nonexistent",
+			Args:   []interface{}{1, 2, 3},
+			Result: new(int),
+		},
+	}
+	if err := client.BatchCall(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{"hello", 10, &Args{"world"}}); !reflect.DeepEqual(*batch[0].Result.(*Result), want) || batch[0].Error != nil {
+		t.Errorf("batch 0: have %#v (error %v), want %#v", batch[0].Result, batch[0].Error, want)
+	}
+	if want := (Result{"hello2", 11, &Args{"world"}}); !reflect.DeepEqual(*batch[1].Result.(*Result), want) || batch[1].Error != nil {
+		t.Errorf("batch 1: have %#v (error %v), want %#v", batch[1].Result, batch[1].Error, want)
+	}
+	if batch[2].Error == nil {
+		t.Errorf("batch 2: missing error for unknown method")
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.CallContext(ctx, nil, "sleep_sleep", 1000); err != context.DeadlineExceeded {
+		t.Fatalf("have error %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The late response must be dropped, leaving the client usable
+	var resp Result
+	if err := client.Call(&resp, "test_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatalf("call after timeout failed: %v", err)
+	}
+	if resp.String != "hello" {
+		t.Errorf("call after timeout: have %q, want %q", resp.String, "hello")
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	nc := make(chan int)
+	count := 10
+	sub, err := client.Subscribe(context.Background(), nc, "counter", count, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case val := <-nc:
+			if val != i {
+				t.Fatalf("value mismatch: have %d, want %d", val, i)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription error: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("notification %d timeout", i)
+		}
+	}
+	sub.Unsubscribe()
+	if _, ok := <-sub.Err(); ok {
+		t.Fatal("error channel not closed after unsubscribe")
+	}
+}
+
+func TestClientSubscribeTimeout(t *testing.T) {
+	service := &DelayedService{unsubscribed: make(chan string, 1)}
+	server := NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.Subscribe(ctx, make(chan int), "delayed", 200); err != context.DeadlineExceeded {
+		t.Fatalf("have error %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The subscription created by the late response must be dropped on the server
+	select {
+	case <-service.unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("subscription not ended after subscribe timeout")
+	}
+}
+
+func TestClientSubscribeClose(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	client := DialInProc(server)
+
+	sub, err := client.Subscribe(context.Background(), make(chan int), "counter", 0, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	client.Close()
+
+	select {
+	case err := <-sub.Err():
+		if err != ErrClientQuit {
+			t.Errorf("have error %v, want %v", err, ErrClientQuit)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not ended after closing the client")
+	}
+	if err := client.Call(nil, "test_noArgsRets"); err != ErrClientQuit {
+		t.Errorf("call on closed client: have error %v, want %v", err, ErrClientQuit)
+	}
+}
+
+func TestClientHTTP(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Stop()
+	hs := httptest.NewServer(NewHTTPServer("*", nil, Policy{}, server).Handler)
+	defer hs.Close()
+
+	client, err := Dial(hs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var resp Result
+	if err := client.Call(&resp, "test_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Result{"hello", 10, &Args{"world"}}); !reflect.DeepEqual(resp, want) {
+		t.Errorf("incorrect result %#v, want %#v", resp, want)
+	}
+	batch := []BatchElem{
+		{Method: "test_echo", Args: []interface{}{"a", 1, &Args{"b"}}, Result: new(Result)},
+		{Method: "test_echo", Args: []interface{}{"c", 2, &Args{"d"}}, Result: new(Result)},
+	}
+	if err := client.BatchCall(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	if res := batch[1].Result.(*Result); res.String != "c" || batch[1].Error != nil {
+		t.Errorf("batch 1: have %#v (error %v)", res, batch[1].Error)
+	}
+	if _, err := client.Subscribe(context.Background(), make(chan int), "counter", 1, 0); err != ErrNotificationsUnsupported {
+		t.Errorf("subscribe over HTTP: have error %v, want %v", err, ErrNotificationsUnsupported)
+	}
+}
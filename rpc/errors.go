@@ -95,3 +95,38 @@ func (e *shutdownError) Code() int {
 func (e *shutdownError) Error() string {
 	return "server is shutting down"
 }
+
+// method is denied by the access policy of the endpoint
+type methodNotAllowedError struct {
+	method string
+}
+
+func (e *methodNotAllowedError) Code() int {
+	return -32004
+}
+
+func (e *methodNotAllowedError) Error() string {
+	return fmt.Sprintf("The method %s is not allowed", e.method)
+}
+
+// client exceeded its request rate on the endpoint
+type rateLimitError struct {
+	method string
+}
+
+func (e *rateLimitError) Code() int {
+	return -32005
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s", e.method)
+}
+
+// request exceeded the size limit of the endpoint
+type requestTooLargeError struct {
+	limit int64
+}
+
+func (e *requestTooLargeError) Error() string {
+	return fmt.Sprintf("request too large (>%d bytes)", e.limit)
+}
@@ -18,10 +18,12 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strings"
 
@@ -29,7 +31,7 @@ import (
 )
 
 const (
-	maxHTTPRequestContentLength = 1024 * 128
+	maxHTTPRequestContentLength = 1024 * 128 // default request size limit
 )
 
 // httpClient connects to a geth RPC server over HTTP.
@@ -91,28 +93,115 @@ func (t *httpReadWriteNopCloser) Close() error {
 
 // newJSONHTTPHandler creates a HTTP handler that will parse incoming JSON requests,
 // send the request to the given API provider and sends the response back to the caller.
-func newJSONHTTPHandler(srv *Server) http.HandlerFunc {
+// Requests are subject to the given access control.
+func newJSONHTTPHandler(srv *Server, ac *accessControl) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.ContentLength > maxHTTPRequestContentLength {
+		limit := ac.maxRequestSize(maxHTTPRequestContentLength)
+		if r.ContentLength > limit {
 			http.Error(w,
-				fmt.Sprintf("content length too large (%d>%d)", r.ContentLength, maxHTTPRequestContentLength),
+				fmt.Sprintf("content length too large (%d>%d)", r.ContentLength, limit),
 				http.StatusRequestEntityTooLarge)
 			return
 		}
+		if err := ac.authenticate(r); err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 
 		w.Header().Set("content-type", "application/json")
 
 		// create a codec that reads direct from the request body until
 		// EOF and writes the response to w and order the server to process
-		// a single request.
-		codec := NewJSONCodec(&httpReadWriteNopCloser{r.Body, w})
+		// a single request. Chunked bodies are cut off at the size limit.
+		body := http.MaxBytesReader(w, r.Body, limit)
+		codec := NewJSONCodec(&httpReadWriteNopCloser{body, w})
 		defer codec.Close()
-		srv.ServeSingleRequest(codec, OptionMethodInvocation)
+		srv.serveRequest(withAccessControl(context.Background(), ac, r.RemoteAddr), codec, true, OptionMethodInvocation)
+	}
+}
+
+// NewPolicyHandler wraps a HTTP handler of requests other than JSON-RPC calls,
+// such as GraphQL queries, in the policy. The requests are authenticated,
+// limited in size and checked against the allowed methods and rate limits as
+// calls of the given method.
+func NewPolicyHandler(policy Policy, method string, next http.Handler) http.Handler {
+	ac := newAccessControl(policy)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := ac.maxRequestSize(maxHTTPRequestContentLength)
+		if r.ContentLength > limit {
+			http.Error(w,
+				fmt.Sprintf("content length too large (%d>%d)", r.ContentLength, limit),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := ac.authenticate(r); err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if err := ac.check(client, method); err != nil {
+			status := http.StatusForbidden
+			if _, ok := err.(*rateLimitError); ok {
+				status = http.StatusTooManyRequests
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// virtualHostHandler is a handler which validates the Host header of incoming
+// requests, preventing DNS rebinding attacks on endpoints bound to private
+// interfaces.
+type virtualHostHandler struct {
+	vhosts map[string]bool
+	next   http.Handler
+}
+
+// NewVirtualHostHandler wraps the handler to only serve requests for the given
+// virtual hostnames. Requests to IP addresses are always served, while '*'
+// matches any hostname. An empty list disables the validation.
+func NewVirtualHostHandler(vhosts []string, next http.Handler) http.Handler {
+	hosts := make(map[string]bool)
+	for _, host := range vhosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts[host] = true
+		}
+	}
+	if len(hosts) == 0 || hosts["*"] {
+		return next
+	}
+	return &virtualHostHandler{vhosts: hosts, next: next}
+}
+
+func (h *virtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// HTTP/1.0 requests may not carry a Host header
+	if r.Host == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		// No port, the Host header is the hostname itself
+		host = r.Host
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) != nil || h.vhosts[strings.ToLower(host)] {
+		h.next.ServeHTTP(w, r)
+		return
 	}
+	http.Error(w, "invalid host specified", http.StatusForbidden)
 }
 
-// NewHTTPServer creates a new HTTP RPC server around an API provider.
-func NewHTTPServer(corsString string, srv *Server) *http.Server {
+// NewHTTPServer creates a new HTTP RPC server around an API provider, serving
+// the requests for the given virtual hosts the given policy permits.
+func NewHTTPServer(corsString string, vhosts []string, policy Policy, srv *Server) *http.Server {
 	var allowedOrigins []string
 	for _, domain := range strings.Split(corsString, ",") {
 		allowedOrigins = append(allowedOrigins, strings.TrimSpace(domain))
@@ -121,9 +210,10 @@ func NewHTTPServer(corsString string, srv *Server) *http.Server {
 	c := cors.New(cors.Options{
 		AllowedOrigins: allowedOrigins,
 		AllowedMethods: []string{"POST", "GET"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
 	})
 
-	handler := c.Handler(newJSONHTTPHandler(srv))
+	handler := NewVirtualHostHandler(vhosts, c.Handler(newJSONHTTPHandler(srv, newAccessControl(policy))))
 
 	return &http.Server{
 		Handler: handler,
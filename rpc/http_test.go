@@ -0,0 +1,62 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVirtualHostHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := NewVirtualHostHandler([]string{"localhost", "Node.LAN"}, ok)
+
+	tests := []struct {
+		host string
+		code int
+	}{
+		{"localhost", http.StatusOK},
+		{"localhost:8545", http.StatusOK},
+		{"node.lan:8545", http.StatusOK},
+		{"127.0.0.1:8545", http.StatusOK},
+		{"[::1]:8545", http.StatusOK},
+		{"::1", http.StatusOK},
+		{"", http.StatusOK},
+		{"attacker.example.com", http.StatusForbidden},
+		{"attacker.example.com:8545", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("host %q: have status %d, want %d", tt.host, w.Code, tt.code)
+		}
+	}
+	// Wildcards and empty lists accept any host
+	for _, vhosts := range [][]string{{"*"}, nil} {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Host = "attacker.example.com"
+		w := httptest.NewRecorder()
+		NewVirtualHostHandler(vhosts, ok).ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("vhosts %v: have status %d, want %d", vhosts, w.Code, http.StatusOK)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"strconv"
 	"strings"
@@ -60,6 +61,20 @@ type JSONError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// Error implements error, so that error responses can be returned as is by
+// the client.
+func (err *JSONError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("json-rpc error %d", err.Code)
+	}
+	return err.Message
+}
+
+// ErrorCode returns the JSON-RPC error code.
+func (err *JSONError) ErrorCode() int {
+	return err.Code
+}
+
 // JSON-RPC notification payload
 type jsonSubscription struct {
 	Subscription string      `json:"subscription"`
@@ -83,6 +98,7 @@ type jsonCodec struct {
 	encMu  sync.Mutex         // guards e
 	e      *json.Encoder      // encodes responses
 	rw     io.ReadWriteCloser // connection
+	limit  *limitReader       // limits the size of requests, if set
 }
 
 // NewJSONCodec creates a new RPC server codec with support for JSON-RPC 2.0
@@ -92,6 +108,15 @@ func NewJSONCodec(rwc io.ReadWriteCloser) ServerCodec {
 	return &jsonCodec{closed: make(chan interface{}), d: d, e: json.NewEncoder(rwc), rw: rwc}
 }
 
+// newLimitedJSONCodec creates a new RPC server codec with support for JSON-RPC
+// 2.0, refusing requests larger than limit bytes.
+func newLimitedJSONCodec(rwc io.ReadWriteCloser, limit int64) ServerCodec {
+	lr := &limitReader{r: rwc, limit: limit}
+	d := json.NewDecoder(lr)
+	d.UseNumber()
+	return &jsonCodec{closed: make(chan interface{}), d: d, e: json.NewEncoder(rwc), rw: rwc, limit: lr}
+}
+
 // isBatch returns true when the first non-whitespace characters is '['
 func isBatch(msg json.RawMessage) bool {
 	for _, c := range msg {
@@ -111,6 +136,12 @@ func (c *jsonCodec) ReadRequestHeaders() ([]rpcRequest, bool, RPCError) {
 	c.decMu.Lock()
 	defer c.decMu.Unlock()
 
+	if c.limit != nil {
+		// The decoder may have buffered the start of the message already,
+		// which counts against the limit as well.
+		buffered, _ := io.Copy(ioutil.Discard, c.d.Buffered())
+		c.limit.remaining = c.limit.limit - buffered
+	}
 	var incomingMsg json.RawMessage
 	if err := c.d.Decode(&incomingMsg); err != nil {
 		return nil, false, &invalidRequestError{err.Error()}
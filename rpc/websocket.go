@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -87,16 +88,32 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 	return f
 }
 
-// NewWSServer creates a new websocket RPC server around an API provider.
-func NewWSServer(allowedOrigins string, handler *Server) *http.Server {
+// NewWSServer creates a new websocket RPC server around an API provider, serving
+// the requests for the given virtual hosts the given policy permits.
+func NewWSServer(allowedOrigins string, vhosts []string, policy Policy, handler *Server) *http.Server {
+	ac := newAccessControl(policy)
+	validateOrigin := wsHandshakeValidator(strings.Split(allowedOrigins, ","))
+
 	return &http.Server{
-		Handler: websocket.Server{
-			Handshake: wsHandshakeValidator(strings.Split(allowedOrigins, ",")),
+		Handler: NewVirtualHostHandler(vhosts, websocket.Server{
+			Handshake: func(cfg *websocket.Config, req *http.Request) error {
+				if err := validateOrigin(cfg, req); err != nil {
+					return err
+				}
+				return ac.authenticate(req)
+			},
 			Handler: func(conn *websocket.Conn) {
-				handler.ServeCodec(NewJSONCodec(&wsReaderWriterCloser{conn}),
-					OptionMethodInvocation|OptionSubscriptions)
+				var codec ServerCodec
+				if limit := ac.maxRequestSize(0); limit > 0 {
+					codec = newLimitedJSONCodec(&wsReaderWriterCloser{conn}, limit)
+				} else {
+					codec = NewJSONCodec(&wsReaderWriterCloser{conn})
+				}
+				defer codec.Close()
+				ctx := withAccessControl(context.Background(), ac, conn.Request().RemoteAddr)
+				handler.serveRequest(ctx, codec, false, OptionMethodInvocation|OptionSubscriptions)
 			},
-		},
+		}),
 	}
 }
 
@@ -151,11 +151,18 @@ type RuleSet struct {
 	HomesteadGasRepriceBlock *big.Int
 	DiehardBlock             *big.Int
 	ExplosionBlock           *big.Int
+	AtlantisBlock            *big.Int
 }
 
 func (r RuleSet) IsHomestead(n *big.Int) bool {
 	return n.Cmp(r.HomesteadBlock) >= 0
 }
+func (r RuleSet) IsAtlantis(n *big.Int) bool {
+	return r.AtlantisBlock != nil && n.Cmp(r.AtlantisBlock) >= 0
+}
+func (r RuleSet) Precompiled(*big.Int) map[string]*vm.PrecompiledAccount {
+	return vm.Precompiled
+}
 func (r RuleSet) GasTable(num *big.Int) *vm.GasTable {
 	if r.HomesteadGasRepriceBlock == nil || num == nil || num.Cmp(r.HomesteadGasRepriceBlock) < 0 {
 		return &vm.GasTable{
@@ -248,7 +255,7 @@ func NewEnvFromMap(ruleSet RuleSet, state *state.StateDB, envValues map[string]s
 	}
 	env.Gas = new(big.Int)
 
-	env.evm = vm.New(env)
+	env.evm = vm.New(env, vm.Config{})
 
 	return env
 }
@@ -325,6 +332,15 @@ func (self *Env) DelegateCall(caller vm.ContractRef, addr common.Address, data [
 	return core.DelegateCall(self, caller, addr, data, gas, price)
 }
 
+func (self *Env) StaticCall(caller vm.ContractRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error) {
+	if self.vmTest && self.depth > 0 {
+		caller.ReturnGas(gas, price)
+
+		return nil, nil
+	}
+	return core.StaticCall(self, caller, addr, data, gas, price)
+}
+
 func (self *Env) Create(caller vm.ContractRef, data []byte, gas, price, value *big.Int) ([]byte, common.Address, error) {
 	if self.vmTest {
 		caller.ReturnGas(gas, price)
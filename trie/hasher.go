@@ -45,12 +45,14 @@ type hasher struct {
 	cachelimit uint16
 	threaded   bool
 	mu         sync.Mutex
+	onleaf     LeafCallback
 }
 
-func newHasher(cachegen, cachelimit uint16) *hasher {
+func newHasher(cachegen, cachelimit uint16, onleaf LeafCallback) *hasher {
 	h := &hasher{
 		cachegen:   cachegen,
 		cachelimit: cachelimit,
+		onleaf:     onleaf,
 	}
 	return h
 }
@@ -223,7 +225,25 @@ func (h *hasher) store(n node, db DatabaseWriter, force bool) (node, error) {
 		h.mu.Lock()
 		err := db.Put(hash, calculator.buffer.Bytes())
 		h.mu.Unlock()
-
+		if err != nil {
+			return hash, err
+		}
+		// Report the values stored directly in this node, so that callers can
+		// link them to the node (e.g. accounts to their storage tries).
+		if h.onleaf != nil {
+			switch n := n.(type) {
+			case *shortNode:
+				if child, ok := n.Val.(valueNode); ok && len(child) > 0 {
+					err = h.onleaf(child, common.BytesToHash(hash))
+				}
+			case *fullNode:
+				for i := 0; i < 16 && err == nil; i++ {
+					if child, ok := n.Children[i].(valueNode); ok && len(child) > 0 {
+						err = h.onleaf(child, common.BytesToHash(hash))
+					}
+				}
+			}
+		}
 		return hash, err
 	}
 	return hash, nil
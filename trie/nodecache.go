@@ -0,0 +1,349 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"sync"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+)
+
+// cachedNode is a trie node held in memory by a NodeCache, together with its
+// reference counts and its position in the flush list.
+type cachedNode struct {
+	blob     []byte              // RLP encoded node
+	parents  int                 // Number of live nodes referencing this one
+	children map[common.Hash]int // Cached nodes referenced by this one
+
+	flushPrev common.Hash // Previous node in the flush list
+	flushNext common.Hash // Next node in the flush list
+}
+
+// size returns the approximate memory used by the cached node.
+func (n *cachedNode) size() common.StorageSize {
+	return common.StorageSize(common.HashLength + len(n.blob))
+}
+
+// NodeCache is an in-memory, reference counted store of trie nodes sitting
+// between the tries and the disk database. Nodes written into the cache are
+// kept in memory until they are garbage collected by Dereference or persisted
+// by Commit or Cap, which allows state tries of short lived blocks to never
+// touch the disk at all.
+//
+// Keys which are not trie node hashes (e.g. secure trie preimages) are passed
+// straight through to the disk database.
+type NodeCache struct {
+	diskdb ethdb.Database
+
+	nodes  map[common.Hash]*cachedNode
+	oldest common.Hash // Oldest tracked node, flush list head
+	newest common.Hash // Newest tracked node, flush list tail
+	size   common.StorageSize
+
+	lock sync.RWMutex
+}
+
+// NewNodeCache creates an empty node cache on top of the given disk database.
+func NewNodeCache(diskdb ethdb.Database) *NodeCache {
+	return &NodeCache{
+		diskdb: diskdb,
+		nodes: map[common.Hash]*cachedNode{
+			// The empty hash is the meta root holding all external references.
+			{}: {children: make(map[common.Hash]int)},
+		},
+	}
+}
+
+// DiskDB returns the database the cache flushes its nodes into.
+func (c *NodeCache) DiskDB() ethdb.Database {
+	return c.diskdb
+}
+
+// Get retrieves a node from the cache, falling back to the disk database.
+func (c *NodeCache) Get(key []byte) ([]byte, error) {
+	if len(key) == common.HashLength {
+		c.lock.RLock()
+		node := c.nodes[common.BytesToHash(key)]
+		c.lock.RUnlock()
+
+		if node != nil && node.blob != nil {
+			return node.blob, nil
+		}
+	}
+	return c.diskdb.Get(key)
+}
+
+// Has reports whether a node is present in the cache or the disk database.
+func (c *NodeCache) Has(key []byte) (bool, error) {
+	if len(key) == common.HashLength {
+		c.lock.RLock()
+		node := c.nodes[common.BytesToHash(key)]
+		c.lock.RUnlock()
+
+		if node != nil && node.blob != nil {
+			return true, nil
+		}
+	}
+	return c.diskdb.Has(key)
+}
+
+// Put inserts a trie node into the cache, referencing all of its children
+// which are also cached. Keys other than node hashes are written to disk.
+func (c *NodeCache) Put(key, value []byte) error {
+	if len(key) != common.HashLength {
+		return c.diskdb.Put(key, value)
+	}
+	hash := common.BytesToHash(key)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.nodes[hash]; ok {
+		return nil
+	}
+	n, err := decodeNode(key, value, 0)
+	if err != nil {
+		return err
+	}
+	entry := &cachedNode{
+		blob:      common.CopyBytes(value),
+		flushPrev: c.newest,
+	}
+	c.nodes[hash] = entry
+	forHashChildren(n, func(child common.Hash) {
+		c.reference(child, hash)
+	})
+	// Append the node to the end of the flush list
+	if c.oldest == (common.Hash{}) {
+		c.oldest, c.newest = hash, hash
+	} else {
+		c.nodes[c.newest].flushNext, c.newest = hash, hash
+	}
+	c.size += entry.size()
+	return nil
+}
+
+// forHashChildren invokes fn for every child of n that is referenced by hash.
+func forHashChildren(n node, fn func(common.Hash)) {
+	switch n := n.(type) {
+	case *shortNode:
+		forHashChildren(n.Val, fn)
+	case *fullNode:
+		for i := 0; i < 16; i++ {
+			forHashChildren(n.Children[i], fn)
+		}
+	case hashNode:
+		fn(common.BytesToHash(n))
+	}
+}
+
+// Reference adds a reference from parent to child. The empty parent hash
+// denotes an external reference, such as a block state root being in use.
+func (c *NodeCache) Reference(child, parent common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.reference(child, parent)
+}
+
+func (c *NodeCache) reference(child, parent common.Hash) {
+	node, ok := c.nodes[child]
+	if !ok || child == (common.Hash{}) {
+		return
+	}
+	owner, ok := c.nodes[parent]
+	if !ok {
+		return
+	}
+	if owner.children == nil {
+		owner.children = make(map[common.Hash]int)
+	} else if owner.children[child] > 0 && parent != (common.Hash{}) {
+		// Internal references are only counted once per parent
+		return
+	}
+	node.parents++
+	owner.children[child]++
+}
+
+// Dereference removes an external reference from a root node, deleting every
+// cached node of the trie which is no longer referenced from anywhere.
+func (c *NodeCache) Dereference(root common.Hash) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.dereference(root, common.Hash{})
+}
+
+func (c *NodeCache) dereference(child, parent common.Hash) {
+	if owner, ok := c.nodes[parent]; ok && owner.children[child] > 0 {
+		owner.children[child]--
+		if owner.children[child] == 0 {
+			delete(owner.children, child)
+		}
+	}
+	node, ok := c.nodes[child]
+	if !ok {
+		return
+	}
+	if node.parents > 0 {
+		node.parents--
+	}
+	if node.parents == 0 {
+		c.unlink(child, node)
+		for hash := range node.children {
+			c.dereference(hash, child)
+		}
+		delete(c.nodes, child)
+		c.size -= node.size()
+	}
+}
+
+// unlink removes a node from the flush list.
+func (c *NodeCache) unlink(hash common.Hash, node *cachedNode) {
+	switch hash {
+	case c.oldest:
+		c.oldest = node.flushNext
+		if c.oldest != (common.Hash{}) {
+			c.nodes[c.oldest].flushPrev = common.Hash{}
+		}
+	case c.newest:
+		c.newest = node.flushPrev
+		c.nodes[c.newest].flushNext = common.Hash{}
+	default:
+		c.nodes[node.flushPrev].flushNext = node.flushNext
+		c.nodes[node.flushNext].flushPrev = node.flushPrev
+	}
+	if c.oldest == (common.Hash{}) {
+		c.newest = common.Hash{}
+	}
+}
+
+// Commit writes the trie rooted at root, including every storage trie it
+// references, from the cache to the disk database and drops the written nodes
+// from memory.
+func (c *NodeCache) Commit(root common.Hash) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	batch := c.diskdb.NewBatch()
+	if err := c.commit(root, &batch); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	c.uncache(root)
+	return nil
+}
+
+func (c *NodeCache) commit(hash common.Hash, batch *ethdb.Batch) error {
+	node, ok := c.nodes[hash]
+	if !ok || node.blob == nil {
+		return nil
+	}
+	for child := range node.children {
+		if err := c.commit(child, batch); err != nil {
+			return err
+		}
+	}
+	if err := (*batch).Put(hash[:], node.blob); err != nil {
+		return err
+	}
+	if (*batch).ValueSize() >= ethdb.IdealBatchSize {
+		if err := (*batch).Write(); err != nil {
+			return err
+		}
+		*batch = c.diskdb.NewBatch()
+	}
+	return nil
+}
+
+// uncache drops a persisted node and all of its cached children from memory.
+func (c *NodeCache) uncache(hash common.Hash) {
+	node, ok := c.nodes[hash]
+	if !ok || node.blob == nil {
+		return
+	}
+	c.unlink(hash, node)
+	for child := range node.children {
+		c.uncache(child)
+	}
+	delete(c.nodes, hash)
+	c.size -= node.size()
+}
+
+// Cap writes the oldest cached nodes to disk until the memory used by the
+// cache drops below the given limit. Since children are always inserted
+// before their parents, the disk database never ends up with a node whose
+// children are missing.
+func (c *NodeCache) Cap(limit common.StorageSize) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var (
+		batch = c.diskdb.NewBatch()
+		size  = c.size
+		next  = c.oldest
+	)
+	for size > limit && next != (common.Hash{}) {
+		node := c.nodes[next]
+		if err := batch.Put(next[:], node.blob); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch = c.diskdb.NewBatch()
+		}
+		size -= node.size()
+		next = node.flushNext
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	// Everything up to next is on disk now, drop it from memory
+	for c.oldest != next {
+		node := c.nodes[c.oldest]
+		delete(c.nodes, c.oldest)
+		c.oldest = node.flushNext
+	}
+	if c.oldest != (common.Hash{}) {
+		c.nodes[c.oldest].flushPrev = common.Hash{}
+	} else {
+		c.newest = common.Hash{}
+	}
+	c.size = size
+	return nil
+}
+
+// Size returns the approximate memory used by the cached nodes.
+func (c *NodeCache) Size() common.StorageSize {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.size
+}
+
+// Nodes returns the number of trie nodes currently held in memory.
+func (c *NodeCache) Nodes() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.nodes) - 1 // Don't count the meta root
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereumproject/go-ethereum/common"
+	"github.com/ethereumproject/go-ethereum/ethdb"
+)
+
+// commitToCache fills a new trie on top of base with the given number of keys
+// and commits it into the cache.
+func commitToCache(t *testing.T, cache *NodeCache, base common.Hash, from, to byte) common.Hash {
+	tr, err := New(base, cache)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", base, err)
+	}
+	for i := from; i < to; i++ {
+		tr.Update(common.LeftPadBytes([]byte{i}, 32), bytes.Repeat([]byte{i}, 40))
+	}
+	root, err := tr.CommitTo(cache)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	cache.Reference(root, common.Hash{})
+	return root
+}
+
+// checkCachedTrie verifies that the trie at root is fully resolvable from db.
+func checkCachedTrie(t *testing.T, db Database, root common.Hash, keys byte) {
+	tr, err := New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	for i := byte(0); i < keys; i++ {
+		val, err := tr.TryGet(common.LeftPadBytes([]byte{i}, 32))
+		if err != nil {
+			t.Fatalf("failed to retrieve key %d: %v", i, err)
+		}
+		if !bytes.Equal(val, bytes.Repeat([]byte{i}, 40)) {
+			t.Fatalf("key %d: value mismatch: have %x", i, val)
+		}
+	}
+	it := NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+	}
+	if it.Err != nil {
+		t.Fatalf("failed to iterate trie %x: %v", root, it.Err)
+	}
+}
+
+// Tests that tries committed into the cache don't touch the disk and that
+// dereferencing them releases all their nodes.
+func TestNodeCacheDereference(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	cache := NewNodeCache(diskdb)
+
+	root1 := commitToCache(t, cache, common.Hash{}, 0, 100)
+	root2 := commitToCache(t, cache, root1, 100, 120)
+
+	if len(diskdb.Keys()) != 0 {
+		t.Fatalf("disk database written to: %d entries", len(diskdb.Keys()))
+	}
+	checkCachedTrie(t, cache, root1, 100)
+	checkCachedTrie(t, cache, root2, 120)
+
+	// Dropping the first trie must retain all nodes shared with the second
+	nodes := cache.Nodes()
+	cache.Dereference(root1)
+	if cache.Nodes() >= nodes {
+		t.Fatalf("no nodes released: have %d, had %d", cache.Nodes(), nodes)
+	}
+	checkCachedTrie(t, cache, root2, 120)
+
+	cache.Dereference(root2)
+	if cache.Nodes() != 0 || cache.Size() != 0 {
+		t.Fatalf("cache not empty: %d nodes, %v", cache.Nodes(), cache.Size())
+	}
+	if len(diskdb.Keys()) != 0 {
+		t.Fatalf("disk database written to: %d entries", len(diskdb.Keys()))
+	}
+}
+
+// Tests that committing a root persists the full trie and releases its nodes.
+func TestNodeCacheCommit(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	cache := NewNodeCache(diskdb)
+
+	root1 := commitToCache(t, cache, common.Hash{}, 0, 100)
+	root2 := commitToCache(t, cache, root1, 100, 120)
+
+	if err := cache.Commit(root2); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	checkCachedTrie(t, diskdb, root2, 120)
+	if _, err := New(root1, diskdb); err == nil {
+		t.Fatalf("uncommitted root %x found on disk", root1)
+	}
+	// Nodes unique to the first trie are still cached
+	checkCachedTrie(t, cache, root1, 100)
+	cache.Dereference(root1)
+	cache.Dereference(root2)
+	if cache.Nodes() != 0 || cache.Size() != 0 {
+		t.Fatalf("cache not empty: %d nodes, %v", cache.Nodes(), cache.Size())
+	}
+}
+
+// Tests that capping the cache flushes the oldest nodes first and that all
+// tries remain accessible afterwards.
+func TestNodeCacheCap(t *testing.T) {
+	diskdb, _ := ethdb.NewMemDatabase()
+	cache := NewNodeCache(diskdb)
+
+	root1 := commitToCache(t, cache, common.Hash{}, 0, 100)
+	root2 := commitToCache(t, cache, root1, 100, 120)
+
+	limit := cache.Size() / 2
+	if err := cache.Cap(limit); err != nil {
+		t.Fatalf("failed to cap cache: %v", err)
+	}
+	if cache.Size() > limit {
+		t.Fatalf("cache size above limit: have %v, want <= %v", cache.Size(), limit)
+	}
+	if len(diskdb.Keys()) == 0 {
+		t.Fatalf("nothing flushed to disk")
+	}
+	checkCachedTrie(t, cache, root1, 100)
+	checkCachedTrie(t, cache, root2, 120)
+
+	if err := cache.Cap(0); err != nil {
+		t.Fatalf("failed to cap cache: %v", err)
+	}
+	if cache.Nodes() != 0 {
+		t.Fatalf("cache not empty: %d nodes", cache.Nodes())
+	}
+	checkCachedTrie(t, diskdb, root1, 100)
+	checkCachedTrie(t, diskdb, root2, 120)
+}
@@ -33,6 +33,12 @@ var ErrNotRequested = errors.New("not requested")
 // node it already processed previously.
 var ErrAlreadyProcessed = errors.New("already processed")
 
+// syncJournalPrefix + hash -> node data of a retrieved trie node whose subtries
+// aren't complete yet. Nodes are only written under their hash once everything
+// below them is, so these are kept aside to resume an interrupted sync without
+// retrieving them again.
+var syncJournalPrefix = []byte("sync-journal-")
+
 // request represents a scheduled or already in-flight state retrieval request.
 type request struct {
 	hash   common.Hash // Hash of the node data content to retrieve
@@ -77,20 +83,53 @@ type Sync struct {
 	membatch *syncMemBatch            // Memory buffer to avoid frequest database writes
 	requests map[common.Hash]*request // Pending requests pertaining to a key hash
 	queue    *prque.Prque             // Priority queue with the pending requests
+	bloom    *SyncBloom               // Filter of the nodes in the database, nil to always look them up
+
+	resumed   map[common.Hash][]byte   // Journaled nodes of an interrupted sync, not needed yet
+	journaled map[common.Hash]struct{} // Pending nodes present in the journal
+	fresh     []common.Hash            // Pending nodes to add to the journal
+	stale     []common.Hash            // Completed nodes to drop from the journal
 }
 
-// NewTrieSync creates a new trie data download scheduler.
+// NewTrieSync creates a new trie data download scheduler. The nodes journaled
+// by an interrupted sync are picked up from the database, so they don't need
+// to be retrieved again.
 func NewTrieSync(root common.Hash, database ethdb.Database, callback LeafCallback) *Sync {
 	ts := &Sync{
-		database: database,
-		membatch: newSyncMemBatch(),
-		requests: make(map[common.Hash]*request),
-		queue:    prque.New(),
+		database:  database,
+		membatch:  newSyncMemBatch(),
+		requests:  make(map[common.Hash]*request),
+		queue:     prque.New(),
+		resumed:   make(map[common.Hash][]byte),
+		journaled: make(map[common.Hash]struct{}),
+	}
+	it := database.NewIterator(syncJournalPrefix, nil)
+	for it.Next() {
+		hash := common.BytesToHash(it.Key()[len(syncJournalPrefix):])
+		ts.resumed[hash] = common.CopyBytes(it.Value())
 	}
+	it.Release()
+
 	ts.AddSubTrie(root, 0, common.Hash{}, callback)
 	return ts
 }
 
+// SetBloom sets the filter used to skip the database lookups of the nodes not
+// retrieved yet. It must hold every node of the database the sync may come
+// across, any other one is retrieved again.
+func (s *Sync) SetBloom(bloom *SyncBloom) {
+	s.bloom = bloom
+}
+
+// known reports whether a trie node or raw entry is already in the database.
+func (s *Sync) known(hash common.Hash) bool {
+	if s.bloom != nil && !s.bloom.Contains(hash) {
+		return false
+	}
+	ok, _ := s.database.Has(hash.Bytes())
+	return ok
+}
+
 // AddSubTrie registers a new trie to the sync code, rooted at the designated parent.
 func (s *Sync) AddSubTrie(root common.Hash, depth int, parent common.Hash, callback LeafCallback) {
 	// Short circuit if the trie is empty or already known
@@ -100,10 +139,12 @@ func (s *Sync) AddSubTrie(root common.Hash, depth int, parent common.Hash, callb
 	if _, ok := s.membatch.batch[root]; ok {
 		return
 	}
-	key := root.Bytes()
-	blob, _ := s.database.Get(key)
-	if local, err := decodeNode(key, blob, 0); local != nil && err == nil {
-		return
+	if s.bloom == nil || s.bloom.Contains(root) {
+		key := root.Bytes()
+		blob, _ := s.database.Get(key)
+		if local, err := decodeNode(key, blob, 0); local != nil && err == nil {
+			return
+		}
 	}
 	// Assemble the new sub-trie sync request
 	req := &request{
@@ -135,7 +176,7 @@ func (s *Sync) AddRawEntry(hash common.Hash, depth int, parent common.Hash) {
 	if _, ok := s.membatch.batch[hash]; ok {
 		return
 	}
-	if blob, _ := s.database.Get(hash.Bytes()); blob != nil {
+	if s.known(hash) {
 		return
 	}
 	// Assemble the new sub-trie sync request
@@ -204,6 +245,9 @@ func (s *Sync) Process(results []SyncResult) (bool, int, error) {
 			committed = true
 			continue
 		}
+		if _, ok := s.journaled[request.hash]; !ok {
+			s.fresh = append(s.fresh, request.hash)
+		}
 		request.deps += len(requests)
 		for _, child := range requests {
 			s.schedule(child)
@@ -220,6 +264,9 @@ func (s *Sync) Commit(dbw DatabaseWriter) (int, error) {
 		if err := dbw.Put(key[:], s.membatch.batch[key]); err != nil {
 			return i, err
 		}
+		if s.bloom != nil {
+			s.bloom.Add(key)
+		}
 	}
 	written := len(s.membatch.order)
 
@@ -228,6 +275,44 @@ func (s *Sync) Commit(dbw DatabaseWriter) (int, error) {
 	return written, nil
 }
 
+// Journal stores the retrieved trie nodes still waiting for their subtries
+// into the journal, and drops the ones completed since the last call. It
+// should go into the same batch as the preceding Commit, so the journal always
+// holds the nodes missing from the database. Once the sync is done the whole
+// journal is dropped.
+func (s *Sync) Journal(batch ethdb.Batch) error {
+	for _, hash := range s.fresh {
+		// Skip the nodes completed in the meantime
+		req := s.requests[hash]
+		if req == nil || req.data == nil {
+			continue
+		}
+		if err := batch.Put(append(syncJournalPrefix, hash[:]...), req.data); err != nil {
+			return err
+		}
+		s.journaled[hash] = struct{}{}
+	}
+	s.fresh = s.fresh[:0]
+
+	for _, hash := range s.stale {
+		if err := batch.Delete(append(syncJournalPrefix, hash[:]...)); err != nil {
+			return err
+		}
+	}
+	s.stale = s.stale[:0]
+
+	// Drop the journal of an interrupted sync that wasn't needed after all
+	if s.Pending() == 0 {
+		for hash := range s.resumed {
+			if err := batch.Delete(append(syncJournalPrefix, hash[:]...)); err != nil {
+				return err
+			}
+		}
+		s.resumed = make(map[common.Hash][]byte)
+	}
+	return nil
+}
+
 // Pending returns the number of state entries currently pending for download.
 func (s *Sync) Pending() int {
 	return len(s.requests)
@@ -242,9 +327,23 @@ func (s *Sync) schedule(req *request) {
 		old.parents = append(old.parents, req.parents...)
 		return
 	}
+	s.requests[req.hash] = req
+
+	// Process the nodes journaled by an interrupted sync right away, scheduling
+	// only their missing children for retrieval
+	if data, ok := s.resumed[req.hash]; ok {
+		delete(s.resumed, req.hash)
+		s.journaled[req.hash] = struct{}{}
+		if _, _, err := s.Process([]SyncResult{{Hash: req.hash, Data: data}}); err == nil {
+			return
+		}
+		// Corrupt journal entry, retrieve the node instead
+		req.data = nil
+		s.stale = append(s.stale, req.hash)
+		delete(s.journaled, req.hash)
+	}
 	// Schedule the request for future retrieval
 	s.queue.Push(req.hash, float32(req.depth))
-	s.requests[req.hash] = req
 }
 
 // children retrieves all the missing children of a state trie entry for future
@@ -293,7 +392,7 @@ func (s *Sync) children(req *request, object node) ([]*request, error) {
 			if _, ok := s.membatch.batch[hash]; ok {
 				continue
 			}
-			if ok, _ := s.database.Has(node); ok {
+			if s.known(hash) {
 				continue
 			}
 			// Locally unknown node, schedule for retrieval
@@ -317,6 +416,10 @@ func (s *Sync) commit(req *request) (err error) {
 	s.membatch.order = append(s.membatch.order, req.hash)
 
 	delete(s.requests, req.hash)
+	if _, ok := s.journaled[req.hash]; ok {
+		delete(s.journaled, req.hash)
+		s.stale = append(s.stale, req.hash)
+	}
 
 	// Check all parents for completion
 	for _, parent := range req.parents {
@@ -0,0 +1,87 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/ethereumproject/go-ethereum/common"
+)
+
+// syncBloomHashes is the number of bits set in a SyncBloom for each node.
+const syncBloomHashes = 4
+
+// SyncBloom is a bloom filter of the trie nodes in the database, letting the
+// trie sync skip the database lookups of the nodes it doesn't have yet. The
+// node hashes are uniformly distributed already, so the filter bits are taken
+// from them directly.
+type SyncBloom struct {
+	bits []byte
+	lock sync.RWMutex
+}
+
+// NewSyncBloom creates an empty bloom filter of the given size in bytes.
+func NewSyncBloom(size int) *SyncBloom {
+	return &SyncBloom{bits: make([]byte, size)}
+}
+
+// LoadSyncBloom creates a bloom filter from the contents of a previous one,
+// as returned by Bytes.
+func LoadSyncBloom(data []byte) *SyncBloom {
+	return &SyncBloom{bits: common.CopyBytes(data)}
+}
+
+// Add adds a node hash to the filter.
+func (b *SyncBloom) Add(hash common.Hash) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for i := 0; i < syncBloomHashes; i++ {
+		bit := b.bit(hash, i)
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Contains reports whether a node hash may have been added to the filter. A
+// false result is certain, a true one may not be.
+func (b *SyncBloom) Contains(hash common.Hash) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for i := 0; i < syncBloomHashes; i++ {
+		bit := b.bit(hash, i)
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Bytes returns the contents of the filter, to be persisted and loaded back
+// with LoadSyncBloom.
+func (b *SyncBloom) Bytes() []byte {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return common.CopyBytes(b.bits)
+}
+
+// bit returns the i-th filter bit of a node hash.
+func (b *SyncBloom) bit(hash common.Hash, i int) uint64 {
+	return binary.BigEndian.Uint64(hash[i*8:]) % uint64(len(b.bits)*8)
+}
@@ -349,3 +349,120 @@ func TestIncompleteTrieSync(t *testing.T) {
 		dstDb.Put(key, value)
 	}
 }
+
+// Tests that an interrupted sync resumes from the nodes it journaled, without
+// requesting them again, and drops the journal once done.
+func TestResumedTrieSync(t *testing.T) {
+	// Create a random trie to copy
+	srcDb, srcTrie, srcData := makeTestTrie()
+	root := common.BytesToHash(srcTrie.Root())
+
+	// Sync a few rounds and interrupt the sync, journaling the pending nodes
+	dstDb, _ := ethdb.NewMemDatabase()
+	sched := NewTrieSync(root, dstDb, nil)
+
+	retrieved := make(map[common.Hash]struct{})
+	queue := append([]common.Hash{}, sched.Missing(0)...)
+	for round := 0; round < 2 && len(queue) > 0; round++ {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcDb.Get(hash.Bytes())
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+			retrieved[hash] = struct{}{}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		queue = append(queue[:0], sched.Missing(0)...)
+	}
+	batch := dstDb.NewBatch()
+	if _, err := sched.Commit(batch); err != nil {
+		t.Fatalf("failed to commit data: %v", err)
+	}
+	if err := sched.Journal(batch); err != nil {
+		t.Fatalf("failed to journal data: %v", err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	// Restart the sync and make sure nothing is retrieved twice
+	sched = NewTrieSync(root, dstDb, nil)
+	queue = append(queue[:0], sched.Missing(0)...)
+	for len(queue) > 0 {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			if _, ok := retrieved[hash]; ok {
+				t.Fatalf("node %x requested again after restart", hash)
+			}
+			data, err := srcDb.Get(hash.Bytes())
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		batch := dstDb.NewBatch()
+		if _, err := sched.Commit(batch); err != nil {
+			t.Fatalf("failed to commit data: %v", err)
+		}
+		if err := sched.Journal(batch); err != nil {
+			t.Fatalf("failed to journal data: %v", err)
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatalf("failed to write batch: %v", err)
+		}
+		queue = append(queue[:0], sched.Missing(0)...)
+	}
+	// Cross check that the two tries are in sync and the journal is gone
+	checkTrieContents(t, dstDb, srcTrie.Root(), srcData)
+
+	it := dstDb.NewIterator(syncJournalPrefix, nil)
+	defer it.Release()
+	if it.Next() {
+		t.Errorf("journal entry left after sync: %x", it.Key())
+	}
+}
+
+// Tests that a trie sync with a bloom filter completes, adding all the synced
+// nodes to the filter.
+func TestTrieSyncBloom(t *testing.T) {
+	srcDb, srcTrie, srcData := makeTestTrie()
+
+	bloom := NewSyncBloom(1024)
+	dstDb, _ := ethdb.NewMemDatabase()
+	sched := NewTrieSync(common.BytesToHash(srcTrie.Root()), dstDb, nil)
+	sched.SetBloom(bloom)
+
+	queue := append([]common.Hash{}, sched.Missing(0)...)
+	for len(queue) > 0 {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcDb.Get(hash.Bytes())
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		if index, err := sched.Commit(dstDb); err != nil {
+			t.Fatalf("failed to commit data #%d: %v", index, err)
+		}
+		queue = append(queue[:0], sched.Missing(0)...)
+	}
+	checkTrieContents(t, dstDb, srcTrie.Root(), srcData)
+
+	// All the synced nodes are in the filter, and survive a reload
+	bloom = LoadSyncBloom(bloom.Bytes())
+	for _, key := range dstDb.Keys() {
+		if !bloom.Contains(common.BytesToHash(key)) {
+			t.Fatalf("synced node %x missing from the bloom", key)
+		}
+	}
+}
@@ -54,9 +54,10 @@ func CacheUnloads() int64 {
 	return cacheUnloadCounter.Count()
 }
 
-// LeafCallback is a callback type invoked when a trie sync reaches a
+// LeafCallback is a callback type invoked when a trie sync or commit reaches a
 // leaf node. It's used by state syncing to check if the leaf node requires some
-// further data syncing.
+// further data syncing, and by state commits to reference storage tries from
+// the account trie nodes holding them.
 type LeafCallback func(leaf []byte, parent common.Hash) error
 
 // Database must be implemented by backing stores for the trie.
@@ -463,7 +464,7 @@ func (t *Trie) Root() []byte { return t.Hash().Bytes() }
 // Hash returns the root hash of the trie. It does not write to the
 // database and can be used even if the trie doesn't have one.
 func (t *Trie) Hash() common.Hash {
-	hash, cached, _ := t.hashRoot(nil)
+	hash, cached, _ := t.hashRoot(nil, nil)
 	t.root = cached
 	return common.BytesToHash(hash.(hashNode))
 }
@@ -488,7 +489,13 @@ func (t *Trie) Commit() (root common.Hash, err error) {
 // the changes made to db are written back to the trie's attached
 // database before using the trie.
 func (t *Trie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
-	hash, cached, err := t.hashRoot(db)
+	return t.CommitToWithCallback(db, nil)
+}
+
+// CommitToWithCallback writes all nodes to the given database like CommitTo,
+// invoking onleaf with every leaf value and the hash of the node storing it.
+func (t *Trie) CommitToWithCallback(db DatabaseWriter, onleaf LeafCallback) (root common.Hash, err error) {
+	hash, cached, err := t.hashRoot(db, onleaf)
 	if err != nil {
 		return (common.Hash{}), err
 	}
@@ -497,10 +504,10 @@ func (t *Trie) CommitTo(db DatabaseWriter) (root common.Hash, err error) {
 	return common.BytesToHash(hash.(hashNode)), nil
 }
 
-func (t *Trie) hashRoot(db DatabaseWriter) (node, node, error) {
+func (t *Trie) hashRoot(db DatabaseWriter, onleaf LeafCallback) (node, node, error) {
 	if t.root == nil {
 		return hashNode(emptyRoot.Bytes()), nil, nil
 	}
-	h := newHasher(t.cachegen, t.cachelimit)
+	h := newHasher(t.cachegen, t.cachelimit, onleaf)
 	return h.hash(t.root, db, true)
 }